@@ -0,0 +1,139 @@
+package gofpdf
+
+import (
+	"fmt"
+)
+
+// Action is an action that the document reader performs, such as the action
+// performed when the document is opened, set with SetOpenAction(). It is
+// one of ActionGoTo, ActionJavaScript, ActionURI and ActionNamed.
+type Action interface {
+	// actionDict returns the action dictionary of the action
+	actionDict(f *Fpdf) string
+}
+
+// ActionGoTo goes to the position Y, in the unit of measure specified in
+// New(), on the page with the one-based number Page.
+type ActionGoTo struct {
+	Page int
+	Y    float64
+}
+
+// ActionJavaScript runs the JavaScript code Script.
+type ActionJavaScript struct {
+	Script string
+}
+
+// ActionURI opens the resource URI, usually a web page.
+type ActionURI struct {
+	URI string
+}
+
+// ActionNamed performs the menu command Name of the reader, which is one of
+// "NextPage", "PrevPage", "FirstPage", "LastPage" or, in many readers,
+// "Print".
+type ActionNamed struct {
+	Name string
+}
+
+func (a ActionGoTo) actionDict(f *Fpdf) string {
+	if a.Page < 1 || a.Page > f.page {
+		f.err = fmt.Errorf("invalid page %d of action", a.Page)
+	}
+	return "<</S /GoTo /D " + f.pageDest(a.Page, a.Y) + ">>"
+}
+
+func (a ActionJavaScript) actionDict(f *Fpdf) string {
+	return "<</S /JavaScript /JS " + f.textstring(a.Script) + ">>"
+}
+
+func (a ActionURI) actionDict(f *Fpdf) string {
+	return "<</S /URI /URI " + f.textstring(a.URI) + ">>"
+}
+
+func (a ActionNamed) actionDict(f *Fpdf) string {
+	return "<</S /Named /N " + pdfName(a.Name) + ">>"
+}
+
+// Events of a page that trigger the actions set with SetPageAction()
+const (
+	// PageEventOpen occurs when the page is opened, that is, displayed.
+	PageEventOpen = "O"
+	// PageEventClose occurs when another page is displayed instead of the
+	// page.
+	PageEventClose = "C"
+)
+
+// SetOpenAction sets the action that the document reader performs when the
+// document is opened, for example ActionGoTo{Page: 2} to display the second
+// page or ActionJavaScript to initialize a form. It takes the place of the
+// zoom mode set with SetDisplayMode(). A nil action removes the open action.
+//
+// The SetOpenAction example demonstrates this method.
+func (f *Fpdf) SetOpenAction(action Action) {
+	f.openAction = action
+}
+
+// SetPageAction sets the action that the document reader performs when the
+// event, PageEventOpen or PageEventClose, occurs on the current page, for
+// example JavaScript that updates the fields of a form. A nil action removes
+// the action of the event.
+//
+// The SetOpenAction example demonstrates this method.
+func (f *Fpdf) SetPageAction(event string, action Action) {
+	if f.err != nil {
+		return
+	}
+	if event != PageEventOpen && event != PageEventClose {
+		f.err = fmt.Errorf("invalid page event %q", event)
+		return
+	}
+	if f.page == 0 {
+		f.err = fmt.Errorf("a page must be added before a page action")
+		return
+	}
+	if f.pageActions == nil {
+		f.pageActions = make(map[int]map[string]Action)
+	}
+	if f.pageActions[f.page] == nil {
+		f.pageActions[f.page] = make(map[string]Action)
+	}
+	if action == nil {
+		delete(f.pageActions[f.page], event)
+		return
+	}
+	f.pageActions[f.page][event] = action
+}
+
+// actionJavaScript returns true if the open action or an action of a page is
+// JavaScript
+func (f *Fpdf) actionJavaScript() bool {
+	if _, ok := f.openAction.(ActionJavaScript); ok {
+		return true
+	}
+	for _, actions := range f.pageActions {
+		for _, action := range actions {
+			if _, ok := action.(ActionJavaScript); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// actionPutPage writes the additional actions of page n
+func (f *Fpdf) actionPutPage(n int) {
+	actions := f.pageActions[n]
+	if len(actions) == 0 {
+		return
+	}
+	var s fmtBuffer
+	s.printf("/AA <<")
+	for _, event := range []string{PageEventOpen, PageEventClose} {
+		if action, ok := actions[event]; ok {
+			s.printf("/%s %s ", event, action.actionDict(f))
+		}
+	}
+	s.printf(">>")
+	f.out(s.String())
+}
@@ -0,0 +1,398 @@
+package gofpdf
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// AnnotationOptions holds the properties of shape annotations, which mark
+// areas, distances and freehand drawings on a page.
+//
+// The properties of MarkupAnnotationOptions apply to shape annotations as
+// well, except Icon. Color is the color of the border of the shape.
+//
+// InteriorColor, if not nil, is the color with which squares, circles and
+// polygons, and the closed line endings of lines, are filled. If it is nil,
+// they are not filled.
+//
+// BorderWidth is the width of the border, in the unit of measure of the
+// document. If it is zero, the border is one point wide. BorderDashes, if not
+// empty, makes the border dashed, with the lengths of the dashes and gaps, in
+// the unit of measure of the document, that SetDashPattern() takes.
+//
+// LineEndings are the styles of the start and the end of lines and
+// polylines: "None", "Square", "Circle", "Diamond", "OpenArrow" or
+// "ClosedArrow". An empty string is the same as "None".
+//
+// Scale, if not nil, is the scale of a drawing, such as a construction
+// drawing, with which PDF readers measure and display the length of lines
+// and polylines, and the area of polygons.
+type AnnotationOptions struct {
+	MarkupAnnotationOptions
+	InteriorColor *RGBType
+	BorderWidth   float64
+	BorderDashes  []float64
+	LineEndings   [2]string
+	Scale         *AnnotationScale
+}
+
+// AnnotationScale is the scale of a drawing that shape annotations measure.
+//
+// Label describes the scale, as in "1 cm = 2 m". Factor is the number of
+// units of the drawing per unit of measure of the document, and Unit is the
+// name of the unit of the drawing: at the scale "1 cm = 2 m" in a document
+// measured in millimeters, Factor is 0.2 and Unit is "m". Measurements are
+// displayed with Decimals digits after the decimal point.
+type AnnotationScale struct {
+	Label    string
+	Factor   float64
+	Unit     string
+	Decimals int
+}
+
+// annotLineEndings are the styles of the line endings of lines and polylines
+var annotLineEndings = map[string]bool{
+	"None": true, "Square": true, "Circle": true, "Diamond": true, "OpenArrow": true, "ClosedArrow": true,
+}
+
+// annotDimensions are the intents of shape annotations that measure a
+// drawing
+var annotDimensions = map[string]string{
+	"Line":     "LineDimension",
+	"Polygon":  "PolygonDimension",
+	"PolyLine": "PolyLineDimension",
+}
+
+// AddShapeAnnotation adds a shape annotation of the specified subtype, with
+// the properties specified by options, to the current page. The points are
+// in the unit of measure of the document:
+//
+// "Square" and "Circle" annotations mark the rectangle, or the ellipse within
+// it, with the upper left and lower right corners points[0] and points[1].
+//
+// "Line" annotations are lines from points[0] to points[1].
+//
+// "Polygon" annotations are closed shapes with at least three vertices, and
+// "PolyLine" annotations are open ones with at least two vertices.
+//
+// "Ink" annotations are freehand drawings with the single stroke points;
+// AddInkAnnotation() adds drawings with several strokes.
+//
+// The annotation is drawn by gofpdf, so that it looks the same in every PDF
+// reader. An error occurs if subtype is unknown, if points has too few
+// vertices for it, if a line ending is unknown, or if options.Scale is given
+// for a subtype other than "Line", "Polygon" and "PolyLine".
+//
+// The AddShapeAnnotation example demonstrates this method.
+func (f *Fpdf) AddShapeAnnotation(subtype string, points []PointType, options AnnotationOptions) {
+	if f.err != nil {
+		return
+	}
+	need := map[string]int{"Square": 2, "Circle": 2, "Line": 2, "Polygon": 3, "PolyLine": 2, "Ink": 2}
+	n, ok := need[subtype]
+	switch {
+	case !ok:
+		f.err = fmt.Errorf("unrecognized shape annotation \"%s\"", subtype)
+	case len(points) < n || (n == 2 && subtype != "PolyLine" && subtype != "Ink" && len(points) != n):
+		f.err = fmt.Errorf("%s annotation needs %d points, not %d", subtype, n, len(points))
+	}
+	if f.err != nil {
+		return
+	}
+	f.addShapeAnnot(subtype, [][]PointType{points}, options)
+}
+
+// AddInkAnnotation adds an ink annotation, a freehand drawing with the
+// strokes strokes, each of at least two points, to the current page. It is
+// the same as AddShapeAnnotation() with the subtype "Ink" otherwise.
+//
+// The AddShapeAnnotation example demonstrates this method.
+func (f *Fpdf) AddInkAnnotation(strokes [][]PointType, options AnnotationOptions) {
+	if f.err != nil {
+		return
+	}
+	if len(strokes) == 0 {
+		f.err = fmt.Errorf("Ink annotation needs at least one stroke")
+		return
+	}
+	for _, stroke := range strokes {
+		if len(stroke) < 2 {
+			f.err = fmt.Errorf("Ink annotation needs 2 points per stroke, not %d", len(stroke))
+			return
+		}
+	}
+	f.addShapeAnnot("Ink", strokes, options)
+}
+
+// addShapeAnnot adds a shape annotation of the specified subtype with the
+// vertices of paths to the current page
+func (f *Fpdf) addShapeAnnot(subtype string, paths [][]PointType, options AnnotationOptions) {
+	if options = f.shapeAnnotOptions(options); f.err != nil {
+		return
+	}
+	if options.Scale != nil {
+		if annotDimensions[subtype] == "" {
+			f.err = fmt.Errorf("%s annotation cannot measure a drawing", subtype)
+			return
+		}
+		// The factor converts points
+		scale := *options.Scale
+		scale.Factor /= f.k
+		options.Scale = &scale
+	}
+	an := markupAnnotType{subtype: subtype}
+	x1, y1, x2, y2 := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, path := range paths {
+		vertices := make([]float64, 0, 2*len(path))
+		for _, pt := range path {
+			x, y := pt.X*f.k, f.hPt-pt.Y*f.k
+			vertices = append(vertices, x, y)
+			x1, y1, x2, y2 = math.Min(x1, x), math.Min(y1, y), math.Max(x2, x), math.Max(y2, y)
+		}
+		an.paths = append(an.paths, vertices)
+	}
+	if subtype == "Square" || subtype == "Circle" {
+		// The border is drawn within the rectangle
+		an.paths = nil
+	} else {
+		margin := options.BorderWidth / 2
+		if subtype == "Line" || subtype == "PolyLine" {
+			margin = math.Max(margin, annotEndingSize(options.BorderWidth))
+		}
+		x1, y1, x2, y2 = x1-margin, y1-margin, x2+margin, y2+margin
+	}
+	an.x, an.y, an.w, an.h = x1, y1, x2-x1, y2-y1
+	f.addMarkupAnnot(an, options)
+	if f.err == nil {
+		version := "1.5"
+		if options.Scale != nil {
+			version = "1.7"
+		}
+		if f.pdfVersion < version {
+			f.pdfVersion = version
+		}
+	}
+}
+
+// shapeAnnotOptions returns options with the border in points and the line
+// endings named, or sets the error of f if a line ending is unknown
+func (f *Fpdf) shapeAnnotOptions(options AnnotationOptions) AnnotationOptions {
+	for j, style := range options.LineEndings {
+		if style == "" {
+			options.LineEndings[j] = "None"
+		} else if !annotLineEndings[style] {
+			f.err = fmt.Errorf("unrecognized line ending \"%s\"", style)
+			return options
+		}
+	}
+	if options.BorderWidth == 0 {
+		options.BorderWidth = 1
+	} else {
+		options.BorderWidth *= f.k
+	}
+	dashes := make([]float64, len(options.BorderDashes))
+	for j, v := range options.BorderDashes {
+		dashes[j] = v * f.k
+	}
+	options.BorderDashes = dashes
+	options.Icon = ""
+	return options
+}
+
+// annotEndingSize returns the length of the line endings of lines with the
+// width w, in points
+func annotEndingSize(w float64) float64 {
+	return 6 * math.Max(w, 1)
+}
+
+// putShapeAnnotEntries writes the entries of the shape annotation an that
+// describe its shape, border and scale
+func (f *Fpdf) putShapeAnnotEntries(an markupAnnotType) {
+	var s fmtBuffer
+	s.printf("/F 4 /BS <</W %.2f", an.options.BorderWidth)
+	if len(an.options.BorderDashes) > 0 {
+		s.printf(" /S /D /D [%s]", annotNumbers(an.options.BorderDashes))
+	}
+	s.printf(">>")
+	if clr := an.options.InteriorColor; clr != nil {
+		s.printf(" /IC [%.3f %.3f %.3f]", float64(clr.R)/255, float64(clr.G)/255, float64(clr.B)/255)
+	}
+	switch an.subtype {
+	case "Line":
+		s.printf(" /L [%s]", annotNumbers(an.paths[0]))
+	case "Polygon", "PolyLine":
+		s.printf(" /Vertices [%s]", annotNumbers(an.paths[0]))
+	case "Ink":
+		s.printf(" /InkList [")
+		for j, path := range an.paths {
+			if j > 0 {
+				s.printf(" ")
+			}
+			s.printf("[%s]", annotNumbers(path))
+		}
+		s.printf("]")
+	}
+	if an.subtype == "Line" || an.subtype == "PolyLine" {
+		s.printf(" /LE [/%s /%s]", an.options.LineEndings[0], an.options.LineEndings[1])
+	}
+	f.out(s.String())
+	if sc := an.options.Scale; sc != nil {
+		// Distances and areas are measured in the unit of the x axis
+		d := 1
+		for j := 0; j < sc.Decimals; j++ {
+			d *= 10
+		}
+		format := func(unit string, c float64) string {
+			return sprintf("[<</Type /NumberFormat /U %s /C %.6f /F /D /D %d>>]",
+				f.textstring(utf8toutf16(unit)), c, d)
+		}
+		f.outf("/IT /%s", annotDimensions[an.subtype])
+		if an.subtype == "Line" {
+			f.out("/Cap true")
+		}
+		f.outf("/Measure <</Type /Measure /Subtype /RL /R %s /X %s /D %s /A %s>>",
+			f.textstring(utf8toutf16(sc.Label)), format(sc.Unit, sc.Factor), format(sc.Unit, 1), format("sq "+sc.Unit, 1))
+	}
+}
+
+// annotNumbers returns the numbers of list, separated by spaces
+func annotNumbers(list []float64) string {
+	s := make([]string, len(list))
+	for j, v := range list {
+		s[j] = sprintf("%.2f", v)
+	}
+	return strings.Join(s, " ")
+}
+
+// drawShapeAppearance writes the operators that draw the shape annotation an
+// to b
+func (f *Fpdf) drawShapeAppearance(b *fmtBuffer, an markupAnnotType) {
+	clr, w := an.options.Color, an.options.BorderWidth
+	b.printf("%.3f %.3f %.3f RG %.2f w", float64(clr.R)/255, float64(clr.G)/255, float64(clr.B)/255, w)
+	fill := an.options.InteriorColor != nil
+	if fill {
+		ic := an.options.InteriorColor
+		b.printf(" %.3f %.3f %.3f rg", float64(ic.R)/255, float64(ic.G)/255, float64(ic.B)/255)
+	}
+	if len(an.options.BorderDashes) > 0 {
+		b.printf(" [%s] 0 d", annotNumbers(an.options.BorderDashes))
+	}
+	b.printf("\n")
+	// op returns the operator that paints a closed path, filled if closed
+	// shapes are filled
+	op := func() string {
+		if fill {
+			return "b"
+		}
+		return "s"
+	}
+	switch an.subtype {
+	case "Square":
+		b.printf("%.2f %.2f %.2f %.2f re %s\n", an.x+w/2, an.y+w/2, an.w-w, an.h-w, op())
+	case "Circle":
+		rx, ry := (an.w-w)/2, (an.h-w)/2
+		cx, cy := an.x+an.w/2, an.y+an.h/2
+		// The ellipse is made of four Bézier curves
+		const kappa = 0.5523
+		kx, ky := rx*kappa, ry*kappa
+		b.printf("%.2f %.2f m %.2f %.2f %.2f %.2f %.2f %.2f c %.2f %.2f %.2f %.2f %.2f %.2f c\n",
+			cx+rx, cy, cx+rx, cy+ky, cx+kx, cy+ry, cx, cy+ry, cx-kx, cy+ry, cx-rx, cy+ky, cx-rx, cy)
+		b.printf("%.2f %.2f %.2f %.2f %.2f %.2f c %.2f %.2f %.2f %.2f %.2f %.2f c %s\n",
+			cx-rx, cy-ky, cx-kx, cy-ry, cx, cy-ry, cx+kx, cy-ry, cx+rx, cy-ky, cx+rx, cy, op())
+	case "Polygon":
+		annotPath(b, an.paths[0])
+		b.printf(" %s\n", op())
+	case "Ink":
+		b.printf("1 J 1 j\n")
+		for _, path := range an.paths {
+			annotPath(b, path)
+			b.printf(" S\n")
+		}
+	default:
+		p := an.paths[0]
+		annotPath(b, p)
+		b.printf(" S\n")
+		if len(an.options.BorderDashes) > 0 {
+			b.printf("[] 0 d\n")
+		}
+		n := len(p)
+		annotLineEnding(b, an.options.LineEndings[0], p[0], p[1], p[0]-p[2], p[1]-p[3], w, fill)
+		annotLineEnding(b, an.options.LineEndings[1], p[n-2], p[n-1], p[n-2]-p[n-4], p[n-1]-p[n-3], w, fill)
+	}
+}
+
+// annotPath writes the operators of the open path through the vertices of
+// path to b
+func annotPath(b *fmtBuffer, path []float64) {
+	for j := 0; j < len(path); j += 2 {
+		op := "l"
+		if j == 0 {
+			op = "m"
+		}
+		if j > 0 {
+			b.printf(" ")
+		}
+		b.printf("%.2f %.2f %s", path[j], path[j+1], op)
+	}
+}
+
+// annotLineEnding writes the operators that draw the line ending of the
+// specified style at (x, y) of a line of width w pointing in the direction
+// (dx, dy) to b. Closed line endings are filled if fill is true.
+func annotLineEnding(b *fmtBuffer, style string, x, y, dx, dy, w float64, fill bool) {
+	l := math.Hypot(dx, dy)
+	if style == "None" || l == 0 {
+		return
+	}
+	size := annotEndingSize(w)
+	// (ux, uy) points along the line and (nx, ny) across it
+	ux, uy := dx/l, dy/l
+	nx, ny := -uy, ux
+	pt := func(along, across float64) (float64, float64) {
+		return x + ux*along + nx*across, y + uy*along + ny*across
+	}
+	op := "s"
+	if fill {
+		op = "b"
+	}
+	var pts [][2]float64
+	switch style {
+	case "OpenArrow", "ClosedArrow":
+		ax, ay := pt(-size, size/2)
+		bx, by := pt(-size, -size/2)
+		if style == "OpenArrow" {
+			b.printf("%.2f %.2f m %.2f %.2f l %.2f %.2f l S\n", ax, ay, x, y, bx, by)
+			return
+		}
+		pts = [][2]float64{{ax, ay}, {x, y}, {bx, by}}
+	case "Square":
+		r := size / 3
+		for _, c := range [][2]float64{{r, r}, {r, -r}, {-r, -r}, {-r, r}} {
+			px, py := pt(c[0], c[1])
+			pts = append(pts, [2]float64{px, py})
+		}
+	case "Diamond":
+		r := size / 2
+		for _, c := range [][2]float64{{r, 0}, {0, -r}, {-r, 0}, {0, r}} {
+			px, py := pt(c[0], c[1])
+			pts = append(pts, [2]float64{px, py})
+		}
+	case "Circle":
+		r := size / 3
+		k := r * 0.5523
+		b.printf("%.2f %.2f m %.2f %.2f %.2f %.2f %.2f %.2f c %.2f %.2f %.2f %.2f %.2f %.2f c ",
+			x+r, y, x+r, y+k, x+k, y+r, x, y+r, x-k, y+r, x-r, y+k, x-r, y)
+		b.printf("%.2f %.2f %.2f %.2f %.2f %.2f c %.2f %.2f %.2f %.2f %.2f %.2f c %s\n",
+			x-r, y-k, x-k, y-r, x, y-r, x+k, y-r, x+r, y-k, x+r, y, op)
+		return
+	}
+	for j, p := range pts {
+		o := "l"
+		if j == 0 {
+			o = "m"
+		}
+		b.printf("%.2f %.2f %s ", p[0], p[1], o)
+	}
+	b.printf("%s\n", op)
+}
@@ -0,0 +1,137 @@
+package gofpdf
+
+// arabicForms holds the presentation forms of Arabic letters: isolated,
+// final, initial and medial. Letters that only join on their right side have
+// no initial or medial forms.
+var arabicForms = map[rune][4]rune{
+	0x0621: {0xfe80, 0, 0, 0},
+	0x0622: {0xfe81, 0xfe82, 0, 0},
+	0x0623: {0xfe83, 0xfe84, 0, 0},
+	0x0624: {0xfe85, 0xfe86, 0, 0},
+	0x0625: {0xfe87, 0xfe88, 0, 0},
+	0x0626: {0xfe89, 0xfe8a, 0xfe8b, 0xfe8c},
+	0x0627: {0xfe8d, 0xfe8e, 0, 0},
+	0x0628: {0xfe8f, 0xfe90, 0xfe91, 0xfe92},
+	0x0629: {0xfe93, 0xfe94, 0, 0},
+	0x062a: {0xfe95, 0xfe96, 0xfe97, 0xfe98},
+	0x062b: {0xfe99, 0xfe9a, 0xfe9b, 0xfe9c},
+	0x062c: {0xfe9d, 0xfe9e, 0xfe9f, 0xfea0},
+	0x062d: {0xfea1, 0xfea2, 0xfea3, 0xfea4},
+	0x062e: {0xfea5, 0xfea6, 0xfea7, 0xfea8},
+	0x062f: {0xfea9, 0xfeaa, 0, 0},
+	0x0630: {0xfeab, 0xfeac, 0, 0},
+	0x0631: {0xfead, 0xfeae, 0, 0},
+	0x0632: {0xfeaf, 0xfeb0, 0, 0},
+	0x0633: {0xfeb1, 0xfeb2, 0xfeb3, 0xfeb4},
+	0x0634: {0xfeb5, 0xfeb6, 0xfeb7, 0xfeb8},
+	0x0635: {0xfeb9, 0xfeba, 0xfebb, 0xfebc},
+	0x0636: {0xfebd, 0xfebe, 0xfebf, 0xfec0},
+	0x0637: {0xfec1, 0xfec2, 0xfec3, 0xfec4},
+	0x0638: {0xfec5, 0xfec6, 0xfec7, 0xfec8},
+	0x0639: {0xfec9, 0xfeca, 0xfecb, 0xfecc},
+	0x063a: {0xfecd, 0xfece, 0xfecf, 0xfed0},
+	0x0641: {0xfed1, 0xfed2, 0xfed3, 0xfed4},
+	0x0642: {0xfed5, 0xfed6, 0xfed7, 0xfed8},
+	0x0643: {0xfed9, 0xfeda, 0xfedb, 0xfedc},
+	0x0644: {0xfedd, 0xfede, 0xfedf, 0xfee0},
+	0x0645: {0xfee1, 0xfee2, 0xfee3, 0xfee4},
+	0x0646: {0xfee5, 0xfee6, 0xfee7, 0xfee8},
+	0x0647: {0xfee9, 0xfeea, 0xfeeb, 0xfeec},
+	0x0648: {0xfeed, 0xfeee, 0, 0},
+	0x0649: {0xfeef, 0xfef0, 0, 0},
+	0x064a: {0xfef1, 0xfef2, 0xfef3, 0xfef4},
+	0x067e: {0xfb56, 0xfb57, 0xfb58, 0xfb59},
+	0x0686: {0xfb7a, 0xfb7b, 0xfb7c, 0xfb7d},
+	0x0698: {0xfb8a, 0xfb8b, 0, 0},
+	0x06a9: {0xfb8e, 0xfb8f, 0xfb90, 0xfb91},
+	0x06af: {0xfb92, 0xfb93, 0xfb94, 0xfb95},
+	0x06cc: {0xfbfc, 0xfbfd, 0xfbfe, 0xfbff},
+}
+
+// arabicLamAlef holds the isolated and final forms of the ligatures of lam
+// with the variants of alef
+var arabicLamAlef = map[rune][2]rune{
+	0x0622: {0xfef5, 0xfef6},
+	0x0623: {0xfef7, 0xfef8},
+	0x0625: {0xfef9, 0xfefa},
+	0x0627: {0xfefb, 0xfefc},
+}
+
+const arabicTatweel = 0x0640
+
+// arabicTransparent returns true for marks that do not affect the joining
+// of the letters around them
+func arabicTransparent(r rune) bool {
+	return (r >= 0x064b && r <= 0x065f) || r == 0x0670 || (r >= 0x06d6 && r <= 0x06ed && r != 0x06dd && r != 0x06de && r != 0x06e5 && r != 0x06e6 && r != 0x06e9)
+}
+
+// arabicJoinsLeft returns true if r connects to the letter that follows it
+func arabicJoinsLeft(r rune) bool {
+	if r == arabicTatweel {
+		return true
+	}
+	forms, ok := arabicForms[r]
+	return ok && forms[2] != 0
+}
+
+// arabicJoinsRight returns true if r connects to the letter that precedes it
+func arabicJoinsRight(r rune) bool {
+	if r == arabicTatweel {
+		return true
+	}
+	forms, ok := arabicForms[r]
+	return ok && forms[1] != 0
+}
+
+// arabicShape replaces the Arabic letters in rs, which is in logical order,
+// with the presentation forms that reflect their joining with neighboring
+// letters. Lam followed by alef is replaced by a ligature.
+func arabicShape(rs []rune) []rune {
+	out := make([]rune, 0, len(rs))
+	// neighbor returns the index of the nearest letter in direction step
+	// from j, skipping transparent marks, or -1
+	neighbor := func(j, step int) int {
+		for j += step; j >= 0 && j < len(rs); j += step {
+			if !arabicTransparent(rs[j]) {
+				return j
+			}
+		}
+		return -1
+	}
+	for j := 0; j < len(rs); j++ {
+		r := rs[j]
+		forms, ok := arabicForms[r]
+		if !ok {
+			out = append(out, r)
+			continue
+		}
+		prev := neighbor(j, -1)
+		joinPrev := prev >= 0 && arabicJoinsLeft(rs[prev])
+		next := neighbor(j, 1)
+		if r == 0x0644 && next >= 0 {
+			if lig, ok := arabicLamAlef[rs[next]]; ok {
+				if joinPrev {
+					out = append(out, lig[1])
+				} else {
+					out = append(out, lig[0])
+				}
+				// Keep any marks between lam and alef
+				out = append(out, rs[j+1:next]...)
+				j = next
+				continue
+			}
+		}
+		joinNext := forms[2] != 0 && next >= 0 && arabicJoinsRight(rs[next])
+		switch {
+		case joinPrev && joinNext:
+			out = append(out, forms[3])
+		case joinPrev && forms[1] != 0:
+			out = append(out, forms[1])
+		case joinNext:
+			out = append(out, forms[2])
+		default:
+			out = append(out, forms[0])
+		}
+	}
+	return out
+}
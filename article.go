@@ -0,0 +1,104 @@
+package gofpdf
+
+import (
+	"fmt"
+)
+
+// articleType is an article thread, the beads of which are the areas of the
+// article in reading order
+type articleType struct {
+	name  string
+	beads []articleBeadType
+	obj   int // object number of the thread
+}
+
+type articleBeadType struct {
+	page       int
+	x, y, w, h float64 // in points, with y at the top of the area
+}
+
+// BeginArticle starts the article thread named nameStr, to which the areas
+// added with ArticleBead() belong. If the thread has been started before, the
+// areas are added to its end, so that the areas of articles that are
+// interleaved, such as the stories of a newsletter, can be added as the pages
+// are laid out. Viewers with article navigation and screen readers follow the
+// areas of a thread in the order they were added, for example down the
+// columns of a page and on to the next page.
+//
+// The BeginArticle example demonstrates this method.
+func (f *Fpdf) BeginArticle(nameStr string) {
+	if f.err != nil {
+		return
+	}
+	for j, article := range f.articles {
+		if article.name == nameStr {
+			f.article = j
+			return
+		}
+	}
+	f.articles = append(f.articles, articleType{name: nameStr})
+	f.article = len(f.articles) - 1
+}
+
+// ArticleBead adds the area of the current page specified by x, y, w and h,
+// in the unit of measure specified in New(), to the article thread started
+// with BeginArticle().
+//
+// The BeginArticle example demonstrates this method.
+func (f *Fpdf) ArticleBead(x, y, w, h float64) {
+	if f.err != nil {
+		return
+	}
+	if len(f.articles) == 0 {
+		f.err = fmt.Errorf("ArticleBead() called without BeginArticle()")
+		return
+	}
+	if f.page == 0 {
+		f.err = fmt.Errorf("a page must be added before an article bead")
+		return
+	}
+	article := &f.articles[f.article]
+	article.beads = append(article.beads, articleBeadType{page: f.page,
+		x: x * f.k, y: f.hPt - y*f.k, w: w * f.k, h: h * f.k})
+}
+
+// putArticles writes the threads and their beads
+func (f *Fpdf) putArticles() {
+	// Each thread is followed by its beads, which refer to each other
+	for j, article := range f.articles {
+		if len(article.beads) == 0 {
+			continue
+		}
+		f.newobj()
+		f.articles[j].obj = f.n
+		f.outf("<</Type /Thread /F %d 0 R /I <</Title %s>>>>", f.n+1, f.textstring(utf8toutf16(article.name)))
+		f.out("endobj")
+		first, count := f.n+1, len(article.beads)
+		for k, bead := range article.beads {
+			f.newobj()
+			var s fmtBuffer
+			s.printf("<</Type /Bead ")
+			if k == 0 {
+				s.printf("/T %d 0 R ", f.articles[j].obj)
+			}
+			s.printf("/N %d 0 R /V %d 0 R /P %d 0 R /R [%.2f %.2f %.2f %.2f]>>",
+				first+(k+1)%count, first+(k+count-1)%count, f.pageObj(bead.page),
+				bead.x, bead.y-bead.h, bead.x+bead.w, bead.y)
+			f.out(s.String())
+			f.out("endobj")
+		}
+	}
+}
+
+// articlePutCatalog writes the /Threads entry of the catalog
+func (f *Fpdf) articlePutCatalog() {
+	var s fmtBuffer
+	for _, article := range f.articles {
+		if article.obj > 0 {
+			s.printf("%d 0 R ", article.obj)
+		}
+	}
+	if s.Len() > 0 {
+		f.outf("/Threads [%s]", s.String())
+	}
+}
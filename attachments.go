@@ -1,19 +1,48 @@
 package gofpdf
 
 import (
+	"compress/zlib"
 	"crypto/md5"
+	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"hash"
+	"io"
+	"math"
+	"path"
+	"reflect"
+	"sort"
 	"strings"
 )
 
+// Checksum options for the Checksum field of Attachment
+const (
+	// AttachmentChecksumMD5 writes the MD5 checksum of the content as
+	// /CheckSum in the embedded file parameters. This is the default.
+	AttachmentChecksumMD5 = iota
+	// AttachmentChecksumSHA256 writes the SHA-256 checksum of the content as
+	// /CheckSumSHA256 in addition to the MD5 /CheckSum, which is kept for
+	// readers that only know the standard entry.
+	AttachmentChecksumSHA256
+	// AttachmentChecksumNone omits checksums altogether.
+	AttachmentChecksumNone
+)
+
 // Attachment defines a content to be included in the pdf, in one
 // of the following ways :
-// 	- associated with the document as a whole : see SetAttachments()
-//	- accessible via a link localized on a page : see AddAttachmentAnnotation()
+//   - associated with the document as a whole : see SetAttachments()
+//   - accessible via a link localized on a page : see AddAttachmentAnnotation()
 type Attachment struct {
 	Content []byte
 
+	// ContentReader, if not nil, is used in place of Content. The data is
+	// read and compressed directly into the document when it is output,
+	// so large files do not need to be held in memory in their entirety. The
+	// reader can only be consumed once: to use the attachment more than once,
+	// pass the same *Attachment each time. Embedding a copy of the attachment
+	// whose reader has already been read results in an error.
+	ContentReader io.Reader
+
 	// Filename is the displayed name of the attachment
 	Filename string
 
@@ -21,31 +50,161 @@ type Attachment struct {
 	// and might be modified by the pdf reader.
 	Description string
 
+	// MimeType, for example "text/xml", is written as the subtype of the
+	// embedded file. PDF/A-3 documents require it; if it is empty in that case
+	// "application/octet-stream" is used.
+	MimeType string
+
+	// Relationship describes how the attachment relates to the document:
+	// "Source", "Data", "Alternative", "Supplement" or "Unspecified". It is
+	// written as /AFRelationship when set and in PDF/A-3 documents, where it
+	// defaults to "Unspecified". Factur-X invoices, for example, use "Data" or
+	// "Alternative" for the embedded XML.
+	Relationship string
+
+	// Uncompressed, if true, stores the content without compression. This
+	// saves time when the content is already compressed, as is the case with
+	// zip archives, JPEG images and most video formats, and deflating it
+	// again would gain little or nothing.
+	Uncompressed bool
+
+	// Checksum selects the checksums that are written with the content:
+	// AttachmentChecksumMD5 (the default), AttachmentChecksumSHA256 or
+	// AttachmentChecksumNone.
+	Checksum int
+
 	objectNumber int // filled when content is included
 }
 
-// return the hex encoded checksum of `data`
-func checksum(data []byte) string {
-	tmp := md5.Sum(data)
-	sl := make([]byte, len(tmp))
-	for i, v := range tmp {
-		sl[i] = v
+// attachmentHash computes the checksums selected for an attachment as its
+// content is written to it
+type attachmentHash struct {
+	md5, sha256 hash.Hash
+}
+
+func newAttachmentHash(mode int) (h *attachmentHash) {
+	h = new(attachmentHash)
+	switch mode {
+	case AttachmentChecksumMD5:
+		h.md5 = md5.New()
+	case AttachmentChecksumSHA256:
+		h.md5 = md5.New()
+		h.sha256 = sha256.New()
 	}
-	return hex.EncodeToString(sl)
+	return
 }
 
-// Writes a compressed file like object as ``/EmbeddedFile``. Compressing is
-// done with deflate. Includes length, compressed length and MD5 checksum.
-func (f *Fpdf) writeCompressedFileObject(content []byte) {
+func (h *attachmentHash) Write(p []byte) (int, error) {
+	if h.md5 != nil {
+		h.md5.Write(p)
+	}
+	if h.sha256 != nil {
+		h.sha256.Write(p)
+	}
+	return len(p), nil
+}
+
+// return the checksum entries of the embedded file parameters
+func (h *attachmentHash) params() (s string) {
+	if h.md5 != nil {
+		s += "/CheckSum <" + hex.EncodeToString(h.md5.Sum(nil)) + "> "
+	}
+	if h.sha256 != nil {
+		s += "/CheckSumSHA256 <" + hex.EncodeToString(h.sha256.Sum(nil)) + "> "
+	}
+	return
+}
+
+// Writes a file like object as “/EmbeddedFile“. Unless `compress` is false,
+// the content is compressed with deflate. Includes length, compressed length
+// and the checksums selected by `sumMode`. mimeStr, if not empty, is written
+// as the subtype of the file.
+func (f *Fpdf) writeFileObject(content []byte, mimeStr string, compress bool, sumMode int) {
 	lenUncompressed := len(content)
-	sum := checksum(content)
-	compressed := sliceCompress(content)
-	lenCompressed := len(compressed)
+	sum := newAttachmentHash(sumMode)
+	sum.Write(content)
+	data := content
+	if compress {
+		data = f.compressFlate(content)
+	}
+	f.newobj()
+	f.outf("<< /Type /EmbeddedFile %s/Length %d %s/Params << %s%s/Size %d >> >>\n",
+		embeddedFileSubtype(mimeStr), f.protect.streamLength(len(data)), embeddedFileFilter(compress),
+		f.embeddedFileModDate(), sum.params(), lenUncompressed)
+	f.putstream(data)
+	f.out("endobj")
+}
+
+// Writes a file like object as “/EmbeddedFile“ by copying the content of
+// `r`, compressed with deflate unless `compress` is false, directly into the
+// document. Since the stream length, size and checksum are only known once
+// the content has been read, they are written as indirect objects that
+// follow the stream.
+func (f *Fpdf) writeFileReader(r io.Reader, mimeStr string, compress bool, sumMode int) (streamID int) {
 	f.newobj()
-	f.outf("<< /Type /EmbeddedFile /Length %d /Filter /FlateDecode /Params << /CheckSum <%s> /Size %d >> >>\n",
-		lenCompressed, sum, lenUncompressed)
-	f.putstream(compressed)
+	streamID = f.n
+	f.outf("<< /Type /EmbeddedFile %s/Length %d 0 R %s/Params %d 0 R >>",
+		embeddedFileSubtype(mimeStr), streamID+1, embeddedFileFilter(compress), streamID+2)
+	f.out("stream")
+	start := f.buffer.Len()
+	sum := newAttachmentHash(sumMode)
+	ew := f.protect.encryptWriter(uint32(streamID), &f.buffer)
+	var w io.WriteCloser = nopWriteCloser{ew}
+	var err error
+	if compress {
+		w, err = zlib.NewWriterLevel(ew, f.compressLevel)
+		if err != nil {
+			f.err = err
+			w = nopWriteCloser{ew}
+		}
+	}
+	size, err := io.Copy(w, io.TeeReader(r, sum))
+	// Closing flushes the final compressed and encrypted blocks; a failure
+	// here would leave the stream truncated
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := ew.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil && f.err == nil {
+		f.err = err
+	}
+	lenStream := f.buffer.Len() - start
+	f.out("")
+	f.out("endstream")
 	f.out("endobj")
+	f.newobj()
+	f.outf("%d", lenStream)
+	f.out("endobj")
+	f.newobj()
+	f.outf("<< %s%s/Size %d >>", f.embeddedFileModDate(), sum.params(), size)
+	f.out("endobj")
+	return
+}
+
+// return the /Subtype entry of an embedded file, if any
+func embeddedFileSubtype(mimeStr string) string {
+	if mimeStr == "" {
+		return ""
+	}
+	return "/Subtype " + pdfName(mimeStr) + " "
+}
+
+// return the /Filter entry of an embedded file, if any
+func embeddedFileFilter(compress bool) string {
+	if compress {
+		return "/Filter /FlateDecode "
+	}
+	return ""
+}
+
+// return the /ModDate entry of embedded file parameters, if any
+func (f *Fpdf) embeddedFileModDate() string {
+	if f.pdfa.part == 0 {
+		return ""
+	}
+	return "/ModDate " + f.textstring("D:"+timeOrNow(f.modDate).Format("20060102150405")) + " "
 }
 
 // Embed includes the content of `a`, and update its internal reference.
@@ -55,26 +214,96 @@ func (f *Fpdf) embed(a *Attachment) {
 	}
 	oldState := f.state
 	f.state = 1 // we write file content in the main buffer
-	f.writeCompressedFileObject(a.Content)
-	streamID := f.n
+	mimeStr, relStr := a.MimeType, a.Relationship
+	if f.pdfa.part == 3 {
+		if mimeStr == "" {
+			mimeStr = "application/octet-stream"
+		}
+		if relStr == "" {
+			relStr = "Unspecified"
+		}
+	}
+	var streamID int
+	if a.ContentReader != nil {
+		// Copies of an Attachment share its reader, which can only be read
+		// once
+		if reflect.TypeOf(a.ContentReader).Comparable() {
+			if f.attachReaders == nil {
+				f.attachReaders = make(map[io.Reader]bool)
+			}
+			if f.attachReaders[a.ContentReader] {
+				f.err = fmt.Errorf("content reader of attachment \"%s\" has already been consumed; "+
+					"pass the same *Attachment to use an attachment more than once", a.Filename)
+				f.state = oldState
+				return
+			}
+			f.attachReaders[a.ContentReader] = true
+		}
+		streamID = f.writeFileReader(a.ContentReader, mimeStr, !a.Uncompressed, a.Checksum)
+	} else {
+		f.writeFileObject(a.Content, mimeStr, !a.Uncompressed, a.Checksum)
+		streamID = f.n
+	}
 	f.newobj()
-	f.outf("<< /Type /Filespec /F () /UF %s /EF << /F %d 0 R >> /Desc %s\n>>",
+	if relStr != "" {
+		relStr = " /AFRelationship " + pdfName(relStr)
+	}
+	f.outf("<< /Type /Filespec /F %s /UF %s /EF << /F %d 0 R >> /Desc %s%s\n>>",
+		f.textstring(asciiFileName(a.Filename)),
 		f.textstring(utf8toutf16(a.Filename)),
 		streamID,
-		f.textstring(utf8toutf16(a.Description)))
+		f.textstring(utf8toutf16(a.Description)),
+		relStr)
 	f.out("endobj")
 	a.objectNumber = f.n
 	f.state = oldState
 }
 
+// asciiFileName returns the file name nameStr with characters outside of
+// printable ASCII replaced by underscores. It is used for the /F entry of
+// file specifications, which readers that ignore /UF rely on.
+func asciiFileName(nameStr string) string {
+	var b strings.Builder
+	for _, r := range nameStr {
+		if r < 0x20 || r > 0x7e {
+			r = '_'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
 // SetAttachments writes attachments as embedded files (document attachment).
 // These attachments are global, see AddAttachmentAnnotation() for a link
 // anchored in a page. Note that only the last call of SetAttachments is
 // useful, previous calls are discarded. Be aware that not all PDF readers
-// support document attachments. See the SetAttachment example for a
+// support document attachments. Use AppendAttachment() to add attachments
+// without discarding existing ones. See the SetAttachment example for a
 // demonstration of this method.
 func (f *Fpdf) SetAttachments(as []Attachment) {
-	f.attachments = as
+	// The slice is copied so that AppendAttachment() and the object numbers
+	// recorded when the attachments are written do not modify the caller's
+	// memory
+	f.attachments = append([]Attachment(nil), as...)
+}
+
+// AppendAttachment adds `a` to the attachments of the document, keeping
+// those added by earlier calls to AppendAttachment() or SetAttachments().
+// This lets independent parts of an application each contribute their own
+// attachments. See the AppendAttachment example for a demonstration of this
+// method.
+func (f *Fpdf) AppendAttachment(a Attachment) {
+	f.attachments = append(f.attachments, a)
+}
+
+// Attachments returns a copy of the document attachments defined with
+// SetAttachments() and AppendAttachment(), in the order in which they will be
+// embedded. Attachments anchored in pages with AddAttachmentAnnotation() are
+// not included.
+func (f *Fpdf) Attachments() []Attachment {
+	as := make([]Attachment, len(f.attachments))
+	copy(as, f.attachments)
+	return as
 }
 
 // embed current attachments. store object numbers
@@ -86,11 +315,35 @@ func (f *Fpdf) putAttachments() {
 	}
 }
 
-// return /EmbeddedFiles tree name catalog entry.
-func (f Fpdf) getEmbeddedFiles() string {
-	names := make([]string, len(f.attachments))
-	for i, as := range f.attachments {
-		names[i] = fmt.Sprintf("(Attachement%d) %d 0 R ", i+1, as.objectNumber)
+// return /EmbeddedFiles tree name catalog entry. The keys are the file names
+// of the attachments, encoded in UTF-16 and sorted as a name tree requires.
+// Names that occur more than once are made unique with a numeric suffix.
+func (f *Fpdf) getEmbeddedFiles() string {
+	type entryType struct {
+		key string // UTF-16 encoded name
+		obj int
+	}
+	entries := make([]entryType, 0, len(f.attachments))
+	used := make(map[string]bool)
+	for _, as := range f.attachments {
+		name := as.Filename
+		if name == "" {
+			name = "attachment"
+		}
+		key := name
+		ext := path.Ext(name)
+		for j := 2; used[key]; j++ {
+			key = fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(name, ext), j, ext)
+		}
+		used[key] = true
+		entries = append(entries, entryType{utf8toutf16(key), as.objectNumber})
+	}
+	sort.SliceStable(entries, func(a, b int) bool {
+		return entries[a].key < entries[b].key
+	})
+	names := make([]string, len(entries))
+	for i, e := range entries {
+		names[i] = fmt.Sprintf("%s %d 0 R ", f.textstring(e.key), e.obj)
 	}
 	nameTree := fmt.Sprintf("<< /Names [\n %s \n] >>", strings.Join(names, "\n"))
 	return nameTree
@@ -102,6 +355,29 @@ type annotationAttach struct {
 	*Attachment
 
 	x, y, w, h float64 // fpdf coordinates (y diff and scaling done)
+	options    AttachmentAnnotationOptions
+	apObj      int // object number of the appearance stream
+}
+
+// AttachmentAnnotationOptions controls the look of an attachment annotation.
+//
+// Icon is the name of the icon that represents the attachment: "Graph",
+// "Paperclip", "PushPin" or "Tag". If it is empty, the annotation is
+// invisible, as it is with AddAttachmentAnnotation().
+//
+// Color, if not nil, specifies the color of the icon and its opacity, from 0
+// (transparent) to 1 (opaque). Since a transparent icon would be of no use, an
+// Alpha value of 0 is taken to mean opaque, so that only the RGB components
+// need to be set.
+//
+// Appearance, if true, causes the icon to be drawn by gofpdf rather than by
+// the PDF reader, so that it looks the same in every reader. If Icon is empty,
+// "PushPin" is used. PDF/A requires every annotation to have an appearance,
+// so in that mode Appearance is implied when Icon is set.
+type AttachmentAnnotationOptions struct {
+	Icon       string
+	Color      *RGBAType
+	Appearance bool
 }
 
 // AddAttachmentAnnotation puts a link on the current page, on the rectangle
@@ -115,43 +391,124 @@ type annotationAttach struct {
 // annotated attachments. See the AddAttachmentAnnotation example for a
 // demonstration of this method.
 func (f *Fpdf) AddAttachmentAnnotation(a *Attachment, x, y, w, h float64) {
-	if a == nil {
+	f.AddAttachmentAnnotationOptions(a, x, y, w, h, AttachmentAnnotationOptions{})
+}
+
+// AddAttachmentAnnotationOptions is the same as AddAttachmentAnnotation() but
+// the annotation is displayed with the icon, color and opacity specified by
+// `options`. See the AddAttachmentAnnotation example for a demonstration of
+// this method.
+func (f *Fpdf) AddAttachmentAnnotationOptions(a *Attachment, x, y, w, h float64, options AttachmentAnnotationOptions) {
+	if f.err != nil || a == nil {
 		return
 	}
+	switch options.Icon {
+	case "", "Graph", "Paperclip", "PushPin", "Tag":
+	default:
+		f.err = fmt.Errorf("unrecognized attachment icon \"%s\"", options.Icon)
+		return
+	}
+	if options.Appearance && options.Icon == "" {
+		options.Icon = "PushPin"
+	}
+	if options.Color != nil {
+		clr := *options.Color
+		if clr.Alpha == 0 {
+			clr.Alpha = 1
+		}
+		options.Color = &clr
+	}
 	f.pageAttachments[f.page] = append(f.pageAttachments[f.page], annotationAttach{
 		Attachment: a,
 		x:          x * f.k, y: f.hPt - y*f.k, w: w * f.k, h: h * f.k,
+		options: options,
 	})
 }
 
 // embed current annotations attachments. store object numbers
 // for later use by putAttachmentAnnotationLinks(), which is
-// called for each page.
+// called for each page. Appearance streams are written here as well.
 func (f *Fpdf) putAnnotationsAttachments() {
-	// avoid duplication
-	m := map[*Attachment]bool{}
-	for _, l := range f.pageAttachments {
-		for _, an := range l {
-			if m[an.Attachment] { // already embedded
-				continue
+	emptyObj := 0
+	for p, l := range f.pageAttachments {
+		for j, an := range l {
+			f.embed(an.Attachment) // content is only included once
+			switch {
+			case an.options.Appearance || (f.pdfa.part > 0 && an.options.Icon != ""):
+				f.pageAttachments[p][j].apObj = f.putAttachmentAppearance(an)
+			case an.options.Icon == "":
+				// invisible annotation: share a single empty appearance
+				if emptyObj == 0 {
+					f.newobj()
+					f.out("<< /Type /XObject /Subtype /Form /BBox [0 0 1 1] /Length 0 >>")
+					f.out("stream")
+					f.out("endstream")
+					f.out("endobj")
+					emptyObj = f.n
+				}
+				f.pageAttachments[p][j].apObj = emptyObj
 			}
-			f.embed(an.Attachment)
 		}
 	}
 }
 
+// attachmentIconPaths holds the drawing operators of the icons used for
+// attachment annotation appearances. They are designed for a unit square.
+var attachmentIconPaths = map[string]string{
+	"Graph": "0.08 w 0.12 0.88 m 0.12 0.12 l 0.88 0.12 l S " +
+		"0.22 0.2 0.14 0.3 re 0.44 0.2 0.14 0.5 re 0.66 0.2 0.14 0.65 re f",
+	"Paperclip": "0.07 w 0.42 0.3 m 0.42 0.72 l 0.42 0.81 0.58 0.81 0.58 0.72 c 0.58 0.2 l " +
+		"0.58 0.04 0.3 0.04 0.3 0.2 c 0.3 0.78 l 0.3 0.96 0.7 0.96 0.7 0.78 c 0.7 0.38 l S",
+	"PushPin": "0.6 0.45 m 0.76 0.45 0.88 0.57 0.88 0.72 c 0.88 0.87 0.76 0.99 0.6 0.99 c " +
+		"0.45 0.99 0.33 0.87 0.33 0.72 c 0.33 0.57 0.45 0.45 0.6 0.45 c f " +
+		"0.08 w 1 J 0.48 0.58 m 0.12 0.1 l S",
+	"Tag": "0.08 0.3 m 0.6 0.3 l 0.92 0.5 l 0.6 0.7 l 0.08 0.7 l h " +
+		"0.7 0.45 m 0.73 0.45 0.75 0.47 0.75 0.5 c 0.75 0.53 0.73 0.55 0.7 0.55 c " +
+		"0.67 0.55 0.65 0.53 0.65 0.5 c 0.65 0.47 0.67 0.45 0.7 0.45 c h f*",
+}
+
+// putAttachmentAppearance writes the appearance stream of an attachment
+// annotation and returns its object number. The icon is centered in the
+// annotation rectangle.
+func (f *Fpdf) putAttachmentAppearance(an annotationAttach) int {
+	var b fmtBuffer
+	if an.options.Color != nil {
+		clr := an.options.Color
+		rgb := sprintf("%.3f %.3f %.3f", float64(clr.R)/255, float64(clr.G)/255, float64(clr.B)/255)
+		b.printf("%s rg %s RG\n", rgb, rgb)
+	}
+	side := math.Min(an.w, an.h)
+	b.printf("%.2f 0 0 %.2f %.2f %.2f cm\n", side, side, (an.w-side)/2, (an.h-side)/2)
+	b.printf("%s\n", attachmentIconPaths[an.options.Icon])
+	f.newobj()
+	f.outf("<< /Type /XObject /Subtype /Form /BBox [0 0 %.2f %.2f] /Length %d >>",
+		an.w, an.h, f.protect.streamLength(b.Len()))
+	f.putstream(b.Bytes())
+	f.out("endobj")
+	return f.n
+}
+
 func (f *Fpdf) putAttachmentAnnotationLinks(out *fmtBuffer, page int) {
 	for _, an := range f.pageAttachments[page] {
 		x1, y1, x2, y2 := an.x, an.y, an.x+an.w, an.y-an.h
-		as := fmt.Sprintf("<< /Type /XObject /Subtype /Form /BBox [%.2f %.2f %.2f %.2f] /Length 0 >>",
-			x1, y1, x2, y2)
-		as += "\nstream\nendstream"
-
 		out.printf("<< /Type /Annot /Subtype /FileAttachment /Rect [%.2f %.2f %.2f %.2f] /Border [0 0 0]\n",
 			x1, y1, x2, y2)
+		if f.pdfa.part > 0 {
+			// PDF/A requires annotations to be printable
+			out.printf("/F 4 ")
+		}
+		if an.options.Icon != "" {
+			out.printf("/Name /%s ", an.options.Icon)
+		}
+		if clr := an.options.Color; clr != nil {
+			out.printf("/C [%.3f %.3f %.3f] /CA %.3f ",
+				float64(clr.R)/255, float64(clr.G)/255, float64(clr.B)/255, clr.Alpha)
+		}
 		out.printf("/Contents %s ", f.textstring(utf8toutf16(an.Description)))
 		out.printf("/T %s ", f.textstring(utf8toutf16(an.Filename)))
-		out.printf("/AP << /N %s>>", as)
+		if an.apObj > 0 {
+			out.printf("/AP << /N %d 0 R >> ", an.apObj)
+		}
 		out.printf("/FS %d 0 R >>\n", an.objectNumber)
 	}
 }
@@ -0,0 +1,53 @@
+package gofpdf
+
+import (
+	"fmt"
+)
+
+// autoPageHeightPt is the height in points of pages added with AddPageAuto()
+// while their content is written, the largest page size that viewers support
+const autoPageHeightPt = 14400
+
+// AddPageAuto adds a page of width wd, in the unit of measure of the
+// document, whose height grows with its content, as for the receipts of roll
+// printers. The page ends at the current position when the next page is
+// added or the document is closed, after the footer, and the bottom margin is
+// added below it; content drawn below the current position, for example at
+// an absolute position, is cut off unless the position is moved past it. The
+// page is as long as needed up to 5080 mm (14400 points), the largest page
+// size that viewers support; an automatic page break near that length adds a
+// page of that height.
+//
+// Positions relative to the bottom of the page, such as those set with
+// SetY() and negative values, refer to the largest height, so headers and
+// footers of the page should be printed from the top or the current position.
+// Watermarks above the content are centered on the page as it ends, while
+// those behind the content are centered on the page of the largest height.
+//
+// The AddPageAuto example demonstrates this method.
+func (f *Fpdf) AddPageAuto(wd float64) {
+	if f.err != nil {
+		return
+	}
+	if wd <= 0 || wd*f.k > autoPageHeightPt {
+		f.err = fmt.Errorf("page width is out of range: %.2f", wd)
+		return
+	}
+	f.AddPageFormat("P", SizeType{Wd: wd, Ht: autoPageHeightPt / f.k})
+	if f.err == nil {
+		f.autoPageHeights[f.page] = 0
+	}
+}
+
+// autoPageEnd sets the height of the current page, if it has been added with
+// AddPageAuto(), to the height of its content
+func (f *Fpdf) autoPageEnd() {
+	if _, ok := f.autoPageHeights[f.page]; !ok {
+		return
+	}
+	ht := (f.y + f.bMargin) * f.k
+	if ht > f.hPt {
+		ht = f.hPt
+	}
+	f.autoPageHeights[f.page] = ht
+}
@@ -0,0 +1,132 @@
+package gofpdf
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PageRange selects pages of the document by their one-based page numbers
+// and ranges, separated by commas, such as "1-3,5,8-", where a range without
+// an end extends to the last page. An empty range selects all pages.
+type PageRange string
+
+// Page ranges for the usual selections of pages
+const (
+	// AllPages selects all pages of the document
+	AllPages PageRange = ""
+	// FirstPage selects only the first page of the document
+	FirstPage PageRange = "1"
+	// AllButFirstPage selects all pages of the document except the first
+	AllButFirstPage PageRange = "2-"
+)
+
+// pageRanges holds the first and last pages of the ranges of a PageRange; a
+// last page of 0 is open-ended. An empty list contains all pages.
+type pageRanges [][2]int
+
+// parse returns the ranges of r, or an error if r is not valid
+func (r PageRange) parse() (ranges pageRanges, err error) {
+	if strings.TrimSpace(string(r)) == "" {
+		return
+	}
+	for _, item := range strings.Split(string(r), ",") {
+		var rg [2]int
+		item = strings.TrimSpace(item)
+		parts := strings.SplitN(item, "-", 2)
+		if rg[0], err = strconv.Atoi(strings.TrimSpace(parts[0])); err == nil {
+			rg[1] = rg[0]
+			if len(parts) == 2 {
+				rg[1] = 0
+				if last := strings.TrimSpace(parts[1]); last != "" {
+					rg[1], err = strconv.Atoi(last)
+				}
+			}
+		}
+		if err != nil || rg[0] < 1 || (rg[1] != 0 && rg[1] < rg[0]) {
+			return nil, fmt.Errorf("invalid page range \"%s\"", item)
+		}
+		ranges = append(ranges, rg)
+	}
+	return
+}
+
+// contains reports whether page lies in one of the ranges
+func (ranges pageRanges) contains(page int) bool {
+	if len(ranges) == 0 {
+		return true
+	}
+	for _, rg := range ranges {
+		if page >= rg[0] && (rg[1] == 0 || page <= rg[1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// backgroundType is a template drawn under the content of a range of pages
+type backgroundType struct {
+	tpl   Template
+	pages pageRanges
+}
+
+// SetPageBackground sets the template tpl, such as a letterhead or the
+// background of a form, to be drawn under the content of the pages in pages
+// when the document is output, for example FirstPage for a letterhead and
+// AllButFirstPage for the plain background of the following pages. The
+// template is placed at its own corner and size, as with UseTemplate(), and
+// is an artifact. Backgrounds set for overlapping ranges are drawn in the
+// order they are set; passing a nil template removes the backgrounds.
+//
+// Since backgrounds are drawn when the document is output, they can be set at
+// any time, and page numbers are the final numbers of the pages, including
+// pages added by a table of contents or with page builders. Pages that are
+// flushed to an output writer receive their backgrounds when they are flushed.
+// An error occurs if pages is not valid.
+//
+// The SetPageBackground example demonstrates this method.
+func (f *Fpdf) SetPageBackground(tpl Template, pages PageRange) {
+	if f.err != nil {
+		return
+	}
+	if tpl == nil {
+		f.backgrounds = nil
+		return
+	}
+	bg := backgroundType{tpl: tpl}
+	if bg.pages, f.err = pages.parse(); f.err != nil {
+		return
+	}
+	f.backgrounds = append(f.backgrounds, bg)
+}
+
+// backgroundsPrepare draws the backgrounds of the pages. It is called when the
+// document is closed; the pages of documents written to an output writer
+// receive their backgrounds in flushContents().
+func (f *Fpdf) backgroundsPrepare() {
+	if f.outStream != nil {
+		return
+	}
+	for n := 1; n < len(f.pages) && f.err == nil; n++ {
+		f.putPageBackground(n)
+	}
+}
+
+// putPageBackground inserts the backgrounds of page n at the start of its
+// content
+func (f *Fpdf) putPageBackground(n int) {
+	var s fmtBuffer
+	_, h := f.pageSizeUnits(n)
+	for _, bg := range f.backgrounds {
+		if !bg.pages.contains(n) || !f.templateUse(bg.tpl) {
+			continue
+		}
+		corner, size := bg.tpl.Size()
+		s.printf("/Artifact BMC\nq 1 0 0 1 %.4f %.4f cm /TPL%s Do Q\nEMC\n",
+			corner.X*f.k, (h-corner.Y-size.Ht)*f.k, bg.tpl.ID())
+	}
+	if s.Len() > 0 {
+		s.Write(f.pages[n].Bytes())
+		f.pages[n] = &s.Buffer
+	}
+}
@@ -0,0 +1,341 @@
+package gofpdf
+
+import (
+	"fmt"
+	"unicode"
+)
+
+// Text direction constants used with SetTextDirection
+const (
+	// TextDirectionAuto determines the base direction of each line from its
+	// first strong character, or uses right-to-left if RTL() is in effect.
+	// This is the default.
+	TextDirectionAuto = iota
+	// TextDirectionLTR sets the base direction to left-to-right.
+	TextDirectionLTR
+	// TextDirectionRTL sets the base direction to right-to-left.
+	TextDirectionRTL
+)
+
+// SetTextDirection sets the base direction of text rendered with UTF-8 fonts
+// to TextDirectionAuto, TextDirectionLTR or TextDirectionRTL.
+//
+// Text in UTF-8 fonts is always converted from logical order, in which it is
+// typed and stored, to the visual order in which it is displayed, using the
+// Unicode bidirectional algorithm. Arabic letters are replaced by their
+// contextual presentation forms, so that they join as they should; the font
+// must contain these forms. Lines are broken before this conversion takes
+// place, so Cell(), MultiCell() and Write() all handle mixed left-to-right and
+// right-to-left text.
+//
+// The base direction determines the order of runs of text with different
+// directions and the direction of neutral characters, such as punctuation,
+// between them. With TextDirectionAuto, a line that starts with a Hebrew or
+// Arabic word is laid out from right to left. The other values override this
+// for lines such as a right-to-left paragraph that begins with a Latin
+// product name.
+//
+// The SetTextDirection example demonstrates this method.
+func (f *Fpdf) SetTextDirection(dir int) {
+	if f.err != nil {
+		return
+	}
+	switch dir {
+	case TextDirectionAuto, TextDirectionLTR, TextDirectionRTL:
+		f.textDirection = dir
+	default:
+		f.err = fmt.Errorf("unsupported text direction %d", dir)
+	}
+}
+
+// bidi character classes, a reduced set of those of the Unicode
+// bidirectional algorithm
+const (
+	bidiL   = iota // strong left-to-right
+	bidiR          // strong right-to-left (Hebrew)
+	bidiAL         // strong right-to-left (Arabic)
+	bidiEN         // European number
+	bidiAN         // Arabic number
+	bidiES         // European separator (plus and minus)
+	bidiET         // European terminator (currency, percent)
+	bidiCS         // common separator
+	bidiNSM        // non-spacing mark
+	bidiWS         // white space
+	bidiON         // other neutral
+)
+
+// bidiClass returns the bidi class of r
+func bidiClass(r rune) int {
+	switch {
+	case r >= '0' && r <= '9':
+		return bidiEN
+	case r >= 0x0660 && r <= 0x0669, r >= 0x06f0 && r <= 0x06f9:
+		return bidiAN
+	case r == '+' || r == '-':
+		return bidiES
+	case r == '#' || r == '$' || r == '%' || r == 0xb0 || r == 0x20ac || r == 0xa3 || r == 0xa5 || r == 0x066a:
+		return bidiET
+	case r == ',' || r == '.' || r == ':' || r == '/' || r == 0xa0 || r == 0x060c:
+		return bidiCS
+	case r >= 0x0591 && r <= 0x05c7 && r != 0x05be && r != 0x05c0 && r != 0x05c3 && r != 0x05c6,
+		arabicTransparent(r):
+		return bidiNSM
+	case r >= 0x0590 && r <= 0x05ff, r >= 0xfb1d && r <= 0xfb4f:
+		return bidiR
+	case r >= 0x0600 && r <= 0x06ff, r >= 0x0750 && r <= 0x077f,
+		r >= 0xfb50 && r <= 0xfdff, r >= 0xfe70 && r <= 0xfeff:
+		return bidiAL
+	case unicode.IsSpace(r):
+		return bidiWS
+	case unicode.IsLetter(r) || unicode.IsMark(r) || unicode.IsDigit(r):
+		return bidiL
+	}
+	return bidiON
+}
+
+// bidiMirror maps characters to their mirrored counterparts, which are
+// displayed in right-to-left runs
+var bidiMirror = map[rune]rune{
+	'(': ')', ')': '(', '[': ']', ']': '[', '{': '}', '}': '{', '<': '>', '>': '<',
+	0xab: 0xbb, 0xbb: 0xab, 0x2039: 0x203a, 0x203a: 0x2039,
+}
+
+// hasRTL returns true if rs contains right-to-left characters
+func hasRTL(rs []rune) bool {
+	for _, r := range rs {
+		if c := bidiClass(r); c == bidiR || c == bidiAL || c == bidiAN {
+			return true
+		}
+	}
+	return false
+}
+
+// bidiBaseLevel returns the paragraph embedding level of rs, 0 for
+// left-to-right and 1 for right-to-left
+func (f *Fpdf) bidiBaseLevel(rs []rune) int {
+	switch f.textDirection {
+	case TextDirectionLTR:
+		return 0
+	case TextDirectionRTL:
+		return 1
+	}
+	if f.isRTL {
+		return 1
+	}
+	for _, r := range rs {
+		switch bidiClass(r) {
+		case bidiL:
+			return 0
+		case bidiR, bidiAL:
+			return 1
+		}
+	}
+	return 0
+}
+
+// bidiLevels resolves the embedding level of each character of a line
+// according to the weak, neutral and implicit rules of the Unicode
+// bidirectional algorithm. Explicit embeddings and isolates are not
+// supported.
+func bidiLevels(rs []rune, base int) []int {
+	n := len(rs)
+	cls := make([]int, n)
+	for j, r := range rs {
+		cls[j] = bidiClass(r)
+	}
+	sos := bidiL
+	if base == 1 {
+		sos = bidiR
+	}
+	// W1: non-spacing marks take the class of the preceding character
+	for j := range cls {
+		if cls[j] == bidiNSM {
+			if j == 0 {
+				cls[j] = sos
+			} else {
+				cls[j] = cls[j-1]
+			}
+		}
+	}
+	// W2, W3: European numbers after Arabic letters are Arabic numbers, and
+	// Arabic letters are right-to-left
+	last := sos
+	for j, c := range cls {
+		switch c {
+		case bidiL, bidiR, bidiAL:
+			last = c
+		case bidiEN:
+			if last == bidiAL {
+				cls[j] = bidiAN
+			}
+		}
+	}
+	for j, c := range cls {
+		if c == bidiAL {
+			cls[j] = bidiR
+		}
+	}
+	// W4: a single separator between two numbers of the same type
+	for j := 1; j+1 < n; j++ {
+		if cls[j-1] == cls[j+1] {
+			switch {
+			case cls[j] == bidiES && cls[j-1] == bidiEN,
+				cls[j] == bidiCS && (cls[j-1] == bidiEN || cls[j-1] == bidiAN):
+				cls[j] = cls[j-1]
+			}
+		}
+	}
+	// W5: terminators adjacent to European numbers
+	for j := 0; j < n; j++ {
+		if cls[j] != bidiET {
+			continue
+		}
+		k := j
+		for k < n && cls[k] == bidiET {
+			k++
+		}
+		if (j > 0 && cls[j-1] == bidiEN) || (k < n && cls[k] == bidiEN) {
+			for ; j < k; j++ {
+				cls[j] = bidiEN
+			}
+		}
+		j = k - 1
+	}
+	// W6: remaining separators and terminators are neutral
+	for j, c := range cls {
+		if c == bidiES || c == bidiET || c == bidiCS {
+			cls[j] = bidiON
+		}
+	}
+	// W7: European numbers after left-to-right text are left-to-right
+	last = sos
+	for j, c := range cls {
+		switch c {
+		case bidiL, bidiR:
+			last = c
+		case bidiEN:
+			if last == bidiL {
+				cls[j] = bidiL
+			}
+		}
+	}
+	// N1, N2: neutrals between characters of the same direction take that
+	// direction, others take the embedding direction; numbers count as
+	// right-to-left
+	strong := func(c int) int {
+		switch c {
+		case bidiL:
+			return bidiL
+		case bidiR, bidiEN, bidiAN:
+			return bidiR
+		}
+		return -1
+	}
+	for j := 0; j < n; j++ {
+		if strong(cls[j]) >= 0 {
+			continue
+		}
+		k := j
+		for k < n && strong(cls[k]) < 0 {
+			k++
+		}
+		before, after := sos, sos
+		if j > 0 {
+			before = strong(cls[j-1])
+		}
+		if k < n {
+			after = strong(cls[k])
+		}
+		dir := sos
+		if before == after {
+			dir = before
+		}
+		for ; j < k; j++ {
+			cls[j] = dir
+		}
+		j = k - 1
+	}
+	// I1, I2: implicit levels
+	levels := make([]int, n)
+	for j, c := range cls {
+		levels[j] = base
+		if base == 0 {
+			switch c {
+			case bidiR:
+				levels[j] = 1
+			case bidiEN, bidiAN:
+				levels[j] = 2
+			}
+		} else if c == bidiL || c == bidiEN || c == bidiAN {
+			levels[j] = 2
+		}
+	}
+	// L1: trailing white space is reset to the paragraph level
+	for j := n - 1; j >= 0 && bidiClass(rs[j]) == bidiWS; j-- {
+		levels[j] = base
+	}
+	return levels
+}
+
+// bidiOrder returns the logical indices of the characters of a line, with
+// the specified resolved levels, in visual order
+func bidiOrder(levels []int) []int {
+	order := make([]int, len(levels))
+	lv := make([]int, len(levels))
+	maxLevel, minOdd := 0, 3
+	for j, lvl := range levels {
+		order[j] = j
+		lv[j] = lvl
+		if lvl > maxLevel {
+			maxLevel = lvl
+		}
+		if lvl%2 == 1 && lvl < minOdd {
+			minOdd = lvl
+		}
+	}
+	// L2: reverse runs at each level from the highest to the lowest odd level
+	for lvl := maxLevel; lvl >= minOdd; lvl-- {
+		for j := 0; j < len(order); j++ {
+			if lv[j] < lvl {
+				continue
+			}
+			k := j
+			for k < len(order) && lv[k] >= lvl {
+				k++
+			}
+			for a, b := j, k-1; a < b; a, b = a+1, b-1 {
+				order[a], order[b] = order[b], order[a]
+				lv[a], lv[b] = lv[b], lv[a]
+			}
+			j = k
+		}
+	}
+	return order
+}
+
+// bidiReorder returns the characters of a line in visual order
+func bidiReorder(rs []rune, base int) []rune {
+	levels := bidiLevels(rs, base)
+	out := make([]rune, len(rs))
+	for j, k := range bidiOrder(levels) {
+		out[j] = rs[k]
+		if levels[k]%2 == 1 {
+			if m, ok := bidiMirror[out[j]]; ok {
+				out[j] = m
+			}
+		}
+	}
+	return out
+}
+
+// visualText returns a line of text in the order in which it is displayed,
+// with Arabic letters shaped. Text without right-to-left characters is
+// returned unchanged unless the base direction is right-to-left.
+func (f *Fpdf) visualText(txtStr string) string {
+	rs := []rune(txtStr)
+	base := f.bidiBaseLevel(rs)
+	if base == 0 && !hasRTL(rs) {
+		return txtStr
+	}
+	return string(bidiReorder(arabicShape(rs), base))
+}
@@ -0,0 +1,49 @@
+package gofpdf
+
+import (
+	"testing"
+)
+
+func TestBidiReorder(t *testing.T) {
+	for _, tc := range []struct {
+		logical, visual string
+		base            int
+	}{
+		// Hebrew in a left-to-right line
+		{"abc אבג def", "abc גבא def", 0},
+		// Latin text and numbers in a right-to-left line
+		{"אבג abc דהו", "והד abc גבא", 1},
+		{"מחיר 120 ש\"ח.", ".ח\"ש 120 ריחמ", 1},
+		{"אבג (דה)", "(הד) גבא", 1},
+		// Numbers with separators keep their order
+		{"אבג 1,234.5%", "1,234.5% גבא", 1},
+	} {
+		got := string(bidiReorder([]rune(tc.logical), tc.base))
+		if got != tc.visual {
+			t.Errorf("%q: got %q, expecting %q", tc.logical, got, tc.visual)
+		}
+	}
+}
+
+func TestArabicShape(t *testing.T) {
+	for _, tc := range []struct {
+		logical string
+		shaped  []rune
+	}{
+		// beh, isolated
+		{"ب", []rune{0xfe8f}},
+		// beh beh beh: initial, medial, final
+		{"ببب", []rune{0xfe91, 0xfe92, 0xfe90}},
+		// alef does not join the following letter
+		{"اب", []rune{0xfe8d, 0xfe8f}},
+		// lam alef ligature after a joining letter
+		{"بلا", []rune{0xfe91, 0xfefc}},
+		// a transparent mark does not break joining
+		{"بَب", []rune{0xfe91, 0x064e, 0xfe90}},
+	} {
+		got := arabicShape([]rune(tc.logical))
+		if string(got) != string(tc.shaped) {
+			t.Errorf("%q: got %U, expecting %U", tc.logical, got, tc.shaped)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package gofpdf
+
+import (
+	"fmt"
+)
+
+// blockType is a block of content that is kept together on a page
+type blockType struct {
+	depth  int     // number of nested blocks
+	page   int     // page on which the block starts
+	offset int     // offset of the block in the content of the page
+	links  int     // number of links of the page before the block
+	y      float64 // ordinate at which the block starts
+	column int     // column in which the block starts
+	x      float64 // left margin at the start of the block
+	state  string  // graphics state at the start of the block
+	moved  bool    // the block has been moved to a new page
+}
+
+// movedBlockType is the content of a block that is moved to a new page
+type movedBlockType struct {
+	page    int // page from which the block is moved
+	content []byte
+	links   []linkType
+	y       float64 // ordinate at which the page was broken
+}
+
+// BeginBlock starts a block of content that is kept together on a page. If
+// a page break occurs within the block, automatically or by a call to
+// AddPage(), the content of the block that has been output so far is moved
+// to the top of the new page and the block continues below it. A block is
+// moved at most once, so a block that is taller than a page is split across
+// pages. Blocks are ended with EndBlock() and can be nested, in which case
+// the outermost block is kept together.
+//
+// Blocks can keep a heading together with the first lines of the following
+// paragraph, or a figure with its caption.
+//
+// The BeginBlock example demonstrates this method.
+func (f *Fpdf) BeginBlock() {
+	if f.err != nil {
+		return
+	}
+	if f.block != nil {
+		f.block.depth++
+		return
+	}
+	f.block = &blockType{depth: 1, page: f.page, y: f.y, column: f.columns.current, x: f.lMargin}
+	if f.page > 0 {
+		f.block.offset = f.pages[f.page].Len()
+		f.block.links = len(f.pageLinks[f.page])
+		var s fmtBuffer
+		if f.currentFont.Name != "" {
+			s.printf("BT /F%s %.2f Tf ET ", f.currentFont.i, f.fontSizePt)
+		}
+		s.printf("%s %s %.2f w %.5f Tc %.5f Tw %.2f Ts ", f.color.draw.str, f.color.fill.str,
+			f.lineWidth*f.k, f.charSpacing*f.k, f.wordSpacing*f.k, f.textRise)
+		f.block.state = s.String()
+	}
+}
+
+// EndBlock ends a block started with BeginBlock().
+func (f *Fpdf) EndBlock() {
+	if f.err != nil {
+		return
+	}
+	if f.block == nil {
+		f.err = fmt.Errorf("EndBlock() called without BeginBlock()")
+		return
+	}
+	f.block.depth--
+	if f.block.depth == 0 {
+		f.block = nil
+	}
+}
+
+// SetKeepTogether specifies whether the text of each call of MultiCell(),
+// WriteParagraph() and WriteStyled() is kept together on a page, as if it
+// were enclosed by BeginBlock() and EndBlock().
+func (f *Fpdf) SetKeepTogether(on bool) {
+	f.keepTogether = on
+}
+
+// GetKeepTogether returns true if text is kept together as set with
+// SetKeepTogether().
+func (f *Fpdf) GetKeepTogether() bool {
+	return f.keepTogether
+}
+
+// cutBlock removes the content of the current block from the current page
+// if a page break within the block moves it to the new page. It returns nil
+// if no block is moved.
+func (f *Fpdf) cutBlock() *movedBlockType {
+	b := f.block
+	if b == nil || b.moved || b.page != f.page || b.column != f.columns.current || f.page == 0 ||
+		f.inHeader || f.inFooter {
+		return nil
+	}
+	buf := f.pages[f.page]
+	moved := &movedBlockType{
+		page:    f.page,
+		content: append([]byte(nil), buf.Bytes()[b.offset:]...),
+		links:   append([]linkType(nil), f.pageLinks[f.page][b.links:]...),
+		y:       f.y,
+	}
+	buf.Truncate(b.offset)
+	f.pageLinks[f.page] = f.pageLinks[f.page][:b.links]
+	return moved
+}
+
+// pasteBlock outputs the content of a block removed by cutBlock() at the
+// current position of the new page or column, and moves below it. If the
+// block starts as high on the previous page or does not fit on the new page,
+// its content is returned to the previous page instead.
+func (f *Fpdf) pasteBlock(moved *movedBlockType) {
+	if moved == nil || f.err != nil {
+		return
+	}
+	b := f.block
+	b.moved = true
+	dx, dy := f.lMargin-b.x, f.y-b.y
+	if dy >= 0 || f.y+moved.y-b.y > f.pageBreakTrigger {
+		f.pages[moved.page].Write(moved.content)
+		f.pageLinks[moved.page] = append(f.pageLinks[moved.page], moved.links...)
+		return
+	}
+	if len(moved.content) > 0 {
+		f.outf("q %s1 0 0 1 %.2f %.2f cm", b.state, dx*f.k, -dy*f.k)
+		f.pages[f.page].Write(f.structMoveContent(moved.content, moved.page))
+		f.out("Q")
+	}
+	for _, link := range moved.links {
+		link.x += dx * f.k
+		link.y -= dy * f.k
+		f.pageLinks[f.page] = append(f.pageLinks[f.page], link)
+	}
+	b.page, b.column, b.x, b.y = f.page, f.columns.current, f.lMargin, f.y
+	f.y = moved.y + dy
+}
@@ -0,0 +1,157 @@
+package gofpdf
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math/bits"
+)
+
+// parsebmp extracts info from BMP data, which is embedded losslessly.
+// Uncompressed images with 1, 4, 8, 16, 24 or 32 bits per pixel are read,
+// including images with bit fields and an alpha channel.
+func (f *Fpdf) parsebmp(r io.Reader, readdpi bool) (info *ImageInfoType) {
+	buf, err := bufferFromReader(r)
+	if err != nil {
+		f.err = err
+		return
+	}
+	img, dpi, err := decodeBMP(buf.Bytes())
+	if err != nil {
+		f.err = err
+		return
+	}
+	info = f.parseimage(img, "png", 0)
+	if f.err == nil && readdpi && dpi > 0 {
+		info.dpi = dpi
+	}
+	return
+}
+
+// decodeBMP returns the image of the BMP data and its resolution in dots per
+// inch, or 0 if the resolution is not set
+func decodeBMP(data []byte) (img image.Image, dpi float64, err error) {
+	le := binary.LittleEndian
+	if len(data) < 26 || data[0] != 'B' || data[1] != 'M' {
+		return nil, 0, fmt.Errorf("image is not a BMP image")
+	}
+	pixels := int(le.Uint32(data[10:]))
+	hdrSize := int(le.Uint32(data[14:]))
+	var w, h, bpp, compression, colors int
+	switch {
+	case hdrSize == 12 && len(data) >= 26:
+		// OS/2 bitmap core header
+		w, h = int(le.Uint16(data[18:])), int(le.Uint16(data[20:]))
+		bpp = int(le.Uint16(data[24:]))
+	case hdrSize >= 40 && len(data) >= 14+hdrSize:
+		w, h = int(int32(le.Uint32(data[18:]))), int(int32(le.Uint32(data[22:])))
+		bpp, compression = int(le.Uint16(data[28:])), int(le.Uint32(data[30:]))
+		dpi = float64(int32(le.Uint32(data[38:]))) * 0.0254
+		colors = int(le.Uint32(data[46:]))
+	default:
+		return nil, 0, fmt.Errorf("BMP image header is not supported")
+	}
+	topDown := h < 0
+	if topDown {
+		h = -h
+	}
+	if w <= 0 || h <= 0 || w > 1<<16 || h > 1<<16 {
+		return nil, 0, fmt.Errorf("BMP image has invalid size %d x %d", w, h)
+	}
+	// The masks of the red, green, blue and alpha components
+	var masks [4]uint32
+	switch {
+	case compression == 0 && bpp == 16:
+		masks = [4]uint32{0x7c00, 0x03e0, 0x001f, 0}
+	case compression == 0 && (bpp == 24 || bpp == 32):
+		masks = [4]uint32{0xff0000, 0x00ff00, 0x0000ff, 0}
+	case (compression == 3 || compression == 6) && (bpp == 16 || bpp == 32):
+		n := 3
+		if compression == 6 || hdrSize >= 56 {
+			n = 4
+		}
+		if len(data) < 54+n*4 {
+			return nil, 0, fmt.Errorf("BMP image bit fields are truncated")
+		}
+		for j := 0; j < n; j++ {
+			masks[j] = le.Uint32(data[54+j*4:])
+		}
+	case compression == 0 && (bpp == 1 || bpp == 4 || bpp == 8):
+	default:
+		return nil, 0, fmt.Errorf("BMP image with %d bits per pixel and compression %d is not supported", bpp, compression)
+	}
+	stride := (w*bpp + 31) / 32 * 4
+	if pixels < 0 || pixels+stride*h > len(data) {
+		return nil, 0, fmt.Errorf("BMP image data is truncated")
+	}
+	row := func(y int) []byte {
+		if !topDown {
+			y = h - 1 - y
+		}
+		return data[pixels+y*stride : pixels+(y+1)*stride]
+	}
+	rect := image.Rect(0, 0, w, h)
+	if bpp <= 8 {
+		// Palette entries are 3 bytes in core headers and 4 bytes otherwise
+		entry, pos := 4, 14+hdrSize
+		if hdrSize == 12 {
+			entry = 3
+		}
+		if colors == 0 || colors > 1<<uint(bpp) {
+			colors = 1 << uint(bpp)
+		}
+		if pos+colors*entry > len(data) {
+			return nil, 0, fmt.Errorf("BMP image palette is truncated")
+		}
+		pal := make(color.Palette, 1<<uint(bpp))
+		for j := range pal {
+			pal[j] = color.RGBA{A: 255}
+			if j < colors {
+				p := data[pos+j*entry:]
+				pal[j] = color.RGBA{R: p[2], G: p[1], B: p[0], A: 255}
+			}
+		}
+		m := image.NewPaletted(rect, pal)
+		for y := 0; y < h; y++ {
+			src, dst := row(y), m.Pix[y*m.Stride:]
+			for x := 0; x < w; x++ {
+				bit := x * bpp
+				dst[x] = src[bit/8] >> uint(8-bpp-bit%8) & (1<<uint(bpp) - 1)
+			}
+		}
+		return m, dpi, nil
+	}
+	m := image.NewNRGBA(rect)
+	for y := 0; y < h; y++ {
+		src, dst := row(y), m.Pix[y*m.Stride:]
+		for x := 0; x < w; x++ {
+			var px uint32
+			switch bpp {
+			case 16:
+				px = uint32(le.Uint16(src[x*2:]))
+			case 24:
+				px = uint32(src[x*3]) | uint32(src[x*3+1])<<8 | uint32(src[x*3+2])<<16
+			default:
+				px = le.Uint32(src[x*4:])
+			}
+			for j := 0; j < 3; j++ {
+				dst[x*4+j] = bmpComponent(px, masks[j], 0)
+			}
+			dst[x*4+3] = bmpComponent(px, masks[3], 255)
+		}
+	}
+	return m, dpi, nil
+}
+
+// bmpComponent returns the component of the pixel px selected by mask, scaled
+// to 8 bits, or def if mask is 0
+func bmpComponent(px, mask uint32, def uint8) uint8 {
+	if mask == 0 {
+		return def
+	}
+	n := bits.OnesCount32(mask)
+	v := (px & mask) >> uint(bits.TrailingZeros32(mask))
+	return uint8(uint64(v) * 255 / (1<<uint(n) - 1))
+}
@@ -0,0 +1,78 @@
+package gofpdf
+
+import (
+	"errors"
+)
+
+// pageCaptureType is the part of a page captured by CapturePageRegion(), in
+// points from the lower left corner of the page
+type pageCaptureType struct {
+	x, y, wd, ht float64
+	doc          *Fpdf // document the region was captured from
+}
+
+// CapturePageRegion returns a template that holds what has been drawn so far
+// in the rectangle of width w and height h with its upper left corner at (x,
+// y) on the current page. The template is placed with UseTemplate(), which
+// puts it where it was captured, or UseTemplateScaled(), like the templates
+// of CreateTemplate(), but it shares the fonts, images and other resources of
+// the document instead of holding its own, so that a complex fragment such as
+// a legend or a stamp that has been drawn once can be put on later pages at
+// the cost of a single operator.
+//
+// Drawing that extends beyond the rectangle is clipped. Links and
+// annotations of the region are not captured, and neither are aliases such
+// as that of AliasNbPages() replaced in it. Since it depends on the document,
+// the template cannot be used in other documents or templates, or be
+// serialized. An error occurs if no page has been added, if a pattern, soft
+// mask or transparency group is being drawn, or if w or h is not positive.
+//
+// The CapturePageRegion example demonstrates this method.
+func (f *Fpdf) CapturePageRegion(x, y, w, h float64) Template {
+	if f.err != nil {
+		return nil
+	}
+	if f.page == 0 || f.state != 2 {
+		f.err = errors.New("a page must be added before a page region is captured")
+		return nil
+	}
+	if f.canvasDepth > 0 {
+		f.err = errors.New("a page region cannot be captured while a pattern, soft mask or transparency group is drawn")
+		return nil
+	}
+	if w <= 0 || h <= 0 {
+		f.SetErrorf("invalid page region size %.2f x %.2f", w, h)
+		return nil
+	}
+	page := f.pages[f.page].Bytes()
+	content := make([]byte, len(page), len(page)+2*len(f.nests))
+	copy(content, page)
+	// The clipping operations, transformations and saved graphics states
+	// still active on the page are ended in the region
+	for _, nest := range f.nests {
+		if nest.page == f.page {
+			content = append(content, "Q\n"...)
+		}
+	}
+	capture := &pageCaptureType{x: x * f.k, y: (f.h - y - h) * f.k, wd: w * f.k, ht: h * f.k, doc: f}
+	return &FpdfTpl{corner: PointType{X: x, Y: y}, size: SizeType{Wd: w, Ht: h}, bytes: [][]byte{nil, content}, page: 1, capture: capture}
+}
+
+// putPageCapture writes the template t of a page region as a form XObject
+// that uses the resources of the document
+func (f *Fpdf) putPageCapture(t *FpdfTpl) {
+	content := t.Bytes()
+	filter := ""
+	if f.compress {
+		filter = "/Filter /" + f.filterName() + " "
+		content = f.compressStream(content)
+	}
+	r := t.capture
+	f.newobj()
+	f.templateObjects[t.ID()] = f.n
+	f.outf("<<%s/Type /XObject /Subtype /Form /BBox [%.5f %.5f %.5f %.5f]", filter, r.x, r.y, r.x+r.wd, r.y+r.ht)
+	f.outf("/Matrix [1 0 0 1 %.5f %.5f] /Resources 2 0 R", 0-r.x, 0-r.y)
+	f.outf("/Length %d >>", f.protect.streamLength(len(content)))
+	f.putstream(content)
+	f.out("endobj")
+}
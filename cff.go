@@ -0,0 +1,343 @@
+package gofpdf
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// OpenType fonts with PostScript outlines keep their glyphs in a table in
+// the Compact Font Format (CFF). The table is embedded as it is, without
+// subsetting, as the program of a CIDFontType0 font. Text is still encoded
+// with the Unicode values of its characters, so the Type0 font has an
+// embedded CMap that maps them to the CIDs of the font's glyphs.
+
+// cffFontType holds the information from the CFF table of an OpenType font
+// needed to embed it
+type cffFontType struct {
+	data     []byte
+	registry string // character collection of a CID-keyed font
+	ordering string
+	supp     int
+	cids     []int // CID of each glyph of a CID-keyed font
+}
+
+// cffIndex returns the objects of the CFF INDEX at position pos of data, and
+// the position that follows it
+func cffIndex(data []byte, pos int) (objs [][]byte, next int, err error) {
+	if pos+2 > len(data) {
+		return nil, 0, fmt.Errorf("CFF INDEX out of range")
+	}
+	count := int(data[pos])<<8 | int(data[pos+1])
+	if count == 0 {
+		return nil, pos + 2, nil
+	}
+	if pos+3 > len(data) {
+		return nil, 0, fmt.Errorf("CFF INDEX out of range")
+	}
+	offSize := int(data[pos+2])
+	if offSize < 1 || offSize > 4 || pos+3+(count+1)*offSize > len(data) {
+		return nil, 0, fmt.Errorf("invalid CFF INDEX")
+	}
+	offset := func(j int) int {
+		v := 0
+		for _, b := range data[pos+3+j*offSize : pos+3+(j+1)*offSize] {
+			v = v<<8 | int(b)
+		}
+		return v
+	}
+	base := pos + 2 + (count+1)*offSize
+	for j := 0; j < count; j++ {
+		start, end := base+offset(j), base+offset(j+1)
+		if start > end || end > len(data) {
+			return nil, 0, fmt.Errorf("invalid CFF INDEX offset")
+		}
+		objs = append(objs, data[start:end])
+	}
+	return objs, base + offset(count), nil
+}
+
+// cffDict returns the operands of each operator in the CFF DICT data. Escaped
+// operators are returned as 1200 plus their second byte. Real numbers are
+// returned as zero, since none are needed.
+func cffDict(data []byte) (map[int][]int, error) {
+	dict := make(map[int][]int)
+	var operands []int
+	for j := 0; j < len(data); {
+		b0 := int(data[j])
+		switch {
+		case b0 == 12:
+			if j+1 >= len(data) {
+				return nil, fmt.Errorf("truncated CFF DICT")
+			}
+			dict[1200+int(data[j+1])] = operands
+			operands = nil
+			j += 2
+		case b0 <= 21:
+			dict[b0] = operands
+			operands = nil
+			j++
+		case b0 == 28 || b0 == 29:
+			n := 2
+			if b0 == 29 {
+				n = 4
+			}
+			if j+n >= len(data) {
+				return nil, fmt.Errorf("truncated CFF DICT")
+			}
+			v := 0
+			for _, b := range data[j+1 : j+1+n] {
+				v = v<<8 | int(b)
+			}
+			if n == 2 {
+				v = int(int16(v))
+			} else {
+				v = int(int32(v))
+			}
+			operands = append(operands, v)
+			j += 1 + n
+		case b0 == 30:
+			j++
+			for j < len(data) && data[j]&0x0f != 0x0f && data[j]>>4 != 0x0f {
+				j++
+			}
+			j++
+			operands = append(operands, 0)
+		case b0 >= 32 && b0 <= 246:
+			operands = append(operands, b0-139)
+			j++
+		case b0 >= 247 && b0 <= 254:
+			if j+1 >= len(data) {
+				return nil, fmt.Errorf("truncated CFF DICT")
+			}
+			v := (b0-247)*256 + int(data[j+1]) + 108
+			if b0 >= 251 {
+				v = -(b0-251)*256 - int(data[j+1]) - 108
+			}
+			operands = append(operands, v)
+			j += 2
+		default:
+			return nil, fmt.Errorf("invalid CFF DICT byte %d", b0)
+		}
+	}
+	return dict, nil
+}
+
+// parseCFF parses the CFF table of the font
+func (utf *utf8FontFile) parseCFF() error {
+	data := utf.getTableData("CFF ")
+	if len(data) < 4 {
+		return fmt.Errorf("font has no CFF table")
+	}
+	cff := &cffFontType{data: data, registry: "Adobe", ordering: "Identity"}
+	_, pos, err := cffIndex(data, int(data[2]))
+	if err != nil {
+		return err
+	}
+	topDicts, pos, err := cffIndex(data, pos)
+	if err != nil {
+		return err
+	}
+	if len(topDicts) != 1 {
+		return fmt.Errorf("CFF table must contain exactly one font")
+	}
+	strs, _, err := cffIndex(data, pos)
+	if err != nil {
+		return err
+	}
+	top, err := cffDict(topDicts[0])
+	if err != nil {
+		return err
+	}
+	if ops, ok := top[17]; !ok || len(ops) != 1 {
+		return fmt.Errorf("CFF font has no CharStrings")
+	}
+	charStrings, _, err := cffIndex(data, top[17][0])
+	if err != nil {
+		return err
+	}
+	if ros, ok := top[1230]; ok && len(ros) == 3 {
+		// CID-keyed font, whose charset maps glyphs to CIDs. The registry and
+		// ordering are never among the 391 standard strings.
+		if ros[0] >= 391 && ros[0]-391 < len(strs) {
+			cff.registry = string(strs[ros[0]-391])
+		}
+		if ros[1] >= 391 && ros[1]-391 < len(strs) {
+			cff.ordering = string(strs[ros[1]-391])
+		}
+		cff.supp = ros[2]
+		if len(top[15]) != 1 {
+			return fmt.Errorf("CID-keyed CFF font has no charset")
+		}
+		cff.cids, err = cffCharset(data, top[15][0], len(charStrings))
+		if err != nil {
+			return err
+		}
+	}
+	utf.cff = cff
+	return nil
+}
+
+// cffCharset returns the values, which are CIDs in CID-keyed fonts, of the
+// charset at position pos of data for a font with numGlyphs glyphs
+func cffCharset(data []byte, pos, numGlyphs int) ([]int, error) {
+	vals := make([]int, 1, numGlyphs)
+	u16 := func(p int) int {
+		return int(data[p])<<8 | int(data[p+1])
+	}
+	if pos >= len(data) {
+		return nil, fmt.Errorf("CFF charset out of range")
+	}
+	format := data[pos]
+	pos++
+	for len(vals) < numGlyphs {
+		switch format {
+		case 0:
+			if pos+2 > len(data) {
+				return nil, fmt.Errorf("truncated CFF charset")
+			}
+			vals = append(vals, u16(pos))
+			pos += 2
+		case 1, 2:
+			size := 3
+			if format == 2 {
+				size = 4
+			}
+			if pos+size > len(data) {
+				return nil, fmt.Errorf("truncated CFF charset")
+			}
+			first, left := u16(pos), int(data[pos+2])
+			if format == 2 {
+				left = u16(pos + 2)
+			}
+			for j := 0; j <= left && len(vals) < numGlyphs; j++ {
+				vals = append(vals, first+j)
+			}
+			pos += size
+		default:
+			return nil, fmt.Errorf("unsupported CFF charset format %d", format)
+		}
+	}
+	return vals, nil
+}
+
+// cid returns the CID of glyph gid
+func (cff *cffFontType) cid(gid int) int {
+	if cff.cids == nil {
+		return gid
+	}
+	if gid < len(cff.cids) {
+		return cff.cids[gid]
+	}
+	return 0
+}
+
+// putCFFFont writes the objects of a UTF-8 font with CFF outlines
+func (f *Fpdf) putCFFFont(font fontDefType) {
+	utf := font.utf8File
+	fontName := "utf8" + font.Name
+	codes := make([]int, 0, len(font.usedRunes))
+	for code := range font.usedRunes {
+		if code > 0 && code < 0x10000 {
+			codes = append(codes, code)
+		}
+	}
+	sort.Ints(codes)
+	cids := make(map[int]int)
+	for _, code := range codes {
+		gid, ok := utf.privateGlyphs[code]
+		if !ok {
+			gid = utf.runeGlyph(rune(code))
+		}
+		cids[code] = utf.cff.cid(gid)
+	}
+	sysInfo := sprintf("<</Registry (%s) /Ordering (%s) /Supplement %d>>",
+		utf.cff.registry, utf.cff.ordering, utf.cff.supp)
+
+	f.newobj()
+	f.outf("<</Type /Font\n/Subtype /Type0\n/BaseFont /%s\n/Encoding %d 0 R\n/DescendantFonts [%d 0 R]\n/ToUnicode %d 0 R>>",
+		fontName, f.n+1, f.n+2, f.n+3)
+	f.out("endobj")
+
+	// Encoding CMap from character codes to CIDs
+	var s fmtBuffer
+	s.printf("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n")
+	s.printf("/CIDSystemInfo %s def\n/CMapName /%s def\n/CMapType 1 def\n", sysInfo, fontName)
+	s.printf("1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+	for j := 0; j < len(codes); j += 100 {
+		block := codes[j:]
+		if len(block) > 100 {
+			block = block[:100]
+		}
+		s.printf("%d begincidchar\n", len(block))
+		for _, code := range block {
+			s.printf("<%04X> %d\n", code, cids[code])
+		}
+		s.printf("endcidchar\n")
+	}
+	s.printf("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend")
+	cmap := s.Bytes()
+	f.newobj()
+	f.outf("<</Type /CMap /CMapName /%s /CIDSystemInfo %s /Length %d>>",
+		fontName, sysInfo, f.protect.streamLength(len(cmap)))
+	f.putstream(cmap)
+	f.out("endobj")
+
+	// Widths are indexed by CID
+	widths := make(map[int]int)
+	for _, code := range codes {
+		if w := font.Cw[code]; w != 0 {
+			if w == 65535 {
+				w = 0
+			}
+			widths[cids[code]] = w
+		}
+	}
+	cidList := make([]int, 0, len(widths))
+	for cid := range widths {
+		cidList = append(cidList, cid)
+	}
+	sort.Ints(cidList)
+	s = fmtBuffer{}
+	s.printf("<</Type /Font\n/Subtype /CIDFontType0\n/BaseFont /%s\n/CIDSystemInfo %s\n/FontDescriptor %d 0 R",
+		fontName, sysInfo, f.n+3)
+	if font.Desc.MissingWidth != 0 {
+		s.printf("\n/DW %d", font.Desc.MissingWidth)
+	}
+	s.printf("\n/W [")
+	for _, cid := range cidList {
+		s.printf(" %d [%d]", cid, widths[cid])
+	}
+	s.printf(" ]>>")
+	f.newobj()
+	f.out(s.String())
+	f.out("endobj")
+
+	f.newobj()
+	f.out("<</Length " + strconv.Itoa(f.protect.streamLength(len(toUnicode))) + ">>")
+	f.putstream([]byte(toUnicode))
+	f.out("endobj")
+
+	// Font descriptor
+	f.newobj()
+	s = fmtBuffer{}
+	s.printf("<</Type /FontDescriptor /FontName /%s\n /Ascent %d", fontName, font.Desc.Ascent)
+	s.printf(" /Descent %d", font.Desc.Descent)
+	s.printf(" /CapHeight %d", font.Desc.CapHeight)
+	s.printf(" /Flags %d", (font.Desc.Flags|4)&^32)
+	s.printf("/FontBBox [%d %d %d %d] ", font.Desc.FontBBox.Xmin, font.Desc.FontBBox.Ymin,
+		font.Desc.FontBBox.Xmax, font.Desc.FontBBox.Ymax)
+	s.printf(" /ItalicAngle %d", font.Desc.ItalicAngle)
+	s.printf(" /StemV %d", font.Desc.StemV)
+	s.printf(" /MissingWidth %d", font.Desc.MissingWidth)
+	s.printf("/FontFile3 %d 0 R>>", f.n+1)
+	f.out(s.String())
+	f.out("endobj")
+
+	// Font program
+	compressed := f.compressFlate(utf.cff.data)
+	f.newobj()
+	f.outf("<</Subtype /CIDFontType0C /Filter /FlateDecode /Length %d>>", f.protect.streamLength(len(compressed)))
+	f.putstream(compressed)
+	f.out("endobj")
+}
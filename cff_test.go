@@ -0,0 +1,137 @@
+package gofpdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// testOTF returns an OpenType font with the tables of DejaVuSansCondensed and
+// a CFF table whose glyphs are empty. If cidKeyed is true, the CFF font is
+// CID-keyed, with a charset that maps glyph n to CID 99+n.
+func testOTF(t *testing.T, cidKeyed bool) []byte {
+	ttf, err := ioutil.ReadFile("font/DejaVuSansCondensed.ttf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	numTables := int(binary.BigEndian.Uint16(ttf[4:]))
+	numGlyphs := 0
+	tables := make(map[string][]byte)
+	var tags []string
+	for j := 0; j < numTables; j++ {
+		rec := ttf[12+16*j:]
+		tag := string(rec[:4])
+		pos, size := binary.BigEndian.Uint32(rec[8:]), binary.BigEndian.Uint32(rec[12:])
+		tables[tag] = ttf[pos : pos+size]
+		tags = append(tags, tag)
+		if tag == "maxp" {
+			numGlyphs = int(binary.BigEndian.Uint16(ttf[pos+4:]))
+		}
+	}
+	u16 := func(v int) []byte {
+		return []byte{byte(v >> 8), byte(v)}
+	}
+	num := func(v int) []byte {
+		return []byte{29, byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)}
+	}
+	index := func(objs ...[]byte) []byte {
+		b := append(u16(len(objs)), 2)
+		off := 1
+		b = append(b, u16(off)...)
+		for _, obj := range objs {
+			off += len(obj)
+			b = append(b, u16(off)...)
+		}
+		for _, obj := range objs {
+			b = append(b, obj...)
+		}
+		return b
+	}
+	header := []byte{1, 0, 4, 2}
+	names := index([]byte("Test"))
+	strs := index([]byte("Adobe"), []byte("Japan1"))
+	gsubrs := u16(0)
+	charStrings := make([][]byte, numGlyphs)
+	for j := range charStrings {
+		charStrings[j] = []byte{14}
+	}
+	// The top DICT has a fixed size, since all offsets are 32-bit numbers
+	topSize := 6
+	if cidKeyed {
+		topSize += 13 + 6
+	}
+	start := len(header) + len(names) + len(index(make([]byte, topSize))) + len(strs) + len(gsubrs)
+	var top, charset []byte
+	csPos := start
+	if cidKeyed {
+		charset = append([]byte{2}, append(u16(100), u16(numGlyphs-2)...)...)
+		csPos += len(charset)
+		top = append(append(append(num(391), num(392)...), 139, 12, 30), append(num(start), 15)...)
+	}
+	top = append(top, append(num(csPos), 17)...)
+	cff := append(append(header, names...), index(top)...)
+	cff = append(append(append(cff, strs...), gsubrs...), charset...)
+	cff = append(cff, index(charStrings...)...)
+	tables["CFF "] = cff
+	tags = append(tags, "CFF ")
+
+	var buf bytes.Buffer
+	buf.Write([]byte("OTTO"))
+	buf.Write(u16(len(tags)))
+	buf.Write(make([]byte, 6))
+	pos := 12 + 16*len(tags)
+	for _, tag := range tags {
+		buf.WriteString(tag)
+		buf.Write(make([]byte, 4))
+		binary.Write(&buf, binary.BigEndian, uint32(pos))
+		binary.Write(&buf, binary.BigEndian, uint32(len(tables[tag])))
+		pos += (len(tables[tag]) + 3) &^ 3
+	}
+	for _, tag := range tags {
+		buf.Write(tables[tag])
+		buf.Write(make([]byte, (4-len(tables[tag])%4)%4))
+	}
+	return buf.Bytes()
+}
+
+// TestCFFFont checks that fonts with PostScript outlines are embedded as
+// CIDFontType0 fonts whose encoding maps characters to the CIDs of their
+// glyphs
+func TestCFFFont(t *testing.T) {
+	for _, cidKeyed := range []bool{false, true} {
+		pdf := New("P", "mm", "A4", "")
+		pdf.SetCompression(false)
+		pdf.AddUTF8FontFromBytes("otf", "", testOTF(t, cidKeyed))
+		pdf.SetFont("otf", "", 12)
+		pdf.AddPage()
+		pdf.Write(10, "ABBA")
+		var buf bytes.Buffer
+		if err := pdf.Output(&buf); err != nil {
+			t.Fatal(err)
+		}
+		doc := buf.String()
+		utf := pdf.currentFont.utf8File
+		cid := utf.runeGlyph('B')
+		ordering := "Identity"
+		if cidKeyed {
+			cid += 99
+			ordering = "Japan1"
+		}
+		for _, want := range []string{
+			"/Subtype /CIDFontType0\n",
+			"/Subtype /CIDFontType0C",
+			"/Ordering (" + ordering + ")",
+			sprintf("<0042> %d\n", cid),
+			sprintf(" %d [%d]", cid, pdf.currentFont.Cw['B']),
+		} {
+			if !strings.Contains(doc, want) {
+				t.Fatalf("CID-keyed %v: %q not found", cidKeyed, want)
+			}
+		}
+		if strings.Contains(doc, "/FontFile2") || strings.Contains(doc, "/CIDToGIDMap") {
+			t.Fatalf("CID-keyed %v: TrueType font objects found", cidKeyed)
+		}
+	}
+}
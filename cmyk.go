@@ -0,0 +1,202 @@
+package gofpdf
+
+import (
+	"fmt"
+)
+
+// cmykColorValue returns the color with the ink percentages c, m, y and k,
+// which are quietly capped to 100, set with the operator opStr. Its RGB
+// components are those of the color on screen.
+func cmykColorValue(c, m, y, k byte, opStr string) (clr colorType) {
+	c, m, y, k = byteBound(c), byteBound(m), byteBound(y), byteBound(k)
+	clr.mode = colorModeCMYK
+	clr.cmyk = cmykColorType{c: c, m: m, y: y, k: k}
+	comp := func(v byte) (int, float64) {
+		return colorComp(int(255*(100-float64(v))*(100-float64(k))/10000 + 0.5))
+	}
+	clr.ir, clr.r = comp(c)
+	clr.ig, clr.g = comp(m)
+	clr.ib, clr.b = comp(y)
+	clr.str = sprintf("%.3f %.3f %.3f %.3f %s", float64(c)/100, float64(m)/100,
+		float64(y)/100, float64(k)/100, opStr)
+	return
+}
+
+// SetDrawColorCMYK sets the color used for all drawing operations to the
+// process color with the ink percentages c, m, y and k, which range from 0 to
+// 100. Values above this are quietly capped to 100. Unlike colors set with
+// SetDrawColor(), the color is put in the document in the DeviceCMYK color
+// space, as printing presses use it, instead of being converted from RGB. The
+// method can be called before the first page is created. The value is
+// retained from page to page.
+//
+// The SetOverprint example demonstrates this method.
+func (f *Fpdf) SetDrawColorCMYK(c, m, y, k byte) {
+	f.color.draw = cmykColorValue(c, m, y, k, "K")
+	if f.page > 0 {
+		f.out(f.color.draw.str)
+	}
+}
+
+// SetFillColorCMYK sets the color used for filling operations to the process
+// color with the ink percentages c, m, y and k, in the same way as
+// SetDrawColorCMYK().
+//
+// The SetOverprint example demonstrates this method.
+func (f *Fpdf) SetFillColorCMYK(c, m, y, k byte) {
+	f.color.fill = cmykColorValue(c, m, y, k, "k")
+	f.colorFlag = f.color.fill.str != f.color.text.str
+	if f.page > 0 {
+		f.out(f.color.fill.str)
+	}
+}
+
+// SetTextColorCMYK sets the color used for text to the process color with
+// the ink percentages c, m, y and k, in the same way as SetDrawColorCMYK().
+//
+// The SetOverprint example demonstrates this method.
+func (f *Fpdf) SetTextColorCMYK(c, m, y, k byte) {
+	f.color.text = cmykColorValue(c, m, y, k, "k")
+	f.colorFlag = f.color.fill.str != f.color.text.str
+}
+
+// GetDrawColorCMYK returns the most recently set CMYK draw color as ink
+// percentages. This will not be the current value if a draw color of some
+// other type has been more recently set.
+func (f *Fpdf) GetDrawColorCMYK() (c, m, y, k byte) {
+	clr := f.color.draw.cmyk
+	return clr.c, clr.m, clr.y, clr.k
+}
+
+// GetFillColorCMYK returns the most recently set CMYK fill color as ink
+// percentages. This will not be the current value if a fill color of some
+// other type has been more recently set.
+func (f *Fpdf) GetFillColorCMYK() (c, m, y, k byte) {
+	clr := f.color.fill.cmyk
+	return clr.c, clr.m, clr.y, clr.k
+}
+
+// GetTextColorCMYK returns the most recently set CMYK text color as ink
+// percentages. This will not be the current value if a text color of some
+// other type has been more recently set.
+func (f *Fpdf) GetTextColorCMYK() (c, m, y, k byte) {
+	clr := f.color.text.cmyk
+	return clr.c, clr.m, clr.y, clr.k
+}
+
+// SetDrawSpotColorCMYK sets the current draw color to the spot color nameStr
+// with the tint, as SetDrawSpotColor() does, and defines the spot color inline
+// with the ink percentages c, m, y and k of its CMYK equivalent, which range
+// from 0 to 100. The spot color is added with AddSpotColor() the first time
+// its name is used, so that print-production documents can address an ink
+// such as a Pantone color where it is used. An error occurs if the name is
+// already associated with a color of other ink percentages.
+//
+// The SetFillSpotColorCMYK example demonstrates this method.
+func (f *Fpdf) SetDrawSpotColorCMYK(nameStr string, c, m, y, k, tint byte) {
+	f.useSpotColor(nameStr, c, m, y, k)
+	f.SetDrawSpotColor(nameStr, tint)
+}
+
+// SetFillSpotColorCMYK sets the current fill color to the spot color nameStr
+// with the tint, defined inline in the same way as for
+// SetDrawSpotColorCMYK().
+//
+// The SetFillSpotColorCMYK example demonstrates this method.
+func (f *Fpdf) SetFillSpotColorCMYK(nameStr string, c, m, y, k, tint byte) {
+	f.useSpotColor(nameStr, c, m, y, k)
+	f.SetFillSpotColor(nameStr, tint)
+}
+
+// SetTextSpotColorCMYK sets the current text color to the spot color nameStr
+// with the tint, defined inline in the same way as for
+// SetDrawSpotColorCMYK().
+//
+// The SetFillSpotColorCMYK example demonstrates this method.
+func (f *Fpdf) SetTextSpotColorCMYK(nameStr string, c, m, y, k, tint byte) {
+	f.useSpotColor(nameStr, c, m, y, k)
+	f.SetTextSpotColor(nameStr, tint)
+}
+
+// useSpotColor adds the spot color nameStr with the ink percentages c, m, y
+// and k unless it has been added, or sets the error of f if it has been added
+// with other ink percentages
+func (f *Fpdf) useSpotColor(nameStr string, c, m, y, k byte) {
+	if f.err != nil {
+		return
+	}
+	clr, ok := f.spotColorMap[nameStr]
+	if !ok {
+		f.AddSpotColor(nameStr, c, m, y, k)
+		return
+	}
+	if clr.val != (cmykColorType{c: byteBound(c), m: byteBound(m), y: byteBound(y), k: byteBound(k)}) {
+		f.err = fmt.Errorf("name \"%s\" is already associated with a spot color of other inks", nameStr)
+	}
+}
+
+// overprintType holds the overprint settings of a graphics state
+type overprintType struct {
+	stroke, fill bool
+	mode         int
+	objNum       int
+}
+
+// SetOverprint sets whether the inks of lines (stroke) and of fills, text and
+// images (fill) that follow are printed over the inks beneath them, instead
+// of knocking them out. Overprinting lets a black text or a spot color varnish
+// print on top of other inks, and keeps slight misregistration of printing
+// plates from showing gaps. mode is the overprint mode: 0 to overprint CMYK
+// colors with all their inks, or 1 to leave the inks beneath where the
+// components of a CMYK color are zero. Overprinting only shows on print
+// output and in viewers that simulate it. An error occurs if mode is not 0 or
+// 1. The value is retained from page to page.
+//
+// The SetOverprint example demonstrates this method.
+func (f *Fpdf) SetOverprint(stroke, fill bool, mode int) {
+	if f.err != nil {
+		return
+	}
+	if mode != 0 && mode != 1 {
+		f.err = fmt.Errorf("overprint mode must be 0 or 1: %d", mode)
+		return
+	}
+	f.overprint = overprintType{stroke: stroke, fill: fill, mode: mode}
+	if f.page > 0 {
+		f.outputOverprint()
+	}
+}
+
+// GetOverprint returns the current overprint settings, as set with
+// SetOverprint().
+func (f *Fpdf) GetOverprint() (stroke, fill bool, mode int) {
+	return f.overprint.stroke, f.overprint.fill, f.overprint.mode
+}
+
+// outputOverprint outputs the graphics state with the current overprint
+// settings, which is added if the document does not have it yet
+func (f *Fpdf) outputOverprint() {
+	op := f.overprint
+	pos := 0
+	for j, st := range f.overprintStates {
+		if st.stroke == op.stroke && st.fill == op.fill && st.mode == op.mode {
+			pos = j + 1
+			break
+		}
+	}
+	if pos == 0 {
+		f.overprintStates = append(f.overprintStates, op)
+		pos = len(f.overprintStates)
+	}
+	f.outf("/OP%d gs", pos)
+}
+
+// putOverprints writes the graphics states with overprint settings
+func (f *Fpdf) putOverprints() {
+	for j, st := range f.overprintStates {
+		f.newobj()
+		f.overprintStates[j].objNum = f.n
+		f.outf("<</Type /ExtGState /OP %t /op %t /OPM %d>>", st.stroke, st.fill, st.mode)
+		f.out("endobj")
+	}
+}
@@ -0,0 +1,162 @@
+package gofpdf
+
+import (
+	"fmt"
+	"math"
+)
+
+// columnsType holds the layout of the columns set with SetColumns()
+type columnsType struct {
+	n       int     // number of columns, or 0 if columns are not used
+	gutter  float64 // space between columns
+	current int     // index of the current column
+	top     float64 // ordinate at which the columns start on the page
+	// lMargin and rMargin are the page margins outside the columns
+	lMargin, rMargin float64
+}
+
+// SetColumns divides the space between the left and right margins into n
+// columns of equal width, separated by gutter, starting at the current
+// ordinate. Text written with Write() and MultiCell(), and other content
+// that breaks automatically, flows down the first column, then down the
+// next one, and continues in the first column of the next page after the
+// last column. The left and right margins are those of the current column,
+// and page headers and footers are printed with the page margins. A value
+// of n less than 2 ends the columns and restores the page margins.
+//
+// ColumnBreak() moves to the next column, and MultiCellBalanced() prints
+// text in columns of equal height.
+//
+// The SetColumns example demonstrates this method.
+func (f *Fpdf) SetColumns(n int, gutter float64) {
+	if f.err != nil {
+		return
+	}
+	if f.columns.n > 1 {
+		f.lMargin, f.rMargin = f.columns.lMargin, f.columns.rMargin
+		f.x = f.lMargin
+		f.columns = columnsType{}
+	}
+	if n < 2 {
+		return
+	}
+	if gutter < 0 || f.w-f.lMargin-f.rMargin-float64(n-1)*gutter <= 0 {
+		f.err = fmt.Errorf("%d columns with a gutter of %.2f do not fit on the page", n, gutter)
+		return
+	}
+	f.columns = columnsType{n: n, gutter: gutter, top: f.y, lMargin: f.lMargin, rMargin: f.rMargin}
+	f.setColumn(0)
+}
+
+// GetColumn returns the index, starting with 0, of the current column set
+// with SetColumns(), or 0 if columns are not used.
+func (f *Fpdf) GetColumn() int {
+	return f.columns.current
+}
+
+// ColumnBreak continues in the next column set with SetColumns(), at the
+// top of the columns, or on a new page after the last column. If columns are
+// not used, it adds a page.
+func (f *Fpdf) ColumnBreak() {
+	if f.err != nil {
+		return
+	}
+	if f.columns.n > 1 && f.columns.current < f.columns.n-1 {
+		f.nextColumn()
+	} else {
+		f.AddPageFormat(f.curOrientation, f.curPageSize)
+	}
+}
+
+// MultiCellBalanced prints txtStr in the columns set with SetColumns() like
+// MultiCell() does, with lines of height h aligned with alignStr. On the
+// last page of the text, the lines are distributed over the remaining
+// columns so that they end at the same height. The current position after
+// the call is at the left margin of the first column below the columns,
+// which start again there. If columns are not used, the text is printed
+// with MultiCell().
+//
+// The SetColumns example demonstrates this method.
+func (f *Fpdf) MultiCellBalanced(h float64, txtStr, alignStr string) {
+	if f.err != nil {
+		return
+	}
+	if f.columns.n < 2 {
+		f.MultiCell(0, h, txtStr, "", alignStr, false)
+		return
+	}
+	const epsilon = 1e-6
+	for txtStr != "" && f.err == nil {
+		col := &f.columns
+		// Lines of the columns on this page, and lines that are already
+		// used in the current column
+		full := int((f.pageBreakTrigger-col.top)/h + epsilon)
+		used := int(math.Ceil((f.y-col.top)/h - epsilon))
+		cols := col.n - col.current
+		lines := len(f.SplitText(txtStr, f.w-f.lMargin-f.rMargin))
+		if full < 1 {
+			f.err = fmt.Errorf("columns are too short for lines of height %.2f", h)
+			return
+		}
+		// The number of lines of the balanced columns
+		t := 0
+		for t <= full && max(t-used, 0)+(cols-1)*t < lines {
+			t++
+		}
+		if t > full {
+			// Fill the columns of this page
+			for c := col.current; c < col.n && txtStr != ""; c++ {
+				txtStr = f.MultiCellBounded(0, h, f.pageBreakTrigger-f.y, txtStr, "", alignStr, false)
+				if txtStr != "" {
+					f.ColumnBreak()
+				}
+			}
+			continue
+		}
+		for c := col.current; c < col.n && txtStr != ""; c++ {
+			txtStr = f.MultiCellBounded(0, h, col.top+float64(t)*h-f.y, txtStr, "", alignStr, false)
+			if txtStr != "" && c < col.n-1 {
+				f.nextColumn()
+			}
+		}
+		if txtStr != "" {
+			// The text needs more lines than expected
+			f.ColumnBreak()
+			continue
+		}
+		col.top += float64(t) * h
+		f.y = col.top
+		f.setColumn(0)
+	}
+}
+
+// setColumn makes column c the current column
+func (f *Fpdf) setColumn(c int) {
+	col := &f.columns
+	width := (f.w - col.lMargin - col.rMargin - float64(col.n-1)*col.gutter) / float64(col.n)
+	x := f.x - f.lMargin
+	col.current = c
+	f.lMargin = col.lMargin + float64(c)*(width+col.gutter)
+	f.rMargin = f.w - f.lMargin - width
+	f.x = f.lMargin + math.Max(x, 0)
+}
+
+// nextColumn continues at the top of the next column of the page, moving the
+// current block there
+func (f *Fpdf) nextColumn() {
+	block := f.cutBlock()
+	f.setColumn(f.columns.current + 1)
+	f.y = f.columns.top
+	f.pasteBlock(block)
+}
+
+// acceptBreak returns true if an automatic page break is accepted. While
+// there are columns after the current one, it continues in the next column
+// instead and returns false.
+func (f *Fpdf) acceptBreak() bool {
+	if f.columns.n > 1 && f.columns.current < f.columns.n-1 && f.autoPageBreak {
+		f.nextColumn()
+		return false
+	}
+	return f.acceptPageBreak()
+}
@@ -1,6 +1,7 @@
 package barcode_test
 
 import (
+	"bytes"
 	"testing"
 
 	"github.com/boombuler/barcode/code128"
@@ -157,6 +158,27 @@ func ExampleRegisterPdf417() {
 	// Successfully generated ../../pdf/contrib_barcode_RegisterPdf417.pdf
 }
 
+func ExampleDraw() {
+	pdf := createPdf()
+
+	barcode.Draw(pdf, barcode.Spec{Type: barcode.Code128, Content: "gofpdf",
+		X: 15, Y: 15, W: 60, H: 20, HumanReadable: true})
+	barcode.Draw(pdf, barcode.Spec{Type: barcode.EAN, Content: "590123412345",
+		X: 90, Y: 15, W: 40, H: 25, HumanReadable: true})
+	barcode.Draw(pdf, barcode.Spec{Type: barcode.QR, Content: "https://github.com/jacobfederer/gofpdf",
+		X: 15, Y: 50, W: 40, H: 40})
+	barcode.Draw(pdf, barcode.Spec{Type: barcode.DataMatrix, Content: "gofpdf",
+		X: 70, Y: 50, W: 25, H: 25})
+	barcode.Draw(pdf, barcode.Spec{Type: barcode.PDF417, Content: "1234567895",
+		X: 110, Y: 50, W: 100, H: 20})
+
+	fileStr := example.Filename("contrib_barcode_Draw")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated ../../pdf/contrib_barcode_Draw.pdf
+}
+
 // TestRegisterCode128 ensures that no panic arises when an invalid barcode is registered.
 func TestRegisterCode128(t *testing.T) {
 	pdf := createPdf()
@@ -220,3 +242,40 @@ func TestBarcodeNonIntegerScalingFactors(t *testing.T) {
 	// Output:
 	// Successfully generated ../../pdf/contrib_barcode_BarcodeScaling.pdf
 }
+
+// TestDraw ensures that barcodes are drawn as rectangles within their quiet
+// zones and that invalid barcodes set an error.
+func TestDraw(t *testing.T) {
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.AddPage()
+	// EAN-13 barcodes are 95 modules wide with 18 modules of quiet zones, so
+	// that the start guard is a bar of one point, 11 points from the left
+	barcode.Draw(pdf, barcode.Spec{Type: barcode.EAN, Content: "590123412345",
+		X: 100, Y: 100, W: 113, H: 50})
+	r, g, b := pdf.GetFillColor()
+	var buf bytes.Buffer
+	err := pdf.Output(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("\n111.00 741.89 1.00 -50.00 re f\n")) {
+		t.Fatalf("start guard not found")
+	}
+	if r != 0 || g != 0 || b != 0 {
+		t.Fatalf("fill color not restored")
+	}
+
+	for _, spec := range []barcode.Spec{
+		{Type: barcode.EAN, Content: "12345", W: 10, H: 10},
+		{Type: barcode.Code128, Content: "Invalid character: é", W: 10, H: 10},
+		{Type: barcode.QR, Content: "gofpdf", W: 0, H: 10},
+		{Type: barcode.Code128, Content: "gofpdf", W: 10, H: 2, HumanReadable: true},
+	} {
+		pdf = createPdf()
+		barcode.Draw(pdf, spec)
+		if pdf.Ok() {
+			t.Fatalf("no error for barcode %+v", spec)
+		}
+	}
+}
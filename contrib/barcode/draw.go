@@ -0,0 +1,166 @@
+package barcode
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"math"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/code128"
+	"github.com/boombuler/barcode/datamatrix"
+	"github.com/boombuler/barcode/ean"
+	"github.com/boombuler/barcode/qr"
+	"github.com/ruudk/golang-pdf417"
+)
+
+// Type is a type of barcode drawn by Draw()
+type Type int
+
+const (
+	// Code128 barcodes encode any ASCII text.
+	Code128 Type = iota
+	// EAN barcodes encode 12 or 13 digits as EAN-13, or 7 or 8 digits as
+	// EAN-8. The check digit is added when it is left out.
+	EAN
+	// QR codes encode any text, with medium error correction.
+	QR
+	// DataMatrix codes encode any text.
+	DataMatrix
+	// PDF417 barcodes encode any text, in 10 columns with error correction
+	// level 2.
+	PDF417
+)
+
+// Spec describes a barcode drawn by Draw()
+type Spec struct {
+	Type    Type
+	Content string // text encoded by the barcode
+	// The upper left corner and size of the barcode, with its quiet zones and
+	// human readable line, in the unit of measure of the document
+	X, Y, W, H float64
+	// HumanReadable is true to print the content of Code128 and EAN barcodes
+	// below their bars, in the current font of the document
+	HumanReadable bool
+}
+
+// drawPdf is a partial PDF implementation that only implements a subset of
+// functions that are required to draw barcodes.
+type drawPdf interface {
+	GetFillColor() (int, int, int)
+	GetFontSize() (ptSize, unitSize float64)
+	GetStringWidth(s string) float64
+	Ok() bool
+	Rect(x, y, w, h float64, styleStr string)
+	SetError(err error)
+	SetFillColor(r, g, b int)
+	Text(x, y float64, txtStr string)
+}
+
+// Draw draws the barcode spec on the current page as filled black
+// rectangles, which print sharply at any size and resolution, instead of
+// the image put on the page by Barcode().
+//
+// The barcode is scaled to fill the rectangle of the spec, less its quiet
+// zones: the blank margins that scanners need around barcodes, of 10 modules
+// (the width of the narrowest bar) on each side of Code128 barcodes, 11 on
+// the left and 7 on the right of EAN-13 barcodes, 7 on each side of EAN-8
+// barcodes, 4 around QR codes, 1 around DataMatrix codes and 2 around PDF417
+// barcodes. The modules of QR and DataMatrix codes stay square; these codes
+// are centered in the rectangle. The human readable line of Code128 and EAN
+// barcodes takes 1.2 times the font size at the bottom of the rectangle.
+//
+// An error is set if the content cannot be encoded as the type of barcode,
+// or if the rectangle is empty.
+func Draw(pdf drawPdf, spec Spec) {
+	if !pdf.Ok() {
+		return
+	}
+	if spec.W <= 0 || spec.H <= 0 {
+		pdf.SetError(errors.New("barcode width and height must be positive"))
+		return
+	}
+	var bcode barcode.Barcode
+	var err error
+	// Quiet zones in modules: left, right, and top and bottom
+	var qLeft, qRight, qTop float64
+	linear := false
+	switch spec.Type {
+	case Code128:
+		bcode, err = code128.Encode(spec.Content)
+		qLeft, qRight, linear = 10, 10, true
+	case EAN:
+		bcode, err = ean.Encode(spec.Content)
+		qLeft, qRight, linear = 11, 7, true
+		if err == nil && bcode.Metadata().CodeKind == barcode.TypeEAN8 {
+			qLeft = 7
+		}
+	case QR:
+		bcode, err = qr.Encode(spec.Content, qr.M, qr.Auto)
+		qLeft, qRight, qTop = 4, 4, 4
+	case DataMatrix:
+		bcode, err = datamatrix.Encode(spec.Content)
+		qLeft, qRight, qTop = 1, 1, 1
+	case PDF417:
+		bcode = pdf417.Encode(spec.Content, 10, 2)
+		qLeft, qRight, qTop = 2, 2, 2
+	default:
+		err = fmt.Errorf("unknown type of barcode %d", spec.Type)
+	}
+	if err != nil {
+		pdf.SetError(err)
+		return
+	}
+	bounds := bcode.Bounds()
+	cols, rows := float64(bounds.Dx()), float64(bounds.Dy())
+	x, y := spec.X, spec.Y
+	mw := spec.W / (cols + qLeft + qRight)
+	var mh, textHeight float64
+	switch {
+	case linear:
+		if spec.HumanReadable {
+			_, fontSize := pdf.GetFontSize()
+			textHeight = fontSize * 1.2
+		}
+		mh = spec.H - textHeight
+		if mh <= 0 {
+			pdf.SetError(errors.New("barcode height leaves no room for its bars"))
+			return
+		}
+	case spec.Type == PDF417:
+		mh = spec.H / (rows + 2*qTop)
+	default:
+		mw = math.Min(mw, spec.H/(rows+2*qTop))
+		mh = mw
+		x += (spec.W - mw*(cols+qLeft+qRight)) / 2
+		y += (spec.H - mh*(rows+2*qTop)) / 2
+	}
+	x += qLeft * mw
+	y += qTop * mh
+	r, g, b := pdf.GetFillColor()
+	pdf.SetFillColor(0, 0, 0)
+	// Adjacent dark modules of a row are drawn as one rectangle
+	for row := bounds.Min.Y; row < bounds.Max.Y; row++ {
+		start := -1
+		for col := bounds.Min.X; col <= bounds.Max.X; col++ {
+			dark := col < bounds.Max.X && isDark(bcode.At(col, row))
+			if dark && start < 0 {
+				start = col
+			} else if !dark && start >= 0 {
+				pdf.Rect(x+float64(start-bounds.Min.X)*mw, y+float64(row-bounds.Min.Y)*mh,
+					float64(col-start)*mw, mh, "F")
+				start = -1
+			}
+		}
+	}
+	pdf.SetFillColor(r, g, b)
+	if textHeight > 0 {
+		content := bcode.Content()
+		pdf.Text(x+(cols*mw-pdf.GetStringWidth(content))/2, spec.Y+spec.H-textHeight*0.25, content)
+	}
+}
+
+// isDark returns true if a module of a barcode with color c is dark
+func isDark(c color.Color) bool {
+	return color.GrayModel.Convert(c).(color.Gray).Y < 128
+}
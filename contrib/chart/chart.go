@@ -0,0 +1,456 @@
+// Package chart draws bar, line, pie and scatter charts on the pages of PDF
+// documents. Charts are drawn as vector graphics, with axes, ticks, legends
+// and labels in the current font of the document, so that they print sharply
+// at any size and their text can be selected and searched.
+package chart
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/jacobfederer/gofpdf"
+)
+
+// Kind is the kind of a chart
+type Kind int
+
+const (
+	// Bar charts draw the values of each category as bars, side by side.
+	Bar Kind = iota
+	// Line charts join the values of each series with lines.
+	Line
+	// Pie charts draw the values of the first series as slices of a disc.
+	Pie
+	// Scatter charts draw each value of a series as a dot, at the position
+	// of its x and y values.
+	Scatter
+)
+
+// Series is a named series of values of a chart
+type Series struct {
+	Name   string    // name of the series in the legend
+	Values []float64 // values, one for each category, or y values of scatter charts
+	X      []float64 // x values of scatter charts
+}
+
+// Spec describes a chart
+type Spec struct {
+	Kind       Kind
+	Title      string   // title above the chart, if not empty
+	Categories []string // labels of the values of bar, line and pie charts
+	Series     []Series
+	XLabel     string           // title of the horizontal axis, if not empty
+	YLabel     string           // title of the vertical axis, if not empty
+	Colors     []gofpdf.RGBType // colors of the series, or of the slices of pie charts; DefaultColors if empty
+	FontSize   float64          // size of the labels in points; the current font size if 0
+	NoLegend   bool             // true to leave out the legend
+}
+
+// DefaultColors are the colors of the series of charts that do not specify
+// their own
+var DefaultColors = []gofpdf.RGBType{
+	{R: 78, G: 121, B: 167}, {R: 242, G: 142, B: 43}, {R: 225, G: 87, B: 89},
+	{R: 118, G: 183, B: 178}, {R: 89, G: 161, B: 79}, {R: 237, G: 201, B: 72},
+	{R: 176, G: 122, B: 161}, {R: 255, G: 157, B: 167}, {R: 156, G: 117, B: 95},
+	{R: 186, G: 176, B: 172},
+}
+
+// chartPdf is a partial PDF implementation that only implements a subset of
+// functions that are required to draw charts.
+type chartPdf interface {
+	ArcTo(x, y, rx, ry, degRotate, degStart, degEnd float64)
+	Circle(x, y, r float64, styleStr string)
+	ClosePath()
+	DrawPath(styleStr string)
+	GetConversionRatio() float64
+	GetDrawColor() (int, int, int)
+	GetFillColor() (int, int, int)
+	GetFontSize() (ptSize, unitSize float64)
+	GetLineWidth() float64
+	GetStringWidth(s string) float64
+	GetTextColor() (int, int, int)
+	Line(x1, y1, x2, y2 float64)
+	LineTo(x, y float64)
+	MoveTo(x, y float64)
+	Ok() bool
+	Rect(x, y, w, h float64, styleStr string)
+	SetDrawColor(r, g, b int)
+	SetError(err error)
+	SetFillColor(r, g, b int)
+	SetFontSize(size float64)
+	SetLineWidth(width float64)
+	SetTextColor(r, g, b int)
+	Text(x, y float64, txtStr string)
+	TransformBegin()
+	TransformEnd()
+	TransformRotate(angle, x, y float64)
+}
+
+// chart draws a chart
+type chart struct {
+	pdf  chartPdf
+	spec Spec
+	pt   float64 // size of a point in the unit of measure of the document
+	fs   float64 // font size in the unit of measure of the document
+}
+
+// Draw draws the chart spec in the rectangle of width w and height h whose
+// upper left corner is at (x, y), in the unit of measure of the document. The
+// labels are printed with the current font of the document, which must have
+// been set with SetFont(). The colors, line width and font size of the
+// document are restored afterwards.
+//
+// Bar and line charts have a value for each category in each of their
+// series, and scatter charts the same number of x and y values. Pie charts
+// draw the first series, which has no negative values, with a legend of the
+// categories. Charts with more than one series have a legend of the series.
+// An error is set if the chart cannot be drawn.
+func Draw(pdf chartPdf, spec Spec, x, y, w, h float64) {
+	if !pdf.Ok() {
+		return
+	}
+	if err := check(spec); err != nil {
+		pdf.SetError(err)
+		return
+	}
+	dr, dg, db := pdf.GetDrawColor()
+	fr, fg, fb := pdf.GetFillColor()
+	tr, tg, tb := pdf.GetTextColor()
+	lineWidth := pdf.GetLineWidth()
+	docSizePt, _ := pdf.GetFontSize()
+	defer func() {
+		pdf.SetDrawColor(dr, dg, db)
+		pdf.SetFillColor(fr, fg, fb)
+		pdf.SetTextColor(tr, tg, tb)
+		pdf.SetLineWidth(lineWidth)
+		pdf.SetFontSize(docSizePt)
+	}()
+	sizePt := docSizePt
+	if spec.FontSize > 0 {
+		sizePt = spec.FontSize
+	}
+	if len(spec.Colors) == 0 {
+		spec.Colors = DefaultColors
+	}
+	c := &chart{pdf: pdf, spec: spec, pt: 1 / pdf.GetConversionRatio()}
+	c.fs = sizePt * c.pt
+	pdf.SetFontSize(sizePt)
+	pdf.SetTextColor(tr, tg, tb)
+	if spec.Title != "" {
+		pdf.SetFontSize(sizePt * 1.25)
+		pdf.Text(x+(w-pdf.GetStringWidth(spec.Title))/2, y+c.fs*1.25, spec.Title)
+		pdf.SetFontSize(sizePt)
+		y += c.fs * 2.25
+		h -= c.fs * 2.25
+	}
+	var names []string
+	switch {
+	case spec.Kind == Pie:
+		names = spec.Categories
+	case len(spec.Series) > 1:
+		for _, s := range spec.Series {
+			names = append(names, s.Name)
+		}
+	}
+	if len(names) > 0 && !spec.NoLegend {
+		w -= c.legend(names, x+w, y)
+	}
+	if spec.Kind == Pie {
+		c.pie(x, y, w, h)
+	} else {
+		c.axes(x, y, w, h)
+	}
+}
+
+// check returns an error if the chart spec cannot be drawn
+func check(spec Spec) error {
+	if len(spec.Series) == 0 {
+		return errors.New("chart has no series")
+	}
+	for _, s := range spec.Series {
+		switch spec.Kind {
+		case Bar, Line, Pie:
+			if len(s.Values) != len(spec.Categories) {
+				return fmt.Errorf("series %q has %d values for %d categories", s.Name, len(s.Values), len(spec.Categories))
+			}
+		case Scatter:
+			if len(s.X) != len(s.Values) {
+				return fmt.Errorf("series %q has %d x values and %d y values", s.Name, len(s.X), len(s.Values))
+			}
+		default:
+			return fmt.Errorf("unknown kind of chart %d", spec.Kind)
+		}
+	}
+	if spec.Kind == Pie {
+		var sum float64
+		for _, v := range spec.Series[0].Values {
+			if v < 0 {
+				return errors.New("pie chart has negative values")
+			}
+			sum += v
+		}
+		if sum == 0 {
+			return errors.New("pie chart has no values")
+		}
+	}
+	return nil
+}
+
+// color returns the color of series or slice j
+func (c *chart) color(j int) gofpdf.RGBType {
+	return c.spec.Colors[j%len(c.spec.Colors)]
+}
+
+// legend draws the legend of names to the left of right, from top, and
+// returns its width
+func (c *chart) legend(names []string, right, top float64) float64 {
+	pdf := c.pdf
+	var width float64
+	for _, name := range names {
+		width = math.Max(width, pdf.GetStringWidth(name))
+	}
+	swatch := c.fs * 0.8
+	width += swatch + c.fs*1.5
+	x := right - width + c.fs
+	for j, name := range names {
+		y := top + c.fs*(0.5+1.5*float64(j))
+		clr := c.color(j)
+		pdf.SetFillColor(clr.R, clr.G, clr.B)
+		pdf.Rect(x, y, swatch, swatch, "F")
+		pdf.Text(x+swatch+c.fs*0.5, y+swatch*0.85, name)
+	}
+	return width
+}
+
+// pie draws a pie chart in the rectangle of width w and height h at (x, y)
+func (c *chart) pie(x, y, w, h float64) {
+	pdf := c.pdf
+	values := c.spec.Series[0].Values
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	// Room is left around the disc for the percentages
+	r := math.Min(w, h)/2 - c.fs*3
+	if r <= 0 {
+		return
+	}
+	cx, cy := x+w/2, y+h/2
+	pdf.SetDrawColor(255, 255, 255)
+	pdf.SetLineWidth(c.pt)
+	// Slices go clockwise from the top
+	angle := 90.0
+	for j, v := range values {
+		if v == 0 {
+			continue
+		}
+		sweep := v / sum * 360
+		clr := c.color(j)
+		pdf.SetFillColor(clr.R, clr.G, clr.B)
+		pdf.MoveTo(cx, cy)
+		pdf.ArcTo(cx, cy, r, r, 0, angle-sweep, angle)
+		pdf.ClosePath()
+		pdf.DrawPath("DF")
+		mid := (angle - sweep/2) * math.Pi / 180
+		label := strconv.FormatFloat(v/sum*100, 'f', 0, 64) + "%"
+		lw := pdf.GetStringWidth(label)
+		lx := cx + (r+c.fs*0.5)*math.Cos(mid)
+		ly := cy - (r+c.fs*0.5)*math.Sin(mid) + c.fs*0.35
+		if math.Cos(mid) < -0.1 {
+			lx -= lw
+		} else if math.Cos(mid) < 0.1 {
+			lx -= lw / 2
+		}
+		if math.Sin(mid) > 0.1 {
+			ly -= c.fs * 0.35
+		} else if math.Sin(mid) < -0.1 {
+			ly += c.fs * 0.35
+		}
+		pdf.Text(lx, ly, label)
+		angle -= sweep
+	}
+}
+
+// axes draws a bar, line or scatter chart with its axes in the rectangle of
+// width w and height h at (x, y)
+func (c *chart) axes(x, y, w, h float64) {
+	pdf := c.pdf
+	spec := c.spec
+	// The range of the values, and of the x values of scatter charts
+	yLo, yHi := math.Inf(1), math.Inf(-1)
+	xLo, xHi := math.Inf(1), math.Inf(-1)
+	for _, s := range spec.Series {
+		for j, v := range s.Values {
+			yLo, yHi = math.Min(yLo, v), math.Max(yHi, v)
+			if spec.Kind == Scatter {
+				xLo, xHi = math.Min(xLo, s.X[j]), math.Max(xHi, s.X[j])
+			}
+		}
+	}
+	if spec.Kind == Bar {
+		yLo, yHi = math.Min(yLo, 0), math.Max(yHi, 0)
+	}
+	yMin, yMax, yStep := ticks(yLo, yHi)
+	var yLabels []string
+	var labelW float64
+	for v := yMin; v <= yMax+yStep/2; v += yStep {
+		label := tickLabel(v, yStep)
+		yLabels = append(yLabels, label)
+		labelW = math.Max(labelW, pdf.GetStringWidth(label))
+	}
+	// The plot area
+	left := x + labelW + c.fs*0.5
+	if spec.YLabel != "" {
+		left += c.fs * 1.5
+	}
+	right := x + w - c.fs
+	top := y + c.fs*0.5
+	bottom := y + h - c.fs*1.5
+	if spec.XLabel != "" {
+		bottom -= c.fs * 1.5
+	}
+	if right <= left || bottom <= top {
+		return
+	}
+	yPos := func(v float64) float64 {
+		return bottom - (v-yMin)/(yMax-yMin)*(bottom-top)
+	}
+	// Grid lines and labels of the vertical axis
+	pdf.SetLineWidth(c.pt * 0.5)
+	pdf.SetDrawColor(210, 210, 210)
+	for j, label := range yLabels {
+		ty := yPos(yMin + float64(j)*yStep)
+		pdf.Line(left, ty, right, ty)
+		pdf.Text(left-c.fs*0.3-pdf.GetStringWidth(label), ty+c.fs*0.35, label)
+	}
+	if spec.YLabel != "" {
+		ly := (top+bottom)/2 + pdf.GetStringWidth(spec.YLabel)/2
+		pdf.TransformBegin()
+		pdf.TransformRotate(90, x+c.fs, ly)
+		pdf.Text(x+c.fs, ly, spec.YLabel)
+		pdf.TransformEnd()
+	}
+	if spec.XLabel != "" {
+		pdf.Text((left+right-pdf.GetStringWidth(spec.XLabel))/2, y+h-c.fs*0.3, spec.XLabel)
+	}
+	// Labels of the horizontal axis, and the position of each value
+	var xPos func(s Series, j int) float64
+	labelY := bottom + c.fs*1.2
+	if spec.Kind == Scatter {
+		xMin, xMax, xStep := ticks(xLo, xHi)
+		xPos = func(s Series, j int) float64 {
+			return left + (s.X[j]-xMin)/(xMax-xMin)*(right-left)
+		}
+		pdf.SetDrawColor(210, 210, 210)
+		for v := xMin; v <= xMax+xStep/2; v += xStep {
+			tx := left + (v-xMin)/(xMax-xMin)*(right-left)
+			pdf.Line(tx, top, tx, bottom)
+			label := tickLabel(v, xStep)
+			pdf.Text(tx-pdf.GetStringWidth(label)/2, labelY, label)
+		}
+	} else {
+		slot := (right - left) / float64(len(spec.Categories))
+		xPos = func(s Series, j int) float64 {
+			return left + slot*(float64(j)+0.5)
+		}
+		for j, label := range spec.Categories {
+			pdf.Text(left+slot*(float64(j)+0.5)-pdf.GetStringWidth(label)/2, labelY, label)
+		}
+	}
+	// The values
+	switch spec.Kind {
+	case Bar:
+		slot := (right - left) / float64(len(spec.Categories))
+		bw := slot * 0.8 / float64(len(spec.Series))
+		zero := yPos(math.Max(yMin, 0))
+		for k, s := range spec.Series {
+			clr := c.color(k)
+			pdf.SetFillColor(clr.R, clr.G, clr.B)
+			for j, v := range s.Values {
+				bx := left + slot*(float64(j)+0.1) + bw*float64(k)
+				vy := yPos(v)
+				pdf.Rect(bx, math.Min(zero, vy), bw, math.Abs(zero-vy), "F")
+			}
+		}
+	case Line:
+		pdf.SetLineWidth(c.pt * 1.5)
+		for k, s := range spec.Series {
+			clr := c.color(k)
+			pdf.SetDrawColor(clr.R, clr.G, clr.B)
+			pdf.SetFillColor(clr.R, clr.G, clr.B)
+			for j, v := range s.Values {
+				if j == 0 {
+					pdf.MoveTo(xPos(s, j), yPos(v))
+				} else {
+					pdf.LineTo(xPos(s, j), yPos(v))
+				}
+			}
+			pdf.DrawPath("D")
+			for j, v := range s.Values {
+				pdf.Circle(xPos(s, j), yPos(v), c.pt*2, "F")
+			}
+		}
+	case Scatter:
+		for k, s := range spec.Series {
+			clr := c.color(k)
+			pdf.SetFillColor(clr.R, clr.G, clr.B)
+			for j, v := range s.Values {
+				pdf.Circle(xPos(s, j), yPos(v), c.pt*2.5, "F")
+			}
+		}
+	}
+	// The axes
+	pdf.SetLineWidth(c.pt * 0.75)
+	pdf.SetDrawColor(0, 0, 0)
+	pdf.Line(left, top, left, bottom)
+	pdf.Line(left, bottom, right, bottom)
+	if yMin < 0 && yMax > 0 {
+		pdf.Line(left, yPos(0), right, yPos(0))
+	}
+}
+
+// ticks returns the first and last tick and the step between the ticks of
+// an axis for the values from lo to hi, at round numbers
+func ticks(lo, hi float64) (min, max, step float64) {
+	if hi == lo {
+		if lo == 0 {
+			hi = 1
+		} else {
+			lo, hi = lo-math.Abs(lo)/2, hi+math.Abs(hi)/2
+		}
+	}
+	step = niceNumber((hi-lo)/4, true)
+	return math.Floor(lo/step) * step, math.Ceil(hi/step) * step, step
+}
+
+// niceNumber returns a number of the form 1, 2 or 5 times a power of ten
+// close to v, rounded or not below v
+func niceNumber(v float64, round bool) float64 {
+	exp := math.Floor(math.Log10(v))
+	fraction := v / math.Pow(10, exp)
+	var nice float64
+	switch {
+	case round && fraction < 1.5, !round && fraction <= 1:
+		nice = 1
+	case round && fraction < 3, !round && fraction <= 2:
+		nice = 2
+	case round && fraction < 7, !round && fraction <= 5:
+		nice = 5
+	default:
+		nice = 10
+	}
+	return nice * math.Pow(10, exp)
+}
+
+// tickLabel returns the label of the tick v of an axis with ticks every step
+func tickLabel(v, step float64) string {
+	decimals := int(math.Max(0, -math.Floor(math.Log10(step)+1e-9)))
+	label := strconv.FormatFloat(v, 'f', decimals, 64)
+	if f, _ := strconv.ParseFloat(label, 64); f == 0 {
+		// Avoid negative zero
+		return strconv.FormatFloat(0, 'f', decimals, 64)
+	}
+	return label
+}
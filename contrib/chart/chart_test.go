@@ -0,0 +1,117 @@
+package chart_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/jacobfederer/gofpdf"
+	"github.com/jacobfederer/gofpdf/contrib/chart"
+	"github.com/jacobfederer/gofpdf/internal/example"
+)
+
+func createPdf() (pdf *gofpdf.Fpdf) {
+	pdf = gofpdf.New("L", "mm", "A4", "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.AddPage()
+	return
+}
+
+func ExampleDraw() {
+	pdf := createPdf()
+	months := []string{"Jan", "Feb", "Mar", "Apr", "May", "Jun"}
+	chart.Draw(pdf, chart.Spec{
+		Kind:       chart.Bar,
+		Title:      "Sales",
+		Categories: months,
+		Series: []chart.Series{
+			{Name: "North", Values: []float64{12, 15, 9, 18, 21, 17}},
+			{Name: "South", Values: []float64{8, 11, 14, 10, 13, 19}},
+		},
+		YLabel: "Units (thousands)",
+	}, 10, 10, 135, 90)
+	chart.Draw(pdf, chart.Spec{
+		Kind:       chart.Line,
+		Title:      "Temperature",
+		Categories: months,
+		Series: []chart.Series{
+			{Name: "2023", Values: []float64{-2.5, 0.5, 5, 10.5, 15, 19}},
+			{Name: "2024", Values: []float64{-1, 1.5, 4, 11, 16.5, 20}},
+		},
+		XLabel: "Month",
+		YLabel: "Celsius",
+	}, 150, 10, 135, 90)
+	chart.Draw(pdf, chart.Spec{
+		Kind:       chart.Pie,
+		Title:      "Market share",
+		Categories: []string{"Alpha", "Beta", "Gamma", "Other"},
+		Series:     []chart.Series{{Values: []float64{45, 25, 20, 10}}},
+	}, 10, 110, 135, 90)
+	chart.Draw(pdf, chart.Spec{
+		Kind:  chart.Scatter,
+		Title: "Height and weight",
+		Series: []chart.Series{
+			{Name: "Sample", X: []float64{160, 165, 170, 175, 180, 185, 172},
+				Values: []float64{55, 62, 66, 74, 79, 88, 70}},
+		},
+		XLabel: "Height (cm)",
+		YLabel: "Weight (kg)",
+	}, 150, 110, 135, 90)
+
+	fileStr := example.Filename("contrib_chart_Draw")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated ../../pdf/contrib_chart_Draw.pdf
+}
+
+func TestDraw(t *testing.T) {
+	pdf := createPdf()
+	pdf.SetDrawColor(1, 2, 3)
+	pdf.SetFillColor(4, 5, 6)
+	pdf.SetTextColor(7, 8, 9)
+	pdf.SetLineWidth(0.5)
+	chart.Draw(pdf, chart.Spec{
+		Kind:       chart.Bar,
+		Categories: []string{"A", "B"},
+		Series:     []chart.Series{{Values: []float64{-3, 7}}},
+		FontSize:   8,
+	}, 10, 10, 100, 60)
+	if r, g, b := pdf.GetDrawColor(); r != 1 || g != 2 || b != 3 {
+		t.Fatalf("draw color not restored: %d %d %d", r, g, b)
+	}
+	if r, g, b := pdf.GetFillColor(); r != 4 || g != 5 || b != 6 {
+		t.Fatalf("fill color not restored: %d %d %d", r, g, b)
+	}
+	if r, g, b := pdf.GetTextColor(); r != 7 || g != 8 || b != 9 {
+		t.Fatalf("text color not restored: %d %d %d", r, g, b)
+	}
+	if pdf.GetLineWidth() != 0.5 {
+		t.Fatalf("line width not restored: %.2f", pdf.GetLineWidth())
+	}
+	if pt, _ := pdf.GetFontSize(); pt != 10 {
+		t.Fatalf("font size not restored: %.2f", pt)
+	}
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"(-4) Tj", "(8) Tj", "(A) Tj", "(B) Tj"} {
+		if !bytes.Contains(buf.Bytes(), []byte(s)) {
+			t.Fatalf("%s not found in chart", s)
+		}
+	}
+
+	for _, spec := range []chart.Spec{
+		{Kind: chart.Bar},
+		{Kind: chart.Line, Categories: []string{"A"}, Series: []chart.Series{{Values: []float64{1, 2}}}},
+		{Kind: chart.Scatter, Series: []chart.Series{{X: []float64{1}, Values: []float64{1, 2}}}},
+		{Kind: chart.Pie, Categories: []string{"A", "B"}, Series: []chart.Series{{Values: []float64{1, -2}}}},
+		{Kind: chart.Pie, Categories: []string{"A"}, Series: []chart.Series{{Values: []float64{0}}}},
+	} {
+		pdf = createPdf()
+		chart.Draw(pdf, spec, 10, 10, 100, 60)
+		if pdf.Ok() {
+			t.Fatalf("no error for chart %+v", spec)
+		}
+	}
+}
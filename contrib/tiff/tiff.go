@@ -19,15 +19,13 @@
 package tiff
 
 import (
-	"bytes"
 	"fmt"
-	"image"
-	"image/png"
 	"io"
 	"os"
 
 	"github.com/jacobfederer/gofpdf"
-	"golang.org/x/image/tiff"
+	// The TIFF decoder reads the images that gofpdf does not embed as they are
+	_ "golang.org/x/image/tiff"
 )
 
 // RegisterReader registers a TIFF image, adding it to the PDF file but not
@@ -35,26 +33,15 @@ import (
 // call to Image() that actually places the image in the document. options
 // specifies various image properties; in this case, the ImageType property
 // should be set to "tiff". The TIFF image is a reader from the reader
-// specified by r.
+// specified by r. Bilevel images compressed with CCITT Group 3 or Group 4
+// compression in a single strip, such as scanned documents, are embedded
+// without being decoded; other images are decoded and embedded losslessly.
 func RegisterReader(fpdf *gofpdf.Fpdf, imgName string, options gofpdf.ImageOptions, r io.Reader) (info *gofpdf.ImageInfoType) {
-	var err error
-	var img image.Image
-	var buf bytes.Buffer
 	if fpdf.Ok() {
 		if options.ImageType == "tiff" || options.ImageType == "tif" {
-			img, err = tiff.Decode(r)
-			if err == nil {
-				err = png.Encode(&buf, img)
-				if err == nil {
-					options.ImageType = "png"
-					info = fpdf.RegisterImageOptionsReader(imgName, options, &buf)
-				}
-			}
+			info = fpdf.RegisterImageOptionsReader(imgName, options, r)
 		} else {
-			err = fmt.Errorf("expecting \"tiff\" or \"tif\" as image type, got \"%s\"", options.ImageType)
-		}
-		if err != nil {
-			fpdf.SetError(err)
+			fpdf.SetError(fmt.Errorf("expecting \"tiff\" or \"tif\" as image type, got \"%s\"", options.ImageType))
 		}
 	}
 	return
@@ -0,0 +1,55 @@
+// Package webp allows WebP images, lossy and lossless, to be used in
+// documents generated with gofpdf.
+package webp
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jacobfederer/gofpdf"
+	"golang.org/x/image/webp"
+)
+
+// RegisterReader registers a WebP image, adding it to the PDF file but not
+// adding it to the page. imgName specifies the name that will be used in the
+// call to Image() that actually places the image in the document. options
+// specifies various image properties; in this case, the ImageType property
+// should be set to "webp". The WebP image is read from the reader specified by
+// r. Lossy images are embedded as JPEG images and lossless images as PNG
+// images, as described for RegisterImageFromImage().
+func RegisterReader(fpdf *gofpdf.Fpdf, imgName string, options gofpdf.ImageOptions, r io.Reader) (info *gofpdf.ImageInfoType) {
+	if !fpdf.Ok() {
+		return
+	}
+	if options.ImageType != "webp" {
+		fpdf.SetError(fmt.Errorf("expecting \"webp\" as image type, got \"%s\"", options.ImageType))
+		return
+	}
+	img, err := webp.Decode(r)
+	if err != nil {
+		fpdf.SetError(err)
+		return
+	}
+	options.ImageType = ""
+	return fpdf.RegisterImageFromImage(imgName, img, options)
+}
+
+// RegisterFile registers a WebP image, adding it to the PDF file but not
+// adding it to the page. imgName specifies the name that will be used in the
+// call to Image() that actually places the image in the document. options
+// specifies various image properties; in this case, the ImageType property
+// should be set to "webp". The WebP image is read from the file specified by
+// webpFileStr.
+func RegisterFile(fpdf *gofpdf.Fpdf, imgName string, options gofpdf.ImageOptions, webpFileStr string) (info *gofpdf.ImageInfoType) {
+	if !fpdf.Ok() {
+		return
+	}
+	f, err := os.Open(webpFileStr)
+	if err != nil {
+		fpdf.SetError(err)
+		return
+	}
+	defer f.Close()
+	return RegisterReader(fpdf, imgName, options, f)
+}
@@ -0,0 +1,298 @@
+package gofpdf
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// counterType is an alias that is replaced with a number of pages
+type counterType struct {
+	alias   string
+	section bool           // count the pages of the section of the page
+	style   PageLabelStyle // numbering style
+	digits  int            // minimum number of digits of decimal numbers
+}
+
+// aliasShiftType is text with aliases that is aligned by its end or center,
+// which is moved when its aliases are replaced
+type aliasShiftType struct {
+	text   string            // text as it is printed, with its aliases
+	factor float64           // part of the difference of widths the text is moved by
+	gs     graphicsStateType // font and spacing of the text
+}
+
+// aliasShiftRe matches the placeholders of the shifts of aligned text
+var aliasShiftRe = regexp.MustCompile(`\{gofpdf:shift:(\d+)\}`)
+
+// AliasCounter defines an alias for a number of pages that is substituted as
+// the document is closed, like AliasNbPages(). If resetOnSection is false,
+// the alias is replaced with the total number of pages of the document;
+// otherwise it is replaced with the number of pages of the section of the
+// page it is printed on, so that footers such as "Page 3 of 10 in Section B"
+// can be printed with SectionPageNo() or the page context passed to
+// SetFooterFuncEx(). The alias must not contain the alias of another counter.
+//
+// A section begins when SetSection() sets a new title, and consists of the
+// pages added until another section begins; the pages of page builders
+// belong to the section in which they are added to the document. The pages of
+// documents written to an output writer cannot hold counters, since their
+// values are not known when the pages are flushed.
+//
+// Text with aliases that is aligned right or centered by CellFormat() and the
+// functions based on it is moved when the aliases are replaced, so that the
+// numbers are aligned as the text would be, whatever the widths of their
+// digits in proportional fonts.
+//
+// The AliasCounter example demonstrates this method.
+func (f *Fpdf) AliasCounter(aliasStr string, resetOnSection bool) {
+	f.AliasCounterFormat(aliasStr, resetOnSection, PageLabelDecimal, 0)
+}
+
+// AliasCounterFormat defines an alias for a number of pages like
+// AliasCounter(), formatted in the numbering style specified by style, such as
+// PageLabelRomanLower. Decimal numbers have at least the number of digits
+// specified by digits, padded with leading zeros. Defining the alias of a
+// counter again replaces the counter.
+//
+// An error occurs if aliasStr is empty, if style is PageLabelNone or not a
+// valid style, or if digits is negative.
+//
+// The AliasCounter example demonstrates this method.
+func (f *Fpdf) AliasCounterFormat(aliasStr string, resetOnSection bool, style PageLabelStyle, digits int) {
+	if f.err != nil {
+		return
+	}
+	switch {
+	case aliasStr == "":
+		f.err = fmt.Errorf("alias of counter must not be empty")
+	case style == PageLabelNone || formatCounter(1, style, 0) == "":
+		f.err = fmt.Errorf("invalid counter style %q", style)
+	case digits < 0:
+		f.err = fmt.Errorf("invalid number of digits %d of counter", digits)
+	}
+	if f.err != nil {
+		return
+	}
+	c := counterType{alias: aliasStr, section: resetOnSection, style: style, digits: digits}
+	for j := range f.counters {
+		if f.counters[j].alias == aliasStr {
+			f.counters[j] = c
+			return
+		}
+	}
+	f.counters = append(f.counters, c)
+}
+
+// SectionPageNo returns the one-based number of the current page in its
+// section, as set with SetSection(), or 0 if no page has been added.
+//
+// The AliasCounter example demonstrates this method.
+func (f *Fpdf) SectionPageNo() (n int) {
+	if f.page == 0 {
+		return
+	}
+	section := f.pageSections[f.page]
+	for p := 1; p <= f.page; p++ {
+		if f.pageSections[p] == section {
+			n++
+		}
+	}
+	return
+}
+
+// sectionPageCount returns the number of pages of the section of page
+func (f *Fpdf) sectionPageCount(page int) (n int) {
+	section := f.pageSections[page]
+	for p := 1; p < len(f.pages); p++ {
+		if f.pageSections[p] == section {
+			n++
+		}
+	}
+	return
+}
+
+// counterValue returns the replacement of the alias of c on page
+func (f *Fpdf) counterValue(c *counterType, page int) string {
+	n := len(f.pages) - 1
+	if c.section {
+		n = f.sectionPageCount(page)
+	}
+	return formatCounter(n, c.style, c.digits)
+}
+
+// formatCounter returns n in the numbering style, or "" if style is not a
+// valid style
+func formatCounter(n int, style PageLabelStyle, digits int) string {
+	switch style {
+	case PageLabelDecimal:
+		return fmt.Sprintf("%0*d", digits, n)
+	case PageLabelRomanUpper:
+		return strings.ToUpper(romanNumeral(n))
+	case PageLabelRomanLower:
+		return romanNumeral(n)
+	case PageLabelLettersUpper, PageLabelLettersLower:
+		if n < 1 {
+			return strconv.Itoa(n)
+		}
+		letter := string(rune('A' + (n-1)%26))
+		if style == PageLabelLettersLower {
+			letter = strings.ToLower(letter)
+		}
+		return strings.Repeat(letter, (n-1)/26+1)
+	}
+	return ""
+}
+
+// romanNumeral returns n, which is positive, in lowercase roman numerals
+func romanNumeral(n int) string {
+	if n < 1 {
+		return strconv.Itoa(n)
+	}
+	values := []int{1000, 900, 500, 400, 100, 90, 50, 40, 10, 9, 5, 4, 1}
+	numerals := []string{"m", "cm", "d", "cd", "c", "xc", "l", "xl", "x", "ix", "v", "iv", "i"}
+	var s strings.Builder
+	for j, value := range values {
+		for n >= value {
+			s.WriteString(numerals[j])
+			n -= value
+		}
+	}
+	return s.String()
+}
+
+// replacePageCounters replaces the aliases of the counters on page n
+func (f *Fpdf) replacePageCounters(n int) {
+	for j := range f.counters {
+		c := &f.counters[j]
+		replacement := f.counterValue(c, n)
+		replaced := false
+		for mode := 0; mode < 2; mode++ {
+			alias, repl := c.alias, replacement
+			if mode == 1 {
+				alias, repl = utf8toutf16(alias, false), utf8toutf16(repl, false)
+			}
+			s := f.pages[n].String()
+			if strings.Contains(s, alias) {
+				f.pages[n].Reset()
+				f.pages[n].WriteString(strings.Replace(s, alias, repl, -1))
+				replaced = true
+			}
+		}
+		if replaced {
+			// The glyphs of the replacement are part of the font subsets
+			for _, font := range f.fonts {
+				if font.usedRunes != nil {
+					for _, r := range replacement {
+						font.usedRunes[int(r)] = int(r)
+					}
+				}
+			}
+		}
+	}
+}
+
+// pageCounterFlushed returns an error if page n, which is flushed, holds the
+// alias of a counter
+func (f *Fpdf) pageCounterFlushed(n int) error {
+	s := f.pages[n].String()
+	for _, c := range f.counters {
+		if strings.Contains(s, c.alias) || strings.Contains(s, utf8toutf16(c.alias, false)) {
+			return fmt.Errorf("counter %s is not known when page %d is flushed", c.alias, n)
+		}
+	}
+	return nil
+}
+
+// hasAlias reports whether txtStr holds an alias that is replaced as the
+// document is closed
+func (f *Fpdf) hasAlias(txtStr string) bool {
+	if f.aliasNbPagesStr != "" && strings.Contains(txtStr, f.aliasNbPagesStr) {
+		return true
+	}
+	for _, c := range f.counters {
+		if strings.Contains(txtStr, c.alias) {
+			return true
+		}
+	}
+	for alias := range f.aliasMap {
+		if strings.Contains(txtStr, alias) {
+			return true
+		}
+	}
+	return false
+}
+
+// aliasShiftBegin returns the operators that begin the shift of the text
+// txtStr, aligned as specified by alignStr, by the difference between its
+// width and the width of the text with its aliases replaced, or an empty
+// string if the text is not moved
+func (f *Fpdf) aliasShiftBegin(txtStr, alignStr string) string {
+	var factor float64
+	switch {
+	case strings.Contains(alignStr, "R"):
+		factor = 1
+	case strings.Contains(alignStr, "C"):
+		factor = 0.5
+	default:
+		return ""
+	}
+	if !f.hasAlias(txtStr) {
+		return ""
+	}
+	f.aliasShifts = append(f.aliasShifts, aliasShiftType{text: txtStr, factor: factor, gs: f.graphicsState()})
+	return sprintf("q 1 0 0 1 {gofpdf:shift:%d} 0 cm ", len(f.aliasShifts)-1)
+}
+
+// resolvePageShifts replaces the placeholders of the shifts of aligned text
+// on page n, whose aliases have been replaced
+func (f *Fpdf) resolvePageShifts(n int) {
+	if len(f.aliasShifts) == 0 {
+		return
+	}
+	s := f.pages[n].String()
+	if !strings.Contains(s, "{gofpdf:shift:") {
+		return
+	}
+	gs := f.graphicsState()
+	s = aliasShiftRe.ReplaceAllStringFunc(s, func(token string) string {
+		j, _ := strconv.Atoi(aliasShiftRe.FindStringSubmatch(token)[1])
+		shift := &f.aliasShifts[j]
+		f.setGraphicsState(&shift.gs)
+		wd := f.GetStringWidth(shift.text) - f.GetStringWidth(f.replacedText(shift.text, n))
+		return sprintf("%.2f", wd*shift.factor*f.k)
+	})
+	f.setGraphicsState(&gs)
+	f.pages[n].Reset()
+	f.pages[n].WriteString(s)
+}
+
+// replacedText returns txtStr with the aliases on page n replaced
+func (f *Fpdf) replacedText(txtStr string, n int) string {
+	for j := range f.counters {
+		txtStr = strings.Replace(txtStr, f.counters[j].alias, f.counterValue(&f.counters[j], n), -1)
+	}
+	for alias, replacement := range f.aliasMap {
+		txtStr = strings.Replace(txtStr, alias, replacement, -1)
+	}
+	return txtStr
+}
+
+// mergeAliasShifts adds the shifts of aligned text of the page builder b to
+// the document and renumbers their placeholders on the pages of b
+func (f *Fpdf) mergeAliasShifts(b *PageBuilder) {
+	if len(b.aliasShifts) == 0 {
+		return
+	}
+	base := len(f.aliasShifts)
+	f.aliasShifts = append(f.aliasShifts, b.aliasShifts...)
+	for n := 1; n <= b.page; n++ {
+		s := aliasShiftRe.ReplaceAllStringFunc(b.pages[n].String(), func(token string) string {
+			j, _ := strconv.Atoi(aliasShiftRe.FindStringSubmatch(token)[1])
+			return sprintf("{gofpdf:shift:%d}", base+j)
+		})
+		b.pages[n].Reset()
+		b.pages[n].WriteString(s)
+	}
+}
@@ -41,6 +41,36 @@ type gradientType struct {
 	clr1Str, clr2Str  string
 	x1, y1, x2, y2, r float64
 	objNum            int
+	stops             []gradientStopType // colors of gradients with more than two
+	r0                float64            // radius of the start circle of radial gradients
+	space             string             // color space, DeviceRGB if empty
+	extend            string             // extension flags, "true true" if empty
+	pattern           bool               // true if the gradient is used as a color
+	patternObjNum     int
+}
+
+// Kinds of nestType
+const (
+	nestClip = iota
+	nestTransform
+	nestGState
+)
+
+// nestNames are the names of the kinds of nestType, for errors
+var nestNames = [...]string{"clipping operation", "transformation", "saved graphics state"}
+
+// nestType is an active clipping operation, transformation context or saved
+// graphics state
+type nestType struct {
+	kind  int                // nestClip, nestTransform or nestGState
+	page  int                // page on which it began
+	saved *graphicsStateType // settings restored by RestoreGState()
+}
+
+// gradientStopType is a color of a gradient and its position, between 0 and 1
+type gradientStopType struct {
+	offset float64
+	clrStr string
 }
 
 const (
@@ -122,13 +152,16 @@ const (
 	colorModeRGB colorMode = iota
 	colorModeSpot
 	colorModeCMYK
+	colorModePattern
+	colorModeICC
 )
 
 type colorType struct {
 	r, g, b    float64
 	ir, ig, ib int
 	mode       colorMode
-	spotStr    string // name of current spot color
+	spotStr    string        // name of current spot color
+	cmyk       cmykColorType // ink percentages of CMYK colors
 	gray       bool
 	str        string
 }
@@ -165,20 +198,27 @@ func (p PointType) XY() (float64, float64) {
 // Changes to this structure should be reflected in its GobEncode and GobDecode
 // methods.
 type ImageInfoType struct {
-	data  []byte  // Raw image data
-	smask []byte  // Soft Mask, an 8bit per-pixel transparency mask
-	n     int     // Image object number
-	w     float64 // Width
-	h     float64 // Height
-	cs    string  // Color space
-	pal   []byte  // Image color palette
-	bpc   int     // Bits Per Component
-	f     string  // Image filter
-	dp    string  // DecodeParms
-	trns  []int   // Transparency mask
-	scale float64 // Document scale factor
-	dpi   float64 // Dots-per-inch found from image file (png only)
-	i     string  // SHA-1 checksum of the above values.
+	data  []byte         // Raw image data
+	smask []byte         // Soft Mask, an 8bit per-pixel transparency mask
+	n     int            // Image object number
+	w     float64        // Width
+	h     float64        // Height
+	cs    string         // Color space
+	pal   []byte         // Image color palette
+	bpc   int            // Bits Per Component
+	f     string         // Image filter
+	dp    string         // DecodeParms
+	trns  []int          // Transparency mask
+	scale float64        // Document scale factor
+	dpi   float64        // Dots-per-inch found from image file (png only)
+	icc   []byte         // Embedded ICC color profile
+	mask  *ImageInfoType // Gray mask image, a stencil mask if it has 1 bit per component
+	cmask []int          // Color key mask, ranges of colors that are masked out
+	i     string         // SHA-1 checksum of the above values.
+	lazy  *lazyImageData // Location of data that is read when needed
+	// EXIF orientation of JPEG images, from 2 to 8 if the image is not
+	// stored upright
+	orientation int
 }
 
 func generateImageID(info *ImageInfoType) (string, error) {
@@ -188,8 +228,23 @@ func generateImageID(info *ImageInfoType) (string, error) {
 
 // GobEncode encodes the receiving image to a byte slice.
 func (info *ImageInfoType) GobEncode() (buf []byte, err error) {
+	if info.lazy != nil && !info.lazy.dataLoaded {
+		// Data that is read for the encoding is released again
+		defer info.unloadImage()
+	}
+	if err = info.loadImage(); err != nil {
+		return
+	}
+	// The mask image is encoded as a nested image
+	var mask []byte
+	if info.mask != nil {
+		if mask, err = info.mask.GobEncode(); err != nil {
+			return
+		}
+	}
 	fields := []interface{}{info.data, info.smask, info.n, info.w, info.h, info.cs,
-		info.pal, info.bpc, info.f, info.dp, info.trns, info.scale, info.dpi}
+		info.pal, info.bpc, info.f, info.dp, info.trns, info.scale, info.dpi, info.icc,
+		info.cmask, mask}
 	w := new(bytes.Buffer)
 	encoder := gob.NewEncoder(w)
 	for j := 0; j < len(fields) && err == nil; j++ {
@@ -204,13 +259,21 @@ func (info *ImageInfoType) GobEncode() (buf []byte, err error) {
 // GobDecode decodes the specified byte buffer (generated by GobEncode) into
 // the receiving image.
 func (info *ImageInfoType) GobDecode(buf []byte) (err error) {
+	var mask []byte
 	fields := []interface{}{&info.data, &info.smask, &info.n, &info.w, &info.h,
-		&info.cs, &info.pal, &info.bpc, &info.f, &info.dp, &info.trns, &info.scale, &info.dpi}
+		&info.cs, &info.pal, &info.bpc, &info.f, &info.dp, &info.trns, &info.scale, &info.dpi, &info.icc,
+		&info.cmask, &mask}
 	r := bytes.NewBuffer(buf)
 	decoder := gob.NewDecoder(r)
 	for j := 0; j < len(fields) && err == nil; j++ {
 		err = decoder.Decode(fields[j])
 	}
+	if len(mask) > 0 {
+		info.mask = new(ImageInfoType)
+		if err = info.mask.GobDecode(mask); err != nil {
+			return
+		}
+	}
 
 	info.i, err = generateImageID(info)
 	return
@@ -243,14 +306,20 @@ func (info *ImageInfoType) Extent() (wd, ht float64) {
 	return info.Width(), info.Height()
 }
 
-// Width returns the width of the image in the units of the Fpdf object.
+// Width returns the width of the image in the units of the Fpdf object. The
+// width of a JPEG image that its EXIF orientation rotates by a quarter turn
+// is its stored height.
 func (info *ImageInfoType) Width() float64 {
-	return info.w / (info.scale * info.dpi / 72)
+	w, _ := info.uprightSize()
+	return w / (info.scale * info.dpi / 72)
 }
 
 // Height returns the height of the image in the units of the Fpdf object.
+// The height of a JPEG image that its EXIF orientation rotates by a quarter
+// turn is its stored width.
 func (info *ImageInfoType) Height() float64 {
-	return info.h / (info.scale * info.dpi / 72)
+	_, h := info.uprightSize()
+	return h / (info.scale * info.dpi / 72)
 }
 
 // SetDpi sets the dots per inch for an image. PNG images MAY have their dpi
@@ -273,6 +342,8 @@ type linkType struct {
 	x, y, wd, ht float64
 	link         int    // Auto-generated internal link ID or...
 	linkStr      string // ...application-provided external link string
+	dest         string // named destination
+	fileStr      string // file opened by the link
 }
 
 type intLinkType struct {
@@ -286,6 +357,7 @@ type outlineType struct {
 	level, parent, first, last, next, prev int
 	y                                      float64
 	p                                      int
+	options                                *BookmarkOptions // set by BookmarkEx()
 }
 
 // InitType is used with NewCustom() to customize an Fpdf instance.
@@ -501,19 +573,36 @@ type PageBox struct {
 type Fpdf struct {
 	isCurrentUTF8    bool                       // is current font used in utf-8 mode
 	isRTL            bool                       // is is right to left mode enabled
+	textDirection    int                        // base direction of bidirectional text
+	shaper           Shaper                     // text shaping engine used by WriteShaped()
+	fontFallbacks    map[string][]string        // fallback font families of each family
+	kerning          bool                       // kerning of UTF-8 fonts enabled
+	hyphenator       Hyphenator                 // hyphenator of words at line breaks
 	page             int                        // current page number
 	n                int                        // current object number
 	offsets          []int                      // array of object offsets
 	templates        map[string]Template        // templates used in this document
 	templateObjects  map[string]int             // template object IDs within this document
+	resourceObjs     map[string]int             // object numbers of the images, image ICC profiles and fonts written, by content hash
 	importedObjs     map[string][]byte          // imported template objects (gofpdi)
 	importedObjPos   map[string]map[int]string  // imported template objects hashes and their positions (gofpdi)
 	importedTplObjs  map[string]string          // imported template names and IDs (hashed) (gofpdi)
 	importedTplIDs   map[string]int             // imported template ids hash to object id int (gofpdi)
+	importedPages    []importedPageType         // pages imported with ImportPage(); id is index+1
+	importedPageIDs  map[string]int             // ids of the imported pages by object hash
+	importedAnnots   map[int][]string           // hashes of the annotations of appended pages by page
+	importedAnnotPos map[int]string             // positions of the hashes of imported annotations in the output
+	pageMarkups      map[int][]markupAnnotType  // text annotations and text markup annotations by page
+	markupObjs       map[int][]int              // object numbers of the markup annotations and their popups by page
 	buffer           fmtBuffer                  // buffer holding in-memory PDF
+	outStream        *outputStreamType          // output writer of pages written as they are finished
+	xrefStream       bool                       // cross-reference stream and object streams for version 1.5 or later
 	pages            []*bytes.Buffer            // slice[page] of page content; 1-based
+	pageBase         int                        // object number that precedes those of the pages
 	state            int                        // current document state
 	compress         bool                       // compression flag
+	compressLevel    int                        // zlib compression level
+	streamFilter     StreamFilter               // encoder of streams in place of zlib, if any
 	k                float64                    // scale factor (number of points in user unit)
 	defOrientation   string                     // default orientation
 	curOrientation   string                     // current orientation
@@ -522,7 +611,16 @@ type Fpdf struct {
 	defPageBoxes     map[string]PageBox         // default page size
 	curPageSize      SizeType                   // current page size
 	pageSizes        map[int]SizeType           // used for pages with non default sizes or orientations
+	pageFormat       pageFormatType             // orientation and size of pages added with AddPage()
+	pageRotations    map[int]int                // rotations of pages in degrees clockwise
+	autoPageHeights  map[int]float64            // heights in points of the content of pages added with AddPageAuto()
+	insertAt         int                        // position of the page added by InsertPageAfter()
+	insertedPage     int                        // page being written if it is not the last page
+	revisit          revisitType                // settings of the page being written while an earlier page is written
 	pageBoxes        map[int]map[string]PageBox // used to define the crop, trim, bleed and art boxes
+	defBoxes         PageBoxes                  // default page boxes relative to the upper left corner
+	watermarks       []watermarkType            // watermarks and stamps applied to each page
+	backgrounds      []backgroundType           // templates drawn under the content of pages
 	unitStr          string                     // unit of measure for all rendered objects except fonts
 	wPt, hPt         float64                    // dimensions of current page in points
 	w, h             float64                    // dimensions of current page in user unit
@@ -548,12 +646,33 @@ type Fpdf struct {
 	fontSizePt       float64                    // current font size in points
 	fontSize         float64                    // current font size in user unit
 	ws               float64                    // word spacing
+	charSpacing      float64                    // character spacing set with SetCharSpacing()
+	wordSpacing      float64                    // word spacing set with SetWordSpacing()
+	textRise         float64                    // text rise in points set with SetTextRise()
+	smallCaps        bool                       // lower case letters are printed as small capitals
+	tabStops         []TabStop                  // tab stops set with SetTabStops()
+	block            *blockType                 // block kept together on a page
+	keepTogether     bool                       // text is kept together on a page
+	columns          columnsType                // columns set with SetColumns()
+	footnotes        footnotesType              // footnotes added with AddFootnote()
+	tocEntries       []tocEntryType             // entries of the table of contents
+	pageClosed       bool                       // the footer of the current page has been printed
+	namedDests       map[string]intLinkType     // named destinations
+	pageLabels       map[int]pageLabelType      // page labels by start page
+	articles         []articleType              // article threads
+	article          int                        // index of the current article thread
+	structTree       structTreeType             // structure tree of a tagged document
+	lang             string                     // natural language of the document
+	artifacts        int                        // number of artifacts begun with BeginArtifact()
 	images           map[string]*ImageInfoType  // array of used images
+	imagesUsed       map[string]bool            // hashes of the images that are placed, which are embedded
+	imagesInline     map[string]bool            // hashes of the images that are placed inline in content streams
 	aliasMap         map[string]string          // map of alias->replacement
 	pageLinks        [][]linkType               // pageLinks[page][link], both 1-based
 	links            []intLinkType              // array of internal links
 	attachments      []Attachment               // slice of content to embed globally
 	pageAttachments  [][]annotationAttach       // 1-based array of annotation for file attachments (per page)
+	attachReaders    map[io.Reader]bool         // content readers of attachments that have been embedded
 	outlines         []outlineType              // array of outlines
 	outlineRoot      int                        // root of outlines
 	autoPageBreak    bool                       // automatic page breaking
@@ -568,7 +687,11 @@ type Fpdf struct {
 	zoomMode         string                     // zoom display mode
 	layoutMode       string                     // layout display mode
 	xmp              []byte                     // XMP metadata
+	xmpAuto          bool                       // XMP metadata is generated
 	addOutputIntent  bool                       // Output intent
+	outputProfile    int                        // ICC profile of the output intent, 0 for sRGB
+	outputCondition  string                     // printing condition of the output intent profile
+	outputIntentObj  int                        // object number of the sRGB output intent profile
 	producer         string                     // producer
 	title            string                     // title
 	subject          string                     // subject
@@ -577,20 +700,41 @@ type Fpdf struct {
 	creator          string                     // creator
 	creationDate     time.Time                  // override for document CreationDate value
 	modDate          time.Time                  // override for document ModDate value
+	customInfo       map[string]string          // custom entries of the document information
+	viewerPrefs      *ViewerPreferences         // viewer preferences
+	openAction       Action                     // action performed when the document is opened
+	pageActions      map[int]map[string]Action  // actions of pages by page and event
 	aliasNbPagesStr  string                     // alias for total number of pages
+	section          string                     // title of the current section for headers and footers
+	sectionNo        int                        // number of the current section, incremented by SetSection()
+	pageSections     map[int]int                // number of the section of each page
+	counters         []counterType              // aliases replaced with numbers of pages
+	aliasShifts      []aliasShiftType           // aligned text moved when its aliases are replaced
+	mirror           mirrorType                 // margins that alternate between odd and even pages
 	pdfVersion       string                     // PDF version number
 	fontDirStr       string                     // location of font definition files
 	capStyle         int                        // line cap style: butt 0, round 1, square 2
 	joinStyle        int                        // line segment join style: miter 0, round 1, bevel 2
+	miterLimit       float64                    // miter limit, 10 by default
 	dashArray        []float64                  // dash array
 	dashPhase        float64                    // dash phase
 	blendList        []blendModeType            // slice[idx] of alpha transparency modes, 1-based
 	blendMap         map[string]int             // map into blendList
 	blendMode        string                     // current blend mode
+	overprint        overprintType              // current overprint settings
+	overprintStates  []overprintType            // graphics states with overprint settings, numbered from 1
 	alpha            float64                    // current transpacency
 	gradientList     []gradientType             // slice[idx] of gradient records
+	patterns         []patternType              // tiling patterns, numbered from 1
+	softMasks        []softMaskType             // soft masks, numbered from 1
+	softMaskStates   []softMaskStateType        // graphics states that apply soft masks, numbered from 1
+	softMaskCanvas   *canvasType                // soft mask being drawn, if any
+	groups           []groupType                // transparency groups, numbered from 1
+	groupCanvases    []*canvasType              // transparency groups being drawn, innermost last
+	canvasDepth      int                        // number of patterns, soft masks and groups being drawn
 	clipNest         int                        // Number of active clipping contexts
 	transformNest    int                        // Number of active transformation contexts
+	nests            []nestType                 // active clipping operations, transformation contexts and saved graphics states, innermost last
 	err              error                      // Set if error occurs during life cycle of instance
 	protect          protectType                // document protection structure
 	layer            layerRecType               // manages optional layers in document
@@ -603,7 +747,12 @@ type Fpdf struct {
 		draw, fill, text colorType
 	}
 	spotColorMap           map[string]spotColorType // Map of named ink-based colors
+	iccProfiles            []iccProfileType         // ICC color profiles, numbered from 1
 	userUnderlineThickness float64                  // A custom user underline thickness multiplier.
+	signature              signatureType            // digital signature field and provider
+	pdfa                   pdfaType                 // PDF/A conformance level
+	pdfx                   pdfxType                 // PDF/X conformance level
+	form                   formType                 // interactive form fields
 }
 
 type encType struct {
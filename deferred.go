@@ -0,0 +1,73 @@
+package gofpdf
+
+import (
+	"fmt"
+	"io"
+)
+
+// RegisterImageOptionsOpener registers an image whose data is read from the
+// readers that open returns, without adding it to the page. Use Image() with
+// the name imgName to add the image to the page.
+//
+// Unlike RegisterImageOptionsReader(), which holds the data of the image for
+// the lifetime of the document, this method reads the image once to find its
+// size and other properties, and keeps only those. The data is read again,
+// with a new reader from open, when the image is embedded as the document is
+// output, and released once it has been written, so that documents with many
+// large images, such as photo books, need not hold all of them in memory.
+// The image must not change in between; an error occurs when the document is
+// output if it has. As for RegisterImageOptionsReader(), options.ImageType
+// needs to be specified.
+//
+// Images that are resampled, masked or placed inline, and images of
+// templates that are written with WriteTo() or Serialize(), are read as well
+// when they are placed or written.
+//
+// The RegisterImageOptionsOpener example demonstrates this method.
+func (f *Fpdf) RegisterImageOptionsOpener(imgName string, options ImageOptions, open func() (io.ReadCloser, error)) (info *ImageInfoType) {
+	if f.err != nil {
+		return
+	}
+	info, ok := f.images[imgName]
+	if ok {
+		return
+	}
+	if info = f.parseOpenedImage(open, options); f.err != nil {
+		return
+	}
+	f.registerImage(imgName, info)
+	if f.err != nil {
+		return
+	}
+	w, h, cs, bpc := info.w, info.h, info.cs, info.bpc
+	dataLen, smaskLen := len(info.data), len(info.smask)
+	info.lazy = &lazyImageData{
+		dataLen:  int64(dataLen),
+		smaskLen: int64(smaskLen),
+		load: func() (data, smask []byte, err error) {
+			loaded := f.parseOpenedImage(open, options)
+			if f.err != nil {
+				return nil, nil, f.err
+			}
+			if loaded.w != w || loaded.h != h || loaded.cs != cs || loaded.bpc != bpc ||
+				len(loaded.data) != dataLen || len(loaded.smask) != smaskLen {
+				return nil, nil, fmt.Errorf("image %s has changed since it was registered", imgName)
+			}
+			return loaded.data, loaded.smask, nil
+		},
+	}
+	info.data, info.smask = nil, nil
+	return
+}
+
+// parseOpenedImage extracts info from the image read from the reader that
+// open returns
+func (f *Fpdf) parseOpenedImage(open func() (io.ReadCloser, error), options ImageOptions) (info *ImageInfoType) {
+	r, err := open()
+	if err != nil {
+		f.err = err
+		return
+	}
+	defer r.Close()
+	return f.parseImageOptions(r, options)
+}
@@ -0,0 +1,128 @@
+package gofpdf
+
+import (
+	"fmt"
+	"sort"
+)
+
+// AddNamedDest defines the named destination nameStr as the position y on
+// page pageNum. A value of -1 for y indicates the current position, and -1
+// for pageNum the current page. Named destinations are written into the
+// document's /Dests name tree, so that links within the document, created
+// with LinkToDest(), and links from other documents, created with
+// LinkToFile(), can refer to them by name. Defining a name again replaces
+// its destination.
+//
+// The AddNamedDest example demonstrates this method.
+func (f *Fpdf) AddNamedDest(nameStr string, pageNum int, y float64) {
+	if f.err != nil {
+		return
+	}
+	if y == -1 {
+		y = f.y
+	}
+	if pageNum == -1 {
+		pageNum = f.page
+	}
+	if nameStr == "" {
+		f.err = fmt.Errorf("a named destination needs a name")
+		return
+	}
+	if pageNum < 1 || pageNum > f.PageCount() {
+		f.err = fmt.Errorf("invalid page %d of named destination %q", pageNum, nameStr)
+		return
+	}
+	if f.namedDests == nil {
+		f.namedDests = make(map[string]intLinkType)
+	}
+	f.namedDests[nameStr] = intLinkType{page: pageNum, y: y}
+}
+
+// LinkToDest puts a link on the rectangular area of the current page
+// specified by x, y, w and h that points to the named destination destStr,
+// defined with AddNamedDest() before or after the link is added.
+func (f *Fpdf) LinkToDest(x, y, w, h float64, destStr string) {
+	if f.err != nil {
+		return
+	}
+	f.pageLinks[f.page] = append(f.pageLinks[f.page],
+		linkType{x: x * f.k, y: f.hPt - y*f.k, wd: w * f.k, ht: h * f.k, dest: destStr})
+}
+
+// LinkToFile puts a link on the rectangular area of the current page
+// specified by x, y, w and h that opens the PDF file fileStr, such as another
+// document of a multi-file document set. fileStr is usually a path relative
+// to the location of the document. The file is opened at its named
+// destination destStr, or at its first page if destStr is empty.
+func (f *Fpdf) LinkToFile(x, y, w, h float64, fileStr, destStr string) {
+	if f.err != nil {
+		return
+	}
+	if fileStr == "" {
+		f.err = fmt.Errorf("a link to a file needs a file name")
+		return
+	}
+	f.pageLinks[f.page] = append(f.pageLinks[f.page],
+		linkType{x: x * f.k, y: f.hPt - y*f.k, wd: w * f.k, ht: h * f.k, dest: destStr, fileStr: fileStr})
+}
+
+// pageDest returns the explicit destination of the position y on page
+func (f *Fpdf) pageDest(page int, y float64) string {
+	return sprintf("[%d 0 R /XYZ 0 %.2f null]", f.pageObj(page), f.pageTop(page, y))
+}
+
+// pageTop returns the position y on page in PDF coordinates
+func (f *Fpdf) pageTop(page int, y float64) float64 {
+	var h float64
+	if sz, ok := f.pageSizes[page]; ok {
+		h = sz.Ht
+	} else if f.defOrientation == "P" {
+		h = f.defPageSize.Ht * f.k
+	} else {
+		h = f.defPageSize.Wd * f.k
+	}
+	return h - y*f.k
+}
+
+// linkAction returns the entries of the link annotation that specify the
+// target of link
+func (f *Fpdf) linkAction(link linkType) string {
+	switch {
+	case link.fileStr != "":
+		dest := "[0 /Fit]"
+		if link.dest != "" {
+			dest = f.textstring(link.dest)
+		}
+		return sprintf("/A <</S /GoToR /F %s /D %s>>", f.textstring(link.fileStr), dest)
+	case link.dest != "":
+		if _, ok := f.namedDests[link.dest]; !ok {
+			f.err = fmt.Errorf("undefined named destination %q", link.dest)
+		}
+		return "/Dest " + f.textstring(link.dest)
+	case link.link == 0:
+		return "/A <</S /URI /URI " + f.textstring(link.linkStr) + ">>"
+	}
+	l := f.links[link.link]
+	return "/Dest " + f.pageDest(l.page, l.y)
+}
+
+// putNamedDests writes the /Dests name tree of the name dictionary
+func (f *Fpdf) putNamedDests() {
+	if len(f.namedDests) == 0 {
+		return
+	}
+	names := make([]string, 0, len(f.namedDests))
+	for name := range f.namedDests {
+		names = append(names, name)
+	}
+	// The keys of a name tree are sorted in byte order
+	sort.Strings(names)
+	var s fmtBuffer
+	s.printf("/Dests << /Names [")
+	for _, name := range names {
+		dest := f.namedDests[name]
+		s.printf(" %s %s", f.textstring(name), f.pageDest(dest.page, dest.y))
+	}
+	s.printf(" ] >>")
+	f.out(s.String())
+}
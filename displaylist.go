@@ -0,0 +1,435 @@
+package gofpdf
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// DisplayOp is an operation of a display list: the name of the method of
+// Fpdf that performs it, with its numeric and string arguments in order.
+// Colors are numbers from 0 to 255, and boolean arguments are 0 or 1.
+type DisplayOp struct {
+	Name string    `json:"name"`
+	Nums []float64 `json:"nums,omitempty"`
+	Strs []string  `json:"strs,omitempty"`
+}
+
+// displayOpArgs holds the number of numeric and string arguments of each
+// operation of a display list. The coordinates of polygons, whose number
+// varies, are counted as -1.
+var displayOpArgs = map[string][2]int{
+	"SetDrawColor":       {3, 0},
+	"SetFillColor":       {3, 0},
+	"SetTextColor":       {3, 0},
+	"SetLineWidth":       {1, 0},
+	"SetFont":            {1, 2},
+	"SetXY":              {2, 0},
+	"Line":               {4, 0},
+	"Rect":               {4, 1},
+	"Circle":             {3, 1},
+	"Ellipse":            {5, 1},
+	"Polygon":            {-1, 1},
+	"Text":               {2, 1},
+	"CellFormat":         {4, 3},
+	"Image":              {4, 1},
+	"TransformBegin":     {0, 0},
+	"TransformTranslate": {2, 0},
+	"TransformScale":     {4, 0},
+	"TransformRotate":    {3, 0},
+	"TransformEnd":       {0, 0},
+}
+
+// DisplayList is a list of drawing operations that are recorded with its
+// methods, which are named after the methods of Fpdf that perform them and
+// take the same arguments. Unlike a template, whose content is fixed once it
+// has been created, a display list can be inspected and modified through
+// Ops, measured with Bounds(), transformed with Translated(), Scaled() and
+// Rotated(), written as JSON with WriteTo() and read with ReadDisplayList(),
+// and replayed onto any document with Replay(), for example to measure a
+// block of content before placing it.
+//
+// Positions and sizes are in the unit of measure of the document the list is
+// replayed onto. The zero value is an empty display list.
+//
+// The DisplayList example demonstrates this type.
+type DisplayList struct {
+	Ops []DisplayOp `json:"ops"`
+}
+
+// add appends the operation name with its arguments to the list
+func (dl *DisplayList) add(name string, nums []float64, strs ...string) {
+	dl.Ops = append(dl.Ops, DisplayOp{Name: name, Nums: nums, Strs: strs})
+}
+
+// SetDrawColor records a call of Fpdf.SetDrawColor().
+func (dl *DisplayList) SetDrawColor(r, g, b int) {
+	dl.add("SetDrawColor", []float64{float64(r), float64(g), float64(b)})
+}
+
+// SetFillColor records a call of Fpdf.SetFillColor().
+func (dl *DisplayList) SetFillColor(r, g, b int) {
+	dl.add("SetFillColor", []float64{float64(r), float64(g), float64(b)})
+}
+
+// SetTextColor records a call of Fpdf.SetTextColor().
+func (dl *DisplayList) SetTextColor(r, g, b int) {
+	dl.add("SetTextColor", []float64{float64(r), float64(g), float64(b)})
+}
+
+// SetLineWidth records a call of Fpdf.SetLineWidth().
+func (dl *DisplayList) SetLineWidth(width float64) {
+	dl.add("SetLineWidth", []float64{width})
+}
+
+// SetFont records a call of Fpdf.SetFont().
+func (dl *DisplayList) SetFont(familyStr, styleStr string, size float64) {
+	dl.add("SetFont", []float64{size}, familyStr, styleStr)
+}
+
+// SetXY records a call of Fpdf.SetXY().
+func (dl *DisplayList) SetXY(x, y float64) {
+	dl.add("SetXY", []float64{x, y})
+}
+
+// Line records a call of Fpdf.Line().
+func (dl *DisplayList) Line(x1, y1, x2, y2 float64) {
+	dl.add("Line", []float64{x1, y1, x2, y2})
+}
+
+// Rect records a call of Fpdf.Rect().
+func (dl *DisplayList) Rect(x, y, w, h float64, styleStr string) {
+	dl.add("Rect", []float64{x, y, w, h}, styleStr)
+}
+
+// Circle records a call of Fpdf.Circle().
+func (dl *DisplayList) Circle(x, y, r float64, styleStr string) {
+	dl.add("Circle", []float64{x, y, r}, styleStr)
+}
+
+// Ellipse records a call of Fpdf.Ellipse().
+func (dl *DisplayList) Ellipse(x, y, rx, ry, degRotate float64, styleStr string) {
+	dl.add("Ellipse", []float64{x, y, rx, ry, degRotate}, styleStr)
+}
+
+// Polygon records a call of Fpdf.Polygon().
+func (dl *DisplayList) Polygon(points []PointType, styleStr string) {
+	nums := make([]float64, 0, 2*len(points))
+	for _, pt := range points {
+		nums = append(nums, pt.X, pt.Y)
+	}
+	dl.add("Polygon", nums, styleStr)
+}
+
+// Text records a call of Fpdf.Text().
+func (dl *DisplayList) Text(x, y float64, txtStr string) {
+	dl.add("Text", []float64{x, y}, txtStr)
+}
+
+// CellFormat records a call of Fpdf.CellFormat() without a link.
+func (dl *DisplayList) CellFormat(w, h float64, txtStr, borderStr string, ln int, alignStr string, fill bool) {
+	fillNum := 0.0
+	if fill {
+		fillNum = 1
+	}
+	dl.add("CellFormat", []float64{w, h, float64(ln), fillNum}, txtStr, borderStr, alignStr)
+}
+
+// Image records a call of Fpdf.ImageOptions() for the image imageNameStr,
+// which is either the name of an image registered with the document or the
+// name of an image file, at position (x, y) with width w and height h, without
+// flow or link.
+func (dl *DisplayList) Image(imageNameStr string, x, y, w, h float64) {
+	dl.add("Image", []float64{x, y, w, h}, imageNameStr)
+}
+
+// TransformBegin records a call of Fpdf.TransformBegin().
+func (dl *DisplayList) TransformBegin() {
+	dl.add("TransformBegin", nil)
+}
+
+// TransformTranslate records a call of Fpdf.TransformTranslate().
+func (dl *DisplayList) TransformTranslate(tx, ty float64) {
+	dl.add("TransformTranslate", []float64{tx, ty})
+}
+
+// TransformScale records a call of Fpdf.TransformScale().
+func (dl *DisplayList) TransformScale(scaleWd, scaleHt, x, y float64) {
+	dl.add("TransformScale", []float64{scaleWd, scaleHt, x, y})
+}
+
+// TransformRotate records a call of Fpdf.TransformRotate().
+func (dl *DisplayList) TransformRotate(angle, x, y float64) {
+	dl.add("TransformRotate", []float64{angle, x, y})
+}
+
+// TransformEnd records a call of Fpdf.TransformEnd().
+func (dl *DisplayList) TransformEnd() {
+	dl.add("TransformEnd", nil)
+}
+
+// transformed returns a copy of the list whose operations are preceded by
+// op in a transformation context
+func (dl *DisplayList) transformed(op DisplayOp) *DisplayList {
+	ops := make([]DisplayOp, 0, len(dl.Ops)+3)
+	ops = append(ops, DisplayOp{Name: "TransformBegin"}, op)
+	ops = append(ops, dl.Ops...)
+	ops = append(ops, DisplayOp{Name: "TransformEnd"})
+	return &DisplayList{Ops: ops}
+}
+
+// Translated returns a copy of the display list whose content is moved by tx
+// horizontally and ty vertically.
+func (dl *DisplayList) Translated(tx, ty float64) *DisplayList {
+	return dl.transformed(DisplayOp{Name: "TransformTranslate", Nums: []float64{tx, ty}})
+}
+
+// Scaled returns a copy of the display list whose content is scaled by the
+// percentages scaleWd horizontally and scaleHt vertically about the point
+// (x, y).
+func (dl *DisplayList) Scaled(scaleWd, scaleHt, x, y float64) *DisplayList {
+	return dl.transformed(DisplayOp{Name: "TransformScale", Nums: []float64{scaleWd, scaleHt, x, y}})
+}
+
+// Rotated returns a copy of the display list whose content is rotated
+// counter-clockwise by angle degrees about the point (x, y).
+func (dl *DisplayList) Rotated(angle, x, y float64) *DisplayList {
+	return dl.transformed(DisplayOp{Name: "TransformRotate", Nums: []float64{angle, x, y}})
+}
+
+// validOp returns true if op is an operation of a display list with the
+// arguments it needs, and sets the error of f otherwise
+func (f *Fpdf) validOp(op DisplayOp) bool {
+	args, ok := displayOpArgs[op.Name]
+	switch {
+	case !ok:
+		f.SetErrorf("unknown display list operation %q", op.Name)
+	case args[0] >= 0 && len(op.Nums) != args[0] || args[0] < 0 && len(op.Nums)%2 != 0 || len(op.Strs) != args[1]:
+		f.SetErrorf("invalid arguments of display list operation %s", op.Name)
+	default:
+		return true
+	}
+	return false
+}
+
+// Replay performs the operations of the display list on the current page of
+// f. An operation that is unknown or does not have the arguments it needs
+// sets the error of f.
+func (dl *DisplayList) Replay(f *Fpdf) {
+	for _, op := range dl.Ops {
+		if f.err != nil || !f.validOp(op) {
+			return
+		}
+		n, s := op.Nums, op.Strs
+		switch op.Name {
+		case "SetDrawColor":
+			f.SetDrawColor(int(n[0]), int(n[1]), int(n[2]))
+		case "SetFillColor":
+			f.SetFillColor(int(n[0]), int(n[1]), int(n[2]))
+		case "SetTextColor":
+			f.SetTextColor(int(n[0]), int(n[1]), int(n[2]))
+		case "SetLineWidth":
+			f.SetLineWidth(n[0])
+		case "SetFont":
+			f.SetFont(s[0], s[1], n[0])
+		case "SetXY":
+			f.SetXY(n[0], n[1])
+		case "Line":
+			f.Line(n[0], n[1], n[2], n[3])
+		case "Rect":
+			f.Rect(n[0], n[1], n[2], n[3], s[0])
+		case "Circle":
+			f.Circle(n[0], n[1], n[2], s[0])
+		case "Ellipse":
+			f.Ellipse(n[0], n[1], n[2], n[3], n[4], s[0])
+		case "Polygon":
+			points := make([]PointType, len(n)/2)
+			for j := range points {
+				points[j] = PointType{n[2*j], n[2*j+1]}
+			}
+			f.Polygon(points, s[0])
+		case "Text":
+			f.Text(n[0], n[1], s[0])
+		case "CellFormat":
+			f.CellFormat(n[0], n[1], s[0], s[1], int(n[2]), s[2], n[3] != 0, 0, "")
+		case "Image":
+			f.ImageOptions(s[0], n[0], n[1], n[2], n[3], false, ImageOptions{}, 0, "")
+		case "TransformBegin":
+			f.TransformBegin()
+		case "TransformTranslate":
+			f.TransformTranslate(n[0], n[1])
+		case "TransformScale":
+			f.TransformScale(n[0], n[1], n[2], n[3])
+		case "TransformRotate":
+			f.TransformRotate(n[0], n[1], n[2])
+		case "TransformEnd":
+			f.TransformEnd()
+		}
+	}
+}
+
+// Bounds returns the position and size, in the unit of measure of f, of the
+// smallest rectangle that encloses what the display list draws when it is
+// replayed onto the current page of f, from the current position of f. Text
+// is measured with the fonts of f, from the ascent to the descent of the font
+// or, for the core fonts, from 0.8 times the font size above the baseline to
+// 0.2 times below it. Lines and outlines are measured along their middle, without their
+// width. Neither the document nor its current position are changed; errors
+// are those of Replay().
+func (dl *DisplayList) Bounds(f *Fpdf) (x, y, w, h float64) {
+	if f.err != nil {
+		return
+	}
+	// Fonts and images are loaded by a page builder rather than by the
+	// document, whose resources would include them
+	m := f.NewPageBuilder()
+	px, py := f.x, f.y
+	matrix := [6]float64{1, 0, 0, 1, 0, 0}
+	var stack [][6]float64
+	x0, y0, x1, y1 := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	point := func(x, y float64) {
+		x, y = matrix[0]*x+matrix[2]*y+matrix[4], matrix[1]*x+matrix[3]*y+matrix[5]
+		x0, y0, x1, y1 = math.Min(x0, x), math.Min(y0, y), math.Max(x1, x), math.Max(y1, y)
+	}
+	rect := func(x, y, w, h float64) {
+		point(x, y)
+		point(x+w, y)
+		point(x, y+h)
+		point(x+w, y+h)
+	}
+	transform := func(a, b, c, d, e, f float64) {
+		t := matrix
+		matrix = [6]float64{t[0]*a + t[2]*b, t[1]*a + t[3]*b, t[0]*c + t[2]*d, t[1]*c + t[3]*d,
+			t[0]*e + t[2]*f + t[4], t[1]*e + t[3]*f + t[5]}
+	}
+	text := func(x, y float64, txtStr string) {
+		font := m.currentFont
+		if font.Name == "" {
+			m.err = fmt.Errorf("font has not been set; unable to render text")
+			return
+		}
+		ascent, descent := float64(font.Desc.Ascent), float64(font.Desc.Descent)
+		if ascent == 0 {
+			// Core fonts have no font descriptor
+			ascent, descent = 800, -200
+		}
+		rect(x, y-ascent*m.fontSize/1000, m.GetStringWidth(txtStr), (ascent-descent)*m.fontSize/1000)
+	}
+	for _, op := range dl.Ops {
+		if !f.validOp(op) {
+			return 0, 0, 0, 0
+		}
+		n, s := op.Nums, op.Strs
+		switch op.Name {
+		case "SetFont":
+			m.SetFont(s[0], s[1], n[0])
+		case "SetXY":
+			px, py = n[0], n[1]
+		case "Line":
+			point(n[0], n[1])
+			point(n[2], n[3])
+		case "Rect":
+			rect(n[0], n[1], n[2], n[3])
+		case "Circle":
+			rect(n[0]-n[2], n[1]-n[2], 2*n[2], 2*n[2])
+		case "Ellipse":
+			sin, cos := math.Sincos(n[4] * math.Pi / 180)
+			hw := math.Sqrt(n[2]*n[2]*cos*cos + n[3]*n[3]*sin*sin)
+			hh := math.Sqrt(n[2]*n[2]*sin*sin + n[3]*n[3]*cos*cos)
+			rect(n[0]-hw, n[1]-hh, 2*hw, 2*hh)
+		case "Polygon":
+			for j := 0; j < len(n); j += 2 {
+				point(n[j], n[j+1])
+			}
+		case "Text":
+			text(n[0], n[1], s[0])
+		case "CellFormat":
+			cw, ch := n[0], n[1]
+			if cw == 0 {
+				cw = f.w - f.rMargin - px
+			}
+			rect(px, py, cw, ch)
+			if s[0] != "" {
+				if m.currentFont.Name == "" {
+					m.err = fmt.Errorf("font has not been set; unable to render text")
+					break
+				}
+				tw := m.GetStringWidth(s[0])
+				tx := px + f.cMargin
+				if strings.Contains(s[2], "R") {
+					tx = px + cw - f.cMargin - tw
+				} else if strings.Contains(s[2], "C") {
+					tx = px + (cw-tw)/2
+				}
+				rect(tx, py, tw, ch)
+			}
+			switch int(n[2]) {
+			case 0:
+				px += cw
+			case 1:
+				px, py = f.lMargin, py+ch
+			case 2:
+				py += ch
+			}
+		case "Image":
+			info := m.RegisterImageOptions(s[0], ImageOptions{})
+			if m.err != nil {
+				f.err = m.err
+				return 0, 0, 0, 0
+			}
+			uw, uh := info.uprightSize()
+			iw, ih := m.imageExtent(info, n[2], n[3], uw, uh)
+			rect(n[0], n[1], iw, ih)
+		case "TransformBegin":
+			stack = append(stack, matrix)
+		case "TransformTranslate":
+			transform(1, 0, 0, 1, n[0], n[1])
+		case "TransformScale":
+			sx, sy := n[0]/100, n[1]/100
+			transform(sx, 0, 0, sy, n[2]-n[2]*sx, n[3]-n[3]*sy)
+		case "TransformRotate":
+			// Counter-clockwise on the page, whose vertical axis points down
+			sin, cos := math.Sincos(n[0] * math.Pi / 180)
+			transform(cos, -sin, sin, cos, n[1]-n[1]*cos-n[2]*sin, n[2]+n[1]*sin-n[2]*cos)
+		case "TransformEnd":
+			if len(stack) > 0 {
+				matrix = stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+			}
+		}
+		if m.err != nil {
+			f.err = m.err
+			return 0, 0, 0, 0
+		}
+	}
+	if x0 > x1 {
+		return 0, 0, 0, 0
+	}
+	return x0, y0, x1 - x0, y1 - y0
+}
+
+// WriteTo writes the display list to w as JSON, in which each operation is
+// an object with its name and its numeric and string arguments.
+//
+// The DisplayList example demonstrates this method.
+func (dl *DisplayList) WriteTo(w io.Writer) (n int64, err error) {
+	data, err := json.Marshal(dl)
+	if err != nil {
+		return 0, err
+	}
+	k, err := w.Write(data)
+	return int64(k), err
+}
+
+// ReadDisplayList reads a display list written with WriteTo() from r.
+//
+// The DisplayList example demonstrates this function.
+func ReadDisplayList(r io.Reader) (*DisplayList, error) {
+	dl := new(DisplayList)
+	if err := json.NewDecoder(r).Decode(dl); err != nil {
+		return nil, err
+	}
+	return dl, nil
+}
@@ -14,7 +14,9 @@ Features
 
 -   Automatic page breaks, line breaks, and text justification
 
--   Inclusion of JPEG, PNG, GIF, TIFF and basic path-only SVG images
+-   Inclusion of JPEG, JPEG 2000, PNG, GIF, TIFF (with CCITT passthrough), BMP,
+    WebP and basic path-only SVG images, and of images decoded by the image
+    package, such as AVIF images
 
 -   Colors, gradients and alpha channel transparency
 
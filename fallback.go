@@ -0,0 +1,170 @@
+package gofpdf
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SetFontFallbacks specifies the font families that are used, in order, for
+// characters that the fonts of family familyStr do not contain. Without
+// fallbacks, such characters are shown as the font's missing glyph symbol,
+// usually an empty box.
+//
+// When text is printed with Cell(), MultiCell(), Write() or Text() in a UTF-8
+// font of family familyStr, each character missing from that font is printed
+// with the first fallback family that contains it, in the same style if that
+// family has it and in the regular style otherwise. The text is split into
+// runs of characters in the same font transparently, and the widths used to
+// align and wrap text are those of the fonts that print it.
+//
+// All families must be UTF-8 fonts, added with AddUTF8Font() or
+// AddUTF8FontFromBytes(), and each fallback family must be available in the
+// regular style. Passing an empty list removes the fallbacks of familyStr.
+//
+// The SetFontFallbacks example demonstrates this method.
+func (f *Fpdf) SetFontFallbacks(familyStr string, fallbacks []string) {
+	if f.err != nil {
+		return
+	}
+	familyStr = strings.ToLower(fontFamilyEscape(familyStr))
+	if len(fallbacks) == 0 {
+		delete(f.fontFallbacks, familyStr)
+		return
+	}
+	list := make([]string, len(fallbacks))
+	for j, fallbackStr := range fallbacks {
+		fallbackStr = strings.ToLower(fontFamilyEscape(fallbackStr))
+		if font, ok := f.fonts[getFontKey(fallbackStr, "")]; !ok || font.Tp != "UTF8" {
+			f.err = fmt.Errorf("fallback font family %s has not been added as a UTF-8 font", fallbacks[j])
+			return
+		}
+		list[j] = fallbackStr
+	}
+	if f.fontFallbacks == nil {
+		f.fontFallbacks = make(map[string][]string)
+	}
+	f.fontFallbacks[familyStr] = list
+}
+
+// fontHasRune returns true if font contains a glyph for r
+func fontHasRune(font *fontDefType, r rune) bool {
+	return int(r) < len(font.Cw) && font.Cw[r] != 0
+}
+
+// fallbackFont returns the font in which r is printed if the current font
+// does not contain it, or nil if r is printed in the current font
+func (f *Fpdf) fallbackFont(r rune) *fontDefType {
+	if !f.isCurrentUTF8 || len(f.fontFallbacks) == 0 || fontHasRune(&f.currentFont, r) {
+		return nil
+	}
+	for _, familyStr := range f.fontFallbacks[f.fontFamily] {
+		font, ok := f.fonts[getFontKey(familyStr, f.fontStyle)]
+		if !ok || font.Tp != "UTF8" {
+			font = f.fonts[getFontKey(familyStr, "")]
+		}
+		if fontHasRune(&font, r) {
+			return &font
+		}
+	}
+	return nil
+}
+
+// fallbackWidth returns the width of r in thousandths of the font size, and
+// true, if r is printed in a fallback font
+func (f *Fpdf) fallbackWidth(r rune) (int, bool) {
+	font := f.fallbackFont(r)
+	if font == nil {
+		return 0, false
+	}
+	if w := font.Cw[r]; w != 65535 {
+		return w, true
+	}
+	return 0, true
+}
+
+// needsFallback returns true if any character of txtStr is printed in a
+// fallback font
+func (f *Fpdf) needsFallback(txtStr string) bool {
+	if !f.isCurrentUTF8 || len(f.fontFallbacks[f.fontFamily]) == 0 {
+		return false
+	}
+	for _, r := range txtStr {
+		if f.fallbackFont(r) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// needsTJ returns true if txtStr is printed with the TJ arrays of tjText(),
+// because it contains characters printed in fallback fonts, is kerned or has
+// word spacing, which the PDF word spacing operator does not apply to UTF-8
+// fonts
+func (f *Fpdf) needsTJ(txtStr string) bool {
+	return f.isCurrentUTF8 && (f.kerning || f.wordSpacing != 0) || f.needsFallback(txtStr)
+}
+
+// tjText returns the operators that show txtStr, switching between the
+// current font and its fallbacks and applying kerning if it is enabled. Each
+// space is preceded by a horizontal adjustment for the word spacing set with
+// SetWordSpacing() and, to justify the text, shift thousandths of the font
+// size. The current font is selected again at the end.
+func (f *Fpdf) tjText(txtStr string, shift float64) string {
+	var s fmtBuffer
+	current := f.currentFont.i
+	var run []rune
+	var runFont *fontDefType
+	flush := func() {
+		if len(run) == 0 {
+			return
+		}
+		font := &f.currentFont
+		if runFont != nil {
+			font = runFont
+		}
+		if font.i != current {
+			s.printf("/F%s %.2f Tf ", font.i, f.fontSizePt)
+			current = font.i
+		}
+		s.printf("[")
+		start := 0
+		for j, r := range run {
+			font.usedRunes[int(r)] = int(r)
+			adj := 0.0
+			if j > 0 {
+				adj -= float64(f.fontKerning(font, run[j-1], r))
+			}
+			if r == ' ' {
+				adj -= shift + f.wordSpacing*1000/f.fontSize
+			}
+			if adj != 0 {
+				if j > start {
+					s.printf("(%s)", f.escape(utf8toutf16(string(run[start:j]), false)))
+				}
+				s.printf("%.3f", adj)
+				start = j
+			}
+		}
+		s.printf("(%s)] TJ ", f.escape(utf8toutf16(string(run[start:]), false)))
+		run = run[:0]
+	}
+	fontKey := func(font *fontDefType) string {
+		if font == nil {
+			return f.currentFont.i
+		}
+		return font.i
+	}
+	for _, r := range txtStr {
+		font := f.fallbackFont(r)
+		if fontKey(font) != fontKey(runFont) {
+			flush()
+		}
+		runFont = font
+		run = append(run, r)
+	}
+	flush()
+	if current != f.currentFont.i {
+		s.printf("/F%s %.2f Tf", f.currentFont.i, f.fontSizePt)
+	}
+	return strings.TrimSpace(s.String())
+}
@@ -0,0 +1,186 @@
+package gofpdf
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Sizes of footnotes relative to the font size of the text they refer to
+const (
+	footnoteScale   = 0.8  // font size of footnotes
+	footnoteLeading = 1.25 // line height of footnotes, relative to their font size
+	footnoteRule    = 8.0  // space for the rule above the footnotes, in points
+)
+
+// footnoteType is a footnote at the bottom of a page
+type footnoteType struct {
+	number    int
+	txtStr    string
+	family    string
+	style     string
+	sizePt    float64
+	lines     int  // number of lines of the footnote on the current page
+	continued bool // the footnote is continued from a previous page
+}
+
+// footnotesType holds the footnotes of the current page
+type footnotesType struct {
+	count   int            // number of footnotes in the document
+	notes   []footnoteType // footnotes at the bottom of the current page
+	pending []footnoteType // footnotes that are continued on the next page
+	height  float64        // space reserved for footnotes on the current page
+}
+
+// AddFootnote prints the next footnote number as a superscript at the
+// current position, in the way WriteSuperscript() does with the height of the
+// last printed cell, and adds txtStr as a footnote with that number at the
+// bottom of the page. Footnotes are numbered from 1 through the document and
+// printed in the current font at 80 percent of its size, below a short rule
+// and above the bottom margin. The space they take is removed from the page
+// when they are added, so that the text above them breaks to the next page
+// earlier. A footnote that does not fit on the page is continued at the
+// bottom of the following page.
+//
+// The AddFootnote example demonstrates this method.
+func (f *Fpdf) AddFootnote(txtStr string) {
+	if f.err != nil {
+		return
+	}
+	if f.currentFont.Name == "" {
+		f.err = fmt.Errorf("font has not been set; unable to render text")
+		return
+	}
+	f.footnotes.count++
+	note := footnoteType{
+		number: f.footnotes.count,
+		txtStr: txtStr,
+		family: f.fontFamily,
+		style:  f.fontStyle,
+		sizePt: f.fontSizePt * footnoteScale,
+	}
+	h := f.lasth
+	if h <= 0 {
+		h = f.fontSize
+	}
+	f.WriteSuperscript(h, strconv.Itoa(note.number))
+	f.placeFootnote(note, f.y+h, false)
+}
+
+// GetFootnoteCount returns the number of footnotes that have been added with
+// AddFootnote().
+func (f *Fpdf) GetFootnoteCount() int {
+	return f.footnotes.count
+}
+
+// footnoteLayout sets the font of note and returns the height of its lines
+// and the indentation of its text
+func (f *Fpdf) footnoteLayout(note footnoteType) (lh, indent float64) {
+	f.SetFont(note.family, note.style, note.sizePt)
+	return f.fontSize * footnoteLeading, f.GetStringWidth("00 ")
+}
+
+// placeFootnote adds note to the bottom of the current page, below top, and
+// reserves the space of the lines that fit there. If force is true, at least
+// one line of the footnote is placed on the page.
+func (f *Fpdf) placeFootnote(note footnoteType, top float64, force bool) {
+	fn := &f.footnotes
+	rule := 0.0
+	if fn.height == 0 {
+		rule = footnoteRule / f.k
+	}
+	family, style, size := f.fontFamily, f.currentStyle(), f.fontSizePt
+	lh, indent := f.footnoteLayout(note)
+	lines := len(f.SplitText(note.txtStr, f.footnoteWidth()-indent))
+	if family != "" {
+		f.SetFont(family, style, size)
+	}
+	note.lines = min(lines, int((f.pageBreakTrigger-rule-top)/lh+1e-9))
+	if force {
+		note.lines = max(note.lines, min(lines, 1))
+	}
+	if note.lines > 0 {
+		h := rule + float64(note.lines)*lh
+		fn.height += h
+		f.pageBreakTrigger -= h
+	} else {
+		note.lines = 0
+	}
+	fn.notes = append(fn.notes, note)
+}
+
+// footnoteWidth returns the width of the footnotes, which extend from the left
+// to the right page margin
+func (f *Fpdf) footnoteWidth() float64 {
+	if f.columns.n > 1 {
+		return f.w - f.columns.lMargin - f.columns.rMargin
+	}
+	return f.w - f.lMargin - f.rMargin
+}
+
+// putFootnotes prints the footnotes of the current page and keeps the lines
+// that do not fit for the next page
+func (f *Fpdf) putFootnotes() {
+	fn := &f.footnotes
+	if len(fn.notes) == 0 {
+		return
+	}
+	notes := fn.notes
+	top := f.pageBreakTrigger
+	f.pageBreakTrigger += fn.height
+	fn.notes, fn.height = nil, 0
+	x, y, lMargin, rMargin := f.x, f.y, f.lMargin, f.rMargin
+	family, style, size, rise := f.fontFamily, f.currentStyle(), f.fontSizePt, f.textRise
+	inFooter := f.inFooter
+	f.inFooter = true
+	if f.columns.n > 1 {
+		f.lMargin, f.rMargin = f.columns.lMargin, f.columns.rMargin
+	}
+	if rise != 0 {
+		f.SetTextRise(0)
+	}
+	if f.pageBreakTrigger > top {
+		rule := footnoteRule / f.k
+		f.Line(f.lMargin, top+rule/2, f.lMargin+f.footnoteWidth()/3, top+rule/2)
+		f.y = top + rule
+	}
+	for _, note := range notes {
+		lh, indent := f.footnoteLayout(note)
+		if note.lines > 0 && !note.continued {
+			// The number is raised in the same way as by WriteSuperscript()
+			f.SetFontSize(note.sizePt * scriptScale)
+			f.SetTextRise(superscriptRise*note.sizePt - 0.3*(note.sizePt-f.fontSizePt))
+			f.x = f.lMargin
+			f.CellFormat(indent, lh, strconv.Itoa(note.number), "", 0, "L", false, 0, "")
+			f.SetTextRise(0)
+			f.SetFontSize(note.sizePt)
+		}
+		f.x = f.lMargin + indent
+		rest := note.txtStr
+		if note.lines > 0 {
+			rest = f.MultiCellBounded(f.footnoteWidth()-indent, lh, float64(note.lines)*lh, note.txtStr, "", "L", false)
+		}
+		if rest != "" {
+			note.continued = note.continued || rest != note.txtStr
+			note.txtStr = rest
+			fn.pending = append(fn.pending, note)
+		}
+	}
+	f.inFooter = inFooter
+	f.x, f.y, f.lMargin, f.rMargin = x, y, lMargin, rMargin
+	if family != "" {
+		f.SetFont(family, style, size)
+	}
+	if rise != 0 {
+		f.SetTextRise(rise)
+	}
+}
+
+// placePendingFootnotes places the footnotes that are continued from the
+// previous page at the bottom of the current page
+func (f *Fpdf) placePendingFootnotes() {
+	pending := f.footnotes.pending
+	f.footnotes.pending = nil
+	for j, note := range pending {
+		f.placeFootnote(note, f.y, j == 0)
+	}
+}
@@ -0,0 +1,514 @@
+package gofpdf
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Field flags defined by the PDF specification
+const (
+	formFlagReadOnly        = 1 << 0
+	formFlagRequired        = 1 << 1
+	formFlagMultiline       = 1 << 12
+	formFlagPassword        = 1 << 13
+	formFlagNoToggleToOff   = 1 << 14
+	formFlagRadio           = 1 << 15
+	formFlagCombo           = 1 << 17
+	formFlagEdit            = 1 << 18
+	formFlagDoNotSpellCheck = 1 << 22
+)
+
+// FormFieldOptions specifies optional attributes of the interactive form
+// fields created by AddTextField(), AddCheckBox(), AddRadioGroup() and
+// AddComboBox().
+//
+// ReadOnly prevents the user from changing the value of the field. Required
+// indicates that the field must have a value when the form is submitted.
+//
+// Multiline and Password apply only to text fields. Multiline permits the
+// value to span several lines; Password causes the value to be masked.
+// MaxLen, if greater than zero, limits the length of the value of a text
+// field.
+//
+// Editable applies only to combo boxes and permits the user to enter a value
+// that is not in the list of choices.
+//
+// BorderColor and BackgroundColor, if not nil, specify the colors of the
+// border and the background of the field.
+type FormFieldOptions struct {
+	ReadOnly        bool
+	Required        bool
+	Multiline       bool
+	Password        bool
+	MaxLen          int
+	Editable        bool
+	BorderColor     *RGBType
+	BackgroundColor *RGBType
+}
+
+// RadioButtonType specifies one button of a radio group. Value is the export
+// value of the button; it must be unique within the group. X and Y specify
+// the upper left corner of the button and Size its width and height, all in
+// user units.
+type RadioButtonType struct {
+	Value string
+	X, Y  float64
+	Size  float64
+}
+
+type formWidgetType struct {
+	page       int
+	x, y, w, h float64           // fpdf coordinates (y diff and scaling done)
+	state      string            // name of "on" appearance state of buttons
+	ap         map[string][]byte // appearance streams by state; "" for single stream
+	obj        int               // object number, set when written
+}
+
+type formFieldType struct {
+	ft      string // field type: "Tx", "Btn" or "Ch"
+	name    string // partial field name, UTF-16 encoded
+	value   string // value: text string for text and choice, name for buttons
+	flags   int
+	da      string   // default appearance of text and choice fields
+	maxLen  int      // maximum length of text fields
+	opt     []string // choices of combo boxes
+	mk      string   // widget appearance characteristics
+	widgets []formWidgetType
+	obj     int // object number, set when written
+}
+
+type formType struct {
+	fields []formFieldType
+}
+
+// formCheck verifies that a form field can be added
+func (f *Fpdf) formCheck(nameStr string) bool {
+	if f.err != nil {
+		return false
+	}
+	if f.page <= 0 {
+		f.err = fmt.Errorf("a page must be added before a form field")
+	} else if f.currentFont.Name == "" {
+		f.err = fmt.Errorf("font has not been set; unable to add form field")
+	} else if nameStr == "" {
+		f.err = fmt.Errorf("form field name must not be empty")
+	}
+	if f.err == nil && (f.formFieldExists(nameStr) || (f.signature.page > 0 && f.signature.name == nameStr)) {
+		f.err = fmt.Errorf("form field \"%s\" already exists", nameStr)
+	}
+	return f.err == nil
+}
+
+// formFieldExists returns true if a form field named nameStr has been added.
+// Signature fields are not considered.
+func (f *Fpdf) formFieldExists(nameStr string) bool {
+	name := utf8toutf16(nameStr)
+	for _, fld := range f.form.fields {
+		if fld.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// formFlags returns the field flags common to all field types
+func formFlags(options FormFieldOptions) (flags int) {
+	if options.ReadOnly {
+		flags |= formFlagReadOnly
+	}
+	if options.Required {
+		flags |= formFlagRequired
+	}
+	return
+}
+
+// formWidget returns a widget positioned on the current page; the position
+// and size are specified in user units
+func (f *Fpdf) formWidget(x, y, w, h float64) formWidgetType {
+	return formWidgetType{page: f.page, x: x * f.k, y: f.hPt - y*f.k, w: w * f.k, h: h * f.k,
+		ap: make(map[string][]byte)}
+}
+
+// formColorStr returns the operator that sets the fill (opStr "rg") or stroke
+// (opStr "RG") color to clr
+func formColorStr(clr *RGBType, opStr string) string {
+	return sprintf("%.3f %.3f %.3f %s", float64(clr.R)/255, float64(clr.G)/255, float64(clr.B)/255, opStr)
+}
+
+// formMK returns the appearance characteristics dictionary entries for the
+// border and background colors
+func formMK(options FormFieldOptions) (s string) {
+	if clr := options.BorderColor; clr != nil {
+		s += sprintf("/BC [%.3f %.3f %.3f] ", float64(clr.R)/255, float64(clr.G)/255, float64(clr.B)/255)
+	}
+	if clr := options.BackgroundColor; clr != nil {
+		s += sprintf("/BG [%.3f %.3f %.3f] ", float64(clr.R)/255, float64(clr.G)/255, float64(clr.B)/255)
+	}
+	return
+}
+
+// formBox returns the operators that draw the background and border of a
+// rectangular widget of width w and height h points
+func formBox(w, h float64, options FormFieldOptions) (s string) {
+	if clr := options.BackgroundColor; clr != nil {
+		s += sprintf("q %s 0 0 %.2f %.2f re f Q\n", formColorStr(clr, "rg"), w, h)
+	}
+	if clr := options.BorderColor; clr != nil {
+		s += sprintf("q %s 1 w 0.5 0.5 %.2f %.2f re S Q\n", formColorStr(clr, "RG"), w-1, h-1)
+	}
+	return
+}
+
+// formCircle returns a path that approximates a circle with Bézier curves
+func formCircle(cx, cy, r float64) string {
+	const k = 0.5523
+	return sprintf("%.2f %.2f m %.2f %.2f %.2f %.2f %.2f %.2f c %.2f %.2f %.2f %.2f %.2f %.2f c "+
+		"%.2f %.2f %.2f %.2f %.2f %.2f c %.2f %.2f %.2f %.2f %.2f %.2f c h",
+		cx+r, cy,
+		cx+r, cy+k*r, cx+k*r, cy+r, cx, cy+r,
+		cx-k*r, cy+r, cx-r, cy+k*r, cx-r, cy,
+		cx-r, cy-k*r, cx-k*r, cy-r, cx, cy-r,
+		cx+k*r, cy-r, cx+r, cy-k*r, cx+r, cy)
+}
+
+// formText returns txtStr encoded and escaped for the current font
+func (f *Fpdf) formText(txtStr string) string {
+	if f.isCurrentUTF8 {
+		for _, uni := range txtStr {
+			f.currentFont.usedRunes[int(uni)] = int(uni)
+		}
+		return f.escape(utf8toutf16(txtStr, false))
+	}
+	return f.escape(txtStr)
+}
+
+// formTextValue returns txtStr as it is stored in a field value, which is
+// UTF-16 for UTF-8 fonts
+func (f *Fpdf) formTextValue(txtStr string) string {
+	if f.isCurrentUTF8 {
+		return utf8toutf16(txtStr)
+	}
+	return txtStr
+}
+
+// formDA returns the default appearance string of text fields, which
+// selects the current font, size and text color
+func (f *Fpdf) formDA() string {
+	return sprintf("/F%s %.2f Tf %s", f.currentFont.i, f.fontSizePt, f.color.text.str)
+}
+
+// formTextAppearance returns the appearance stream of a text field or combo
+// box of width w and height h points that displays the lines in lines
+func (f *Fpdf) formTextAppearance(w, h float64, lines []string, multiline bool, options FormFieldOptions) []byte {
+	var b fmtBuffer
+	b.printf("%s", formBox(w, h, options))
+	b.printf("/Tx BMC q 1 1 %.2f %.2f re W n BT %s 2 ", w-2, h-2, f.formDA())
+	lineHt := f.fontSizePt * 1.15
+	if multiline {
+		b.printf("%.2f Td %.2f TL", h-2-f.fontSizePt, lineHt)
+	} else {
+		// Same vertical placement as Cell()
+		b.printf("%.2f Td", 0.5*h-0.3*f.fontSizePt)
+	}
+	for j, line := range lines {
+		if j > 0 {
+			b.printf(" T*")
+		}
+		b.printf(" (%s) Tj", f.formText(line))
+	}
+	b.printf(" ET Q EMC")
+	return b.Bytes()
+}
+
+// AddTextField adds an interactive text field named nameStr to the current
+// page. The field occupies the rectangle specified by x, y, w and h in user
+// units and has the initial value valueStr. The text of the field is shown in
+// the current font, font size and text color; these must be set before
+// calling this method. Since embedded UTF-8 fonts only contain the glyphs that
+// are used in the document, a core font is a better choice for fields that
+// the user is expected to edit. The options argument specifies flags and
+// colors of the field; see FormFieldOptions.
+//
+// The AddTextField example demonstrates this method and the other form field
+// methods.
+func (f *Fpdf) AddTextField(nameStr string, x, y, w, h float64, valueStr string, options FormFieldOptions) {
+	if !f.formCheck(nameStr) {
+		return
+	}
+	fld := formFieldType{ft: "Tx", name: utf8toutf16(nameStr), value: f.formTextValue(valueStr)}
+	fld.flags = formFlags(options)
+	if options.Multiline {
+		fld.flags |= formFlagMultiline
+	}
+	if options.Password {
+		fld.flags |= formFlagPassword | formFlagDoNotSpellCheck
+	}
+	fld.da = f.formDA()
+	fld.maxLen = options.MaxLen
+	fld.mk = formMK(options)
+	wd := f.formWidget(x, y, w, h)
+	displayStr := valueStr
+	if options.Password {
+		displayStr = strings.Repeat("*", len([]rune(valueStr)))
+	}
+	var lines []string
+	if options.Multiline {
+		for _, para := range strings.Split(displayStr, "\n") {
+			lines = append(lines, f.SplitText(para, w-4/f.k)...)
+		}
+	} else {
+		lines = []string{displayStr}
+	}
+	wd.ap[""] = f.formTextAppearance(wd.w, wd.h, lines, options.Multiline, options)
+	fld.widgets = []formWidgetType{wd}
+	f.form.fields = append(f.form.fields, fld)
+}
+
+// AddCheckBox adds an interactive check box named nameStr to the current
+// page. The upper left corner of the box is specified by x and y, and its
+// width and height by size, all in user units. checked specifies the initial
+// state of the box. The check mark is drawn in the current text color. The
+// options argument specifies flags and colors of the field; see
+// FormFieldOptions.
+func (f *Fpdf) AddCheckBox(nameStr string, x, y, size float64, checked bool, options FormFieldOptions) {
+	if !f.formCheck(nameStr) {
+		return
+	}
+	fld := formFieldType{ft: "Btn", name: utf8toutf16(nameStr), value: "/Off"}
+	if checked {
+		fld.value = "/Yes"
+	}
+	fld.flags = formFlags(options)
+	fld.mk = formMK(options) + "/CA (4) "
+	wd := f.formWidget(x, y, size, size)
+	wd.state = "/Yes"
+	s := wd.w
+	box := formBox(s, s, options)
+	wd.ap["/Yes"] = []byte(sprintf("%sq %s %s %.2f w 1 J 1 j %.2f %.2f m %.2f %.2f l %.2f %.2f l S Q",
+		box, rgbColorValue(f.color.text.ir, f.color.text.ig, f.color.text.ib, "G", "RG").str, f.color.text.str, s*0.1,
+		s*0.2, s*0.5, s*0.42, s*0.25, s*0.8, s*0.78))
+	wd.ap["/Off"] = []byte(box)
+	fld.widgets = []formWidgetType{wd}
+	f.form.fields = append(f.form.fields, fld)
+}
+
+// AddRadioGroup adds a group of interactive radio buttons named nameStr to
+// the current page. Each element of buttons specifies the position, size and
+// export value of one button. selectedStr is the export value of the button
+// that is initially selected; if it is empty, no button is selected. The
+// selection mark is drawn in the current text color. The options argument
+// specifies flags and colors of the field; see FormFieldOptions.
+func (f *Fpdf) AddRadioGroup(nameStr string, buttons []RadioButtonType, selectedStr string, options FormFieldOptions) {
+	if !f.formCheck(nameStr) {
+		return
+	}
+	if len(buttons) == 0 {
+		f.err = fmt.Errorf("radio group \"%s\" has no buttons", nameStr)
+		return
+	}
+	fld := formFieldType{ft: "Btn", name: utf8toutf16(nameStr), value: "/Off"}
+	fld.flags = formFlags(options) | formFlagRadio | formFlagNoToggleToOff
+	fld.mk = formMK(options) + "/CA (l) "
+	used := make(map[string]bool)
+	for _, btn := range buttons {
+		if btn.Value == "" || used[btn.Value] {
+			f.err = fmt.Errorf("radio button values must be unique and not empty")
+			return
+		}
+		used[btn.Value] = true
+		wd := f.formWidget(btn.X, btn.Y, btn.Size, btn.Size)
+		wd.state = pdfName(btn.Value)
+		if btn.Value == selectedStr {
+			fld.value = wd.state
+		}
+		s := wd.w
+		var ring string
+		if clr := options.BackgroundColor; clr != nil {
+			ring += sprintf("q %s %s f Q\n", formColorStr(clr, "rg"), formCircle(s/2, s/2, s/2))
+		}
+		if clr := options.BorderColor; clr != nil {
+			ring += sprintf("q %s 1 w %s S Q\n", formColorStr(clr, "RG"), formCircle(s/2, s/2, s/2-0.5))
+		}
+		wd.ap[wd.state] = []byte(sprintf("%sq %s %s f Q", ring, f.color.text.str, formCircle(s/2, s/2, s/4)))
+		wd.ap["/Off"] = []byte(ring)
+		fld.widgets = append(fld.widgets, wd)
+	}
+	if selectedStr != "" && fld.value == "/Off" {
+		f.err = fmt.Errorf("radio group \"%s\" has no button with value \"%s\"", nameStr, selectedStr)
+		return
+	}
+	f.form.fields = append(f.form.fields, fld)
+}
+
+// AddComboBox adds an interactive combo box named nameStr to the current
+// page. The field occupies the rectangle specified by x, y, w and h in user
+// units. itemList contains the choices that are presented to the user and
+// valueStr is the initial value. The text of the field is shown in the
+// current font, font size and text color. The options argument specifies
+// flags and colors of the field; see FormFieldOptions.
+func (f *Fpdf) AddComboBox(nameStr string, x, y, w, h float64, itemList []string, valueStr string, options FormFieldOptions) {
+	if !f.formCheck(nameStr) {
+		return
+	}
+	fld := formFieldType{ft: "Ch", name: utf8toutf16(nameStr), value: f.formTextValue(valueStr)}
+	fld.flags = formFlags(options) | formFlagCombo
+	if options.Editable {
+		fld.flags |= formFlagEdit
+	}
+	for _, item := range itemList {
+		fld.opt = append(fld.opt, f.formTextValue(item))
+	}
+	fld.da = f.formDA()
+	fld.mk = formMK(options)
+	wd := f.formWidget(x, y, w, h)
+	wd.ap[""] = f.formTextAppearance(wd.w, wd.h, []string{valueStr}, false, options)
+	fld.widgets = []formWidgetType{wd}
+	f.form.fields = append(f.form.fields, fld)
+}
+
+// putFormAppearance writes an appearance stream of a widget and returns its
+// object number
+func (f *Fpdf) putFormAppearance(wd formWidgetType, stream []byte) int {
+	f.newobj()
+	f.outf("<</Type /XObject /Subtype /Form /BBox [0 0 %.2f %.2f] /Resources 2 0 R /Length %d>>",
+		wd.w, wd.h, f.protect.streamLength(len(stream)))
+	f.putstream(stream)
+	f.out("endobj")
+	return f.n
+}
+
+// putFormWidgetEntries writes the entries of a widget annotation dictionary
+func (f *Fpdf) putFormWidgetEntries(wd formWidgetType, apObjs map[string]int, mk string) {
+	f.outf("/Type /Annot /Subtype /Widget /F 4 /Rect [%.2f %.2f %.2f %.2f]", wd.x, wd.y-wd.h, wd.x+wd.w, wd.y)
+	if mk != "" {
+		f.outf("/MK <<%s>>", mk)
+	}
+	if obj, ok := apObjs[""]; ok {
+		f.outf("/AP <</N %d 0 R>>", obj)
+	} else {
+		var states []string
+		for _, st := range []string{wd.state, "/Off"} {
+			states = append(states, sprintf("%s %d 0 R", st, apObjs[st]))
+		}
+		f.outf("/AP <</N <<%s>>>>", strings.Join(states, " "))
+	}
+}
+
+// putFormFields writes the form field objects along with their widgets and
+// appearance streams. It is called before the pages are written so that the
+// widgets can be included in the annotations of their pages.
+func (f *Fpdf) putFormFields() {
+	for j := range f.form.fields {
+		fld := &f.form.fields[j]
+		apObjs := make([]map[string]int, len(fld.widgets))
+		for k, wd := range fld.widgets {
+			apObjs[k] = make(map[string]int)
+			for _, st := range []string{"", wd.state, "/Off"} {
+				if stream, ok := wd.ap[st]; ok {
+					if _, done := apObjs[k][st]; !done {
+						apObjs[k][st] = f.putFormAppearance(wd, stream)
+					}
+				}
+			}
+		}
+		radio := fld.flags&formFlagRadio != 0
+		f.newobj()
+		fld.obj = f.n
+		f.outf("<</FT /%s /T %s /Ff %d", fld.ft, f.textstring(fld.name), fld.flags)
+		if fld.ft == "Btn" {
+			f.outf("/V %s", fld.value)
+		} else {
+			f.outf("/V %s /DV %s", f.textstring(fld.value), f.textstring(fld.value))
+		}
+		if fld.da != "" {
+			f.outf("/DA %s", f.textstring(fld.da))
+		}
+		if fld.maxLen > 0 {
+			f.outf("/MaxLen %d", fld.maxLen)
+		}
+		if len(fld.opt) > 0 {
+			opt := make([]string, len(fld.opt))
+			for k, item := range fld.opt {
+				opt[k] = f.textstring(item)
+			}
+			f.outf("/Opt [%s]", strings.Join(opt, " "))
+		}
+		if radio {
+			// The buttons are kids of the field and follow it directly
+			var kids []string
+			for k := range fld.widgets {
+				kids = append(kids, sprintf("%d 0 R", fld.obj+1+k))
+			}
+			f.outf("/Kids [%s]>>", strings.Join(kids, " "))
+			f.out("endobj")
+			for k, wd := range fld.widgets {
+				f.newobj()
+				fld.widgets[k].obj = f.n
+				f.outf("<</Parent %d 0 R", fld.obj)
+				f.putFormWidgetEntries(wd, apObjs[k], fld.mk)
+				state := "/Off"
+				if wd.state == fld.value {
+					state = wd.state
+				}
+				f.outf("/AS %s>>", state)
+				f.out("endobj")
+			}
+		} else {
+			wd := fld.widgets[0]
+			fld.widgets[0].obj = fld.obj
+			f.putFormWidgetEntries(wd, apObjs[0], fld.mk)
+			if fld.ft == "Btn" {
+				f.outf("/AS %s", fld.value)
+			}
+			f.out(">>")
+			f.out("endobj")
+		}
+	}
+}
+
+// putFormWidgetRefs adds references to the form widgets on the specified page
+// to the annotations of the page
+func (f *Fpdf) putFormWidgetRefs(out *fmtBuffer, page int) {
+	for _, fld := range f.form.fields {
+		for _, wd := range fld.widgets {
+			if wd.page == page {
+				out.printf("%d 0 R ", wd.obj)
+			}
+		}
+	}
+	if f.signature.fieldObj > 0 && f.signature.page == page {
+		out.printf("%d 0 R ", f.signature.fieldObj)
+	}
+}
+
+// formWidgetCount returns the number of form widgets on the specified page
+func (f *Fpdf) formWidgetCount(page int) (count int) {
+	for _, fld := range f.form.fields {
+		for _, wd := range fld.widgets {
+			if wd.page == page {
+				count++
+			}
+		}
+	}
+	if f.signature.fieldObj > 0 && f.signature.page == page {
+		count++
+	}
+	return
+}
+
+// formPutCatalog writes the interactive form entry of the catalog
+func (f *Fpdf) formPutCatalog() {
+	if len(f.form.fields) == 0 && f.signature.fieldObj == 0 {
+		return
+	}
+	var refs []string
+	for _, fld := range f.form.fields {
+		refs = append(refs, sprintf("%d 0 R", fld.obj))
+	}
+	sigFlags := ""
+	if f.signature.fieldObj > 0 {
+		refs = append(refs, sprintf("%d 0 R", f.signature.fieldObj))
+		sigFlags = " /SigFlags 3"
+	}
+	f.outf("/AcroForm <</Fields [%s] /DR 2 0 R%s>>", strings.Join(refs, " "), sigFlags)
+}
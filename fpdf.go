@@ -23,7 +23,7 @@ package gofpdf
 
 import (
 	"bytes"
-	"crypto/md5"
+	"compress/zlib"
 	"encoding/binary"
 	hex "encoding/hex"
 	"encoding/json"
@@ -80,6 +80,9 @@ func fpdfNew(orientationStr, unitStr, sizeStr, fontDirStr string, size SizeType)
 	f.pages = make([]*bytes.Buffer, 0, 8)
 	f.pages = append(f.pages, bytes.NewBufferString("")) // pages[0] is unused (1-based)
 	f.pageSizes = make(map[int]SizeType)
+	f.pageRotations = make(map[int]int)
+	f.autoPageHeights = make(map[int]float64)
+	f.pageSections = make(map[int]int)
 	f.pageBoxes = make(map[int]map[string]PageBox)
 	f.defPageBoxes = make(map[string]PageBox)
 	f.state = 0
@@ -93,6 +96,8 @@ func fpdfNew(orientationStr, unitStr, sizeStr, fontDirStr string, size SizeType)
 	f.importedTplObjs = make(map[string]string)
 	f.importedTplIDs = make(map[string]int, 0)
 	f.images = make(map[string]*ImageInfoType)
+	f.imagesUsed = make(map[string]bool)
+	f.imagesInline = make(map[string]bool)
 	f.pageLinks = make([][]linkType, 0, 8)
 	f.pageLinks = append(f.pageLinks, make([]linkType, 0, 0)) // pageLinks[0] is unused (1-based)
 	f.links = make([]intLinkType, 0, 8)
@@ -194,12 +199,14 @@ func fpdfNew(orientationStr, unitStr, sizeStr, fontDirStr string, size SizeType)
 	}
 	// Enable compression
 	f.SetCompression(!gl.noCompress)
+	f.compressLevel = zlib.BestSpeed
 	f.spotColorMap = make(map[string]spotColorType)
 	f.blendList = make([]blendModeType, 0, 8)
 	f.blendList = append(f.blendList, blendModeType{}) // blendList[0] is unused (1-based)
 	f.blendMap = make(map[string]int)
 	f.blendMode = "Normal"
 	f.alpha = 1
+	f.miterLimit = 10
 	f.gradientList = make([]gradientType, 0, 8)
 	f.gradientList = append(f.gradientList, gradientType{}) // gradientList[0] is unused
 	// Set default PDF version number
@@ -318,6 +325,7 @@ func (f *Fpdf) GetMargins() (left, top, right, bottom float64) {
 // cm. Call this method to change them. If the value of the right margin is
 // less than zero, it is set to the same as the left margin.
 func (f *Fpdf) SetMargins(left, top, right float64) {
+	f.mirror = mirrorType{}
 	f.lMargin = left
 	f.tMargin = top
 	if right < 0 {
@@ -353,7 +361,8 @@ func (f *Fpdf) SetCellMargin(margin float64) {
 // SetPageBoxRec sets the page box for the current page, and any following
 // pages. Allowable types are trim, trimbox, crop, cropbox, bleed, bleedbox,
 // art and artbox box types are case insensitive. See SetPageBox() for a method
-// that specifies the coordinates and extent of the page box individually.
+// that specifies the coordinates and extent of the page box individually, and
+// SetPageBoxes() for a method that sets all the boxes of any page.
 func (f *Fpdf) SetPageBoxRec(t string, pb PageBox) {
 	switch strings.ToLower(t) {
 	case "trim":
@@ -398,10 +407,13 @@ func (f *Fpdf) SetPageBox(t string, x, y, wd, ht float64) {
 }
 
 // SetPage sets the current page to that of a valid page in the PDF document.
-// pageNum is one-based. The SetPage() example demonstrates this method.
+// pageNum is one-based. Positions on the page are relative to its own size and
+// orientation. Content added to the page continues with the graphics state
+// that its content ends with; SetPageForWriting() also applies the settings
+// of the document to the page. The SetPage() example demonstrates this method.
 func (f *Fpdf) SetPage(pageNum int) {
 	if (pageNum > 0) && (pageNum < len(f.pages)) {
-		f.page = pageNum
+		f.selectPage(pageNum)
 	}
 }
 
@@ -507,7 +519,7 @@ func (f *Fpdf) GetAutoPageBreak() (auto bool, margin float64) {
 func (f *Fpdf) SetAutoPageBreak(auto bool, margin float64) {
 	f.autoPageBreak = auto
 	f.bMargin = margin
-	f.pageBreakTrigger = f.h - margin
+	f.pageBreakTrigger = f.h - margin - f.footnotes.height
 }
 
 // SetDisplayMode sets advisory display directives for the document viewer.
@@ -568,6 +580,53 @@ func (f *Fpdf) SetCompression(compress bool) {
 	f.compress = compress
 }
 
+// SetCompressionLevel sets the level of the zlib compression of the document,
+// from zlib.BestSpeed (1), the default, which suits documents that are
+// generated on the fly, to zlib.BestCompression (9), which suits documents
+// that are archived. zlib.DefaultCompression (-1) and zlib.HuffmanOnly (-2)
+// are accepted as well. The level applies to pages, templates, fonts, images
+// and attachments that are compressed by this package, unless a stream
+// filter has been set with SetStreamFilter(). Compression itself is turned on
+// and off with SetCompression().
+//
+// Since the level is set to make documents smaller, the document also ends
+// with the cross-reference and object streams of PDF 1.5, as described for
+// SetPDFVersion(), and its version is raised to 1.5 if it is lower, unless a
+// lower version is set afterwards.
+//
+// The SetCompressionLevel example demonstrates this method.
+func (f *Fpdf) SetCompressionLevel(level int) {
+	if level < zlib.HuffmanOnly || level > zlib.BestCompression {
+		f.err = fmt.Errorf("invalid compression level %d", level)
+		return
+	}
+	f.compressLevel = level
+	f.xrefStream = true
+}
+
+// StreamFilter encodes the streams of a document in place of the built-in
+// zlib compression, for example with a faster or better deflate
+// implementation. It is set with SetStreamFilter().
+type StreamFilter interface {
+	// Name returns the name of the PDF filter that decodes the streams
+	// encoded by Encode(), such as "FlateDecode".
+	Name() string
+	// Encode returns data encoded.
+	Encode(data []byte) ([]byte, error)
+}
+
+// SetStreamFilter specifies the encoder of the streams of the document that
+// are compressed when compression is on: the content of pages and templates,
+// imported pages, palettes and object streams. The streams of fonts, images
+// and attachments, which are decoded with FlateDecode, are compressed with
+// zlib at the level set with SetCompressionLevel(). A nil filter restores the
+// built-in zlib compression.
+//
+// The SetCompressionLevel example demonstrates this method.
+func (f *Fpdf) SetStreamFilter(filter StreamFilter) {
+	f.streamFilter = filter
+}
+
 // SetProducer defines the producer of the document. isUTF8 indicates if the string
 // is encoded in ISO-8859-1 (false) or UTF-8 (true).
 func (f *Fpdf) SetProducer(producerStr string, isUTF8 bool) {
@@ -623,24 +682,72 @@ func (f *Fpdf) SetCreator(creatorStr string, isUTF8 bool) {
 	f.creator = creatorStr
 }
 
+// SetCustomInfo sets the entry keyStr of the document information dictionary
+// to valueStr, which is encoded in UTF-8, so that information such as
+// workflow identifiers or case numbers can be stored with the document. An
+// empty valueStr removes the entry. The standard entries, such as Title and
+// Author, are set with the corresponding methods instead, such as SetTitle()
+// and SetAuthor().
+func (f *Fpdf) SetCustomInfo(keyStr, valueStr string) {
+	if f.err != nil {
+		return
+	}
+	switch keyStr {
+	case "":
+		f.err = fmt.Errorf("an entry of the document information needs a key")
+		return
+	case "Title", "Author", "Subject", "Keywords", "Creator", "Producer", "CreationDate", "ModDate":
+		f.err = fmt.Errorf("%s is a standard entry of the document information", keyStr)
+		return
+	}
+	if valueStr == "" {
+		delete(f.customInfo, keyStr)
+		return
+	}
+	if f.customInfo == nil {
+		f.customInfo = make(map[string]string)
+	}
+	f.customInfo[keyStr] = valueStr
+}
+
+// GetCustomInfo returns the value of the entry keyStr of the document
+// information dictionary set with SetCustomInfo(), or an empty string if the
+// entry has not been set.
+func (f *Fpdf) GetCustomInfo(keyStr string) string {
+	return f.customInfo[keyStr]
+}
+
 // SetXmpMetadata defines XMP metadata that will be embedded with the document.
+// It takes the place of the metadata generated for PDF/A or as set with
+// SetAutoXmpMetadata().
 func (f *Fpdf) SetXmpMetadata(xmpStream []byte) {
 	f.xmp = xmpStream
 }
 
 // SetPDFVersion updates the pdf version that will be embedded with the document.
+// Documents of version 1.5 or later end with a cross-reference stream instead
+// of a cross-reference table, and the objects that are not streams, such as
+// the dictionaries of pages, fonts and annotations, are packed into
+// compressed object streams, which makes documents with many pages or
+// annotations considerably smaller. Objects are not packed in encrypted or
+// signed documents.
 func (f *Fpdf) SetPDFVersion(version string) {
 	f.pdfVersion = version
+	f.xrefStream = version >= "1.5"
 }
 
-// SetPDFVersion updates the pdf version that will be embedded with the document.
+// SetOutputIntent sets whether the document has an output intent: the built-in
+// sRGB profile, or the ICC profile set with SetOutputIntentProfile(), that
+// describes the device the colors of the document are meant for. PDF/A
+// documents always have one.
 func (f *Fpdf) SetOutputIntent(addOutputIntent bool) {
 	f.addOutputIntent = addOutputIntent
 }
 
 // AliasNbPages defines an alias for the total number of pages. It will be
 // substituted as the document is closed. An empty string is replaced with the
-// string "{nb}".
+// string "{nb}". AliasCounter() and AliasCounterFormat() define aliases for
+// the number of pages of sections and for formatted numbers.
 //
 // See the example for AddPage() for a demonstration of this method.
 func (f *Fpdf) AliasNbPages(aliasStr string) {
@@ -675,6 +782,12 @@ func (f *Fpdf) Close() {
 			f.err = fmt.Errorf("clip procedure must be explicitly ended")
 		} else if f.transformNest > 0 {
 			f.err = fmt.Errorf("transformation procedure must be explicitly ended")
+		} else if f.gStateCount() > 0 {
+			f.err = fmt.Errorf("saved graphics state must be explicitly restored")
+		} else if f.softMaskCanvas != nil {
+			f.err = fmt.Errorf("soft mask must be explicitly ended")
+		} else if len(f.groupCanvases) > 0 {
+			f.err = fmt.Errorf("transparency group must be explicitly ended")
 		}
 	}
 	if f.err != nil {
@@ -689,19 +802,32 @@ func (f *Fpdf) Close() {
 			return
 		}
 	}
+	// The footer is printed on the page being written
+	f.resumeOpenPage()
+	// Footnotes that do not fit on the last page need further pages
+	f.putFootnotes()
+	for len(f.footnotes.pending) > 0 && f.err == nil {
+		f.AddPage()
+		f.putFootnotes()
+	}
 	// Page footer
-	f.inFooter = true
-	if f.footerFnc != nil {
-		f.footerFnc()
-	} else if f.footerFncLpi != nil {
-		f.footerFncLpi(true)
+	if !f.pageClosed {
+		f.inFooter = true
+		if f.footerFnc != nil {
+			f.footerFnc()
+		} else if f.footerFncLpi != nil {
+			f.footerFncLpi(true)
+		}
+		f.inFooter = false
 	}
-	f.inFooter = false
 
 	// Close page
 	f.endpage()
 	// Close document
 	f.enddoc()
+	if f.outStream != nil && f.err == nil {
+		f.flushOutput()
+	}
 	return
 }
 
@@ -732,9 +858,7 @@ func (f *Fpdf) AddPageFormat(orientationStr string, size SizeType) {
 	if f.err != nil {
 		return
 	}
-	if f.page != len(f.pages)-1 {
-		f.page = len(f.pages) - 1
-	}
+	f.resumeOpenPage()
 	if f.state == 0 {
 		f.open()
 	}
@@ -748,12 +872,20 @@ func (f *Fpdf) AddPageFormat(orientationStr string, size SizeType) {
 	}
 	fontsize := f.fontSizePt
 	lw := f.lineWidth
+	cs, wsp, rise := f.charSpacing, f.wordSpacing, f.textRise
 	dc := f.color.draw
 	fc := f.color.fill
 	tc := f.color.text
 	cf := f.colorFlag
+	// The content of a block is moved to the new page
+	block := f.cutBlock()
+	// Headers and footers are printed with the page margins
+	if f.columns.n > 1 {
+		f.lMargin, f.rMargin = f.columns.lMargin, f.columns.rMargin
+	}
+	f.putFootnotes()
 
-	if f.page > 0 {
+	if f.page > 0 && !f.pageClosed {
 		f.inFooter = true
 		// Page footer avoid double call on footer.
 		if f.footerFnc != nil {
@@ -766,13 +898,31 @@ func (f *Fpdf) AddPageFormat(orientationStr string, size SizeType) {
 		// Close page
 		f.endpage()
 	}
+	// The new page follows the last page, unless InsertPageAfter() places it
+	// elsewhere
+	f.insertedPage = 0
+	if f.page != len(f.pages)-1 {
+		f.page = len(f.pages) - 1
+	}
+	// Mirrored margins alternate from page to page
+	position := f.page + 1
+	if f.insertAt > 0 {
+		position = f.insertAt
+	}
+	f.mirrorMargins(position)
 	// Start new page
 	f.beginpage(orientationStr, size)
+	f.pageSections[f.page] = f.sectionNo
+	f.placeNewPage()
+	f.putWatermarks(WatermarkUnder)
 	// 	Set line cap style to current value
 	// f.out("2 J")
 	f.outf("%d J", f.capStyle)
 	// 	Set line join style to current value
 	f.outf("%d j", f.joinStyle)
+	if f.miterLimit != 10 {
+		f.outf("%.2f M", f.miterLimit)
+	}
 	// Set line width
 	f.lineWidth = lw
 	f.outf("%.2f w", lw*f.k)
@@ -798,6 +948,20 @@ func (f *Fpdf) AddPageFormat(orientationStr string, size SizeType) {
 	}
 	f.color.text = tc
 	f.colorFlag = cf
+	// Set overprint settings
+	if f.overprint != (overprintType{}) {
+		f.outputOverprint()
+	}
+	// Set character and word spacing
+	if cs != 0 {
+		f.SetCharSpacing(cs)
+	}
+	if wsp != 0 {
+		f.SetWordSpacing(wsp)
+	}
+	if rise != 0 {
+		f.SetTextRise(rise)
+	}
 	// 	Page header
 	if f.headerFnc != nil {
 		f.inHeader = true
@@ -819,6 +983,16 @@ func (f *Fpdf) AddPageFormat(orientationStr string, size SizeType) {
 			return
 		}
 	}
+	// Restore character and word spacing
+	if f.charSpacing != cs {
+		f.SetCharSpacing(cs)
+	}
+	if f.wordSpacing != wsp {
+		f.SetWordSpacing(wsp)
+	}
+	if f.textRise != rise {
+		f.SetTextRise(rise)
+	}
 	// Restore colors
 	if f.color.draw.str != dc.str {
 		f.color.draw = dc
@@ -830,6 +1004,14 @@ func (f *Fpdf) AddPageFormat(orientationStr string, size SizeType) {
 	}
 	f.color.text = tc
 	f.colorFlag = cf
+	// Footnotes continue at the bottom of the new page
+	f.placePendingFootnotes()
+	// Columns start again below the header
+	if f.columns.n > 1 {
+		f.columns.top = f.y
+		f.setColumn(0)
+	}
+	f.pasteBlock(block)
 	return
 }
 
@@ -852,7 +1034,11 @@ func (f *Fpdf) AddPage() {
 		return
 	}
 	// dbg("AddPage")
-	f.AddPageFormat(f.defOrientation, f.defPageSize)
+	if f.pageFormat.orientation != "" {
+		f.AddPageFormat(f.pageFormat.orientation, f.pageFormat.size)
+	} else {
+		f.AddPageFormat(f.defOrientation, f.defPageSize)
+	}
 	return
 }
 
@@ -960,8 +1146,19 @@ func (f *Fpdf) GetStringWidth(s string) float64 {
 	if f.err != nil {
 		return 0
 	}
-	w := f.GetStringSymbolWidth(s)
-	return float64(w) * f.fontSize / 1000
+	w := float64(f.GetStringSymbolWidth(s))
+	if f.charSpacing != 0 || f.wordSpacing != 0 || f.smallCaps {
+		if f.isCurrentUTF8 {
+			for _, c := range s {
+				w += f.extraWidth(c)
+			}
+		} else {
+			for _, c := range []byte(s) {
+				w += f.extraWidth(rune(c))
+			}
+		}
+	}
+	return w * f.fontSize / 1000
 }
 
 // GetStringSymbolWidth returns the length of a string in glyf units. A font must be
@@ -973,9 +1170,14 @@ func (f *Fpdf) GetStringSymbolWidth(s string) int {
 	w := 0
 	if f.isCurrentUTF8 {
 		unicode := []rune(s)
-		for _, char := range unicode {
+		for j, char := range unicode {
+			if j > 0 {
+				w += f.kern(unicode[j-1], char)
+			}
 			intChar := int(char)
-			if len(f.currentFont.Cw) >= intChar && f.currentFont.Cw[intChar] > 0 {
+			if fw, ok := f.fallbackWidth(char); ok {
+				w += fw
+			} else if len(f.currentFont.Cw) >= intChar && f.currentFont.Cw[intChar] > 0 {
 				if f.currentFont.Cw[intChar] != 65535 {
 					w += f.currentFont.Cw[intChar]
 				}
@@ -996,6 +1198,17 @@ func (f *Fpdf) GetStringSymbolWidth(s string) int {
 	return w
 }
 
+// tableWidth returns the width of c in the width table cw of the current
+// font, or the missing width of the font if c lies beyond the table, as
+// characters outside the Basic Multilingual Plane and, with core fonts,
+// characters outside cp1252 do
+func (f *Fpdf) tableWidth(cw []int, c rune) int {
+	if c >= 0 && int(c) < len(cw) {
+		return cw[c]
+	}
+	return f.currentFont.Desc.MissingWidth
+}
+
 // SetLineWidth defines the line width. By default, the value equals 0.2 mm.
 // The method can be called before the first page is created. The value is
 // retained from page to page.
@@ -1020,16 +1233,7 @@ func (f *Fpdf) GetLineWidth() float64 {
 // method can be called before the first page is created. The value is
 // retained from page to page.
 func (f *Fpdf) SetLineCapStyle(styleStr string) {
-	var capStyle int
-	switch styleStr {
-	case "round":
-		capStyle = 1
-	case "square":
-		capStyle = 2
-	default:
-		capStyle = 0
-	}
-	f.capStyle = capStyle
+	f.capStyle = lineCapStyle(styleStr)
 	if f.page > 0 {
 		f.outf("%d J", f.capStyle)
 	}
@@ -1039,21 +1243,61 @@ func (f *Fpdf) SetLineCapStyle(styleStr string) {
 // "round" or "bevel". The method can be called before the first page
 // is created. The value is retained from page to page.
 func (f *Fpdf) SetLineJoinStyle(styleStr string) {
-	var joinStyle int
+	f.joinStyle = lineJoinStyle(styleStr)
+	if f.page > 0 {
+		f.outf("%d j", f.joinStyle)
+	}
+}
+
+// lineCapStyle returns the number of the line cap style styleStr
+func lineCapStyle(styleStr string) int {
 	switch styleStr {
 	case "round":
-		joinStyle = 1
+		return 1
+	case "square":
+		return 2
+	}
+	return 0
+}
+
+// lineJoinStyle returns the number of the line join style styleStr
+func lineJoinStyle(styleStr string) int {
+	switch styleStr {
+	case "round":
+		return 1
 	case "bevel":
-		joinStyle = 2
-	default:
-		joinStyle = 0
+		return 2
 	}
-	f.joinStyle = joinStyle
+	return 0
+}
+
+// SetMiterLimit sets the miter limit, which bevels the miter joins of lines
+// whose length, divided by the line width, would be greater than limit. Lines
+// that meet at a sharp angle have long miter joins; the default limit of 10
+// bevels the joins of lines that meet at an angle of less than about 11
+// degrees. An error occurs if limit is less than 1. The method can be called
+// before the first page is created. The value is retained from page to page.
+//
+// The SaveGState example demonstrates this method.
+func (f *Fpdf) SetMiterLimit(limit float64) {
+	if f.err != nil {
+		return
+	}
+	if limit < 1 {
+		f.err = fmt.Errorf("miter limit must be at least 1: %.2f", limit)
+		return
+	}
+	f.miterLimit = limit
 	if f.page > 0 {
-		f.outf("%d j", f.joinStyle)
+		f.outf("%.2f M", limit)
 	}
 }
 
+// GetMiterLimit returns the current miter limit.
+func (f *Fpdf) GetMiterLimit() float64 {
+	return f.miterLimit
+}
+
 // SetDashPattern sets the dash pattern that is used to draw lines. The
 // dashArray elements are numbers that specify the lengths, in units
 // established in New(), of alternating dashes and gaps. The dash phase
@@ -1078,18 +1322,24 @@ func (f *Fpdf) SetDashPattern(dashArray []float64, dashPhase float64) {
 }
 
 func (f *Fpdf) outputDashPattern() {
+	f.outbuf(dashPatternBuffer(f.dashArray, f.dashPhase))
+}
+
+// dashPatternBuffer returns the operator that sets the dash pattern
+// dashArray, with the dash phase dashPhase, in points
+func dashPatternBuffer(dashArray []float64, dashPhase float64) *bytes.Buffer {
 	var buf bytes.Buffer
 	buf.WriteByte('[')
-	for i, value := range f.dashArray {
+	for i, value := range dashArray {
 		if i > 0 {
 			buf.WriteByte(' ')
 		}
 		buf.WriteString(strconv.FormatFloat(value, 'f', 2, 64))
 	}
 	buf.WriteString("] ")
-	buf.WriteString(strconv.FormatFloat(f.dashPhase, 'f', 2, 64))
+	buf.WriteString(strconv.FormatFloat(dashPhase, 'f', 2, 64))
 	buf.WriteString(" d")
-	f.outbuf(&buf)
+	return &buf
 }
 
 // Line draws a line between points (x1, y1) and (x2, y2) using the current
@@ -1171,6 +1421,69 @@ func (f *Fpdf) RoundedRectExt(x, y, w, h, rTL, rTR, rBR, rBL float64, stylestr s
 	f.out(fillDrawOp(stylestr))
 }
 
+// BorderStyle holds the settings with which RoundedRectEx() draws and fills a
+// rectangle, in place of the current settings of the document. Zero values
+// and nil colors leave the current settings in effect.
+type BorderStyle struct {
+	Width     float64   // line width of the border
+	Color     *RGBType  // draw color of the border
+	FillColor *RGBType  // fill color of the rectangle
+	Dash      []float64 // dash pattern of the border, as for SetDashPattern()
+	DashPhase float64
+}
+
+// RoundedRectEx outputs a rectangle of width w and height h with the upper
+// left corner positioned at point (x, y), like RoundedRectExt(), with the
+// radii of its upper left, upper right, lower right and lower left corners
+// in radii, and the line width, colors and dash pattern of border. A zero
+// radius means a squared corner. Radii that do not fit in the rectangle are
+// reduced in proportion, as in CSS. styleStr is the same as for Rect(). The
+// settings of the document are not changed. An error occurs if a radius is
+// negative.
+//
+// The RoundedRectEx example demonstrates this method.
+func (f *Fpdf) RoundedRectEx(x, y, w, h float64, radii [4]float64, styleStr string, border BorderStyle) {
+	if f.err != nil {
+		return
+	}
+	// scale is the factor by which the radii are reduced, if they overlap
+	scale := 1.0
+	for j, r := range radii {
+		if r < 0 {
+			f.err = fmt.Errorf("corner radius is negative: %.2f", r)
+			return
+		}
+		side := w
+		if j%2 == 1 {
+			side = h
+		}
+		if sum := r + radii[(j+1)%4]; sum > side {
+			scale = math.Min(scale, side/sum)
+		}
+	}
+	var buf fmtBuffer
+	buf.printf("q")
+	if border.Width > 0 {
+		buf.printf(" %.2f w", border.Width*f.k)
+	}
+	if border.Color != nil {
+		buf.printf(" %s", rgbColorValue(border.Color.R, border.Color.G, border.Color.B, "G", "RG").str)
+	}
+	if border.FillColor != nil {
+		buf.printf(" %s", rgbColorValue(border.FillColor.R, border.FillColor.G, border.FillColor.B, "g", "rg").str)
+	}
+	if len(border.Dash) > 0 {
+		dash := make([]float64, len(border.Dash))
+		for j, value := range border.Dash {
+			dash[j] = value * f.k
+		}
+		buf.printf(" %s", dashPatternBuffer(dash, border.DashPhase*f.k).String())
+	}
+	f.out(buf.String())
+	f.roundedRectPath(x, y, w, h, radii[0]*scale, radii[1]*scale, radii[2]*scale, radii[3]*scale)
+	f.outf("%s Q", fillDrawOp(styleStr))
+}
+
 // Circle draws a circle centered on point (x, y) with radius r.
 //
 // styleStr can be "F" for filled, "D" for outlined only, or "DF" or "FD" for
@@ -1357,33 +1670,44 @@ func (f *Fpdf) SetAlpha(alpha float64, blendModeStr string) {
 	if f.err != nil {
 		return
 	}
-	var bl blendModeType
+	pos := f.blendState(alpha, blendModeStr)
+	if pos == 0 {
+		return
+	}
+	f.alpha = alpha
+	f.blendMode = blendModeStr
+	f.outf("/GS%d gs", pos)
+}
+
+// blendState returns the number of the graphics state with the alpha value
+// alpha and the blend mode blendModeStr, which is added if the document does
+// not have it yet, or 0 if an error occurs
+func (f *Fpdf) blendState(alpha float64, blendModeStr string) int {
+	var modeStr string
 	switch blendModeStr {
 	case "Normal", "Multiply", "Screen", "Overlay",
 		"Darken", "Lighten", "ColorDodge", "ColorBurn", "HardLight", "SoftLight",
 		"Difference", "Exclusion", "Hue", "Saturation", "Color", "Luminosity":
-		bl.modeStr = blendModeStr
+		modeStr = blendModeStr
 	case "":
-		bl.modeStr = "Normal"
+		modeStr = "Normal"
 	default:
 		f.err = fmt.Errorf("unrecognized blend mode \"%s\"", blendModeStr)
-		return
+		return 0
 	}
 	if alpha < 0.0 || alpha > 1.0 {
 		f.err = fmt.Errorf("alpha value (0.0 - 1.0) is out of range: %.3f", alpha)
-		return
+		return 0
 	}
-	f.alpha = alpha
-	f.blendMode = blendModeStr
 	alphaStr := sprintf("%.3f", alpha)
-	keyStr := sprintf("%s %s", alphaStr, blendModeStr)
+	keyStr := sprintf("%s %s", alphaStr, modeStr)
 	pos, ok := f.blendMap[keyStr]
 	if !ok {
 		pos = len(f.blendList) // at least 1
-		f.blendList = append(f.blendList, blendModeType{alphaStr, alphaStr, blendModeStr, 0})
+		f.blendList = append(f.blendList, blendModeType{alphaStr, alphaStr, modeStr, 0})
 		f.blendMap[keyStr] = pos
 	}
-	f.outf("/GS%d gs", pos)
+	return pos
 }
 
 func (f *Fpdf) gradientClipStart(x, y, w, h float64) {
@@ -1402,8 +1726,8 @@ func (f *Fpdf) gradient(tp, r1, g1, b1, r2, g2, b2 int, x1, y1, x2, y2, r float6
 	pos := len(f.gradientList)
 	clr1 := rgbColorValue(r1, g1, b1, "", "")
 	clr2 := rgbColorValue(r2, g2, b2, "", "")
-	f.gradientList = append(f.gradientList, gradientType{tp, clr1.str, clr2.str,
-		x1, y1, x2, y2, r, 0})
+	f.gradientList = append(f.gradientList, gradientType{tp: tp, clr1Str: clr1.str, clr2Str: clr2.str,
+		x1: x1, y1: y1, x2: x2, y2: y2, r: r})
 	f.outf("/Sh%d sh", pos)
 }
 
@@ -1463,7 +1787,7 @@ func (f *Fpdf) RadialGradient(x, y, w, h float64, r1, g1, b1, r2, g2, b2 int, x1
 //
 // This ClipText() example demonstrates this method.
 func (f *Fpdf) ClipRect(x, y, w, h float64, outline bool) {
-	f.clipNest++
+	f.clipBegin()
 	f.outf("q %.2f %.2f %.2f %.2f re W %s", x*f.k, (f.h-y)*f.k, w*f.k, -h*f.k, strIf(outline, "S", "n"))
 }
 
@@ -1475,9 +1799,19 @@ func (f *Fpdf) ClipRect(x, y, w, h float64, outline bool) {
 // will be shown. After calling this method, all rendering operations (for
 // example, Image(), LinearGradient(), etc) will be clipped. Call ClipEnd() to
 // restore unclipped operations.
+//
+// The text is printed like Text() does, with UTF-8 fonts, kerning and
+// character and word spacing, so that images or gradients can fill headlines
+// in any font.
 func (f *Fpdf) ClipText(x, y float64, txtStr string, outline bool) {
-	f.clipNest++
-	f.outf("q BT %.5f %.5f Td %d Tr (%s) Tj ET", x*f.k, (f.h-y)*f.k, intIf(outline, 5, 7), f.escape(txtStr))
+	if f.isCurrentUTF8 {
+		txtStr = f.visualText(txtStr)
+		if f.isRTL {
+			x -= f.GetStringWidth(txtStr)
+		}
+	}
+	f.clipBegin()
+	f.outf("q BT %.5f %.5f Td %d Tr %s ET", x*f.k, (f.h-y)*f.k, intIf(outline, 5, 7), f.showText(txtStr))
 }
 
 func (f *Fpdf) clipArc(x1, y1, x2, y2, x3, y3 float64) {
@@ -1505,7 +1839,8 @@ func (f *Fpdf) ClipRoundedRect(x, y, w, h, r float64, outline bool) {
 // rBR (bottom-right), rBL (bottom-left). See ClipRoundedRect() for more
 // details. This method is demonstrated in the ClipText() example.
 func (f *Fpdf) ClipRoundedRectExt(x, y, w, h, rTL, rTR, rBR, rBL float64, outline bool) {
-	f.clipNest++
+	f.clipBegin()
+	f.out("q")
 	f.roundedRectPath(x, y, w, h, rTL, rTR, rBR, rBL)
 	f.outf(" W %s", strIf(outline, "S", "n"))
 }
@@ -1517,7 +1852,7 @@ func (f *Fpdf) roundedRectPath(x, y, w, h, rTL, rTR, rBR, rBL float64) {
 	k := f.k
 	hp := f.h
 	myArc := (4.0 / 3.0) * (math.Sqrt2 - 1.0)
-	f.outf("q %.5f %.5f m", (x+rTL)*k, (hp-y)*k)
+	f.outf("%.5f %.5f m", (x+rTL)*k, (hp-y)*k)
 	xc := x + w - rTR
 	yc := y + rTR
 	f.outf("%.5f %.5f l", xc*k, (hp-y)*k)
@@ -1554,7 +1889,7 @@ func (f *Fpdf) roundedRectPath(x, y, w, h, rTL, rTR, rBR, rBL float64) {
 //
 // This ClipText() example demonstrates this method.
 func (f *Fpdf) ClipEllipse(x, y, rx, ry float64, outline bool) {
-	f.clipNest++
+	f.clipBegin()
 	lx := (4.0 / 3.0) * rx * (math.Sqrt2 - 1)
 	ly := (4.0 / 3.0) * ry * (math.Sqrt2 - 1)
 	k := f.k
@@ -1603,7 +1938,7 @@ func (f *Fpdf) ClipCircle(x, y, r float64, outline bool) {
 //
 // The ClipText() example demonstrates this method.
 func (f *Fpdf) ClipPolygon(points []PointType, outline bool) {
-	f.clipNest++
+	f.clipBegin()
 	var s fmtBuffer
 	h := f.h
 	k := f.k
@@ -1615,15 +1950,41 @@ func (f *Fpdf) ClipPolygon(points []PointType, outline bool) {
 	f.out(s.String())
 }
 
+// ClipPath begins a clipping operation in which rendering is confined to the
+// inside of the path p, created with NewPath(), determined with the nonzero
+// winding number rule. outline is true to draw the path with the current draw
+// color and line width. Only the outer half of the outline will be shown.
+// Call ClipEnd() to restore unclipped operations. ClipEvenOdd() of the path
+// clips with the even-odd rule.
+//
+// The ClipPath example demonstrates this method.
+func (f *Fpdf) ClipPath(p *Path, outline bool) {
+	f.clipBegin()
+	p.paint("q", strIf(outline, "W S", "W n"))
+}
+
+// clipBegin records the start of a clipping operation
+func (f *Fpdf) clipBegin() {
+	f.clipNest++
+	f.nests = append(f.nests, nestType{kind: nestClip, page: f.page})
+}
+
 // ClipEnd ends a clipping operation that was started with a call to
-// ClipRect(), ClipRoundedRect(), ClipText(), ClipEllipse(), ClipCircle() or
-// ClipPolygon(). Clipping operations can be nested. The document cannot be
-// successfully output while a clipping operation is active.
+// ClipRect(), ClipRoundedRect(), ClipText(), ClipEllipse(), ClipCircle(),
+// ClipPolygon() or ClipPath(). Clipping operations can be nested, with
+// each other and with transformations. An error occurs if a transformation
+// that began after the clipping operation has not ended, or if the clipping
+// operation began on another page. The document cannot be successfully output
+// while a clipping operation is active.
 //
 // The ClipText() example demonstrates this method.
 func (f *Fpdf) ClipEnd() {
 	if f.err == nil {
 		if f.clipNest > 0 {
+			f.err = f.nestEnd(nestClip)
+			if f.err != nil {
+				return
+			}
 			f.clipNest--
 			f.out("Q")
 		} else {
@@ -1632,6 +1993,21 @@ func (f *Fpdf) ClipEnd() {
 	}
 }
 
+// nestEnd removes the innermost clipping operation, transformation context or
+// saved graphics state and returns an error if it is not of the kind that
+// ends, or if it began on another page
+func (f *Fpdf) nestEnd(kind int) error {
+	last := f.nests[len(f.nests)-1]
+	switch {
+	case last.kind != kind:
+		return fmt.Errorf("%s cannot end before the %s that began after it", nestNames[kind], nestNames[last.kind])
+	case last.page != f.page:
+		return fmt.Errorf("%s must end on the page where it began", nestNames[kind])
+	}
+	f.nests = f.nests[:len(f.nests)-1]
+	return nil
+}
+
 // AddFont imports a TrueType, OpenType or Type1 font and makes it available.
 // It is necessary to generate a font definition file first with the makefont
 // utility. It is not necessary to call this function for the core PDF fonts
@@ -1657,7 +2033,8 @@ func (f *Fpdf) AddFont(familyStr, styleStr, fileStr string) {
 }
 
 // AddUTF8Font imports a TrueType font with utf-8 symbols and makes it available.
-// It is necessary to generate a font definition file first with the makefont
+// OpenType fonts are supported too, including those with PostScript (CFF)
+// outlines, which are embedded in full rather than subset. It is necessary to generate a font definition file first with the makefont
 // utility. It is not necessary to call this function for the core PDF fonts
 // (courier, helvetica, times, zapfdingbats).
 //
@@ -1808,7 +2185,8 @@ func (f *Fpdf) AddFontFromBytes(familyStr, styleStr string, jsonFileBytes, zFile
 
 // AddUTF8FontFromBytes  imports a TrueType font with utf-8 symbols from static
 // bytes within the executable and makes it available for use in the generated
-// document.
+// document. As with AddUTF8Font(), OpenType fonts with PostScript outlines
+// are supported.
 //
 // family specifies the font family. The name can be chosen arbitrarily. If it
 // is a standard family name, it will override the corresponding font. This
@@ -2179,7 +2557,7 @@ func (f *Fpdf) newLink(x, y, w, h float64, link int, linkStr string) {
 	// f.pageLinks[f.page] = linkList
 	// }
 	f.pageLinks[f.page] = append(f.pageLinks[f.page],
-		linkType{x * f.k, f.hPt - y*f.k, w * f.k, h * f.k, link, linkStr})
+		linkType{x: x * f.k, y: f.hPt - y*f.k, wd: w * f.k, ht: h * f.k, link: link, linkStr: linkStr})
 }
 
 // Link puts a link on a rectangular area of the page. Text or image links are
@@ -2213,25 +2591,64 @@ func (f *Fpdf) Bookmark(txtStr string, level int, y float64) {
 	f.outlines = append(f.outlines, outlineType{text: txtStr, level: level, y: y, p: f.PageNo(), prev: -1, last: -1, next: -1, first: -1})
 }
 
+// BookmarkOptions specifies the appearance and destination of a bookmark set
+// with BookmarkEx(). Bold and Italic set the style of the title, and Color
+// its color if it is not nil. Zoom is the way the destination is displayed:
+// "XYZ" (the default) scrolls to it and applies ZoomFactor, such as 1.5 for
+// 150 percent, if it is not zero; "FitH" fits the width of the page in the
+// window with the destination at the top; "Fit" fits the whole page. If Open
+// is true, the bookmarks below the bookmark are shown initially. If Dest is
+// not empty, the bookmark points to the named destination Dest, defined with
+// AddNamedDest(), instead of a position on the current page, and Zoom is not
+// used.
+type BookmarkOptions struct {
+	Bold, Italic bool
+	Color        *RGBType
+	Zoom         string
+	ZoomFactor   float64
+	Open         bool
+	Dest         string
+}
+
+// BookmarkEx sets a bookmark like Bookmark() does, with the appearance and
+// destination specified by options.
+//
+// The BookmarkEx example demonstrates this method.
+func (f *Fpdf) BookmarkEx(txtStr string, level int, y float64, options BookmarkOptions) {
+	if f.err != nil {
+		return
+	}
+	if f.err = bookmarkZoom(&options); f.err != nil {
+		return
+	}
+	f.Bookmark(txtStr, level, y)
+	f.outlines[len(f.outlines)-1].options = &options
+}
+
+// bookmarkZoom validates the zoom mode of options, which defaults to XYZ
+func bookmarkZoom(options *BookmarkOptions) error {
+	switch options.Zoom {
+	case "":
+		options.Zoom = "XYZ"
+	case "XYZ", "FitH", "Fit":
+	default:
+		return fmt.Errorf("invalid bookmark zoom mode %q", options.Zoom)
+	}
+	return nil
+}
+
 // Text prints a character string. The origin (x, y) is on the left of the
 // first character at the baseline. This method permits a string to be placed
 // precisely on the page, but it is usually easier to use Cell(), MultiCell()
 // or Write() which are the standard methods to print text.
 func (f *Fpdf) Text(x, y float64, txtStr string) {
-	var txt2 string
 	if f.isCurrentUTF8 {
+		txtStr = f.visualText(txtStr)
 		if f.isRTL {
-			txtStr = reverseText(txtStr)
 			x -= f.GetStringWidth(txtStr)
 		}
-		txt2 = f.escape(utf8toutf16(txtStr, false))
-		for _, uni := range []rune(txtStr) {
-			f.currentFont.usedRunes[int(uni)] = int(uni)
-		}
-	} else {
-		txt2 = f.escape(txtStr)
 	}
-	s := sprintf("BT %.2f %.2f Td (%s) Tj ET", x*f.k, (f.h-y)*f.k, txt2)
+	s := sprintf("BT %.2f %.2f Td %s ET", x*f.k, (f.h-y)*f.k, f.showText(txtStr))
 	if f.underline && txtStr != "" {
 		s += " " + f.dounderline(x, y, txtStr)
 	}
@@ -2241,13 +2658,105 @@ func (f *Fpdf) Text(x, y float64, txtStr string) {
 	if f.colorFlag {
 		s = sprintf("q %s %s Q", f.color.text.str, s)
 	}
-	f.out(s)
+	f.out(f.structMark(s))
 }
 
-// SetWordSpacing sets spacing between words of following text. See the
-// WriteAligned() example for a demonstration of its use.
+// showText returns the operators that show txtStr, which is in visual order,
+// with the current font
+func (f *Fpdf) showText(txtStr string) string {
+	if f.isCurrentUTF8 {
+		for _, uni := range []rune(txtStr) {
+			f.currentFont.usedRunes[int(uni)] = int(uni)
+		}
+	}
+	switch {
+	case f.smallCaps:
+		return f.smallCapsText(txtStr)
+	case f.needsTJ(txtStr):
+		return f.tjText(txtStr, 0)
+	case f.isCurrentUTF8:
+		return sprintf("(%s) Tj", f.escape(utf8toutf16(txtStr, false)))
+	}
+	return sprintf("(%s) Tj", f.escape(txtStr))
+}
+
+// SetWordSpacing sets spacing between words of following text. space is
+// the extra space added to each space character, in the unit of measure
+// specified in New(); it may be negative. The spacing is retained from page
+// to page, and is taken into account by GetStringWidth() and by the line
+// breaking of MultiCell(), Write() and SplitText(). In UTF-8 fonts, which
+// the PDF word spacing operator does not affect, the spacing is applied with
+// text position adjustments. See the WriteAligned() example for a
+// demonstration of its use.
 func (f *Fpdf) SetWordSpacing(space float64) {
-	f.out(sprintf("%.5f Tw", space*f.k))
+	f.wordSpacing = space
+	if f.page > 0 {
+		f.out(sprintf("%.5f Tw", space*f.k))
+	}
+}
+
+// GetWordSpacing returns the word spacing set with SetWordSpacing().
+func (f *Fpdf) GetWordSpacing() float64 {
+	return f.wordSpacing
+}
+
+// SetCharSpacing sets spacing between characters of following text, to
+// track out headings for example. space is the extra space added after each
+// character, in the unit of measure specified in New(); it may be negative.
+// As with SetWordSpacing(), the spacing is retained from page to page and is
+// taken into account when text is measured and broken into lines.
+//
+// The SetCharSpacing example demonstrates this method.
+func (f *Fpdf) SetCharSpacing(space float64) {
+	f.charSpacing = space
+	if f.page > 0 {
+		f.out(sprintf("%.5f Tc", space*f.k))
+	}
+}
+
+// GetCharSpacing returns the character spacing set with SetCharSpacing().
+func (f *Fpdf) GetCharSpacing() float64 {
+	return f.charSpacing
+}
+
+// SetTextRise raises following text above the baseline, or lowers it below
+// the baseline if rise is negative. rise is specified in points, like font
+// sizes. Underlines and strike-out lines follow the text. The rise is
+// retained from page to page.
+//
+// WriteSuperscript() and WriteSubscript() use the text rise to write
+// superscripts and subscripts. The WriteSuperscript example demonstrates
+// this method.
+func (f *Fpdf) SetTextRise(rise float64) {
+	f.textRise = rise
+	if f.page > 0 {
+		f.outf("%.2f Ts", rise)
+	}
+}
+
+// GetTextRise returns the text rise, in points, set with SetTextRise().
+func (f *Fpdf) GetTextRise() float64 {
+	return f.textRise
+}
+
+// extraWidth returns the width that character and word spacing and small
+// capitals add to the width of c, in thousandths of the font size
+func (f *Fpdf) extraWidth(c rune) float64 {
+	space := f.charSpacing
+	if c == ' ' {
+		space += f.wordSpacing
+	}
+	return space*1000/f.fontSize + f.smallCapWidth(c)
+}
+
+// putWordSpacing sets the word spacing of the text state to the sum of the
+// spacing set with SetWordSpacing() and the spacing of justified text
+func (f *Fpdf) putWordSpacing() {
+	if space := f.wordSpacing + f.ws; space != 0 {
+		f.outf("%.3f Tw", space*f.k)
+	} else {
+		f.out("0 Tw")
+	}
 }
 
 // SetTextRenderingMode sets the rendering mode of following text.
@@ -2335,14 +2844,18 @@ func (f *Fpdf) CellFormat(w, h float64, txtStr, borderStr string, ln int,
 
 	borderStr = strings.ToUpper(borderStr)
 	k := f.k
-	if f.y+h > f.pageBreakTrigger && !f.inHeader && !f.inFooter && f.acceptPageBreak() {
+	if f.y+h > f.pageBreakTrigger && !f.inHeader && !f.inFooter && f.acceptBreak() {
 		// Automatic page break
 		x := f.x
+		if f.columns.n > 1 {
+			// The position is kept in the first column of the new page
+			x -= f.lMargin - f.columns.lMargin
+		}
 		ws := f.ws
 		// dbg("auto page break, x %.2f, ws %.2f", x, ws)
 		if ws > 0 {
 			f.ws = 0
-			f.out("0 Tw")
+			f.putWordSpacing()
 		}
 		f.AddPageFormat(f.curOrientation, f.curPageSize)
 		if f.err != nil {
@@ -2351,7 +2864,7 @@ func (f *Fpdf) CellFormat(w, h float64, txtStr, borderStr string, ln int,
 		f.x = x
 		if ws > 0 {
 			f.ws = ws
-			f.outf("%.3f Tw", ws*k)
+			f.putWordSpacing()
 		}
 	}
 	if w == 0 {
@@ -2396,7 +2909,11 @@ func (f *Fpdf) CellFormat(w, h float64, txtStr, borderStr string, ln int,
 			s.printf("%.2f %.2f m %.2f %.2f l S ", left, bottom, right, bottom)
 		}
 	}
+	textStart := s.Len()
 	if len(txtStr) > 0 {
+		if f.isCurrentUTF8 {
+			txtStr = f.visualText(txtStr)
+		}
 		var dx, dy float64
 		// Horizontal alignment
 		switch {
@@ -2427,39 +2944,40 @@ func (f *Fpdf) CellFormat(w, h float64, txtStr, borderStr string, ln int,
 		default:
 			dy = 0
 		}
+		// Text with aliases is moved by the change of its width
+		shift := f.aliasShiftBegin(txtStr, alignStr)
+		s.WriteString(shift)
 		if f.colorFlag {
 			s.printf("q %s ", f.color.text.str)
 		}
 		//If multibyte, Tw has no effect - do word spacing using an adjustment before each space
-		if (f.ws != 0 || alignStr == "J") && f.isCurrentUTF8 { // && f.ws != 0
-			if f.isRTL {
-				txtStr = reverseText(txtStr)
-			}
+		if (f.ws != 0 || alignStr == "J") && f.isCurrentUTF8 && !f.smallCaps { // && f.ws != 0
 			wmax := int(math.Ceil((w - 2*f.cMargin) * 1000 / f.fontSize))
 			for _, uni := range []rune(txtStr) {
 				f.currentFont.usedRunes[int(uni)] = int(uni)
 			}
 			space := f.escape(utf8toutf16(" ", false))
-			strSize := f.GetStringSymbolWidth(txtStr)
-			s.printf("BT 0 Tw %.2f %.2f Td [", (f.x+dx)*k, (f.h-(f.y+.5*h+.3*f.fontSize))*k)
+			strSize := int(f.GetStringWidth(txtStr) * 1000 / f.fontSize)
 			t := strings.Split(txtStr, " ")
 			shift := float64((wmax - strSize)) / float64(len(t)-1)
 			numt := len(t)
-			for i := 0; i < numt; i++ {
-				tx := t[i]
-				tx = "(" + f.escape(utf8toutf16(tx, false)) + ")"
-				s.printf("%s ", tx)
-				if (i + 1) < numt {
-					s.printf("%.3f(%s) ", -shift, space)
+			if f.needsTJ(txtStr) {
+				s.printf("BT %.2f %.2f Td %s ET", (f.x+dx)*k, (f.h-(f.y+.5*h+.3*f.fontSize))*k, f.tjText(txtStr, shift))
+			} else {
+				s.printf("BT %.2f %.2f Td [", (f.x+dx)*k, (f.h-(f.y+.5*h+.3*f.fontSize))*k)
+				for i := 0; i < numt; i++ {
+					tx := t[i]
+					tx = "(" + f.escape(utf8toutf16(tx, false)) + ")"
+					s.printf("%s ", tx)
+					if (i + 1) < numt {
+						s.printf("%.3f(%s) ", -shift, space)
+					}
 				}
+				s.printf("] TJ ET")
 			}
-			s.printf("] TJ ET")
 		} else {
 			var txt2 string
 			if f.isCurrentUTF8 {
-				if f.isRTL {
-					txtStr = reverseText(txtStr)
-				}
 				txt2 = f.escape(utf8toutf16(txtStr, false))
 				for _, uni := range []rune(txtStr) {
 					f.currentFont.usedRunes[int(uni)] = int(uni)
@@ -2472,7 +2990,13 @@ func (f *Fpdf) CellFormat(w, h float64, txtStr, borderStr string, ln int,
 			}
 			bt := (f.x + dx) * k
 			td := (f.h - (f.y + dy + .5*h + .3*f.fontSize)) * k
-			s.printf("BT %.2f %.2f Td (%s)Tj ET", bt, td, txt2)
+			if f.smallCaps {
+				s.printf("BT %.2f %.2f Td %s ET", bt, td, f.smallCapsText(txtStr))
+			} else if f.needsTJ(txtStr) {
+				s.printf("BT %.2f %.2f Td %s ET", bt, td, f.tjText(txtStr, 0))
+			} else {
+				s.printf("BT %.2f %.2f Td (%s)Tj ET", bt, td, txt2)
+			}
 			//BT %.2F %.2F Td (%s) Tj ET',(f.x+dx)*k,(f.h-(f.y+.5*h+.3*f.FontSize))*k,txt2);
 		}
 
@@ -2485,11 +3009,19 @@ func (f *Fpdf) CellFormat(w, h float64, txtStr, borderStr string, ln int,
 		if f.colorFlag {
 			s.printf(" Q")
 		}
+		if shift != "" {
+			s.printf(" Q")
+		}
 		if link > 0 || len(linkStr) > 0 {
 			f.newLink(f.x+dx, f.y+dy+.5*h-.5*f.fontSize, f.GetStringWidth(txtStr), f.fontSize, link, linkStr)
 		}
 	}
 	str := s.String()
+	if f.structTree.tagged {
+		// The background and borders are not part of the structure
+		str = strings.TrimSpace(f.structArtifact(strings.TrimSpace(str[:textStart])) + " " +
+			f.structMark(str[textStart:]))
+	}
 	if len(str) > 0 {
 		f.out(str)
 	}
@@ -2506,17 +3038,6 @@ func (f *Fpdf) CellFormat(w, h float64, txtStr, borderStr string, ln int,
 	return
 }
 
-// Revert string to use in RTL languages
-func reverseText(text string) string {
-	oldText := []rune(text)
-	newText := make([]rune, len(oldText))
-	length := len(oldText) - 1
-	for i, r := range oldText {
-		newText[length-i] = r
-	}
-	return string(newText)
-}
-
 // Cell is a simpler version of CellFormat with no fill, border, links or
 // special alignment. The Cell_strikeout() example demonstrates this method.
 func (f *Fpdf) Cell(w, h float64, txtStr string) {
@@ -2555,25 +3076,44 @@ func (f *Fpdf) SplitLines(txt []byte, w float64) [][]byte {
 	i := 0
 	j := 0
 	l := 0
+	sp := 0.0 // character and word spacing
+	var chars []rune // characters of the text for hyphenation
+	if f.hyphenator != nil && !f.isCurrentUTF8 {
+		chars = f.textRunes(string(s))
+	}
 	for i < nb {
 		c := s[i]
 		l += cw[c]
+		sp += f.extraWidth(rune(c))
 		if c == ' ' || c == '\t' || c == '\n' {
 			sep = i
 		}
-		if c == '\n' || l > wmax {
-			if sep == -1 {
+		if c == '\n' || l+int(sp) > wmax {
+			start := j
+			if sep != -1 {
+				start = sep + 1
+			}
+			var n int
+			if c != '\n' && chars != nil {
+				n = f.hyphenation(chars, j, start, float64(wmax))
+			}
+			if n > 0 {
+				// Break the last word of the line with a hyphen
+				lines = append(lines, append(s[j:start+n:start+n], '-'))
+				i = start + n
+			} else if sep == -1 {
 				if i == j {
 					i++
 				}
-				sep = i
+				lines = append(lines, s[j:i])
 			} else {
 				i = sep + 1
+				lines = append(lines, s[j:sep])
 			}
-			lines = append(lines, s[j:sep])
 			sep = -1
 			j = i
 			l = 0
+			sp = 0
 		} else {
 			i++
 		}
@@ -2591,6 +3131,12 @@ func (f *Fpdf) SplitLines(txt []byte, w float64) [][]byte {
 // Text can be aligned, centered or justified. The cell block can be framed and
 // the background painted. See CellFormat() for more details.
 //
+// alignStr is "L", "C" or "R" to align lines left, center or right, or "J",
+// the default, to justify them. Justified lines are spread to the width of
+// the cell by widening their spaces, except for the last line of each
+// paragraph, which ends with an explicit line break or the end of txtStr and
+// is aligned left, or right with right-to-left text.
+//
 // The current position after calling MultiCell() is the beginning of the next
 // line, equivalent to calling CellFormat with ln equal to 1.
 //
@@ -2608,9 +3154,39 @@ func (f *Fpdf) SplitLines(txt []byte, w float64) [][]byte {
 // removed should call strings.TrimRight(txtStr, "\r\n") before calling this
 // method.
 func (f *Fpdf) MultiCell(w, h float64, txtStr, borderStr, alignStr string, fill bool) {
+	f.multiCell(w, h, txtStr, borderStr, alignStr, fill, 0)
+}
+
+// MultiCellBounded is like MultiCell() but stops when the lines that have been
+// output reach a total height of maxH. The text that did not fit is returned,
+// or an empty string if all of txtStr was output. This permits text to be
+// flowed through a series of boxes or columns: the remainder is simply passed
+// to the next call. If a bottom border is requested, it is drawn below the
+// last line that is output. No text is output if maxH is less than h.
+//
+// The MultiCellBounded example demonstrates this method.
+func (f *Fpdf) MultiCellBounded(w, h, maxH float64, txtStr, borderStr, alignStr string, fill bool) (rest string) {
+	if f.err != nil {
+		return txtStr
+	}
+	maxLines := int(maxH/h + 1e-9)
+	if maxLines < 1 {
+		return txtStr
+	}
+	return f.multiCell(w, h, txtStr, borderStr, alignStr, fill, maxLines)
+}
+
+// multiCell implements MultiCell() and MultiCellBounded(). If maxLines is
+// greater than zero, output stops after that many lines and the remaining
+// text is returned.
+func (f *Fpdf) multiCell(w, h float64, txtStr, borderStr, alignStr string, fill bool, maxLines int) (rest string) {
 	if f.err != nil {
 		return
 	}
+	if f.keepTogether {
+		f.BeginBlock()
+		defer f.EndBlock()
+	}
 	// dbg("MultiCell")
 	if alignStr == "" {
 		alignStr = "J"
@@ -2675,8 +3251,39 @@ func (f *Fpdf) MultiCell(w, h float64, txtStr, borderStr, alignStr string, fill
 	j := 0
 	l := 0
 	ls := 0
+	sp := 0.0 // character and word spacing
 	ns := 0
 	nl := 1
+	var txt []rune // characters of the text for hyphenation
+	if f.hyphenator != nil {
+		txt = f.textRunes(s)
+	}
+	// lineBorder returns the border of a line that is followed by another,
+	// which includes the bottom border if output is about to stop
+	lineBorder := func() string {
+		if maxLines > 0 && nl == maxLines && strings.Contains(borderStr, "B") {
+			return b + "B"
+		}
+		return b
+	}
+	// stop returns true if the line limit has been reached, in which case the
+	// remaining text is stored in rest
+	stop := func() bool {
+		if maxLines == 0 || nl <= maxLines {
+			return false
+		}
+		if f.ws > 0 {
+			f.ws = 0
+			f.putWordSpacing()
+		}
+		f.x = f.lMargin
+		if f.isCurrentUTF8 {
+			rest = string(srune[j:])
+		} else {
+			rest = s[j:]
+		}
+		return true
+	}
 	for i < nb {
 		// Get next character
 		var c rune
@@ -2689,7 +3296,7 @@ func (f *Fpdf) MultiCell(w, h float64, txtStr, borderStr, alignStr string, fill
 			// Explicit line break
 			if f.ws > 0 {
 				f.ws = 0
-				f.out("0 Tw")
+				f.putWordSpacing()
 			}
 
 			if f.isCurrentUTF8 {
@@ -2701,74 +3308,108 @@ func (f *Fpdf) MultiCell(w, h float64, txtStr, borderStr, alignStr string, fill
 						newAlignStr = "L"
 					}
 				}
-				f.CellFormat(w, h, string(srune[j:i]), b, 2, newAlignStr, fill, 0, "")
+				f.CellFormat(w, h, string(srune[j:i]), lineBorder(), 2, newAlignStr, fill, 0, "")
 			} else {
-				f.CellFormat(w, h, s[j:i], b, 2, alignStr, fill, 0, "")
+				f.CellFormat(w, h, s[j:i], lineBorder(), 2, alignStr, fill, 0, "")
 			}
 			i++
 			sep = -1
 			j = i
 			l = 0
+			sp = 0
 			ns = 0
 			nl++
 			if len(borderStr) > 0 && nl == 2 {
 				b = b2
 			}
+			if stop() {
+				return
+			}
 			continue
 		}
 		if c == ' ' || isChinese(c) {
 			sep = i
-			ls = l
+			ls = l + int(sp)
 			ns++
 		}
 		if int(c) >= len(cw) {
 			f.err = fmt.Errorf("character outside the supported range: %s", string(c))
 			return
 		}
-		if cw[int(c)] == 0 { //Marker width 0 used for missing symbols
+		if i > j && f.isCurrentUTF8 {
+			l += f.kern(srune[i-1], c)
+		}
+		if fw, ok := f.fallbackWidth(c); ok {
+			l += fw
+		} else if cw[int(c)] == 0 { //Marker width 0 used for missing symbols
 			l += f.currentFont.Desc.MissingWidth
 		} else if cw[int(c)] != 65535 { //Marker width 65535 used for zero width symbols
 			l += cw[int(c)]
 		}
-		if l > wmax {
+		sp += f.extraWidth(c)
+		if l+int(sp) > wmax {
 			// Automatic line break
-			if sep == -1 {
+			start := j
+			if sep != -1 {
+				start = sep + 1
+			}
+			if n := f.hyphenation(txt, j, start, float64(wmax)); n > 0 {
+				// Break the last word of the line with a hyphen
+				line := f.lineText(txt[j:start+n]) + "-"
+				if alignStr == "J" && !f.isCurrentUTF8 {
+					if ns > 0 {
+						f.ws = (w - 2*f.cMargin - f.GetStringWidth(line)) / float64(ns)
+					} else {
+						f.ws = 0
+					}
+					f.putWordSpacing()
+				}
+				f.CellFormat(w, h, line, lineBorder(), 2, alignStr, fill, 0, "")
+				i = start + n
+			} else if sep == -1 {
 				if i == j {
 					i++
 				}
 				if f.ws > 0 {
 					f.ws = 0
-					f.out("0 Tw")
+					f.putWordSpacing()
 				}
 				if f.isCurrentUTF8 {
-					f.CellFormat(w, h, string(srune[j:i]), b, 2, alignStr, fill, 0, "")
+					f.CellFormat(w, h, string(srune[j:i]), lineBorder(), 2, alignStr, fill, 0, "")
 				} else {
-					f.CellFormat(w, h, s[j:i], b, 2, alignStr, fill, 0, "")
+					f.CellFormat(w, h, s[j:i], lineBorder(), 2, alignStr, fill, 0, "")
 				}
 			} else {
-				if alignStr == "J" {
+				// Justified lines of UTF-8 fonts are spread by CellFormat()
+				if alignStr == "J" && !f.isCurrentUTF8 {
+					// Distribute the remaining width over the spaces
+					// within the line, excluding the one it breaks at
 					if ns > 1 {
-						f.ws = float64((wmax-ls)/1000) * f.fontSize / float64(ns-1)
+						f.ws = float64(wmax-ls) / 1000 * f.fontSize / float64(ns-1)
 					} else {
 						f.ws = 0
 					}
-					f.outf("%.3f Tw", f.ws*f.k)
+					f.putWordSpacing()
 				}
 				if f.isCurrentUTF8 {
-					f.CellFormat(w, h, string(srune[j:sep]), b, 2, alignStr, fill, 0, "")
+					f.CellFormat(w, h, string(srune[j:sep]), lineBorder(), 2, alignStr, fill, 0, "")
 				} else {
-					f.CellFormat(w, h, s[j:sep], b, 2, alignStr, fill, 0, "")
+					f.CellFormat(w, h, s[j:sep], lineBorder(), 2, alignStr, fill, 0, "")
 				}
 				i = sep + 1
 			}
 			sep = -1
 			j = i
 			l = 0
+			sp = 0
 			ns = 0
 			nl++
 			if len(borderStr) > 0 && nl == 2 {
 				b = b2
 			}
+			if stop() {
+				return
+			}
 		} else {
 			i++
 		}
@@ -2776,7 +3417,7 @@ func (f *Fpdf) MultiCell(w, h float64, txtStr, borderStr, alignStr string, fill
 	// Last chunk
 	if f.ws > 0 {
 		f.ws = 0
-		f.out("0 Tw")
+		f.putWordSpacing()
 	}
 	if len(borderStr) > 0 && strings.Contains(borderStr, "B") {
 		b += "B"
@@ -2794,11 +3435,16 @@ func (f *Fpdf) MultiCell(w, h float64, txtStr, borderStr, alignStr string, fill
 		f.CellFormat(w, h, s[j:i], b, 2, alignStr, fill, 0, "")
 	}
 	f.x = f.lMargin
+	return
 }
 
 // write outputs text in flowing mode
 func (f *Fpdf) write(h float64, txtStr string, link int, linkStr string) {
 	// dbg("Write")
+	if strings.IndexByte(txtStr, '\t') >= 0 {
+		f.writeTabs(h, txtStr, link, linkStr)
+		return
+	}
 	cw := f.currentFont.Cw
 	w := f.w - f.rMargin - f.x
 	wmax := (w - 2*f.cMargin) * 1000 / f.fontSize
@@ -2818,6 +3464,10 @@ func (f *Fpdf) write(h float64, txtStr string, link int, linkStr string) {
 	j := 0
 	l := 0.0
 	nl := 1
+	var txt []rune // characters of the text for hyphenation
+	if f.hyphenator != nil {
+		txt = f.textRunes(s)
+	}
 	for i < nb {
 		// Get next character
 		var c rune
@@ -2848,10 +3498,30 @@ func (f *Fpdf) write(h float64, txtStr string, link int, linkStr string) {
 		if c == ' ' {
 			sep = i
 		}
-		l += float64(cw[int(c)])
+		if int(c) >= len(cw) {
+			f.err = fmt.Errorf("character outside the supported range: %s", string(c))
+			return
+		}
+		if i > j && f.isCurrentUTF8 {
+			l += float64(f.kern([]rune(s)[i-1], c))
+		}
+		if fw, ok := f.fallbackWidth(c); ok {
+			l += float64(fw)
+		} else {
+			l += float64(cw[int(c)])
+		}
+		l += f.extraWidth(c)
 		if l > wmax {
 			// Automatic line break
-			if sep == -1 {
+			start := j
+			if sep != -1 {
+				start = sep + 1
+			}
+			if n := f.hyphenation(txt, j, start, wmax); n > 0 {
+				// Break the last word of the line with a hyphen
+				f.CellFormat(w, h, f.lineText(txt[j:start+n])+"-", "", 2, "", false, link, linkStr)
+				i = start + n
+			} else if sep == -1 {
 				if f.x > f.lMargin {
 					// Move to next line
 					f.x = f.lMargin
@@ -2904,7 +3574,8 @@ func (f *Fpdf) write(h float64, txtStr string, link int, linkStr string) {
 // Write prints text from the current position. When the right margin is
 // reached (or the \n character is met) a line break occurs and text continues
 // from the left margin. Upon method exit, the current position is left just at
-// the end of the text.
+// the end of the text. A tab character advances to the next tab stop set with
+// SetTabStops().
 //
 // It is possible to put a link on the text.
 //
@@ -3011,13 +3682,27 @@ func (f *Fpdf) ImageTypeFromMime(mimeStr string) (tp string) {
 		tp = "jpg"
 	case "image/gif":
 		tp = "gif"
+	case "image/tiff":
+		tp = "tiff"
+	case "image/bmp":
+		tp = "bmp"
+	case "image/jp2", "image/jpx":
+		tp = "jp2"
+	case "image/webp":
+		tp = "webp"
+	case "image/avif":
+		tp = "avif"
 	default:
 		f.SetErrorf("unsupported image type: %s", mimeStr)
 	}
 	return
 }
 
-func (f *Fpdf) imageOut(info *ImageInfoType, x, y, w, h float64, allowNegativeX, flow bool, link int, linkStr string) {
+// imageExtent returns the width and height, in the unit of measure of the
+// document, with which an image of info is printed with the width w and
+// height h passed to ImageOptions(), where iw and ih are the width and height
+// in pixels of the upright image, or of its bounding box if it is rotated
+func (f *Fpdf) imageExtent(info *ImageInfoType, w, h, iw, ih float64) (float64, float64) {
 	// Automatic width and height calculation if needed
 	if w == 0 && h == 0 {
 		// Put image at 96 dpi
@@ -3035,22 +3720,44 @@ func (f *Fpdf) imageOut(info *ImageInfoType, x, y, w, h float64, allowNegativeX,
 		h = -info.dpi
 	}
 	if w < 0 {
-		w = -info.w * 72.0 / w / f.k
+		w = -iw * 72.0 / w / f.k
 	}
 	if h < 0 {
-		h = -info.h * 72.0 / h / f.k
+		h = -ih * 72.0 / h / f.k
 	}
 	if w == 0 {
-		w = h * info.w / info.h
+		w = h * iw / ih
 	}
 	if h == 0 {
-		h = w * info.h / info.w
+		h = w * ih / iw
+	}
+	return w, h
+}
+
+// imageOut places the image info in the rectangle of width w and height h,
+// with the matrix m mapping the unit square of the image to the unit square
+// of the rectangle, inline in the content stream if inline is true and the
+// image can be placed inline
+func (f *Fpdf) imageOut(info *ImageInfoType, x, y, w, h float64, m TransformMatrix, inline, allowNegativeX, flow bool, link int, linkStr, altStr string) {
+	var bi string
+	if inline {
+		if bi = f.inlineImage(info); f.err != nil {
+			return
+		}
+	}
+	if bi != "" {
+		f.imagesInline[info.i] = true
+	} else {
+		f.imagesUsed[info.i] = true
 	}
 	// Flowing mode
 	if flow {
-		if f.y+h > f.pageBreakTrigger && !f.inHeader && !f.inFooter && f.acceptPageBreak() {
+		if f.y+h > f.pageBreakTrigger && !f.inHeader && !f.inFooter && f.acceptBreak() {
 			// Automatic page break
 			x2 := f.x
+			if f.columns.n > 1 {
+				x2 -= f.lMargin - f.columns.lMargin
+			}
 			f.AddPageFormat(f.curOrientation, f.curPageSize)
 			if f.err != nil {
 				return
@@ -3067,7 +3774,19 @@ func (f *Fpdf) imageOut(info *ImageInfoType, x, y, w, h float64, allowNegativeX,
 	}
 	// dbg("h %.2f", h)
 	// q 85.04 0 0 NaN 28.35 NaN cm /I2 Do Q
-	f.outf("q %.5f 0 0 %.5f %.5f %.5f cm /I%s Do Q", w*f.k, h*f.k, x*f.k, (f.h-(y+h))*f.k, info.i)
+	s := sprintf("q %.5f 0 0 %.5f %.5f %.5f cm", w*f.k, h*f.k, x*f.k, (f.h-(y+h))*f.k)
+	if m != (TransformMatrix{1, 0, 0, 1, 0, 0}) {
+		s += sprintf(" %.5f %.5f %.5f %.5f %.5f %.5f cm", m.A, m.B, m.C, m.D, m.E, m.F)
+	}
+	if bi != "" {
+		s += " " + bi + " Q"
+	} else {
+		s += sprintf(" /I%s Do Q", info.i)
+	}
+	if altStr != "" {
+		s = sprintf("/Figure <</Alt (%s)>> BDC %s EMC", f.escape(utf8toutf16(altStr)), s)
+	}
+	f.out(f.structMark(s))
 	if link > 0 || len(linkStr) > 0 {
 		f.newLink(x, y, w, h, link, linkStr)
 	}
@@ -3129,7 +3848,28 @@ func (f *Fpdf) ImageOptions(imageNameStr string, x, y, w, h float64, flow bool,
 	if f.err != nil {
 		return
 	}
-	f.imageOut(info, x, y, w, h, options.AllowNegativePosition, flow, link, linkStr)
+	bw, bh, m := imagePlacement(info, options.Rotation, options.FlipH, options.FlipV)
+	w, h = f.imageExtent(info, w, h, bw, bh)
+	// The image is resampled for the size at which it is placed, in the
+	// orientation in which it is stored
+	iw, ih := info.uprightSize()
+	iw, ih = w*iw/bw, h*ih/bh
+	if info.orientation >= 5 {
+		iw, ih = ih, iw
+	}
+	if info = f.resampledImage(imageNameStr, info, iw, ih, options); f.err != nil {
+		return
+	}
+	if info = f.maskedImage(info, options); f.err != nil {
+		return
+	}
+	if options.AltText != "" && f.structTree.tagged && f.artifacts == 0 && !f.inHeader && !f.inFooter {
+		f.StartStructElemEx(RoleFigure, StructElemOptions{AltText: options.AltText})
+		f.imageOut(info, x, y, w, h, m, options.Inline, options.AllowNegativePosition, flow, link, linkStr, "")
+		f.EndStructElem()
+		return
+	}
+	f.imageOut(info, x, y, w, h, m, options.Inline, options.AllowNegativePosition, flow, link, linkStr, options.AltText)
 	return
 }
 
@@ -3149,8 +3889,20 @@ func (f *Fpdf) RegisterImageReader(imgName, tp string, r io.Reader) (info *Image
 // parsing an image.
 //
 // ImageType's possible values are (case insensitive):
-// "JPG", "JPEG", "PNG" and "GIF". If empty, the type is inferred from
-// the file extension.
+// "JPG", "JPEG", "PNG", "GIF", "TIF", "TIFF", "BMP", "JP2", "JPX", "J2K" and
+// "J2C". If empty, the type is inferred from the file extension. JPEG 2000
+// images, as JP2 and JPX files or as codestreams, are embedded without being
+// decoded, which requires PDF 1.5. PNG images with 16 bits per component keep
+// their depth, which requires PDF 1.5 as well, except in PDF/A-1 documents,
+// where they are reduced to 8 bits. The ICC color profiles embedded in PNG
+// and JPEG images are embedded with them, so that their colors are rendered
+// as the profiles define them. Images of other types, such as "WEBP"
+// and "AVIF", are read with the decoder registered for the type with the
+// image package, for example by importing golang.org/x/image/webp, and
+// embedded as described for RegisterImageFromImage(). TIFF images that are
+// bilevel images compressed with CCITT Group 3 or Group 4 compression in a
+// single strip, as scanned documents usually are, are embedded without being
+// decoded; other TIFF images are read with a registered TIFF decoder as well.
 //
 // ReadDpi defines whether to attempt to automatically read the image
 // dpi information from the image file. Normally, this should be set
@@ -3160,10 +3912,86 @@ func (f *Fpdf) RegisterImageReader(imgName, tp string, r io.Reader) (info *Image
 //
 // AllowNegativePosition can be set to true in order to prevent the default
 // coercion of negative x values to the current x position.
+//
+// AltText is the alternate description of the image that screen readers
+// speak in its place. In a tagged document, as set with SetTagged(), the
+// image becomes a figure of the structure with this description. Otherwise
+// the description is attached to the content of the image, which
+// accessibility checkers recognize as well. Images that are purely decorative
+// should instead be marked with BeginArtifact().
+//
+// JPEGQuality is the quality, from 1 to 100, with which
+// RegisterImageFromImage() encodes images as JPEG images and with which
+// images resampled for MaxDPI are encoded again; 90 if it is 0.
+//
+// MaxDPI is the highest resolution, in dots per inch, with which
+// ImageOptions() places the image. An image that has more pixels than this
+// resolution allows at the size at which it is placed is resampled to this
+// resolution, and the resampled copy is embedded in its place, which makes
+// documents with large photographs much smaller. JPEG images are encoded
+// again as JPEG images with the quality JPEGQuality; other images remain
+// lossless. The image itself is embedded only if it is placed elsewhere
+// without being resampled. CCITT, JPEG 2000 and CMYK images and images with
+// color key masks are not resampled. If MaxDPI is 0, images are placed as
+// they are.
+//
+// SMaskImage is the name of a gray image, registered or loaded like the
+// image itself, that ImageOptions() applies to the image as a mask, for
+// example a mask channel that a scanner stores separately: white areas of
+// the mask show the image and black areas hide it. A mask image with 1 bit
+// per component, such as a CCITT compressed TIFF image, is a stencil mask,
+// which PDF/A-1 permits; other mask images are soft masks, whose gray levels
+// are levels of opacity. The mask image need not have the size of the image,
+// and replaces the transparency of the image. An error occurs if the mask
+// image is not a gray image without transparency.
+//
+// MaskColors is a color key mask that ImageOptions() applies to the image:
+// pairs of the lowest and highest values of each color component, or of the
+// palette index, of the colors that are not painted, in the range of the
+// values of the image, such as 0 to 255. For example, {250, 255, 250, 255,
+// 250, 255} hides the white background of an RGB image. An error occurs if
+// the number of values does not match the color space of the image or if the
+// ranges are invalid.
+//
+// Rotation is the angle, in degrees, by which ImageOptions() rotates the
+// image counter-clockwise about its center, and FlipH and FlipV mirror it
+// horizontally and vertically before it is rotated. The width and height
+// passed to ImageOptions() are those of the bounding box of the rotated
+// image, whose upper left corner is placed at the position passed to it, so
+// that an image rotated by 90 or 270 degrees has the width and height of the
+// image swapped, and its aspect ratio is kept if the width or the height is
+// 0. JPEG images are placed upright as their EXIF orientation specifies,
+// whether or not they are rotated or flipped, so that photographs taken with
+// phones do not appear sideways; the width and height of such an image that
+// is stored on its side are swapped, as Width() and Height() report them.
+//
+// Inline makes ImageOptions() put the image in the content stream of the
+// page, as an inline image, instead of embedding it once as an object that
+// the page refers to. This saves the overhead of an object for each of the
+// images of documents with many small, distinct images such as icons, but
+// repeats the data of the image wherever it is placed, so it suits images of
+// no more than about 4 KB that are placed once. The data is encoded with
+// ASCII85. Images with transparency, masks, ICC profiles or JPEG 2000 data,
+// which cannot be inline images, are embedded as objects regardless.
+//
+// Deferred makes RegisterImageOptions() and ImageOptions() keep only the
+// size and the other properties of an image file, and read its data again
+// when the document is output, as RegisterImageOptionsOpener() does, so that
+// documents with many large images need not hold their data in memory.
 type ImageOptions struct {
 	ImageType             string
 	ReadDpi               bool
 	AllowNegativePosition bool
+	AltText               string
+	JPEGQuality           int
+	MaxDPI                float64
+	SMaskImage            string
+	MaskColors            []int
+	Rotation              float64
+	FlipH                 bool
+	FlipV                 bool
+	Inline                bool
+	Deferred              bool
 }
 
 // RegisterImageOptionsReader registers an image, reading it from Reader r, adding it
@@ -3171,6 +3999,10 @@ type ImageOptions struct {
 // name to add the image to the page. Note that tp should be specified in this
 // case.
 //
+// An image is embedded in the PDF file only once it has been placed on a page
+// or in a template that is used. Images with the same content, registered
+// with different names, are embedded once.
+//
 // See Image() for restrictions on the image and the options parameters.
 func (f *Fpdf) RegisterImageOptionsReader(imgName string, options ImageOptions, r io.Reader) (info *ImageInfoType) {
 	// Thanks, Ivan Daniluk, for generalizing this code to use the Reader interface.
@@ -3183,6 +4015,16 @@ func (f *Fpdf) RegisterImageOptionsReader(imgName string, options ImageOptions,
 	}
 
 	// First use of this image, get info
+	if info = f.parseImageOptions(r, options); f.err != nil {
+		return
+	}
+	f.registerImage(imgName, info)
+	return
+}
+
+// parseImageOptions extracts info from the image read from r, of the type
+// options.ImageType
+func (f *Fpdf) parseImageOptions(r io.Reader, options ImageOptions) (info *ImageInfoType) {
 	if options.ImageType == "" {
 		f.err = fmt.Errorf("image type should be specified if reading from custom reader")
 		return
@@ -3198,19 +4040,32 @@ func (f *Fpdf) RegisterImageOptionsReader(imgName string, options ImageOptions,
 		info = f.parsepng(r, options.ReadDpi)
 	case "gif":
 		info = f.parsegif(r)
+	case "tif", "tiff":
+		info = f.parsetiff(r, options.ReadDpi)
+	case "bmp":
+		info = f.parsebmp(r, options.ReadDpi)
+	case "jp2", "jpx", "j2k", "j2c":
+		info = f.parsejpx(r, options.ReadDpi)
 	default:
-		f.err = fmt.Errorf("unsupported image type: %s", options.ImageType)
-	}
-	if f.err != nil {
-		return
+		info = f.parsedecoded(r, options.ImageType)
 	}
+	return
+}
 
+// registerImage adds info to the images of the document with the name
+// imgName. An image with the same content as an image registered before with
+// another name shares the data of that image, and is embedded only once.
+func (f *Fpdf) registerImage(imgName string, info *ImageInfoType) {
 	if info.i, f.err = generateImageID(info); f.err != nil {
 		return
 	}
+	for _, other := range f.images {
+		if other.i == info.i && other.lazy == nil {
+			info.data, info.smask, info.icc = other.data, other.smask, other.icc
+			break
+		}
+	}
 	f.images[imgName] = info
-
-	return
 }
 
 // RegisterImage registers an image, adding it to the PDF file but not adding
@@ -3233,19 +4088,16 @@ func (f *Fpdf) RegisterImage(fileStr, tp string) (info *ImageInfoType) {
 // to the page. Note that Image() calls this function, so this function is only
 // necessary if you need information about the image before placing it. See
 // Image() for restrictions on the image and the "tp" parameters.
+//
+// An image is embedded in the PDF file only once it has been placed on a page
+// or in a template that is used. Images with the same content, registered
+// with different names, are embedded once.
 func (f *Fpdf) RegisterImageOptions(fileStr string, options ImageOptions) (info *ImageInfoType) {
 	info, ok := f.images[fileStr]
 	if ok {
 		return
 	}
 
-	file, err := os.Open(fileStr)
-	if err != nil {
-		f.err = err
-		return
-	}
-	defer file.Close()
-
 	// First use of this image, get info
 	if options.ImageType == "" {
 		pos := strings.LastIndex(fileStr, ".")
@@ -3255,7 +4107,18 @@ func (f *Fpdf) RegisterImageOptions(fileStr string, options ImageOptions) (info
 		}
 		options.ImageType = fileStr[pos+1:]
 	}
+	if options.Deferred {
+		return f.RegisterImageOptionsOpener(fileStr, options, func() (io.ReadCloser, error) {
+			return os.Open(fileStr)
+		})
+	}
 
+	file, err := os.Open(fileStr)
+	if err != nil {
+		f.err = err
+		return
+	}
+	defer file.Close()
 	return f.RegisterImageOptionsReader(fileStr, options, file)
 }
 
@@ -3282,6 +4145,10 @@ func (f *Fpdf) ImportObjPos(objPos map[string]map[int]string) {
 
 // putImportedTemplates writes the imported template objects to the PDF
 func (f *Fpdf) putImportedTemplates() {
+	if f.protect.encrypted && len(f.importedPages) > 0 {
+		f.err = fmt.Errorf("imported pages cannot be encrypted")
+		return
+	}
 	nOffset := f.n + 1
 
 	// keep track of list of sha1 hashes (to be replaced with integers)
@@ -3290,14 +4157,17 @@ func (f *Fpdf) putImportedTemplates() {
 	// actual object data with new id
 	objsIDData := make([][]byte, len(f.importedObjs))
 
-	// Populate hash slice and data slice
+	// Populate hash slice and data slice, in the order of the hashes so that
+	// the object ids do not vary from one output to the next
 	i := 0
-	for k, v := range f.importedObjs {
+	for k := range f.importedObjs {
 		objsIDHash[i] = k
-		objsIDData[i] = v
-
 		i++
 	}
+	sort.Strings(objsIDHash)
+	for i = 0; i < len(objsIDHash); i++ {
+		objsIDData[i] = f.importedObjs[objsIDHash[i]]
+	}
 
 	// Populate a lookup table to get an object id from a hash
 	hashToObjID := make(map[string]int, len(f.importedObjs))
@@ -3332,6 +4202,13 @@ func (f *Fpdf) putImportedTemplates() {
 		f.newobj()
 		f.out(string(objsIDData[i]))
 	}
+
+	// Replace the hashes of the imported annotations of the pages, which
+	// have been written already
+	buf := f.buffer.Bytes()
+	for pos, h := range f.importedAnnotPos {
+		copy(buf[pos:pos+40], fmt.Sprintf("%40d", f.importedTplIDs[h]))
+	}
 }
 
 // UseImportedTemplate uses imported template from gofpdi. It draws imported
@@ -3441,6 +4318,32 @@ func (f *Fpdf) SetProtection(actionFlag byte, userPassStr, ownerPassStr string)
 	f.protect.setProtection(actionFlag, userPassStr, ownerPassStr)
 }
 
+// SetProtectionEx applies certain constraints on the finished PDF document
+// using the encryption algorithm specified by mode. EncryptionRC4 selects the
+// 40-bit RC4 scheme used by SetProtection. EncryptionAES256 selects the
+// 256-bit AES scheme defined by PDF 2.0; strings and streams are encrypted
+// with the AESV3 crypt filter and the PDF version of the document is raised
+// to 2.0. Readers that predate this scheme will not be able to open the
+// document.
+//
+// actionFlag, userPassStr and ownerPassStr have the same meaning as they do
+// in SetProtection. Passwords longer than 127 bytes are truncated when
+// EncryptionAES256 is used.
+func (f *Fpdf) SetProtectionEx(mode int, actionFlag byte, userPassStr, ownerPassStr string) {
+	if f.err != nil {
+		return
+	}
+	switch mode {
+	case EncryptionRC4:
+		f.protect.setProtection(actionFlag, userPassStr, ownerPassStr)
+	case EncryptionAES256:
+		f.protect.setProtectionAES256(actionFlag, userPassStr, ownerPassStr)
+		f.err = f.protect.err
+	default:
+		f.err = fmt.Errorf("unsupported encryption mode %d", mode)
+	}
+}
+
 // OutputAndClose sends the PDF document to the writer specified by w. This
 // method will close both f and w, even if an error is detected and no document
 // is produced.
@@ -3477,8 +4380,15 @@ func (f *Fpdf) Output(w io.Writer) error {
 		return f.err
 	}
 	// dbg("Output")
+	if f.outStream != nil {
+		f.err = fmt.Errorf("document is written to the output writer set with SetOutputWriter()")
+		return f.err
+	}
 	if f.state < 3 {
 		f.Close()
+		if f.err != nil {
+			return f.err
+		}
 	}
 	_, err := f.buffer.WriteTo(w)
 	if err != nil {
@@ -3523,6 +4433,7 @@ func (f *Fpdf) beginpage(orientationStr string, size SizeType) {
 		return
 	}
 	f.page++
+	f.pageClosed = false
 	// add the default page boxes, if any exist, to the page
 	f.pageBoxes[f.page] = make(map[string]PageBox)
 	for box, pb := range f.defPageBoxes {
@@ -3559,11 +4470,17 @@ func (f *Fpdf) beginpage(orientationStr string, size SizeType) {
 	if orientationStr != f.defOrientation || size.Wd != f.defPageSize.Wd || size.Ht != f.defPageSize.Ht {
 		f.pageSizes[f.page] = SizeType{f.wPt, f.hPt}
 	}
+	f.addPageBoxes(f.page, f.defBoxes)
 	return
 }
 
 func (f *Fpdf) endpage() {
-	f.EndLayer()
+	f.layerEndAll()
+	f.artifactEndAll()
+	if f.state == 2 && !f.pageClosed {
+		f.autoPageEnd()
+		f.putWatermarks(WatermarkOver)
+	}
 	f.state = 1
 }
 
@@ -3605,7 +4522,7 @@ func (f *Fpdf) escape(s string) string {
 func (f *Fpdf) textstring(s string) string {
 	if f.protect.encrypted {
 		b := []byte(s)
-		f.protect.rc4(uint32(f.n), &b)
+		f.protect.encrypt(uint32(f.n), &b)
 		s = string(b)
 	}
 	return "(" + f.escape(s) + ")"
@@ -3629,17 +4546,29 @@ func (f *Fpdf) SetUnderlineThickness(thickness float64) {
 
 // Underline text
 func (f *Fpdf) dounderline(x, y float64, txt string) string {
+	return f.underlineRect(x, y, f.GetStringWidth(txt)+f.ws*float64(blankCount(txt)))
+}
+
+// underlineRect returns the operators that draw an underline of width w
+// below the baseline at (x, y), raised by the text rise
+func (f *Fpdf) underlineRect(x, y, w float64) string {
+	y -= f.textRise / f.k
 	up := float64(f.currentFont.Up)
 	ut := float64(f.currentFont.Ut) * f.userUnderlineThickness
-	w := f.GetStringWidth(txt) + f.ws*float64(blankCount(txt))
 	return sprintf("%.2f %.2f %.2f %.2f re f", x*f.k,
 		(f.h-(y-up/1000*f.fontSize))*f.k, w*f.k, -ut/1000*f.fontSizePt)
 }
 
 func (f *Fpdf) dostrikeout(x, y float64, txt string) string {
+	return f.strikeoutRect(x, y, f.GetStringWidth(txt)+f.ws*float64(blankCount(txt)))
+}
+
+// strikeoutRect returns the operators that draw a strike-out line of width w
+// above the baseline at (x, y), raised by the text rise
+func (f *Fpdf) strikeoutRect(x, y, w float64) string {
+	y -= f.textRise / f.k
 	up := float64(f.currentFont.Up)
 	ut := float64(f.currentFont.Ut)
-	w := f.GetStringWidth(txt) + f.ws*float64(blankCount(txt))
 	return sprintf("%.2f %.2f %.2f %.2f re f", x*f.k,
 		(f.h-(y+4*up/1000*f.fontSize))*f.k, w*f.k, -ut/1000*f.fontSizePt)
 }
@@ -3692,6 +4621,8 @@ func (f *Fpdf) parsejpg(r io.Reader) (info *ImageInfoType) {
 		f.err = fmt.Errorf("image JPEG buffer has unsupported color space (%v)", config.ColorModel)
 		return
 	}
+	info.icc = imageICCProfile(jpegICCProfile(info.data), iccComponents[info.cs])
+	info.orientation = jpegOrientation(info.data)
 	return
 }
 
@@ -3750,20 +4681,48 @@ func (f *Fpdf) newobj() {
 	for j := len(f.offsets); j <= f.n; j++ {
 		f.offsets = append(f.offsets, 0)
 	}
-	f.offsets[f.n] = f.buffer.Len()
+	f.offsets[f.n] = f.offset()
 	f.outf("%d 0 obj", f.n)
 }
 
 func (f *Fpdf) putstream(b []byte) {
 	// dbg("putstream")
 	if f.protect.encrypted {
-		f.protect.rc4(uint32(f.n), &b)
+		f.protect.encrypt(uint32(f.n), &b)
 	}
 	f.out("stream")
 	f.out(string(b))
 	f.out("endstream")
 }
 
+// filterName returns the name of the filter of the streams that are
+// compressed with compressStream()
+func (f *Fpdf) filterName() string {
+	if f.streamFilter != nil {
+		return f.streamFilter.Name()
+	}
+	return "FlateDecode"
+}
+
+// compressStream returns the data of a stream encoded with the stream filter
+// set with SetStreamFilter() or compressed with zlib
+func (f *Fpdf) compressStream(data []byte) []byte {
+	if f.streamFilter == nil {
+		return f.compressFlate(data)
+	}
+	data, err := f.streamFilter.Encode(data)
+	if err != nil && f.err == nil {
+		f.err = fmt.Errorf("unable to encode stream: %s", err)
+	}
+	return data
+}
+
+// compressFlate returns data compressed with zlib at the compression level of
+// the document, for streams that are decoded with FlateDecode
+func (f *Fpdf) compressFlate(data []byte) []byte {
+	return sliceCompressLevel(data, f.compressLevel)
+}
+
 // out; Add a line to the document
 func (f *Fpdf) out(s string) {
 	if f.state == 2 {
@@ -3868,19 +4827,26 @@ func (f *Fpdf) RegisterAlias(alias, replacement string) {
 }
 
 func (f *Fpdf) replaceAliases() {
+	for n := 1; n <= f.page; n++ {
+		f.replacePageCounters(n)
+		f.replacePageAliases(n)
+		f.resolvePageShifts(n)
+	}
+}
+
+// replacePageAliases replaces the aliases registered so far on page n
+func (f *Fpdf) replacePageAliases(n int) {
 	for mode := 0; mode < 2; mode++ {
 		for alias, replacement := range f.aliasMap {
 			if mode == 1 {
 				alias = utf8toutf16(alias, false)
 				replacement = utf8toutf16(replacement, false)
 			}
-			for n := 1; n <= f.page; n++ {
-				s := f.pages[n].String()
-				if strings.Contains(s, alias) {
-					s = strings.Replace(s, alias, replacement, -1)
-					f.pages[n].Truncate(0)
-					f.pages[n].WriteString(s)
-				}
+			s := f.pages[n].String()
+			if strings.Contains(s, alias) {
+				s = strings.Replace(s, alias, replacement, -1)
+				f.pages[n].Truncate(0)
+				f.pages[n].WriteString(s)
 			}
 		}
 	}
@@ -3903,6 +4869,12 @@ func (f *Fpdf) putpages() {
 		wPt = f.defPageSize.Ht * f.k
 		hPt = f.defPageSize.Wd * f.k
 	}
+	if f.outStream != nil {
+		// The content streams of the pages that have not been flushed
+		// precede the page dictionaries, whose numbers follow each other
+		f.flushContents(nb)
+	}
+	f.pageBase = f.n
 	pagesObjectNumbers := make([]int, nb+1) // 1-based
 	for n := 1; n <= nb; n++ {
 		// Page
@@ -3911,59 +4883,76 @@ func (f *Fpdf) putpages() {
 		f.out("<</Type /Page")
 		f.out("/Parent 1 0 R")
 		pageSize, ok = f.pageSizes[n]
-		if ok {
+		if ht, auto := f.autoPageHeights[n]; auto {
+			// The page extends from its top down to the end of its content
+			if !ok {
+				pageSize = SizeType{Wd: wPt, Ht: hPt}
+			}
+			f.outf("/MediaBox [0 %.2f %.2f %.2f]", pageSize.Ht-ht, pageSize.Wd, pageSize.Ht)
+		} else if ok {
 			f.outf("/MediaBox [0 0 %.2f %.2f]", pageSize.Wd, pageSize.Ht)
 		}
-		for t, pb := range f.pageBoxes[n] {
-			f.outf("/%s [%.2f %.2f %.2f %.2f]", t, pb.X, pb.Y, pb.Wd, pb.Ht)
+		for _, t := range pageBoxNames {
+			if pb, ok := f.pageBoxes[n][t]; ok {
+				f.outf("/%s [%.2f %.2f %.2f %.2f]", t, pb.X, pb.Y, pb.Wd, pb.Ht)
+			}
+		}
+		if rotate, ok := f.pageRotations[n]; ok {
+			f.outf("/Rotate %d", rotate)
 		}
 		f.out("/Resources 2 0 R")
+		f.structPutPage(n)
+		f.actionPutPage(n)
 		// Links
-		if len(f.pageLinks[n])+len(f.pageAttachments[n]) > 0 {
+		if len(f.pageLinks[n])+len(f.pageAttachments[n])+len(f.pageMarkups[n])+f.formWidgetCount(n)+len(f.importedAnnots[n]) > 0 {
 			var annots fmtBuffer
 			annots.printf("/Annots [")
 			for _, pl := range f.pageLinks[n] {
 				annots.printf("<</Type /Annot /Subtype /Link /Rect [%.2f %.2f %.2f %.2f] /Border [0 0 0] ",
 					pl.x, pl.y, pl.x+pl.wd, pl.y-pl.ht)
-				if pl.link == 0 {
-					annots.printf("/A <</S /URI /URI %s>>>>", f.textstring(pl.linkStr))
-				} else {
-					l := f.links[pl.link]
-					var sz SizeType
-					var h float64
-					sz, ok = f.pageSizes[l.page]
-					if ok {
-						h = sz.Ht
-					} else {
-						h = hPt
-					}
-					// dbg("h [%.2f], l.y [%.2f] f.k [%.2f]\n", h, l.y, f.k)
-					annots.printf("/Dest [%d 0 R /XYZ 0 %.2f null]>>", 1+2*l.page, h-l.y*f.k)
+				if f.pdfa.part > 0 {
+					// PDF/A requires annotations to be printable
+					annots.printf("/F 4 ")
 				}
+				annots.printf("%s>>", f.linkAction(pl))
 			}
 			f.putAttachmentAnnotationLinks(&annots, n)
+			f.putMarkupAnnotRefs(&annots, n)
+			f.putFormWidgetRefs(&annots, n)
+			// The hashes of imported annotations are replaced with their object
+			// ids once the imported objects are numbered
+			pos := make(map[int]string)
+			for _, h := range f.importedAnnots[n] {
+				pos[annots.Len()] = h
+				annots.printf("%s 0 R ", h)
+			}
 			annots.printf("]")
+			if len(pos) > 0 {
+				if f.importedAnnotPos == nil {
+					f.importedAnnotPos = make(map[int]string)
+				}
+				for offset, h := range pos {
+					f.importedAnnotPos[f.buffer.Len()+offset] = h
+				}
+			}
 			f.out(annots.String())
 		}
 		if f.pdfVersion > "1.3" {
-			f.out("/Group <</Type /Group /S /Transparency /CS /DeviceRGB>>")
+			f.outf("/Group <</Type /Group /S /Transparency /CS /%s>>", f.groupColorSpace())
+		}
+		if f.outStream != nil {
+			f.outf("/Contents %d 0 R>>", f.outStream.contents[n])
+			f.out("endobj")
+			continue
 		}
 		f.outf("/Contents %d 0 R>>", f.n+1)
 		f.out("endobj")
 		// Page content
 		f.newobj()
-		if f.compress {
-			data := sliceCompress(f.pages[n].Bytes())
-			f.outf("<</Filter /FlateDecode /Length %d>>", len(data))
-			f.putstream(data)
-		} else {
-			f.outf("<</Length %d>>", f.pages[n].Len())
-			f.putstream(f.pages[n].Bytes())
-		}
-		f.out("endobj")
+		f.putPageContent(n)
 	}
 	// Pages root
-	f.offsets[1] = f.buffer.Len()
+	f.offsets[1] = f.offset()
 	f.out("1 0 obj")
 	f.out("<</Type /Pages")
 	var kids fmtBuffer
@@ -3979,6 +4968,19 @@ func (f *Fpdf) putpages() {
 	f.out("endobj")
 }
 
+// putPageContent writes the content stream of page n as the current object
+func (f *Fpdf) putPageContent(n int) {
+	if f.compress {
+		data := f.compressStream(f.pages[n].Bytes())
+		f.outf("<</Filter /%s /Length %d>>", f.filterName(), f.protect.streamLength(len(data)))
+		f.putstream(data)
+	} else {
+		f.outf("<</Length %d>>", f.protect.streamLength(f.pages[n].Len()))
+		f.putstream(f.pages[n].Bytes())
+	}
+	f.out("endobj")
+}
+
 func (f *Fpdf) putfonts() {
 	if f.err != nil {
 		return
@@ -4025,7 +5027,7 @@ func (f *Fpdf) putfonts() {
 					buf = append(buf, font[6+info.length1+6:info.length2]...)
 					font = buf
 				}
-				f.outf("<</Length %d", len(font))
+				f.outf("<</Length %d", f.protect.streamLength(len(font)))
 				if compressed {
 					f.out("/Filter /FlateDecode")
 				}
@@ -4051,9 +5053,16 @@ func (f *Fpdf) putfonts() {
 		}
 		for _, key = range keyList {
 			font = f.fonts[key]
+			// Fonts that have been added under several keys are written once
+			if n, found := f.resourceObjs["F"+font.i]; found {
+				font.N = n
+				f.fonts[key] = font
+				continue
+			}
 			// Font objects
 			font.N = f.n + 1
 			f.fonts[key] = font
+			f.resourceObjs["F"+font.i] = font.N
 			tp := font.Tp
 			name := font.Name
 			switch tp {
@@ -4117,12 +5126,20 @@ func (f *Fpdf) putfonts() {
 				f.out(s.String())
 				f.out("endobj")
 			case "UTF8":
+				if font.utf8File.cff != nil {
+					f.putCFFFont(font)
+					break
+				}
 				fontName := "utf8" + font.Name
 				usedRunes := font.usedRunes
 				delete(usedRunes, 0)
-				utf8FontStream := font.utf8File.GenerateCutFont(usedRunes)
+				utf8FontStream, err := font.utf8File.GenerateCutFont(usedRunes, false)
+				if err != nil {
+					f.err = fmt.Errorf("unable to subset font %s: %s", font.Name, err)
+					return
+				}
 				utf8FontSize := len(utf8FontStream)
-				compressedFontStream := sliceCompress(utf8FontStream)
+				compressedFontStream := f.compressFlate(utf8FontStream)
 				CodeSignDictionary := font.utf8File.CodeSymbolDictionary
 				delete(CodeSignDictionary, 0)
 
@@ -4140,7 +5157,7 @@ func (f *Fpdf) putfonts() {
 				f.out("endobj")
 
 				f.newobj()
-				f.out("<</Length " + strconv.Itoa(len(toUnicode)) + ">>")
+				f.out("<</Length " + strconv.Itoa(f.protect.streamLength(len(toUnicode))) + ">>")
 				f.putstream([]byte(toUnicode))
 				f.out("endobj")
 
@@ -4177,15 +5194,15 @@ func (f *Fpdf) putfonts() {
 					cidToGidMap[cc*2+1] = byte(glyph & 0xFF)
 				}
 
-				cidToGidMap = sliceCompress(cidToGidMap)
+				cidToGidMap = f.compressFlate(cidToGidMap)
 				f.newobj()
-				f.out("<</Length " + strconv.Itoa(len(cidToGidMap)) + "/Filter /FlateDecode>>")
+				f.out("<</Length " + strconv.Itoa(f.protect.streamLength(len(cidToGidMap))) + "/Filter /FlateDecode>>")
 				f.putstream(cidToGidMap)
 				f.out("endobj")
 
 				//Font file
 				f.newobj()
-				f.out("<</Length " + strconv.Itoa(len(compressedFontStream)))
+				f.out("<</Length " + strconv.Itoa(f.protect.streamLength(len(compressedFontStream))))
 				f.out("/Filter /FlateDecode")
 				f.out("/Length1 " + strconv.Itoa(utf8FontSize))
 				f.out(">>")
@@ -4364,72 +5381,141 @@ func (f *Fpdf) putimages() {
 		sort.SliceStable(keyList, func(i, j int) bool { return f.images[keyList[i]].w < f.images[keyList[j]].w })
 	}
 
-	// Maintain a list of inserted image SHA-1 hashes, with their
-	// corresponding object ID number.
-	insertedImages := map[string]int{}
-
+	// The images of the templates, which are usually the same as images of
+	// the document or of other templates, follow those of the document.
+	images := make([]*ImageInfoType, 0, len(keyList))
 	for _, key = range keyList {
-		image := f.images[key]
+		if f.imagesUsed[f.images[key].i] {
+			images = append(images, f.images[key])
+		}
+	}
+	for _, t := range sortTemplates(f.templates, true) {
+		tImages := t.Images()
+		keyList = keyList[:0]
+		for key = range tImages {
+			keyList = append(keyList, key)
+		}
+		sort.Strings(keyList)
+		for _, key = range keyList {
+			images = append(images, tImages[key])
+		}
+	}
 
+	for _, image := range images {
 		// Check if this image has already been inserted using it's SHA-1 hash.
-		insertedImageObjN, isFound := insertedImages[image.i]
+		insertedImageObjN, isFound := f.resourceObjs["I"+image.i]
 
 		// If found, skip inserting the image as a new object, and
-		// use the object ID from the insertedImages map.
+		// use the object ID from the resource registry.
 		// If not, insert the image into the PDF and store the object ID.
 		if isFound {
 			image.n = insertedImageObjN
 		} else {
 			f.putimage(image)
-			insertedImages[image.i] = image.n
+			f.resourceObjs["I"+image.i] = image.n
 		}
 	}
 }
 
 func (f *Fpdf) putimage(info *ImageInfoType) {
+	if f.err = info.loadImage(); f.err != nil {
+		return
+	}
+	defer info.unloadImage()
 	f.newobj()
 	info.n = f.n
 	f.out("<</Type /XObject")
 	f.out("/Subtype /Image")
 	f.outf("/Width %d", int(info.w))
 	f.outf("/Height %d", int(info.h))
+	// The soft mask, the palette, the ICC profile and the mask image follow
+	// the image
+	palObj := f.n + 1
+	if len(info.smask) > 0 {
+		palObj++
+	}
+	next := palObj
 	if info.cs == "Indexed" {
-		f.outf("/ColorSpace [/Indexed /DeviceRGB %d %d 0 R]", len(info.pal)/3-1, f.n+1)
-	} else {
-		f.outf("/ColorSpace /%s", info.cs)
-		if info.cs == "DeviceCMYK" {
+		next++
+	}
+	base, components := info.cs, iccComponents[info.cs]
+	if info.cs == "Indexed" {
+		base, components = "DeviceRGB", 3
+	}
+	base = "/" + base
+	var iccObj int
+	var putICC bool
+	if len(info.icc) > 0 {
+		iccObj, putICC = f.imageICCObject(info, next)
+		base = sprintf("[/ICCBased %d 0 R]", iccObj)
+		if putICC {
+			next++
+		}
+	}
+	var maskObj int
+	var putMask bool
+	if info.mask != nil {
+		maskObj, putMask = f.imageMaskObject(info.mask, next)
+	}
+	if info.cs == "Indexed" {
+		f.outf("/ColorSpace [/Indexed %s %d %d 0 R]", base, len(info.pal)/3-1, palObj)
+	} else if info.cs != "" {
+		// JPEG 2000 images without a color space use the one of their data
+		f.outf("/ColorSpace %s", base)
+		if info.cs == "DeviceCMYK" && info.f != "JPXDecode" {
 			f.out("/Decode [1 0 1 0 1 0 1 0]")
 		}
 	}
-	f.outf("/BitsPerComponent %d", info.bpc)
+	if info.f != "JPXDecode" {
+		// The bit depth of JPEG 2000 images is that of their data
+		f.outf("/BitsPerComponent %d", info.bpc)
+	}
 	if len(info.f) > 0 {
 		f.outf("/Filter /%s", info.f)
 	}
 	if len(info.dp) > 0 {
 		f.outf("/DecodeParms <<%s>>", info.dp)
 	}
-	if len(info.trns) > 0 {
+	switch {
+	case len(info.cmask) > 0:
+		var cmask fmtBuffer
+		for _, v := range info.cmask {
+			cmask.printf("%d ", v)
+		}
+		f.outf("/Mask [%s]", cmask.String())
+	case len(info.trns) > 0:
 		var trns fmtBuffer
 		for _, v := range info.trns {
 			trns.printf("%d %d ", v, v)
 		}
 		f.outf("/Mask [%s]", trns.String())
 	}
-	if info.smask != nil {
+	switch {
+	case len(info.smask) > 0:
 		f.outf("/SMask %d 0 R", f.n+1)
+	case info.mask != nil && info.mask.bpc == 1:
+		f.outf("/Mask %d 0 R", maskObj)
+	case info.mask != nil:
+		f.outf("/SMask %d 0 R", maskObj)
 	}
-	f.outf("/Length %d>>", len(info.data))
+	f.outf("/Length %d>>", f.protect.streamLength(len(info.data)))
 	f.putstream(info.data)
 	f.out("endobj")
 	// 	Soft mask
 	if len(info.smask) > 0 {
+		// The soft masks of images with 16 bits per component have 16 bits
+		// per pixel as well
+		bpc := 8
+		if info.bpc == 16 {
+			bpc = 16
+		}
 		smask := &ImageInfoType{
 			w:     info.w,
 			h:     info.h,
 			cs:    "DeviceGray",
-			bpc:   8,
+			bpc:   bpc,
 			f:     info.f,
-			dp:    sprintf("/Predictor 15 /Colors 1 /BitsPerComponent 8 /Columns %d", int(info.w)),
+			dp:    sprintf("/Predictor 15 /Colors 1 /BitsPerComponent %d /Columns %d", bpc, int(info.w)),
 			data:  info.smask,
 			scale: f.k,
 		}
@@ -4439,15 +5525,23 @@ func (f *Fpdf) putimage(info *ImageInfoType) {
 	if info.cs == "Indexed" {
 		f.newobj()
 		if f.compress {
-			pal := sliceCompress(info.pal)
-			f.outf("<</Filter /FlateDecode /Length %d>>", len(pal))
+			pal := f.compressStream(info.pal)
+			f.outf("<</Filter /%s /Length %d>>", f.filterName(), f.protect.streamLength(len(pal)))
 			f.putstream(pal)
 		} else {
-			f.outf("<</Length %d>>", len(info.pal))
+			f.outf("<</Length %d>>", f.protect.streamLength(len(info.pal)))
 			f.putstream(info.pal)
 		}
 		f.out("endobj")
 	}
+	// 	ICC profile
+	if putICC {
+		f.putImageICC(info, components)
+	}
+	// 	Mask image
+	if putMask {
+		f.putImageMask(info.mask)
+	}
 }
 
 func (f *Fpdf) putxobjectdict() {
@@ -4461,9 +5555,13 @@ func (f *Fpdf) putxobjectdict() {
 		if f.catalogSort {
 			sort.SliceStable(keyList, func(i, j int) bool { return f.images[keyList[i]].i < f.images[keyList[j]].i })
 		}
+		written := make(map[string]bool, len(keyList))
 		for _, key = range keyList {
 			image = f.images[key]
-			f.outf("/I%s %d 0 R", image.i, image.n)
+			if !written[image.i] && f.imagesUsed[image.i] {
+				written[image.i] = true
+				f.outf("/I%s %d 0 R", image.i, image.n)
+			}
 		}
 	}
 	{
@@ -4481,11 +5579,19 @@ func (f *Fpdf) putxobjectdict() {
 		}
 	}
 	{
-		for tplName, objID := range f.importedTplObjs {
+		var keyList []string
+		for tplName := range f.importedTplObjs {
+			keyList = append(keyList, tplName)
+		}
+		sort.Strings(keyList)
+		for _, tplName := range keyList {
 			// here replace obj id hash with n
-			f.outf("%s %d 0 R", tplName, f.importedTplIDs[objID])
+			f.outf("%s %d 0 R", tplName, f.importedTplIDs[f.importedTplObjs[tplName]])
 		}
 	}
+	for j, gr := range f.groups {
+		f.outf("/TG%d %d 0 R", j+1, gr.objNum)
+	}
 }
 
 func (f *Fpdf) putresourcedict() {
@@ -4501,9 +5607,13 @@ func (f *Fpdf) putresourcedict() {
 		if f.catalogSort {
 			sort.SliceStable(keyList, func(i, j int) bool { return f.fonts[keyList[i]].i < f.fonts[keyList[j]].i })
 		}
+		written := make(map[string]bool, len(keyList))
 		for _, key = range keyList {
 			font = f.fonts[key]
-			f.outf("/F%s %d 0 R", font.i, font.N)
+			if !written[font.i] {
+				written[font.i] = true
+				f.outf("/F%s %d 0 R", font.i, font.N)
+			}
 		}
 	}
 	f.out(">>")
@@ -4511,11 +5621,17 @@ func (f *Fpdf) putresourcedict() {
 	f.putxobjectdict()
 	f.out(">>")
 	count := len(f.blendList)
-	if count > 1 {
+	if count > 1 || len(f.softMaskStates) > 0 || len(f.overprintStates) > 0 {
 		f.out("/ExtGState <<")
 		for j := 1; j < count; j++ {
 			f.outf("/GS%d %d 0 R", j, f.blendList[j].objNum)
 		}
+		for j, st := range f.overprintStates {
+			f.outf("/OP%d %d 0 R", j+1, st.objNum)
+		}
+		for j, st := range f.softMaskStates {
+			f.outf("/SM%d %d 0 R", j+1, st.objNum)
+		}
 		f.out(">>")
 	}
 	count = len(f.gradientList)
@@ -4526,6 +5642,20 @@ func (f *Fpdf) putresourcedict() {
 		}
 		f.out(">>")
 	}
+	var patterns fmtBuffer
+	for j := 1; j < count; j++ {
+		if f.gradientList[j].pattern {
+			patterns.printf("/P%d %d 0 R\n", j, f.gradientList[j].patternObjNum)
+		}
+	}
+	for j, pt := range f.patterns {
+		patterns.printf("/TP%d %d 0 R\n", j+1, pt.objNum)
+	}
+	if patterns.Len() > 0 {
+		f.out("/Pattern <<")
+		f.out(strings.TrimSuffix(patterns.String(), "\n"))
+		f.out(">>")
+	}
 	// Layers
 	f.layerPutResourceDict()
 	f.spotColorPutResourceDict()
@@ -4548,24 +5678,64 @@ func (f *Fpdf) putGradients() {
 	for j := 1; j < count; j++ {
 		var f1 int
 		gr := f.gradientList[j]
-		if gr.tp == 2 || gr.tp == 3 {
+		if len(gr.stops) > 2 {
+			f1 = f.putGradientStops(gr.stops)
+		} else if gr.tp == 2 || gr.tp == 3 {
 			f.newobj()
 			f.outf("<</FunctionType 2 /Domain [0.0 1.0] /C0 [%s] /C1 [%s] /N 1>>", gr.clr1Str, gr.clr2Str)
 			f.out("endobj")
 			f1 = f.n
 		}
+		space, extend := gr.space, gr.extend
+		if space == "" {
+			space = "DeviceRGB"
+		}
+		if extend == "" {
+			extend = "true true"
+		}
 		f.newobj()
-		f.outf("<</ShadingType %d /ColorSpace /DeviceRGB", gr.tp)
+		f.outf("<</ShadingType %d /ColorSpace /%s", gr.tp, space)
 		if gr.tp == 2 {
-			f.outf("/Coords [%.5f %.5f %.5f %.5f] /Function %d 0 R /Extend [true true]>>",
-				gr.x1, gr.y1, gr.x2, gr.y2, f1)
+			f.outf("/Coords [%.5f %.5f %.5f %.5f] /Function %d 0 R /Extend [%s]>>",
+				gr.x1, gr.y1, gr.x2, gr.y2, f1, extend)
 		} else if gr.tp == 3 {
-			f.outf("/Coords [%.5f %.5f 0 %.5f %.5f %.5f] /Function %d 0 R /Extend [true true]>>",
-				gr.x1, gr.y1, gr.x2, gr.y2, gr.r, f1)
+			r0Str := "0"
+			if gr.r0 != 0 {
+				r0Str = sprintf("%.5f", gr.r0)
+			}
+			f.outf("/Coords [%.5f %.5f %s %.5f %.5f %.5f] /Function %d 0 R /Extend [%s]>>",
+				gr.x1, gr.y1, r0Str, gr.x2, gr.y2, gr.r, f1, extend)
 		}
 		f.out("endobj")
 		f.gradientList[j].objNum = f.n
+		if gr.pattern {
+			f.newobj()
+			f.outf("<</Type /Pattern /PatternType 2 /Shading %d 0 R>>", f.n-1)
+			f.out("endobj")
+			f.gradientList[j].patternObjNum = f.n
+		}
+	}
+}
+
+// putGradientStops writes a function that blends each color of stops into
+// the next one, and returns its object number
+func (f *Fpdf) putGradientStops(stops []gradientStopType) int {
+	var fns, bounds, encode fmtBuffer
+	for j := 1; j < len(stops); j++ {
+		f.newobj()
+		f.outf("<</FunctionType 2 /Domain [0.0 1.0] /C0 [%s] /C1 [%s] /N 1>>", stops[j-1].clrStr, stops[j].clrStr)
+		f.out("endobj")
+		fns.printf("%d 0 R ", f.n)
+		if j > 1 {
+			bounds.printf("%.5f ", stops[j-1].offset)
+		}
+		encode.printf("0 1 ")
 	}
+	f.newobj()
+	f.outf("<</FunctionType 3 /Domain [0.0 1.0] /Functions [%s] /Bounds [%s] /Encode [%s]>>",
+		strings.TrimSpace(fns.String()), strings.TrimSpace(bounds.String()), strings.TrimSpace(encode.String()))
+	f.out("endobj")
+	return f.n
 }
 
 func (f *Fpdf) putjavascript() {
@@ -4593,8 +5763,14 @@ func (f *Fpdf) putresources() {
 	}
 	f.layerPutLayers()
 	f.putBlendModes()
+	f.putOverprints()
 	f.putGradients()
+	f.putPatterns()
+	f.putSoftMasks()
+	f.putGroups()
 	f.putSpotColors()
+	f.putICCProfiles()
+	f.resourceObjs = make(map[string]int)
 	f.putfonts()
 	if f.err != nil {
 		return
@@ -4603,7 +5779,7 @@ func (f *Fpdf) putresources() {
 	f.putTemplates()
 	f.putImportedTemplates() // gofpdi
 	// 	Resource dictionary
-	f.offsets[2] = f.buffer.Len()
+	f.offsets[2] = f.offset()
 	f.out("2 0 obj")
 	f.out("<<")
 	f.putresourcedict()
@@ -4615,10 +5791,24 @@ func (f *Fpdf) putresources() {
 		f.protect.objNum = f.n
 		f.out("<<")
 		f.out("/Filter /Standard")
-		f.out("/V 1")
-		f.out("/R 2")
-		f.outf("/O (%s)", f.escape(string(f.protect.oValue)))
-		f.outf("/U (%s)", f.escape(string(f.protect.uValue)))
+		if f.protect.mode == EncryptionAES256 {
+			f.out("/V 5")
+			f.out("/R 6")
+			f.out("/Length 256")
+			f.out("/CF <</StdCF <</AuthEvent /DocOpen /CFM /AESV3 /Length 32>>>>")
+			f.out("/StmF /StdCF")
+			f.out("/StrF /StdCF")
+			f.outf("/O <%s>", hex.EncodeToString(f.protect.oValue))
+			f.outf("/U <%s>", hex.EncodeToString(f.protect.uValue))
+			f.outf("/OE <%s>", hex.EncodeToString(f.protect.oeValue))
+			f.outf("/UE <%s>", hex.EncodeToString(f.protect.ueValue))
+			f.outf("/Perms <%s>", hex.EncodeToString(f.protect.permsValue))
+		} else {
+			f.out("/V 1")
+			f.out("/R 2")
+			f.outf("/O (%s)", f.escape(string(f.protect.oValue)))
+			f.outf("/U (%s)", f.escape(string(f.protect.uValue)))
+		}
 		f.outf("/P %d", f.protect.pValue)
 		f.out(">>")
 		f.out("endobj")
@@ -4657,25 +5847,45 @@ func (f *Fpdf) putinfo() {
 	f.outf("/CreationDate %s", f.textstring("D:"+creation.Format("20060102150405")))
 	mod := timeOrNow(f.modDate)
 	f.outf("/ModDate %s", f.textstring("D:"+mod.Format("20060102150405")))
+	if f.pdfx.version != "" {
+		f.outf("/GTS_PDFXVersion %s /Trapped /False", f.textstring(f.pdfx.version))
+	}
+	keys := make([]string, 0, len(f.customInfo))
+	for key := range f.customInfo {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		f.outf("%s %s", pdfName(key), f.textstring(utf8toutf16(f.customInfo[key])))
+	}
 }
 
 func (f *Fpdf) putcatalog() {
 
 	f.out("/Type /Catalog")
 	f.out("/Pages 1 0 R")
+	if f.outStream != nil && f.pdfVersion > f.outStream.version {
+		// The header has been written before the features of a later version
+		// were used
+		f.outf("/Version /%s", f.pdfVersion)
+	}
 
 	xmpDataIsPresent := len(f.xmp) != 0
 
 	if f.addOutputIntent {
-
-		var outputIntentReference int
-		if xmpDataIsPresent {
-			outputIntentReference = f.n - 3
+		if f.outputProfile > 0 {
+			// The profile serves as the output intent of both PDF/A and PDF/X
+			pr := f.iccProfiles[f.outputProfile-1]
+			cond := f.textstring(f.outputCondition)
+			f.out("/OutputIntents[")
+			for _, s := range []string{"GTS_PDFA1", "GTS_PDFX"} {
+				f.outf("<< /Type /OutputIntent /S/%s /DestOutputProfile %d 0 R /OutputConditionIdentifier%s /Info%s>>",
+					s, pr.objNum, cond, cond)
+			}
+			f.out("]")
 		} else {
-			outputIntentReference = f.n - 2
+			f.outf("/OutputIntents[<< /Type /OutputIntent /Info(sRGB) /S/GTS_PDFA1 /DestOutputProfile %v 0 R /OutputConditionIdentifier(sRGB) /Info(sRGB)>>]", f.outputIntentObj)
 		}
-
-		f.outf("/OutputIntents[<< /Type /OutputIntent /Info(sRGB) /S/GTS_PDFA1 /DestOutputProfile %v 0 R /OutputConditionIdentifier(sRGB) /Info(sRGB)>>]", outputIntentReference)
 	}
 
 	if xmpDataIsPresent {
@@ -4684,13 +5894,15 @@ func (f *Fpdf) putcatalog() {
 		f.outf("/Metadata %v 0 R", xmpReference)
 	}
 
-	switch f.zoomMode {
-	case "fullpage":
-		f.out("/OpenAction [3 0 R /Fit]")
-	case "fullwidth":
-		f.out("/OpenAction [3 0 R /FitH null]")
-	case "real":
-		f.out("/OpenAction [3 0 R /XYZ null null 1]")
+	switch {
+	case f.openAction != nil:
+		f.outf("/OpenAction %s", f.openAction.actionDict(f))
+	case f.zoomMode == "fullpage":
+		f.outf("/OpenAction [%d 0 R /Fit]", f.pageObj(1))
+	case f.zoomMode == "fullwidth":
+		f.outf("/OpenAction [%d 0 R /FitH null]", f.pageObj(1))
+	case f.zoomMode == "real":
+		f.outf("/OpenAction [%d 0 R /XYZ null null 1]", f.pageObj(1))
 	}
 	// } 	else if !is_string($this->zoomMode))
 	// 		$this->out('/OpenAction [3 0 R /XYZ null null '.sprintf('%.2f',$this->zoomMode/100).']');
@@ -4709,6 +5921,14 @@ func (f *Fpdf) putcatalog() {
 		}
 		f.out("/PageLayout /" + f.layoutMode)
 	}
+	// Page labels
+	f.putPageLabels()
+	// Article threads
+	f.articlePutCatalog()
+	// Structure tree and language
+	f.structPutCatalog()
+	// Viewer preferences
+	f.viewerPutCatalog()
 	// Bookmarks
 	if len(f.outlines) > 0 {
 		f.outf("/Outlines %d 0 R", f.outlineRoot)
@@ -4716,9 +5936,14 @@ func (f *Fpdf) putcatalog() {
 	}
 	// Layers
 	f.layerPutCatalog()
+	// Signature field
+	f.formPutCatalog()
+	// Associated files
+	f.pdfaPutCatalog()
 	// Name dictionary :
 	//	-> Javascript
 	//	-> Embedded files
+	//	-> Named destinations
 	f.out("/Names <<")
 	// JavaScript
 	if f.javascript != nil {
@@ -4726,27 +5951,49 @@ func (f *Fpdf) putcatalog() {
 	}
 	// Embedded files
 	f.outf("/EmbeddedFiles %s", f.getEmbeddedFiles())
+	// Named destinations
+	f.putNamedDests()
 	f.out(">>")
 }
 
 func (f *Fpdf) putheader() {
-	if len(f.blendMap) > 0 && f.pdfVersion < "1.4" {
+	if (len(f.blendMap) > 0 || len(f.softMasks) > 0 || len(f.groups) > 0 || f.structTree.tagged) && f.pdfVersion < "1.4" {
 		f.pdfVersion = "1.4"
 	}
+	if f.protect.encrypted && f.protect.mode == EncryptionAES256 && f.pdfVersion < "2.0" {
+		f.pdfVersion = "2.0"
+	}
+	if f.xrefStream && f.pdfVersion < "1.5" && f.pdfa.part != 1 {
+		// Set with SetCompressionLevel()
+		f.pdfVersion = "1.5"
+	}
+	if f.outStream != nil {
+		if f.outStream.version != "" {
+			// The header has been written with the first page flushed
+			return
+		}
+		f.outStream.version = f.pdfVersion
+	}
 	f.outf("%%PDF-%s", f.pdfVersion)
 	f.out("%ßßßß")
 }
 
 func (f *Fpdf) puttrailer() {
-	f.outf("/Size %d", f.n+1)
-	f.outf("/Root %d 0 R", f.n)
-	f.outf("/Info %d 0 R", f.n-1)
+	f.putTrailerEntries(f.n+1, f.n, f.n-1)
+}
+
+// putTrailerEntries writes the entries of the trailer dictionary for a
+// document of size objects with the catalog root and the document
+// information dictionary info
+func (f *Fpdf) putTrailerEntries(size, root, info int) {
+	f.outf("/Size %d", size)
+	f.outf("/Root %d 0 R", root)
+	f.outf("/Info %d 0 R", info)
 	if f.protect.encrypted {
 		f.outf("/Encrypt %d 0 R", f.protect.objNum)
 	}
 
-	sum := md5.Sum(f.buffer.Bytes())
-	pdfID := hex.EncodeToString(sum[:])
+	pdfID := hex.EncodeToString(f.documentDigest())
 
 	f.outf("/ID [<%v><%v>]", pdfID, pdfID)
 }
@@ -4756,7 +6003,7 @@ func (f *Fpdf) putxmp() {
 		return
 	}
 	f.newobj()
-	f.outf("<< /Type /Metadata /Subtype /XML /Length %d >>", len(f.xmp))
+	f.outf("<< /Type /Metadata /Subtype /XML /Length %d >>", f.protect.streamLength(len(f.xmp)))
 	f.putstream(f.xmp)
 	f.out("endobj")
 }
@@ -4764,12 +6011,13 @@ func (f *Fpdf) putxmp() {
 var sRGBv2ProfileAsHex = []byte("00000bd000000000020000006d6e74725247422058595a2007df0002000f00000000000061637370000000000000000000000000000000000000000100000000000000000000f6d6000100000000d32d000000003d0eb2deae9397be9b6726ce8c0a43ce00000000000000000000000000000000000000000000000000000000000000106465736300000144000000636258595a000001a80000001462545243000001bc0000080c67545243000001bc0000080c72545243000001bc0000080c646d6464000009c8000000886758595a00000a50000000146c756d6900000a64000000146d65617300000a7800000024626b707400000a9c000000147258595a00000ab0000000147465636800000ac40000000c7675656400000ad0000000877774707400000b58000000146370727400000b6c000000376368616400000ba40000002c6465736300000000000000097352474232303134000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000058595a2000000000000024a000000f840000b6cf63757276000000000000040000000005000a000f00140019001e00230028002d00320037003b00400045004a004f00540059005e00630068006d00720077007c00810086008b00900095009a009f00a400a900ae00b200b700bc00c100c600cb00d000d500db00e000e500eb00f000f600fb01010107010d01130119011f0125012b01320138013e0145014c0152015901600167016e0175017c0183018b0192019a01a101a901b101b901c101c901d101d901e101e901f201fa0203020c0214021d0226022f02380241024b0254025d02670271027a0284028e029802a202ac02b602c102cb02d502e002eb02f50300030b03160321032d03380343034f035a03660372037e038a039603a203ae03ba03c703d303e003ec03f9040604130420042d043b0448045504630471047e048c049a04a804b604c404d304e104f004fe050d051c052b053a05490558056705770586059605a605b505c505d505e505f6060606160627063706480659066a067b068c069d06af06c006d106e306f507070719072b073d074f076107740786079907ac07bf07d207e507f8080b081f08320846085a086e0882089608aa08be08d208e708fb09100925093a094f09640979098f09a409ba09cf09e509fb0a110a270a3d0a540a6a0a810a980aae0ac50adc0af30b0b0b220b390b510b690b800b980bb00bc80be10bf90c120c2a0c430c5c0c750c8e0ca70cc00cd90cf30d0d0d260d400d5a0d740d8e0da90dc30dde0df80e130e2e0e490e640e7f0e9b0eb60ed20eee0f090f250f410f5e0f7a0f960fb30fcf0fec1009102610431061107e109b10b910d710f511131131114f116d118c11aa11c911e81207122612451264128412a312c312e31303132313431363138313a413c513e5140614271449146a148b14ad14ce14f01512153415561578159b15bd15e0160316261649166c168f16b216d616fa171d17411765178917ae17d217f7181b18401865188a18af18d518fa19201945196b199119b719dd1a041a2a1a511a771a9e1ac51aec1b141b3b1b631b8a1bb21bda1c021c2a1c521c7b1ca31ccc1cf51d1e1d471d701d991dc31dec1e161e401e6a1e941ebe1ee91f131f3e1f691f941fbf1fea20152041206c209820c420f0211c2148217521a121ce21fb22272255228222af22dd230a23382366239423c223f0241f244d247c24ab24da250925382568259725c725f726272657268726b726e827182749277a27ab27dc280d283f287128a228d429062938296b299d29d02a022a352a682a9b2acf2b022b362b692b9d2bd12c052c392c6e2ca22cd72d0c2d412d762dab2de12e162e4c2e822eb72eee2f242f5a2f912fc72ffe3035306c30a430db3112314a318231ba31f2322a3263329b32d4330d3346337f33b833f1342b3465349e34d83513354d358735c235fd3637367236ae36e937243760379c37d738143850388c38c839053942397f39bc39f93a363a743ab23aef3b2d3b6b3baa3be83c273c653ca43ce33d223d613da13de03e203e603ea03ee03f213f613fa23fe24023406440a640e74129416a41ac41ee4230427242b542f7433a437d43c044034447448a44ce45124555459a45de4622466746ab46f04735477b47c04805484b489148d7491d496349a949f04a374a7d4ac44b0c4b534b9a4be24c2a4c724cba4d024d4a4d934ddc4e254e6e4eb74f004f494f934fdd5027507150bb51065150519b51e65231527c52c75313535f53aa53f65442548f54db5528557555c2560f565c56a956f75744579257e0582f587d58cb591a596959b85a075a565aa65af55b455b955be55c355c865cd65d275d785dc95e1a5e6c5ebd5f0f5f615fb36005605760aa60fc614f61a261f56249629c62f06343639763eb6440649464e9653d659265e7663d669266e8673d679367e9683f689668ec6943699a69f16a486a9f6af76b4f6ba76bff6c576caf6d086d606db96e126e6b6ec46f1e6f786fd1702b708670e0713a719571f0724b72a67301735d73b87414747074cc7528758575e1763e769b76f8775677b37811786e78cc792a798979e77a467aa57b047b637bc27c217c817ce17d417da17e017e627ec27f237f847fe5804780a8810a816b81cd8230829282f4835783ba841d848084e3854785ab860e867286d7873b879f8804886988ce8933899989fe8a648aca8b308b968bfc8c638cca8d318d988dff8e668ece8f368f9e9006906e90d6913f91a89211927a92e3934d93b69420948a94f4955f95c99634969f970a977597e0984c98b89924999099fc9a689ad59b429baf9c1c9c899cf79d649dd29e409eae9f1d9f8b9ffaa069a0d8a147a1b6a226a296a306a376a3e6a456a4c7a538a5a9a61aa68ba6fda76ea7e0a852a8c4a937a9a9aa1caa8fab02ab75abe9ac5cacd0ad44adb8ae2daea1af16af8bb000b075b0eab160b1d6b24bb2c2b338b3aeb425b49cb513b58ab601b679b6f0b768b7e0b859b8d1b94ab9c2ba3bbab5bb2ebba7bc21bc9bbd15bd8fbe0abe84beffbf7abff5c070c0ecc167c1e3c25fc2dbc358c3d4c451c4cec54bc5c8c646c6c3c741c7bfc83dc8bcc93ac9b9ca38cab7cb36cbb6cc35ccb5cd35cdb5ce36ceb6cf37cfb8d039d0bad13cd1bed23fd2c1d344d3c6d449d4cbd54ed5d1d655d6d8d75cd7e0d864d8e8d96cd9f1da76dafbdb80dc05dc8add10dd96de1cdea2df29dfafe036e0bde144e1cce253e2dbe363e3ebe473e4fce584e60de696e71fe7a9e832e8bce946e9d0ea5beae5eb70ebfbec86ed11ed9cee28eeb4ef40efccf058f0e5f172f1fff28cf319f3a7f434f4c2f550f5def66df6fbf78af819f8a8f938f9c7fa57fae7fb77fc07fc98fd29fdbafe4bfedcff6dffff64657363000000000000002e4945432036313936362d322d312044656661756c742052474220436f6c6f7572205370616365202d20735247420000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000058595a2000000000000062990000b785000018da58595a20000000000000000000500000000000006d656173000000000000000100000000000000000000000000000000000000000000000258595a20000000000000009e000000a40000008758595a200000000000006fa2000038f50000039073696720000000004352542064657363000000000000002d5265666572656e63652056696577696e6720436f6e646974696f6e20696e204945432036313936362d322d31000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000058595a20000000000000f6d6000100000000d32d7465787400000000436f7079726967687420496e7465726e6174696f6e616c20436f6c6f7220436f6e736f727469756d2c20323031350000736633320000000000010c44000005dffffff326000007940000fd8ffffffba1fffffda2000003db0000c075")
 
 func (f *Fpdf) putOutputIntent() {
-	if !f.addOutputIntent {
+	if !f.addOutputIntent || f.outputProfile > 0 {
 		return
 	}
 
 	f.newobj()
-	f.outf("<< /N 3 /Length %v /Filter /ASCIIHexDecode >>", len(sRGBv2ProfileAsHex))
+	f.outputIntentObj = f.n
+	f.outf("<< /N 3 /Length %v /Filter /ASCIIHexDecode >>", f.protect.streamLength(len(sRGBv2ProfileAsHex)))
 	f.putstream(sRGBv2ProfileAsHex)
 	f.out("endobj")
 }
@@ -4798,8 +6046,19 @@ func (f *Fpdf) putbookmarks() {
 			lru[o.level] = i
 			level = o.level
 		}
+		// Bookmarks that are open show the bookmarks below them, which are
+		// counted in visible
+		visible := make([]int, nb)
+		for i := nb - 1; i >= 0; i-- {
+			if p := f.outlines[i].parent; p < nb {
+				visible[p]++
+				if o := f.outlines[i].options; o != nil && o.Open {
+					visible[p] += visible[i]
+				}
+			}
+		}
 		n := f.n + 1
-		for _, o := range f.outlines {
+		for i, o := range f.outlines {
 			f.newobj()
 			f.outf("<</Title %s", f.textstring(o.text))
 			f.outf("/Parent %d 0 R", n+o.parent)
@@ -4815,8 +6074,12 @@ func (f *Fpdf) putbookmarks() {
 			if o.last != -1 {
 				f.outf("/Last %d 0 R", n+o.last)
 			}
-			f.outf("/Dest [%d 0 R /XYZ 0 %.2f null]", 1+2*o.p, (f.h-o.y)*f.k)
-			f.out("/Count 0>>")
+			if o.options == nil {
+				f.outf("/Dest [%d 0 R /XYZ 0 %.2f null]", f.pageObj(o.p), (f.h-o.y)*f.k)
+				f.out("/Count 0>>")
+			} else {
+				f.putBookmarkOptions(*o.options, o.p, o.y, visible[i])
+			}
 			f.out("endobj")
 		}
 		f.newobj()
@@ -4827,15 +6090,78 @@ func (f *Fpdf) putbookmarks() {
 	}
 }
 
+// putBookmarkOptions writes the destination, the appearance and the count of
+// a bookmark set with BookmarkEx() on page at y. visible is the number of
+// bookmarks below it that are shown when it is open.
+func (f *Fpdf) putBookmarkOptions(o BookmarkOptions, page int, y float64, visible int) {
+	switch {
+	case o.Dest != "":
+		if _, ok := f.namedDests[o.Dest]; !ok {
+			f.err = fmt.Errorf("undefined named destination %q", o.Dest)
+		}
+		f.outf("/Dest %s", f.textstring(o.Dest))
+	case o.Zoom == "FitH":
+		f.outf("/Dest [%d 0 R /FitH %.2f]", f.pageObj(page), f.pageTop(page, y))
+	case o.Zoom == "Fit":
+		f.outf("/Dest [%d 0 R /Fit]", f.pageObj(page))
+	case o.ZoomFactor != 0:
+		f.outf("/Dest [%d 0 R /XYZ 0 %.2f %.2f]", f.pageObj(page), f.pageTop(page, y), o.ZoomFactor)
+	default:
+		f.outf("/Dest %s", f.pageDest(page, y))
+	}
+	flags := 0
+	if o.Italic {
+		flags |= 1
+	}
+	if o.Bold {
+		flags |= 2
+	}
+	if flags != 0 {
+		f.outf("/F %d", flags)
+	}
+	if o.Color != nil {
+		f.outf("/C [%.3f %.3f %.3f]", float64(o.Color.R)/255, float64(o.Color.G)/255, float64(o.Color.B)/255)
+	}
+	if !o.Open {
+		visible = -visible
+	}
+	f.outf("/Count %d>>", visible)
+}
+
 func (f *Fpdf) enddoc() {
 	if f.err != nil {
 		return
 	}
+	f.backgroundsPrepare()
+	f.pdfaPrepare()
+	if f.err != nil {
+		return
+	}
+	f.pdfxPrepare()
+	if f.err != nil {
+		return
+	}
+	f.xmpPrepare()
 	f.layerEndDoc()
+	if s := f.outStream; s != nil {
+		switch {
+		case f.signature.provider != nil:
+			f.err = fmt.Errorf("document written to an output writer cannot be signed")
+		case s.version != "" && s.protect != f.protect.encrypted:
+			f.err = fmt.Errorf("protection must be set before pages are flushed")
+		}
+		if f.err != nil {
+			return
+		}
+	}
 	f.putheader()
 	// Embedded files
 	f.putAttachments()
 	f.putAnnotationsAttachments()
+	// Signature field
+	f.putSignature()
+	f.putFormFields()
+	f.putMarkupAnnots()
 	f.putpages()
 	f.putresources()
 	if f.err != nil {
@@ -4843,6 +6169,10 @@ func (f *Fpdf) enddoc() {
 	}
 	// Bookmarks
 	f.putbookmarks()
+	// Article threads
+	f.putArticles()
+	// Structure tree
+	f.putStructTree()
 	// Output Intent
 	f.putOutputIntent()
 	// Metadata
@@ -4859,23 +6189,34 @@ func (f *Fpdf) enddoc() {
 	f.putcatalog()
 	f.out(">>")
 	f.out("endobj")
-	// Cross-ref
-	o := f.buffer.Len()
-	f.out("xref")
-	f.outf("0 %d", f.n+1)
-	f.out("0000000000 65535 f ")
-	for j := 1; j <= f.n; j++ {
-		f.outf("%010d 00000 n ", f.offsets[j])
-	}
-	// Trailer
-	f.out("trailer")
-	f.out("<<")
-	f.puttrailer()
-	f.out(">>")
-	f.out("startxref")
-	f.outf("%d", o)
-	f.out("%%EOF")
+	if f.compactXref() {
+		// Cross-ref stream with the trailer
+		f.putXrefStream(f.n, f.n-1)
+	} else {
+		// Cross-ref
+		o := f.offset()
+		f.out("xref")
+		f.outf("0 %d", f.n+1)
+		f.out("0000000000 65535 f ")
+		for j := 1; j <= f.n; j++ {
+			f.outf("%010d 00000 n ", f.offsets[j])
+		}
+		// Trailer
+		f.out("trailer")
+		f.out("<<")
+		f.puttrailer()
+		f.out(">>")
+		f.out("startxref")
+		f.outf("%d", o)
+		f.out("%%EOF")
+	}
 	f.state = 3
+	if f.protect.err != nil {
+		f.err = f.protect.err
+		return
+	}
+	// Digital signature covering the completed document
+	f.signDocument()
 	return
 }
 
@@ -19,16 +19,37 @@ package gofpdf_test
 import (
 	"bufio"
 	"bytes"
+	"compress/zlib"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/md5"
+	cryptorand "crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/ascii85"
+	"encoding/binary"
+	"encoding/hex"
 	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
 	"io"
 	"io/ioutil"
 	"math"
+	"math/big"
 	"math/rand"
 	"net/http"
 	"os"
 	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -807,7 +828,9 @@ func ExampleFpdf_WriteAligned() {
 	leftMargin, _, rightMargin, _ := pdf.GetMargins()
 	pageWidth, _ := pdf.GetPageSize()
 	pageWidth -= leftMargin + rightMargin
-	pdf.SetWordSpacing((pageWidth - pdf.GetStringWidth(line)) / float64(strings.Count(line, " ")))
+	// The text is written between the cell margins
+	textWidth := pageWidth - 2*pdf.GetCellMargin()
+	pdf.SetWordSpacing((textWidth - pdf.GetStringWidth(line)) / float64(strings.Count(line, " ")))
 	pdf.WriteAligned(pageWidth, 35, line, "L")
 	fileStr := example.Filename("Fpdf_WriteAligned")
 	err := pdf.OutputFileAndClose(fileStr)
@@ -1677,6 +1700,185 @@ func ExampleFpdf_SetProtection() {
 	// Successfully generated pdf/Fpdf_SetProtection.pdf
 }
 
+// ExampleFpdf_Sign demonstrates a digitally signed document. A self-signed
+// certificate is generated here; in practice the key and certificate would
+// come from a certificate authority, possibly by way of a hardware token.
+func ExampleFpdf_Sign() {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), cryptorand.Reader)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	tmpl := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "gofpdf example signer"},
+		NotBefore:    time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Date(2039, 1, 1, 0, 0, 0, 0, time.UTC),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(cryptorand.Reader, &tmpl, &tmpl, key.Public(), key)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.Write(10, "This document is digitally signed.")
+	pdf.Rect(20, 40, 60, 20, "D")
+	pdf.AddSignatureField("Approval", 20, 40, 60, 20)
+	pdf.Sign(key, cert)
+	fileStr := example.Filename("Fpdf_Sign")
+	err = pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_Sign.pdf
+}
+
+// TestSignatureByteRange verifies that the signature covers the entire
+// document except for the signature itself
+func TestSignatureByteRange(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), cryptorand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "gofpdf test signer"},
+		NotBefore:    time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Date(2039, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	der, err := x509.CreateCertificate(cryptorand.Reader, &tmpl, &tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.Write(10, "Signed")
+	pdf.Sign(key, cert)
+	var buf bytes.Buffer
+	err = pdf.Output(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := buf.Bytes()
+	m := regexp.MustCompile(`/ByteRange \[(\d+) (\d+) (\d+) (\d+)\]`).FindSubmatch(doc)
+	if m == nil {
+		t.Fatal("/ByteRange not found")
+	}
+	var br [4]int
+	for j := range br {
+		br[j], _ = strconv.Atoi(string(m[j+1]))
+	}
+	if br[0] != 0 || br[2]+br[3] != len(doc) {
+		t.Fatalf("byte range %v does not cover document of length %d", br, len(doc))
+	}
+	contents := doc[br[1]:br[2]]
+	if !regexp.MustCompile(`^<[0-9a-fA-F]+>$`).Match(contents) {
+		t.Fatalf("gap in byte range is not the /Contents hex string")
+	}
+	if !bytes.HasSuffix(doc[:br[1]], []byte("/Contents ")) {
+		t.Fatalf("gap in byte range does not follow /Contents")
+	}
+	sig, err := hex.DecodeString(string(contents[1 : len(contents)-1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The messageDigest authenticated attribute: OID 1.2.840.113549.1.9.4
+	// followed by a set containing a 32-byte octet string
+	attr := []byte{0x06, 0x09, 0x2a, 0x86, 0x48, 0x86, 0xf7, 0x0d, 0x01, 0x09, 0x04, 0x31, 0x22, 0x04, 0x20}
+	j := bytes.Index(sig, attr)
+	if j < 0 {
+		t.Fatal("messageDigest attribute not found in signature")
+	}
+	h := sha256.New()
+	h.Write(doc[br[0] : br[0]+br[1]])
+	h.Write(doc[br[2] : br[2]+br[3]])
+	if !bytes.Equal(sig[j+len(attr):j+len(attr)+32], h.Sum(nil)) {
+		t.Fatal("messageDigest does not match the signed byte range")
+	}
+}
+
+// pageObjNums returns the object numbers of the page dictionaries of the
+// uncompressed document doc, in the order of the pages
+func pageObjNums(doc string) []string {
+	var list []string
+	for _, m := range regexp.MustCompile(`(\d+) 0 obj\n<</Type /Page\n`).FindAllStringSubmatch(doc, -1) {
+		list = append(list, m[1])
+	}
+	return list
+}
+
+// TestSignatureDestinations verifies that the destinations of a signed
+// document, whose signature field is written before the pages, refer to the
+// page dictionaries
+func TestSignatureDestinations(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), cryptorand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "gofpdf test signer"},
+		NotBefore:    time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Date(2039, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	der, err := x509.CreateCertificate(cryptorand.Reader, &tmpl, &tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false)
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.AddPage()
+	pdf.AddSignatureField("Approval", 20, 40, 60, 20)
+	pdf.AddPage()
+	pdf.Bookmark("Second page", 0, 0)
+	pdf.Sign(key, cert)
+	var buf bytes.Buffer
+	if err = pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	doc := buf.String()
+	pages := pageObjNums(doc)
+	if len(pages) != 2 {
+		t.Fatalf("%d page dictionaries found", len(pages))
+	}
+	dests := regexp.MustCompile(`/Dest \[(\d+) 0 R`).FindAllStringSubmatch(doc, -1)
+	if len(dests) != 1 || dests[0][1] != pages[1] {
+		t.Fatalf("bookmark destinations %v do not refer to page object %s", dests, pages[1])
+	}
+}
+
+// ExampleFpdf_SetProtectionEx demonstrates password protection using the
+// AES-256 encryption scheme.
+func ExampleFpdf_SetProtectionEx() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetProtectionEx(gofpdf.EncryptionAES256, gofpdf.CnProtectPrint, "123", "abc")
+	pdf.AddPage()
+	pdf.SetFont("Arial", "", 12)
+	pdf.Write(10, "Password-protected with AES-256.")
+	fileStr := example.Filename("Fpdf_SetProtectionEx")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_SetProtectionEx.pdf
+}
+
 // ExampleFpdf_Polygon displays equilateral polygons in a demonstration of the Polygon
 // function.
 func ExampleFpdf_Polygon() {
@@ -2878,6 +3080,329 @@ func ExampleFpdf_SetAttachments() {
 	// Successfully generated pdf/Fpdf_EmbeddedFiles.pdf
 }
 
+// TestAttachmentUncompressed verifies that uncompressed attachments are
+// stored as is, both from a byte slice and from a reader
+func TestAttachmentUncompressed(t *testing.T) {
+	content := []byte("already compressed content")
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetAttachments([]gofpdf.Attachment{
+		{Content: content, Filename: "a.bin", Uncompressed: true},
+		{ContentReader: bytes.NewReader(content), Filename: "b.bin", Uncompressed: true},
+	})
+	pdf.AddPage()
+	var buf bytes.Buffer
+	err := pdf.Output(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := buf.String()
+	if regexp.MustCompile(`/Type /EmbeddedFile [^>]*/Filter`).MatchString(doc) {
+		t.Error("uncompressed attachment has a filter")
+	}
+	size := fmt.Sprintf("/Size %d ", len(content))
+	if n := strings.Count(doc, size); n != 2 {
+		t.Fatalf("got %d attachments of size %d, want 2", n, len(content))
+	}
+	// Direct length
+	m := regexp.MustCompile(`/Type /EmbeddedFile /Length (\d+) /Params`).FindStringSubmatch(doc)
+	if m == nil || m[1] != strconv.Itoa(len(content)) {
+		t.Errorf("stream length %v does not match size %d", m, len(content))
+	}
+	// Indirect length
+	m = regexp.MustCompile(`/Type /EmbeddedFile /Length (\d+) 0 R /Params`).FindStringSubmatch(doc)
+	if m == nil {
+		t.Fatal("attachment with indirect length not found")
+	}
+	n, _ := strconv.Atoi(m[1])
+	if obj := strings.TrimSpace(pdfObject(doc, n)); obj != strconv.Itoa(len(content)) {
+		t.Errorf("stream length %s does not match size %d", obj, len(content))
+	}
+	if !strings.Contains(doc, "stream\n"+string(content)) {
+		t.Error("content is not stored as is")
+	}
+}
+
+// TestAttachmentChecksums verifies the checksums written with attachments,
+// both from a byte slice and from a reader
+func TestAttachmentChecksums(t *testing.T) {
+	content := []byte("checksum content")
+	md5Str := fmt.Sprintf("/CheckSum <%x> ", md5.Sum(content))
+	sha256Str := fmt.Sprintf("/CheckSumSHA256 <%x> ", sha256.Sum256(content))
+	for _, reader := range []bool{false, true} {
+		for _, tc := range []struct {
+			mode        int
+			md5, sha256 bool
+		}{
+			{gofpdf.AttachmentChecksumMD5, true, false},
+			{gofpdf.AttachmentChecksumSHA256, true, true},
+			{gofpdf.AttachmentChecksumNone, false, false},
+		} {
+			a := gofpdf.Attachment{Filename: "a.txt", Checksum: tc.mode}
+			if reader {
+				a.ContentReader = bytes.NewReader(content)
+			} else {
+				a.Content = content
+			}
+			pdf := gofpdf.New("P", "mm", "A4", "")
+			pdf.SetAttachments([]gofpdf.Attachment{a})
+			pdf.AddPage()
+			var buf bytes.Buffer
+			err := pdf.Output(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			doc := buf.String()
+			if strings.Contains(doc, md5Str) != tc.md5 || strings.Count(doc, "/CheckSum ") != strings.Count(doc, md5Str) {
+				t.Errorf("reader %v, mode %d: unexpected MD5 checksum", reader, tc.mode)
+			}
+			if strings.Contains(doc, sha256Str) != tc.sha256 || strings.Count(doc, "/CheckSumSHA256 ") != strings.Count(doc, sha256Str) {
+				t.Errorf("reader %v, mode %d: unexpected SHA-256 checksum", reader, tc.mode)
+			}
+		}
+	}
+}
+
+// TestSetAttachmentsCopy verifies that the slice passed to SetAttachments()
+// is not modified
+func TestSetAttachmentsCopy(t *testing.T) {
+	list := make([]gofpdf.Attachment, 2, 4)
+	list[0] = gofpdf.Attachment{Content: []byte("a"), Filename: "a.txt"}
+	list[1] = gofpdf.Attachment{Content: []byte("b"), Filename: "b.txt"}
+	keep := list[1]
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetAttachments(list[:1])
+	pdf.AppendAttachment(gofpdf.Attachment{Content: []byte("c"), Filename: "c.txt"})
+	pdf.AddPage()
+	err := pdf.Output(ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(list[1], keep) {
+		t.Error("AppendAttachment() overwrote the caller's slice")
+	}
+	if !reflect.DeepEqual(list[0], gofpdf.Attachment{Content: []byte("a"), Filename: "a.txt"}) {
+		t.Error("output modified the caller's attachment")
+	}
+	if n := len(pdf.Attachments()); n != 2 {
+		t.Errorf("got %d attachments, want 2", n)
+	}
+}
+
+// TestAttachmentAnnotationOptions verifies the opacity and appearance of
+// attachment annotations
+func TestAttachmentAnnotationOptions(t *testing.T) {
+	output := func(level string, options gofpdf.AttachmentAnnotationOptions) (string, error) {
+		pdf := gofpdf.New("P", "mm", "A4", "")
+		if level != "" {
+			pdf.SetPDFAConformance(level)
+		}
+		pdf.AddPage()
+		a := gofpdf.Attachment{Content: []byte("note"), Filename: "note.txt"}
+		pdf.AddAttachmentAnnotationOptions(&a, 10, 10, 10, 10, options)
+		var buf bytes.Buffer
+		err := pdf.Output(&buf)
+		return buf.String(), err
+	}
+	// An alpha value of zero means opaque
+	doc, err := output("", gofpdf.AttachmentAnnotationOptions{Icon: "Tag", Color: &gofpdf.RGBAType{R: 255}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(doc, "/C [1.000 0.000 0.000] /CA 1.000 ") {
+		t.Error("color without alpha is not opaque")
+	}
+	if strings.Contains(doc, "/AP ") {
+		t.Error("appearance written although not requested")
+	}
+	// PDF/A requires an appearance
+	doc, err = output(gofpdf.PDFA3B, gofpdf.AttachmentAnnotationOptions{Icon: "Tag"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(doc, "/AP << /N ") {
+		t.Error("no appearance written in PDF/A mode")
+	}
+}
+
+// TestPDFAAssociatedFiles verifies that the files of attachment annotations
+// are associated with a PDF/A-3 document and that file specifications carry
+// the file name in /F
+func TestPDFAAssociatedFiles(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetPDFAConformance(gofpdf.PDFA3B)
+	pdf.AppendAttachment(gofpdf.Attachment{Content: []byte("<xml/>"), Filename: "factur-x.xml",
+		MimeType: "text/xml", Relationship: "Data"})
+	pdf.AddPage()
+	a := gofpdf.Attachment{Content: []byte("note"), Filename: "note.txt"}
+	pdf.AddAttachmentAnnotation(&a, 10, 10, 10, 10)
+	pdf.AddAttachmentAnnotation(&a, 30, 10, 10, 10)
+	var buf bytes.Buffer
+	err := pdf.Output(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := buf.String()
+	if !strings.Contains(doc, "/F (factur-x.xml) ") {
+		t.Error("/F entry of file specification does not contain the file name")
+	}
+	m := regexp.MustCompile(`/AF \[((?:\d+ 0 R )*)\]`).FindStringSubmatch(doc)
+	if m == nil {
+		t.Fatal("/AF not found in catalog")
+	}
+	if n := strings.Count(m[1], " R "); n != 2 {
+		t.Fatalf("got %d associated files, want 2: %s", n, m[0])
+	}
+	for _, ref := range regexp.MustCompile(`/FS (\d+ 0 R)`).FindAllStringSubmatch(doc, -1) {
+		if !strings.Contains(m[1], ref[1]+" ") {
+			t.Errorf("annotation file %s is not associated with the document", ref[1])
+		}
+	}
+}
+
+// TestAttachmentReaderReuse verifies that a content reader that is shared by
+// copies of an attachment is not silently embedded empty the second time
+func TestAttachmentReaderReuse(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	a := gofpdf.Attachment{ContentReader: strings.NewReader("content"), Filename: "a.txt"}
+	pdf.SetAttachments([]gofpdf.Attachment{a})
+	pdf.AddPage()
+	pdf.AddAttachmentAnnotation(&a, 10, 10, 10, 10)
+	err := pdf.Output(ioutil.Discard)
+	if err == nil {
+		t.Fatal("reuse of consumed content reader was not reported")
+	}
+
+	// Passing the same *Attachment embeds the content once
+	pdf = gofpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+	a = gofpdf.Attachment{ContentReader: strings.NewReader("content"), Filename: "a.txt"}
+	pdf.AddAttachmentAnnotation(&a, 10, 10, 10, 10)
+	pdf.AddAttachmentAnnotation(&a, 30, 10, 10, 10)
+	err = pdf.Output(ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestEmbeddedFilesNameTree verifies that attachments are listed by file name
+// in sorted order and that duplicate names are made unique.
+func TestEmbeddedFilesNameTree(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	for _, name := range []string{"b.txt", "a.txt", "b.txt"} {
+		pdf.AppendAttachment(gofpdf.Attachment{Content: []byte(name), Filename: name})
+	}
+	pdf.AddPage()
+	var buf bytes.Buffer
+	err := pdf.Output(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	utf16 := func(s string) string {
+		var b bytes.Buffer
+		b.WriteString("(\xfe\xff")
+		for _, r := range s {
+			b.WriteByte(0)
+			if r == '(' || r == ')' {
+				b.WriteByte('\\')
+			}
+			b.WriteRune(r)
+		}
+		b.WriteString(")")
+		return b.String()
+	}
+	out := buf.String()
+	out = out[strings.Index(out, "/EmbeddedFiles"):]
+	pos := -1
+	for _, name := range []string{"a.txt", "b (2).txt", "b.txt"} {
+		j := strings.Index(out, utf16(name)+" ")
+		if j < 0 {
+			t.Fatalf("name %q not found in name tree", name)
+		}
+		if j < pos {
+			t.Fatalf("name %q is out of order", name)
+		}
+		pos = j
+	}
+}
+
+// ExampleFpdf_AppendAttachment demonstrates attachments that are contributed
+// by independent parts of an application.
+func ExampleFpdf_AppendAttachment() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	addInvoiceData := func() {
+		pdf.AppendAttachment(gofpdf.Attachment{
+			Content:  []byte("<Invoice><ID>2019-0042</ID></Invoice>"),
+			Filename: "invoice.xml",
+		})
+	}
+	addAuditTrail := func() {
+		pdf.AppendAttachment(gofpdf.Attachment{
+			Content:  []byte("2019-06-01 created\n2019-06-02 approved\n"),
+			Filename: "audit.txt",
+		})
+	}
+	addInvoiceData()
+	addAuditTrail()
+	pdf.AddPage()
+	pdf.SetFont("Arial", "", 12)
+	for _, a := range pdf.Attachments() {
+		pdf.CellFormat(0, 8, "Attached: "+a.Filename, "", 1, "L", false, 0, "")
+	}
+	fileStr := example.Filename("Fpdf_AppendAttachment")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_AppendAttachment.pdf
+}
+
+// ExampleFpdf_SetAttachments_reader demonstrates an attachment whose content
+// is read from a file while the document is output.
+func ExampleFpdf_SetAttachments_reader() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	file, err := os.Open("LICENSE")
+	if err != nil {
+		pdf.SetError(err)
+	} else {
+		defer file.Close()
+	}
+	pdf.SetAttachments([]gofpdf.Attachment{{ContentReader: file, Filename: "License",
+		Checksum: gofpdf.AttachmentChecksumSHA256}})
+	pdf.AddPage()
+	pdf.SetFont("Arial", "", 12)
+	pdf.Write(10, "This document has an attachment that was streamed from a file.")
+	fileStr := example.Filename("Fpdf_SetAttachments_reader")
+	err = pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_SetAttachments_reader.pdf
+}
+
+// ExampleFpdf_SetPDFAConformance demonstrates a PDF/A-3 document with an
+// embedded XML file of the kind used by electronic invoices.
+func ExampleFpdf_SetPDFAConformance() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetPDFAConformance(gofpdf.PDFA3B)
+	pdf.SetTitle("Invoice 2019-0042", true)
+	pdf.SetAuthor("Example Supplies Ltd.", true)
+	pdf.AddUTF8Font("dejavu", "", example.FontFile("DejaVuSansCondensed.ttf"))
+	pdf.AddPage()
+	pdf.SetFont("dejavu", "", 14)
+	pdf.Write(8, "Invoice 2019-0042 – total due € 118.00")
+	xml := []byte(`<?xml version="1.0" encoding="UTF-8"?><Invoice><ID>2019-0042</ID></Invoice>`)
+	pdf.SetAttachments([]gofpdf.Attachment{{
+		Content:      xml,
+		Filename:     "factur-x.xml",
+		Description:  "Invoice data",
+		MimeType:     "text/xml",
+		Relationship: "Data",
+	}})
+	fileStr := example.Filename("Fpdf_SetPDFAConformance")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_SetPDFAConformance.pdf
+}
+
 func ExampleFpdf_AddAttachmentAnnotation() {
 	pdf := gofpdf.New("P", "mm", "A4", "")
 	pdf.SetFont("Arial", "", 12)
@@ -2900,6 +3425,22 @@ func ExampleFpdf_AddAttachmentAnnotation() {
 	pdf.AddAttachmentAnnotation(&a, 2, 80, 50, 15)
 	pdf.Cell(50, 15, "A second link (no copy)")
 
+	// Icons drawn by the reader and by gofpdf
+	pdf.SetXY(5, 120)
+	pdf.Cell(50, 8, "Icons:")
+	for j, icon := range []string{"Graph", "Paperclip", "PushPin", "Tag"} {
+		x := 30 + float64(j)*15
+		pdf.AddAttachmentAnnotationOptions(&a, x, 120, 8, 8, gofpdf.AttachmentAnnotationOptions{
+			Icon:  icon,
+			Color: &gofpdf.RGBAType{R: 0, G: 64, B: 160, Alpha: 1},
+		})
+		pdf.AddAttachmentAnnotationOptions(&a, x, 135, 8, 8, gofpdf.AttachmentAnnotationOptions{
+			Icon:       icon,
+			Color:      &gofpdf.RGBAType{R: 160, G: 0, B: 0, Alpha: 0.6},
+			Appearance: true,
+		})
+	}
+
 	fileStr := example.Filename("Fpdf_FileAnnotations")
 	err = pdf.OutputFileAndClose(fileStr)
 	example.Summary(err, fileStr)
@@ -2922,3 +3463,8149 @@ func ExampleFpdf_SetModificationDate() {
 	// Output:
 	// Successfully generated pdf/Fpdf_SetModificationDate.pdf
 }
+
+// ExampleFpdf_AddTextField demonstrates interactive form fields.
+func ExampleFpdf_AddTextField() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.Cell(0, 10, "Registration")
+	pdf.Ln(15)
+	options := gofpdf.FormFieldOptions{
+		BorderColor:     &gofpdf.RGBType{R: 96, G: 96, B: 96},
+		BackgroundColor: &gofpdf.RGBType{R: 240, G: 240, B: 255},
+	}
+	label := func(y float64, txtStr string) {
+		pdf.SetFont("Helvetica", "", 11)
+		pdf.SetXY(20, y)
+		pdf.Cell(40, 8, txtStr)
+	}
+	label(30, "Name")
+	pdf.SetFont("Helvetica", "", 11)
+	required := options
+	required.Required = true
+	required.MaxLen = 40
+	pdf.AddTextField("name", 60, 30, 100, 8, "", required)
+	label(42, "Password")
+	password := options
+	password.Password = true
+	pdf.AddTextField("password", 60, 42, 100, 8, "secret", password)
+	label(54, "Comments")
+	multiline := options
+	multiline.Multiline = true
+	pdf.AddTextField("comments", 60, 54, 100, 24, "Multiple lines of text\nare permitted here.", multiline)
+	label(84, "Country")
+	pdf.AddComboBox("country", 60, 84, 60, 8, []string{"Austria", "Germany", "Switzerland"}, "Germany", options)
+	label(96, "Newsletter")
+	pdf.SetTextColor(0, 96, 0)
+	pdf.AddCheckBox("newsletter", 60, 96, 6, true, options)
+	label(108, "Plan")
+	pdf.SetTextColor(0, 0, 128)
+	var buttons []gofpdf.RadioButtonType
+	for j, planStr := range []string{"Basic", "Standard", "Premium"} {
+		x := 60 + float64(j)*35
+		buttons = append(buttons, gofpdf.RadioButtonType{Value: planStr, X: x, Y: 109, Size: 6})
+		pdf.SetXY(x+7, 108)
+		pdf.Cell(25, 8, planStr)
+	}
+	pdf.AddRadioGroup("plan", buttons, "Standard", options)
+	fileStr := example.Filename("Fpdf_AddTextField")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_AddTextField.pdf
+}
+
+// pdfObject returns the body of indirect object n in the PDF document doc
+func pdfObject(doc string, n int) string {
+	head := fmt.Sprintf("\n%d 0 obj\n", n)
+	j := strings.Index(doc, head)
+	if j < 0 {
+		return ""
+	}
+	body := doc[j+len(head):]
+	return body[:strings.Index(body, "endobj")]
+}
+
+// pdfRefs returns the object numbers of the indirect references that follow
+// keyStr in str, for example the elements of "/Kids [4 0 R 5 0 R]"
+func pdfRefs(str, keyStr string) (list []int) {
+	j := strings.Index(str, keyStr)
+	if j < 0 {
+		return
+	}
+	str = str[j+len(keyStr):]
+	str = str[strings.Index(str, "[")+1 : strings.Index(str, "]")]
+	fields := strings.Fields(str)
+	for k := 0; k+2 < len(fields); k += 3 {
+		n, _ := strconv.Atoi(fields[k])
+		list = append(list, n)
+	}
+	return
+}
+
+func TestFormFields(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.AddTextField("text", 10, 10, 50, 8, "value", gofpdf.FormFieldOptions{ReadOnly: true})
+	pdf.AddRadioGroup("radio", []gofpdf.RadioButtonType{
+		{Value: "A", X: 10, Y: 30, Size: 5},
+		{Value: "B", X: 20, Y: 30, Size: 5},
+	}, "B", gofpdf.FormFieldOptions{})
+	pdf.AddSignatureField("Signature1", 10, 50, 50, 20)
+	var buf bytes.Buffer
+	err := pdf.Output(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := buf.String()
+	j := strings.Index(doc, "/AcroForm")
+	if j < 0 {
+		t.Fatal("no /AcroForm entry in catalog")
+	}
+	acroForm := doc[j:]
+	acroForm = acroForm[:strings.Index(acroForm, ">>")]
+	if !strings.Contains(acroForm, "/SigFlags 3") {
+		t.Errorf("/SigFlags missing from %q", acroForm)
+	}
+	fields := pdfRefs(acroForm, "/Fields")
+	if len(fields) != 3 {
+		t.Fatalf("got %d fields, want 3", len(fields))
+	}
+	var radio string
+	for _, n := range fields {
+		obj := pdfObject(doc, n)
+		if strings.Contains(obj, "/Ff 49152") {
+			radio = obj
+		}
+	}
+	if radio == "" {
+		t.Fatal("radio group not found in /Fields")
+	}
+	if !strings.Contains(radio, "/V /B") {
+		t.Errorf("radio group value not set: %q", radio)
+	}
+	kids := pdfRefs(radio, "/Kids")
+	if len(kids) != 2 {
+		t.Fatalf("got %d radio buttons, want 2", len(kids))
+	}
+	for k, stateStr := range []string{"/AS /Off", "/AS /B"} {
+		obj := pdfObject(doc, kids[k])
+		if !strings.Contains(obj, stateStr) {
+			t.Errorf("radio button %d: %q not found in %q", k, stateStr, obj)
+		}
+		if !strings.Contains(obj, "/Off ") {
+			t.Errorf("radio button %d has no off appearance", k)
+		}
+	}
+
+	// Field names must be unique, including the name of the signature field
+	pdf = gofpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.AddSignatureField("Signature1", 10, 50, 50, 20)
+	pdf.AddTextField("Signature1", 10, 10, 50, 8, "", gofpdf.FormFieldOptions{})
+	if pdf.Error() == nil {
+		t.Error("duplicate field name was accepted")
+	}
+	pdf = gofpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.AddTextField("Signature1", 10, 10, 50, 8, "", gofpdf.FormFieldOptions{})
+	pdf.AddSignatureField("Signature1", 10, 50, 50, 20)
+	if pdf.Error() == nil {
+		t.Error("duplicate signature field name was accepted")
+	}
+}
+
+// TestFormFieldDestinations verifies that the internal links of a document
+// with form fields, which are written before the pages, refer to the page
+// dictionaries
+func TestFormFieldDestinations(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false)
+	pdf.SetFont("Helvetica", "", 12)
+	link := pdf.AddLink()
+	pdf.AddPage()
+	pdf.AddTextField("name", 20, 20, 80, 8, "", gofpdf.FormFieldOptions{})
+	pdf.Link(20, 40, 80, 8, link)
+	pdf.AddPage()
+	pdf.SetLink(link, 0, -1)
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	doc := buf.String()
+	pages := pageObjNums(doc)
+	if len(pages) != 2 {
+		t.Fatalf("%d page dictionaries found", len(pages))
+	}
+	dests := regexp.MustCompile(`/Dest \[(\d+) 0 R`).FindAllStringSubmatch(doc, -1)
+	if len(dests) != 1 || dests[0][1] != pages[1] {
+		t.Fatalf("link destinations %v do not refer to page object %s", dests, pages[1])
+	}
+}
+
+// ExampleFpdf_NewTable demonstrates a table with automatically sized columns
+// that continues across pages with its header repeated.
+func ExampleFpdf_NewTable() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFont("Arial", "", 10)
+	pdf.AddPage()
+	tbl := pdf.NewTable([]gofpdf.TableColumnType{
+		{Header: "No.", Strategy: gofpdf.ColumnFixed, Width: 12, Align: "R"},
+		{Header: "Country", Strategy: gofpdf.ColumnAuto},
+		{Header: "Description", Strategy: gofpdf.ColumnPercent, Width: 55},
+		{Header: "Share", Strategy: gofpdf.ColumnAuto, Align: "R"},
+	})
+	tbl.SetHeaderStyle("B", &gofpdf.RGBType{R: 220, G: 230, B: 240})
+	tbl.SetVerticalAlign("M")
+	txtStr := lorem()
+	for j := 0; j < 40; j++ {
+		country := []string{"Austria", "Belgium", "Denmark", "France", "Germany"}[j%5]
+		tbl.AddRow(strconv.Itoa(j+1), country, txtStr[:40+j*37%200],
+			fmt.Sprintf("%.1f %%", float64(j*37%100)/3))
+	}
+	tbl.Draw()
+	pdf.Ln(5)
+	pdf.Write(5, "Text following the table.")
+	fileStr := example.Filename("Fpdf_NewTable")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_NewTable.pdf
+}
+
+// TestTableRowSplit verifies that a row that is taller than a page is split
+// across pages and that the header is repeated on each of them
+func TestTableRowSplit(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A6", "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.AddPage()
+	tbl := pdf.NewTable([]gofpdf.TableColumnType{{Header: "Text"}})
+	tbl.AddRow(strings.Repeat("line\n", 60))
+	tbl.Draw()
+	if pdf.Error() != nil {
+		t.Fatal(pdf.Error())
+	}
+	if n := pdf.PageCount(); n < 3 {
+		t.Fatalf("got %d pages, expecting at least 3", n)
+	}
+	tbl = pdf.NewTable([]gofpdf.TableColumnType{{Header: "A"}, {Header: "B"}})
+	tbl.AddRow("only one cell")
+	if pdf.Error() == nil {
+		t.Fatal("row with wrong number of cells was accepted")
+	}
+}
+
+// ExampleFpdf_MultiCellBounded demonstrates text that is flowed through a
+// series of boxes.
+func ExampleFpdf_MultiCellBounded() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFont("Times", "", 12)
+	pdf.AddPage()
+	txtStr := lorem() + "\n\n" + lorem()
+	const boxWd, boxHt, lineHt = 55.0, 60.0, 5.0
+	for j := 0; j < 3 && txtStr != ""; j++ {
+		x := 10 + float64(j)*(boxWd+10)
+		pdf.Rect(x, 20, boxWd, boxHt, "D")
+		pdf.SetXY(x, 20)
+		txtStr = pdf.MultiCellBounded(boxWd, lineHt, boxHt, txtStr, "", "J", false)
+	}
+	fileStr := example.Filename("Fpdf_MultiCellBounded")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_MultiCellBounded.pdf
+}
+
+func TestMultiCellBounded(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.AddPage()
+	txtStr := "one\ntwo\nthree\nfour"
+	y0 := pdf.GetY()
+	rest := pdf.MultiCellBounded(50, 5, 10, txtStr, "1", "L", false)
+	if rest != "three\nfour" {
+		t.Fatalf("got remainder %q", rest)
+	}
+	if y := pdf.GetY(); math.Abs(y-y0-10) > 0.001 {
+		t.Fatalf("got y %.2f after two lines, expecting %.2f", y, y0+10)
+	}
+	rest = pdf.MultiCellBounded(50, 5, 100, rest, "1", "L", false)
+	if rest != "" {
+		t.Fatalf("got remainder %q after all text fits", rest)
+	}
+	if rest = pdf.MultiCellBounded(50, 5, 4, txtStr, "", "L", false); rest != txtStr {
+		t.Fatalf("text output although box is less than one line high")
+	}
+	// Automatic line breaks
+	words := strings.Repeat("word ", 40)
+	rest = pdf.MultiCellBounded(40, 5, 15, words, "", "J", false)
+	if rest == "" || !strings.HasSuffix(words, rest) || strings.HasPrefix(rest, " ") {
+		t.Fatalf("unexpected remainder %q", rest)
+	}
+	if pdf.Error() != nil {
+		t.Fatal(pdf.Error())
+	}
+}
+
+// TestMultiCellJustify checks that the lines of justified text, except the
+// last line of each paragraph, are spread to the width of the cell
+func TestMultiCellJustify(t *testing.T) {
+	const width = 60.0
+	txtStr := "The quick brown fox jumps over the lazy dog and keeps running " +
+		"through the field.\nA second paragraph follows the first one."
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false)
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.AddPage()
+	textWidth := width - 2*pdf.GetCellMargin()
+	lines := pdf.SplitLines([]byte(txtStr), width)
+	pdf.MultiCell(width, 5, txtStr, "", "J", false)
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	re := regexp.MustCompile(`([-\d.]+) Tw\nBT [\d.]+ [\d.]+ Td \(([^)]*)\)Tj ET`)
+	matches := re.FindAllStringSubmatch(buf.String(), -1)
+	if len(matches) != len(lines) {
+		t.Fatalf("found %d lines with word spacing, expecting %d", len(matches), len(lines))
+	}
+	for j, m := range matches {
+		if m[2] != string(lines[j]) {
+			t.Fatalf("line %d is %q, expecting %q", j, m[2], lines[j])
+		}
+		ws, _ := strconv.ParseFloat(m[1], 64)
+		ws /= pdf.GetConversionRatio()
+		// Paragraphs end with periods
+		last := strings.HasSuffix(m[2], ".")
+		wd := pdf.GetStringWidth(m[2]) + ws*float64(strings.Count(m[2], " "))
+		switch {
+		case last && ws != 0:
+			t.Fatalf("last line %q of paragraph has word spacing %.3f", m[2], ws)
+		case !last && math.Abs(wd-textWidth) > 0.01:
+			t.Fatalf("justified line %q is %.3f wide, expecting %.3f", m[2], wd, textWidth)
+		}
+	}
+
+	// UTF-8 text is justified with adjustments before its spaces
+	pdf = gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false)
+	pdf.AddUTF8Font("dejavu", "", example.FontFile("DejaVuSansCondensed.ttf"))
+	pdf.SetFont("dejavu", "", 12)
+	pdf.AddPage()
+	pdf.MultiCell(width, 5, txtStr, "", "J", false)
+	buf.Reset()
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if n, want := strings.Count(buf.String(), "] TJ ET"), len(pdf.SplitText(txtStr, width))-2; n != want {
+		t.Fatalf("found %d justified lines of UTF-8 text, expecting %d", n, want)
+	}
+}
+
+// ExampleFpdf_SetHyphenator demonstrates the hyphenation of words at the
+// ends of lines with TeX hyphenation patterns. Complete sets of patterns for
+// many languages can be loaded from the files of the hyph-utf8 project with
+// LoadPatternHyphenator().
+func ExampleFpdf_SetHyphenator() {
+	hyphenator, err := gofpdf.LoadPatternHyphenator(strings.NewReader(`
+% A few English patterns
+\patterns{
+hy3ph he2n hena4 hen5at 1na n2at 1tio 2io o2n 1ta 4tab 1ble 2bl
+1ca 4cat 1cu 1ti 4tiv 1ty 3ra 2rat 4ram 1ma 1gr
+}
+\hyphenation{ta-ble}
+`))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	txtStr := "Hyphenation improves the typography of narrow columns: " +
+		"the concatenation of long words, such as in typographical " +
+		"configurations, leaves large gaps in justified text without it."
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.AddPage()
+	y := pdf.GetY()
+	for j, h := range []gofpdf.Hyphenator{nil, hyphenator} {
+		pdf.SetHyphenator(h)
+		pdf.SetLeftMargin(10 + float64(j)*70)
+		pdf.SetXY(10+float64(j)*70, y)
+		pdf.MultiCell(45, 6, txtStr, "1", "J", false)
+	}
+	fileStr := example.Filename("Fpdf_SetHyphenator")
+	err = pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_SetHyphenator.pdf
+}
+
+// TestHyphenation checks the hyphenation of words with patterns and at the
+// ends of lines
+func TestHyphenation(t *testing.T) {
+	h, err := gofpdf.LoadPatternHyphenator(strings.NewReader(`% patterns
+\patterns{ % comment
+hy3ph he2n hena4 hen5at 1na n2at 1tio 2io o2n}
+\hyphenation{
+ta-ble }`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for word, want := range map[string]string{
+		"hyphenation": "[2 6]",
+		"Hyphenation": "[2 6]",
+		"table":       "[2]",
+		"hyphen":      "[2]",
+		"noun":        "[]",
+	} {
+		if got := fmt.Sprint(h.Hyphenate(word)); got != want {
+			t.Fatalf("%s hyphenated at %s, expecting %s", word, got, want)
+		}
+	}
+	if _, err = gofpdf.LoadPatternHyphenator(strings.NewReader("no patterns")); err == nil {
+		t.Fatal("file without patterns accepted")
+	}
+
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false)
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.SetHyphenator(h)
+	w := pdf.GetStringWidth("the hyphen-") + 2*pdf.GetCellMargin() + 0.1
+	lines := pdf.SplitLines([]byte("the hyphenation."), w)
+	if got := fmt.Sprintf("%q", lines); got != `["the hyphen-" "ation."]` {
+		t.Fatalf("text split into %s", got)
+	}
+	pdf.AddPage()
+	pdf.MultiCell(w, 5, "the hyphenation.", "", "L", false)
+	pageWd, _ := pdf.GetPageSize()
+	left, _, _, _ := pdf.GetMargins()
+	pdf.SetRightMargin(pageWd - left - w)
+	pdf.Write(5, "the hyphenation.")
+	pdf.AddUTF8Font("dejavu", "", example.FontFile("DejaVuSansCondensed.ttf"))
+	pdf.SetFont("dejavu", "", 12)
+	w = pdf.GetStringWidth("the hyphen-") + 2*pdf.GetCellMargin() + 0.1
+	if got := fmt.Sprintf("%q", pdf.SplitText("the hyphenation.", w)); got != `["the hyphen-" "ation."]` {
+		t.Fatalf("UTF-8 text split into %s", got)
+	}
+	var buf bytes.Buffer
+	if err = pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(buf.String(), "(the hyphen-)Tj"); n != 2 {
+		t.Fatalf("found %d hyphenated lines, expecting 2", n)
+	}
+}
+
+// ExampleFpdf_WriteParagraph demonstrates paragraphs with leading, spacing,
+// indentation and inline style changes.
+func ExampleFpdf_WriteParagraph() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFont("Times", "", 12)
+	pdf.AddPage()
+	heading := gofpdf.ParagraphStyle{SpaceAfter: 2}
+	body := gofpdf.ParagraphStyle{
+		Leading:         6,
+		SpaceAfter:      4,
+		FirstLineIndent: 8,
+		Alignment:       "J",
+	}
+	pdf.SetFontSize(16)
+	pdf.WriteParagraph(heading, "<b>Paragraph styles</b>")
+	pdf.SetFontSize(12)
+	txtStr := "Paragraphs are broken into lines that fill the width between " +
+		"the margins, with <i>italic</i>, <b>bold</b>, <u>underlined</u> and " +
+		"<s>struck out</s> text, or <b>bold text with <i>nested italic</i> " +
+		"words</b>. The lines of justified paragraphs, except the last one, " +
+		"are spread to the full width, and page breaks do not leave single " +
+		"lines of a paragraph at the bottom or the top of a page."
+	for j := 0; j < 12; j++ {
+		pdf.WriteParagraph(body, txtStr)
+	}
+	for _, align := range []string{"L", "C", "R"} {
+		pdf.WriteParagraph(gofpdf.ParagraphStyle{Alignment: align, SpaceAfter: 4},
+			"Short lines aligned with "+align+"\nand an explicit line break")
+	}
+	fileStr := example.Filename("Fpdf_WriteParagraph")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_WriteParagraph.pdf
+}
+
+// TestWriteParagraph checks the lines, justification and page breaks of
+// paragraphs
+func TestWriteParagraph(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false)
+	pdf.SetMargins(10, 10, 10)
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.AddPage()
+	y := pdf.GetY()
+	pdf.WriteParagraph(gofpdf.ParagraphStyle{Leading: 6, SpaceBefore: 2, SpaceAfter: 3}, "one\ntwo <b>three</b>\n\nfour")
+	if got := pdf.GetY(); math.Abs(got-y-2-24-3) > 1e-9 {
+		t.Fatalf("paragraph ends at %.2f, expecting %.2f", got, y+2+24+3)
+	}
+	words := strings.Repeat("justified text ", 20)
+	pdf.WriteParagraph(gofpdf.ParagraphStyle{Alignment: "J"}, words)
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "(three) Tj") || !strings.Contains(buf.String(), "/Helvetica-Bold") {
+		t.Fatal("bold text not found")
+	}
+	// The last word of the first line of justified text ends at the right
+	// margin and the last line is not justified
+	re := regexp.MustCompile(`BT ([\d.]+) ([\d.]+) Td \((justified|text)\) Tj ET`)
+	var lines [][][]string
+	for _, m := range re.FindAllStringSubmatch(buf.String(), -1) {
+		if len(lines) == 0 || lines[len(lines)-1][0][2] != m[2] {
+			lines = append(lines, nil)
+		}
+		lines[len(lines)-1] = append(lines[len(lines)-1], m)
+	}
+	if len(lines) < 2 {
+		t.Fatalf("found %d lines of justified text", len(lines))
+	}
+	k := pdf.GetConversionRatio()
+	first, last := lines[0], lines[len(lines)-1]
+	x, _ := strconv.ParseFloat(first[len(first)-1][1], 64)
+	if end := x/k + pdf.GetStringWidth(first[len(first)-1][3]); math.Abs(end-200) > 0.01 {
+		t.Fatalf("first line ends at %.2f, expecting 200", end)
+	}
+	x, _ = strconv.ParseFloat(last[1][1], 64)
+	if want := 10 + pdf.GetStringWidth(last[0][3]+" "); math.Abs(x/k-want) > 0.01 {
+		t.Fatalf("second word of last line at %.2f, expecting %.2f", x/k, want)
+	}
+
+	// Of four lines of which three fit on the page, two are kept together
+	// at the top of the next page
+	pdf = gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.AddPage()
+	_, pageHt := pdf.GetPageSize()
+	_, top, _, bottom := pdf.GetMargins()
+	pdf.SetY(pageHt - bottom - 3*10 - 1)
+	style := gofpdf.ParagraphStyle{Leading: 10}
+	pdf.WriteParagraph(style, "1\n2\n3\n4")
+	if page, y := pdf.PageNo(), pdf.GetY(); page != 2 || math.Abs(y-top-20) > 1e-9 {
+		t.Fatalf("paragraph ends on page %d at %.2f, expecting page 2 at %.2f", page, y, top+20)
+	}
+	// Three lines of which one fits move to the next page
+	pdf.SetY(pageHt - bottom - 10 - 1)
+	pdf.WriteParagraph(style, "1\n2\n3")
+	if page, y := pdf.PageNo(), pdf.GetY(); page != 3 || math.Abs(y-top-30) > 1e-9 {
+		t.Fatalf("paragraph ends on page %d at %.2f, expecting page 3 at %.2f", page, y, top+30)
+	}
+	if pdf.Error() != nil {
+		t.Fatal(pdf.Error())
+	}
+}
+
+// ExampleFpdf_WriteStyled demonstrates text with spans of different fonts,
+// colors and links that is flowed as one paragraph.
+func ExampleFpdf_WriteStyled() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.AddPage()
+	red := &gofpdf.RGBType{R: 200, G: 0, B: 0}
+	blue := &gofpdf.RGBType{R: 0, G: 0, B: 160}
+	spans := []gofpdf.Span{
+		{Text: "Styled text ", FontStyle: "B", SizeDelta: 6},
+		{Text: "mixes fonts such as "},
+		{Text: "Times", FontFamily: "Times", FontStyle: "I"},
+		{Text: " and "},
+		{Text: "Courier", FontFamily: "Courier"},
+		{Text: ", colors such as "},
+		{Text: "red", Color: red},
+		{Text: " and sizes from "},
+		{Text: "small", SizeDelta: -4},
+		{Text: " to "},
+		{Text: "large", SizeDelta: 4},
+		{Text: " within one block of text, which is broken into lines across " +
+			"the boundaries of its spans. Spans can also be links, to a "},
+		{Text: "web site", Color: blue, FontStyle: "U", URL: "https://github.com/jung-kurt/gofpdf"},
+		{Text: " for example, and "},
+		{Text: "un", FontStyle: "B"},
+		{Text: "broken", FontStyle: "I"},
+		{Text: " words can change their style."},
+	}
+	pdf.WriteStyled(gofpdf.ParagraphStyle{Alignment: "J", SpaceAfter: 6}, spans)
+	pdf.SetLeftMargin(60)
+	pdf.SetRightMargin(60)
+	pdf.WriteStyled(gofpdf.ParagraphStyle{Alignment: "C"}, spans)
+	fileStr := example.Filename("Fpdf_WriteStyled")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_WriteStyled.pdf
+}
+
+// TestWriteStyled checks the fonts, colors, links and line heights of spans
+func TestWriteStyled(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false)
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.AddPage()
+	y := pdf.GetY()
+	pdf.WriteStyled(gofpdf.ParagraphStyle{}, []gofpdf.Span{
+		{Text: "Large ", SizeDelta: 8},
+		{Text: "red", Color: &gofpdf.RGBType{R: 255}},
+		{Text: " link", URL: "https://example.com"},
+		{Text: "ed", FontStyle: "B"},
+	})
+	if want := y + 1.25*20/pdf.GetConversionRatio(); math.Abs(pdf.GetY()-want) > 1e-9 {
+		t.Fatalf("paragraph ends at %.2f, expecting %.2f", pdf.GetY(), want)
+	}
+	if size, _ := pdf.GetFontSize(); size != 12 {
+		t.Fatalf("font size is %v after styled text", size)
+	}
+	if r, g, b := pdf.GetTextColor(); r != 0 || g != 0 || b != 0 {
+		t.Fatalf("text color is %d %d %d after styled text", r, g, b)
+	}
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	str := buf.String()
+	for _, want := range []string{" 20.00 Tf", "1.000 0.000 0.000 rg BT", "(red) Tj",
+		"/URI (https://example.com)", "/Helvetica-Bold"} {
+		if !strings.Contains(str, want) {
+			t.Fatalf("%q not found", want)
+		}
+	}
+	// The pieces of the word "linked" are printed next to each other
+	m := regexp.MustCompile(`(?s)BT ([\d.]+) [\d.]+ Td \(link\) Tj ET.*?BT ([\d.]+) [\d.]+ Td \(ed\) Tj ET`).FindStringSubmatch(str)
+	if m == nil {
+		t.Fatal("linked not found")
+	}
+	x1, _ := strconv.ParseFloat(m[1], 64)
+	x2, _ := strconv.ParseFloat(m[2], 64)
+	if wd := (x2 - x1) / pdf.GetConversionRatio(); math.Abs(wd-pdf.GetStringWidth("link")) > 0.01 {
+		t.Fatalf("ed follows link at %.2f, expecting %.2f", wd, pdf.GetStringWidth("link"))
+	}
+}
+
+// ExampleFpdf_WriteHTML demonstrates rendering HTML with lists, a table, an
+// image and inline styles.
+func ExampleFpdf_WriteHTML() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFont("Helvetica", "", 11)
+	pdf.AddPage()
+	pdf.RegisterImageOptions(example.ImageFile("logo.png"), gofpdf.ImageOptions{})
+	htmlStr := `<h1>WriteHTML</h1>
+<p style="text-align: justify">WriteHTML lays out <b>paragraphs</b> with
+<i>inline</i> <u>formatting</u>, <span style="color: #c00000">colors</span>,
+<font size="5">sizes</font> and <code>fonts</code>, and
+<a href="https://github.com/jung-kurt/gofpdf">links</a> &amp; entities
+such as &eacute; and &laquo;&nbsp;&raquo;.
+<ul>
+<li>Lists can be nested:
+  <ol>
+  <li>first item
+  <li>second item
+  </ol>
+<li>Items are indented.
+</ul>
+<blockquote style="color: gray; font-style: italic">Block quotes are
+indented on both sides.</blockquote>
+<table border="1" width="80%">
+<tr><th>Tag</th><th>Rendering</th></tr>
+<tr><td>&lt;table&gt;</td><td>Drawn with NewTable()</td></tr>
+<tr><td>&lt;img&gt;</td><td>Taken from the image registry</td></tr>
+</table>
+<p style="margin-top: 8mm" align="center">Centered image:</p>
+<img src="` + example.ImageFile("logo.png") + `" width="120" align="center">
+<hr>
+<p style="font-size: 8pt">Lengths can be given in pt, px, mm, cm, in, em
+or percent.</p>`
+	pdf.WriteHTML(htmlStr, gofpdf.HTMLOptions{})
+	fileStr := example.Filename("Fpdf_WriteHTML")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_WriteHTML.pdf
+}
+
+// TestWriteHTML checks the layout of lists, tables and margins
+func TestWriteHTML(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false)
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.AddPage()
+	y := pdf.GetY()
+	pdf.WriteHTML(`<p style="margin: 10mm 0 5mm">One</p><p>Two &#8364;</p>`,
+		gofpdf.HTMLOptions{ParagraphSpacing: 2})
+	// The margins between the paragraphs collapse to 5 mm
+	lineHt := 1.25 * 12 / pdf.GetConversionRatio()
+	if want := y + 10 + lineHt + 5 + lineHt + 2; math.Abs(pdf.GetY()-want) > 1e-9 {
+		t.Fatalf("paragraphs end at %.2f, expecting %.2f", pdf.GetY(), want)
+	}
+	pdf.WriteHTML(`<ol start="3"><li>three<ul><li>nested</ul><li>four</ol>
+<table><tr><th>A<th>B<tr><td>1<td align="right">2</table>
+<span style="color: rgb(255, 0, 0); font-size: 2em">red</span>
+<a href="https://example.com">link</a>`, gofpdf.HTMLOptions{ListIndent: 10})
+	if size, _ := pdf.GetFontSize(); size != 12 {
+		t.Fatalf("font size is %v after HTML", size)
+	}
+	if r, g, b := pdf.GetTextColor(); r != 0 || g != 0 || b != 0 {
+		t.Fatalf("text color is %d %d %d after HTML", r, g, b)
+	}
+	if err := pdf.Error(); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	str := buf.String()
+	for _, want := range []string{"(\x80) Tj", "(3.) Tj", "(4.) Tj", "(\x95) Tj",
+		"(nested) Tj", "(A)Tj", "(B)Tj", "(2)Tj", "/Helvetica-Bold",
+		"1.000 0.000 0.000 rg", " 24.00 Tf", "/URI (https://example.com)"} {
+		if !strings.Contains(str, want) {
+			t.Fatalf("%q not found", want)
+		}
+	}
+	// List items are indented by the list indent and nested lists further
+	x := func(txt string) float64 {
+		m := regexp.MustCompile(`BT ([\d.]+) [\d.]+ Td \(` + txt + `\) Tj`).FindStringSubmatch(str)
+		if m == nil {
+			t.Fatalf("%s not found", txt)
+		}
+		v, _ := strconv.ParseFloat(m[1], 64)
+		return v / pdf.GetConversionRatio()
+	}
+	left, _, _, _ := pdf.GetMargins()
+	if math.Abs(x("three")-(left+10)) > 0.01 || math.Abs(x("nested")-(left+20)) > 0.01 {
+		t.Fatalf("list items at %.2f and %.2f", x("three"), x("nested"))
+	}
+}
+
+// ExampleFpdf_TextOnPath demonstrates text along a curve and around a seal.
+func ExampleFpdf_TextOnPath() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFont("Helvetica", "", 14)
+	pdf.AddPage()
+	// A sine wave approximated by short segments
+	var wave []gofpdf.PointType
+	for x := 20.0; x <= 190; x += 1 {
+		wave = append(wave, gofpdf.PointType{X: x, Y: 40 - 12*math.Sin((x-20)/20)})
+	}
+	pdf.SetDrawColor(200, 200, 200)
+	for j := 1; j < len(wave); j++ {
+		pdf.Line(wave[j-1].X, wave[j-1].Y, wave[j].X, wave[j].Y)
+	}
+	pdf.SetTextColor(0, 0, 128)
+	pdf.TextOnPath(wave, "Text can follow any path, such as this sine wave, one character at a time.")
+	// A seal with text along its upper and lower halves
+	x, y, r := 105.0, 130.0, 40.0
+	pdf.SetDrawColor(128, 0, 0)
+	pdf.SetLineWidth(1)
+	pdf.Circle(x, y, r+8, "D")
+	pdf.SetLineWidth(0.3)
+	pdf.Circle(x, y, r-8, "D")
+	pdf.SetTextColor(128, 0, 0)
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.SetCharSpacing(0.5)
+	top := "GOFPDF SEAL OF QUALITY"
+	angle := pdf.GetStringWidth(top) / r * 180 / math.Pi
+	pdf.TextOnArc(x, y, r, 90+angle/2, true, top)
+	bottom := "TEXT ON ARCS"
+	// The lower text has its tops inside the circle, so its baseline is on
+	// a larger circle
+	rb := r + 4
+	angle = pdf.GetStringWidth(bottom) / rb * 180 / math.Pi
+	pdf.TextOnArc(x, y, rb, 270-angle/2, false, bottom)
+	pdf.SetCharSpacing(0)
+	pdf.SetFont("Times", "BI", 28)
+	pdf.TextOnArc(x, y, 12, 180, true, "2024")
+	fileStr := example.Filename("Fpdf_TextOnPath")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_TextOnPath.pdf
+}
+
+// TestTextOnPath checks the position and rotation of characters along a
+// path
+func TestTextOnPath(t *testing.T) {
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.SetCompression(false)
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.AddPage()
+	// Down the page, after a repeated point
+	pdf.TextOnPath([]gofpdf.PointType{{X: 100, Y: 100}, {X: 100, Y: 100}, {X: 100, Y: 200}}, "ab")
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	str := buf.String()
+	_, h := pdf.GetPageSize()
+	wa := pdf.GetStringWidth("a")
+	for _, want := range []string{
+		"0.00000 -1.00000 1.00000 0.00000",
+		fmt.Sprintf("BT 100.00 %.2f Td (a) Tj ET", h-100),
+		fmt.Sprintf("BT 100.00 %.2f Td (b) Tj ET", h-100-wa),
+	} {
+		if !strings.Contains(str, want) {
+			t.Fatalf("%q not found", want)
+		}
+	}
+	pdf = gofpdf.New("P", "pt", "A4", "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.AddPage()
+	pdf.TextOnPath([]gofpdf.PointType{{X: 100, Y: 100}}, "a")
+	if pdf.Error() == nil {
+		t.Fatal("expecting error for path with one point")
+	}
+}
+
+// ExampleFpdf_WriteSuperscript demonstrates superscripts, subscripts, text
+// rise and small capitals.
+func ExampleFpdf_WriteSuperscript() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFont("Times", "", 14)
+	pdf.AddPage()
+	lineHt := 8.0
+	pdf.Write(lineHt, "Einstein's famous equation, E = mc")
+	pdf.WriteSuperscript(lineHt, "2")
+	pdf.Write(lineHt, ", and the formula of water, H")
+	pdf.WriteSubscript(lineHt, "2")
+	pdf.Write(lineHt, "O, are written without moving the current position by hand. "+
+		"Footnote markers")
+	pdf.WriteSuperscript(lineHt, "1")
+	pdf.Write(lineHt, " are superscripts too.")
+	pdf.Ln(2 * lineHt)
+	pdf.Write(lineHt, "The text rise can also be set ")
+	pdf.SetTextRise(3)
+	pdf.SetFontStyle("U")
+	pdf.Write(lineHt, "directly")
+	pdf.SetFontStyle("")
+	pdf.SetTextRise(0)
+	pdf.Write(lineHt, ", in points.")
+	pdf.Ln(2 * lineHt)
+	pdf.SetSmallCaps(true)
+	pdf.Write(lineHt, "Small Capitals ")
+	pdf.SetSmallCaps(false)
+	pdf.Write(lineHt, "are synthesized from the capital letters of fonts that lack them.")
+	pdf.Ln(2 * lineHt)
+	pdf.SetSmallCaps(true)
+	pdf.SetFont("Helvetica", "B", 12)
+	pdf.CellFormat(0, 10, "Centered Heading in Small Caps", "1", 1, "C", false, 0, "")
+	pdf.SetSmallCaps(false)
+	fileStr := example.Filename("Fpdf_WriteSuperscript")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_WriteSuperscript.pdf
+}
+
+// TestTextRise checks text rise and small capitals
+func TestTextRise(t *testing.T) {
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.SetCompression(false)
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.AddPage()
+	x := pdf.GetX()
+	pdf.WriteSuperscript(12, "2")
+	if size, _ := pdf.GetFontSize(); size != 10 || pdf.GetTextRise() != 0 {
+		t.Fatalf("font size %v and text rise %v after superscript", size, pdf.GetTextRise())
+	}
+	pdf.SetFontSize(6.5)
+	if wd := pdf.GetStringWidth("2"); math.Abs(pdf.GetX()-x-wd) > 1e-9 {
+		t.Fatalf("superscript is %.2f wide, expecting %.2f", pdf.GetX()-x, wd)
+	}
+	pdf.SetFontSize(10)
+	pdf.SetTextRise(5)
+	pdf.AddPage()
+	pdf.SetTextRise(0)
+	wd := pdf.GetStringWidth("Ab")
+	pdf.SetSmallCaps(true)
+	scWd := pdf.GetStringWidth("Ab")
+	pdf.SetFontSize(7.5)
+	bWd := pdf.GetStringWidth("B")
+	pdf.SetFontSize(10)
+	if want := pdf.GetStringWidth("A") + bWd; math.Abs(scWd-want) > 1e-9 || scWd == wd {
+		t.Fatalf("small caps width %.3f, expecting %.3f", scWd, want)
+	}
+	pdf.Text(10, 20, "Ab")
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	str := buf.String()
+	// The superscript is raised by 35% of the font size, less the raise of
+	// the smaller baseline by Write()
+	for _, want := range []string{"2.45 Ts", "0.00 Ts", "5.00 Ts\n"} {
+		if !strings.Contains(str, want) {
+			t.Fatalf("%q not found", want)
+		}
+	}
+	if !regexp.MustCompile(`\(A\) Tj /F\w+ 7.50 Tf \(B\) Tj /F\w+ 10.00 Tf ET`).MatchString(str) {
+		t.Fatal("small capitals not found")
+	}
+}
+
+// TestWriteScriptOutsideBMP verifies that superscripts and subscripts with
+// characters outside the Basic Multilingual Plane, which UTF-8 fonts do not
+// support, set an error
+func TestWriteScriptOutsideBMP(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddUTF8Font("dejavu", "", example.FontFile("DejaVuSansCondensed.ttf"))
+	pdf.SetFont("dejavu", "", 12)
+	pdf.AddPage()
+	pdf.Write(6, "Smile")
+	pdf.WriteSuperscript(6, "\U0001F600")
+	if pdf.Error() == nil {
+		t.Fatal("no error with superscript outside the Basic Multilingual Plane")
+	}
+	if size, _ := pdf.GetFontSize(); size != 12 {
+		t.Fatalf("font size %v after superscript", size)
+	}
+}
+
+// ExampleFpdf_SetTabStops demonstrates tab stops with leaders and decimal
+// alignment.
+func ExampleFpdf_SetTabStops() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.AddPage()
+	lineHt := 7.0
+	pdf.SetFontStyle("B")
+	pdf.Write(lineHt, "Contents\n\n")
+	pdf.SetFontStyle("")
+	pdf.SetTabStops([]gofpdf.TabStop{{Pos: 10}, {Pos: 150, Alignment: "R", Leader: "."}})
+	for _, entry := range []string{
+		"1\tIntroduction\t1",
+		"2\tTab stops\t4",
+		"3\tLeaders\t12",
+		"4\tDecimal alignment\t117",
+	} {
+		pdf.Write(lineHt, entry+"\n")
+	}
+	pdf.Ln(lineHt)
+	pdf.SetTabStops([]gofpdf.TabStop{{Pos: 70, Alignment: "C"}, {Pos: 130, Alignment: "D", Leader: "-"}})
+	pdf.SetFontStyle("B")
+	pdf.Write(lineHt, "Item\tQuantity\tPrice\n")
+	pdf.SetFontStyle("")
+	for _, row := range []string{
+		"Paper\t500\t4.99",
+		"Pencils\t12\t11.5",
+		"Eraser\t1\t0.75",
+		"Binder\t3\t125",
+	} {
+		pdf.Write(lineHt, row+"\n")
+	}
+	pdf.Ln(lineHt)
+	pdf.SetTabStops(nil)
+	pdf.Write(lineHt, "Without tab stops,\ttabs advance\tto multiples\tof half an inch.")
+	fileStr := example.Filename("Fpdf_SetTabStops")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_SetTabStops.pdf
+}
+
+// TestTabStops checks the alignment of text at tab stops
+func TestTabStops(t *testing.T) {
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.SetCompression(false)
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.SetCellMargin(0)
+	pdf.AddPage()
+	left, _, _, _ := pdf.GetMargins()
+	pdf.SetTabStops([]gofpdf.TabStop{{Pos: 200, Alignment: "D", Leader: "."}, {Pos: 100, Alignment: "R"}})
+	pdf.Write(12, "a\tbb\t12.5\n\tc")
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	str := buf.String()
+	x := func(txt string) float64 {
+		m := regexp.MustCompile(`BT ([\d.]+) [\d.]+ Td \(` + regexp.QuoteMeta(txt) + `\)Tj`).FindStringSubmatch(str)
+		if m == nil {
+			t.Fatalf("%s not found", txt)
+		}
+		v, _ := strconv.ParseFloat(m[1], 64)
+		return v
+	}
+	for _, c := range []struct {
+		txt  string
+		want float64
+	}{
+		{"bb", left + 100 - pdf.GetStringWidth("bb")},
+		{"12.5", left + 200 - pdf.GetStringWidth("12")},
+		{"c", left + 100 - pdf.GetStringWidth("c")},
+	} {
+		if got := x(c.txt); math.Abs(got-c.want) > 0.01 {
+			t.Fatalf("%s at %.2f, expecting %.2f", c.txt, got, c.want)
+		}
+	}
+	if !strings.Contains(str, "(....") {
+		t.Fatal("leader not found")
+	}
+	pdf.SetTabStops([]gofpdf.TabStop{{Alignment: "X"}})
+	if pdf.Error() == nil {
+		t.Fatal("expecting error for invalid alignment")
+	}
+}
+
+// ExampleFpdf_BeginBlock demonstrates keeping headings together with their
+// paragraphs across page breaks.
+func ExampleFpdf_BeginBlock() {
+	pdf := gofpdf.New("P", "mm", "A5", "")
+	pdf.SetFont("Times", "", 11)
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-12)
+		pdf.CellFormat(0, 6, fmt.Sprintf("Page %d", pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+	pdf.AddPage()
+	txtStr := strings.Repeat("Blocks keep related content together on one page. ", 8)
+	for j := 1; j <= 8; j++ {
+		// The heading is kept with the paragraph that follows it
+		pdf.BeginBlock()
+		pdf.SetFont("Helvetica", "B", 14)
+		pdf.CellFormat(0, 10, fmt.Sprintf("Section %d", j), "B", 1, "", false, 0, "")
+		pdf.SetFont("Times", "", 11)
+		pdf.MultiCell(0, 5, txtStr, "", "J", false)
+		pdf.EndBlock()
+		pdf.Ln(4)
+	}
+	// Paragraphs that are each kept together
+	pdf.SetKeepTogether(true)
+	for j := 0; j < 4; j++ {
+		pdf.MultiCell(0, 5, txtStr, "1", "L", false)
+		pdf.Ln(4)
+	}
+	pdf.SetKeepTogether(false)
+	fileStr := example.Filename("Fpdf_BeginBlock")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_BeginBlock.pdf
+}
+
+// TestBeginBlock checks that a block is moved to the next page
+func TestBeginBlock(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false)
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.AddPage()
+	_, top, _, _ := pdf.GetMargins()
+	_, ht := pdf.GetPageSize()
+	pdf.SetY(ht - 40)
+	pdf.BeginBlock()
+	pdf.CellFormat(0, 10, "Heading", "", 1, "", false, 0, "https://example.com")
+	pdf.MultiCell(0, 10, "One\nTwo\nThree", "", "L", false)
+	pdf.EndBlock()
+	if pdf.PageNo() != 2 {
+		t.Fatalf("block ends on page %d", pdf.PageNo())
+	}
+	// The block has 1 + 3 lines of 10 mm
+	if want := top + 40; math.Abs(pdf.GetY()-want) > 1e-9 {
+		t.Fatalf("block ends at %.2f, expecting %.2f", pdf.GetY(), want)
+	}
+	// A block that starts at the top of the page is split as if it were
+	// not a block
+	long := strings.Repeat("Text that does not fit on the page. ", 150)
+	ref := gofpdf.New("P", "mm", "A4", "")
+	ref.SetFont("Helvetica", "", 12)
+	ref.AddPage()
+	ref.MultiCell(0, 10, long, "", "L", false)
+	pdf.AddPage()
+	pdf.BeginBlock()
+	pdf.MultiCell(0, 10, long, "", "L", false)
+	pdf.EndBlock()
+	if pdf.PageNo() != ref.PageNo()+2 || pdf.GetY() != ref.GetY() {
+		t.Fatalf("long block ends on page %d at %.2f", pdf.PageNo(), pdf.GetY())
+	}
+	pdf.EndBlock()
+	if pdf.Error() == nil {
+		t.Fatal("expecting error for unbalanced EndBlock()")
+	}
+	pdf.ClearError()
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	str := buf.String()
+	// Page 1 is empty apart from its initial state, and the heading is moved
+	// up to the top of page 2
+	dy := (ht - 40 - top) * pdf.GetConversionRatio()
+	m := regexp.MustCompile(`q BT /F\w+ 12.00 Tf ET .*? 1 0 0 1 0.00 ([\d.]+) cm\n` +
+		`BT [\d.]+ [\d.]+ Td \(Heading\)Tj ET`).FindStringSubmatch(str)
+	if m == nil {
+		t.Fatal("moved block not found")
+	}
+	if v, _ := strconv.ParseFloat(m[1], 64); math.Abs(v-dy) > 0.01 {
+		t.Fatalf("block moved by %.2f, expecting %.2f", v, dy)
+	}
+	if strings.Count(str, "/URI (https://example.com)") != 1 {
+		t.Fatal("link not found")
+	}
+}
+
+// ExampleFpdf_SetColumns demonstrates text that flows through columns,
+// including balanced columns at the end of an article.
+func ExampleFpdf_SetColumns() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-15)
+		pdf.SetFont("Helvetica", "I", 8)
+		pdf.CellFormat(0, 10, fmt.Sprintf("Page %d", pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+	pdf.AddPage()
+	pdf.SetFont("Helvetica", "B", 18)
+	pdf.CellFormat(0, 12, "Columns", "B", 1, "C", false, 0, "")
+	pdf.Ln(4)
+	txtStr := strings.Repeat("Text flows down the first column, then down the "+
+		"next one, and on to the next page after the last column. ", 30)
+	pdf.SetColumns(3, 6)
+	pdf.SetFont("Times", "", 11)
+	pdf.Write(5, txtStr)
+	// The next section starts at the top of the next column
+	pdf.ColumnBreak()
+	pdf.SetFont("Times", "B", 11)
+	pdf.Write(5, "Balanced columns\n")
+	pdf.SetFont("Times", "", 11)
+	pdf.MultiCellBalanced(5, strings.Repeat("Balanced columns end at the same height. ", 40), "J")
+	pdf.SetColumns(1, 0)
+	pdf.Ln(4)
+	pdf.SetFont("Helvetica", "", 11)
+	pdf.MultiCell(0, 5, "Text after the columns spans the page again.", "T", "C", false)
+	fileStr := example.Filename("Fpdf_SetColumns")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_SetColumns.pdf
+}
+
+// TestColumns checks the flow of text through columns
+func TestColumns(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.AddPage()
+	left, top, right, _ := pdf.GetMargins()
+	wd, _ := pdf.GetPageSize()
+	pdf.SetY(50)
+	pdf.SetColumns(2, 10)
+	colW := (wd - left - right - 10) / 2
+	if l, _, r, _ := pdf.GetMargins(); l != left || math.Abs(wd-r-left-colW) > 1e-9 {
+		t.Fatalf("first column from %.2f to %.2f", l, wd-r)
+	}
+	long := strings.Repeat("Text that flows through the columns. ", 30)
+	lines := len(pdf.SplitText(long, colW))
+	pdf.MultiCell(0, 10, long, "", "L", false)
+	// 22 lines of 10 mm fit into the first column
+	if pdf.PageNo() != 1 || pdf.GetColumn() != 1 {
+		t.Fatalf("text ends on page %d in column %d", pdf.PageNo(), pdf.GetColumn())
+	}
+	if l, _, _, _ := pdf.GetMargins(); math.Abs(l-(left+colW+10)) > 1e-9 {
+		t.Fatalf("second column at %.2f", l)
+	}
+	if want := 50 + float64(lines-22)*10; math.Abs(pdf.GetY()-want) > 1e-9 {
+		t.Fatalf("text ends at %.2f, expecting %.2f", pdf.GetY(), want)
+	}
+	// After the last column, the text continues in the first column of the
+	// next page
+	pdf.ColumnBreak()
+	if pdf.PageNo() != 2 || pdf.GetColumn() != 0 || pdf.GetY() != top {
+		t.Fatalf("column break to page %d, column %d at %.2f", pdf.PageNo(), pdf.GetColumn(), pdf.GetY())
+	}
+	pdf.Write(10, long+long)
+	if pdf.PageNo() != 2 || pdf.GetColumn() != 1 {
+		t.Fatalf("written text ends on page %d in column %d", pdf.PageNo(), pdf.GetColumn())
+	}
+	// Balanced columns on the next page
+	pdf.ColumnBreak()
+	txtStr := strings.Repeat("Balanced text. ", 100)
+	lines = len(pdf.SplitText(txtStr, colW))
+	pdf.MultiCellBalanced(10, txtStr, "L")
+	if want := top + float64((lines+1)/2)*10; pdf.PageNo() != 3 || math.Abs(pdf.GetY()-want) > 1e-9 {
+		t.Fatalf("balanced text ends on page %d at %.2f, expecting %.2f", pdf.PageNo(), pdf.GetY(), want)
+	}
+	if pdf.GetColumn() != 0 || pdf.GetX() != left {
+		t.Fatalf("balanced text ends in column %d at %.2f", pdf.GetColumn(), pdf.GetX())
+	}
+	pdf.SetColumns(0, 0)
+	if l, _, r, _ := pdf.GetMargins(); l != left || r != right {
+		t.Fatalf("margins %.2f and %.2f after columns", l, r)
+	}
+	pdf.SetColumns(3, wd)
+	if pdf.Error() == nil {
+		t.Fatal("expecting error for columns that do not fit")
+	}
+}
+
+// ExampleFpdf_AddFootnote demonstrates footnotes, including one that is
+// continued on the next page.
+func ExampleFpdf_AddFootnote() {
+	pdf := gofpdf.New("P", "mm", "A5", "")
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-12)
+		pdf.SetFont("Helvetica", "I", 8)
+		pdf.CellFormat(0, 6, fmt.Sprintf("Page %d", pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+	pdf.AddPage()
+	pdf.SetFont("Times", "", 12)
+	txtStr := strings.Repeat("The text above the footnotes is shortened to make room for them. ", 4)
+	for j := 0; j < 3; j++ {
+		pdf.Write(6, txtStr+"A footnote is referenced here")
+		pdf.AddFootnote("Footnotes are printed at the bottom of the page on which they are referenced.")
+		pdf.Write(6, ".\n\n")
+	}
+	pdf.Write(6, txtStr+"This footnote is long")
+	pdf.AddFootnote(strings.Repeat("A footnote that does not fit on the page continues at the bottom of the next one. ", 12))
+	pdf.Write(6, ".\n\n"+strings.Repeat(txtStr, 3))
+	fileStr := example.Filename("Fpdf_AddFootnote")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_AddFootnote.pdf
+}
+
+// TestAddFootnote checks that footnotes reserve space at the bottom of the
+// page and continue on the next page
+func TestAddFootnote(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false)
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.AddPage()
+	_, ht := pdf.GetPageSize()
+	_, _, _, bottom := pdf.GetMargins()
+	pdf.Write(5, "Text")
+	pdf.AddFootnote("Note")
+	if pdf.GetFootnoteCount() != 1 {
+		t.Fatalf("%d footnotes", pdf.GetFootnoteCount())
+	}
+	// A line of 8 points with a leading of 1.25 and the rule of 8 points
+	rule, line := 8/pdf.GetConversionRatio(), 10/pdf.GetConversionRatio()
+	pdf.SetY(ht - bottom - rule - line - 5)
+	pdf.Write(5, "Above the footnote\nBelow the footnote")
+	if pdf.PageNo() != 2 {
+		t.Fatalf("text ends on page %d", pdf.PageNo())
+	}
+	// A footnote that needs three pages
+	pdf.AddFootnote(strings.Repeat("Long footnote. ", 1500))
+	pdf.Write(5, ".")
+	if err := pdf.Output(ioutil.Discard); err != nil {
+		t.Fatal(err)
+	}
+	if pdf.PageNo() != 4 {
+		t.Fatalf("document ends on page %d", pdf.PageNo())
+	}
+	pdf = gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false)
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.AddPage()
+	pdf.Write(5, "Text")
+	pdf.AddFootnote("Note")
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	str := buf.String()
+	// The number of the footnote is raised in the text and in the footnote
+	if strings.Count(str, "(1)Tj") != 2 || !strings.Contains(str, "(Note)Tj") {
+		t.Fatal("footnote not found")
+	}
+	if !regexp.MustCompile(`/F\w+ 5\.20 Tf ET\n[\d.]+ Ts`).MatchString(str) {
+		t.Fatal("raised footnote number not found")
+	}
+}
+
+// TestFootnoteCharacters verifies that footnotes with characters beyond the
+// width table of the font are laid out without a panic
+func TestFootnoteCharacters(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.AddPage()
+	pdf.Write(5, "Text")
+	pdf.AddFootnote("Prices in €, names in 中文")
+	if err := pdf.Output(ioutil.Discard); err != nil {
+		t.Fatal(err)
+	}
+	pdf = gofpdf.New("P", "mm", "A4", "")
+	pdf.AddUTF8Font("dejavu", "", example.FontFile("DejaVuSansCondensed.ttf"))
+	pdf.SetFont("dejavu", "", 10)
+	pdf.AddPage()
+	if lines := pdf.SplitText("Smile \U0001F600", 100); len(lines) != 1 {
+		t.Fatalf("%d lines", len(lines))
+	}
+	pdf.Write(5, "Text")
+	pdf.AddFootnote("Smile \U0001F600")
+	if pdf.Output(ioutil.Discard) == nil {
+		t.Fatal("no error with footnote outside the Basic Multilingual Plane")
+	}
+}
+
+// ExampleFpdf_RenderTOC demonstrates a table of contents that is inserted
+// after the title page once the document is complete.
+func ExampleFpdf_RenderTOC() {
+	pdf := gofpdf.New("P", "mm", "A5", "")
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-12)
+		pdf.SetFont("Helvetica", "I", 8)
+		pdf.CellFormat(0, 6, fmt.Sprintf("Page %d", pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+	pdf.AddPage()
+	pdf.SetFont("Helvetica", "B", 24)
+	pdf.CellFormat(0, 100, "Table of Contents", "", 1, "C", false, 0, "")
+	txtStr := strings.Repeat("The table of contents lists the chapters and sections of this document. ", 12)
+	for j := 1; j <= 6; j++ {
+		pdf.AddPage()
+		pdf.SetFont("Helvetica", "B", 16)
+		title := fmt.Sprintf("Chapter %d", j)
+		pdf.TOCEntry(0, title)
+		pdf.CellFormat(0, 10, title, "", 1, "", false, 0, "")
+		for k := 1; k <= 3; k++ {
+			pdf.SetFont("Helvetica", "B", 12)
+			title = fmt.Sprintf("Section %d.%d", j, k)
+			pdf.TOCEntry(1, title)
+			pdf.CellFormat(0, 8, title, "", 1, "", false, 0, "")
+			pdf.SetFont("Times", "", 11)
+			pdf.MultiCell(0, 5, txtStr, "", "J", false)
+		}
+	}
+	// The table of contents follows the title page
+	pdf.AddPage()
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.CellFormat(0, 10, "Contents", "", 1, "", false, 0, "")
+	pdf.SetFont("Times", "", 12)
+	pdf.RenderTOC(2)
+	fileStr := example.Filename("Fpdf_RenderTOC")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_RenderTOC.pdf
+}
+
+// TestRenderTOC checks the page numbers and links of the table of contents
+func TestRenderTOC(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false)
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.AddPage()
+	pdf.Cell(0, 10, "Title")
+	for j := 2; j <= 4; j++ {
+		pdf.AddPage()
+		pdf.SetY(100)
+		pdf.TOCEntry(0, fmt.Sprintf("Entry%d", j))
+		pdf.Cell(0, 10, fmt.Sprintf("Page%d", j))
+	}
+	pdf.AddPage()
+	// Enough entries for two pages
+	for j := 0; j < 60; j++ {
+		pdf.TOCEntry(1, "Sub")
+	}
+	pdf.RenderTOC(2)
+	if pdf.PageCount() != 6 || pdf.PageNo() != 6 {
+		t.Fatalf("%d pages, current page %d", pdf.PageCount(), pdf.PageNo())
+	}
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	str := buf.String()
+	// The content pages follow the table of contents
+	order := regexp.MustCompile(`\((Title|Entry\d|Page\d)\)Tj`).FindAllStringSubmatch(str, -1)
+	var got []string
+	for _, m := range order {
+		got = append(got, m[1])
+	}
+	if want := "Title Entry2 Entry3 Entry4 Page2 Page3 Page4"; strings.Join(got, " ") != want {
+		t.Fatalf("text in order %v, expecting %s", got, want)
+	}
+	if !regexp.MustCompile(`\(Entry2\)Tj ET\nBT [\d.]+ [\d.]+ Td \(\.+\)Tj ET\nBT [\d.]+ [\d.]+ Td \(4\)Tj`).MatchString(str) {
+		t.Fatal("page number of first entry not found")
+	}
+	// Page objects are numbered 3, 5, 7 and so on, and the link of the
+	// first entry points to page 4
+	if !strings.Contains(str, "/Dest [9 0 R /XYZ 0 558.43 null]") {
+		t.Fatal("link to first entry not found")
+	}
+	pdf = gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.AddPage()
+	pdf.RenderTOC(2)
+	if pdf.Error() == nil {
+		t.Fatal("expecting error for invalid page number")
+	}
+}
+
+// ExampleFpdf_AddNamedDest demonstrates links to named destinations within
+// the document and in another document of a document set.
+func ExampleFpdf_AddNamedDest() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFont("Helvetica", "", 14)
+	pdf.AddPage()
+	pdf.SetTextColor(0, 0, 192)
+	for j := 1; j <= 3; j++ {
+		txtStr := fmt.Sprintf("Go to chapter %d", j)
+		pdf.LinkToDest(pdf.GetX(), pdf.GetY(), pdf.GetStringWidth(txtStr), 10, fmt.Sprintf("chapter-%d", j))
+		pdf.Cell(0, 10, txtStr)
+		pdf.Ln(10)
+	}
+	txtStr := "Open the first section of the other volume"
+	pdf.LinkToFile(pdf.GetX(), pdf.GetY(), pdf.GetStringWidth(txtStr), 10, "volume2.pdf", "section-1")
+	pdf.Cell(0, 10, txtStr)
+	pdf.SetTextColor(0, 0, 0)
+	for j := 1; j <= 3; j++ {
+		pdf.AddPage()
+		pdf.SetY(40 * float64(j))
+		pdf.AddNamedDest(fmt.Sprintf("chapter-%d", j), -1, -1)
+		pdf.Cell(0, 10, fmt.Sprintf("Chapter %d", j))
+	}
+	fileStr := example.Filename("Fpdf_AddNamedDest")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_AddNamedDest.pdf
+}
+
+// TestAddNamedDest checks the /Dests name tree and the links to named
+// destinations
+func TestAddNamedDest(t *testing.T) {
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.SetCompression(false)
+	pdf.AddPage()
+	pdf.LinkToDest(10, 10, 50, 20, "intro")
+	pdf.LinkToFile(10, 40, 50, 20, "other.pdf", "")
+	pdf.LinkToFile(10, 70, 50, 20, "other.pdf", "part-2")
+	pdf.AddPage()
+	pdf.AddNamedDest("zeta", 1, 100)
+	pdf.AddNamedDest("intro", -1, 200)
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	str := buf.String()
+	_, ht := pdf.GetPageSize()
+	for _, want := range []string{
+		"/Dest (intro)>>",
+		"/A <</S /GoToR /F (other.pdf) /D [0 /Fit]>>>>",
+		"/A <</S /GoToR /F (other.pdf) /D (part-2)>>>>",
+		fmt.Sprintf("/Dests << /Names [ (intro) [5 0 R /XYZ 0 %.2f null] (zeta) [3 0 R /XYZ 0 %.2f null] ] >>",
+			ht-200, ht-100),
+	} {
+		if !strings.Contains(str, want) {
+			t.Fatalf("%s not found", want)
+		}
+	}
+	pdf = gofpdf.New("P", "pt", "A4", "")
+	pdf.AddPage()
+	pdf.LinkToDest(10, 10, 50, 20, "missing")
+	if err := pdf.Output(ioutil.Discard); err == nil {
+		t.Fatal("expecting error for undefined named destination")
+	}
+	pdf = gofpdf.New("P", "pt", "A4", "")
+	pdf.AddPage()
+	pdf.AddNamedDest("later", 2, 0)
+	if pdf.Error() == nil {
+		t.Fatal("expecting error for invalid page")
+	}
+}
+
+// ExampleFpdf_BookmarkEx demonstrates bookmarks with styles, colors, zoom
+// modes and named destinations.
+func ExampleFpdf_BookmarkEx() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFont("Helvetica", "", 14)
+	for j := 1; j <= 3; j++ {
+		pdf.AddPage()
+		title := fmt.Sprintf("Part %d", j)
+		pdf.BookmarkEx(title, 0, -1, gofpdf.BookmarkOptions{Bold: true,
+			Color: &gofpdf.RGBType{R: 0, G: 0, B: 160}, Zoom: "FitH", Open: j == 1})
+		pdf.Cell(0, 10, title)
+		pdf.Ln(20)
+		for k := 1; k <= 2; k++ {
+			title = fmt.Sprintf("Section %d.%d", j, k)
+			pdf.BookmarkEx(title, 1, -1, gofpdf.BookmarkOptions{Italic: true, ZoomFactor: 2})
+			pdf.Cell(0, 10, title)
+			pdf.Ln(20)
+		}
+	}
+	pdf.AddNamedDest("appendix", -1, -1)
+	pdf.Cell(0, 10, "Appendix")
+	pdf.BookmarkEx("Appendix", 0, -1, gofpdf.BookmarkOptions{Dest: "appendix"})
+	fileStr := example.Filename("Fpdf_BookmarkEx")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_BookmarkEx.pdf
+}
+
+// TestBookmarkEx checks the entries of bookmarks set with BookmarkEx()
+func TestBookmarkEx(t *testing.T) {
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.SetCompression(false)
+	pdf.AddPage()
+	_, ht := pdf.GetPageSize()
+	pdf.BookmarkEx("Open", 0, 100, gofpdf.BookmarkOptions{Bold: true, Italic: true,
+		Color: &gofpdf.RGBType{R: 255, G: 0, B: 0}, Zoom: "FitH", Open: true})
+	pdf.BookmarkEx("Closed", 1, 200, gofpdf.BookmarkOptions{ZoomFactor: 1.5})
+	pdf.BookmarkEx("Leaf", 2, 300, gofpdf.BookmarkOptions{Zoom: "Fit"})
+	pdf.BookmarkEx("Named", 1, 0, gofpdf.BookmarkOptions{Dest: "there"})
+	pdf.AddNamedDest("there", 1, 400)
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	str := buf.String()
+	for _, want := range []string{
+		fmt.Sprintf("/Dest [3 0 R /FitH %.2f]\n/F 3\n/C [1.000 0.000 0.000]\n/Count 2>>", ht-100),
+		fmt.Sprintf("/Dest [3 0 R /XYZ 0 %.2f 1.50]\n/Count -1>>", ht-200),
+		"/Dest [3 0 R /Fit]\n/Count 0>>",
+		"/Dest (there)\n/Count 0>>",
+	} {
+		if !strings.Contains(str, want) {
+			t.Fatalf("%q not found", want)
+		}
+	}
+	pdf.BookmarkEx("Zoom", 0, 0, gofpdf.BookmarkOptions{Zoom: "FitV"})
+	if pdf.Error() == nil {
+		t.Fatal("expecting error for invalid zoom mode")
+	}
+}
+
+// ExampleFpdf_AddChildLayer demonstrates nested layers and a radio button
+// group of layers for the languages of a document.
+func ExampleFpdf_AddChildLayer() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFont("Helvetica", "", 14)
+	plan := pdf.AddLayer("Floor plan", true)
+	walls := pdf.AddChildLayer(plan, "Walls", true)
+	furniture := pdf.AddChildLayer(plan, "Furniture", false)
+	english := pdf.AddLayer("English", true)
+	german := pdf.AddLayer("Deutsch", false)
+	pdf.AddLayerRadioGroup(english, german)
+	pdf.OpenLayerPane()
+	pdf.AddPage()
+	pdf.BeginLayer(plan)
+	pdf.BeginLayer(walls)
+	pdf.SetLineWidth(2)
+	pdf.Rect(20, 40, 170, 120, "D")
+	pdf.Line(100, 40, 100, 160)
+	pdf.EndLayer()
+	pdf.BeginLayer(furniture)
+	pdf.SetFillColor(200, 160, 120)
+	pdf.Rect(40, 60, 40, 20, "F")
+	pdf.Rect(130, 100, 30, 40, "F")
+	pdf.EndLayer()
+	pdf.EndLayer()
+	pdf.SetY(170)
+	pdf.BeginLayer(english)
+	pdf.Cell(0, 10, "Ground floor")
+	pdf.EndLayer()
+	pdf.BeginLayer(german)
+	pdf.Cell(0, 10, "Erdgeschoss")
+	pdf.EndLayer()
+	fileStr := example.Filename("Fpdf_AddChildLayer")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_AddChildLayer.pdf
+}
+
+// TestAddChildLayer checks nested marked content, the order of nested layers
+// and radio button groups
+func TestAddChildLayer(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false)
+	a := pdf.AddLayer("A", true)
+	b := pdf.AddLayer("B", false)
+	a1 := pdf.AddChildLayer(a, "A1", true)
+	pdf.AddLayerRadioGroup(a, b)
+	pdf.AddPage()
+	pdf.BeginLayer(a)
+	pdf.BeginLayer(a1)
+	pdf.Line(0, 0, 10, 10)
+	pdf.EndLayer()
+	pdf.Line(0, 10, 10, 0)
+	// B is not a child of A, so A is ended
+	pdf.BeginLayer(b)
+	pdf.Line(0, 0, 10, 0)
+	pdf.EndLayer()
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	str := buf.String()
+	if !regexp.MustCompile(`/OC /OC0 BDC\n/OC /OC2 BDC\n.* l S\nEMC\n.* l S\nEMC\n/OC /OC1 BDC\n.* l S\nEMC\n`).MatchString(str) {
+		t.Fatal("marked content not found")
+	}
+	m := regexp.MustCompile(`/Order \[(\d+) 0 R \[(\d+) 0 R \] (\d+) 0 R \] /RBGroups \[\[(\d+) 0 R (\d+) 0 R\]\]`).FindStringSubmatch(str)
+	if m == nil || m[1] != m[4] || m[3] != m[5] {
+		t.Fatalf("layer order and radio button groups not found")
+	}
+	pdf.AddChildLayer(5, "C", true)
+	if pdf.Error() == nil {
+		t.Fatal("expecting error for invalid parent layer")
+	}
+}
+
+// ExampleFpdf_SetPageLabel demonstrates front matter numbered with roman
+// numerals and an appendix numbered with a prefix.
+func ExampleFpdf_SetPageLabel() {
+	pdf := gofpdf.New("P", "mm", "A5", "")
+	pdf.SetFont("Helvetica", "", 14)
+	pdf.SetPageLabel(1, gofpdf.PageLabelRomanLower, "", 1)
+	pdf.SetPageLabel(4, gofpdf.PageLabelDecimal, "", 1)
+	pdf.SetPageLabel(9, gofpdf.PageLabelDecimal, "A-", 1)
+	for j := 1; j <= 10; j++ {
+		pdf.AddPage()
+		switch {
+		case j < 4:
+			pdf.Cell(0, 10, "Front matter")
+		case j < 9:
+			pdf.Cell(0, 10, "Body")
+		default:
+			pdf.Cell(0, 10, "Appendix")
+		}
+	}
+	fileStr := example.Filename("Fpdf_SetPageLabel")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_SetPageLabel.pdf
+}
+
+// TestSetPageLabel checks the /PageLabels number tree
+func TestSetPageLabel(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A5", "")
+	pdf.SetCompression(false)
+	pdf.SetPageLabel(6, gofpdf.PageLabelLettersUpper, "", 1)
+	pdf.SetPageLabel(3, gofpdf.PageLabelRomanUpper, "", 5)
+	pdf.SetPageLabel(5, gofpdf.PageLabelNone, "Cover", 1)
+	for j := 0; j < 5; j++ {
+		pdf.AddPage()
+	}
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	// Labels for pages that were not added are omitted
+	want := "/PageLabels <</Nums [0 <</S /D>> 2 <</S /R /St 5>> 4 <</P (\xfe\xff\x00C\x00o\x00v\x00e\x00r)>> ]>>"
+	if !strings.Contains(buf.String(), want) {
+		t.Fatalf("%q not found", want)
+	}
+	pdf.SetPageLabel(1, "X", "", 1)
+	if pdf.Error() == nil {
+		t.Fatal("expecting error for invalid style")
+	}
+}
+
+// ExampleFpdf_BeginArticle demonstrates article threads that follow the
+// columns of a newsletter.
+func ExampleFpdf_BeginArticle() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFont("Times", "", 12)
+	left, top, right, bottom := pdf.GetMargins()
+	wd, ht := pdf.GetPageSize()
+	colW := (wd - left - right - 10) / 2
+	colH := ht - top - bottom
+	txtStr := strings.Repeat("An article thread leads the reader down one column and on to the next. ", 40)
+	// The first story fills the left columns of two pages, the second one
+	// the right columns
+	for page := 0; page < 2; page++ {
+		pdf.AddPage()
+		for col, name := range []string{"First story", "Second story"} {
+			x := left + float64(col)*(colW+10)
+			pdf.BeginArticle(name)
+			pdf.ArticleBead(x, top, colW, colH)
+			pdf.SetXY(x, top)
+			pdf.MultiCellBounded(colW, 5, colH, txtStr, "", "J", false)
+		}
+	}
+	fileStr := example.Filename("Fpdf_BeginArticle")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_BeginArticle.pdf
+}
+
+// TestBeginArticle checks the threads and beads of articles
+func TestBeginArticle(t *testing.T) {
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.SetCompression(false)
+	pdf.ArticleBead(0, 0, 10, 10)
+	if pdf.Error() == nil {
+		t.Fatal("expecting error for bead without article")
+	}
+	pdf.ClearError()
+	_, ht := pdf.GetPageSize()
+	pdf.AddPage()
+	pdf.BeginArticle("A")
+	pdf.ArticleBead(10, 20, 100, 200)
+	pdf.BeginArticle("B")
+	pdf.ArticleBead(200, 20, 100, 200)
+	pdf.AddPage()
+	pdf.BeginArticle("A")
+	pdf.ArticleBead(10, 20, 100, 200)
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	str := buf.String()
+	m := regexp.MustCompile(`(\d+) 0 obj\n<</Type /Thread /F (\d+) 0 R /I <</Title \(.+?\)>>>>\nendobj\n` +
+		`(\d+) 0 obj\n<</Type /Bead /T (\d+) 0 R /N (\d+) 0 R /V (\d+) 0 R /P 3 0 R /R \[10.00 ([\d.]+) 110.00 ([\d.]+)\]>>\nendobj\n` +
+		`(\d+) 0 obj\n<</Type /Bead /N (\d+) 0 R /V (\d+) 0 R /P 5 0 R `).FindStringSubmatch(str)
+	if m == nil {
+		t.Fatal("thread A not found")
+	}
+	// The beads form a ring
+	if m[2] != m[3] || m[1] != m[4] || m[5] != m[9] || m[6] != m[9] || m[10] != m[3] || m[11] != m[3] {
+		t.Fatalf("beads not linked: %v", m[1:])
+	}
+	if m[7] != fmt.Sprintf("%.2f", ht-220) || m[8] != fmt.Sprintf("%.2f", ht-20) {
+		t.Fatalf("bead at %s to %s", m[7], m[8])
+	}
+	if !regexp.MustCompile(`/Threads \[` + m[1] + ` 0 R \d+ 0 R \]`).MatchString(str) {
+		t.Fatal("threads not found in catalog")
+	}
+}
+
+// ExampleFpdf_StartStructElem demonstrates a tagged document with headings,
+// paragraphs, a list and a figure with an alternate description.
+func ExampleFpdf_StartStructElem() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetTagged(true)
+	pdf.SetLang("en-US")
+	pdf.SetTitle("Tagged document", true)
+	pdf.SetFooterFunc(func() {
+		// Page footers are marked as artifacts
+		pdf.SetY(-15)
+		pdf.SetFont("Helvetica", "I", 8)
+		pdf.CellFormat(0, 10, fmt.Sprintf("Page %d", pdf.PageNo()), "T", 0, "C", false, 0, "")
+	})
+	pdf.AddPage()
+	pdf.StartStructElem(gofpdf.RoleHeading1)
+	pdf.SetFont("Helvetica", "B", 18)
+	pdf.CellFormat(0, 12, "Accessible documents", "", 1, "L", false, 0, "")
+	pdf.EndStructElem()
+	pdf.SetFont("Helvetica", "", 11)
+	pdf.StartStructElem(gofpdf.RoleParagraph)
+	pdf.MultiCell(0, 5, "Screen readers follow the structure elements of a tagged "+
+		"document rather than the order in which its content was printed.", "", "L", false)
+	pdf.EndStructElem()
+	pdf.Ln(4)
+	pdf.StartStructElem(gofpdf.RoleList)
+	for j, item := range []string{"Headings", "Paragraphs", "Figures"} {
+		pdf.StartStructElem(gofpdf.RoleListItem)
+		pdf.StartStructElem(gofpdf.RoleLabel)
+		pdf.CellFormat(8, 6, fmt.Sprintf("%d.", j+1), "", 0, "R", false, 0, "")
+		pdf.EndStructElem()
+		pdf.StartStructElem(gofpdf.RoleListBody)
+		pdf.CellFormat(0, 6, item, "", 1, "L", false, 0, "")
+		pdf.EndStructElem()
+		pdf.EndStructElem()
+	}
+	pdf.EndStructElem()
+	pdf.Ln(4)
+	pdf.StartStructElemEx(gofpdf.RoleFigure, gofpdf.StructElemOptions{AltText: "The logo of the Go gopher"})
+	pdf.ImageOptions(example.ImageFile("logo.png"), -1, -1, 30, 0, true, gofpdf.ImageOptions{}, 0, "")
+	pdf.EndStructElem()
+	fileStr := example.Filename("Fpdf_StartStructElem")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_StartStructElem.pdf
+}
+
+// TestStartStructElem checks the marked content and the structure tree of a
+// tagged document
+func TestStartStructElem(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false)
+	pdf.StartStructElem(gofpdf.RoleParagraph)
+	if pdf.Error() == nil {
+		t.Fatal("expecting error for untagged document")
+	}
+	pdf.ClearError()
+	pdf.SetTagged(true)
+	pdf.SetLang("de")
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.SetHeaderFunc(func() {
+		pdf.Cell(0, 10, "Header")
+		pdf.Ln(10)
+	})
+	pdf.AddPage()
+	pdf.StartStructElem(gofpdf.RoleHeading1)
+	pdf.CellFormat(0, 10, "Title", "1", 1, "L", false, 0, "")
+	pdf.EndStructElem()
+	pdf.StartStructElemEx(gofpdf.RoleParagraph, gofpdf.StructElemOptions{ActualText: "Text"})
+	pdf.SetKeepTogether(true)
+	pdf.SetY(250)
+	pdf.MultiCell(0, 10, strings.Repeat("Text ", 150), "", "L", false)
+	pdf.EndStructElem()
+	pdf.EndStructElem()
+	if pdf.Error() == nil {
+		t.Fatal("expecting error for unbalanced EndStructElem()")
+	}
+	pdf.ClearError()
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	str := buf.String()
+	for _, re := range []string{
+		`/Artifact BMC BT [\d.]+ [\d.]+ Td \(Header\)Tj ET EMC`,
+		`/Artifact BMC [-\d. ]+re S EMC /H1 <</MCID 0>> BDC BT [\d.]+ [\d.]+ Td \(Title\)Tj ET EMC`,
+		// The start of the paragraph is moved to the second page
+		`cm\n/P <</MCID 0>> BDC BT [\d.]+ [\d.]+ Td \(Text`,
+		`/StructParents 1\n/Tabs /S`,
+		`/Type /StructTreeRoot /K \[(\d+) 0 R\] /ParentTree <</Nums \[0 \[\d+ 0 R null null \] 1 \[(\d+ 0 R ){8}\] \]>> /ParentTreeNextKey 2>>`,
+		`<</Type /StructElem /S /P /P \d+ 0 R /K \[<</Type /MCR /Pg 5 0 R /MCID 0>> <</Type /MCR /Pg 5 0 R /MCID 1>> .*\] /ActualText \(`,
+		`/Lang \(de\)\n/MarkInfo <</Marked true>>\n/StructTreeRoot \d+ 0 R`,
+	} {
+		if !regexp.MustCompile(re).MatchString(str) {
+			t.Errorf("%s not found", re)
+		}
+	}
+	if strings.Contains(str, "/MCR /Pg 3 0 R /MCID 1") {
+		t.Error("moved paragraph is still referenced on the first page")
+	}
+}
+
+// ExampleFpdf_BeginArtifact demonstrates the alternate description of an
+// image and decorative content that is marked as an artifact.
+func ExampleFpdf_BeginArtifact() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFont("Helvetica", "", 14)
+	pdf.AddPage()
+	// The decorative frame is skipped by screen readers
+	pdf.BeginArtifact()
+	pdf.SetDrawColor(160, 160, 160)
+	pdf.Rect(5, 5, 200, 287, "D")
+	pdf.EndArtifact()
+	pdf.Cell(0, 10, "The gopher")
+	pdf.Ln(12)
+	pdf.ImageOptions(example.ImageFile("logo.png"), -1, -1, 40, 0, true,
+		gofpdf.ImageOptions{AltText: "The logo of the Go gopher"}, 0, "")
+	fileStr := example.Filename("Fpdf_BeginArtifact")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_BeginArtifact.pdf
+}
+
+// TestBeginArtifact checks artifacts and the alternate description of images
+// in documents with and without tagging
+func TestBeginArtifact(t *testing.T) {
+	for _, tagged := range []bool{false, true} {
+		pdf := gofpdf.New("P", "mm", "A4", "")
+		pdf.SetCompression(false)
+		pdf.SetTagged(tagged)
+		pdf.SetFont("Helvetica", "", 12)
+		pdf.AddPage()
+		pdf.BeginArtifact()
+		pdf.Line(10, 10, 100, 10)
+		pdf.Cell(0, 10, "Decoration")
+		pdf.EndArtifact()
+		pdf.ImageOptions(example.ImageFile("logo.png"), 10, 20, 30, 0, false,
+			gofpdf.ImageOptions{AltText: "Logo"}, 0, "")
+		// Artifacts that are open at the end of a page are ended there
+		pdf.BeginArtifact()
+		pdf.AddPage()
+		pdf.EndArtifact()
+		var buf bytes.Buffer
+		if err := pdf.Output(&buf); err != nil {
+			t.Fatal(err)
+		}
+		str := buf.String()
+		res := []string{`/Artifact BMC\n[\d. ]+ m [\d. ]+ l S\nBT [\d.]+ [\d.]+ Td \(Decoration\)Tj ET\nEMC\n`,
+			`/Artifact BMC\nEMC\n\nendstream`}
+		if tagged {
+			res = append(res, `/Figure <</MCID 0>> BDC q [\d. ]+ cm /I\w+ Do Q EMC`,
+				`<</Type /StructElem /S /Figure /P \d+ 0 R /K \[<</Type /MCR /Pg 3 0 R /MCID 0>> \] /Alt \(.+?\)>>`)
+		} else {
+			res = append(res, `/Figure <</Alt \(.+?\)>> BDC q [\d. ]+ cm /I\w+ Do Q EMC`)
+		}
+		for _, re := range res {
+			if !regexp.MustCompile(re).MatchString(str) {
+				t.Errorf("tagged %v: %s not found", tagged, re)
+			}
+		}
+		if !strings.Contains(str, "(\xfe\xff\x00L\x00o\x00g\x00o)") {
+			t.Errorf("tagged %v: alternate description not found", tagged)
+		}
+	}
+}
+
+// ExampleFpdf_SetAutoXmpMetadata demonstrates XMP metadata generated from
+// the document information.
+func ExampleFpdf_SetAutoXmpMetadata() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetTitle("Quarterly report", true)
+	pdf.SetAuthor("Finance department", true)
+	pdf.SetSubject("Results of the third quarter", true)
+	pdf.SetKeywords("report finance quarterly", true)
+	pdf.SetAutoXmpMetadata(true)
+	pdf.SetFont("Helvetica", "", 14)
+	pdf.AddPage()
+	pdf.Cell(0, 10, "The metadata of this document is also available as XMP.")
+	fileStr := example.Filename("Fpdf_SetAutoXmpMetadata")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_SetAutoXmpMetadata.pdf
+}
+
+// TestSetAutoXmpMetadata checks the generated XMP metadata and its reference
+// in the catalog
+func TestSetAutoXmpMetadata(t *testing.T) {
+	output := func(custom []byte) string {
+		pdf := gofpdf.New("P", "mm", "A4", "")
+		pdf.SetCompression(false)
+		pdf.SetTitle("Fish & Chips", true)
+		pdf.SetAuthor("Author", false)
+		pdf.SetKeywords("food", false)
+		pdf.SetCreationDate(time.Date(2020, 5, 4, 3, 2, 1, 0, time.UTC))
+		pdf.SetModificationDate(time.Date(2021, 6, 5, 4, 3, 2, 0, time.UTC))
+		pdf.SetAutoXmpMetadata(true)
+		if custom != nil {
+			pdf.SetXmpMetadata(custom)
+		}
+		pdf.AddPage()
+		var buf bytes.Buffer
+		if err := pdf.Output(&buf); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+	str := output(nil)
+	m := regexp.MustCompile(`(\d+) 0 obj\n<< /Type /Metadata /Subtype /XML /Length \d+ >>\nstream\n([^\x00]*?)\nendstream`).FindStringSubmatch(str)
+	if m == nil {
+		t.Fatal("metadata stream not found")
+	}
+	for _, s := range []string{
+		`<rdf:li xml:lang="x-default">Fish &amp; Chips</rdf:li>`,
+		`<dc:creator><rdf:Seq><rdf:li>Author</rdf:li></rdf:Seq></dc:creator>`,
+		`<pdf:Keywords>food</pdf:Keywords>`,
+		`<xmp:CreateDate>2020-05-04T03:02:01</xmp:CreateDate>`,
+		`<xmp:ModifyDate>2021-06-05T04:03:02</xmp:ModifyDate>`,
+	} {
+		if !strings.Contains(m[2], s) {
+			t.Errorf("%s not found", s)
+		}
+	}
+	if strings.Contains(m[2], "pdfaid") {
+		t.Error("unexpected PDF/A identification")
+	}
+	if !strings.Contains(str, "/Metadata "+m[1]+" 0 R") {
+		t.Error("metadata not referenced by the catalog")
+	}
+	if str = output([]byte("<custom/>")); !strings.Contains(str, "stream\n<custom/>\nendstream") {
+		t.Error("custom metadata not found")
+	}
+}
+
+// ExampleFpdf_SetCustomInfo demonstrates custom entries of the document
+// information.
+func ExampleFpdf_SetCustomInfo() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetTitle("Claim", true)
+	pdf.SetCustomInfo("CaseNumber", "2024-0815")
+	pdf.SetCustomInfo("Workflow", "Review – stage 2")
+	pdf.SetFont("Helvetica", "", 14)
+	pdf.AddPage()
+	pdf.Cell(0, 10, "Case number "+pdf.GetCustomInfo("CaseNumber"))
+	fileStr := example.Filename("Fpdf_SetCustomInfo")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_SetCustomInfo.pdf
+}
+
+// TestSetCustomInfo checks the custom entries of the document information
+func TestSetCustomInfo(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCustomInfo("Title", "Title")
+	if pdf.Error() == nil {
+		t.Fatal("expecting error for standard entry")
+	}
+	pdf.ClearError()
+	pdf.SetCustomInfo("Workflow ID", "42")
+	pdf.SetCustomInfo("Case", "7")
+	pdf.SetCustomInfo("Removed", "x")
+	pdf.SetCustomInfo("Removed", "")
+	if pdf.GetCustomInfo("Case") != "7" || pdf.GetCustomInfo("Removed") != "" {
+		t.Fatal("unexpected custom information")
+	}
+	pdf.AddPage()
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	str := buf.String()
+	if !regexp.MustCompile(`/ModDate \(D:\d+\)\n/Case \(.+?\)\n/Workflow#20ID \(.+?\)\n>>`).MatchString(str) {
+		t.Error("custom entries not found")
+	}
+	if strings.Contains(str, "/Removed") {
+		t.Error("removed entry found")
+	}
+}
+
+// ExampleFpdf_SetViewerPreferences demonstrates a document that opens in a
+// reduced window and prints two copies of its first page on both sides of
+// the paper.
+func ExampleFpdf_SetViewerPreferences() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetTitle("Kiosk information", true)
+	pdf.SetViewerPreferences(gofpdf.ViewerPreferences{
+		HideToolbar:     true,
+		HideMenubar:     true,
+		FitWindow:       true,
+		DisplayDocTitle: true,
+		Duplex:          "DuplexFlipLongEdge",
+		PrintScaling:    "None",
+		NumCopies:       2,
+		PrintPageRange:  []int{1, 1},
+	})
+	pdf.SetFont("Helvetica", "", 14)
+	for j := 1; j <= 2; j++ {
+		pdf.AddPage()
+		pdf.Cell(0, 10, fmt.Sprintf("Page %d", j))
+	}
+	fileStr := example.Filename("Fpdf_SetViewerPreferences")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_SetViewerPreferences.pdf
+}
+
+// TestSetViewerPreferences checks the viewer preferences of the catalog
+func TestSetViewerPreferences(t *testing.T) {
+	output := func(tagged bool, prefs *gofpdf.ViewerPreferences) string {
+		pdf := gofpdf.New("P", "mm", "A4", "")
+		pdf.SetTagged(tagged)
+		if prefs != nil {
+			pdf.SetViewerPreferences(*prefs)
+		}
+		pdf.AddPage()
+		var buf bytes.Buffer
+		if err := pdf.Output(&buf); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+	str := output(false, &gofpdf.ViewerPreferences{HideToolbar: true, CenterWindow: true,
+		Duplex: "Simplex", NumCopies: 3, PrintPageRange: []int{2, 4}})
+	if !strings.Contains(str, "/ViewerPreferences <</HideToolbar true /CenterWindow true /Duplex /Simplex /NumCopies 3 /PrintPageRange [1 3 ] >>") {
+		t.Error("viewer preferences not found")
+	}
+	if !strings.HasPrefix(str, "%PDF-1.7") {
+		t.Error("PDF version not raised")
+	}
+	if str = output(true, &gofpdf.ViewerPreferences{FitWindow: true}); !strings.Contains(str, "/ViewerPreferences <</FitWindow true /DisplayDocTitle true >>") {
+		t.Error("title not displayed in tagged document")
+	}
+	if str = output(false, nil); strings.Contains(str, "/ViewerPreferences") {
+		t.Error("unexpected viewer preferences")
+	}
+	for _, prefs := range []gofpdf.ViewerPreferences{
+		{Duplex: "Both"},
+		{PrintScaling: "Fit"},
+		{NumCopies: -1},
+		{PrintPageRange: []int{1}},
+		{PrintPageRange: []int{3, 2}},
+	} {
+		pdf := gofpdf.New("P", "mm", "A4", "")
+		pdf.SetViewerPreferences(prefs)
+		if pdf.Error() == nil {
+			t.Errorf("expecting error for %+v", prefs)
+		}
+	}
+}
+
+// ExampleFpdf_SetOpenAction demonstrates a document that opens at its second
+// page and runs JavaScript when its first page is displayed.
+func ExampleFpdf_SetOpenAction() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFont("Helvetica", "", 14)
+	pdf.AddPage()
+	pdf.Cell(0, 10, "First page")
+	pdf.SetPageAction(gofpdf.PageEventOpen, gofpdf.ActionJavaScript{Script: "app.alert('First page');"})
+	pdf.AddPage()
+	pdf.Cell(0, 10, "The document opens at this page")
+	pdf.SetOpenAction(gofpdf.ActionGoTo{Page: 2, Y: 0})
+	fileStr := example.Filename("Fpdf_SetOpenAction")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_SetOpenAction.pdf
+}
+
+// TestSetOpenAction checks the open action of the catalog and the additional
+// actions of pages
+func TestSetOpenAction(t *testing.T) {
+	output := func(action gofpdf.Action) (string, error) {
+		pdf := gofpdf.New("P", "pt", "A4", "")
+		pdf.SetDisplayMode("fullpage", "")
+		pdf.SetOpenAction(action)
+		pdf.AddPage()
+		pdf.SetPageAction(gofpdf.PageEventClose, gofpdf.ActionNamed{Name: "FirstPage"})
+		pdf.SetPageAction(gofpdf.PageEventOpen, gofpdf.ActionURI{URI: "https://example.com"})
+		pdf.AddPage()
+		var buf bytes.Buffer
+		err := pdf.Output(&buf)
+		return buf.String(), err
+	}
+	str, err := output(gofpdf.ActionGoTo{Page: 2, Y: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !regexp.MustCompile(`/OpenAction <</S /GoTo /D \[5 0 R /XYZ 0 741.89 null\]>>`).MatchString(str) {
+		t.Error("open action not found")
+	}
+	if !strings.Contains(str, "/AA <</O <</S /URI /URI (https://example.com)>> /C <</S /Named /N /FirstPage>> >>") {
+		t.Error("page actions not found")
+	}
+	if str, _ = output(nil); !strings.Contains(str, "/OpenAction [3 0 R /Fit]") {
+		t.Error("zoom mode not found")
+	}
+	if _, err = output(gofpdf.ActionGoTo{Page: 3}); err == nil {
+		t.Error("expecting error for missing page")
+	}
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetPageAction(gofpdf.PageEventOpen, gofpdf.ActionJavaScript{Script: "1;"})
+	if pdf.Error() == nil {
+		t.Error("expecting error for page action without page")
+	}
+}
+
+// ExampleFpdf_SetOutline demonstrates an outline that is built as a tree
+// after the pages have been generated.
+func ExampleFpdf_SetOutline() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFont("Helvetica", "", 14)
+	var chapters []gofpdf.OutlineNode
+	for j := 1; j <= 3; j++ {
+		pdf.AddPage()
+		pdf.Cell(0, 10, fmt.Sprintf("Chapter %d", j))
+		chapter := gofpdf.OutlineNode{Title: fmt.Sprintf("Chapter %d", j), Page: pdf.PageNo()}
+		for k := 1; k <= 2; k++ {
+			y := 20 + float64(k)*60
+			pdf.SetXY(10, y)
+			pdf.Cell(0, 10, fmt.Sprintf("Section %d.%d", j, k))
+			chapter.Children = append(chapter.Children,
+				gofpdf.OutlineNode{Title: fmt.Sprintf("Section %d.%d", j, k), Page: pdf.PageNo(), Y: y})
+		}
+		chapters = append(chapters, chapter)
+	}
+	// The chapters are listed below a part that is shown in bold
+	pdf.SetOutline([]gofpdf.OutlineNode{{
+		Title:    "Part I",
+		Page:     1,
+		Options:  &gofpdf.BookmarkOptions{Bold: true, Open: true},
+		Children: chapters,
+	}})
+	fileStr := example.Filename("Fpdf_SetOutline")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_SetOutline.pdf
+}
+
+// TestSetOutline checks the outline tree returned by Outline() and set with
+// SetOutline()
+func TestSetOutline(t *testing.T) {
+	output := func(fnc func(pdf *gofpdf.Fpdf)) string {
+		pdf := gofpdf.New("P", "mm", "A4", "")
+		pdf.SetCompression(false)
+		pdf.SetFont("Helvetica", "", 12)
+		pdf.AddPage()
+		pdf.Bookmark("A", 0, 0)
+		pdf.Bookmark("A1", 1, 10)
+		pdf.Bookmark("A1a", 2, 20)
+		pdf.AddPage()
+		pdf.BookmarkEx("B", 0, 0, gofpdf.BookmarkOptions{Italic: true})
+		fnc(pdf)
+		var buf bytes.Buffer
+		if err := pdf.Output(&buf); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+	var nodes []gofpdf.OutlineNode
+	plain := output(func(pdf *gofpdf.Fpdf) {
+		nodes = pdf.Outline()
+	})
+	if len(nodes) != 2 || len(nodes[0].Children) != 1 || len(nodes[0].Children[0].Children) != 1 ||
+		nodes[0].Children[0].Children[0].Title != "A1a" || nodes[0].Children[0].Children[0].Y != 20 ||
+		nodes[1].Title != "B" || nodes[1].Page != 2 || nodes[1].Options == nil || !nodes[1].Options.Italic {
+		t.Fatalf("unexpected outline %+v", nodes)
+	}
+	// The outline is written in the same way after a round trip
+	if str := output(func(pdf *gofpdf.Fpdf) { pdf.SetOutline(pdf.Outline()) }); str != plain {
+		t.Error("outline changed by round trip")
+	}
+	// Outlines are merged
+	str := output(func(pdf *gofpdf.Fpdf) {
+		pdf.SetOutline(append([]gofpdf.OutlineNode{{Title: "Cover", Page: 1}}, pdf.Outline()...))
+	})
+	if !regexp.MustCompile(`<</Title \(.+?\)\n/Parent \d+ 0 R\n/Next \d+ 0 R\n/Dest \[3 0 R /XYZ 0 841.89 null\]\n/Count 0>>`).MatchString(str) {
+		t.Error("merged bookmark not found")
+	}
+	if !strings.Contains(str, "/Type /Outlines") {
+		t.Error("outline not found")
+	}
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetOutline([]gofpdf.OutlineNode{{Title: "X", Page: 1, Options: &gofpdf.BookmarkOptions{Zoom: "Z"}}})
+	if pdf.Error() == nil {
+		t.Error("expecting error for invalid zoom mode")
+	}
+}
+
+// ExampleFpdf_FillTemplate demonstrates a report whose heading, rows and
+// total are printed from region templates.
+func ExampleFpdf_FillTemplate() {
+	type item struct {
+		Name        string
+		Description string
+		Amount      float64
+	}
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFont("Helvetica", "", 10)
+	heading, err := gofpdf.NewRegionTemplate(12,
+		gofpdf.RegionElement{W: 40, H: 8, Text: "Item", Border: "B", FontStyle: "B"},
+		gofpdf.RegionElement{X: 40, W: 110, H: 8, Text: "Description", Border: "B", FontStyle: "B"},
+		gofpdf.RegionElement{X: 150, W: 40, H: 8, Text: "Amount", Border: "B", Align: "R", FontStyle: "B"})
+	if err != nil {
+		panic(err)
+	}
+	row, err := gofpdf.NewRegionTemplate(7,
+		gofpdf.RegionElement{W: 40, H: 6, Text: "{{.Name}}"},
+		gofpdf.RegionElement{X: 40, W: 110, H: 6, Text: "{{.Description}}", MultiLine: true},
+		gofpdf.RegionElement{X: 150, W: 40, H: 6, Text: `{{printf "%.2f" .Amount}}`, Align: "R"})
+	if err != nil {
+		panic(err)
+	}
+	total, err := gofpdf.NewRegionTemplate(10,
+		gofpdf.RegionElement{Y: 2, W: 150, H: 8, Text: "Total", Border: "T", FontStyle: "B"},
+		gofpdf.RegionElement{X: 150, Y: 2, W: 40, H: 8, Text: `{{printf "%.2f" .}}`, Border: "T", Align: "R", FontStyle: "B"})
+	if err != nil {
+		panic(err)
+	}
+	pdf.SetHeaderFunc(func() {
+		pdf.FillTemplate(heading, nil)
+	})
+	pdf.AddPage()
+	var sum float64
+	for j := 1; j <= 60; j++ {
+		it := item{Name: fmt.Sprintf("Item %d", j), Amount: float64(j) * 12.5,
+			Description: strings.Repeat("A description that may wrap to several lines. ", j%4+1)}
+		pdf.FillTemplate(row, it)
+		sum += it.Amount
+	}
+	pdf.FillTemplate(total, sum)
+	fileStr := example.Filename("Fpdf_FillTemplate")
+	err = pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_FillTemplate.pdf
+}
+
+// TestFillTemplate checks the placement of region templates and their page
+// breaks
+func TestFillTemplate(t *testing.T) {
+	if _, err := gofpdf.NewRegionTemplate(10, gofpdf.RegionElement{Text: "{{.Name"}); err == nil {
+		t.Fatal("expecting error for invalid template")
+	}
+	tpl, err := gofpdf.NewRegionTemplate(20,
+		gofpdf.RegionElement{W: 50, H: 10, Text: "{{.Name}}"},
+		gofpdf.RegionElement{X: 60, W: 50, H: 10, Text: "{{.Text}}", MultiLine: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.AddPage()
+	pdf.SetX(20)
+	top := pdf.GetY()
+	pdf.FillTemplate(tpl, map[string]string{"Name": "A", "Text": "Short"})
+	if x, y := pdf.GetXY(); x != 20 || math.Abs(y-top-20) > 1e-9 {
+		t.Fatalf("position after short region %.2f, %.2f", x, y)
+	}
+	// The region grows with the lines of the multi-line cell
+	pdf.FillTemplate(tpl, map[string]string{"Name": "B", "Text": strings.Repeat("Long text ", 12)})
+	if y := pdf.GetY(); y <= top+40 {
+		t.Fatalf("position after long region %.2f", y)
+	}
+	pdf.SetXY(20, 270)
+	pdf.FillTemplate(tpl, map[string]string{"Name": "C", "Text": "Moved"})
+	if pdf.PageNo() != 2 || math.Abs(pdf.GetY()-top-20) > 1e-9 || pdf.GetX() != 20 {
+		t.Fatalf("region not moved to the next page: page %d at %.2f, %.2f", pdf.PageNo(), pdf.GetX(), pdf.GetY())
+	}
+	pdf.FillTemplate(tpl, map[string]string{"Name": "D"})
+	if pdf.Error() == nil {
+		t.Fatal("expecting error for missing key")
+	}
+}
+
+// ExampleFpdf_ImportPage demonstrates a letterhead, taken from an existing
+// PDF document, on which a letter is printed, and a thumbnail of the page.
+func ExampleFpdf_ImportPage() {
+	// The letterhead is usually read from a file
+	var letterhead bytes.Buffer
+	src := gofpdf.New("P", "mm", "A4", "")
+	src.AddPage()
+	src.SetFont("Helvetica", "B", 20)
+	src.SetTextColor(0, 64, 128)
+	src.Cell(0, 12, "Example Corporation")
+	src.Image(example.ImageFile("logo.png"), 170, 10, 25, 0, false, "", 0, "")
+	src.SetDrawColor(0, 64, 128)
+	src.Line(10, 28, 200, 28)
+	src.SetFont("Helvetica", "", 8)
+	src.SetXY(10, 280)
+	src.CellFormat(0, 5, "Example Corporation - 1 Example Street - Example City", "T", 0, "C", false, 0, "")
+	err := src.Output(&letterhead)
+	if err == nil {
+		pdf := gofpdf.New("P", "mm", "A4", "")
+		id := pdf.ImportPage(bytes.NewReader(letterhead.Bytes()), 1, "MediaBox")
+		pdf.SetHeaderFunc(func() {
+			pdf.UseImportedPage(id, 0, 0, 0, 0)
+			pdf.SetY(40)
+		})
+		pdf.AddPage()
+		pdf.SetFont("Times", "", 12)
+		pdf.MultiCell(0, 6, "Dear reader,\n\nthis letter is printed on a letterhead that has "+
+			"been imported from another PDF document. A thumbnail of the letterhead follows.", "", "", false)
+		w, h := pdf.GetImportedPageSize(id)
+		pdf.Rect(20, 100, w/4, h/4, "D")
+		pdf.UseImportedPage(id, 20, 100, w/4, 0)
+		fileStr := example.Filename("Fpdf_ImportPage")
+		err = pdf.OutputFileAndClose(fileStr)
+		example.Summary(err, fileStr)
+	}
+	// Output:
+	// Successfully generated pdf/Fpdf_ImportPage.pdf
+}
+
+// importTestPDF returns a PDF document with the given objects, numbered from
+// 1 on, whose catalog is object 1. If xref is false, the cross-reference table
+// is left out, so that readers need to locate the objects themselves.
+func importTestPDF(xref bool, objs ...string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objs))
+	for j, obj := range objs {
+		offsets[j] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", j+1, obj)
+	}
+	start := buf.Len()
+	if xref {
+		fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objs)+1)
+		for _, offset := range offsets {
+			fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+		}
+	}
+	fmt.Fprintf(&buf, "trailer\n<</Size %d /Root 1 0 R>>\nstartxref\n%d\n%%%%EOF\n", len(objs)+1, start)
+	return buf.Bytes()
+}
+
+// TestImportPage checks the page tree, boundaries, rotation and resources of
+// imported pages, and the parsing of compressed cross-reference information
+func TestImportPage(t *testing.T) {
+	src := gofpdf.New("P", "pt", "A4", "")
+	src.SetFont("Helvetica", "B", 20)
+	for _, s := range []string{"First", "Second"} {
+		src.AddPage()
+		src.Cell(100, 20, s)
+		src.Image(example.ImageFile("logo.png"), 400, 20, 100, 0, false, "", 0, "")
+	}
+	var buf bytes.Buffer
+	if err := src.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	doc := buf.Bytes()
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.SetCompression(false)
+	first := pdf.ImportPage(bytes.NewReader(doc), 1, "")
+	again := pdf.ImportPage(bytes.NewReader(doc), 1, "/CropBox")
+	second := pdf.ImportPage(bytes.NewReader(doc), 2, "MediaBox")
+	if first != 1 || again != 1 || second != 2 {
+		t.Fatalf("imported page ids %d, %d, %d", first, again, second)
+	}
+	if w, h := pdf.GetImportedPageSize(second); math.Abs(w-595.28) > 1e-9 || math.Abs(h-841.89) > 1e-9 {
+		t.Fatalf("imported page size %.2f by %.2f", w, h)
+	}
+	pdf.AddPage()
+	pdf.UseImportedPage(first, 0, 0, 0, 0)
+	pdf.UseImportedPage(second, 100, 100, 0, 420.945)
+	buf.Reset()
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	s := buf.String()
+	for _, re := range []string{
+		`q 1.00000 0 0 1.00000 0.00 0.00 cm /IPG1 Do Q`,
+		`q 0.50000 0 0 0.50000 100.00 320.9\d cm /IPG2 Do Q`,
+		`/IPG1 \d+ 0 R\n/IPG2 \d+ 0 R`,
+		`/Subtype /Form /FormType 1 /BBox \[0 0 595.28 841.89\] /Matrix \[1 0 0 1 0 0\]`,
+		`\(First\)Tj`,
+		`\(Second\)Tj`,
+	} {
+		if !regexp.MustCompile(re).MatchString(s) {
+			t.Fatalf("expecting %s", re)
+		}
+	}
+	// Both pages share their font and image
+	if n := strings.Count(s, "/BaseFont /Helvetica-Bold"); n != 1 {
+		t.Fatalf("font imported %d times", n)
+	}
+	if n := strings.Count(s, "/Subtype /Image"); n != 1 {
+		t.Fatalf("image imported %d times", n)
+	}
+
+	// A rotated page that inherits its boundaries, with content split
+	// between streams, in a document without cross-reference table
+	doc = importTestPDF(false,
+		"<</Type /Catalog /Pages 2 0 R>>",
+		"<</Type /Pages /Kids [3 0 R] /Count 1 /MediaBox [0 0 200 100] /Rotate 90 /Resources <<>>>>",
+		"<</Type /Page /Parent 2 0 R /Contents [4 0 R 5 0 R] /CropBox [10 20 110 100]>>",
+		"<</Length 4>>\nstream\n0 0 \nendstream",
+		"<</Length 16 /Filter /ASCIIHexDecode>>\nstream\n3130203130207265>\nendstream")
+	pdf = gofpdf.New("P", "pt", "A4", "")
+	pdf.SetCompression(false)
+	id := pdf.ImportPage(bytes.NewReader(doc), 1, "")
+	if w, h := pdf.GetImportedPageSize(id); w != 80 || h != 100 {
+		t.Fatalf("rotated page size %.2f by %.2f", w, h)
+	}
+	pdf.AddPage()
+	pdf.UseImportedPage(id, 0, 0, 0, 0)
+	buf.Reset()
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "/BBox [10 20 110 100] /Matrix [0 -1 1 0 -20 110] /Resources <<>> /Length 14>>\nstream\n0 0 \n10 10 re\n") {
+		t.Fatal("rotated page not imported")
+	}
+
+	// A document with a cross-reference stream and an object stream
+	var objStm, xrefStm bytes.Buffer
+	objStm.WriteString("1 0 2 31 ")
+	header := objStm.Len()
+	objStm.WriteString("<</Type /Catalog /Pages 2 0 R>><</Type /Pages /Kids [4 0 R] /Count 1>>")
+	objs := []string{
+		fmt.Sprintf("<</Type /ObjStm /N 2 /First %d /Length %d>>\nstream\n%s\nendstream", header, objStm.Len(), objStm.String()),
+		"<</Type /Page /Parent 2 0 R /MediaBox [0 0 300 300] /Contents 5 0 R>>",
+		"<</Length 10>>\nstream\n0 0 1 1 re\nendstream",
+	}
+	var body bytes.Buffer
+	body.WriteString("%PDF-1.5\n")
+	offsets := []int{0, 0, 0}
+	for j, obj := range objs {
+		offsets = append(offsets, body.Len())
+		fmt.Fprintf(&body, "%d 0 obj\n%s\nendobj\n", j+3, obj)
+	}
+	// Rows of the types and fields of objects 0 to 5, with the PNG Up
+	// predictor
+	rows := [][]byte{{0, 0, 0, 0}, {2, 0, 3, 0}, {2, 0, 3, 1}, {1, byte(offsets[3] >> 8), byte(offsets[3]), 0},
+		{1, byte(offsets[4] >> 8), byte(offsets[4]), 0}, {1, byte(offsets[5] >> 8), byte(offsets[5]), 0}}
+	prev := make([]byte, 4)
+	zw := zlib.NewWriter(&xrefStm)
+	for _, row := range rows {
+		up := make([]byte, len(row))
+		for j := range row {
+			up[j] = row[j] - prev[j]
+		}
+		zw.Write(append([]byte{2}, up...))
+		prev = row
+	}
+	zw.Close()
+	start := body.Len()
+	fmt.Fprintf(&body, "6 0 obj\n<</Type /XRef /Size 7 /Index [0 6] /W [1 2 1] /Root 1 0 R "+
+		"/Filter /FlateDecode /DecodeParms <</Predictor 12 /Columns 4>> /Length %d>>\nstream\n", xrefStm.Len())
+	body.Write(xrefStm.Bytes())
+	fmt.Fprintf(&body, "\nendstream\nendobj\nstartxref\n%d\n%%%%EOF\n", start)
+	pdf = gofpdf.New("P", "pt", "A4", "")
+	id = pdf.ImportPage(bytes.NewReader(body.Bytes()), 1, "TrimBox")
+	if w, h := pdf.GetImportedPageSize(id); w != 300 || h != 300 || pdf.Err() {
+		t.Fatalf("page of cross-reference stream document %.2f by %.2f: %v", w, h, pdf.Error())
+	}
+
+	for _, c := range []struct {
+		doc  []byte
+		page int
+		box  string
+	}{
+		{doc, 2, ""},
+		{doc, 0, ""},
+		{doc, 1, "PageBox"},
+		{[]byte("%!PS-Adobe-3.0\n"), 1, ""},
+		{importTestPDF(true, "<</Type /Catalog /Pages 2 0 R>>", "<</Type /Pages /Kids [] /Count 0>>"), 1, ""},
+		// Hexadecimal data that begins with the delimiter of a dictionary
+		{importTestPDF(true, "<</Type /Catalog /Pages 2 0 R>>", "<</Type /Pages /Kids [3 0 R] /Count 1>>",
+			"<</Type /Page /Parent 2 0 R /MediaBox [0 0 100 100] /Contents 4 0 R>>",
+			"<</Length 4 /Filter /ASCIIHexDecode>>\nstream\n<41>\nendstream"), 1, ""},
+	} {
+		pdf = gofpdf.New("P", "pt", "A4", "")
+		if pdf.ImportPage(bytes.NewReader(c.doc), c.page, c.box) != 0 || !pdf.Err() {
+			t.Fatalf("expecting error for page %d of box %q", c.page, c.box)
+		}
+	}
+	pdf = gofpdf.New("P", "pt", "A4", "")
+	pdf.SetProtection(0, "", "owner")
+	id = pdf.ImportPage(bytes.NewReader(doc), 1, "")
+	pdf.AddPage()
+	pdf.UseImportedPage(id, 0, 0, 0, 0)
+	if err := pdf.Output(ioutil.Discard); err == nil {
+		t.Fatal("expecting error for encrypted imported page")
+	}
+}
+
+// ExampleFpdf_AppendPDF demonstrates a cover page followed by an existing
+// document, whose links keep working.
+func ExampleFpdf_AppendPDF() {
+	// The appended document is usually read from a file
+	var report bytes.Buffer
+	src := gofpdf.New("P", "mm", "A5", "")
+	src.SetFont("Helvetica", "", 14)
+	src.AddPage()
+	link := src.AddLink()
+	src.CellFormat(0, 10, "Contents: see the results on the next page", "", 1, "", false, link, "")
+	src.CellFormat(0, 10, "Web site of the project", "", 1, "", false, 0, "https://github.com/jacobfederer/gofpdf")
+	src.AddPage()
+	src.SetLink(link, 0, -1)
+	src.Cell(0, 10, "Results")
+	err := src.Output(&report)
+	if err == nil {
+		pdf := gofpdf.New("P", "mm", "A4", "")
+		pdf.SetFont("Helvetica", "B", 24)
+		pdf.AddPage()
+		pdf.CellFormat(0, 100, "Annual report", "", 1, "C", false, 0, "")
+		pdf.AppendPDF(bytes.NewReader(report.Bytes()))
+		fileStr := example.Filename("Fpdf_AppendPDF")
+		err = pdf.OutputFileAndClose(fileStr)
+		example.Summary(err, fileStr)
+	}
+	// Output:
+	// Successfully generated pdf/Fpdf_AppendPDF.pdf
+}
+
+// TestAppendPDF checks the pages, links and annotations of appended
+// documents
+func TestAppendPDF(t *testing.T) {
+	src := gofpdf.New("P", "pt", "A5", "")
+	src.SetFont("Helvetica", "", 12)
+	src.AddPage()
+	link := src.AddLink()
+	src.CellFormat(100, 20, "Next", "", 0, "", false, link, "")
+	src.CellFormat(100, 20, "Web", "", 0, "", false, 0, "https://example.com")
+	src.LinkToDest(0, 100, 100, 20, "end")
+	src.LinkToFile(0, 200, 100, 20, "other.pdf", "")
+	src.AddPageFormat("L", gofpdf.SizeType{Wd: 300, Ht: 400})
+	src.SetLink(link, 100, -1)
+	src.AddNamedDest("end", 2, 50)
+	var buf bytes.Buffer
+	if err := src.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	doc := buf.Bytes()
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.SetCompression(false)
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.AddPage()
+	pdf.Cell(100, 20, "Cover")
+	pdf.AppendPDF(bytes.NewReader(doc))
+	if pdf.PageCount() != 3 {
+		t.Fatalf("document has %d pages", pdf.PageCount())
+	}
+	if w, h := pdf.GetPageSize(); w != 400 || h != 300 {
+		t.Fatalf("last appended page %.2f by %.2f", w, h)
+	}
+	buf.Reset()
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	s := buf.String()
+	for _, re := range []string{
+		// Links to the second appended page, the seventh object
+		`/Rect \[31.19 562.93 55.86 550.93\] /Border \[0 0 0\] /Dest \[7 0 R /XYZ 0 200.00 null\]`,
+		`/Rect \[0.00 495.28 100.00 475.28\] /Border \[0 0 0\] /Dest \[7 0 R /XYZ 0 250.00 null\]`,
+		`/Rect \[131.19 562.93 155.86 550.93\] /Border \[0 0 0\] /A <</S /URI /URI \(https://example.com\)>>`,
+		// The link to another file is copied
+		`/Dest \[7 0 R /XYZ 0 250.00 null\]>> +(\d+) 0 R \]`,
+		`/A <</D \[0 /Fit\] /F <6f746865722e706466> /S /GoToR>> /Border \[0 0 0\] /Rect \[0 375.28 100 395.28\]`,
+		`/MediaBox \[0 0 400.00 300.00\]`,
+	} {
+		if !regexp.MustCompile(re).MatchString(s) {
+			t.Fatalf("expecting %s", re)
+		}
+	}
+
+	// A note with a pop-up window on a rotated page
+	doc = importTestPDF(true,
+		"<</Type /Catalog /Pages 2 0 R>>",
+		"<</Type /Pages /Kids [3 0 R] /Count 1>>",
+		"<</Type /Page /Parent 2 0 R /MediaBox [0 0 200 100] /Rotate 90 /Annots [4 0 R 5 0 R] /Contents 6 0 R>>",
+		"<</Type /Annot /Subtype /Text /Rect [10 10 30 20] /Contents (Note) /Popup 5 0 R /P 3 0 R>>",
+		"<</Type /Annot /Subtype /Popup /Rect [50 10 150 60] /Parent 4 0 R>>",
+		"<</Length 0>>\nstream\n\nendstream")
+	buf.Reset()
+	if err := gofpdf.Merge(&buf, bytes.NewReader(doc), bytes.NewReader(doc)); err != nil {
+		t.Fatal(err)
+	}
+	// Each copy of the note refers to its own pop-up window and the other
+	// way round
+	s = buf.String()
+	notes := regexp.MustCompile(`(\d+) 0 obj\n<</Contents <4e6f7465> /Popup +(\d+) 0 R /Rect \[10 170 20 190\] /Subtype /Text /Type /Annot>>`).FindAllStringSubmatch(s, -1)
+	popups := regexp.MustCompile(`(\d+) 0 obj\n<</Parent +(\d+) 0 R /Rect \[10 50 60 150\] /Subtype /Popup /Type /Annot>>`).FindAllStringSubmatch(s, -1)
+	if len(notes) != 2 || len(popups) != 2 {
+		t.Fatalf("%d notes and %d pop-up windows", len(notes), len(popups))
+	}
+	for _, note := range notes {
+		found := false
+		for _, popup := range popups {
+			if note[2] == popup[1] && popup[2] == note[1] {
+				found = true
+			}
+		}
+		if !found || !regexp.MustCompile(`/Annots \[ +`+note[1]+` 0 R +\d+ 0 R \]`).MatchString(s) {
+			t.Fatalf("note %s not linked to its pop-up window", note[1])
+		}
+	}
+	if notes[0][1] == notes[1][1] {
+		t.Fatal("note shared by appended pages")
+	}
+	if err := gofpdf.Merge(ioutil.Discard); err == nil {
+		t.Fatal("expecting error for merge without documents")
+	}
+}
+
+// ExampleReadTemplate demonstrates templates that are kept in a library of
+// pre-rendered templates, such as a file or a cache, and read from it.
+func ExampleReadTemplate() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	logo := pdf.CreateTemplate(func(tpl *gofpdf.Tpl) {
+		tpl.Image(example.ImageFile("logo.png"), 10, 10, 30, 0, false, "", 0, "")
+	})
+	heading := pdf.CreateTemplate(func(tpl *gofpdf.Tpl) {
+		tpl.UseTemplate(logo)
+		tpl.SetFont("Helvetica", "B", 20)
+		tpl.Text(50, 25, "Template library")
+	})
+	// Write the template to the library
+	var library bytes.Buffer
+	_, err := heading.(*gofpdf.FpdfTpl).WriteTo(&library)
+	if err == nil {
+		// Read the template from the library, once with its image data and
+		// once with image data that is read when the document is output
+		var read, opened gofpdf.Template
+		read, err = gofpdf.ReadTemplate(bytes.NewReader(library.Bytes()))
+		if err == nil {
+			opened, err = gofpdf.OpenTemplate(bytes.NewReader(library.Bytes()))
+		}
+		if err == nil {
+			pdf.AddPage()
+			pdf.UseTemplate(read)
+			pdf.UseTemplateScaled(opened, gofpdf.PointType{X: 0, Y: 50}, gofpdf.SizeType{Wd: 105, Ht: 148.5})
+			fileStr := example.Filename("ReadTemplate")
+			err = pdf.OutputFileAndClose(fileStr)
+			example.Summary(err, fileStr)
+		}
+	}
+	// Output:
+	// Successfully generated pdf/ReadTemplate.pdf
+}
+
+// countingReaderAt counts the reads of a template image data
+type countingReaderAt struct {
+	r     io.ReaderAt
+	reads int
+}
+
+func (c *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	c.reads++
+	return c.r.ReadAt(p, off)
+}
+
+// TestReadTemplate checks that templates that are written and read again
+// produce the same document, and the errors of damaged templates
+func TestReadTemplate(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	logo := pdf.CreateTemplate(func(tpl *gofpdf.Tpl) {
+		tpl.Image(example.ImageFile("logo.png"), 10, 10, 30, 0, false, "", 0, "")
+	})
+	tpl := pdf.CreateTemplate(func(tpl *gofpdf.Tpl) {
+		tpl.UseTemplate(logo)
+		tpl.Image(example.ImageFile("logo.png"), 50, 10, 30, 0, false, "", 0, "")
+		tpl.SetFont("Helvetica", "", 12)
+		tpl.Text(10, 50, "First page")
+		tpl.AddPage()
+		tpl.Text(10, 50, "Second page")
+	})
+	output := func(tpl gofpdf.Template) []byte {
+		pdf := gofpdf.New("P", "mm", "A4", "")
+		pdf.SetCompression(false)
+		pdf.AddPage()
+		// Templates that have been read do not carry their fonts
+		pdf.SetFont("Helvetica", "", 12)
+		pdf.UseTemplate(tpl)
+		second, _ := tpl.FromPage(2)
+		pdf.UseTemplate(second)
+		var buf bytes.Buffer
+		if err := pdf.Output(&buf); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	var buf bytes.Buffer
+	if _, err := tpl.(*gofpdf.FpdfTpl).WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	// The image the template and its nested template share is written once
+	buf = bytes.Buffer{}
+	if _, err := logo.(*gofpdf.FpdfTpl).WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if len(data) > buf.Len()+1000 {
+		t.Fatalf("template of %d bytes, nested template of %d bytes", len(data), buf.Len())
+	}
+	read, err := gofpdf.ReadTemplate(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.ID() != tpl.ID() || read.NumPages() != 2 || len(read.Images()) != len(tpl.Images()) ||
+		len(read.Templates()) != len(tpl.Templates()) {
+		t.Fatal("template read differs from template written")
+	}
+	counter := &countingReaderAt{r: bytes.NewReader(data)}
+	opened, err := gofpdf.OpenTemplate(counter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counter.reads == 0 {
+		t.Fatal("template not read")
+	}
+	reads := counter.reads
+	want := output(tpl)
+	if !bytes.Equal(output(read), want) {
+		t.Fatal("document with template read differs")
+	}
+	if !bytes.Equal(output(opened), want) || counter.reads == reads {
+		t.Fatal("document with template opened differs")
+	}
+	for _, c := range []struct {
+		data []byte
+		err  string
+	}{
+		{append([]byte("GOFPDFTPL\x04"), data[10:]...), "unsupported template format version 4"},
+		{append([]byte("GOFPDFTMP"), data[9:]...), "not a template"},
+		{data[:len(data)/2], "unexpected EOF"},
+		{data[:200], "unexpected EOF"},
+	} {
+		if _, err := gofpdf.ReadTemplate(bytes.NewReader(c.data)); err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Fatalf("expecting error %q, got %v", c.err, err)
+		}
+	}
+}
+
+// TestTemplateResources checks that images, fonts and nested templates that
+// several templates share are written once, whether or not the document uses
+// them as well
+func TestTemplateResources(t *testing.T) {
+	logo := example.ImageFile("logo.jpg")
+	newTpl := func(text string) gofpdf.Template {
+		inner := gofpdf.CreateTpl(gofpdf.PointType{}, gofpdf.SizeType{Wd: 40, Ht: 40}, "P", "mm", "", func(tpl *gofpdf.Tpl) {
+			tpl.Image(logo, 0, 0, 20, 0, false, "", 0, "")
+		})
+		return gofpdf.CreateTpl(gofpdf.PointType{}, gofpdf.SizeType{Wd: 80, Ht: 80}, "P", "mm", "", func(tpl *gofpdf.Tpl) {
+			tpl.Image(logo, 40, 0, 20, 0, false, "", 0, "")
+			tpl.SetFont("Courier", "", 12)
+			tpl.Text(5, 60, text)
+			tpl.UseTemplate(inner)
+		})
+	}
+	for _, docResources := range []bool{false, true} {
+		pdf := gofpdf.New("P", "mm", "A4", "")
+		pdf.SetCompression(false)
+		pdf.AddPage()
+		if docResources {
+			pdf.Image(logo, 10, 200, 30, 0, false, "", 0, "")
+			pdf.SetFont("Courier", "", 12)
+			pdf.Text(10, 250, "Document")
+		}
+		pdf.UseTemplate(newTpl("First template"))
+		pdf.UseTemplateScaled(newTpl("Second template"), gofpdf.PointType{X: 100, Y: 100}, gofpdf.SizeType{Wd: 80, Ht: 80})
+		var buf bytes.Buffer
+		if err := pdf.Output(&buf); err != nil {
+			t.Fatal(err)
+		}
+		s := buf.String()
+		if n := strings.Count(s, "/Subtype /Image"); n != 1 {
+			t.Errorf("document resources %v: %d images, expected 1", docResources, n)
+		}
+		if n := strings.Count(s, "/Subtype /Form"); n != 3 {
+			t.Errorf("document resources %v: %d templates, expected 3", docResources, n)
+		}
+		if n := strings.Count(s, "/BaseFont /Courier"); n != 1 {
+			t.Errorf("document resources %v: %d fonts, expected 1", docResources, n)
+		}
+		if strings.Contains(s, " 0 0 R") {
+			t.Errorf("document resources %v: reference to object 0", docResources)
+		}
+		for _, dict := range regexp.MustCompile(`/XObject <<[^>]*>>`).FindAllString(s, -1) {
+			names := regexp.MustCompile(`/(I|TPL)[0-9a-f]+`).FindAllString(dict, -1)
+			seen := make(map[string]bool)
+			for _, name := range names {
+				if seen[name] {
+					t.Errorf("document resources %v: %s listed twice in %q", docResources, name, dict)
+				}
+				seen[name] = true
+			}
+		}
+	}
+}
+
+// ExampleFpdf_SetOutputWriter demonstrates a long document whose pages are
+// written to a file as they are finished, rather than held in memory.
+func ExampleFpdf_SetOutputWriter() {
+	fileStr := example.Filename("Fpdf_SetOutputWriter")
+	fl, err := os.Create(fileStr)
+	if err == nil {
+		pdf := gofpdf.New("P", "mm", "A4", "")
+		pdf.SetOutputWriter(fl)
+		pdf.SetFooterFunc(func() {
+			pdf.SetY(-15)
+			pdf.SetFont("Arial", "I", 8)
+			pdf.CellFormat(0, 10, fmt.Sprintf("Page %d", pdf.PageNo()), "", 0, "C", false, 0, "")
+		})
+		for account := 1; account <= 200; account++ {
+			pdf.AddPage()
+			// Write the statement of the previous account
+			pdf.FlushPage()
+			pdf.SetFont("Arial", "B", 14)
+			pdf.CellFormat(0, 10, fmt.Sprintf("Statement of account %06d", account), "", 1, "", false, 0, "")
+			pdf.SetFont("Courier", "", 10)
+			for j := 1; j <= 30; j++ {
+				pdf.CellFormat(0, 7, fmt.Sprintf("2024-%02d-%02d  Transaction %3d %12.2f", 1+j%12, 1+j%28, j,
+					float64(account*j%1000)/7), "", 1, "", false, 0, "")
+			}
+		}
+		pdf.Close()
+		err = pdf.Error()
+		if closeErr := fl.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_SetOutputWriter.pdf
+}
+
+// checkXref checks that the entries of the cross-reference table of the
+// document doc point to the objects they refer to
+func checkXref(t *testing.T, doc []byte) {
+	t.Helper()
+	m := regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`).FindSubmatch(doc)
+	if m == nil {
+		t.Fatal("startxref not found")
+	}
+	start, _ := strconv.Atoi(string(m[1]))
+	if !bytes.HasPrefix(doc[start:], []byte("xref\n")) {
+		t.Fatalf("startxref %d does not point to the cross-reference table", start)
+	}
+	lines := strings.Split(string(doc[start:]), "\n")
+	var count int
+	fmt.Sscanf(lines[1], "0 %d", &count)
+	for j := 1; j < count; j++ {
+		offset, _ := strconv.Atoi(lines[2+j][:10])
+		if !bytes.HasPrefix(doc[offset:], []byte(fmt.Sprintf("%d 0 obj", j))) {
+			t.Fatalf("offset %d of object %d points to %q", offset, j, doc[offset:offset+10])
+		}
+	}
+}
+
+// TestSetOutputWriter checks that pages are written to the output writer as
+// they are flushed, that the document written is consistent, and the errors
+// of features that need the complete document
+func TestSetOutputWriter(t *testing.T) {
+	var buf bytes.Buffer
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false)
+	pdf.SetOutputWriter(&buf)
+	pdf.SetFont("Helvetica", "", 12)
+	last := pdf.AddLink()
+	var lengths []int
+	for j := 1; j <= 20; j++ {
+		pdf.AddPage()
+		pdf.FlushPage()
+		lengths = append(lengths, buf.Len())
+		pdf.Bookmark(fmt.Sprintf("Page %d", j), 0, 0)
+		pdf.CellFormat(0, 10, fmt.Sprintf("Page %d", j), "", 1, "", false, 0, "")
+		if j == 1 {
+			pdf.CellFormat(0, 10, "To the last page", "", 1, "", false, last, "")
+		}
+	}
+	pdf.SetLink(last, 0, 20)
+	pdf.AddPage()
+	if lengths[0] != 0 || lengths[1] == 0 || lengths[19] <= lengths[18] {
+		t.Fatalf("pages not written as they are flushed: %v", lengths)
+	}
+	if strings.Contains(buf.String(), "Page 21") || strings.Contains(buf.String(), "/Type /Page\n") {
+		t.Fatal("unfinished page or page dictionary written before the document is closed")
+	}
+	pdf.Close()
+	if err := pdf.Error(); err != nil {
+		t.Fatal(err)
+	}
+	doc := buf.Bytes()
+	checkXref(t, doc)
+	s := buf.String()
+	if !strings.HasPrefix(s, "%PDF-1.3\n") || strings.Count(s, "%PDF") != 1 || !strings.Contains(s, "/Count 21") {
+		t.Fatal("document structure differs")
+	}
+	m := regexp.MustCompile(`/Annots \[<<.*?/Dest \[(\d+) 0 R`).FindStringSubmatch(s)
+	if m == nil {
+		t.Fatal("link not found")
+	}
+	if !regexp.MustCompile(`\n` + m[1] + ` 0 obj\n<</Type /Page\n[^\n]*\n[^\n]*\n/Contents \d+ 0 R`).MatchString(s) {
+		t.Fatalf("link destination %s 0 R is not a page", m[1])
+	}
+	merged := gofpdf.New("P", "mm", "A4", "")
+	merged.AppendPDF(bytes.NewReader(doc))
+	if merged.Error() != nil || merged.PageCount() != 21 {
+		t.Fatalf("document written cannot be read: %v", merged.Error())
+	}
+
+	for _, c := range []struct {
+		name string
+		fn   func(pdf *gofpdf.Fpdf)
+	}{
+		{"total number of pages", func(pdf *gofpdf.Fpdf) {
+			pdf.AliasNbPages("")
+			pdf.AddPage()
+			pdf.Cell(0, 10, "Page 1 of {nb}")
+			pdf.AddPage()
+			pdf.FlushPage()
+		}},
+		{"set after the first page", func(pdf *gofpdf.Fpdf) {
+			pdf.AddPage()
+			pdf.SetOutputWriter(ioutil.Discard)
+		}},
+		{"protection after flushing", func(pdf *gofpdf.Fpdf) {
+			pdf.AddPage()
+			pdf.AddPage()
+			pdf.FlushPage()
+			pdf.SetProtection(0, "", "owner")
+		}},
+	} {
+		pdf := gofpdf.New("P", "mm", "A4", "")
+		pdf.SetFont("Helvetica", "", 12)
+		if c.name != "set after the first page" {
+			pdf.SetOutputWriter(ioutil.Discard)
+		}
+		c.fn(pdf)
+		pdf.Close()
+		if pdf.Error() == nil {
+			t.Errorf("%s: no error", c.name)
+		}
+	}
+	pdf = gofpdf.New("P", "mm", "A4", "")
+	pdf.SetOutputWriter(ioutil.Discard)
+	if pdf.Output(ioutil.Discard) == nil {
+		t.Error("Output() of document written to an output writer succeeds")
+	}
+}
+
+// TestCompactXref checks that documents of version 1.5 end with a
+// cross-reference stream that points to the objects, whether they are packed
+// into object streams or not, and that they are smaller
+func TestCompactXref(t *testing.T) {
+	output := func(version string, compress bool, fn func(pdf *gofpdf.Fpdf)) []byte {
+		pdf := gofpdf.New("P", "mm", "A4", "")
+		pdf.SetCompression(compress)
+		if version != "" {
+			pdf.SetPDFVersion(version)
+		}
+		if fn != nil {
+			fn(pdf)
+		}
+		pdf.SetFont("Helvetica", "", 12)
+		for j := 1; j <= 150; j++ {
+			pdf.AddPage()
+			pdf.Bookmark(fmt.Sprintf("Page %d", j), 0, 0)
+			pdf.CellFormat(0, 10, fmt.Sprintf("Page %d", j), "", 1, "", false, 0, "https://example.com")
+		}
+		var buf bytes.Buffer
+		if err := pdf.Output(&buf); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	doc := output("1.5", false, nil)
+	s := string(doc)
+	if strings.Contains(s, "\nxref\n") || strings.Count(s, "/Type /ObjStm") < 2 {
+		t.Fatal("document has no cross-reference and object streams")
+	}
+	// Check the entries of the cross-reference stream
+	m := regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`).FindStringSubmatch(s)
+	start, _ := strconv.Atoi(m[1])
+	m = regexp.MustCompile(`^(\d+) 0 obj\n<</Type /XRef /W \[1 (\d) 2\]\n/Size (\d+)\n(?s:.*?)/Length (\d+)>>\nstream\n`).
+		FindStringSubmatch(s[start:])
+	if m == nil {
+		t.Fatalf("cross-reference stream not found at %d", start)
+	}
+	w, _ := strconv.Atoi(m[2])
+	size, _ := strconv.Atoi(m[3])
+	length, _ := strconv.Atoi(m[4])
+	data := doc[start+len(m[0]):]
+	if length != size*(3+w) {
+		t.Fatalf("cross-reference stream of %d bytes for %d objects", length, size)
+	}
+	field := func(b []byte) (v int) {
+		for _, c := range b {
+			v = v<<8 | int(c)
+		}
+		return
+	}
+	packed := 0
+	for j := 1; j < size; j++ {
+		e := data[j*(3+w) : (j+1)*(3+w)]
+		switch e[0] {
+		case 1:
+			offset := field(e[1 : 1+w])
+			if !strings.HasPrefix(s[offset:], fmt.Sprintf("%d 0 obj\n", j)) {
+				t.Fatalf("object %d not found at %d", j, offset)
+			}
+		case 2:
+			stm := field(e[1 : 1+w])
+			re := regexp.MustCompile(fmt.Sprintf(`\n%d 0 obj\n<</Type /ObjStm /N \d+ /First \d+\n`, stm))
+			if !re.MatchString(s) {
+				t.Fatalf("object stream %d of object %d not found", stm, j)
+			}
+			packed++
+		default:
+			t.Fatalf("entry of object %d of type %d", j, e[0])
+		}
+	}
+	if packed < 300 {
+		t.Fatalf("%d objects packed", packed)
+	}
+	// The document can be read, and is smaller than the classic one
+	compact := output("1.5", true, nil)
+	merged := gofpdf.New("P", "mm", "A4", "")
+	merged.AppendPDF(bytes.NewReader(compact))
+	if merged.Error() != nil || merged.PageCount() != 150 {
+		t.Fatalf("document cannot be read: %v", merged.Error())
+	}
+	if classic := output("", true, nil); len(compact)*10 > len(classic)*8 {
+		t.Fatalf("document of %d bytes, classic document of %d bytes", len(compact), len(classic))
+	}
+	// Objects of encrypted documents are not packed
+	s = string(output("1.5", true, func(pdf *gofpdf.Fpdf) {
+		pdf.SetProtection(0, "", "owner")
+	}))
+	if !strings.Contains(s, "/Type /XRef") || strings.Contains(s, "/Type /ObjStm") {
+		t.Fatal("objects of encrypted document packed")
+	}
+}
+
+// hexFilter encodes streams in hexadecimal, which keeps them readable in a
+// text editor
+type hexFilter struct {
+	err error
+}
+
+func (hexFilter) Name() string {
+	return "ASCIIHexDecode"
+}
+
+func (h hexFilter) Encode(data []byte) ([]byte, error) {
+	return []byte(hex.EncodeToString(data) + ">"), h.err
+}
+
+// ExampleFpdf_SetCompressionLevel demonstrates a document that is compressed
+// as well as possible for archiving, and a document whose streams are encoded
+// with a custom filter.
+func ExampleFpdf_SetCompressionLevel() {
+	for _, archive := range []bool{true, false} {
+		pdf := gofpdf.New("P", "mm", "A4", "")
+		fileStr := example.Filename("Fpdf_SetStreamFilter")
+		if archive {
+			pdf.SetCompressionLevel(zlib.BestCompression)
+			fileStr = example.Filename("Fpdf_SetCompressionLevel")
+		} else {
+			pdf.SetStreamFilter(hexFilter{})
+		}
+		pdf.SetFont("Times", "", 12)
+		pdf.AddPage()
+		for j := 1; j <= 40; j++ {
+			pdf.CellFormat(0, 6, fmt.Sprintf("Line %d of a document to keep", j), "", 1, "", false, 0, "")
+		}
+		err := pdf.OutputFileAndClose(fileStr)
+		example.Summary(err, fileStr)
+	}
+	// Output:
+	// Successfully generated pdf/Fpdf_SetCompressionLevel.pdf
+	// Successfully generated pdf/Fpdf_SetStreamFilter.pdf
+}
+
+// TestSetCompressionLevel checks that the compression level and stream
+// filters are applied, and their errors
+func TestSetCompressionLevel(t *testing.T) {
+	output := func(fn func(pdf *gofpdf.Fpdf)) ([]byte, error) {
+		pdf := gofpdf.New("P", "mm", "A4", "")
+		pdf.SetCompression(true)
+		fn(pdf)
+		pdf.SetFont("Times", "", 12)
+		for j := 1; j <= 10; j++ {
+			pdf.AddPage()
+			for k := 1; k <= 40; k++ {
+				pdf.CellFormat(0, 6, fmt.Sprintf("Line %d of page %d", k, j), "", 1, "", false, 0, "")
+			}
+		}
+		var buf bytes.Buffer
+		err := pdf.Output(&buf)
+		return buf.Bytes(), err
+	}
+	fast, err := output(func(pdf *gofpdf.Fpdf) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	best, err := output(func(pdf *gofpdf.Fpdf) { pdf.SetCompressionLevel(zlib.BestCompression) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(fast, []byte("%PDF-1.3\n")) || bytes.Contains(fast, []byte("/Type /XRef")) {
+		t.Fatal("document of default compression level changed")
+	}
+	if !bytes.HasPrefix(best, []byte("%PDF-1.5\n")) || !bytes.Contains(best, []byte("/Type /XRef")) ||
+		len(best) >= len(fast) {
+		t.Fatalf("document of best compression of %d bytes, of default compression %d bytes", len(best), len(fast))
+	}
+	if _, err = output(func(pdf *gofpdf.Fpdf) { pdf.SetCompressionLevel(10) }); err == nil {
+		t.Fatal("invalid compression level accepted")
+	}
+
+	doc, err := output(func(pdf *gofpdf.Fpdf) { pdf.SetStreamFilter(hexFilter{}) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := regexp.MustCompile(`<</Filter /ASCIIHexDecode /Length \d+>>\nstream\n([0-9a-f]+)>\nendstream`).FindSubmatch(doc)
+	if m == nil {
+		t.Fatal("page content not encoded with the stream filter")
+	}
+	if content, _ := hex.DecodeString(string(m[1])); !bytes.Contains(content, []byte("(Line 1 of page 1)Tj")) {
+		t.Fatalf("page content %q", content)
+	}
+	merged := gofpdf.New("P", "mm", "A4", "")
+	merged.AppendPDF(bytes.NewReader(doc))
+	if merged.Error() != nil || merged.PageCount() != 10 {
+		t.Fatalf("document cannot be read: %v", merged.Error())
+	}
+	if _, err = output(func(pdf *gofpdf.Fpdf) {
+		pdf.SetStreamFilter(hexFilter{err: fmt.Errorf("out of memory")})
+	}); err == nil {
+		t.Fatal("error of stream filter ignored")
+	}
+}
+
+// ExampleFpdf_OutputLinearized demonstrates a report written as a linearized
+// document, whose first page web browsers display before the rest of the
+// document has been downloaded.
+func ExampleFpdf_OutputLinearized() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-15)
+		pdf.SetFont("Arial", "I", 8)
+		pdf.CellFormat(0, 10, fmt.Sprintf("Page %d of {nb}", pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+	pdf.AliasNbPages("")
+	for region := 1; region <= 40; region++ {
+		pdf.AddPage()
+		pdf.ImageOptions(example.ImageFile("logo.png"), 170, 10, 25, 0, false, gofpdf.ImageOptions{}, 0, "")
+		pdf.SetFont("Arial", "B", 16)
+		pdf.CellFormat(0, 12, fmt.Sprintf("Sales of region %d", region), "", 1, "", false, 0, "")
+		pdf.SetFont("Arial", "", 11)
+		for month := 1; month <= 12; month++ {
+			pdf.CellFormat(0, 8, fmt.Sprintf("Month %2d %12.2f", month, float64(region*month*137%1000)/3), "", 1, "", false, 0, "")
+		}
+	}
+	fileStr := example.Filename("Fpdf_OutputLinearized")
+	fl, err := os.Create(fileStr)
+	if err == nil {
+		err = pdf.OutputLinearized(fl)
+		if closeErr := fl.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_OutputLinearized.pdf
+}
+
+// TestOutputLinearized checks the layout of a linearized document: the
+// linearization dictionary, the cross-reference tables, the position of the
+// first page and of the hint stream, and that the document can be read
+func TestOutputLinearized(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(true)
+	pdf.SetFont("Helvetica", "", 12)
+	last := pdf.AddLink()
+	for j := 1; j <= 12; j++ {
+		pdf.AddPage()
+		pdf.CellFormat(0, 10, fmt.Sprintf("Page %d", j), "", 1, "", false, last, "")
+		pdf.ImageOptions(example.ImageFile("logo.png"), 10, 30, 30, 0, false, gofpdf.ImageOptions{}, 0, "")
+	}
+	pdf.SetLink(last, 0, 12)
+	var buf bytes.Buffer
+	if err := pdf.OutputLinearized(&buf); err != nil {
+		t.Fatal(err)
+	}
+	doc := buf.Bytes()
+	s := string(doc)
+	m := regexp.MustCompile(`^%PDF-1\.\d\n%[^\n]*\n\d+ 0 obj\n<</Linearized 1 /L (\d+) /H \[(\d+) \d+\] /O (\d+) /E (\d+) /N (\d+) /T (\d+)>>`).
+		FindStringSubmatch(s)
+	if m == nil {
+		t.Fatalf("linearization dictionary not found in %q", s[:100])
+	}
+	var v [6]int
+	for j := range v {
+		v[j], _ = strconv.Atoi(m[1+j])
+	}
+	length, hint, first, end, count, main := v[0], v[1], v[2], v[3], v[4], v[5]
+	if length != len(doc) || count != 12 {
+		t.Fatalf("document of %d bytes and %d pages", length, count)
+	}
+	if !strings.HasPrefix(s[main:], "\n0000000000 65535 f \n") {
+		t.Fatalf("main cross-reference table not found at %d", main)
+	}
+	// Check the entries of both cross-reference tables
+	offsets := make(map[int]int)
+	sections := regexp.MustCompile(`\nxref\n(\d+) (\d+)\n`).FindAllStringSubmatchIndex(s, -1)
+	if len(sections) != 2 {
+		t.Fatalf("%d cross-reference tables", len(sections))
+	}
+	for _, sec := range sections {
+		start, _ := strconv.Atoi(s[sec[2]:sec[3]])
+		n, _ := strconv.Atoi(s[sec[4]:sec[5]])
+		for j := 0; j < n; j++ {
+			entry := s[sec[1]+20*j : sec[1]+20*(j+1)]
+			if strings.HasSuffix(entry, "f \n") {
+				continue
+			}
+			offset, _ := strconv.Atoi(entry[:10])
+			if !strings.HasPrefix(s[offset:], fmt.Sprintf("%d 0 obj\n", start+j)) {
+				t.Fatalf("object %d not found at %d", start+j, offset)
+			}
+			offsets[start+j] = offset
+		}
+	}
+	if !strings.HasSuffix(s, fmt.Sprintf("startxref\n%d\n%%%%EOF\n", sections[0][0]+1)) {
+		t.Fatal("startxref does not point to the first-page cross-reference table")
+	}
+	// The first page comes before the end of the first page section, and the
+	// page offset hint table locates it
+	page := s[offsets[first]:]
+	page = page[:strings.Index(page, "endobj")]
+	if offsets[first] >= end || !strings.Contains(page, "/Contents ") || !strings.Contains(page, "/Type /Page>>") {
+		t.Fatalf("first page at %d, end of first page section at %d", offsets[first], end)
+	}
+	m = regexp.MustCompile(`^\d+ 0 obj\n<</Length \d+ /S \d+>>\nstream\n`).FindStringSubmatch(s[hint:])
+	if m == nil {
+		t.Fatalf("hint stream not found at %d", hint)
+	}
+	h := doc[hint+len(m[0]):]
+	if pos := int(h[4])<<24 | int(h[5])<<16 | int(h[6])<<8 | int(h[7]); pos != offsets[first] {
+		t.Fatalf("hint table locates the first page at %d rather than %d", pos, offsets[first])
+	}
+	merged := gofpdf.New("P", "mm", "A4", "")
+	merged.AppendPDF(bytes.NewReader(doc))
+	if merged.Error() != nil || merged.PageCount() != 12 {
+		t.Fatalf("document cannot be read: %v", merged.Error())
+	}
+
+	pdf = gofpdf.New("P", "mm", "A4", "")
+	pdf.SetProtection(0, "user", "owner")
+	pdf.AddPage()
+	if pdf.OutputLinearized(&buf) == nil {
+		t.Fatal("encrypted document linearized")
+	}
+	pdf = gofpdf.New("P", "mm", "A4", "")
+	pdf.SetOutputWriter(&buf)
+	pdf.AddPage()
+	if pdf.OutputLinearized(&buf) == nil {
+		t.Fatal("document written to an output writer linearized")
+	}
+}
+
+// ExampleFpdf_AddBuiltPages demonstrates a catalog whose sections are
+// rendered by several goroutines at once, each with a page builder, and then
+// added to the document in order.
+func ExampleFpdf_AddBuiltPages() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFont("Arial", "", 11)
+	pdf.AddPage()
+	pdf.SetFont("Arial", "B", 24)
+	pdf.CellFormat(0, 40, "Product catalog", "", 1, "C", false, 0, "")
+	pdf.SetFont("Arial", "", 11)
+	sections := make([]*gofpdf.PageBuilder, 8)
+	var wg sync.WaitGroup
+	for j := range sections {
+		b := pdf.NewPageBuilder()
+		sections[j] = b
+		wg.Add(1)
+		go func(section int) {
+			defer wg.Done()
+			b.SetFooterFunc(func() {
+				b.SetY(-15)
+				b.SetFont("Arial", "I", 8)
+				b.CellFormat(0, 10, fmt.Sprintf("Section %d, page %d", section, b.PageNo()), "", 0, "C", false, 0, "")
+			})
+			b.AddPage()
+			b.SetFont("Arial", "B", 16)
+			b.CellFormat(0, 12, fmt.Sprintf("Section %d", section), "", 1, "", false, 0, "")
+			b.SetFont("Arial", "", 11)
+			for item := 1; item <= 60; item++ {
+				if item%20 == 1 {
+					b.ImageOptions(example.ImageFile("logo.png"), 180, b.GetY(), 15, 0, false, gofpdf.ImageOptions{}, 0, "")
+				}
+				b.CellFormat(0, 7, fmt.Sprintf("Item %d.%02d %10.2f", section, item, float64(section*item%97)*1.25),
+					"", 1, "", false, 0, "")
+			}
+		}(j + 1)
+	}
+	wg.Wait()
+	pdf.AddBuiltPages(sections...)
+	fileStr := example.Filename("Fpdf_AddBuiltPages")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_AddBuiltPages.pdf
+}
+
+// TestAddBuiltPages checks that the pages of page builders rendered at once
+// are added in order, with their fonts, images and links, and the features
+// that page builders do not support
+func TestAddBuiltPages(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false)
+	pdf.AddUTF8Font("dejavu", "", example.FontFile("DejaVuSansCondensed.ttf"))
+	pdf.SetKerning(true)
+	pdf.SetFont("dejavu", "", 12)
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-15)
+		pdf.SetFont("Helvetica", "", 8)
+		pdf.CellFormat(0, 10, "Document footer", "", 0, "C", false, 0, "")
+	})
+	pdf.AddPage()
+	pdf.SetAlpha(0.5, "Normal")
+	pdf.SetAlpha(1, "Normal")
+	pdf.Write(8, "Cover")
+	builders := make([]*gofpdf.PageBuilder, 4)
+	var wg sync.WaitGroup
+	for j := range builders {
+		b := pdf.NewPageBuilder()
+		builders[j] = b
+		wg.Add(1)
+		go func(j int) {
+			defer wg.Done()
+			b.AddPage()
+			link := b.AddLink()
+			b.CellFormat(0, 10, fmt.Sprintf("Builder %d Привет", j), "", 1, "", false, link, "")
+			b.WriteShaped(10, "office")
+			b.SetAlpha(0.5, "Normal")
+			b.ImageOptions(example.ImageFile("logo.png"), 10, 40, 30, 0, false, gofpdf.ImageOptions{}, 0, "")
+			b.SetAlpha(1, "Normal")
+			b.AddPage()
+			b.SetLink(link, 0, -1)
+			b.Write(10, fmt.Sprintf("End of builder %d", j))
+		}(j)
+	}
+	wg.Wait()
+	pdf.AddBuiltPages(builders...)
+	pdf.AddPage()
+	pdf.Write(8, "Back cover")
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if pdf.PageCount() != 10 {
+		t.Fatalf("document has %d pages", pdf.PageCount())
+	}
+	s := buf.String()
+	if strings.Count(s, "/Subtype /Image") != 1 || strings.Count(s, "/Type /ExtGState") != 2 ||
+		strings.Count(s, "/Subtype /Type0") != 1 {
+		t.Fatal("resources of the page builders not shared with the document")
+	}
+	// The links of each builder lead to its second page, the page objects of
+	// the document are numbered 3, 5, 7...
+	links := regexp.MustCompile(`/Dest \[(\d+) 0 R`).FindAllStringSubmatch(s, -1)
+	if len(links) != 4 {
+		t.Fatalf("%d links", len(links))
+	}
+	for j, m := range links {
+		if m[1] != strconv.Itoa(1+2*(3+2*j)) {
+			t.Fatalf("link %d leads to object %s", j, m[1])
+		}
+	}
+	merged := gofpdf.New("P", "mm", "A4", "")
+	merged.AppendPDF(bytes.NewReader(buf.Bytes()))
+	if merged.Error() != nil || merged.PageCount() != 10 {
+		t.Fatalf("document cannot be read: %v", merged.Error())
+	}
+	if strings.Count(s, "(Document footer)") != 2 {
+		t.Fatal("footer of the document not printed on its own pages only")
+	}
+
+	pdf = gofpdf.New("P", "mm", "A4", "")
+	b := pdf.NewPageBuilder()
+	b.AddPage()
+	b.LinearGradient(10, 10, 50, 50, 0, 0, 0, 255, 255, 255, 0, 0, 1, 1)
+	pdf.AddBuiltPages(b)
+	if pdf.Error() == nil {
+		t.Fatal("page builder with a gradient added")
+	}
+	pdf = gofpdf.New("P", "mm", "A4", "")
+	b = pdf.NewPageBuilder()
+	b.AddPage()
+	pdf.AddBuiltPages(b)
+	pdf.AddBuiltPages(b)
+	if pdf.Error() == nil {
+		t.Fatal("pages of page builder added twice")
+	}
+}
+
+// ExampleDisplayList demonstrates a label recorded once, measured to be
+// centered on the page, and replayed in several places, one of them from its
+// JSON form.
+func ExampleDisplayList() {
+	var label gofpdf.DisplayList
+	label.SetDrawColor(0, 80, 180)
+	label.SetFillColor(220, 230, 250)
+	label.SetLineWidth(0.8)
+	label.Rect(0, 0, 80, 30, "DF")
+	label.SetFont("Helvetica", "B", 16)
+	label.SetXY(5, 5)
+	label.CellFormat(70, 10, "Fragile", "", 2, "C", false)
+	label.SetFont("Helvetica", "", 11)
+	label.CellFormat(70, 10, "Handle with care", "", 2, "C", false)
+	label.Circle(72, 8, 4, "D")
+
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+	_, _, w, h := label.Bounds(pdf)
+	pageW, _ := pdf.GetPageSize()
+	label.Translated((pageW-w)/2, 20).Replay(pdf)
+	label.Rotated(15, 0, 30).Translated(30, 120).Replay(pdf)
+	var buf bytes.Buffer
+	_, err := label.Scaled(150, 150, 0, 0).WriteTo(&buf)
+	if err == nil {
+		var large *gofpdf.DisplayList
+		large, err = gofpdf.ReadDisplayList(&buf)
+		if err == nil {
+			large.Translated(50, 200-h).Replay(pdf)
+		}
+	}
+	pdf.SetError(err)
+	fileStr := example.Filename("DisplayList")
+	err = pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/DisplayList.pdf
+}
+
+// TestDisplayList checks that a display list draws what the calls it records
+// draw, its bounds, transformations and serialization, and the errors of
+// invalid operations
+func TestDisplayList(t *testing.T) {
+	var dl gofpdf.DisplayList
+	dl.SetDrawColor(255, 0, 0)
+	dl.SetLineWidth(1)
+	dl.Line(10, 10, 40, 20)
+	dl.Polygon([]gofpdf.PointType{{X: 20, Y: 30}, {X: 50, Y: 35}, {X: 30, Y: 60}}, "D")
+	dl.SetFont("Helvetica", "", 12)
+	dl.SetXY(20, 70)
+	dl.CellFormat(50, 10, "Cell", "1", 1, "R", false)
+	dl.Image(example.ImageFile("logo.png"), 60, 10, 20, 0)
+	output := func(fn func(pdf *gofpdf.Fpdf)) string {
+		pdf := gofpdf.New("P", "mm", "A4", "")
+		pdf.SetCompression(false)
+		pdf.AddPage()
+		fn(pdf)
+		var buf bytes.Buffer
+		if err := pdf.Output(&buf); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+	direct := output(func(pdf *gofpdf.Fpdf) {
+		pdf.SetDrawColor(255, 0, 0)
+		pdf.SetLineWidth(1)
+		pdf.Line(10, 10, 40, 20)
+		pdf.Polygon([]gofpdf.PointType{{X: 20, Y: 30}, {X: 50, Y: 35}, {X: 30, Y: 60}}, "D")
+		pdf.SetFont("Helvetica", "", 12)
+		pdf.SetXY(20, 70)
+		pdf.CellFormat(50, 10, "Cell", "1", 1, "R", false, 0, "")
+		pdf.ImageOptions(example.ImageFile("logo.png"), 60, 10, 20, 0, false, gofpdf.ImageOptions{}, 0, "")
+	})
+	if replayed := output(func(pdf *gofpdf.Fpdf) { dl.Replay(pdf) }); replayed != direct {
+		t.Fatal("replayed display list differs from the calls it records")
+	}
+
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+	round := func(v ...float64) string {
+		return fmt.Sprintf("%.2f", v)
+	}
+	x, y, w, h := dl.Bounds(pdf)
+	info := pdf.GetImageInfo(example.ImageFile("logo.png"))
+	if got := round(x, y, w, h); got != round(10, 10, 70, 70) || info != nil {
+		t.Fatalf("bounds %s", got)
+	}
+	x, y, w, h = dl.Translated(5, -5).Bounds(pdf)
+	if got := round(x, y, w, h); got != round(15, 5, 70, 70) {
+		t.Fatalf("bounds of translated list %s", got)
+	}
+	var rect gofpdf.DisplayList
+	rect.Rect(0, 0, 10, 5, "F")
+	x, y, w, h = rect.Rotated(90, 0, 0).Bounds(pdf)
+	if got := round(x, y, w, h); got != round(0, -10, 5, 10) {
+		t.Fatalf("bounds of rotated rectangle %s", got)
+	}
+	x, y, w, h = rect.Scaled(200, 50, 10, 0).Bounds(pdf)
+	if got := round(x, y, w, h); got != round(-10, 0, 20, 2.5) {
+		t.Fatalf("bounds of scaled rectangle %s", got)
+	}
+	var text gofpdf.DisplayList
+	text.SetFont("Courier", "", 10)
+	text.Text(10, 20, "abcd")
+	x, y, w, h = text.Bounds(pdf)
+	size := 10 / pdf.GetConversionRatio()
+	if got := round(x, y, w, h); got != round(10, 20-0.8*size, 2.4*size, size) {
+		t.Fatalf("bounds of text %s", got)
+	}
+	if pdf.Error() != nil {
+		t.Fatal(pdf.Error())
+	}
+
+	var buf bytes.Buffer
+	if _, err := dl.Scaled(50, 50, 0, 0).WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	read, err := gofpdf.ReadDisplayList(&buf)
+	if err != nil || !reflect.DeepEqual(read, dl.Scaled(50, 50, 0, 0)) {
+		t.Fatalf("display list read differs from the one written: %v", err)
+	}
+
+	read.Ops = append(read.Ops, gofpdf.DisplayOp{Name: "Line", Nums: []float64{1, 2}})
+	read.Replay(pdf)
+	if pdf.Error() == nil {
+		t.Fatal("invalid operation replayed")
+	}
+}
+
+// ExampleFpdf_NewMeasurer demonstrates the measurement of a block of text
+// before it is drawn, so that it can be aligned with the bottom of the page
+func ExampleFpdf_NewMeasurer() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFont("Times", "", 12)
+	pdf.AddPage()
+	txtStr := strings.Repeat("The height of this paragraph is measured before it is drawn, "+
+		"so that its last line ends at the bottom margin of the page. ", 6)
+	m := pdf.NewMeasurer()
+	m.MultiCell(120, 6, txtStr, "", "J", false)
+	r := m.Result()
+	pdf.SetError(m.Error())
+	_, pageH := pdf.GetPageSize()
+	_, bottom := pdf.GetAutoPageBreak()
+	y := pageH - bottom - r.Height
+	pdf.SetFillColor(230, 230, 230)
+	pdf.Rect(40, y-4, 130, r.Height+8, "F")
+	pdf.SetXY(45, y)
+	pdf.MultiCell(120, 6, txtStr, "", "J", false)
+	fileStr := example.Filename("Fpdf_NewMeasurer")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_NewMeasurer.pdf
+}
+
+// TestMeasurer checks that a measurer reaches the position that the document
+// reaches with the same calls, with the same page breaks, without changing
+// the document
+func TestMeasurer(t *testing.T) {
+	txtStr := strings.Repeat("Measured text wraps over several lines. ", 20)
+	layout := func(pdf *gofpdf.Fpdf) {
+		pdf.MultiCell(100, 6, txtStr, "", "L", false)
+		pdf.CellFormat(40, 10, "Cell", "1", 1, "", false, 0, "")
+		pdf.ImageOptions(example.ImageFile("logo.png"), -1, -1, 20, 0, true,
+			gofpdf.ImageOptions{}, 0, "")
+	}
+	newDoc := func() *gofpdf.Fpdf {
+		pdf := gofpdf.New("P", "mm", "A4", "")
+		pdf.SetCompression(false)
+		pdf.SetFont("Helvetica", "", 12)
+		pdf.AddPage()
+		pdf.SetHeaderFunc(func() {
+			pdf.SetY(30)
+		})
+		return pdf
+	}
+	output := func(pdf *gofpdf.Fpdf) string {
+		var buf bytes.Buffer
+		if err := pdf.Output(&buf); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+	// Without page breaks, the height is the distance covered
+	pdf := newDoc()
+	m := pdf.NewMeasurer()
+	layout(m.Fpdf)
+	single := m.Result()
+	if m.Error() != nil {
+		t.Fatal(m.Error())
+	}
+	_, top, _, _ := pdf.GetMargins()
+	if single.PageBreaks != 0 || single.Height <= 0 || math.Abs(single.Y-single.Height-top) > 1e-9 {
+		t.Fatalf("unexpected result %+v", single)
+	}
+	// With page breaks, the height is the same, and the position the one the
+	// document reaches
+	pdf = newDoc()
+	pdf.SetY(240)
+	x, y := pdf.GetXY()
+	m = pdf.NewMeasurer()
+	m.SetHeaderFunc(func() {
+		m.SetY(30)
+	})
+	layout(m.Fpdf)
+	r := m.Result()
+	if gotX, gotY := pdf.GetXY(); gotX != x || gotY != y || pdf.PageNo() != 1 {
+		t.Fatalf("document changed by measurer")
+	}
+	measured := output(pdf)
+	pdf = newDoc()
+	pdf.SetY(240)
+	unmeasured := output(pdf)
+	if measured != unmeasured {
+		t.Fatalf("measurer added to the output of the document")
+	}
+	pdf = newDoc()
+	pdf.SetY(240)
+	layout(pdf)
+	x, y = pdf.GetXY()
+	if r.PageBreaks != pdf.PageNo()-1 || r.PageBreaks == 0 || r.X != x || r.Y != y {
+		t.Fatalf("measured %+v, document at (%.2f, %.2f) on page %d", r, x, y, pdf.PageNo())
+	}
+	if math.Abs(r.Height-single.Height) > 1e-9 {
+		t.Fatalf("height %.2f with page breaks, %.2f without", r.Height, single.Height)
+	}
+}
+
+// ExampleFpdf_DrawSVG demonstrates the drawing of SVG images with groups,
+// transformations, gradients, transparency and text
+func ExampleFpdf_DrawSVG() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+	pdf.SetFont("Helvetica", "", 12)
+	svg := []byte(`<svg xmlns="http://www.w3.org/2000/svg" width="400" height="300" viewBox="0 0 200 150">
+  <defs>
+    <linearGradient id="sky" x1="0" y1="0" x2="0" y2="1">
+      <stop offset="0" stop-color="#1e3c72"/>
+      <stop offset="0.6" stop-color="#2a5298"/>
+      <stop offset="1" stop-color="#f6d365"/>
+    </linearGradient>
+    <radialGradient id="sun" cx="50%" cy="50%" r="50%">
+      <stop offset="0" stop-color="#fff7b0"/>
+      <stop offset="1" stop-color="#ffb300"/>
+    </radialGradient>
+  </defs>
+  <rect width="200" height="150" fill="url(#sky)"/>
+  <circle cx="150" cy="40" r="18" fill="url(#sun)"/>
+  <path d="M0 150 L0 110 Q40 80 80 105 T160 100 Q185 95 200 105 L200 150 Z" fill="#2e7d32"/>
+  <g transform="translate(40 95) rotate(-10)" stroke="#4e342e" stroke-width="2">
+    <rect x="-10" y="-20" width="20" height="20" fill="#8d6e63"/>
+    <polygon points="-14,-20 0,-32 14,-20" fill="#c62828" stroke-linejoin="round"/>
+  </g>
+  <g fill="white" fill-opacity="0.8">
+    <ellipse cx="60" cy="30" rx="20" ry="7"/>
+    <ellipse cx="75" cy="26" rx="14" ry="6"/>
+  </g>
+  <path d="M110 130 a20 10 0 1 1 40 0" fill="none" stroke="#ffeb3b" stroke-width="1.5"
+    stroke-dasharray="4 2"/>
+  <text x="100" y="140" font-family="Times, serif" font-weight="bold" font-size="12"
+    text-anchor="middle" fill="#fff">Landscape <tspan fill="#ffeb3b" font-style="italic">in SVG</tspan></text>
+</svg>`)
+	pdf.DrawSVG(svg, 20, 20, 170, 0)
+	pdf.SetY(160)
+	pdf.Cell(0, 10, "The same image at half size, and a badge at its own size:")
+	pdf.DrawSVG(svg, 20, 175, 85, 0)
+	badge, err := ioutil.ReadFile(example.ImageFile("doc.svg"))
+	if err == nil {
+		pdf.DrawSVG(badge, 120, 175, 0, 0)
+	}
+	pdf.SetError(err)
+	fileStr := example.Filename("Fpdf_DrawSVG")
+	err = pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_DrawSVG.pdf
+}
+
+// TestDrawSVG checks the operators, shadings and graphics states of drawn
+// SVG images, and the errors of invalid images
+func TestDrawSVG(t *testing.T) {
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.SetCompression(false)
+	pdf.AddPage()
+	pdf.SetFont("Courier", "", 10)
+	pdf.DrawSVG([]byte(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100 50">
+  <linearGradient id="g"><stop offset="0" stop-color="red"/><stop offset=".5" stop-color="lime"/>
+    <stop offset="1" stop-color="blue"/></linearGradient>
+  <rect width="100" height="50" style="fill:url(#g)"/>
+  <circle cx="25" cy="25" r="10" fill="rgb(0,0,255)" opacity="0.5" stroke="black"/>
+  <text x="10" y="40" font-family="Times" font-size="8">Text</text>
+</svg>`), 100, 100, 200, 0)
+	if err := pdf.Error(); err != nil {
+		t.Fatal(err)
+	}
+	if size, _ := pdf.GetFontSize(); size != 10 {
+		t.Fatalf("font size of document changed to %.2f", size)
+	}
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, s := range []string{
+		// The viewBox is scaled to 200 by 100 points at (100, 100)
+		"q 2.00000 0 0 -2.00000 100.00000",
+		"0 0 100.00000 50.00000 re W n",
+		"/FunctionType 3 /Domain [0.0 1.0]",
+		"/Bounds [0.50000]",
+		"/Sh1 sh",
+		"/GS1 gs",
+		"/ca 0.500 /CA 0.500",
+		"35.00000 25.00000 m",
+		"\nB\n",
+		"/BaseFont /Times-Roman",
+		"1 0 0 -1 10.00000 40.00000 Tm (Text) Tj",
+	} {
+		if !strings.Contains(out, s) {
+			t.Fatalf("%q not found in output", s)
+		}
+	}
+	for _, svg := range []string{
+		`<svg viewBox="0 0 10 10"><rect width="10" height="10">`,
+		`<html></html>`,
+		`<svg><rect width="10" height="10"/></svg>`,
+	} {
+		pdf = gofpdf.New("P", "mm", "A4", "")
+		pdf.AddPage()
+		pdf.DrawSVG([]byte(svg), 10, 10, 50, 0)
+		if pdf.Error() == nil {
+			t.Fatalf("no error for %s", svg)
+		}
+	}
+	pdf = gofpdf.New("P", "mm", "A4", "")
+	pdf.DrawSVG([]byte(`<svg viewBox="0 0 10 10"/>`), 10, 10, 50, 0)
+	if pdf.Error() == nil {
+		t.Fatal("no error for SVG image drawn before a page is added")
+	}
+}
+
+// ExampleFpdf_SetFillGradient demonstrates gradients with several colors,
+// in the RGB, CMYK and gray color spaces, used to fill and stroke shapes.
+func ExampleFpdf_SetFillGradient() {
+	pdf := gofpdf.New("", "", "", "")
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.AddPage()
+	pdf.SetFillGradient(gofpdf.Gradient{
+		X1: 20, Y1: 0, X2: 190, Y2: 0,
+		Stops: []gofpdf.GradientStop{
+			{Offset: 0, Color: gofpdf.GradientRGB(228, 3, 3)},
+			{Offset: 0.2, Color: gofpdf.GradientRGB(255, 140, 0)},
+			{Offset: 0.4, Color: gofpdf.GradientRGB(255, 237, 0)},
+			{Offset: 0.6, Color: gofpdf.GradientRGB(0, 128, 38)},
+			{Offset: 0.8, Color: gofpdf.GradientRGB(0, 77, 255)},
+			{Offset: 1, Color: gofpdf.GradientRGB(117, 7, 135)},
+		},
+	})
+	pdf.Rect(20, 20, 170, 40, "F")
+	pdf.SetFillGradient(gofpdf.Gradient{
+		Radial: true, X1: 50, Y1: 100, R1: 5, X2: 60, Y2: 110, R2: 30,
+		Stops: []gofpdf.GradientStop{
+			{Offset: 0, Color: gofpdf.GradientCMYK(0, 0, 0, 0)},
+			{Offset: 0.5, Color: gofpdf.GradientCMYK(100, 0, 0, 0)},
+			{Offset: 1, Color: gofpdf.GradientCMYK(100, 60, 0, 20)},
+		},
+		ExtendEnd: true,
+	})
+	pdf.Circle(60, 110, 30, "F")
+	pdf.SetLineWidth(4)
+	pdf.SetDrawGradient(gofpdf.Gradient{
+		X1: 120, Y1: 80, X2: 180, Y2: 140,
+		Stops: []gofpdf.GradientStop{
+			{Offset: 0, Color: gofpdf.GradientGray(0)},
+			{Offset: 1, Color: gofpdf.GradientGray(220)},
+		},
+		ExtendStart: true, ExtendEnd: true,
+	})
+	pdf.Rect(120, 80, 60, 60, "D")
+	pdf.SetDrawColor(0, 0, 0)
+	pdf.SetFillColor(0, 0, 0)
+	fileStr := example.Filename("Fpdf_SetFillGradient")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_SetFillGradient.pdf
+}
+
+// TestSetFillGradient verifies the shadings and patterns of gradients with
+// several colors, and that invalid gradients set an error.
+func TestSetFillGradient(t *testing.T) {
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.AddPage()
+	pdf.SetFillGradient(gofpdf.Gradient{
+		X1: 10, Y1: 20, X2: 110, Y2: 20,
+		Stops: []gofpdf.GradientStop{
+			{Offset: 0.75, Color: gofpdf.GradientCMYK(0, 0, 0, 100)},
+			{Offset: 0.25, Color: gofpdf.GradientCMYK(100, 0, 0, 0)},
+		},
+		ExtendEnd: true,
+	})
+	pdf.Rect(10, 10, 100, 20, "F")
+	pdf.SetDrawGradient(gofpdf.Gradient{
+		Radial: true, X1: 50, Y1: 50, R1: 5, X2: 50, Y2: 50, R2: 25,
+		Stops: []gofpdf.GradientStop{
+			{Offset: 0, Color: gofpdf.GradientGray(0)},
+			{Offset: 1, Color: gofpdf.GradientGray(255)},
+		},
+	})
+	pdf.Circle(50, 50, 20, "D")
+	var buf bytes.Buffer
+	err := pdf.Output(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		"/Pattern cs /P1 scn\n", "/Pattern CS /P2 SCN\n",
+		"/Pattern <<\n/P1 ", "/PatternType 2 /Shading ",
+		"/FunctionType 3 /Domain [0.0 1.0] /Functions [", "/Bounds [0.25000 0.75000]",
+		"/C0 [1.000 0.000 0.000 0.000] /C1 [0.000 0.000 0.000 1.000]",
+		"/ShadingType 2 /ColorSpace /DeviceCMYK",
+		"/Coords [10.00000 821.89000 110.00000 821.89000] /Function",
+		"/Extend [false true]",
+		"/ShadingType 3 /ColorSpace /DeviceGray",
+		"/Coords [50.00000 791.89000 5.00000 50.00000 791.89000 25.00000]",
+		"/C0 [0.000] /C1 [1.000]",
+	} {
+		if !bytes.Contains(buf.Bytes(), []byte(s)) {
+			t.Fatalf("%q not found in document", s)
+		}
+	}
+
+	for _, gr := range []gofpdf.Gradient{
+		{Stops: []gofpdf.GradientStop{{Color: gofpdf.GradientGray(0)}}},
+		{Stops: []gofpdf.GradientStop{{Color: gofpdf.GradientGray(0)}, {Offset: 1, Color: gofpdf.GradientRGB(0, 0, 0)}}},
+	} {
+		pdf = gofpdf.New("P", "pt", "A4", "")
+		pdf.AddPage()
+		pdf.SetFillGradient(gr)
+		if pdf.Ok() {
+			t.Fatalf("no error for gradient %+v", gr)
+		}
+	}
+}
+
+// ExampleFpdf_CreatePattern demonstrates shapes and cells filled with
+// tiling patterns.
+func ExampleFpdf_CreatePattern() {
+	pdf := gofpdf.New("", "", "", "")
+	pdf.SetFont("Helvetica", "", 12)
+	hatch := pdf.CreatePattern(4, 4, func(p *gofpdf.PatternDrawer) {
+		p.SetDrawColor(40, 80, 160)
+		p.SetLineWidth(0.3)
+		p.Line(0, 4, 4, 0)
+	})
+	dots := pdf.CreatePattern(5, 5, func(p *gofpdf.PatternDrawer) {
+		p.SetFillColor(255, 240, 200)
+		p.Rect(0, 0, 5, 5, "F")
+		p.SetFillColor(220, 120, 40)
+		p.Circle(2.5, 2.5, 1, "F")
+	})
+	logo := pdf.CreatePattern(30, 12, func(p *gofpdf.PatternDrawer) {
+		p.SetTextColor(180, 180, 180)
+		p.SetFontSize(9)
+		p.Text(2, 8, "gofpdf")
+	})
+	pdf.AddPage()
+	pdf.SetFillPattern(hatch)
+	pdf.Rect(20, 20, 80, 50, "FD")
+	pdf.SetFillPattern(dots)
+	pdf.Circle(150, 45, 25, "FD")
+	pdf.SetFillPattern(logo)
+	pdf.SetXY(20, 90)
+	pdf.CellFormat(170, 30, "Cells can be filled with patterns", "1", 1, "C", true, 0, "")
+	fileStr := example.Filename("Fpdf_CreatePattern")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_CreatePattern.pdf
+}
+
+// TestCreatePattern verifies the content and resources of tiling patterns,
+// and that the state of the document is restored after their cell is drawn.
+func TestCreatePattern(t *testing.T) {
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.AddPage()
+	pdf.SetXY(50, 60)
+	pdf.SetFillColor(10, 20, 30)
+	id := pdf.CreatePattern(10, 20, func(p *gofpdf.PatternDrawer) {
+		p.SetFillColor(255, 0, 0)
+		p.SetLineWidth(3)
+		p.SetFont("Times", "B", 20)
+		p.Rect(0, 0, 5, 5, "F")
+		p.AddPage()
+	})
+	if id != 0 || pdf.Ok() {
+		t.Fatalf("no error for pattern that adds a page")
+	}
+
+	pdf = gofpdf.New("P", "pt", "A4", "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.AddPage()
+	pdf.SetXY(50, 60)
+	pdf.SetFillColor(10, 20, 30)
+	lineWidth := pdf.GetLineWidth()
+	id = pdf.CreatePattern(10, 20, func(p *gofpdf.PatternDrawer) {
+		p.SetFillColor(255, 0, 0)
+		p.SetLineWidth(3)
+		p.SetFont("Times", "B", 20)
+		p.Rect(0, 0, 5, 5, "F")
+	})
+	if id != 1 {
+		t.Fatalf("pattern is %d", id)
+	}
+	if x, y := pdf.GetXY(); x != 50 || y != 60 {
+		t.Fatalf("position not restored: %.2f %.2f", x, y)
+	}
+	if r, g, b := pdf.GetFillColor(); r != 10 || g != 20 || b != 30 {
+		t.Fatalf("fill color not restored: %d %d %d", r, g, b)
+	}
+	if pdf.GetLineWidth() != lineWidth {
+		t.Fatalf("line width not restored: %.2f", pdf.GetLineWidth())
+	}
+	if pt, _ := pdf.GetFontSize(); pt != 10 {
+		t.Fatalf("font not restored: %.2f", pt)
+	}
+	pdf.SetFillPattern(id)
+	pdf.Rect(0, 0, 100, 100, "F")
+	var buf bytes.Buffer
+	err := pdf.Output(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		"/Pattern cs /TP1 scn\n0.00 841.89 100.00 -100.00 re f",
+		"/Type /Pattern /PatternType 1 /PaintType 1 /TilingType 1\n" +
+			"/BBox [0 0 10.00000 20.00000] /XStep 10.00000 /YStep 20.00000 /Resources 2 0 R",
+		"1.000 0.000 0.000 rg\n3.00 w\nBT /F",
+		" 20.00 Tf ET\n0.00 20.00 5.00 -5.00 re f\n",
+		"/Pattern <<\n/TP1 ",
+		"/BaseFont /Times-Bold",
+	} {
+		if !bytes.Contains(buf.Bytes(), []byte(s)) {
+			t.Fatalf("%q not found in document", s)
+		}
+	}
+
+	pdf = gofpdf.New("P", "pt", "A4", "")
+	pdf.AddPage()
+	pdf.SetFillPattern(2)
+	if pdf.Ok() {
+		t.Fatalf("no error for pattern that does not exist")
+	}
+}
+
+// ExampleFpdf_NewPath demonstrates paths with several subpaths, filled with
+// the nonzero winding number and even-odd rules, and used to clip.
+func ExampleFpdf_NewPath() {
+	pdf := gofpdf.New("", "", "", "")
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.AddPage()
+	pdf.SetFillColor(120, 160, 220)
+	pdf.SetLineWidth(0.8)
+	star := func(cx, cy float64) *gofpdf.Path {
+		path := pdf.NewPath()
+		for j := 0; j < 5; j++ {
+			a := math.Pi/2 + float64(j)*4*math.Pi/5
+			path.LineTo(cx+30*math.Cos(a), cy-30*math.Sin(a))
+		}
+		return path.Close()
+	}
+	pdf.Text(20, 20, "Nonzero winding number")
+	star(50, 60).FillStroke()
+	pdf.Text(110, 20, "Even-odd")
+	star(140, 60).FillStrokeEvenOdd()
+	// A ring is a circle with a hole, whichever direction its subpaths go
+	ring := pdf.NewPath().
+		ArcTo(50, 140, 30, 30, 0, 0, 360).Close().
+		ArcTo(50, 140, 15, 15, 0, 0, 360).Close()
+	ring.FillEvenOdd()
+	// Open subpaths are left open when stroked
+	pdf.NewPath().
+		MoveTo(100, 160).CurveTo(120, 100, 140, 160).
+		MoveTo(150, 160).CurveBezierCubicTo(160, 100, 180, 200, 190, 120).
+		Stroke()
+	pdf.NewPath().Rect(20, 190, 170, 40).MoveTo(105, 195).LineTo(185, 225).LineTo(25, 225).Close().ClipEvenOdd()
+	pdf.LinearGradient(20, 190, 170, 40, 250, 200, 120, 60, 120, 200, 0, 0, 1, 0)
+	pdf.ClipEnd()
+	fileStr := example.Filename("Fpdf_NewPath")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_NewPath.pdf
+}
+
+// TestNewPath verifies the operators of paths and their arcs.
+func TestNewPath(t *testing.T) {
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.AddPage()
+	pdf.SetXY(30, 40)
+	path := pdf.NewPath().MoveTo(10, 20).LineTo(30, 20).CurveTo(30, 50, 60, 50).Close().Rect(100, 100, 50, 20)
+	path.FillEvenOdd()
+	path.Stroke()
+	pdf.NewPath().ArcTo(100, 100, 10, 20, 90, 0, 180).Clip()
+	pdf.ClipEnd()
+	if x, y := pdf.GetXY(); x != 30 || y != 40 {
+		t.Fatalf("position changed: %.2f %.2f", x, y)
+	}
+	var buf bytes.Buffer
+	err := pdf.Output(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	segs := "10.00 821.89 m\n30.00 821.89 l\n30.00000 801.89000 40.00000 791.89000 60.00000 791.89000 c\nh\n" +
+		"100.00 741.89 50.00 -20.00 re\n"
+	for _, s := range []string{
+		segs + "f*\n", segs + "S\n",
+		// The arc is rotated to start at the top of the ellipse
+		"q\n100.00 751.89 m\n",
+		"86.17015 749.91300 82.67949 746.89000 c\n",
+		"100.00000 731.89000 c\nW n\nQ\n",
+	} {
+		if !bytes.Contains(buf.Bytes(), []byte(s)) {
+			t.Fatalf("%q not found in document", s)
+		}
+	}
+}
+
+// ExampleFpdf_ClipPath demonstrates a headline in a UTF-8 font filled with an
+// image, and an image cut out with a path.
+func ExampleFpdf_ClipPath() {
+	pdf := gofpdf.New("", "", "", "")
+	pdf.AddUTF8Font("dejavu", "B", example.FontFile("DejaVuSansCondensed-Bold.ttf"))
+	pdf.AddPage()
+	pdf.SetFont("dejavu", "B", 64)
+	pdf.ClipText(15, 45, "Göteborg", false)
+	pdf.Image(example.ImageFile("sweden.png"), 15, 15, 180, 0, false, "", 0, "")
+	pdf.ClipEnd()
+	// A keyhole: a circle on top of a trapezoid
+	keyhole := pdf.NewPath().
+		ArcTo(105, 100, 25, 25, 0, 0, 360).Close().
+		MoveTo(90, 110).LineTo(120, 110).LineTo(135, 180).LineTo(75, 180).Close()
+	pdf.ClipPath(keyhole, true)
+	pdf.Image(example.ImageFile("golang-gopher.png"), 55, 70, 100, 0, false, "", 0, "")
+	pdf.ClipEnd()
+	fileStr := example.Filename("Fpdf_ClipPath")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_ClipPath.pdf
+}
+
+// TestClipNesting verifies that clipping operations and transformations end
+// in the reverse order of their start, on the page where they began.
+func TestClipNesting(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+	pdf.ClipRect(10, 10, 50, 50, false)
+	pdf.TransformBegin()
+	pdf.TransformRotate(30, 20, 20)
+	pdf.ClipPath(pdf.NewPath().Rect(20, 20, 10, 10), false)
+	pdf.ClipEnd()
+	pdf.TransformEnd()
+	pdf.ClipEnd()
+	if err := pdf.Output(ioutil.Discard); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, fnc := range map[string]func(pdf *gofpdf.Fpdf){
+		"clip ends before transformation": func(pdf *gofpdf.Fpdf) {
+			pdf.ClipRect(10, 10, 50, 50, false)
+			pdf.TransformBegin()
+			pdf.ClipEnd()
+		},
+		"transformation ends before clip": func(pdf *gofpdf.Fpdf) {
+			pdf.TransformBegin()
+			pdf.ClipText(10, 10, "clip", false)
+			pdf.TransformEnd()
+		},
+		"clip ends on another page": func(pdf *gofpdf.Fpdf) {
+			pdf.ClipCircle(50, 50, 20, false)
+			pdf.AddPage()
+			pdf.ClipEnd()
+		},
+	} {
+		pdf = gofpdf.New("P", "mm", "A4", "")
+		pdf.SetFont("Helvetica", "", 12)
+		pdf.AddPage()
+		fnc(pdf)
+		if pdf.Ok() {
+			t.Fatalf("no error when %s", name)
+		}
+	}
+}
+
+// ExampleFpdf_BeginSoftMask demonstrates an image that fades out gradually
+// and an image with feathered edges.
+func ExampleFpdf_BeginSoftMask() {
+	pdf := gofpdf.New("", "", "", "")
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.AddPage()
+	// The mask is white at the top and black at the bottom of the image
+	pdf.BeginSoftMask()
+	pdf.LinearGradient(15, 15, 180, 90, 255, 255, 255, 0, 0, 0, 0, 0, 0, 1)
+	fade := pdf.EndSoftMask()
+	pdf.ApplySoftMask(fade, "Luminosity")
+	pdf.Image(example.ImageFile("sweden.png"), 15, 15, 180, 90, false, "", 0, "")
+	pdf.ApplySoftMask(0, "")
+	// The mask is white in the middle and black at the edges of the image
+	pdf.BeginSoftMask()
+	pdf.RadialGradient(55, 120, 100, 100, 255, 255, 255, 0, 0, 0, 0.5, 0.5, 0.5, 0.5, 0.5)
+	feather := pdf.EndSoftMask()
+	pdf.ApplySoftMask(feather, "")
+	pdf.Image(example.ImageFile("golang-gopher.png"), 55, 120, 100, 100, false, "", 0, "")
+	pdf.ApplySoftMask(0, "")
+	pdf.Text(15, 240, "Content drawn after the mask is removed is not masked.")
+	fileStr := example.Filename("Fpdf_BeginSoftMask")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_BeginSoftMask.pdf
+}
+
+// TestSoftMask verifies the objects written for soft masks and the errors of
+// misused masks.
+func TestSoftMask(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+	pdf.SetXY(20, 30)
+	pdf.BeginSoftMask()
+	pdf.SetFillColor(255, 255, 255)
+	pdf.Rect(10, 10, 50, 50, "F")
+	mask := pdf.EndSoftMask()
+	if x, y := pdf.GetXY(); x != 20 || y != 30 {
+		t.Fatalf("position is (%.2f, %.2f) after the soft mask", x, y)
+	}
+	pdf.ApplySoftMask(mask, "luminosity")
+	pdf.Rect(0, 0, 100, 100, "F")
+	pdf.ApplySoftMask(mask, "Alpha")
+	pdf.ApplySoftMask(mask, "")
+	pdf.ApplySoftMask(0, "")
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"%PDF-1.4", "/Group <</S /Transparency /CS /DeviceRGB>>",
+		"/S /Luminosity /G", "/S /Alpha /G", "/SMask /None", "/SM1 gs", "/SM2 gs", "/SM3 gs"} {
+		if !bytes.Contains(buf.Bytes(), []byte(s)) {
+			t.Fatalf("%q not found in document", s)
+		}
+	}
+	if bytes.Contains(buf.Bytes(), []byte("/SM4")) {
+		t.Fatalf("graphics state of the soft mask is not reused")
+	}
+
+	for name, fnc := range map[string]func(pdf *gofpdf.Fpdf){
+		"mask begun twice": func(pdf *gofpdf.Fpdf) {
+			pdf.BeginSoftMask()
+			pdf.BeginSoftMask()
+		},
+		"mask ended without beginning": func(pdf *gofpdf.Fpdf) {
+			pdf.EndSoftMask()
+		},
+		"mask not ended": func(pdf *gofpdf.Fpdf) {
+			pdf.BeginSoftMask()
+			pdf.Output(ioutil.Discard)
+		},
+		"page added in mask": func(pdf *gofpdf.Fpdf) {
+			pdf.BeginSoftMask()
+			pdf.AddPage()
+			pdf.EndSoftMask()
+		},
+		"unknown mask type": func(pdf *gofpdf.Fpdf) {
+			pdf.BeginSoftMask()
+			pdf.ApplySoftMask(pdf.EndSoftMask(), "Color")
+		},
+		"unknown mask": func(pdf *gofpdf.Fpdf) {
+			pdf.ApplySoftMask(1, "")
+		},
+	} {
+		pdf = gofpdf.New("P", "mm", "A4", "")
+		pdf.AddPage()
+		fnc(pdf)
+		if pdf.Ok() {
+			t.Fatalf("no error when %s", name)
+		}
+	}
+}
+
+// ExampleFpdf_BeginTransparencyGroup demonstrates overlapping circles drawn
+// at half opacity one by one and as a group, with the blend modes of groups
+// and a knockout group.
+func ExampleFpdf_BeginTransparencyGroup() {
+	pdf := gofpdf.New("", "", "", "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.AddPage()
+	circles := func(x, y float64) {
+		pdf.SetFillColor(220, 40, 40)
+		pdf.Circle(x+15, y+15, 15, "F")
+		pdf.SetFillColor(40, 160, 40)
+		pdf.Circle(x+35, y+15, 15, "F")
+		pdf.SetFillColor(40, 40, 220)
+		pdf.Circle(x+25, y+32, 15, "F")
+	}
+	pdf.SetFillColor(240, 200, 60)
+	pdf.Rect(10, 20, 190, 120, "F")
+	pdf.Text(15, 30, "Shapes at half opacity")
+	pdf.SetAlpha(0.5, "Normal")
+	circles(15, 35)
+	pdf.SetAlpha(1, "Normal")
+	pdf.Text(80, 30, "Group at half opacity")
+	pdf.BeginTransparencyGroup(false, false)
+	circles(80, 35)
+	pdf.EndTransparencyGroup(0.5, "Normal")
+	pdf.Text(145, 30, "Knockout group")
+	pdf.BeginTransparencyGroup(false, true)
+	pdf.SetAlpha(0.6, "Normal")
+	circles(145, 35)
+	pdf.EndTransparencyGroup(1, "Normal")
+	for j, mode := range []string{"Multiply", "Screen", "Difference"} {
+		x := 15 + float64(j)*65
+		pdf.Text(x, 95, "Group with "+mode)
+		pdf.BeginTransparencyGroup(true, false)
+		circles(x, 100)
+		pdf.EndTransparencyGroup(1, mode)
+	}
+	fileStr := example.Filename("Fpdf_BeginTransparencyGroup")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_BeginTransparencyGroup.pdf
+}
+
+// TestTransparencyGroup verifies the objects written for transparency groups
+// and the errors of misused groups.
+func TestTransparencyGroup(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+	pdf.SetAlpha(0.5, "")
+	pdf.BeginTransparencyGroup(true, true)
+	pdf.Rect(10, 10, 50, 50, "F")
+	pdf.BeginTransparencyGroup(false, false)
+	pdf.SetAlpha(0.25, "Multiply")
+	pdf.Rect(20, 20, 50, 50, "F")
+	pdf.EndTransparencyGroup(1, "Screen")
+	if alpha, mode := pdf.GetAlpha(); alpha != 0.5 || mode != "" {
+		t.Fatalf("alpha is %.2f %q after the group", alpha, mode)
+	}
+	pdf.EndTransparencyGroup(0.75, "")
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"%PDF-1.4", "/Group <</S /Transparency /I true /K true>>",
+		"/Group <</S /Transparency /I false /K false>>", "/TG1 ", "/TG2 ",
+		"/BM /Screen", "/BM /Normal", "q /GS3 gs /TG2 Do Q", "q /GS4 gs /TG1 Do Q"} {
+		if !bytes.Contains(buf.Bytes(), []byte(s)) {
+			t.Fatalf("%q not found in document", s)
+		}
+	}
+	if bytes.Contains(buf.Bytes(), []byte("/BM /\n")) || bytes.Contains(buf.Bytes(), []byte("/BM />>")) {
+		t.Fatalf("graphics state without blend mode")
+	}
+
+	for name, fnc := range map[string]func(pdf *gofpdf.Fpdf){
+		"group ended without beginning": func(pdf *gofpdf.Fpdf) {
+			pdf.EndTransparencyGroup(1, "")
+		},
+		"group not ended": func(pdf *gofpdf.Fpdf) {
+			pdf.BeginTransparencyGroup(false, false)
+			pdf.Output(ioutil.Discard)
+		},
+		"group ended within soft mask": func(pdf *gofpdf.Fpdf) {
+			pdf.BeginTransparencyGroup(false, false)
+			pdf.BeginSoftMask()
+			pdf.EndTransparencyGroup(1, "")
+		},
+		"soft mask ended within group": func(pdf *gofpdf.Fpdf) {
+			pdf.BeginSoftMask()
+			pdf.BeginTransparencyGroup(false, false)
+			pdf.EndSoftMask()
+		},
+		"unknown blend mode": func(pdf *gofpdf.Fpdf) {
+			pdf.BeginTransparencyGroup(false, false)
+			pdf.EndTransparencyGroup(1, "Plus")
+		},
+		"alpha out of range": func(pdf *gofpdf.Fpdf) {
+			pdf.BeginTransparencyGroup(false, false)
+			pdf.EndTransparencyGroup(1.5, "")
+		},
+	} {
+		pdf = gofpdf.New("P", "mm", "A4", "")
+		pdf.AddPage()
+		fnc(pdf)
+		if pdf.Ok() {
+			t.Fatalf("no error when %s", name)
+		}
+	}
+}
+
+// ExampleFpdf_SaveGState demonstrates a helper function that changes the
+// drawing settings without leaving them changed, the miter limit, and paths
+// with their own line settings.
+func ExampleFpdf_SaveGState() {
+	pdf := gofpdf.New("", "", "", "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.AddPage()
+	// badge draws a red, dashed star; its settings do not leak to its caller
+	badge := func(x, y float64) {
+		pdf.SaveGState()
+		pdf.SetDrawColor(200, 0, 0)
+		pdf.SetFillColor(255, 230, 230)
+		pdf.SetLineWidth(1.5)
+		pdf.SetLineJoinStyle("round")
+		pdf.SetDashPattern([]float64{4, 2}, 0)
+		var pts []gofpdf.PointType
+		for j := 0; j < 10; j++ {
+			r := 20.0
+			if j%2 == 1 {
+				r = 8
+			}
+			a := float64(j) * math.Pi / 5
+			pts = append(pts, gofpdf.PointType{X: x + r*math.Sin(a), Y: y - r*math.Cos(a)})
+		}
+		pdf.Polygon(pts, "FD")
+		pdf.RestoreGState()
+	}
+	badge(40, 45)
+	pdf.Rect(70, 25, 40, 40, "D")
+	pdf.Text(70, 72, "Drawn with the settings of the document")
+	// Sharp joins are beveled when the miter would be too long
+	pdf.SetLineWidth(4)
+	for j, limit := range []float64{10, 2} {
+		x := 20 + float64(j)*90
+		pdf.SetMiterLimit(limit)
+		pdf.Polygon([]gofpdf.PointType{{X: x, Y: 120}, {X: x + 30, Y: 90}, {X: x + 60, Y: 120}, {X: x + 30, Y: 105}}, "D")
+		pdf.Text(x, 132, fmt.Sprintf("Miter limit %.0f", limit))
+	}
+	pdf.SetMiterLimit(10)
+	pdf.SetLineWidth(0.2)
+	// Each path has its own line settings
+	pdf.NewPath().MoveTo(20, 160).CurveTo(60, 130, 100, 160).
+		SetLineWidth(3).SetLineCapStyle("round").SetDashPattern([]float64{0, 6}, 0).Stroke()
+	pdf.NewPath().MoveTo(110, 160).LineTo(130, 140).LineTo(150, 160).LineTo(170, 140).LineTo(190, 160).
+		SetLineWidth(3).SetLineJoinStyle("bevel").Stroke()
+	pdf.Line(20, 175, 190, 175)
+	fileStr := example.Filename("Fpdf_SaveGState")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_SaveGState.pdf
+}
+
+// TestSaveGState verifies that a saved graphics state is restored in the
+// document and in PDF, and the errors of misused graphics states.
+func TestSaveGState(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.AddPage()
+	lineWidth := pdf.GetLineWidth()
+	pdf.SaveGState()
+	pdf.SetDrawColor(255, 0, 0)
+	pdf.SetLineWidth(2)
+	pdf.SetMiterLimit(3)
+	pdf.SetFontSize(20)
+	pdf.SetAlpha(0.5, "Multiply")
+	pdf.ClipRect(10, 10, 50, 50, false)
+	pdf.ClipEnd()
+	pdf.RestoreGState()
+	if r, g, b := pdf.GetDrawColor(); r != 0 || g != 0 || b != 0 {
+		t.Fatalf("draw color is %d %d %d after restoring", r, g, b)
+	}
+	if pdf.GetLineWidth() != lineWidth || pdf.GetMiterLimit() != 10 {
+		t.Fatalf("line width %.2f and miter limit %.2f after restoring", pdf.GetLineWidth(), pdf.GetMiterLimit())
+	}
+	if ptSize, _ := pdf.GetFontSize(); ptSize != 12 {
+		t.Fatalf("font size is %.2f after restoring", ptSize)
+	}
+	if alpha, _ := pdf.GetAlpha(); alpha != 1 {
+		t.Fatalf("alpha is %.2f after restoring", alpha)
+	}
+	pdf.NewPath().MoveTo(10, 10).LineTo(50, 50).SetLineWidth(1).SetMiterLimit(4).Stroke()
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"\nq\n", "3.00 M", "\nQ\n", "q\n2.83 w\n4.00 M\n28.35 813.54 m"} {
+		if !bytes.Contains(buf.Bytes(), []byte(s)) {
+			t.Fatalf("%q not found in document", s)
+		}
+	}
+
+	for name, fnc := range map[string]func(pdf *gofpdf.Fpdf){
+		"restored without saving": func(pdf *gofpdf.Fpdf) {
+			pdf.RestoreGState()
+		},
+		"restored before clip ends": func(pdf *gofpdf.Fpdf) {
+			pdf.SaveGState()
+			pdf.ClipRect(10, 10, 50, 50, false)
+			pdf.RestoreGState()
+		},
+		"transformation ends before restoring": func(pdf *gofpdf.Fpdf) {
+			pdf.TransformBegin()
+			pdf.SaveGState()
+			pdf.TransformEnd()
+		},
+		"restored on another page": func(pdf *gofpdf.Fpdf) {
+			pdf.SaveGState()
+			pdf.AddPage()
+			pdf.RestoreGState()
+		},
+		"not restored": func(pdf *gofpdf.Fpdf) {
+			pdf.SaveGState()
+			pdf.Output(ioutil.Discard)
+		},
+		"miter limit less than 1": func(pdf *gofpdf.Fpdf) {
+			pdf.SetMiterLimit(0.5)
+		},
+		"path miter limit less than 1": func(pdf *gofpdf.Fpdf) {
+			pdf.NewPath().SetMiterLimit(0)
+		},
+	} {
+		pdf = gofpdf.New("P", "mm", "A4", "")
+		pdf.AddPage()
+		fnc(pdf)
+		if pdf.Ok() {
+			t.Fatalf("no error when %s", name)
+		}
+	}
+}
+
+// ExampleFpdf_ImageTransformed demonstrates images and a template placed
+// with matrices that combine scaling, rotation and skewing.
+func ExampleFpdf_ImageTransformed() {
+	pdf := gofpdf.New("", "", "", "")
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.AddPage()
+	image := example.ImageFile("golang-gopher.png")
+	// A 40 by 40 image rotated by 30 degrees around its center (40, 50)
+	sin, cos := math.Sincos(30 * math.Pi / 180)
+	pdf.ImageTransformed(image, gofpdf.TransformMatrix{
+		A: 40 * cos, B: 40 * sin, C: -40 * sin, D: 40 * cos,
+		E: 40 - 20*cos + 20*sin, F: 50 - 20*sin - 20*cos,
+	})
+	// A 40 by 40 image skewed to the right and mirrored
+	pdf.ImageTransformed(image, gofpdf.TransformMatrix{A: -40, C: 20, D: 40, E: 130, F: 30})
+	// A template of a card, scaled down by half and rotated at three angles
+	card := pdf.CreateTemplateCustom(gofpdf.PointType{}, gofpdf.SizeType{Wd: 80, Ht: 50}, func(tpl *gofpdf.Tpl) {
+		tpl.SetFillColor(230, 240, 255)
+		tpl.Rect(0, 0, 80, 50, "FD")
+		tpl.SetFont("Helvetica", "B", 16)
+		tpl.Text(10, 28, "Transformed")
+	})
+	for j, deg := range []float64{-20, 0, 20} {
+		sin, cos := math.Sincos(deg * math.Pi / 180)
+		pdf.UseTemplateTransformed(card, gofpdf.TransformMatrix{
+			A: 0.5 * cos, B: 0.5 * sin, C: -0.5 * sin, D: 0.5 * cos,
+			E: 30 + float64(j)*55, F: 120,
+		})
+	}
+	fileStr := example.Filename("Fpdf_ImageTransformed")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_ImageTransformed.pdf
+}
+
+// TestImageTransformed verifies that images and templates placed with a
+// matrix that only scales and translates are output as by Image() and
+// UseTemplateScaled().
+func TestImageTransformed(t *testing.T) {
+	content := func(fnc func(pdf *gofpdf.Fpdf, tpl gofpdf.Template)) string {
+		pdf := gofpdf.New("P", "mm", "A4", "")
+		tpl := pdf.CreateTemplateCustom(gofpdf.PointType{}, gofpdf.SizeType{Wd: 40, Ht: 20}, func(tpl *gofpdf.Tpl) {
+			tpl.Rect(0, 0, 40, 20, "D")
+		})
+		pdf.AddPage()
+		fnc(pdf, tpl)
+		var buf bytes.Buffer
+		if err := pdf.Output(&buf); err != nil {
+			t.Fatal(err)
+		}
+		s := buf.String()
+		start := strings.Index(s, "\nq ")
+		return s[start+1 : start+strings.Index(s[start:], " Q")]
+	}
+	image := example.ImageFile("logo.png")
+	want := content(func(pdf *gofpdf.Fpdf, tpl gofpdf.Template) {
+		pdf.Image(image, 10, 20, 60, 30, false, "", 0, "")
+	})
+	got := content(func(pdf *gofpdf.Fpdf, tpl gofpdf.Template) {
+		pdf.ImageTransformed(image, gofpdf.TransformMatrix{A: 60, D: 30, E: 10, F: 20})
+	})
+	// Image() prints zeros without decimals
+	if want = strings.Replace(want, " 0 0 ", " 0.00000 0.00000 ", 1); got != want {
+		t.Fatalf("image placed with %q instead of %q", got, want)
+	}
+	got = content(func(pdf *gofpdf.Fpdf, tpl gofpdf.Template) {
+		pdf.UseTemplateTransformed(tpl, gofpdf.TransformMatrix{A: 2, D: 1.5, E: 10, F: 20})
+	})
+	// The template is 30 mm high and 20 mm below the top of the page
+	if want = "q 2.00000 0.00000 0.00000 1.50000 28.34646 700.15772 cm /TPL"; !strings.HasPrefix(got, want) {
+		t.Fatalf("template placed with %q instead of %q", got, want)
+	}
+}
+
+// ExampleFpdf_PolylineEx demonstrates the arrowheads, markers and smoothing
+// of a flowchart and a chart drawn with PolylineEx() and PolygonEx().
+func ExampleFpdf_PolylineEx() {
+	pdf := gofpdf.New("", "", "", "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.AddPage()
+	// Flowchart boxes joined by connectors
+	box := func(x, y float64, txt string) {
+		pdf.SetXY(x, y)
+		pdf.CellFormat(40, 12, txt, "1", 0, "C", false, 0, "")
+	}
+	box(20, 20, "Start")
+	box(85, 20, "Process")
+	box(150, 20, "Decide")
+	box(85, 60, "Report")
+	pdf.SetLineWidth(0.4)
+	pdf.PolylineEx([]gofpdf.PointType{{X: 60, Y: 26}, {X: 85, Y: 26}}, gofpdf.LineStyle{Arrow: gofpdf.ArrowEnd})
+	pdf.PolylineEx([]gofpdf.PointType{{X: 125, Y: 26}, {X: 150, Y: 26}},
+		gofpdf.LineStyle{Arrow: gofpdf.ArrowBoth, ArrowShape: gofpdf.ArrowStealth})
+	pdf.PolylineEx([]gofpdf.PointType{{X: 170, Y: 32}, {X: 170, Y: 66}, {X: 125, Y: 66}},
+		gofpdf.LineStyle{Arrow: gofpdf.ArrowEnd, ArrowShape: gofpdf.ArrowOpen, ArrowLength: 4, ArrowWidth: 4})
+	pdf.SetDrawColor(0, 90, 180)
+	pdf.PolylineEx([]gofpdf.PointType{{X: 85, Y: 66}, {X: 50, Y: 60}, {X: 40, Y: 32}},
+		gofpdf.LineStyle{Smooth: true, Arrow: gofpdf.ArrowEnd, ArrowShape: gofpdf.ArrowDiamond})
+	// A smooth chart line through its values, with markers
+	values := []float64{12, 30, 22, 45, 38, 60, 52}
+	var pts []gofpdf.PointType
+	for j, v := range values {
+		pts = append(pts, gofpdf.PointType{X: 20 + float64(j)*25, Y: 160 - v})
+	}
+	pdf.SetDrawColor(0, 0, 0)
+	pdf.Line(20, 160, 175, 160)
+	pdf.SetDrawColor(200, 60, 0)
+	pdf.SetFillColor(255, 255, 255)
+	pdf.PolylineEx(pts, gofpdf.LineStyle{Smooth: true, Marker: gofpdf.MarkerCircle, MarkerSize: 3})
+	// A smooth closed shape through five points
+	pdf.SetFillColor(255, 220, 120)
+	pdf.PolygonEx([]gofpdf.PointType{{X: 60, Y: 190}, {X: 100, Y: 180}, {X: 130, Y: 210},
+		{X: 95, Y: 250}, {X: 50, Y: 230}}, "FD", gofpdf.LineStyle{Smooth: true, Marker: gofpdf.MarkerSquare})
+	fileStr := example.Filename("Fpdf_PolylineEx")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_PolylineEx.pdf
+}
+
+// TestPolylineEx verifies the shortened line and arrowhead of a polyline and
+// the Catmull-Rom curve of a smooth polyline.
+func TestPolylineEx(t *testing.T) {
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.AddPage()
+	pdf.SetDrawColor(255, 0, 0)
+	pdf.PolylineEx([]gofpdf.PointType{{X: 10, Y: 100}, {X: 110, Y: 100}},
+		gofpdf.LineStyle{Arrow: gofpdf.ArrowEnd, ArrowLength: 10, ArrowWidth: 6})
+	pdf.PolylineEx([]gofpdf.PointType{{X: 0, Y: 0}, {X: 10, Y: 10}, {X: 20, Y: 0}}, gofpdf.LineStyle{Smooth: true})
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		// The line ends at the back of the arrowhead, which is filled with the
+		// draw color
+		"10.00 741.89 m\n100.00000 741.89000 l\nS\n",
+		"q 1.000 0.000 0.000 rg\n110.00 741.89 m\n100.00000 738.89000 l\n100.00000 744.89000 l\n110.00000 741.89000 l\nh f Q",
+		// The curve passes through the middle point, with a horizontal tangent
+		"1.66667 840.22333 6.66667 831.89000 10.00000 831.89000 c",
+		"13.33333 831.89000 18.33333 840.22333 20.00000 841.89000 c",
+	} {
+		if !bytes.Contains(buf.Bytes(), []byte(s)) {
+			t.Fatalf("%q not found in document", s)
+		}
+	}
+}
+
+// ExampleFpdf_RoundedRectEx demonstrates rectangles with a different radius
+// at each corner, dashed borders and their own colors.
+func ExampleFpdf_RoundedRectEx() {
+	pdf := gofpdf.New("", "", "", "")
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.AddPage()
+	pdf.RoundedRectEx(20, 20, 70, 40, [4]float64{15, 0, 15, 0}, "FD", gofpdf.BorderStyle{
+		Width: 1, Color: &gofpdf.RGBType{R: 0, G: 90, B: 180}, FillColor: &gofpdf.RGBType{R: 220, G: 235, B: 255},
+	})
+	pdf.RoundedRectEx(110, 20, 70, 40, [4]float64{5, 20, 5, 20}, "D", gofpdf.BorderStyle{
+		Width: 0.8, Color: &gofpdf.RGBType{R: 200, G: 0, B: 0}, Dash: []float64{3, 1.5},
+	})
+	// Radii larger than the rectangle make a pill
+	pdf.RoundedRectEx(20, 80, 160, 20, [4]float64{50, 50, 50, 50}, "F", gofpdf.BorderStyle{
+		FillColor: &gofpdf.RGBType{R: 40, G: 160, B: 80},
+	})
+	// The settings of the document are unchanged
+	pdf.Rect(20, 120, 160, 20, "D")
+	pdf.Text(25, 132, "Drawn with the settings of the document")
+	fileStr := example.Filename("Fpdf_RoundedRectEx")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_RoundedRectEx.pdf
+}
+
+// TestRoundedRectEx verifies the settings and the reduced radii of a rounded
+// rectangle and the error of a negative radius.
+func TestRoundedRectEx(t *testing.T) {
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.AddPage()
+	pdf.RoundedRectEx(10, 10, 100, 20, [4]float64{20, 20, 20, 0}, "FD", gofpdf.BorderStyle{
+		Width: 2, Color: &gofpdf.RGBType{R: 255}, FillColor: &gofpdf.RGBType{B: 255}, Dash: []float64{4, 2},
+	})
+	pdf.RoundedRectExt(10, 50, 100, 20, 5, 5, 5, 5, "D")
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		"q 2.00 w 1.000 0.000 0.000 RG 0.000 0.000 1.000 rg [4.00 2.00] 0.00 d\n",
+		// The radii of 20 on the right side, 20 high, are halved
+		"20.00000 831.89000 m",
+		"B Q\n",
+	} {
+		if !bytes.Contains(buf.Bytes(), []byte(s)) {
+			t.Fatalf("%q not found in document", s)
+		}
+	}
+	if n := bytes.Count(buf.Bytes(), []byte("q")) - bytes.Count(buf.Bytes(), []byte("Q")); n != 0 {
+		t.Fatalf("%d graphics states not restored", n)
+	}
+	pdf = gofpdf.New("P", "pt", "A4", "")
+	pdf.AddPage()
+	pdf.RoundedRectEx(10, 10, 100, 20, [4]float64{-1, 0, 0, 0}, "D", gofpdf.BorderStyle{})
+	if pdf.Ok() {
+		t.Fatalf("no error with a negative radius")
+	}
+}
+
+// ExampleFpdf_SetOverprint demonstrates process colors in CMYK and a spot
+// color printed over them.
+func ExampleFpdf_SetOverprint() {
+	pdf := gofpdf.New("", "", "", "")
+	pdf.SetFont("Helvetica", "B", 24)
+	pdf.AddSpotColor("PANTONE 871 C", 20, 28, 60, 20)
+	pdf.AddPage()
+	pdf.SetFillColorCMYK(100, 0, 0, 0)
+	pdf.Rect(20, 20, 80, 50, "F")
+	pdf.SetFillColorCMYK(0, 100, 0, 0)
+	pdf.Rect(60, 40, 80, 50, "F")
+	// The spot color prints over the process colors instead of knocking them
+	// out
+	pdf.SetOverprint(true, true, 1)
+	pdf.SetTextSpotColor("PANTONE 871 C", 100)
+	pdf.Text(30, 60, "Gold varnish")
+	pdf.SetOverprint(false, false, 0)
+	pdf.SetTextColorCMYK(0, 0, 0, 100)
+	pdf.Text(20, 110, "Process black")
+	fileStr := example.Filename("Fpdf_SetOverprint")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_SetOverprint.pdf
+}
+
+// TestSetOverprint verifies the operators of CMYK colors and the graphics
+// states of overprint settings.
+func TestSetOverprint(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFillColorCMYK(0, 100, 100, 0)
+	pdf.SetDrawColorCMYK(10, 20, 30, 140)
+	pdf.AddPage()
+	if r, g, b := pdf.GetFillColor(); r != 255 || g != 0 || b != 0 {
+		t.Fatalf("RGB fill color is %d %d %d", r, g, b)
+	}
+	if c, m, y, k := pdf.GetDrawColorCMYK(); c != 10 || m != 20 || y != 30 || k != 100 {
+		t.Fatalf("CMYK draw color is %d %d %d %d", c, m, y, k)
+	}
+	pdf.SetOverprint(true, true, 1)
+	pdf.SaveGState()
+	pdf.SetOverprint(false, true, 0)
+	pdf.RestoreGState()
+	if stroke, fill, mode := pdf.GetOverprint(); !stroke || !fill || mode != 1 {
+		t.Fatalf("overprint is %t %t %d after restoring", stroke, fill, mode)
+	}
+	pdf.AddPage()
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"0.000 1.000 1.000 0.000 k", "0.100 0.200 0.300 1.000 K",
+		"<</Type /ExtGState /OP true /op true /OPM 1>>", "<</Type /ExtGState /OP false /op true /OPM 0>>"} {
+		if !bytes.Contains(buf.Bytes(), []byte(s)) {
+			t.Fatalf("%q not found in document", s)
+		}
+	}
+	// The overprint settings are retained on the second page
+	if n := bytes.Count(buf.Bytes(), []byte("/OP1 gs")); n != 2 {
+		t.Fatalf("overprint set %d times", n)
+	}
+	pdf = gofpdf.New("P", "mm", "A4", "")
+	pdf.SetOverprint(true, true, 2)
+	if pdf.Ok() {
+		t.Fatalf("no error with overprint mode 2")
+	}
+}
+
+// ExampleFpdf_SetFillSpotColorCMYK demonstrates spot colors that are defined
+// where they are used.
+func ExampleFpdf_SetFillSpotColorCMYK() {
+	pdf := gofpdf.New("", "", "", "")
+	pdf.SetFont("Helvetica", "B", 20)
+	pdf.AddPage()
+	for j, tint := range []byte{100, 75, 50, 25} {
+		pdf.SetFillSpotColorCMYK("PANTONE 185 C", 0, 91, 76, 0, tint)
+		pdf.Rect(20+float64(j)*40, 20, 35, 35, "F")
+	}
+	pdf.SetLineWidth(2)
+	pdf.SetDrawSpotColorCMYK("PANTONE 7474 C", 90, 0, 30, 25, 100)
+	pdf.Rect(15, 15, 165, 45, "D")
+	pdf.SetTextSpotColorCMYK("PANTONE 7474 C", 90, 0, 30, 25, 100)
+	pdf.Text(20, 75, "Two inks, four tints")
+	fileStr := example.Filename("Fpdf_SetFillSpotColorCMYK")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_SetFillSpotColorCMYK.pdf
+}
+
+// TestSpotColorCMYK verifies that spot colors defined inline are added once,
+// and that a name cannot be used with other ink percentages.
+func TestSpotColorCMYK(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false)
+	pdf.AddPage()
+	pdf.SetFillSpotColorCMYK("PANTONE 185 C", 0, 91, 76, 0, 80)
+	pdf.Rect(10, 10, 20, 20, "F")
+	pdf.SetDrawSpotColorCMYK("PANTONE 185 C", 0, 91, 76, 0, 40)
+	pdf.Rect(10, 40, 20, 20, "D")
+	pdf.SetTextSpotColorCMYK("Varnish", 0, 0, 0, 120, 100)
+	if name, c, m, y, k := pdf.GetFillSpotColor(); name != "PANTONE 185 C" || c != 0 || m != 91 || y != 76 || k != 0 {
+		t.Fatalf("fill spot color %s %d %d %d %d", name, c, m, y, k)
+	}
+	if _, _, _, _, k := pdf.GetTextSpotColor(); k != 100 {
+		t.Fatalf("text spot color with black ink %d", k)
+	}
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	str := buf.String()
+	if n := strings.Count(str, "[/Separation /PANTONE#20185#20C"); n != 1 {
+		t.Fatalf("spot color added %d times", n)
+	}
+	for _, s := range []string{"/CS1 cs 0.800 scn", "/CS1 CS 0.400 SCN", "/C1 [0.000 0.910 0.760 0.000]"} {
+		if !strings.Contains(str, s) {
+			t.Fatalf("%q not found in document", s)
+		}
+	}
+	pdf = gofpdf.New("P", "mm", "A4", "")
+	pdf.AddSpotColor("PANTONE 185 C", 0, 91, 76, 0)
+	pdf.AddPage()
+	pdf.SetFillSpotColorCMYK("PANTONE 185 C", 0, 91, 76, 0, 100)
+	if !pdf.Ok() {
+		t.Fatal(pdf.Error())
+	}
+	pdf.SetFillSpotColorCMYK("PANTONE 185 C", 0, 100, 80, 0, 100)
+	if pdf.Ok() {
+		t.Fatal("no error with other inks of a spot color")
+	}
+}
+
+// ExampleFpdf_RegisterICCProfile demonstrates colors in the color space of an
+// ICC profile and a profile as the output intent of the document.
+func ExampleFpdf_RegisterICCProfile() {
+	pdf := gofpdf.New("", "", "", "")
+	data, err := ioutil.ReadFile(example.ImageFile("sRGB2014.icc"))
+	if err == nil {
+		pdf.RegisterICCProfile("sRGB2014", data, 3)
+		pdf.SetOutputIntentProfile("sRGB2014", "sRGB IEC61966-2.1")
+		pdf.SetFont("Helvetica", "B", 24)
+		pdf.AddPage()
+		pdf.SetFillColorICC("sRGB2014", 0.9, 0.3, 0.1)
+		pdf.SetDrawColorICC("sRGB2014", 0.1, 0.2, 0.6)
+		pdf.SetLineWidth(2)
+		pdf.Rect(20, 20, 80, 50, "FD")
+		pdf.SetTextColorICC("sRGB2014", 0.1, 0.5, 0.2)
+		pdf.Text(20, 90, "Color managed")
+	} else {
+		pdf.SetError(err)
+	}
+	fileStr := example.Filename("Fpdf_RegisterICCProfile")
+	err = pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_RegisterICCProfile.pdf
+}
+
+// TestRegisterICCProfile verifies the color spaces and output intent of ICC
+// profiles and the errors of invalid profiles and colors.
+func TestRegisterICCProfile(t *testing.T) {
+	data, err := ioutil.ReadFile(example.ImageFile("sRGB2014.icc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false)
+	pdf.RegisterICCProfile("srgb", data, 3)
+	pdf.SetOutputIntentProfile("srgb", "")
+	pdf.AddPage()
+	pdf.SetFillColorICC("srgb", 1, 0.5, -1)
+	pdf.SetDrawColorICC("srgb", 0, 0, 1)
+	if r, g, b := pdf.GetFillColor(); r != 255 || g != 128 || b != 0 {
+		t.Fatalf("RGB fill color is %d %d %d", r, g, b)
+	}
+	var buf bytes.Buffer
+	if err = pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"/ICC1 cs 1.000 0.500 0.000 scn", "/ICC1 CS 0.000 0.000 1.000 SCN",
+		"/N 3 /Alternate /DeviceRGB", "/S/GTS_PDFX", "/OutputConditionIdentifier(srgb)"} {
+		if !bytes.Contains(buf.Bytes(), []byte(s)) {
+			t.Fatalf("%q not found in document", s)
+		}
+	}
+	// The profile is written once and referenced by its color space
+	if n := bytes.Count(buf.Bytes(), []byte("/N 3")); n != 1 {
+		t.Fatalf("profile written %d times", n)
+	}
+	for _, fn := range []func(pdf *gofpdf.Fpdf){
+		func(pdf *gofpdf.Fpdf) { pdf.RegisterICCProfile("cmyk", data, 4) },
+		func(pdf *gofpdf.Fpdf) { pdf.RegisterICCProfile("bad", data, 2) },
+		func(pdf *gofpdf.Fpdf) { pdf.RegisterICCProfile("short", data[:100], 3) },
+		func(pdf *gofpdf.Fpdf) { pdf.RegisterICCProfile("srgb", data, 3) },
+		func(pdf *gofpdf.Fpdf) { pdf.SetFillColorICC("none", 0.5) },
+		func(pdf *gofpdf.Fpdf) { pdf.SetTextColorICC("srgb", 0.5) },
+		func(pdf *gofpdf.Fpdf) { pdf.SetOutputIntentProfile("none", "") },
+	} {
+		pdf = gofpdf.New("P", "mm", "A4", "")
+		pdf.RegisterICCProfile("srgb", data, 3)
+		fn(pdf)
+		if pdf.Ok() {
+			t.Fatalf("no error with invalid profile or color")
+		}
+	}
+}
+
+// ExampleFpdf_SetPDFXConformance demonstrates a document prepared for print
+// in conformance with PDF/X-4.
+func ExampleFpdf_SetPDFXConformance() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetPDFXConformance(gofpdf.PDFX4)
+	pdf.SetTitle("Flyer", true)
+	data, err := ioutil.ReadFile(example.ImageFile("sRGB2014.icc"))
+	if err == nil {
+		pdf.RegisterICCProfile("sRGB2014", data, 3)
+		pdf.SetOutputIntentProfile("sRGB2014", "sRGB IEC61966-2.1")
+		pdf.AddUTF8Font("dejavu", "", example.FontFile("DejaVuSansCondensed.ttf"))
+		// The trim box is the size of the finished page, inside a bleed of 3 mm
+		pdf.SetPageBox("trim", 3, 3, 204, 291)
+		pdf.SetPageBox("bleed", 0, 0, 210, 297)
+		pdf.AddPage()
+		pdf.SetFillColor(200, 60, 40)
+		pdf.Rect(0, 0, 210, 80, "F")
+		pdf.SetFont("dejavu", "", 28)
+		pdf.SetTextColorICC("sRGB2014", 1, 1, 1)
+		pdf.Text(20, 50, "Summer sale")
+	} else {
+		pdf.SetError(err)
+	}
+	fileStr := example.Filename("Fpdf_SetPDFXConformance")
+	err = pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_SetPDFXConformance.pdf
+}
+
+// TestSetPDFXConformance verifies the keys of PDF/X-4 documents and the list
+// of violations reported for documents that do not conform.
+func TestSetPDFXConformance(t *testing.T) {
+	data, err := ioutil.ReadFile(example.ImageFile("sRGB2014.icc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A CMYK profile, as far as the document is concerned
+	cmyk := append([]byte(nil), data...)
+	copy(cmyk[16:20], "CMYK")
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false)
+	pdf.SetPDFXConformance(gofpdf.PDFX4)
+	pdf.SetTitle("Proof", false)
+	pdf.RegisterICCProfile("press", cmyk, 4)
+	pdf.SetOutputIntentProfile("press", "FOGRA39")
+	pdf.AddUTF8Font("dejavu", "", example.FontFile("DejaVuSansCondensed.ttf"))
+	pdf.SetPageBox("trim", 0, 0, 210, 297)
+	pdf.AddPage()
+	pdf.SetFillColorCMYK(0, 50, 100, 0)
+	pdf.Rect(10, 10, 50, 50, "F")
+	pdf.SetFont("dejavu", "", 12)
+	pdf.Text(10, 80, "CMYK only")
+	var buf bytes.Buffer
+	if err = pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"/GTS_PDFXVersion (PDF/X-4) /Trapped /False", "/S/GTS_PDFX",
+		"<pdfxid:GTS_PDFXVersion>PDF/X-4</pdfxid:GTS_PDFXVersion>", "<xmpMM:DocumentID>uuid:",
+		"/TrimBox"} {
+		if !bytes.Contains(buf.Bytes(), []byte(s)) {
+			t.Fatalf("%q not found in document", s)
+		}
+	}
+	// All violations are listed
+	pdf = gofpdf.New("P", "mm", "A4", "")
+	pdf.SetPDFXConformance(gofpdf.PDFX4)
+	pdf.RegisterICCProfile("press", cmyk, 4)
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.AddPage()
+	pdf.SetFillColor(255, 0, 0)
+	pdf.Rect(10, 10, 50, 50, "F")
+	pdf.Image(example.ImageFile("logo.png"), 10, 70, 30, 0, false, "", 0, "")
+	pdf.AddPage()
+	err = pdf.Output(&buf)
+	if err == nil {
+		t.Fatal("no error for document that does not conform")
+	}
+	for _, s := range []string{"pages without a trim box: 1, 2", "no title", "no output intent profile",
+		"DeviceRGB colors used by page 1, page 2, image", "font Helvetica is not embedded"} {
+		if !strings.Contains(err.Error(), s) {
+			t.Fatalf("%q not found in error %q", s, err)
+		}
+	}
+	pdf = gofpdf.New("P", "mm", "A4", "")
+	pdf.SetPDFXConformance("PDF/X-1a")
+	if pdf.Ok() {
+		t.Fatal("no error for unsupported level")
+	}
+}
+
+// TestPDFXTransparencyGroups verifies that the transparency groups of pages
+// and soft masks of a PDF/X document are blended in the color space of its
+// CMYK output intent
+func TestPDFXTransparencyGroups(t *testing.T) {
+	data, err := ioutil.ReadFile(example.ImageFile("sRGB2014.icc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmyk := append([]byte(nil), data...)
+	copy(cmyk[16:20], "CMYK")
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false)
+	pdf.SetPDFXConformance(gofpdf.PDFX4)
+	pdf.SetTitle("Proof", false)
+	pdf.RegisterICCProfile("press", cmyk, 4)
+	pdf.SetOutputIntentProfile("press", "FOGRA39")
+	pdf.SetPageBox("trim", 0, 0, 210, 297)
+	pdf.AddPage()
+	pdf.BeginSoftMask()
+	pdf.SetFillColorCMYK(0, 0, 0, 50)
+	pdf.Rect(10, 10, 50, 50, "F")
+	mask := pdf.EndSoftMask()
+	pdf.ApplySoftMask(mask, "")
+	pdf.SetAlpha(0.5, "Normal")
+	pdf.SetFillColorCMYK(0, 50, 100, 0)
+	pdf.Rect(10, 10, 50, 50, "F")
+	var buf bytes.Buffer
+	if err = pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	str := buf.String()
+	for _, s := range []string{"/Group <</Type /Group /S /Transparency /CS /DeviceCMYK>>",
+		"/Group <</S /Transparency /CS /DeviceCMYK>> /Resources 2 0 R"} {
+		if !strings.Contains(str, s) {
+			t.Fatalf("%q not found in document", s)
+		}
+	}
+	if strings.Contains(str, "/DeviceRGB") {
+		t.Fatal("DeviceRGB found in document with CMYK output intent")
+	}
+}
+
+// ExampleFpdf_SetPageBoxes demonstrates the bleed and trim boxes of pages
+// prepared for a print shop.
+func ExampleFpdf_SetPageBoxes() {
+	const bleed = 3
+	// The media box is the finished size with a bleed around it
+	pdf := gofpdf.NewCustom(&gofpdf.InitType{
+		UnitStr: "mm",
+		Size:    gofpdf.SizeType{Wd: 148 + 2*bleed, Ht: 210 + 2*bleed},
+	})
+	pdf.SetDefaultPageBoxes(gofpdf.PageBoxes{
+		Bleed: gofpdf.PageBox{SizeType: gofpdf.SizeType{Wd: 148 + 2*bleed, Ht: 210 + 2*bleed}},
+		Trim: gofpdf.PageBox{SizeType: gofpdf.SizeType{Wd: 148, Ht: 210},
+			PointType: gofpdf.PointType{X: bleed, Y: bleed}},
+	})
+	pdf.SetFont("Helvetica", "B", 20)
+	for j := 0; j < 2; j++ {
+		pdf.AddPage()
+		// The background extends into the bleed
+		pdf.SetFillColor(40, 90, 160)
+		pdf.Rect(0, 0, 148+2*bleed, 60, "F")
+		pdf.SetTextColor(255, 255, 255)
+		pdf.Text(15, 40, fmt.Sprintf("Page %d", j+1))
+	}
+	// The cover is cropped to the finished size in viewers
+	boxes := pdf.GetPageBoxes(1)
+	boxes.Crop = boxes.Trim
+	pdf.SetPageBoxes(1, boxes)
+	fileStr := example.Filename("Fpdf_SetPageBoxes")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_SetPageBoxes.pdf
+}
+
+// TestSetPageBoxes verifies the coordinates of page boxes relative to the
+// upper left corner and the errors of boxes that do not fit.
+func TestSetPageBoxes(t *testing.T) {
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.SetCompression(false)
+	pdf.SetDefaultPageBoxes(gofpdf.PageBoxes{Trim: gofpdf.PageBox{
+		SizeType: gofpdf.SizeType{Wd: 500, Ht: 700}, PointType: gofpdf.PointType{X: 10, Y: 20}}})
+	pdf.AddPage()
+	pdf.AddPageFormat("L", gofpdf.SizeType{Wd: 800, Ht: 900})
+	pdf.SetPageBoxes(1, gofpdf.PageBoxes{
+		Bleed: gofpdf.PageBox{SizeType: gofpdf.SizeType{Wd: 595.28, Ht: 841.89}},
+		Art: gofpdf.PageBox{SizeType: gofpdf.SizeType{Wd: 100, Ht: 100},
+			PointType: gofpdf.PointType{X: 50, Y: 50}},
+	})
+	if boxes := pdf.GetPageBoxes(2); boxes.Trim.X != 10 || boxes.Trim.Y != 20 || boxes.Trim.Wd != 500 ||
+		boxes.Trim.Ht != 700 {
+		t.Fatalf("trim box of page 2 is %v", boxes.Trim)
+	}
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	// Page 2 is 900 by 800 points
+	for _, s := range []string{"/BleedBox [0.00 0.00 595.28 841.89]\n/ArtBox [50.00 691.89 150.00 791.89]",
+		"/TrimBox [10.00 80.00 510.00 780.00]"} {
+		if !bytes.Contains(buf.Bytes(), []byte(s)) {
+			t.Fatalf("%q not found in document", s)
+		}
+	}
+	if bytes.Count(buf.Bytes(), []byte("/TrimBox")) != 1 {
+		t.Fatal("trim box of page 1 not replaced")
+	}
+	for _, boxes := range []gofpdf.PageBoxes{
+		{Crop: gofpdf.PageBox{SizeType: gofpdf.SizeType{Wd: 600, Ht: 100}}},
+		{Trim: gofpdf.PageBox{SizeType: gofpdf.SizeType{Wd: -10, Ht: 100}}},
+		{Bleed: gofpdf.PageBox{SizeType: gofpdf.SizeType{Wd: 100, Ht: 100}},
+			Trim: gofpdf.PageBox{SizeType: gofpdf.SizeType{Wd: 100, Ht: 100}, PointType: gofpdf.PointType{X: 1}}},
+	} {
+		pdf = gofpdf.New("P", "pt", "A4", "")
+		pdf.AddPage()
+		pdf.SetPageBoxes(1, boxes)
+		if pdf.Ok() {
+			t.Fatalf("no error for page boxes %v", boxes)
+		}
+	}
+	pdf = gofpdf.New("P", "pt", "A4", "")
+	pdf.SetPageBoxes(1, gofpdf.PageBoxes{})
+	if pdf.Ok() {
+		t.Fatal("no error for page that does not exist")
+	}
+}
+
+// ExampleFpdf_AddPrinterMarks demonstrates crop and registration marks, color
+// bars and page information around the trim box of a page.
+func ExampleFpdf_AddPrinterMarks() {
+	const (
+		slug  = 20
+		bleed = 3
+	)
+	pdf := gofpdf.NewCustom(&gofpdf.InitType{
+		UnitStr: "mm",
+		Size:    gofpdf.SizeType{Wd: 148 + 2*slug, Ht: 210 + 2*slug},
+	})
+	pdf.SetTitle("Postcard", false)
+	pdf.SetDefaultPageBoxes(gofpdf.PageBoxes{
+		Bleed: gofpdf.PageBox{SizeType: gofpdf.SizeType{Wd: 148 + 2*bleed, Ht: 210 + 2*bleed},
+			PointType: gofpdf.PointType{X: slug - bleed, Y: slug - bleed}},
+		Trim: gofpdf.PageBox{SizeType: gofpdf.SizeType{Wd: 148, Ht: 210},
+			PointType: gofpdf.PointType{X: slug, Y: slug}},
+	})
+	pdf.AddPage()
+	pdf.SetFillColorCMYK(80, 10, 0, 0)
+	pdf.Rect(slug-bleed, slug-bleed, 148+2*bleed, 90, "F")
+	pdf.SetFont("Helvetica", "B", 24)
+	pdf.SetTextColorCMYK(0, 0, 0, 100)
+	pdf.Text(slug+10, slug+110, "Greetings")
+	pdf.AddPrinterMarks(gofpdf.MarksOptions{Crop: true, Bleed: true, Registration: true,
+		ColorBars: true, PageInfo: true})
+	fileStr := example.Filename("Fpdf_AddPrinterMarks")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_AddPrinterMarks.pdf
+}
+
+// TestAddPrinterMarks verifies the position and color of printer marks and
+// that the settings of the document are left as they were.
+func TestAddPrinterMarks(t *testing.T) {
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.SetCompression(false)
+	pdf.SetDefaultPageBoxes(gofpdf.PageBoxes{Trim: gofpdf.PageBox{
+		SizeType: gofpdf.SizeType{Wd: 400, Ht: 600}, PointType: gofpdf.PointType{X: 100, Y: 100}}})
+	pdf.AddPage()
+	pdf.SetFillColor(255, 0, 0)
+	pdf.SetLineWidth(2)
+	sizePt, _ := pdf.GetFontSize()
+	pdf.AddPrinterMarks(gofpdf.MarksOptions{Crop: true, Offset: 10, Length: 20, PageInfo: true})
+	if r, g, b := pdf.GetFillColor(); r != 255 || g != 0 || b != 0 {
+		t.Fatalf("fill color is %d %d %d after marks", r, g, b)
+	}
+	if w := pdf.GetLineWidth(); w != 2 {
+		t.Fatalf("line width is %.2f after marks", w)
+	}
+	if pt, _ := pdf.GetFontSize(); pt != sizePt {
+		t.Fatalf("font size is %.2f after marks", pt)
+	}
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	// The upper left crop marks end 10 points outside the trim box, which is
+	// at 100, 741.89 in PDF coordinates
+	for _, s := range []string{"/Separation /All", "/Artifact BMC", "/CS1 CS 1.000 SCN",
+		"90.00 741.89 m 70.00 741.89 l S", "100.00 751.89 m 100.00 771.89 l S", "(Page 1 "} {
+		if !bytes.Contains(buf.Bytes(), []byte(s)) {
+			t.Fatalf("%q not found in document", s)
+		}
+	}
+	pdf = gofpdf.New("P", "pt", "A4", "")
+	pdf.AddPage()
+	pdf.AddPrinterMarks(gofpdf.MarksOptions{Crop: true})
+	if pdf.Ok() {
+		t.Fatal("no error for page without trim box")
+	}
+}
+
+// ExampleFpdf_SetPageRotation demonstrates a section of landscape pages in a
+// portrait document and a page that is rotated for display.
+func ExampleFpdf_SetPageRotation() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.SetFooterFunc(func() {
+		w, _ := pdf.GetPageSize()
+		pdf.SetXY(0, -15)
+		pdf.CellFormat(w, 10, fmt.Sprintf("Page %d", pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+	pdf.AddPage()
+	pdf.Write(6, "Portrait page")
+	// The wide tables follow on landscape pages
+	pdf.SetDefaultPageFormat("L", gofpdf.SizeType{Wd: 210, Ht: 297})
+	pdf.AddPage()
+	pdf.Write(6, "Landscape page")
+	pdf.AddPage()
+	pdf.Write(6, "Landscape page shown in portrait orientation")
+	pdf.SetPageRotation(3, 90)
+	pdf.SetDefaultPageFormat("P", gofpdf.SizeType{Wd: 210, Ht: 297})
+	pdf.AddPage()
+	pdf.Write(6, "Portrait page")
+	fileStr := example.Filename("Fpdf_SetPageRotation")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_SetPageRotation.pdf
+}
+
+// TestSetPageRotation verifies the rotation of pages, the format of pages
+// added after SetDefaultPageFormat() and the size of pages revisited with
+// SetPage().
+func TestSetPageRotation(t *testing.T) {
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.SetCompression(false)
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.SetFooterFunc(func() {
+		pdf.Text(10, 10, fmt.Sprintf("Footer %d", pdf.PageNo()))
+	})
+	pdf.AddPage()
+	pdf.SetDefaultPageFormat("landscape", gofpdf.SizeType{Wd: 400, Ht: 600})
+	pdf.AddPage()
+	if w, h := pdf.GetPageSize(); w != 600 || h != 400 {
+		t.Fatalf("page 2 is %.2f by %.2f", w, h)
+	}
+	pdf.SetPageRotation(1, -90)
+	pdf.SetPageRotation(2, 180)
+	pdf.SetPageRotation(2, 360)
+	if r := pdf.GetPageRotation(1); r != 270 {
+		t.Fatalf("rotation of page 1 is %d", r)
+	}
+	pdf.SetPage(1)
+	if w, h := pdf.GetPageSize(); w != 595.28 || h != 841.89 {
+		t.Fatalf("page 1 is %.2f by %.2f after SetPage", w, h)
+	}
+	pdf.Text(10, 41.89, "Revisited")
+	// A page break continues with a page of the format of the current page
+	pdf.ColumnBreak()
+	if w, h := pdf.GetPageSize(); w != 595.28 || h != 841.89 {
+		t.Fatalf("page 3 is %.2f by %.2f", w, h)
+	}
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	doc := buf.String()
+	for _, s := range []string{"/Rotate 270", "/MediaBox [0 0 600.00 400.00]", "10.00 800.00 Td (Revisited)",
+		"10.00 831.89 Td (Footer 1)", "10.00 390.00 Td (Footer 2)"} {
+		if !strings.Contains(doc, s) {
+			t.Fatalf("%q not found in document", s)
+		}
+	}
+	if strings.Count(doc, "/Rotate") != 1 {
+		t.Fatal("rotation of 360 degrees written")
+	}
+	for _, fn := range []func(pdf *gofpdf.Fpdf){
+		func(pdf *gofpdf.Fpdf) { pdf.SetPageRotation(1, 45) },
+		func(pdf *gofpdf.Fpdf) { pdf.SetPageRotation(2, 90) },
+		func(pdf *gofpdf.Fpdf) { pdf.SetDefaultPageFormat("X", gofpdf.SizeType{Wd: 100, Ht: 100}) },
+		func(pdf *gofpdf.Fpdf) { pdf.SetDefaultPageFormat("P", gofpdf.SizeType{Wd: 0, Ht: 100}) },
+	} {
+		pdf = gofpdf.New("P", "pt", "A4", "")
+		pdf.AddPage()
+		fn(pdf)
+		if pdf.Ok() {
+			t.Fatal("no error for invalid rotation or format")
+		}
+	}
+}
+
+// ExampleFpdf_OutputImposed demonstrates a booklet of A5 pages imposed on A4
+// sheets.
+func ExampleFpdf_OutputImposed() {
+	pdf := gofpdf.New("P", "mm", "A5", "")
+	pdf.SetFont("Helvetica", "", 24)
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-15)
+		pdf.SetFont("Helvetica", "", 10)
+		pdf.CellFormat(0, 10, fmt.Sprintf("%d", pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+	for j := 1; j <= 6; j++ {
+		pdf.AddPage()
+		pdf.SetFont("Helvetica", "", 24)
+		pdf.Write(12, fmt.Sprintf("Chapter %d", j))
+	}
+	fileStr := example.Filename("Fpdf_OutputImposed")
+	fl, err := os.Create(fileStr)
+	if err == nil {
+		err = pdf.OutputImposed(fl, gofpdf.ImpositionOptions{Booklet: true, Creep: 0.5,
+			SheetSize: gofpdf.SizeType{Wd: 297, Ht: 210}})
+		if closeErr := fl.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_OutputImposed.pdf
+}
+
+// TestOutputImposed verifies the order of the pages of booklets and N-up
+// sheets and the creep of the pages of inner sheets.
+func TestOutputImposed(t *testing.T) {
+	newDoc := func(pages int) *gofpdf.Fpdf {
+		pdf := gofpdf.NewCustom(&gofpdf.InitType{UnitStr: "pt", Size: gofpdf.SizeType{Wd: 300, Ht: 400}})
+		pdf.SetCompression(false)
+		for j := 0; j < pages; j++ {
+			pdf.AddPage()
+		}
+		return pdf
+	}
+	order := regexp.MustCompile(`/IPG(\d+) Do`)
+	impose := func(pdf *gofpdf.Fpdf, opts gofpdf.ImpositionOptions) string {
+		var buf bytes.Buffer
+		if err := pdf.OutputImposed(&buf, opts); err != nil {
+			t.Fatal(err)
+		}
+		var list []string
+		for _, m := range order.FindAllStringSubmatch(buf.String(), -1) {
+			list = append(list, m[1])
+		}
+		return fmt.Sprintf("%d sides: %s", strings.Count(buf.String(), "/Type /Page\n"), strings.Join(list, " "))
+	}
+	var buf bytes.Buffer
+	// Six pages are padded to eight, on two sheets
+	pdf := newDoc(6)
+	if s := impose(pdf, gofpdf.ImpositionOptions{Booklet: true, Creep: 2}); s != "4 sides: 1 2 6 3 4 5" {
+		t.Fatalf("booklet is %s", s)
+	}
+	pdf = newDoc(6)
+	pdf.OutputImposed(&buf, gofpdf.ImpositionOptions{Booklet: true, Creep: 2})
+	// The left page of the inner sheet is moved towards the spine
+	if !strings.Contains(buf.String(), "1.00000 0 0 1.00000 2.00 0.00 cm /IPG6 Do") {
+		t.Fatal("creep not applied to inner sheet")
+	}
+	if s := impose(newDoc(5), gofpdf.ImpositionOptions{NUp: 4}); s != "2 sides: 1 2 3 4 5" {
+		t.Fatalf("4-up sheets are %s", s)
+	}
+	pdf = newDoc(2)
+	if pdf.OutputImposed(&buf, gofpdf.ImpositionOptions{NUp: 4, Booklet: true}) == nil {
+		t.Fatal("no error for 4-up booklet")
+	}
+}
+
+// ExampleFpdf_SetWatermark demonstrates a "DRAFT" watermark behind the
+// content of all pages, a logo above the content of the first page and an
+// "APPROVED" stamp on the last page.
+func ExampleFpdf_SetWatermark() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetWatermark(gofpdf.WatermarkOptions{Text: "DRAFT", Angle: 45})
+	pdf.AddWatermark(gofpdf.WatermarkOptions{Image: example.ImageFile("logo.png"),
+		ImageWidth: 30, Opacity: 0.5, Layer: gofpdf.WatermarkOver, Pages: "1"})
+	pdf.AddWatermark(gofpdf.WatermarkOptions{Text: "APPROVED", FontSize: 36,
+		Color: &gofpdf.RGBType{R: 200, G: 0, B: 0}, Opacity: 0.8, Angle: -15,
+		Layer: gofpdf.WatermarkOver, Pages: "3"})
+	pdf.SetFont("Times", "", 12)
+	for j := 0; j < 3; j++ {
+		pdf.AddPage()
+		pdf.MultiCell(0, 5, lorem(), "", "", false)
+	}
+	fileStr := example.Filename("Fpdf_SetWatermark")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_SetWatermark.pdf
+}
+
+// TestSetWatermark verifies the pages and layers of watermarks and the
+// errors for invalid options.
+func TestSetWatermark(t *testing.T) {
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.SetCompression(false)
+	pdf.SetWatermark(gofpdf.WatermarkOptions{Text: "UNDER", Pages: "1,3-"})
+	pdf.AddWatermark(gofpdf.WatermarkOptions{Text: "OVER", Layer: gofpdf.WatermarkOver, Pages: "2"})
+	pdf.SetFooterFunc(func() {
+		pdf.Text(20, 800, "footer")
+	})
+	pdf.SetFont("Helvetica", "", 12)
+	for j := 1; j <= 4; j++ {
+		pdf.AddPage()
+		pdf.Text(20, 40, fmt.Sprintf("page %d", j))
+	}
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	// Each page content stream is checked for the watermarks before and after
+	// the text of the page
+	var list []string
+	for _, m := range regexp.MustCompile(`(?s)stream\n(.*?)endstream`).FindAllStringSubmatch(buf.String(), -1) {
+		pos := strings.Index(m[1], "(page ")
+		if pos < 0 {
+			continue
+		}
+		switch {
+		case strings.Contains(m[1][:pos], "(UNDER)"):
+			list = append(list, "under")
+		case strings.Contains(m[1][pos:], "(OVER)"):
+			list = append(list, "over")
+		default:
+			list = append(list, "none")
+		}
+	}
+	if s := strings.Join(list, " "); s != "under over under under" {
+		t.Fatalf("watermarks are %s", s)
+	}
+	if !strings.Contains(buf.String(), "/Artifact BMC") {
+		t.Fatal("watermark is not an artifact")
+	}
+	for _, opts := range []gofpdf.WatermarkOptions{
+		{Text: "X", Pages: "3-1"},
+		{Text: "X", Pages: "a"},
+		{Text: "X", Pages: "0"},
+		{Text: "X", Opacity: 2},
+		{Text: "X", Image: "logo.png"},
+		{Opacity: 0.5},
+	} {
+		pdf = gofpdf.New("P", "pt", "A4", "")
+		pdf.SetWatermark(opts)
+		if pdf.Ok() {
+			t.Fatalf("no error for %+v", opts)
+		}
+	}
+}
+
+// ExampleFpdf_SetPageBackground demonstrates a letterhead on the first page
+// of a letter and a plain background on the following pages.
+func ExampleFpdf_SetPageBackground() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	letterhead := pdf.CreateTemplate(func(tpl *gofpdf.Tpl) {
+		tpl.Image(example.ImageFile("logo.png"), 10, 10, 30, 0, false, "", 0, "")
+		tpl.SetFont("Helvetica", "B", 16)
+		tpl.Text(50, 20, "Example Company")
+		tpl.SetFont("Helvetica", "", 9)
+		tpl.Text(50, 26, "1 Main Street, Springfield")
+		tpl.SetDrawColor(0, 100, 200)
+		tpl.Line(10, 35, 200, 35)
+	})
+	plain := pdf.CreateTemplate(func(tpl *gofpdf.Tpl) {
+		tpl.SetDrawColor(0, 100, 200)
+		tpl.Line(10, 15, 200, 15)
+		tpl.SetFillColor(230, 240, 250)
+		tpl.Rect(0, 0, 5, 297, "F")
+	})
+	pdf.SetPageBackground(letterhead, gofpdf.FirstPage)
+	pdf.SetPageBackground(plain, gofpdf.AllButFirstPage)
+	pdf.SetTopMargin(45)
+	pdf.SetFont("Times", "", 12)
+	pdf.AddPage()
+	for j := 0; j < 5; j++ {
+		pdf.MultiCell(0, 5, lorem(), "", "", false)
+		pdf.Ln(5)
+	}
+	fileStr := example.Filename("Fpdf_SetPageBackground")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_SetPageBackground.pdf
+}
+
+// TestSetPageBackground verifies that backgrounds are drawn at the start of
+// the selected pages, also when pages are flushed to an output writer.
+func TestSetPageBackground(t *testing.T) {
+	content := regexp.MustCompile(`(?s)stream\n(.*?)endstream`)
+	for _, flush := range []bool{false, true} {
+		var buf bytes.Buffer
+		pdf := gofpdf.New("P", "pt", "A4", "")
+		pdf.SetCompression(false)
+		if flush {
+			pdf.SetOutputWriter(&buf)
+		}
+		first := pdf.CreateTemplate(func(tpl *gofpdf.Tpl) {
+			tpl.Text(10, 10, "first")
+		})
+		rest := pdf.CreateTemplate(func(tpl *gofpdf.Tpl) {
+			tpl.Text(10, 10, "rest")
+		})
+		pdf.SetPageBackground(first, gofpdf.FirstPage)
+		pdf.SetFont("Helvetica", "", 12)
+		for j := 1; j <= 3; j++ {
+			pdf.AddPage()
+			pdf.Text(20, 40, fmt.Sprintf("page %d", j))
+			if flush {
+				pdf.FlushPage()
+			}
+		}
+		// Backgrounds can be set until the pages are written
+		pdf.SetPageBackground(rest, gofpdf.AllButFirstPage)
+		if flush {
+			pdf.Close()
+		} else {
+			pdf.Output(&buf)
+		}
+		if err := pdf.Error(); err != nil {
+			t.Fatal(err)
+		}
+		var list []string
+		for _, m := range content.FindAllStringSubmatch(buf.String(), -1) {
+			if !strings.Contains(m[1], "(page ") {
+				continue
+			}
+			switch {
+			case strings.HasPrefix(m[1], "/Artifact BMC\nq 1 0 0 1 0.0000 0.0000 cm /TPL"+first.ID()+" Do Q\nEMC\n"):
+				list = append(list, "first")
+			case strings.HasPrefix(m[1], "/Artifact BMC\nq 1 0 0 1 0.0000 0.0000 cm /TPL"+rest.ID()+" Do Q\nEMC\n"):
+				list = append(list, "rest")
+			default:
+				list = append(list, "none")
+			}
+		}
+		want := "first rest rest"
+		if flush {
+			// Page 2 was flushed before the second background was set, while
+			// the current page 3 was not
+			want = "first none rest"
+		}
+		if s := strings.Join(list, " "); s != want {
+			t.Fatalf("backgrounds with flush %v are %s", flush, s)
+		}
+	}
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.SetPageBackground(pdf.CreateTemplate(func(tpl *gofpdf.Tpl) {}), "2-1")
+	if pdf.Ok() {
+		t.Fatal("no error for invalid page range")
+	}
+}
+
+// ExampleFpdf_SetHeaderFuncEx demonstrates running headers with the title of
+// the current chapter and footers with the page number and the total number
+// of pages.
+func ExampleFpdf_SetHeaderFuncEx() {
+	pdf := gofpdf.New("P", "mm", "A5", "")
+	pdf.SetHeaderFuncEx(func(ctx gofpdf.PageContext) {
+		if ctx.Section == "" {
+			return
+		}
+		pdf.SetFont("Helvetica", "I", 8)
+		pdf.CellFormat(0, 5, ctx.Section, "B", 1, "R", false, 0, "")
+		pdf.Ln(5)
+	})
+	pdf.SetFooterFuncEx(func(ctx gofpdf.PageContext) {
+		pdf.SetY(-15)
+		pdf.SetFont("Helvetica", "", 8)
+		str := fmt.Sprintf("Page %d of %s", ctx.PageNo, ctx.TotalPages)
+		if ctx.LastPage {
+			str += " - end of document"
+		}
+		pdf.CellFormat(0, 10, str, "", 0, "C", false, 0, "")
+	})
+	for j := 1; j <= 3; j++ {
+		pdf.SetSection(fmt.Sprintf("Chapter %d", j))
+		pdf.AddPage()
+		pdf.SetFont("Helvetica", "B", 16)
+		pdf.CellFormat(0, 10, pdf.GetSection(), "", 1, "", false, 0, "")
+		pdf.SetFont("Times", "", 11)
+		for k := 0; k < j*2; k++ {
+			pdf.MultiCell(0, 5, lorem(), "", "", false)
+			pdf.Ln(3)
+		}
+	}
+	fileStr := example.Filename("Fpdf_SetHeaderFuncEx")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_SetHeaderFuncEx.pdf
+}
+
+// TestSetHeaderFuncEx verifies the page context passed to header and footer
+// functions and the substitution of the total number of pages.
+func TestSetHeaderFuncEx(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false)
+	var list []string
+	pdf.SetHeaderFuncEx(func(ctx gofpdf.PageContext) {
+		list = append(list, fmt.Sprintf("header %d %s %.0fx%.0f %v", ctx.PageNo, ctx.Section,
+			ctx.PageSize.Wd, ctx.PageSize.Ht, ctx.LastPage))
+	})
+	pdf.SetFooterFuncEx(func(ctx gofpdf.PageContext) {
+		list = append(list, fmt.Sprintf("footer %d %s %v", ctx.PageNo, ctx.Section, ctx.LastPage))
+		pdf.Text(10, 290, fmt.Sprintf("%d/%s", ctx.PageNo, ctx.TotalPages))
+	})
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.SetSection("One")
+	pdf.AddPage()
+	pdf.SetSection("Two")
+	pdf.AddPageFormat("L", gofpdf.SizeType{Wd: 210, Ht: 297})
+	pdf.AddPage()
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := "header 1 One 210x297 false, footer 1 Two false, header 2 Two 297x210 false, " +
+		"footer 2 Two false, header 3 Two 210x297 false, footer 3 Two true"
+	if s := strings.Join(list, ", "); s != want {
+		t.Fatalf("contexts are %s", s)
+	}
+	for _, s := range []string{"(1/3)", "(2/3)", "(3/3)"} {
+		if !strings.Contains(buf.String(), s) {
+			t.Fatalf("total number of pages not substituted in %s", s)
+		}
+	}
+}
+
+// ExampleFpdf_SetMarginsMirrored demonstrates the margins of a book printed
+// on both sides, with running headers and page numbers at the outer edges.
+func ExampleFpdf_SetMarginsMirrored() {
+	pdf := gofpdf.New("P", "mm", "A5", "")
+	pdf.SetMarginsMirrored(25, 12, 20)
+	pdf.SetHeaderFuncOddEven(func(ctx gofpdf.PageContext) {
+		pdf.SetFont("Helvetica", "I", 8)
+		pdf.CellFormat(0, 5, ctx.Section, "B", 1, "R", false, 0, "")
+	}, func(ctx gofpdf.PageContext) {
+		pdf.SetFont("Helvetica", "I", 8)
+		pdf.CellFormat(0, 5, "A Book of Examples", "B", 1, "L", false, 0, "")
+	})
+	pdf.SetFooterFuncOddEven(func(ctx gofpdf.PageContext) {
+		pdf.SetY(-15)
+		pdf.SetFont("Helvetica", "", 8)
+		pdf.CellFormat(0, 10, fmt.Sprintf("%d", ctx.PageNo), "", 0, "R", false, 0, "")
+	}, func(ctx gofpdf.PageContext) {
+		pdf.SetY(-15)
+		pdf.SetFont("Helvetica", "", 8)
+		pdf.CellFormat(0, 10, fmt.Sprintf("%d", ctx.PageNo), "", 0, "L", false, 0, "")
+	})
+	pdf.SetSection("Chapter 1")
+	pdf.AddPage()
+	pdf.SetFont("Times", "", 11)
+	for j := 0; j < 6; j++ {
+		pdf.MultiCell(0, 5, lorem(), "", "J", false)
+		pdf.Ln(3)
+	}
+	fileStr := example.Filename("Fpdf_SetMarginsMirrored")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_SetMarginsMirrored.pdf
+}
+
+// TestSetMarginsMirrored verifies that the margins alternate between odd and
+// even pages, also in columns, and that SetMargins() ends the mirroring.
+func TestSetMarginsMirrored(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFont("Helvetica", "", 12)
+	var list []string
+	margins := func() string {
+		left, top, right, _ := pdf.GetMargins()
+		return fmt.Sprintf("%.0f %.0f %.0f", left, top, right)
+	}
+	pdf.SetHeaderFuncOddEven(func(ctx gofpdf.PageContext) {
+		list = append(list, "odd "+margins())
+	}, func(ctx gofpdf.PageContext) {
+		list = append(list, "even "+margins())
+	})
+	pdf.SetMarginsMirrored(30, 10, 15)
+	pdf.AddPage()
+	if pdf.GetX() != 30 {
+		t.Fatalf("position on odd page is %.2f", pdf.GetX())
+	}
+	pdf.AddPage()
+	if pdf.GetX() != 10 {
+		t.Fatalf("position on even page is %.2f", pdf.GetX())
+	}
+	pdf.SetColumns(2, 10)
+	pdf.ColumnBreak()
+	pdf.ColumnBreak()
+	// The first column of page 3 starts at its inner margin
+	list = append(list, "column "+margins())
+	pdf.SetColumns(1, 0)
+	pdf.SetMargins(20, 20, 20)
+	pdf.AddPage()
+	if s := strings.Join(list, ", "); s != "odd 30 15 10, even 10 15 30, odd 30 15 10, column 30 15 100, even 20 20 20" {
+		t.Fatalf("margins are %s", s)
+	}
+}
+
+// ExampleFpdf_AddPageAuto demonstrates a receipt on a page as long as its
+// content.
+func ExampleFpdf_AddPageAuto() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(4, 5, 4)
+	pdf.SetAutoPageBreak(true, 5)
+	pdf.AddPageAuto(80)
+	pdf.SetFont("Courier", "B", 12)
+	pdf.CellFormat(0, 6, "CORNER STORE", "", 1, "C", false, 0, "")
+	pdf.SetFont("Courier", "", 9)
+	pdf.CellFormat(0, 5, "2021-06-01 12:34", "B", 1, "C", false, 0, "")
+	total := 0.0
+	for j, item := range []string{"Coffee", "Croissant", "Orange juice", "Newspaper", "Chocolate bar"} {
+		price := 1.25 + float64(j)*0.8
+		total += price
+		pdf.CellFormat(50, 5, item, "", 0, "", false, 0, "")
+		pdf.CellFormat(0, 5, fmt.Sprintf("%.2f", price), "", 1, "R", false, 0, "")
+	}
+	pdf.SetFont("Courier", "B", 9)
+	pdf.CellFormat(50, 6, "TOTAL", "T", 0, "", false, 0, "")
+	pdf.CellFormat(0, 6, fmt.Sprintf("%.2f", total), "T", 1, "R", false, 0, "")
+	pdf.Ln(4)
+	pdf.SetFont("Courier", "", 8)
+	pdf.CellFormat(0, 4, "Thank you for your visit", "", 1, "C", false, 0, "")
+	fileStr := example.Filename("Fpdf_AddPageAuto")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_AddPageAuto.pdf
+}
+
+// TestAddPageAuto verifies that pages added with AddPageAuto() end below
+// their content and that other pages keep their size.
+func TestAddPageAuto(t *testing.T) {
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.SetCompression(false)
+	pdf.SetMargins(10, 10, 10)
+	pdf.SetAutoPageBreak(true, 10)
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.AddPageAuto(200)
+	for j := 0; j < 10; j++ {
+		pdf.CellFormat(0, 15, fmt.Sprintf("line %d", j), "", 1, "", false, 0, "")
+	}
+	pdf.AddPage()
+	pdf.AddPageAuto(100)
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	boxes := regexp.MustCompile(`/MediaBox \[[^]]*\]`).FindAllString(buf.String(), -1)
+	// The first page ends 170 points below its top, the last one 20 points
+	want := "/MediaBox [0 14230.00 200.00 14400.00], /MediaBox [0 14380.00 100.00 14400.00], " +
+		"/MediaBox [0 0 595.28 841.89]"
+	if s := strings.Join(boxes, ", "); s != want {
+		t.Fatalf("media boxes are %s", s)
+	}
+	pdf = gofpdf.New("P", "pt", "A4", "")
+	pdf.AddPageAuto(0)
+	if pdf.Ok() {
+		t.Fatal("no error for zero width")
+	}
+}
+
+// ExampleFpdf_MovePage demonstrates a cover page that is printed last and
+// moved to the front, a page inserted in the middle of a document and an
+// empty page at the end that is deleted.
+func ExampleFpdf_MovePage() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-15)
+		pdf.SetFont("Helvetica", "I", 8)
+		pdf.CellFormat(0, 10, fmt.Sprintf("Page %d", pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+	for j := 1; j <= 3; j++ {
+		pdf.AddPage()
+		pdf.SetFont("Helvetica", "B", 16)
+		pdf.Bookmark(fmt.Sprintf("Chapter %d", j), 0, 0)
+		pdf.CellFormat(0, 10, fmt.Sprintf("Chapter %d", j), "", 1, "", false, 0, "")
+		pdf.SetFont("Times", "", 12)
+		pdf.MultiCell(0, 5, lorem(), "", "", false)
+	}
+	// A page inserted after the first chapter
+	pdf.InsertPageAfter(1)
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.CellFormat(0, 10, "Intermission", "", 1, "C", false, 0, "")
+	// The cover page, which knows the number of chapters, is printed last
+	pdf.SetFooterFunc(nil)
+	pdf.AddPage()
+	pdf.SetFont("Helvetica", "B", 24)
+	pdf.CellFormat(0, 100, "A Book in 3 Chapters", "", 1, "C", false, 0, "")
+	pdf.MovePage(pdf.PageCount(), 1)
+	// An empty page added by mistake is dropped
+	pdf.AddPage()
+	pdf.DeletePage(pdf.PageCount())
+	fileStr := example.Filename("Fpdf_MovePage")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_MovePage.pdf
+}
+
+// TestMovePage verifies the order of pages and the references to them after
+// pages are moved, inserted and deleted.
+func TestMovePage(t *testing.T) {
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.SetCompression(false)
+	pdf.SetFont("Helvetica", "", 12)
+	var headers []string
+	pdf.SetHeaderFunc(func() {
+		headers = append(headers, fmt.Sprintf("header %d", pdf.PageNo()))
+	})
+	pdf.SetFooterFunc(func() {
+		pdf.Text(20, 820, fmt.Sprintf("footer %d", pdf.PageNo()))
+	})
+	for j := 1; j <= 3; j++ {
+		pdf.AddPage()
+		pdf.Text(20, 40, fmt.Sprintf("page %d", j))
+	}
+	pdf.Bookmark("Three", 0, 0)
+	pdf.SetPageRotation(3, 90)
+	// The page being written is moved after its footer is printed
+	pdf.MovePage(3, 1)
+	if pdf.GetPageRotation(1) != 90 || pdf.PageNo() != 1 {
+		t.Fatalf("rotation and current page are %d and %d after move", pdf.GetPageRotation(1), pdf.PageNo())
+	}
+	if n := pdf.InsertPageAfter(1); n != 2 {
+		t.Fatalf("inserted page is %d", n)
+	}
+	pdf.Text(20, 40, "page inserted")
+	pdf.AddPage()
+	pdf.Text(20, 40, "page empty")
+	pdf.DeletePage(pdf.PageCount())
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	doc := buf.String()
+	var list []string
+	for _, m := range regexp.MustCompile(`\((page|footer) ([^)]*)\)`).FindAllStringSubmatch(doc, -1) {
+		list = append(list, m[2])
+	}
+	want := "3, 3, inserted, 2, 1, 1, 2, 2"
+	if s := strings.Join(list, ", "); s != want {
+		t.Fatalf("pages are %s", s)
+	}
+	if s := strings.Join(headers, ", "); s != "header 1, header 2, header 3, header 2, header 5" {
+		t.Fatalf("headers are %s", s)
+	}
+	kids := regexp.MustCompile(`/Kids \[(\d+) 0 R`).FindStringSubmatch(doc)
+	if kids == nil || !strings.Contains(doc, "/Dest ["+kids[1]+" 0 R /XYZ") {
+		t.Fatal("bookmark does not lead to the moved page")
+	}
+	for _, fn := range []func(pdf *gofpdf.Fpdf){
+		func(pdf *gofpdf.Fpdf) { pdf.MovePage(1, 3) },
+		func(pdf *gofpdf.Fpdf) { pdf.DeletePage(1) },
+		func(pdf *gofpdf.Fpdf) { pdf.InsertPageAfter(2) },
+	} {
+		pdf = gofpdf.New("P", "pt", "A4", "")
+		pdf.AddPage()
+		fn(pdf)
+		if pdf.Ok() {
+			t.Fatal("no error for invalid page")
+		}
+	}
+}
+
+// ExampleFpdf_SetPageForWriting demonstrates printing notes on earlier pages
+// once the content of the following pages is known.
+func ExampleFpdf_SetPageForWriting() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFont("Times", "", 12)
+	var breaks []int
+	pdf.SetAcceptPageBreakFunc(func() bool {
+		breaks = append(breaks, pdf.PageNo())
+		return true
+	})
+	pdf.AddPage()
+	total := 0
+	for j := 1; j <= 120; j++ {
+		pdf.CellFormat(120, 6, fmt.Sprintf("Item %d", j), "", 0, "", false, 0, "")
+		pdf.CellFormat(30, 6, fmt.Sprintf("%d", j*3), "", 1, "R", false, 0, "")
+		total += j * 3
+	}
+	pdf.SetFont("Helvetica", "B", 12)
+	pdf.CellFormat(150, 8, fmt.Sprintf("Total %d", total), "T", 1, "R", false, 0, "")
+	// The pages that the table breaks on are noted, and the total printed
+	// above the table
+	_, ht := pdf.GetPageSize()
+	for _, n := range breaks {
+		pdf.SetPageForWriting(n)
+		pdf.SetFont("Helvetica", "I", 9)
+		pdf.SetTextColor(128, 128, 128)
+		pdf.Text(10, ht-12, fmt.Sprintf("Continued on page %d", n+1))
+	}
+	pdf.SetPageForWriting(1)
+	pdf.SetFont("Helvetica", "B", 14)
+	pdf.SetTextColor(0, 0, 128)
+	pdf.Text(10, 7, fmt.Sprintf("Grand total: %d", total))
+	fileStr := example.Filename("Fpdf_SetPageForWriting")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_SetPageForWriting.pdf
+}
+
+// TestSetPageForWriting verifies the content and settings of pages written
+// after later pages are added.
+func TestSetPageForWriting(t *testing.T) {
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.SetCompression(false)
+	pdf.SetFooterFunc(func() {
+		pdf.Text(20, 820, fmt.Sprintf("footer %d", pdf.PageNo()))
+	})
+	pdf.SetFont("Helvetica", "", 12)
+	for j := 1; j <= 3; j++ {
+		pdf.AddPage()
+		pdf.SetTextRenderingMode(1)
+		pdf.Text(20, 40, fmt.Sprintf("page %d", j))
+	}
+	pdf.SetFont("Courier", "B", 10)
+	pdf.SetLineWidth(2)
+	pdf.SetXY(50, 60)
+	pdf.SetPageForWriting(1)
+	if pdf.PageNo() != 1 {
+		t.Fatalf("current page is %d", pdf.PageNo())
+	}
+	pdf.SetFont("Times", "", 8)
+	pdf.SetDrawColor(255, 0, 0)
+	pdf.Text(20, 60, "total on page 1")
+	pdf.SetXY(70, 80)
+	pdf.SetPageForWriting(3)
+	if x, y := pdf.GetXY(); x != 50 || y != 60 {
+		t.Fatalf("position on the page being written is %.0f, %.0f", x, y)
+	}
+	if size, _ := pdf.GetFontSize(); size != 10 {
+		t.Fatalf("font size of the page being written is %.0f", size)
+	}
+	if r, g, b := pdf.GetDrawColor(); r != 0 || g != 0 || b != 0 {
+		t.Fatalf("draw color of the page being written is %d %d %d", r, g, b)
+	}
+	pdf.SetPageForWriting(2)
+	pdf.Text(20, 60, "note on page 2")
+	pdf.AddPage()
+	pdf.SetPageForWriting(5)
+	if pdf.Error() == nil {
+		t.Fatalf("page 5 can be written")
+	}
+	pdf.ClearError()
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	streams := regexp.MustCompile(`(?s)stream\n(.*?)endstream`).FindAllStringSubmatch(buf.String(), -1)
+	if len(streams) < 4 {
+		t.Fatalf("%d page streams", len(streams))
+	}
+	// Page 1: the earlier content is enclosed, the settings applied anew
+	p1 := streams[0][1]
+	for _, s := range []string{"q\n0 J\n", "1 Tr\n", "(footer 1) Tj ET\nQ\n0 J\n0 j\n2.00 w\n",
+		" 8.00 Tf ET\n1.000 0.000 0.000 RG\n"} {
+		if !strings.Contains(p1, s) {
+			t.Fatalf("page 1 does not contain %q:\n%s", s, p1)
+		}
+	}
+	if strings.Index(p1, "(total on page 1)") < strings.Index(p1, "Q\n") {
+		t.Fatalf("page 1 does not end with its note:\n%s", p1)
+	}
+	// Page 3: its footer is printed once, after the other pages are written
+	p3 := streams[2][1]
+	if strings.Count(p3, "footer 3") != 1 || strings.Contains(p3, "q\nBT") {
+		t.Fatalf("page 3 is not written once:\n%s", p3)
+	}
+	if !strings.Contains(streams[1][1], "(note on page 2) Tj ET\n") {
+		t.Fatalf("page 2 does not contain its note:\n%s", streams[1][1])
+	}
+}
+
+// ExampleFpdf_AliasCounter demonstrates page numbers counted in sections and
+// formatted as roman numerals.
+func ExampleFpdf_AliasCounter() {
+	pdf := gofpdf.New("P", "mm", "A5", "")
+	pdf.AliasCounter("{sec}", true)
+	pdf.AliasCounterFormat("{total}", false, gofpdf.PageLabelRomanUpper, 0)
+	pdf.SetFooterFuncEx(func(ctx gofpdf.PageContext) {
+		pdf.SetY(-15)
+		pdf.SetFont("Helvetica", "", 8)
+		str := fmt.Sprintf("Page %d of {sec} in %s", ctx.SectionPageNo, ctx.Section)
+		pdf.CellFormat(0, 5, str, "", 1, "C", false, 0, "")
+		pdf.CellFormat(0, 5, "{total} pages", "", 0, "R", false, 0, "")
+	})
+	for j, count := range []int{2, 5, 1} {
+		pdf.SetSection(fmt.Sprintf("Section %c", 'A'+j))
+		pdf.AddPage()
+		pdf.SetFont("Helvetica", "B", 16)
+		pdf.CellFormat(0, 10, pdf.GetSection(), "", 1, "", false, 0, "")
+		pdf.SetFont("Times", "", 11)
+		for k := 0; k < count*3; k++ {
+			pdf.MultiCell(0, 5, lorem(), "", "", false)
+		}
+	}
+	fileStr := example.Filename("Fpdf_AliasCounter")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_AliasCounter.pdf
+}
+
+// TestAliasCounter verifies the replacement of counters in sections, their
+// formats and the alignment of text with aliases.
+func TestAliasCounter(t *testing.T) {
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.SetCompression(false)
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.AliasCounter("{sec}", true)
+	pdf.AliasCounterFormat("{all}", false, gofpdf.PageLabelRomanLower, 0)
+	pdf.AliasCounterFormat("{pad}", false, gofpdf.PageLabelDecimal, 3)
+	var numbers []string
+	for _, section := range []string{"A", "A", "B", "B", "B", "", "C"} {
+		pdf.SetSection(section)
+		pdf.AddPage()
+		numbers = append(numbers, fmt.Sprintf("%d", pdf.SectionPageNo()))
+		pdf.Text(20, 40, "<{sec} {all} {pad}>")
+	}
+	if str := strings.Join(numbers, " "); str != "1 2 1 2 3 1 1" {
+		t.Fatalf("section page numbers are %s", str)
+	}
+	pdf.SetXY(100, 100)
+	pdf.CellFormat(200, 20, "{sec}", "", 1, "R", false, 0, "")
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var values []string
+	for _, m := range regexp.MustCompile(`\(<(.*?)>\)`).FindAllStringSubmatch(buf.String(), -1) {
+		values = append(values, m[1])
+	}
+	if str := strings.Join(values, ", "); str != "2 vii 007, 2 vii 007, 3 vii 007, 3 vii 007, 3 vii 007, 1 vii 007, 1 vii 007" {
+		t.Fatalf("counters are replaced with %s", str)
+	}
+	// The cell text "{sec}" is replaced with "1" and moved by the difference
+	// of their widths, so that it stays aligned right
+	pdf = gofpdf.New("P", "pt", "A4", "")
+	pdf.SetFont("Helvetica", "", 10)
+	shift := pdf.GetStringWidth("{sec}") - pdf.GetStringWidth("1")
+	if !strings.Contains(buf.String(), fmt.Sprintf("q 1 0 0 1 %.2f 0 cm BT", shift)) {
+		t.Fatalf("text with alias is not moved by %.2f", shift)
+	}
+	for _, style := range []gofpdf.PageLabelStyle{gofpdf.PageLabelNone, "x"} {
+		pdf.AliasCounterFormat("{x}", false, style, 0)
+		if pdf.Error() == nil {
+			t.Fatalf("counter style %q is accepted", style)
+		}
+		pdf.ClearError()
+	}
+}
+
+// ExampleFpdf_RegisterImageFromImage demonstrates embedding images drawn or
+// decoded in memory, such as charts and WebP and AVIF images read with
+// decoders of the image package.
+func ExampleFpdf_RegisterImageFromImage() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+	pdf.SetFont("Helvetica", "", 11)
+	// A photograph-like image is embedded as a JPEG image
+	photo := image.NewYCbCr(image.Rect(0, 0, 256, 128), image.YCbCrSubsampleRatio420)
+	for y := 0; y < 128; y++ {
+		for x := 0; x < 256; x++ {
+			photo.Y[photo.YOffset(x, y)] = uint8(x)
+			photo.Cb[photo.COffset(x, y)] = uint8(255 - y*2)
+			photo.Cr[photo.COffset(x, y)] = uint8(y * 2)
+		}
+	}
+	pdf.RegisterImageFromImage("photo", photo, gofpdf.ImageOptions{JPEGQuality: 80})
+	pdf.Image("photo", 10, 10, 80, 0, false, "", 0, "")
+	pdf.Text(100, 30, "YCbCr image embedded with DCTDecode")
+	// An image with transparency is embedded losslessly
+	badge := image.NewNRGBA(image.Rect(0, 0, 100, 100))
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 100; x++ {
+			dx, dy := x-50, y-50
+			if dx*dx+dy*dy < 2500 {
+				badge.SetNRGBA(x, y, color.NRGBA{R: 200, G: 40, B: 40, A: uint8(255 - (dx*dx+dy*dy)/10)})
+			}
+		}
+	}
+	pdf.RegisterImageFromImage("badge", badge, gofpdf.ImageOptions{})
+	pdf.Image("badge", 10, 60, 40, 0, false, "", 0, "")
+	pdf.Text(100, 80, "NRGBA image embedded with FlateDecode")
+	// A paletted image, such as a chart, keeps its palette
+	chart := image.NewPaletted(image.Rect(0, 0, 120, 80), color.Palette{
+		color.White, color.RGBA{R: 40, G: 90, B: 160, A: 255}, color.RGBA{R: 230, G: 140, B: 30, A: 255}})
+	for j, v := range []int{30, 55, 45, 70, 20} {
+		for x := 8 + j*22; x < 24+j*22; x++ {
+			for y := 80 - v; y < 80; y++ {
+				chart.SetColorIndex(x, y, uint8(1+j%2))
+			}
+		}
+	}
+	pdf.RegisterImageFromImage("chart", chart, gofpdf.ImageOptions{})
+	pdf.Image("chart", 10, 110, 60, 0, false, "", 0, "")
+	pdf.Text(100, 130, "Paletted image embedded with its palette")
+	fileStr := example.Filename("Fpdf_RegisterImageFromImage")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_RegisterImageFromImage.pdf
+}
+
+// TestRegisterImageFromImage verifies the embedding of decoded images and of
+// images read with decoders registered with the image package.
+func TestRegisterImageFromImage(t *testing.T) {
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	photo := image.NewYCbCr(image.Rect(0, 0, 8, 4), image.YCbCrSubsampleRatio444)
+	badge := image.NewNRGBA(image.Rect(0, 0, 6, 3))
+	badge.SetNRGBA(1, 1, color.NRGBA{R: 255, A: 128})
+	for _, tc := range []struct {
+		name, tp string
+		img      image.Image
+		filter   string
+		smask    bool
+	}{
+		{"photo", "", photo, "DCTDecode", false},
+		{"photo-png", "PNG", photo, "FlateDecode", false},
+		{"badge", "", badge, "FlateDecode", true},
+		{"badge-jpg", "jpeg", badge, "DCTDecode", false},
+	} {
+		info := pdf.RegisterImageFromImage(tc.name, tc.img, gofpdf.ImageOptions{ImageType: tc.tp})
+		if pdf.Err() {
+			t.Fatalf("%s: %v", tc.name, pdf.Error())
+		}
+		if info.Width() != float64(tc.img.Bounds().Dx()) || info.Height() != float64(tc.img.Bounds().Dy()) {
+			t.Fatalf("%s: size is %.0f x %.0f", tc.name, info.Width(), info.Height())
+		}
+	}
+	pdf.AddPage()
+	for _, name := range []string{"photo", "photo-png", "badge", "badge-jpg"} {
+		pdf.Image(name, 10, 10, 0, 0, false, "", 0, "")
+	}
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if strings.Count(out, "/Filter /DCTDecode") != 2 || !strings.Contains(out, "/SMask") {
+		t.Fatalf("images are not embedded as expected")
+	}
+	pdf = gofpdf.New("P", "pt", "A4", "")
+	pdf.RegisterImageFromImage("photo", photo, gofpdf.ImageOptions{ImageType: "tiff"})
+	if pdf.Error() == nil {
+		t.Fatalf("decoded image is embedded as TIFF image")
+	}
+	pdf = gofpdf.New("P", "pt", "A4", "")
+	pdf.RegisterImageFromImage("photo", photo, gofpdf.ImageOptions{JPEGQuality: 101})
+	if pdf.Error() == nil {
+		t.Fatalf("JPEG quality 101 is accepted")
+	}
+	// The pixels are embedded in the color space of the image, and the
+	// premultiplied pixels of RGBA images are restored
+	pdf = gofpdf.New("P", "pt", "A4", "")
+	pdf.SetCompression(false)
+	gray := image.NewGray(image.Rect(0, 0, 2, 1))
+	gray.Pix[0], gray.Pix[1] = 0x30, 0x31
+	paletted := image.NewPaletted(image.Rect(0, 0, 2, 1), color.Palette{color.Black, color.Transparent})
+	paletted.Pix[1] = 1
+	rgba := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	rgba.SetRGBA(0, 0, color.RGBA{R: 64, G: 32, B: 0, A: 128})
+	pdf.RegisterImageFromImage("gray", gray, gofpdf.ImageOptions{})
+	pdf.RegisterImageFromImage("paletted", paletted, gofpdf.ImageOptions{})
+	rgbaInfo := pdf.RegisterImageFromImage("rgba", rgba, gofpdf.ImageOptions{})
+	low := pdf.RegisterImageFromImage("low", photo, gofpdf.ImageOptions{ImageType: "jpg", JPEGQuality: 10})
+	high := pdf.RegisterImageFromImage("high", photo, gofpdf.ImageOptions{ImageType: "jpg", JPEGQuality: 100})
+	if pdf.Err() {
+		t.Fatal(pdf.Error())
+	}
+	var rgbaEnc, lowEnc, highEnc []byte
+	rgbaEnc, _ = rgbaInfo.GobEncode()
+	lowEnc, _ = low.GobEncode()
+	highEnc, _ = high.GobEncode()
+	if bytes.Equal(lowEnc, highEnc) {
+		t.Fatalf("JPEG quality is ignored")
+	}
+	if !bytes.Contains(rgbaEnc, []byte{127, 63, 0}) {
+		t.Fatalf("premultiplied RGBA pixels are not restored")
+	}
+	pdf.AddPage()
+	for _, name := range []string{"gray", "paletted", "rgba"} {
+		pdf.Image(name, 10, 10, 0, 0, false, "", 0, "")
+	}
+	buf.Reset()
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out = buf.String()
+	if !strings.Contains(out, "/ColorSpace /DeviceGray") || !strings.Contains(out, "/ColorSpace [/Indexed /DeviceRGB 1") ||
+		strings.Count(out, "/SMask") != 2 {
+		t.Fatalf("color spaces of images are not kept")
+	}
+	// The palette of a paletted image with transparency follows its soft mask
+	var palObj, maskObj int
+	pos := strings.Index(out, "/ColorSpace [/Indexed")
+	fmt.Sscanf(out[pos:], "/ColorSpace [/Indexed /DeviceRGB 1 %d 0 R]", &palObj)
+	fmt.Sscanf(out[strings.Index(out[pos:], "/SMask")+pos:], "/SMask %d 0 R", &maskObj)
+	if palObj != maskObj+1 || !strings.Contains(out, fmt.Sprintf("\n%d 0 obj\n<</Length 6>>", palObj)) {
+		t.Fatalf("palette of image is object %d and its soft mask is object %d", palObj, maskObj)
+	}
+	// Images of other types are read with the decoders of the image package
+	image.RegisterFormat("gofpdftest", "GOFPDFTEST", func(r io.Reader) (image.Image, error) {
+		return badge, nil
+	}, func(r io.Reader) (image.Config, error) {
+		return image.Config{ColorModel: badge.ColorModel(), Width: 6, Height: 3}, nil
+	})
+	pdf = gofpdf.New("P", "pt", "A4", "")
+	options := gofpdf.ImageOptions{ImageType: "gofpdftest"}
+	info := pdf.RegisterImageOptionsReader("test", options, strings.NewReader("GOFPDFTEST"))
+	if pdf.Err() || info.Width() != 6 {
+		t.Fatalf("image of registered type is not read: %v", pdf.Error())
+	}
+	options.ImageType = "webp"
+	pdf.RegisterImageOptionsReader("webp", options, strings.NewReader("RIFF"))
+	if err := pdf.Error(); err == nil || err.Error() != "unsupported image type: webp" {
+		t.Fatalf("image of unregistered type is read: %v", err)
+	}
+}
+
+// ExampleFpdf_RegisterImageOptions_bmp demonstrates the embedding of BMP
+// images.
+func ExampleFpdf_RegisterImageOptions_bmp() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+	pdf.SetFont("Helvetica", "", 11)
+	pdf.ImageOptions(example.ImageFile("logo.bmp"), 10, 10, 30, 0, false,
+		gofpdf.ImageOptions{ReadDpi: true}, 0, "")
+	pdf.Text(50, 20, "logo.bmp")
+	fileStr := example.Filename("Fpdf_RegisterImageOptions_bmp")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_RegisterImageOptions_bmp.pdf
+}
+
+// TestRegisterImageOptionsTIFF verifies that CCITT compressed TIFF images
+// are embedded as they are and that BMP images are read.
+func TestRegisterImageOptionsTIFF(t *testing.T) {
+	// tiff returns a little-endian TIFF image with the SHORT tags and the
+	// strip data
+	tiff := func(tags map[uint16]uint16, strip []byte) []byte {
+		var buf bytes.Buffer
+		le := binary.LittleEndian
+		var keys []int
+		for tag := range tags {
+			keys = append(keys, int(tag))
+		}
+		sort.Ints(keys)
+		count := len(keys) + 2
+		offset := 8 + 2 + count*12 + 4
+		buf.WriteString("II")
+		binary.Write(&buf, le, uint16(42))
+		binary.Write(&buf, le, uint32(8))
+		binary.Write(&buf, le, uint16(count))
+		for _, tag := range keys {
+			binary.Write(&buf, le, []uint16{uint16(tag), 3})
+			binary.Write(&buf, le, []uint32{1, uint32(tags[uint16(tag)])})
+		}
+		binary.Write(&buf, le, []uint16{273, 4})
+		binary.Write(&buf, le, []uint32{1, uint32(offset)})
+		binary.Write(&buf, le, []uint16{279, 4})
+		binary.Write(&buf, le, []uint32{1, uint32(len(strip))})
+		binary.Write(&buf, le, uint32(0))
+		buf.Write(strip)
+		return buf.Bytes()
+	}
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.SetCompression(false)
+	for _, tc := range []struct {
+		tags map[uint16]uint16
+		dp   string
+	}{
+		{map[uint16]uint16{256: 16, 257: 2, 259: 4}, "/K -1 /Columns 16 /Rows 2"},
+		{map[uint16]uint16{256: 16, 257: 2, 259: 3, 262: 1, 266: 2, 292: 5},
+			"/K 4 /Columns 16 /Rows 2 /EncodedByteAlign true /BlackIs1 true"},
+	} {
+		strip := []byte("\x01\x80ccitt")
+		options := gofpdf.ImageOptions{ImageType: "tiff"}
+		pdf.RegisterImageOptionsReader(tc.dp, options, bytes.NewReader(tiff(tc.tags, strip)))
+		pdf.AddPage()
+		pdf.Image(tc.dp, 10, 10, 0, 0, false, "", 0, "")
+	}
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, s := range []string{
+		"/ColorSpace /DeviceGray\n/BitsPerComponent 1\n/Filter /CCITTFaxDecode\n" +
+			"/DecodeParms <</K -1 /Columns 16 /Rows 2>>\n/Length 7>>\nstream\n\x01\x80ccitt\nendstream",
+		"/DecodeParms <</K 4 /Columns 16 /Rows 2 /EncodedByteAlign true /BlackIs1 true>>\n" +
+			"/Length 7>>\nstream\n\x80\x01\xc6\xc6\x96\x2e\x2e\nendstream",
+	} {
+		if !strings.Contains(out, s) {
+			t.Fatalf("TIFF image is not embedded as %q", s)
+		}
+	}
+	// Other TIFF images need a TIFF decoder
+	pdf = gofpdf.New("P", "pt", "A4", "")
+	tags := map[uint16]uint16{256: 16, 257: 2, 258: 8, 259: 5}
+	pdf.RegisterImageOptionsReader("lzw", gofpdf.ImageOptions{ImageType: "tif"}, bytes.NewReader(tiff(tags, []byte("lzw"))))
+	if err := pdf.Error(); err == nil || !strings.Contains(err.Error(), "TIFF decoder") {
+		t.Fatalf("LZW compressed TIFF image is read: %v", err)
+	}
+	// A bottom-up 24-bit BMP image and a top-down 1-bit BMP image
+	bmp := func(w, h, bpp int, palette, rows []byte) []byte {
+		var buf bytes.Buffer
+		le := binary.LittleEndian
+		buf.WriteString("BM")
+		binary.Write(&buf, le, []uint32{uint32(54 + len(palette) + len(rows)), 0, uint32(54 + len(palette)), 40})
+		binary.Write(&buf, le, []int32{int32(w), int32(h)})
+		binary.Write(&buf, le, []uint16{1, uint16(bpp)})
+		binary.Write(&buf, le, []uint32{0, uint32(len(rows)), 0, 0, 0, 0})
+		buf.Write(palette)
+		buf.Write(rows)
+		return buf.Bytes()
+	}
+	rgb := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	rgb.SetNRGBA(0, 0, color.NRGBA{R: 255, A: 255})
+	rgb.SetNRGBA(1, 0, color.NRGBA{G: 255, A: 255})
+	rgb.SetNRGBA(0, 1, color.NRGBA{B: 255, A: 255})
+	rgb.SetNRGBA(1, 1, color.NRGBA{R: 1, G: 2, B: 3, A: 255})
+	mono := image.NewPaletted(image.Rect(0, 0, 3, 2), color.Palette{color.RGBA{A: 255}, color.RGBA{R: 255, G: 255, B: 255, A: 255}})
+	mono.SetColorIndex(1, 0, 1)
+	mono.SetColorIndex(0, 1, 1)
+	mono.SetColorIndex(2, 1, 1)
+	for _, tc := range []struct {
+		data []byte
+		img  image.Image
+	}{
+		{bmp(2, 2, 24, nil, []byte{255, 0, 0, 3, 2, 1, 0, 0, 0, 0, 255, 0, 255, 0, 0, 0}), rgb},
+		{bmp(3, -2, 1, []byte{0, 0, 0, 0, 255, 255, 255, 0}, []byte{0x40, 0, 0, 0, 0xa0, 0, 0, 0}), mono},
+	} {
+		pdf = gofpdf.New("P", "pt", "A4", "")
+		got := pdf.RegisterImageOptionsReader("bmp", gofpdf.ImageOptions{ImageType: "BMP"}, bytes.NewReader(tc.data))
+		want := pdf.RegisterImageFromImage("want", tc.img, gofpdf.ImageOptions{ImageType: "png"})
+		if pdf.Err() {
+			t.Fatal(pdf.Error())
+		}
+		gotData, _ := got.GobEncode()
+		wantData, _ := want.GobEncode()
+		if !bytes.Equal(gotData, wantData) {
+			t.Fatalf("BMP image of %d x %d pixels is not read as expected", tc.img.Bounds().Dx(), tc.img.Bounds().Dy())
+		}
+	}
+}
+
+// ExampleFpdf_RegisterImageOptions_jp2 demonstrates the embedding of JPEG
+// 2000 images, which are passed through without being decoded.
+func ExampleFpdf_RegisterImageOptions_jp2() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+	pdf.SetFont("Helvetica", "", 11)
+	pdf.ImageOptions(example.ImageFile("gray.jp2"), 10, 10, 0, 0, false,
+		gofpdf.ImageOptions{ReadDpi: true}, 0, "")
+	pdf.Text(40, 20, "gray.jp2, embedded with JPXDecode")
+	fileStr := example.Filename("Fpdf_RegisterImageOptions_jp2")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_RegisterImageOptions_jp2.pdf
+}
+
+// TestRegisterImageOptionsJPX verifies that JPEG 2000 images are embedded as
+// they are, with the size and color space of their headers.
+func TestRegisterImageOptionsJPX(t *testing.T) {
+	be := binary.BigEndian
+	// codestream returns the main header of a JPEG 2000 codestream
+	codestream := func(w, h uint32, nc int) []byte {
+		var buf bytes.Buffer
+		binary.Write(&buf, be, []uint16{0xff4f, 0xff51, uint16(38 + 3*nc), 0})
+		binary.Write(&buf, be, []uint32{w + 4, h + 2, 4, 2, w, h, 0, 0})
+		binary.Write(&buf, be, uint16(nc))
+		for j := 0; j < nc; j++ {
+			buf.Write([]byte{7, 1, 1})
+		}
+		return buf.Bytes()
+	}
+	box := func(tp string, data []byte) []byte {
+		var buf bytes.Buffer
+		binary.Write(&buf, be, uint32(8+len(data)))
+		buf.WriteString(tp)
+		buf.Write(data)
+		return buf.Bytes()
+	}
+	// jp2 returns a JP2 file with the header boxes and the codestream
+	jp2 := func(stream []byte, hdr ...[]byte) []byte {
+		data := box("jP  ", []byte{0x0d, 0x0a, 0x87, 0x0a})
+		data = append(data, box("jp2h", bytes.Join(hdr, nil))...)
+		return append(data, box("jp2c", stream)...)
+	}
+	sRGB := box("colr", []byte{1, 0, 0, 0, 0, 0, 16})
+	icc := box("colr", []byte{2, 0, 0, 'i', 'c', 'c'})
+	res := box("res ", box("resc", []byte{0x0b, 0x13, 0, 1, 0x2e, 0x4c, 0, 1, 0, 0}))
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.SetCompression(false)
+	for _, tc := range []struct {
+		name string
+		data []byte
+		w, h float64
+		cs   string
+	}{
+		{"j2k", codestream(16, 8, 3), 16, 8, "/ColorSpace /DeviceRGB"},
+		{"jp2", jp2(codestream(5, 7, 4), sRGB), 5, 7, ""},
+		{"jpx", jp2(codestream(3, 3, 3), icc), 3, 3, ""},
+		{"j2c", jp2(codestream(3, 3, 3), sRGB), 3, 3, "/ColorSpace /DeviceRGB"},
+	} {
+		options := gofpdf.ImageOptions{ImageType: tc.name, ReadDpi: true}
+		info := pdf.RegisterImageOptionsReader(tc.name, options, bytes.NewReader(tc.data))
+		if pdf.Err() {
+			t.Fatalf("%s: %v", tc.name, pdf.Error())
+		}
+		if info.Width() != tc.w || info.Height() != tc.h {
+			t.Fatalf("%s: size is %.0f x %.0f", tc.name, info.Width(), info.Height())
+		}
+		pdf.AddPage()
+		pdf.Image(tc.name, 10, 10, 0, 0, false, "", 0, "")
+		var buf bytes.Buffer
+		if err := pdf.Output(&buf); err != nil {
+			t.Fatal(err)
+		}
+		pdf = gofpdf.New("P", "pt", "A4", "")
+		pdf.SetCompression(false)
+		want := fmt.Sprintf("/Height %.0f\n%s/Filter /JPXDecode\n/Length %d>>\nstream\n",
+			tc.h, tc.cs, len(tc.data))
+		if tc.cs != "" {
+			want = strings.Replace(want, tc.cs, tc.cs+"\n", 1)
+		}
+		if out := buf.String(); !strings.HasPrefix(out, "%PDF-1.5") || !strings.Contains(out, want+string(tc.data)) {
+			t.Fatalf("%s: image is not embedded as %q", tc.name, want)
+		}
+	}
+	info := pdf.RegisterImageOptionsReader("res", gofpdf.ImageOptions{ImageType: "JP2", ReadDpi: true},
+		bytes.NewReader(jp2(codestream(3, 3, 3), sRGB, res)))
+	if pdf.Err() || math.Abs(info.Width()-3*72/(11852*0.0254)) > 0.001 {
+		t.Fatalf("resolution of JP2 image is not read: %v", pdf.Error())
+	}
+	pdf.RegisterImageOptionsReader("bad", gofpdf.ImageOptions{ImageType: "jp2"}, strings.NewReader("\xff\x4f\xff\x90"))
+	if pdf.Error() == nil {
+		t.Fatalf("invalid JPEG 2000 image is read")
+	}
+	pdf = gofpdf.New("P", "pt", "A4", "")
+	pdf.SetPDFAConformance(gofpdf.PDFA1B)
+	pdf.AddUTF8Font("dejavu", "", example.FontFile("DejaVuSansCondensed.ttf"))
+	pdf.SetFont("dejavu", "", 12)
+	pdf.AddPage()
+	pdf.RegisterImageOptionsReader("j2k", gofpdf.ImageOptions{ImageType: "j2k"}, bytes.NewReader(codestream(4, 4, 1)))
+	pdf.Image("j2k", 10, 10, 0, 0, false, "", 0, "")
+	if err := pdf.Output(ioutil.Discard); err == nil {
+		t.Fatalf("JPEG 2000 image is accepted in PDF/A-1 document")
+	}
+}
+
+// ExampleFpdf_RegisterImageOptions_icc demonstrates the embedding of images
+// with 16 bits per component and of images with ICC color profiles.
+func ExampleFpdf_RegisterImageOptions_icc() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+	pdf.SetFont("Helvetica", "", 11)
+	// The gopher image holds an ICC profile, which is applied to its colors
+	pdf.Image(example.ImageFile("golang-gopher.png"), 10, 10, 40, 0, false, "", 0, "")
+	pdf.Text(60, 30, "PNG image with an ICC profile")
+	// A smooth gradient keeps its 16 bits per component
+	ramp := image.NewGray16(image.Rect(0, 0, 512, 32))
+	for x := 0; x < 512; x++ {
+		for y := 0; y < 32; y++ {
+			ramp.SetGray16(x, y, color.Gray16{Y: uint16(x * 128)})
+		}
+	}
+	var buf bytes.Buffer
+	png.Encode(&buf, ramp)
+	pdf.RegisterImageOptionsReader("ramp", gofpdf.ImageOptions{ImageType: "png"}, &buf)
+	pdf.Image("ramp", 10, 70, 120, 0, false, "", 0, "")
+	pdf.Text(10, 87, "16-bit gray PNG image")
+	fileStr := example.Filename("Fpdf_RegisterImageOptions_icc")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_RegisterImageOptions_icc.pdf
+}
+
+// TestRegisterImageOptions16Bit verifies that the samples of PNG images with
+// 16 bits per component are kept and that the ICC profiles of PNG and JPEG
+// images are embedded.
+func TestRegisterImageOptions16Bit(t *testing.T) {
+	img := image.NewNRGBA64(image.Rect(0, 0, 3, 2))
+	for j := range img.Pix {
+		img.Pix[j] = uint8(j * 37)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.SetCompression(false)
+	pdf.RegisterImageOptionsReader("rgba", gofpdf.ImageOptions{ImageType: "png"}, bytes.NewReader(buf.Bytes()))
+	pdf.AddPage()
+	pdf.Image("rgba", 10, 10, 0, 0, false, "", 0, "")
+	var out bytes.Buffer
+	if err := pdf.Output(&out); err != nil {
+		t.Fatal(err)
+	}
+	// stream returns the data of the stream whose dictionary holds dict
+	stream := func(dict string) []byte {
+		s := out.String()
+		pos := strings.Index(s, dict)
+		if pos < 0 {
+			t.Fatalf("no stream with %q", dict)
+		}
+		var n int
+		pos += strings.Index(s[pos:], "/Length ")
+		fmt.Sscanf(s[pos:], "/Length %d>>", &n)
+		pos = strings.Index(s[pos:], "stream\n") + pos + len("stream\n")
+		return []byte(s[pos : pos+n])
+	}
+	// decode returns the image of the PNG data, which has the color type
+	// ct and 16 bits per sample
+	decode := func(data []byte, ct byte) image.Image {
+		var png16 bytes.Buffer
+		chunk := func(tp string, data []byte) {
+			binary.Write(&png16, binary.BigEndian, uint32(len(data)))
+			crc := crc32.NewIEEE()
+			crc.Write([]byte(tp))
+			crc.Write(data)
+			png16.WriteString(tp)
+			png16.Write(data)
+			binary.Write(&png16, binary.BigEndian, crc.Sum32())
+		}
+		png16.WriteString("\x89PNG\r\n\x1a\n")
+		chunk("IHDR", []byte{0, 0, 0, 3, 0, 0, 0, 2, 16, ct, 0, 0, 0})
+		chunk("IDAT", data)
+		chunk("IEND", nil)
+		m, err := png.Decode(&png16)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+	rgb := decode(stream("/DecodeParms <</Predictor 15 /Colors 3 /BitsPerComponent 16 /Columns 3>>"), 2)
+	alpha := decode(stream("/DecodeParms <</Predictor 15 /Colors 1 /BitsPerComponent 16 /Columns 3>>"), 0)
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 3; x++ {
+			want := img.NRGBA64At(x, y)
+			r, g, b, _ := rgb.At(x, y).RGBA()
+			a, _, _, _ := alpha.At(x, y).RGBA()
+			if r != uint32(want.R) || g != uint32(want.G) || b != uint32(want.B) || a != uint32(want.A) {
+				t.Fatalf("pixel %d, %d is %d %d %d %d, not %v", x, y, r, g, b, a, want)
+			}
+		}
+	}
+	if !strings.HasPrefix(out.String(), "%PDF-1.5") {
+		t.Fatalf("document with 16-bit image is not PDF 1.5")
+	}
+	// PDF/A-1 documents reduce the images to 8 bits per component
+	pdf = gofpdf.New("P", "pt", "A4", "")
+	pdf.SetPDFAConformance(gofpdf.PDFA1B)
+	pdf.SetCompression(false)
+	pdf.SetAlpha(1, "Normal")
+	gray := image.NewGray16(image.Rect(0, 0, 3, 2))
+	buf.Reset()
+	png.Encode(&buf, gray)
+	pdf.RegisterImageOptionsReader("gray", gofpdf.ImageOptions{ImageType: "png"}, &buf)
+	pdf.AddPage()
+	pdf.Image("gray", 10, 10, 0, 0, false, "", 0, "")
+	out.Reset()
+	pdf.Output(&out)
+	if !strings.Contains(out.String(), "/ColorSpace /DeviceGray\n/BitsPerComponent 8\n") {
+		t.Fatalf("16-bit image is not reduced in PDF/A-1 document")
+	}
+	// ICC profiles
+	profile, err := ioutil.ReadFile(example.ImageFile("sRGB2014.icc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 2, 2)))
+	var iccp bytes.Buffer
+	iccp.WriteString("sRGB\x00\x00")
+	zw := zlib.NewWriter(&iccp)
+	zw.Write(profile)
+	zw.Close()
+	crc := crc32.NewIEEE()
+	crc.Write([]byte("iCCP"))
+	crc.Write(iccp.Bytes())
+	var tagged bytes.Buffer
+	tagged.Write(buf.Bytes()[:33])
+	binary.Write(&tagged, binary.BigEndian, uint32(iccp.Len()))
+	tagged.WriteString("iCCP")
+	tagged.Write(iccp.Bytes())
+	binary.Write(&tagged, binary.BigEndian, crc.Sum32())
+	tagged.Write(buf.Bytes()[33:])
+	// jpg returns a JPEG image of img with the ICC profile in two segments
+	jpg := func(img image.Image) []byte {
+		var data bytes.Buffer
+		jpeg.Encode(&data, img, nil)
+		var segs bytes.Buffer
+		segs.Write(data.Bytes()[:2])
+		for j, part := range [][]byte{profile[:1000], profile[1000:]} {
+			binary.Write(&segs, binary.BigEndian, []uint16{0xffe2, uint16(2 + 14 + len(part))})
+			segs.WriteString("ICC_PROFILE\x00")
+			segs.Write([]byte{byte(j + 1), 2})
+			segs.Write(part)
+		}
+		segs.Write(data.Bytes()[2:])
+		return segs.Bytes()
+	}
+	pdf = gofpdf.New("P", "pt", "A4", "")
+	pdf.SetCompression(false)
+	for _, tc := range []struct {
+		name, tp string
+		data     []byte
+	}{
+		{"png", "png", tagged.Bytes()},
+		{"jpg", "jpg", jpg(image.NewRGBA(image.Rect(0, 0, 2, 2)))},
+		{"gray", "jpg", jpg(image.NewGray(image.Rect(0, 0, 2, 2)))},
+	} {
+		pdf.RegisterImageOptionsReader(tc.name, gofpdf.ImageOptions{ImageType: tc.tp}, bytes.NewReader(tc.data))
+	}
+	pdf.AddPage()
+	for _, name := range []string{"png", "jpg", "gray"} {
+		pdf.Image(name, 10, 10, 0, 0, false, "", 0, "")
+	}
+	out.Reset()
+	if err := pdf.Output(&out); err != nil {
+		t.Fatal(err)
+	}
+	s := out.String()
+	if strings.Count(s, "/ColorSpace [/ICCBased ") != 2 || strings.Count(s, "/N 3 /Alternate /DeviceRGB") != 1 ||
+		!strings.Contains(s, "/ColorSpace /DeviceGray") || !strings.Contains(s, string(profile[:200])) {
+		t.Fatalf("ICC profiles of images are not embedded as expected")
+	}
+}
+
+// ExampleFpdf_ImageOptions_maxDPI demonstrates the resampling of large
+// images to the resolution at which they are placed.
+func ExampleFpdf_ImageOptions_maxDPI() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+	pdf.SetFont("Helvetica", "", 11)
+	// A photograph of 2400 x 1600 pixels
+	photo := image.NewRGBA(image.Rect(0, 0, 2400, 1600))
+	for y := 0; y < 1600; y++ {
+		for x := 0; x < 2400; x++ {
+			photo.SetRGBA(x, y, color.RGBA{R: uint8(x / 10), G: uint8(y / 7), B: uint8((x + y) / 16), A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	jpeg.Encode(&buf, photo, &jpeg.Options{Quality: 95})
+	pdf.RegisterImageOptionsReader("photo", gofpdf.ImageOptions{ImageType: "jpg"}, &buf)
+	// Each thumbnail of the catalog is embedded with 150 dots per inch
+	options := gofpdf.ImageOptions{MaxDPI: 150, JPEGQuality: 80}
+	for j := 0; j < 4; j++ {
+		pdf.ImageOptions("photo", 10+float64(j)*45, 10, 40, 0, false, options, 0, "")
+	}
+	pdf.Text(10, 45, "Thumbnails resampled from 2400 x 1600 to 237 x 158 pixels")
+	fileStr := example.Filename("Fpdf_ImageOptions_maxDPI")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_ImageOptions_maxDPI.pdf
+}
+
+// TestImageOptionsMaxDPI verifies that images placed with a maximum
+// resolution are resampled, and that the images they replace are embedded
+// only if they are placed without being resampled.
+func TestImageOptionsMaxDPI(t *testing.T) {
+	photo := image.NewRGBA(image.Rect(0, 0, 600, 400))
+	var photoJPG bytes.Buffer
+	jpeg.Encode(&photoJPG, photo, nil)
+	// The left half of the icon is red and its right half blue and half
+	// transparent
+	icon := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 4; x++ {
+			icon.SetNRGBA(x, y, color.NRGBA{R: 255, A: 255})
+			if x >= 2 {
+				icon.SetNRGBA(x, y, color.NRGBA{B: 255, A: 128})
+			}
+		}
+	}
+	var iconPNG bytes.Buffer
+	png.Encode(&iconPNG, icon)
+	// output returns the document with the images placed by place
+	output := func(place func(pdf *gofpdf.Fpdf)) string {
+		pdf := gofpdf.New("P", "pt", "A4", "")
+		pdf.SetCompression(false)
+		pdf.RegisterImageOptionsReader("photo", gofpdf.ImageOptions{ImageType: "jpg"}, bytes.NewReader(photoJPG.Bytes()))
+		pdf.RegisterImageOptionsReader("icon", gofpdf.ImageOptions{ImageType: "png"}, bytes.NewReader(iconPNG.Bytes()))
+		pdf.AddPage()
+		place(pdf)
+		var buf bytes.Buffer
+		if err := pdf.Output(&buf); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+	options := gofpdf.ImageOptions{MaxDPI: 100}
+	out := output(func(pdf *gofpdf.Fpdf) {
+		// 72 points are one inch
+		pdf.ImageOptions("photo", 10, 10, 72, 0, false, options, 0, "")
+		pdf.ImageOptions("photo", 10, 100, 72, 0, false, options, 0, "")
+		pdf.ImageOptions("icon", 10, 200, 1.44, 0, false, options, 0, "")
+	})
+	if strings.Count(out, "/Width 100\n/Height 67\n/ColorSpace /DeviceRGB\n/BitsPerComponent 8\n/Filter /DCTDecode") != 1 ||
+		strings.Contains(out, "/Width 600") || strings.Contains(out, "/Width 4\n") {
+		t.Fatalf("images are not resampled")
+	}
+	// The resampled icon has a red and a blue pixel, whose alpha value is
+	// kept in the soft mask
+	pos := strings.Index(out, "/Width 2\n/Height 1\n/ColorSpace /DeviceRGB")
+	if pos < 0 {
+		t.Fatalf("icon is not resampled")
+	}
+	pos += strings.Index(out[pos:], "stream\n") + len("stream\n")
+	r, err := zlib.NewReader(strings.NewReader(out[pos:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pixels, _ := ioutil.ReadAll(r)
+	if !bytes.Equal(pixels, []byte{255, 0, 0, 0, 0, 255}) || !strings.Contains(out, "/SMask") {
+		t.Fatalf("icon is resampled to %v", pixels)
+	}
+	// Images placed as they are, or at a lower resolution than the maximum,
+	// are embedded as well
+	out = output(func(pdf *gofpdf.Fpdf) {
+		pdf.ImageOptions("photo", 10, 10, 72, 0, false, options, 0, "")
+		pdf.ImageOptions("photo", 10, 100, 0, 0, false, gofpdf.ImageOptions{}, 0, "")
+		pdf.ImageOptions("icon", 10, 200, 72, 0, false, options, 0, "")
+	})
+	if !strings.Contains(out, "/Width 100\n") || !strings.Contains(out, "/Width 600\n") ||
+		!strings.Contains(out, "/Width 4\n") {
+		t.Fatalf("images placed as they are are not embedded")
+	}
+}
+
+// ExampleFpdf_ImageOptions_mask demonstrates masking images with separate
+// mask images and with color key masks.
+func ExampleFpdf_ImageOptions_mask() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+	pdf.SetFont("Helvetica", "", 11)
+	pdf.SetFillColor(230, 200, 120)
+	pdf.Rect(0, 0, 210, 60, "F")
+	// A photograph and a separate mask that fades it out at its edges
+	photo := image.NewRGBA(image.Rect(0, 0, 200, 120))
+	vignette := image.NewGray(image.Rect(0, 0, 200, 120))
+	for y := 0; y < 120; y++ {
+		for x := 0; x < 200; x++ {
+			photo.SetRGBA(x, y, color.RGBA{R: uint8(x), G: 90, B: uint8(y * 2), A: 255})
+			dx, dy := float64(x-100)/100, float64(y-60)/60
+			vignette.SetGray(x, y, color.Gray{Y: uint8(255 * math.Max(0, 1-dx*dx-dy*dy))})
+		}
+	}
+	var photoPNG, maskPNG bytes.Buffer
+	png.Encode(&photoPNG, photo)
+	png.Encode(&maskPNG, vignette)
+	pdf.RegisterImageOptionsReader("photo", gofpdf.ImageOptions{ImageType: "png"}, &photoPNG)
+	pdf.RegisterImageOptionsReader("vignette", gofpdf.ImageOptions{ImageType: "png"}, &maskPNG)
+	pdf.ImageOptions("photo", 10, 5, 80, 0, false, gofpdf.ImageOptions{SMaskImage: "vignette"}, 0, "")
+	// The white background of the logo is not painted
+	pdf.ImageOptions(example.ImageFile("logo.jpg"), 120, 10, 40, 0, false,
+		gofpdf.ImageOptions{MaskColors: []int{235, 255, 235, 255, 235, 255}}, 0, "")
+	pdf.Text(10, 70, "Photograph with a separate soft mask, logo with a color key mask")
+	fileStr := example.Filename("Fpdf_ImageOptions_mask")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_ImageOptions_mask.pdf
+}
+
+// TestImageOptionsMask verifies that mask images are applied as soft and
+// stencil masks, and color key masks as ranges of colors.
+func TestImageOptionsMask(t *testing.T) {
+	encode := func(img image.Image) []byte {
+		var buf bytes.Buffer
+		png.Encode(&buf, img)
+		return buf.Bytes()
+	}
+	// An opaque RGB image of 8 x 4 pixels
+	opaque := image.NewNRGBA(image.Rect(0, 0, 8, 4))
+	for j := 3; j < len(opaque.Pix); j += 4 {
+		opaque.Pix[j] = 255
+	}
+	rgb := encode(opaque)
+	// A bilevel gray PNG image of 8 x 1 pixels
+	var bilevel bytes.Buffer
+	chunk := func(tp string, data []byte) {
+		binary.Write(&bilevel, binary.BigEndian, uint32(len(data)))
+		crc := crc32.NewIEEE()
+		crc.Write([]byte(tp))
+		crc.Write(data)
+		bilevel.WriteString(tp)
+		bilevel.Write(data)
+		binary.Write(&bilevel, binary.BigEndian, crc.Sum32())
+	}
+	var idat bytes.Buffer
+	zw := zlib.NewWriter(&idat)
+	zw.Write([]byte{0, 0xf0})
+	zw.Close()
+	bilevel.WriteString("\x89PNG\r\n\x1a\n")
+	chunk("IHDR", []byte{0, 0, 0, 8, 0, 0, 0, 1, 1, 0, 0, 0, 0})
+	chunk("IDAT", idat.Bytes())
+	chunk("IEND", nil)
+	// output returns the document with the images placed by place, and its
+	// error
+	output := func(pdfa bool, place func(pdf *gofpdf.Fpdf)) (string, error) {
+		pdf := gofpdf.New("P", "pt", "A4", "")
+		pdf.SetCompression(false)
+		if pdfa {
+			pdf.SetPDFAConformance(gofpdf.PDFA1B)
+			pdf.AddUTF8Font("dejavu", "", example.FontFile("DejaVuSansCondensed.ttf"))
+		}
+		images := map[string][]byte{
+			"rgb":     rgb,
+			"gray":    encode(image.NewGray(image.Rect(0, 0, 4, 2))),
+			"bilevel": bilevel.Bytes(),
+		}
+		if !pdfa {
+			images["alpha"] = encode(image.NewNRGBA(image.Rect(0, 0, 4, 2)))
+		}
+		for name, data := range images {
+			pdf.RegisterImageOptionsReader(name, gofpdf.ImageOptions{ImageType: "png"}, bytes.NewReader(data))
+		}
+		pdf.AddPage()
+		place(pdf)
+		var buf bytes.Buffer
+		err := pdf.Output(&buf)
+		return buf.String(), err
+	}
+	out, err := output(false, func(pdf *gofpdf.Fpdf) {
+		pdf.ImageOptions("rgb", 10, 10, 80, 0, false, gofpdf.ImageOptions{SMaskImage: "gray"}, 0, "")
+		pdf.ImageOptions("rgb", 10, 60, 80, 0, false, gofpdf.ImageOptions{SMaskImage: "bilevel"}, 0, "")
+		pdf.ImageOptions("rgb", 10, 110, 80, 0, false,
+			gofpdf.ImageOptions{MaskColors: []int{250, 255, 0, 10, 0, 10}}, 0, "")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The masked copies of the image are embedded, with the masks, but
+	// neither the image nor the masks on their own
+	if strings.Count(out, "/Subtype /Image") != 5 ||
+		!regexp.MustCompile(`/SMask \d+ 0 R`).MatchString(out) ||
+		!regexp.MustCompile(`/Mask \d+ 0 R`).MatchString(out) ||
+		!strings.Contains(out, "/Type /XObject /Subtype /Image /Width 4 /Height 2 /ColorSpace /DeviceGray /BitsPerComponent 8") ||
+		!strings.Contains(out, "/Type /XObject /Subtype /Image /Width 8 /Height 1 /ImageMask true /Decode [1 0]") ||
+		!strings.Contains(out, "/Mask [250 255 0 10 0 10 ]") {
+		t.Fatalf("masks are not embedded as expected")
+	}
+	// PDF/A-1 permits stencil masks but not soft masks
+	if _, err = output(true, func(pdf *gofpdf.Fpdf) {
+		pdf.ImageOptions("rgb", 10, 10, 80, 0, false, gofpdf.ImageOptions{SMaskImage: "bilevel"}, 0, "")
+	}); err != nil {
+		t.Fatalf("stencil mask is not accepted in PDF/A-1 document: %v", err)
+	}
+	if _, err = output(true, func(pdf *gofpdf.Fpdf) {
+		pdf.ImageOptions("rgb", 10, 10, 80, 0, false, gofpdf.ImageOptions{SMaskImage: "gray"}, 0, "")
+	}); err == nil {
+		t.Fatalf("soft mask is accepted in PDF/A-1 document")
+	}
+	for _, options := range []gofpdf.ImageOptions{
+		{SMaskImage: "rgb"},
+		{SMaskImage: "alpha"},
+		{MaskColors: []int{0, 255}},
+		{MaskColors: []int{10, 5, 0, 255, 0, 255}},
+		{MaskColors: []int{0, 256, 0, 255, 0, 255}},
+	} {
+		if _, err = output(false, func(pdf *gofpdf.Fpdf) {
+			pdf.ImageOptions("rgb", 10, 10, 80, 0, false, options, 0, "")
+		}); err == nil {
+			t.Fatalf("invalid mask %v is accepted", options)
+		}
+	}
+	// Templates keep the masks of their images
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	tpl := pdf.CreateTemplate(func(tpl *gofpdf.Tpl) {
+		tpl.RegisterImageOptionsReader("rgb", gofpdf.ImageOptions{ImageType: "png"},
+			bytes.NewReader(rgb))
+		tpl.RegisterImageOptionsReader("bilevel", gofpdf.ImageOptions{ImageType: "png"}, bytes.NewReader(bilevel.Bytes()))
+		tpl.ImageOptions("rgb", 10, 10, 80, 0, false, gofpdf.ImageOptions{SMaskImage: "bilevel"}, 0, "")
+	})
+	var buf bytes.Buffer
+	if _, err = tpl.(*gofpdf.FpdfTpl).WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	read, err := gofpdf.ReadTemplate(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pdf.SetCompression(false)
+	pdf.AddPage()
+	pdf.UseTemplate(read)
+	buf.Reset()
+	if err = pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "/ImageMask true") || strings.Count(buf.String(), "/Subtype /Image") != 2 {
+		t.Fatalf("mask of template image is not kept")
+	}
+}
+
+// ExampleFpdf_RegisterImageOptions_shared demonstrates that images are
+// embedded only if they are placed, and only once if the same image is
+// registered with several names.
+func ExampleFpdf_RegisterImageOptions_shared() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFont("Helvetica", "", 11)
+	// Images that may be needed are registered up front; the ones that are
+	// not placed are not embedded
+	for _, name := range []string{"logo.jpg", "logo.gif", "logo-gray.png"} {
+		pdf.RegisterImageOptions(example.ImageFile(name), gofpdf.ImageOptions{})
+	}
+	// The same logo, read by different parts of an application, is embedded
+	// once
+	logo, err := ioutil.ReadFile(example.ImageFile("logo.jpg"))
+	if err == nil {
+		pdf.RegisterImageOptionsReader("header logo", gofpdf.ImageOptions{ImageType: "jpg"}, bytes.NewReader(logo))
+		pdf.RegisterImageOptionsReader("footer logo", gofpdf.ImageOptions{ImageType: "jpg"}, bytes.NewReader(logo))
+	}
+	pdf.SetHeaderFunc(func() {
+		pdf.ImageOptions("header logo", 10, 6, 20, 0, false, gofpdf.ImageOptions{}, 0, "")
+		pdf.SetY(25)
+	})
+	pdf.SetFooterFunc(func() {
+		pdf.ImageOptions("footer logo", 180, 280, 10, 0, false, gofpdf.ImageOptions{}, 0, "")
+	})
+	for j := 1; j <= 3; j++ {
+		pdf.AddPage()
+		pdf.Write(6, fmt.Sprintf("Page %d has the logo in its header and footer, embedded once.", j))
+	}
+	fileStr := example.Filename("Fpdf_RegisterImageOptions_shared")
+	err = pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_RegisterImageOptions_shared.pdf
+}
+
+// TestRegisterImageOptionsUnused verifies that only placed images are
+// embedded, and that images with the same content are embedded once.
+func TestRegisterImageOptionsUnused(t *testing.T) {
+	logo, err := ioutil.ReadFile(example.ImageFile("logo.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// output returns the document with the images logo, under two names, and
+	// logo.png and the image with alpha channel registered, and the images
+	// placed by place, and its error
+	output := func(pdfa bool, place func(pdf *gofpdf.Fpdf)) (string, error) {
+		pdf := gofpdf.New("P", "pt", "A4", "")
+		pdf.SetCompression(false)
+		if pdfa {
+			pdf.SetPDFAConformance(gofpdf.PDFA1B)
+			pdf.AddUTF8Font("dejavu", "", example.FontFile("DejaVuSansCondensed.ttf"))
+		}
+		a := pdf.RegisterImageOptionsReader("a", gofpdf.ImageOptions{ImageType: "jpg"}, bytes.NewReader(logo))
+		b := pdf.RegisterImageOptionsReader("b", gofpdf.ImageOptions{ImageType: "jpg"}, bytes.NewReader(logo))
+		pdf.RegisterImageOptions(example.ImageFile("logo.png"), gofpdf.ImageOptions{})
+		pdf.RegisterImageFromImage("alpha", image.NewNRGBA(image.Rect(0, 0, 4, 2)), gofpdf.ImageOptions{})
+		if a == nil || b == nil || a.Width() != b.Width() {
+			t.Fatalf("image is not registered")
+		}
+		pdf.AddPage()
+		place(pdf)
+		var buf bytes.Buffer
+		err := pdf.Output(&buf)
+		return buf.String(), err
+	}
+	for _, tc := range []struct {
+		name   string
+		place  func(pdf *gofpdf.Fpdf)
+		images int
+	}{
+		{"none", func(pdf *gofpdf.Fpdf) {}, 0},
+		{"both names", func(pdf *gofpdf.Fpdf) {
+			pdf.Image("a", 10, 10, 50, 0, false, "", 0, "")
+			pdf.Image("b", 10, 100, 50, 0, false, "", 0, "")
+		}, 1},
+		{"transformed", func(pdf *gofpdf.Fpdf) {
+			pdf.ImageTransformed("b", gofpdf.TransformMatrix{A: 50, D: 50, E: 10, F: 10})
+		}, 1},
+		{"template", func(pdf *gofpdf.Fpdf) {
+			tpl := pdf.CreateTemplate(func(tpl *gofpdf.Tpl) {
+				tpl.Image("a", 0, 0, 50, 0, false, "", 0, "")
+			})
+			pdf.UseTemplate(tpl)
+		}, 1},
+		{"unused template", func(pdf *gofpdf.Fpdf) {
+			pdf.CreateTemplate(func(tpl *gofpdf.Tpl) {
+				tpl.Image("a", 0, 0, 50, 0, false, "", 0, "")
+			})
+		}, 0},
+		{"page builder", func(pdf *gofpdf.Fpdf) {
+			b := pdf.NewPageBuilder()
+			b.AddPage()
+			b.Image("b", 10, 10, 50, 0, false, "", 0, "")
+			pdf.AddBuiltPages(b)
+		}, 1},
+	} {
+		out, err := output(false, tc.place)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if n := strings.Count(out, "/Subtype /Image"); n != tc.images {
+			t.Fatalf("%s: %d images are embedded, not %d", tc.name, n, tc.images)
+		}
+	}
+	// Images that are not placed are not checked for conformance
+	if _, err = output(true, func(pdf *gofpdf.Fpdf) {
+		pdf.Image("a", 10, 10, 50, 0, false, "", 0, "")
+	}); err != nil {
+		t.Fatalf("image that is not placed is checked for PDF/A-1 conformance: %v", err)
+	}
+	if _, err = output(true, func(pdf *gofpdf.Fpdf) {
+		pdf.Image("alpha", 10, 10, 50, 0, false, "", 0, "")
+	}); err == nil {
+		t.Fatalf("placed image with alpha channel is accepted in PDF/A-1 document")
+	}
+}
+
+// ExampleFpdf_ImageOptions_rotation demonstrates rotating and flipping images
+// as they are placed.
+func ExampleFpdf_ImageOptions_rotation() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+	pdf.SetFont("Helvetica", "", 9)
+	x := 10.0
+	for _, rotation := range []float64{0, 90, 180, 270, 30} {
+		pdf.ImageOptions(example.ImageFile("logo.png"), x, 20, 0, 24, false,
+			gofpdf.ImageOptions{Rotation: rotation}, 0, "")
+		pdf.Text(x, 55, fmt.Sprintf("Rotated by %.0f degrees", rotation))
+		x += 38
+	}
+	x = 10
+	for _, tc := range []struct {
+		label        string
+		flipH, flipV bool
+	}{
+		{"Flipped horizontally", true, false},
+		{"Flipped vertically", false, true},
+		{"Flipped both ways", true, true},
+	} {
+		pdf.ImageOptions(example.ImageFile("logo.png"), x, 70, 30, 0, false,
+			gofpdf.ImageOptions{FlipH: tc.flipH, FlipV: tc.flipV}, 0, "")
+		pdf.Text(x, 100, tc.label)
+		x += 50
+	}
+	fileStr := example.Filename("Fpdf_ImageOptions_rotation")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_ImageOptions_rotation.pdf
+}
+
+// TestImageOptionsRotation verifies that images are rotated and flipped as
+// they are placed, and that JPEG images are placed upright as their EXIF
+// orientation specifies.
+func TestImageOptionsRotation(t *testing.T) {
+	// jpegImage returns a JPEG image of 8 x 4 pixels with the EXIF
+	// orientation orientation, or without EXIF data if it is 0
+	jpegImage := func(orientation uint16) []byte {
+		var buf bytes.Buffer
+		jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 8, 4)), nil)
+		data := buf.Bytes()
+		if orientation == 0 {
+			return data
+		}
+		var exif bytes.Buffer
+		exif.WriteString("Exif\x00\x00MM\x00\x2a\x00\x00\x00\x08")
+		binary.Write(&exif, binary.BigEndian, []uint16{1, 0x0112, 3, 0, 1, orientation, 0})
+		binary.Write(&exif, binary.BigEndian, uint32(0))
+		var app1 bytes.Buffer
+		app1.Write([]byte{0xff, 0xe1})
+		binary.Write(&app1, binary.BigEndian, uint16(exif.Len()+2))
+		app1.Write(exif.Bytes())
+		return append(append(append([]byte(nil), data[:2]...), app1.Bytes()...), data[2:]...)
+	}
+	// output returns the content of the page of the document on which place
+	// places the images "upright" and "sideways", whose EXIF orientation
+	// rotates it clockwise
+	output := func(place func(pdf *gofpdf.Fpdf)) string {
+		pdf := gofpdf.New("P", "pt", "A4", "")
+		pdf.SetCompression(false)
+		pdf.RegisterImageOptionsReader("upright", gofpdf.ImageOptions{ImageType: "jpg"}, bytes.NewReader(jpegImage(0)))
+		sideways := pdf.RegisterImageOptionsReader("sideways", gofpdf.ImageOptions{ImageType: "jpg"},
+			bytes.NewReader(jpegImage(6)))
+		if pdf.Err() {
+			t.Fatal(pdf.Error())
+		}
+		if w, h := sideways.Extent(); w != 4 || h != 8 {
+			t.Fatalf("image whose EXIF orientation rotates it has the size %.0f x %.0f", w, h)
+		}
+		pdf.AddPage()
+		place(pdf)
+		var buf bytes.Buffer
+		if err := pdf.Output(&buf); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+	for _, tc := range []struct {
+		name    string
+		options gofpdf.ImageOptions
+		image   string
+		w, h    float64
+		cm      string
+	}{
+		{"upright", gofpdf.ImageOptions{}, "upright", 0, 0, "q 6.00000 0 0 3.00000 10.00000 "},
+		{"full turn", gofpdf.ImageOptions{Rotation: -360}, "upright", 0, 0, "q 6.00000 0 0 3.00000 10.00000 "},
+		{"exif", gofpdf.ImageOptions{}, "sideways", 0, 0,
+			"q 3.00000 0 0 6.00000 10.00000 775.89000 cm 0.00000 -1.00000 1.00000 0.00000 0.00000 1.00000 cm /I"},
+		{"quarter turn", gofpdf.ImageOptions{Rotation: 90}, "upright", 80, 0,
+			"q 80.00000 0 0 160.00000 10.00000 621.89000 cm 0.00000 1.00000 -1.00000 0.00000 1.00000 0.00000 cm /I"},
+		{"exif and quarter turn", gofpdf.ImageOptions{Rotation: 270}, "sideways", 80, 0,
+			"q 80.00000 0 0 40.00000 10.00000 741.89000 cm -1.00000 0.00000 0.00000 -1.00000 1.00000 1.00000 cm /I"},
+		{"flipped", gofpdf.ImageOptions{FlipH: true}, "upright", 80, 40,
+			"q 80.00000 0 0 40.00000 10.00000 741.89000 cm -1.00000 0.00000 0.00000 1.00000 1.00000 0.00000 cm /I"},
+		{"flipped twice", gofpdf.ImageOptions{FlipH: true, FlipV: true}, "upright", 80, 40,
+			"cm -1.00000 0.00000 0.00000 -1.00000 1.00000 1.00000 cm /I"},
+		{"eighth turn", gofpdf.ImageOptions{Rotation: 45}, "upright", 0, 0,
+			"q 6.36396 0 0 6.36396 10.00000 "},
+	} {
+		out := output(func(pdf *gofpdf.Fpdf) {
+			pdf.ImageOptions(tc.image, 10, 60, tc.w, tc.h, false, tc.options, 0, "")
+		})
+		if !strings.Contains(out, tc.cm) {
+			t.Fatalf("%s: image is not placed with %q", tc.name, tc.cm)
+		}
+		if tc.options.Rotation == -360 && strings.Contains(out, "cm 1.00000") {
+			t.Fatalf("%s: image is rotated", tc.name)
+		}
+	}
+	// Images placed with a matrix are upright, and images are resampled in
+	// the orientation in which they are stored
+	out := output(func(pdf *gofpdf.Fpdf) {
+		pdf.ImageTransformed("sideways", gofpdf.TransformMatrix{A: 20, D: 40, E: 10, F: 10})
+		pdf.ImageOptions("sideways", 10, 100, 2, 0, false, gofpdf.ImageOptions{MaxDPI: 72}, 0, "")
+	})
+	if !strings.Contains(out, "q 20.00000 0.00000 0.00000 40.00000 10.00000 791.89000 cm 0.00000 -1.00000 1.00000 0.00000 0.00000 1.00000 cm /I") ||
+		!strings.Contains(out, "q 2.00000 0 0 4.00000 10.00000 737.89000 cm 0.00000 -1.00000 1.00000 0.00000 0.00000 1.00000 cm /I") ||
+		!regexp.MustCompile(`/Width 4\s+/Height 2\s`).MatchString(out) {
+		t.Fatalf("sideways image is not placed upright with a matrix or when resampled")
+	}
+}
+
+// ExampleFpdf_ImageOptions_inline demonstrates placing small images inline in
+// the content stream of the page.
+func ExampleFpdf_ImageOptions_inline() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+	pdf.SetFont("Helvetica", "", 11)
+	pdf.Text(10, 15, "A hundred distinct icons, each placed inline")
+	for j := 0; j < 100; j++ {
+		icon := image.NewPaletted(image.Rect(0, 0, 8, 8),
+			color.Palette{color.White, color.RGBA{uint8(j * 25 % 256), uint8(j * 7), uint8(255 - j*2), 255}})
+		for k := 0; k < 64; k++ {
+			if (k/8+k%8+j)%3 != 0 {
+				icon.Pix[k] = 1
+			}
+		}
+		name := fmt.Sprintf("icon%d", j)
+		pdf.RegisterImageFromImage(name, icon, gofpdf.ImageOptions{})
+		pdf.ImageOptions(name, 10+float64(j%10)*12, 25+float64(j/10)*12, 10, 10, false,
+			gofpdf.ImageOptions{Inline: true}, 0, "")
+	}
+	fileStr := example.Filename("Fpdf_ImageOptions_inline")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_ImageOptions_inline.pdf
+}
+
+// TestImageOptionsInline verifies that images are placed inline in content
+// streams unless they cannot be inline images.
+func TestImageOptionsInline(t *testing.T) {
+	gray := image.NewGray(image.Rect(0, 0, 4, 2))
+	for j := range gray.Pix {
+		gray.Pix[j] = uint8(j * 30)
+	}
+	paletted := image.NewPaletted(image.Rect(0, 0, 2, 1), color.Palette{color.Black, color.White})
+	var photo bytes.Buffer
+	jpeg.Encode(&photo, image.NewGray(image.Rect(0, 0, 8, 4)), nil)
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.SetCompression(false)
+	pdf.RegisterImageFromImage("gray", gray, gofpdf.ImageOptions{})
+	pdf.RegisterImageFromImage("paletted", paletted, gofpdf.ImageOptions{})
+	pdf.RegisterImageFromImage("alpha", image.NewNRGBA(image.Rect(0, 0, 4, 2)), gofpdf.ImageOptions{})
+	pdf.RegisterImageOptionsReader("photo", gofpdf.ImageOptions{ImageType: "jpg"}, &photo)
+	pdf.AddPage()
+	inline := gofpdf.ImageOptions{Inline: true}
+	for _, name := range []string{"gray", "paletted", "photo", "alpha"} {
+		pdf.ImageOptions(name, 10, 10, 40, 0, false, inline, 0, "")
+	}
+	pdf.ImageOptions("photo", 10, 100, 40, 0, false, inline, 0, "")
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	// Only the image with an alpha channel is embedded as an object, with its
+	// soft mask, and the other images are placed as often as they are placed
+	if strings.Count(out, "/Subtype /Image") != 2 || strings.Count(out, " Do Q") != 1 ||
+		strings.Count(out, "BI /W 8 /H 4 /CS /G /BPC 8 /F [/A85 /DCT] ID ") != 2 ||
+		!strings.Contains(out, "BI /W 2 /H 1 /CS [/I /RGB 1 <000000ffffff>] /BPC 8 /F [/A85 /Fl] ID ") {
+		t.Fatalf("images are not placed inline as expected")
+	}
+	m := regexp.MustCompile(`BI /W 4 /H 2 /CS /G /BPC 8 /F \[/A85 /Fl\] ID (\S*)~> EI Q`).FindStringSubmatch(out)
+	if m == nil {
+		t.Fatalf("gray image is not placed inline")
+	}
+	data := make([]byte, len(m[1]))
+	n, _, err := ascii85.Decode(data, []byte(m[1]), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := zlib.NewReader(bytes.NewReader(data[:n]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pix, err := ioutil.ReadAll(r)
+	if err != nil || !bytes.Equal(pix, gray.Pix) {
+		t.Fatalf("data of inline image is not the pixels of the image")
+	}
+}
+
+// ExampleFpdf_RegisterImageOptionsOpener demonstrates registering images whose
+// data is read only as the document is output.
+func ExampleFpdf_RegisterImageOptionsOpener() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFont("Helvetica", "", 11)
+	pdf.AddPage()
+	y := 20.0
+	for _, name := range []string{"logo.jpg", "logo.png", "logo.gif"} {
+		fileStr := example.ImageFile(name)
+		// Only the size and properties of the image are kept until the
+		// document is output
+		pdf.RegisterImageOptionsOpener(name, gofpdf.ImageOptions{ImageType: name[5:]},
+			func() (io.ReadCloser, error) {
+				return os.Open(fileStr)
+			})
+		pdf.ImageOptions(name, 10, y, 30, 0, false, gofpdf.ImageOptions{}, 0, "")
+		pdf.Text(50, y+10, name+" is read when the document is output")
+		y += 40
+	}
+	// The Deferred option does the same for image files
+	pdf.ImageOptions(example.ImageFile("logo-gray.png"), 10, y, 30, 0, false,
+		gofpdf.ImageOptions{Deferred: true}, 0, "")
+	fileStr := example.Filename("Fpdf_RegisterImageOptionsOpener")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_RegisterImageOptionsOpener.pdf
+}
+
+// TestRegisterImageOptionsOpener verifies that the data of images registered
+// with openers is read as the document is output, and that the document is
+// the same as if the images were read when they were registered.
+func TestRegisterImageOptionsOpener(t *testing.T) {
+	logo, err := ioutil.ReadFile(example.ImageFile("logo.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gray := new(bytes.Buffer)
+	png.Encode(gray, image.NewGray(image.Rect(0, 0, 4, 4)))
+	// output returns the streams of the document in which the logo, with the
+	// gray image as mask in the second place, is placed, with register
+	// registering the images, in an order that does not depend on the order
+	// of the objects
+	streamRe := regexp.MustCompile(`(?s)stream\n(.*?)\nendstream`)
+	output := func(register func(pdf *gofpdf.Fpdf, name string, data []byte)) ([]byte, error) {
+		pdf := gofpdf.New("P", "mm", "A4", "")
+		pdf.SetCreationDate(time.Date(2020, 5, 4, 3, 2, 1, 0, time.UTC))
+		pdf.SetModificationDate(time.Date(2021, 6, 5, 4, 3, 2, 0, time.UTC))
+		register(pdf, "logo", logo)
+		register(pdf, "gray", gray.Bytes())
+		pdf.AddPage()
+		pdf.Image("logo", 10, 10, 40, 0, false, "", 0, "")
+		pdf.ImageOptions("logo", 10, 60, 40, 0, false, gofpdf.ImageOptions{SMaskImage: "gray"}, 0, "")
+		pdf.Image("logo", 10, 110, 20, 0, false, "", 0, "")
+		var buf bytes.Buffer
+		err := pdf.Output(&buf)
+		streams := streamRe.FindAll(buf.Bytes(), -1)
+		sort.Slice(streams, func(i, j int) bool { return bytes.Compare(streams[i], streams[j]) < 0 })
+		return bytes.Join(streams, nil), err
+	}
+	want, err := output(func(pdf *gofpdf.Fpdf, name string, data []byte) {
+		pdf.RegisterImageOptionsReader(name, gofpdf.ImageOptions{ImageType: "png"}, bytes.NewReader(data))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opened := make(map[string]int)
+	got, err := output(func(pdf *gofpdf.Fpdf, name string, data []byte) {
+		pdf.RegisterImageOptionsOpener(name, gofpdf.ImageOptions{ImageType: "png"}, func() (io.ReadCloser, error) {
+			opened[name]++
+			return ioutil.NopCloser(bytes.NewReader(data)), nil
+		})
+		if opened[name] != 1 {
+			t.Fatalf("image is opened %d times as it is registered", opened[name])
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("document with images read as it is output differs")
+	}
+	// The logo and the mask are read as they are registered, as the masked
+	// copy is placed and as they are output, and the logo once more for its
+	// masked copy
+	if opened["logo"] != 4 || opened["gray"] != 3 {
+		t.Fatalf("images are opened %v times", opened)
+	}
+	// Image files are read as the document is output with the Deferred
+	// option
+	dir, err := ioutil.TempDir("", "gofpdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, remove := range []bool{false, true} {
+		got, err = output(func(pdf *gofpdf.Fpdf, name string, data []byte) {
+			fileStr := filepath.Join(dir, name+".png")
+			if err := ioutil.WriteFile(fileStr, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			pdf.RegisterImageOptions(fileStr, gofpdf.ImageOptions{Deferred: true})
+			pdf.RegisterImageOptionsOpener(name, gofpdf.ImageOptions{ImageType: "png"}, func() (io.ReadCloser, error) {
+				return os.Open(fileStr)
+			})
+			if remove {
+				os.Remove(fileStr)
+			}
+		})
+		switch {
+		case !remove && (err != nil || !bytes.Equal(got, want)):
+			t.Fatalf("document with image files read as it is output differs: %v", err)
+		case remove && err == nil:
+			t.Fatalf("image file that is removed before the document is output is not reported")
+		}
+	}
+	// Images that change or cannot be opened are reported
+	for _, tc := range []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"changed", gray.Bytes(), nil},
+		{"unavailable", nil, fmt.Errorf("unavailable")},
+	} {
+		_, err = output(func(pdf *gofpdf.Fpdf, name string, data []byte) {
+			calls := 0
+			pdf.RegisterImageOptionsOpener(name, gofpdf.ImageOptions{ImageType: "png"}, func() (io.ReadCloser, error) {
+				calls++
+				if calls > 1 && name == "logo" {
+					if tc.err != nil {
+						return nil, tc.err
+					}
+					data = tc.data
+				}
+				return ioutil.NopCloser(bytes.NewReader(data)), nil
+			})
+		})
+		if err == nil {
+			t.Fatalf("%s image is not reported", tc.name)
+		}
+	}
+}
+
+// ExampleFpdf_CapturePageRegion demonstrates a legend that is drawn once on
+// the first page and stamped on the pages that follow.
+func ExampleFpdf_CapturePageRegion() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFont("Helvetica", "", 9)
+	pdf.AddPage()
+	pdf.SetDrawColor(90, 90, 90)
+	pdf.SetFillColor(245, 245, 235)
+	pdf.RoundedRect(140, 10, 60, 34, 3, "1234", "FD")
+	pdf.Text(144, 17, "Legend")
+	for j, name := range []string{"Measured", "Estimated", "Target"} {
+		y := 24 + float64(j)*7
+		r, g, b := 200-j*80, 60+j*60, 60+j*50
+		pdf.SetFillColor(r, g, b)
+		pdf.Rect(144, y-3, 6, 4, "F")
+		pdf.Text(153, y, name)
+	}
+	legend := pdf.CapturePageRegion(140, 10, 60, 34)
+	for page := 1; page <= 4; page++ {
+		if page > 1 {
+			pdf.AddPage()
+			pdf.UseTemplate(legend)
+		}
+		pdf.Text(10, 60, fmt.Sprintf("Page %d", page))
+	}
+	// The legend may be placed at another position and size, too
+	pdf.UseTemplateScaled(legend, gofpdf.PointType{X: 10, Y: 80}, gofpdf.SizeType{Wd: 120, Ht: 68})
+	fileStr := example.Filename("Fpdf_CapturePageRegion")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_CapturePageRegion.pdf
+}
+
+// TestCapturePageRegion verifies the form XObjects written for captured page
+// regions and the errors of misused regions.
+func TestCapturePageRegion(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false)
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.AddPage()
+	pdf.TransformBegin()
+	pdf.Rect(10, 10, 50, 20, "D")
+	pdf.Text(12, 20, "Stamp")
+	stamp := pdf.CapturePageRegion(10, 10, 50, 20)
+	pdf.TransformEnd()
+	corner := pdf.CapturePageRegion(0, 0, 30, 30)
+	if !pdf.Ok() {
+		t.Fatal(pdf.Error())
+	}
+	if stamp.ID() == corner.ID() {
+		t.Fatal("regions of the same content have the same ID")
+	}
+	if !bytes.HasSuffix(stamp.Bytes(), []byte("ET\nQ\n")) {
+		t.Fatalf("transformation not ended in region: %q", stamp.Bytes())
+	}
+	if corner, size := stamp.Size(); corner.X != 10 || corner.Y != 10 || size.Wd != 50 || size.Ht != 20 {
+		t.Fatalf("region is %.2f x %.2f at (%.2f, %.2f)", size.Wd, size.Ht, corner.X, corner.Y)
+	}
+	if _, err := stamp.Serialize(); err == nil {
+		t.Fatal("no error when region is serialized")
+	}
+	pdf.AddPage()
+	pdf.UseTemplate(stamp)
+	pdf.UseTemplateScaled(corner, gofpdf.PointType{X: 100, Y: 100}, gofpdf.SizeType{Wd: 60, Ht: 60})
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	str := buf.String()
+	for _, s := range []string{
+		"/BBox [28.34646 756.85063 170.07874 813.54354]",
+		"/Matrix [1 0 0 1 -28.34646 -756.85063] /Resources 2 0 R",
+		"/BBox [0.00000 756.85063 85.03937 841.89000]",
+		"q 1.0000 0 0 1.0000 28.3465 756.8506 cm\n/TPL" + stamp.ID() + " Do Q",
+		"q 2.0000 0 0 2.0000 283.4646 388.3467 cm\n/TPL" + corner.ID() + " Do Q",
+		"/TPL" + stamp.ID() + " ", "/TPL" + corner.ID() + " ",
+	} {
+		if !strings.Contains(str, s) {
+			t.Fatalf("%q not found in document", s)
+		}
+	}
+	// Only the regions that are used are written
+	if n := strings.Count(str, "/Subtype /Form"); n != 2 {
+		t.Fatalf("%d form XObjects written instead of 2", n)
+	}
+
+	for name, fnc := range map[string]func(pdf *gofpdf.Fpdf){
+		"region captured without page": func(pdf *gofpdf.Fpdf) {
+			pdf.CapturePageRegion(0, 0, 10, 10)
+		},
+		"region of zero width": func(pdf *gofpdf.Fpdf) {
+			pdf.AddPage()
+			pdf.CapturePageRegion(0, 0, 0, 10)
+		},
+		"region captured within group": func(pdf *gofpdf.Fpdf) {
+			pdf.AddPage()
+			pdf.BeginTransparencyGroup(false, false)
+			pdf.CapturePageRegion(0, 0, 10, 10)
+		},
+		"region used in another document": func(pdf *gofpdf.Fpdf) {
+			other := gofpdf.New("P", "mm", "A4", "")
+			other.AddPage()
+			pdf.AddPage()
+			pdf.UseTemplate(other.CapturePageRegion(0, 0, 10, 10))
+		},
+	} {
+		pdf = gofpdf.New("P", "mm", "A4", "")
+		fnc(pdf)
+		if pdf.Ok() {
+			t.Fatalf("no error when %s", name)
+		}
+	}
+}
+
+// ExampleFpdf_AddTextAnnotation demonstrates a document prepared for review
+// with sticky notes and text markups.
+func ExampleFpdf_AddTextAnnotation() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFont("Helvetica", "", 14)
+	pdf.AddPage()
+	// quad returns the quadrilateral that covers txt written at (x, y)
+	quad := func(x, y float64, txt string) []gofpdf.PointType {
+		_, size := pdf.GetFontSize()
+		w := pdf.GetStringWidth(txt)
+		top, bottom := y-0.8*size, y+0.25*size
+		return []gofpdf.PointType{{X: x, Y: top}, {X: x + w, Y: top}, {X: x, Y: bottom}, {X: x + w, Y: bottom}}
+	}
+	lines := []string{
+		"The quarterly figures are final.",
+		"Revenue grew by twelve percent.",
+		"Costs remained within the budget.",
+		"The outlook is unchanged.",
+	}
+	for j, line := range lines {
+		pdf.Text(20, 40+float64(j)*12, line)
+	}
+	reviewer := "A. Reviewer"
+	pdf.AddHighlight(quad(20, 40, lines[0]), gofpdf.RGBType{R: 255, G: 230, B: 0}, reviewer, "Please confirm with finance.")
+	pdf.AddUnderline(quad(20, 52, lines[1]), gofpdf.RGBType{R: 0, G: 90, B: 200}, reviewer, "Add the exact figure.")
+	pdf.AddSquiggly(quad(20, 64, lines[2]), gofpdf.RGBType{R: 220, G: 0, B: 0}, reviewer, "Which budget?")
+	pdf.AddStrikeOut(quad(20, 76, lines[3]), gofpdf.RGBType{R: 220, G: 0, B: 0}, reviewer, "Remove this sentence.")
+	pdf.AddTextAnnotation(110, 36, gofpdf.RGBType{R: 255, G: 200, B: 0}, reviewer, "Looks good overall.")
+	pdf.AddTextAnnotationOptions(110, 60, gofpdf.MarkupAnnotationOptions{
+		Author:   "B. Editor",
+		Contents: "Consider a chart for these figures.",
+		Subject:  "Layout",
+		Icon:     "Comment",
+		Color:    &gofpdf.RGBAType{R: 80, G: 180, B: 80},
+		Modified: time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC),
+		Open:     true,
+	})
+	fileStr := example.Filename("Fpdf_AddTextAnnotation")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_AddTextAnnotation.pdf
+}
+
+// TestMarkupAnnotations verifies the objects written for text annotations and
+// text markup annotations and the errors of invalid annotations.
+func TestMarkupAnnotations(t *testing.T) {
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.SetModificationDate(time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC))
+	pdf.AddPage()
+	quad := []gofpdf.PointType{{X: 100, Y: 90}, {X: 200, Y: 90}, {X: 100, Y: 110}, {X: 200, Y: 110}}
+	pdf.AddHighlight(quad, gofpdf.RGBType{R: 255, G: 255, B: 0}, "Ann", "Check")
+	pdf.AddPage()
+	pdf.AddTextAnnotationOptions(50, 100, gofpdf.MarkupAnnotationOptions{Author: "Bob", Contents: "Note",
+		Subject: "Topic", Icon: "Key", Open: true, Color: &gofpdf.RGBAType{R: 0, G: 0, B: 255, Alpha: 0.5},
+		Modified: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)})
+	pdf.AddStrikeOut(quad, gofpdf.RGBType{}, "", "")
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	str := buf.String()
+	for _, s := range []string{
+		"%PDF-1.4",
+		"<</Type /Annot /Subtype /Highlight /Rect [100.00 731.89 200.00 751.89]\n/F 4 /QuadPoints [100.00 751.89 200.00 751.89 100.00 731.89 200.00 731.89]\n" +
+			"/C [1.000 1.000 0.000] /CA 1.000\n/T (\xfe\xff\x00A\x00n\x00n) /Contents (\xfe\xff\x00C\x00h\x00e\x00c\x00k)\n" +
+			"/M (D:20261016093000)\n/AP <</N 3 0 R>>\n/Popup 5 0 R>>",
+		"<</Type /Annot /Subtype /Popup /Rect [210.00 631.89 390.00 751.89] /F 28 /Parent 4 0 R /Open false>>",
+		"/Resources <</ExtGState <</GS1 <</BM /Multiply>>>>>> ",
+		"/GS1 gs 1.000 1.000 0.000 rg\n100.00 751.89 m 200.00 751.89 l 200.00 731.89 l 100.00 731.89 l h f",
+		"<</Type /Annot /Subtype /Text /Rect [50.00 721.89 70.00 741.89]\n/F 28 /Name /Key /Open true\n/C [0.000 0.000 1.000] /CA 0.500\n",
+		"/Subj (\xfe\xff\x00T\x00o\x00p\x00i\x00c)\n/M (D:20260102030405)\n/Popup ",
+		"/Subtype /Popup /Rect [80.00 621.89 260.00 741.89] /F 28 /Parent 6 0 R /Open true>>",
+		"0.000 0.000 0.000 RG\n1.25 w 100.00 741.89 m 200.00 741.89 l S",
+	} {
+		if !strings.Contains(str, s) {
+			t.Fatalf("%q not found in document", s)
+		}
+	}
+	// Each annotation and its popup are listed in the annotations of its page
+	if !regexp.MustCompile(`/Annots \[(\d+) 0 R (\d+) 0 R \]`).MatchString(str) {
+		t.Fatal("annotations of page not found")
+	}
+	// Text annotations have appearances only in PDF/A documents
+	if n := strings.Count(str, "/AP <</N "); n != 2 {
+		t.Fatalf("%d appearances instead of 2", n)
+	}
+	pdf = gofpdf.New("P", "pt", "A4", "")
+	pdf.SetPDFAConformance(gofpdf.PDFA2B)
+	pdf.AddPage()
+	pdf.AddTextAnnotation(10, 10, gofpdf.RGBType{R: 255}, "", "")
+	buf.Reset()
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "/AP <</N ") {
+		t.Fatal("text annotation of PDF/A document without appearance")
+	}
+
+	for name, fnc := range map[string]func(pdf *gofpdf.Fpdf){
+		"annotation without page": func(pdf *gofpdf.Fpdf) {
+			pdf.AddTextAnnotation(10, 10, gofpdf.RGBType{}, "", "")
+		},
+		"unknown icon": func(pdf *gofpdf.Fpdf) {
+			pdf.AddPage()
+			pdf.AddTextAnnotationOptions(10, 10, gofpdf.MarkupAnnotationOptions{Icon: "Pin"})
+		},
+		"unknown markup": func(pdf *gofpdf.Fpdf) {
+			pdf.AddPage()
+			pdf.AddTextMarkupAnnotation("Caret", quad, gofpdf.MarkupAnnotationOptions{})
+		},
+		"incomplete quadrilateral": func(pdf *gofpdf.Fpdf) {
+			pdf.AddPage()
+			pdf.AddUnderline(quad[:3], gofpdf.RGBType{}, "", "")
+		},
+		"highlight in PDF/A-1": func(pdf *gofpdf.Fpdf) {
+			pdf.SetPDFAConformance(gofpdf.PDFA1B)
+			pdf.AddPage()
+			pdf.AddHighlight(quad, gofpdf.RGBType{}, "", "")
+			pdf.Output(ioutil.Discard)
+		},
+		"annotation in PDF/X": func(pdf *gofpdf.Fpdf) {
+			pdf.SetPDFXConformance(gofpdf.PDFX4)
+			pdf.AddPage()
+			pdf.AddSquiggly(quad, gofpdf.RGBType{}, "", "")
+			pdf.Output(ioutil.Discard)
+		},
+	} {
+		pdf = gofpdf.New("P", "pt", "A4", "")
+		fnc(pdf)
+		if pdf.Ok() {
+			t.Fatalf("no error with %s", name)
+		}
+	}
+}
+
+// TestMarkupAnnotationDestinations verifies that the links and bookmarks of a
+// document with markup annotations, which are written before the pages, refer
+// to the page dictionaries
+func TestMarkupAnnotationDestinations(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false)
+	pdf.SetFont("Helvetica", "", 12)
+	link := pdf.AddLink()
+	pdf.AddPage()
+	pdf.AddTextAnnotation(20, 20, gofpdf.RGBType{R: 255, G: 220}, "Reviewer", "Check the figures")
+	pdf.AddShapeAnnotation("Square", []gofpdf.PointType{{X: 20, Y: 40}, {X: 60, Y: 60}}, gofpdf.AnnotationOptions{})
+	pdf.AddFreeTextAnnotation(80, 40, 60, 20, "Free text", gofpdf.FreeTextStyle{})
+	pdf.AddStampAnnotation(20, 80, 50, 15, gofpdf.StampDraft)
+	pdf.Link(20, 100, 80, 8, link)
+	pdf.AddPage()
+	pdf.SetLink(link, 0, -1)
+	pdf.Bookmark("Second page", 0, 0)
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	doc := buf.String()
+	pages := pageObjNums(doc)
+	if len(pages) != 2 {
+		t.Fatalf("%d page dictionaries found", len(pages))
+	}
+	dests := regexp.MustCompile(`/Dest \[(\d+) 0 R`).FindAllStringSubmatch(doc, -1)
+	if len(dests) != 2 {
+		t.Fatalf("%d destinations found", len(dests))
+	}
+	for _, d := range dests {
+		if d[1] != pages[1] {
+			t.Fatalf("destination %s does not refer to page object %s", d[0], pages[1])
+		}
+	}
+}
+
+// ExampleFpdf_AddShapeAnnotation demonstrates a floor plan at the scale 1:100
+// that is marked up with shape annotations, some of which measure it.
+func ExampleFpdf_AddShapeAnnotation() {
+	pdf := gofpdf.New("L", "mm", "A4", "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.AddPage()
+	pdf.Text(20, 20, "Floor plan, 1:100")
+	pdf.SetLineWidth(0.8)
+	pdf.Rect(20, 30, 120, 80, "D")
+	pdf.Line(80, 30, 80, 110)
+	pdf.Line(20, 70, 80, 70)
+	scale := &gofpdf.AnnotationScale{Label: "1 mm = 0.1 m", Factor: 0.1, Unit: "m", Decimals: 2}
+	reviewer := gofpdf.MarkupAnnotationOptions{Author: "Site engineer"}
+	options := func(contents string) gofpdf.AnnotationOptions {
+		opt := gofpdf.AnnotationOptions{MarkupAnnotationOptions: reviewer}
+		opt.Contents = contents
+		opt.Color = &gofpdf.RGBAType{R: 0, G: 90, B: 200}
+		return opt
+	}
+	opt := options("Length of the building")
+	opt.LineEndings = [2]string{"ClosedArrow", "ClosedArrow"}
+	opt.InteriorColor = &gofpdf.RGBType{R: 0, G: 90, B: 200}
+	opt.Scale = scale
+	pdf.AddShapeAnnotation("Line", []gofpdf.PointType{{X: 20, Y: 120}, {X: 140, Y: 120}}, opt)
+	opt = options("Floor area of the living room")
+	opt.Scale = scale
+	opt.BorderDashes = []float64{2, 1}
+	pdf.AddShapeAnnotation("Polygon", []gofpdf.PointType{{X: 80, Y: 30}, {X: 140, Y: 30},
+		{X: 140, Y: 110}, {X: 80, Y: 110}}, opt)
+	opt = options("Window to be moved")
+	opt.Color = &gofpdf.RGBAType{R: 220, G: 0, B: 0}
+	opt.InteriorColor = &gofpdf.RGBType{R: 255, G: 220, B: 220}
+	opt.BorderWidth = 0.6
+	pdf.AddShapeAnnotation("Circle", []gofpdf.PointType{{X: 14, Y: 42}, {X: 26, Y: 54}}, opt)
+	opt = options("Check the door")
+	opt.Color = &gofpdf.RGBAType{R: 220, G: 0, B: 0}
+	pdf.AddShapeAnnotation("Square", []gofpdf.PointType{{X: 74, Y: 64}, {X: 86, Y: 76}}, opt)
+	opt = options("Route of the new cable")
+	opt.LineEndings = [2]string{"Circle", "OpenArrow"}
+	pdf.AddShapeAnnotation("PolyLine", []gofpdf.PointType{{X: 30, Y: 100}, {X: 60, Y: 100},
+		{X: 60, Y: 80}, {X: 120, Y: 80}}, opt)
+	var strokes [][]gofpdf.PointType
+	for j := 0; j < 3; j++ {
+		var stroke []gofpdf.PointType
+		for k := 0; k <= 20; k++ {
+			x := 160 + float64(k)*4
+			stroke = append(stroke, gofpdf.PointType{X: x, Y: 50 + float64(j)*12 + 3*math.Sin(float64(k))})
+		}
+		strokes = append(strokes, stroke)
+	}
+	opt = options("Notes of the meeting")
+	opt.BorderWidth = 0.5
+	pdf.AddInkAnnotation(strokes, opt)
+	fileStr := example.Filename("Fpdf_AddShapeAnnotation")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_AddShapeAnnotation.pdf
+}
+
+// TestShapeAnnotations verifies the objects written for shape annotations
+// and the errors of invalid ones.
+func TestShapeAnnotations(t *testing.T) {
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.AddPage()
+	pdf.AddShapeAnnotation("Line", []gofpdf.PointType{{X: 100, Y: 100}, {X: 200, Y: 100}}, gofpdf.AnnotationOptions{
+		BorderWidth: 2, BorderDashes: []float64{4, 2}, LineEndings: [2]string{"", "ClosedArrow"},
+		InteriorColor: &gofpdf.RGBType{R: 255},
+		Scale:         &gofpdf.AnnotationScale{Label: "1 pt = 2 cm", Factor: 2, Unit: "cm", Decimals: 1},
+	})
+	pdf.AddShapeAnnotation("Square", []gofpdf.PointType{{X: 10, Y: 10}, {X: 50, Y: 30}}, gofpdf.AnnotationOptions{})
+	pdf.AddInkAnnotation([][]gofpdf.PointType{{{X: 10, Y: 10}, {X: 20, Y: 20}}, {{X: 30, Y: 10}, {X: 40, Y: 20}}},
+		gofpdf.AnnotationOptions{})
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	str := buf.String()
+	for _, s := range []string{
+		"%PDF-1.7",
+		"<</Type /Annot /Subtype /Line /Rect [88.00 729.89 212.00 753.89]\n/F 4 /BS <</W 2.00 /S /D /D [4.00 2.00]>> " +
+			"/IC [1.000 0.000 0.000] /L [100.00 741.89 200.00 741.89] /LE [/None /ClosedArrow]\n/IT /LineDimension\n/Cap true\n",
+		"/X [<</Type /NumberFormat /U (\xfe\xff\x00c\x00m) /C 2.000000 /F /D /D 10>>]",
+		"1.000 0.000 0.000 RG 2.00 w 1.000 0.000 0.000 rg [4.00 2.00] 0 d\n100.00 741.89 m 200.00 741.89 l S\n[] 0 d\n" +
+			"188.00 747.89 m 200.00 741.89 l 188.00 735.89 l b\n",
+		"<</Type /Annot /Subtype /Square /Rect [10.00 811.89 50.00 831.89]\n/F 4 /BS <</W 1.00>>\n",
+		"10.50 812.39 39.00 19.00 re s",
+		"/InkList [[10.00 831.89 20.00 821.89] [30.00 831.89 40.00 821.89]]",
+		"1 J 1 j\n10.00 831.89 m 20.00 821.89 l S\n30.00 831.89 m 40.00 821.89 l S",
+	} {
+		if !strings.Contains(str, s) {
+			t.Fatalf("%q not found in document", s)
+		}
+	}
+
+	square := []gofpdf.PointType{{X: 10, Y: 10}, {X: 20, Y: 20}}
+	for name, fnc := range map[string]func(pdf *gofpdf.Fpdf){
+		"unknown shape": func(pdf *gofpdf.Fpdf) {
+			pdf.AddShapeAnnotation("Star", square, gofpdf.AnnotationOptions{})
+		},
+		"polygon with two vertices": func(pdf *gofpdf.Fpdf) {
+			pdf.AddShapeAnnotation("Polygon", square, gofpdf.AnnotationOptions{})
+		},
+		"line with three points": func(pdf *gofpdf.Fpdf) {
+			pdf.AddShapeAnnotation("Line", append(square, square[0]), gofpdf.AnnotationOptions{})
+		},
+		"ink stroke with one point": func(pdf *gofpdf.Fpdf) {
+			pdf.AddInkAnnotation([][]gofpdf.PointType{square, square[:1]}, gofpdf.AnnotationOptions{})
+		},
+		"unknown line ending": func(pdf *gofpdf.Fpdf) {
+			pdf.AddShapeAnnotation("Line", square, gofpdf.AnnotationOptions{LineEndings: [2]string{"Arrow"}})
+		},
+		"square with scale": func(pdf *gofpdf.Fpdf) {
+			pdf.AddShapeAnnotation("Square", square, gofpdf.AnnotationOptions{Scale: &gofpdf.AnnotationScale{Factor: 1}})
+		},
+	} {
+		pdf = gofpdf.New("P", "pt", "A4", "")
+		pdf.AddPage()
+		fnc(pdf)
+		if pdf.Ok() {
+			t.Fatalf("no error with %s", name)
+		}
+	}
+}
+
+// ExampleFpdf_AddFreeTextAnnotation demonstrates free text annotations with
+// callout lines that label the parts of a figure.
+func ExampleFpdf_AddFreeTextAnnotation() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddUTF8Font("dejavu", "", example.FontFile("DejaVuSansCondensed.ttf"))
+	pdf.SetFont("dejavu", "", 12)
+	pdf.AddPage()
+	pdf.Text(20, 20, "Cross-section of the housing")
+	pdf.SetFillColor(200, 200, 210)
+	pdf.Rect(40, 60, 80, 50, "FD")
+	pdf.SetFillColor(150, 170, 200)
+	pdf.Circle(80, 85, 15, "FD")
+	label := gofpdf.FreeTextStyle{FontSize: 10, Align: "C"}
+	label.Author = "Designer"
+	label.InteriorColor = &gofpdf.RGBType{R: 255, G: 255, B: 230}
+	label.LineEndings = [2]string{"ClosedArrow"}
+	label.Callout = []gofpdf.PointType{{X: 80, Y: 85}, {X: 140, Y: 50}, {X: 150, Y: 50}}
+	pdf.AddFreeTextAnnotation(150, 40, 45, 20, "Bearing Ø 30 mm, pressed in", label)
+	label.Callout = []gofpdf.PointType{{X: 45, Y: 105}, {X: 30, Y: 130}}
+	label.TextColor = gofpdf.RGBType{R: 160, G: 0, B: 0}
+	pdf.AddFreeTextAnnotation(15, 130, 50, 16, "Housing: die-cast aluminium", label)
+	note := gofpdf.FreeTextStyle{NoBorder: true, FontFamily: "Helvetica", FontStyle: "I", Align: "L"}
+	note.Author = "Designer"
+	pdf.AddFreeTextAnnotation(20, 160, 170, 20, "All dimensions in millimeters. Tolerances according "+
+		"to the general tolerance class m unless specified otherwise.", note)
+	fileStr := example.Filename("Fpdf_AddFreeTextAnnotation")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_AddFreeTextAnnotation.pdf
+}
+
+// TestFreeTextAnnotation verifies the objects written for free text
+// annotations and the errors of invalid ones.
+func TestFreeTextAnnotation(t *testing.T) {
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.SetCompression(false)
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.AddPage()
+	style := gofpdf.FreeTextStyle{FontFamily: "Times", FontStyle: "B", FontSize: 9, Align: "R",
+		TextColor: gofpdf.RGBType{R: 255}}
+	style.BorderWidth = 2
+	style.Callout = []gofpdf.PointType{{X: 50, Y: 50}, {X: 100, Y: 100}}
+	style.LineEndings[0] = "OpenArrow"
+	pdf.AddFreeTextAnnotation(100, 90, 100, 40, "Label", style)
+	if size, _ := pdf.GetFontSize(); size != 12 {
+		t.Fatalf("font size of the document is %.2f after the annotation", size)
+	}
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	str := buf.String()
+	for _, s := range []string{
+		"%PDF-1.6",
+		" 9.00 Tf 1.000 0.000 0.000 rg) /Q 2 /BS <</W 2.00>> /RD [62.00 0.00 0.00 52.00]\n" +
+			"/IT /FreeTextCallout /CL [50.00 791.89 100.00 741.89] /LE /OpenArrow\n/C [0.000 0.000 0.000] /CA 1.000\n",
+		"/BaseFont /Times-Bold",
+		"/BBox [38.00 711.89 200.00 803.89] /Resources 2 0 R /Length ",
+		"q 1 0 0 1 100.00 711.89 cm\n",
+		"(Label)Tj",
+		"0.000 0.000 0.000 RG 2.00 w\n50.00 791.89 m 100.00 741.89 l S\n",
+	} {
+		if !strings.Contains(str, s) {
+			t.Fatalf("%q not found in document", s)
+		}
+	}
+	// The default appearance selects the font of the text
+	if !regexp.MustCompile(`/Subtype /FreeText /Rect \[38.00 711.89 200.00 803.89\]\n/F 4 /DA \(/F\w+ 9.00 Tf `).MatchString(str) {
+		t.Fatal("free text annotation not found")
+	}
+
+	for name, fnc := range map[string]func(pdf *gofpdf.Fpdf){
+		"annotation without page": func(pdf *gofpdf.Fpdf) {
+			pdf.AddFreeTextAnnotation(10, 10, 50, 20, "", gofpdf.FreeTextStyle{})
+		},
+		"empty box": func(pdf *gofpdf.Fpdf) {
+			pdf.AddPage()
+			pdf.AddFreeTextAnnotation(10, 10, 50, 0, "", gofpdf.FreeTextStyle{})
+		},
+		"callout with one point": func(pdf *gofpdf.Fpdf) {
+			pdf.AddPage()
+			pdf.AddFreeTextAnnotation(10, 10, 50, 20, "", gofpdf.FreeTextStyle{Callout: []gofpdf.PointType{{}}})
+		},
+		"unknown font": func(pdf *gofpdf.Fpdf) {
+			pdf.AddPage()
+			pdf.AddFreeTextAnnotation(10, 10, 50, 20, "", gofpdf.FreeTextStyle{FontFamily: "nosuchfont"})
+		},
+	} {
+		pdf = gofpdf.New("P", "pt", "A4", "")
+		pdf.SetFont("Helvetica", "", 12)
+		fnc(pdf)
+		if pdf.Ok() {
+			t.Fatalf("no error with %s", name)
+		}
+	}
+}
+
+// ExampleFpdf_AddStampAnnotation demonstrates a document stamped with standard
+// stamps and with a custom stamp whose appearance is a template.
+func ExampleFpdf_AddStampAnnotation() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFont("Helvetica", "B", 16)
+	seal := pdf.CreateTemplateCustom(gofpdf.PointType{}, gofpdf.SizeType{Wd: 50, Ht: 50}, func(tpl *gofpdf.Tpl) {
+		tpl.SetDrawColor(20, 60, 140)
+		tpl.SetTextColor(20, 60, 140)
+		tpl.SetLineWidth(1.5)
+		tpl.Circle(25, 25, 23, "D")
+		tpl.SetLineWidth(0.5)
+		tpl.Circle(25, 25, 19, "D")
+		tpl.SetFont("Helvetica", "B", 11)
+		tpl.SetXY(5, 17)
+		tpl.CellFormat(40, 6, "QUALITY", "", 2, "C", false, 0, "")
+		tpl.CellFormat(40, 6, "ASSURANCE", "", 2, "C", false, 0, "")
+		tpl.SetFont("Helvetica", "", 8)
+		tpl.CellFormat(40, 5, "2026-10-16", "", 0, "C", false, 0, "")
+	})
+	pdf.AddPage()
+	pdf.Text(20, 30, "Specification 4711, revision C")
+	pdf.SetFont("Helvetica", "", 11)
+	pdf.SetXY(20, 40)
+	pdf.MultiCell(170, 6, "The stamps on this page are annotations: reviewers can move, "+
+		"inspect and remove them, and their appearances look the same in every reader.", "", "L", false)
+	pdf.AddStampAnnotation(140, 10, 55, 16, gofpdf.StampApproved)
+	pdf.AddStampAnnotationOptions(20, 70, 80, 20, gofpdf.StampNotForPublicRelease,
+		gofpdf.MarkupAnnotationOptions{Author: "Legal", Contents: "Internal until the launch"})
+	pdf.AddCustomStampAnnotation(140, 70, 40, 40, seal,
+		gofpdf.MarkupAnnotationOptions{Author: "QA", Contents: "Checked against test plan 12"})
+	fileStr := example.Filename("Fpdf_AddStampAnnotation")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_AddStampAnnotation.pdf
+}
+
+// TestStampAnnotation verifies the objects written for standard and custom
+// stamp annotations and the errors of invalid ones.
+func TestStampAnnotation(t *testing.T) {
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.SetCompression(false)
+	tpl := pdf.CreateTemplateCustom(gofpdf.PointType{}, gofpdf.SizeType{Wd: 100, Ht: 50}, func(tpl *gofpdf.Tpl) {
+		tpl.Rect(0, 0, 100, 50, "F")
+	})
+	pdf.AddPage()
+	pdf.AddStampAnnotation(100, 100, 150, 40, gofpdf.StampNotApproved)
+	pdf.AddCustomStampAnnotation(300, 100, 50, 25, tpl, gofpdf.MarkupAnnotationOptions{
+		Color: &gofpdf.RGBAType{R: 0, G: 0, B: 255}})
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	str := buf.String()
+	for _, s := range []string{
+		"<</Type /Annot /Subtype /Stamp /Rect [100.00 701.89 250.00 741.89]\n/F 4 /Name /NotApproved\n" +
+			"/C [0.745 0.118 0.118] /CA 1.000\n",
+		"(NOT APPROVED)Tj",
+		"/BaseFont /Helvetica-Bold",
+		"<</Type /Annot /Subtype /Stamp /Rect [300.00 716.89 350.00 741.89]\n/F 4 /Name /Custom\n" +
+			"/C [0.000 0.000 1.000] /CA 1.000\n",
+		"/BBox [300.00 716.89 350.00 741.89] /Resources 2 0 R /Length ",
+		"q 1 0 0 1 300.00 716.89 cm\nq 0.50000 0 0 0.50000 0 0 cm /TPL" + tpl.ID() + " Do Q\nQ\n",
+		"/TPL" + tpl.ID() + " ",
+	} {
+		if !strings.Contains(str, s) {
+			t.Fatalf("%q not found in document", s)
+		}
+	}
+
+	for name, fnc := range map[string]func(pdf *gofpdf.Fpdf){
+		"stamp without page": func(pdf *gofpdf.Fpdf) {
+			pdf.AddStampAnnotation(10, 10, 50, 20, gofpdf.StampDraft)
+		},
+		"unknown stamp": func(pdf *gofpdf.Fpdf) {
+			pdf.AddPage()
+			pdf.AddStampAnnotation(10, 10, 50, 20, "Rejected")
+		},
+		"empty stamp": func(pdf *gofpdf.Fpdf) {
+			pdf.AddPage()
+			pdf.AddStampAnnotation(10, 10, 0, 20, gofpdf.StampDraft)
+		},
+		"stamp without template": func(pdf *gofpdf.Fpdf) {
+			pdf.AddPage()
+			pdf.AddCustomStampAnnotation(10, 10, 50, 20, nil, gofpdf.MarkupAnnotationOptions{})
+		},
+	} {
+		pdf = gofpdf.New("P", "pt", "A4", "")
+		fnc(pdf)
+		if pdf.Ok() {
+			t.Fatalf("no error with %s", name)
+		}
+	}
+}
+
+// ExampleFpdf_SetTextDirection demonstrates bidirectional text with Hebrew
+// and Arabic.
+func ExampleFpdf_SetTextDirection() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddUTF8Font("dejavu", "", example.FontFile("DejaVuSansCondensed.ttf"))
+	pdf.SetFont("dejavu", "", 14)
+	pdf.AddPage()
+	pdf.CellFormat(0, 10, "The word שלום means peace.", "", 1, "L", false, 0, "")
+	pdf.CellFormat(0, 10, "שלום עולם, 2019 (hello world)", "", 1, "R", false, 0, "")
+	pdf.CellFormat(0, 10, "مرحبا بالعالم", "", 1, "R", false, 0, "")
+	pdf.SetTextDirection(gofpdf.TextDirectionRTL)
+	pdf.CellFormat(0, 10, "gofpdf היא ספרייה", "", 1, "R", false, 0, "")
+	pdf.SetTextDirection(gofpdf.TextDirectionAuto)
+	pdf.Ln(5)
+	pdf.MultiCell(80, 7, "טקסט ארוך שנשבר לשורות אחדות ומיושר לימין, עם מספרים כמו 3.14 ומילים באנגלית.", "1", "R", false)
+	fileStr := example.Filename("Fpdf_SetTextDirection")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_SetTextDirection.pdf
+}
+
+// ExampleFpdf_WriteShaped demonstrates text shaping with the built-in shaper,
+// which forms the ligatures of the font.
+func ExampleFpdf_WriteShaped() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddUTF8Font("dejavu", "", example.FontFile("DejaVuSansCondensed.ttf"))
+	pdf.SetFont("dejavu", "", 16)
+	pdf.AddPage()
+	pdf.Write(10, "Write: office, fluffy, affix")
+	pdf.Ln(10)
+	pdf.WriteShaped(10, "WriteShaped: office, fluffy, affix")
+	pdf.Ln(10)
+	pdf.WriteShaped(10, "مرحبا بالعالم")
+	fileStr := example.Filename("Fpdf_WriteShaped")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_WriteShaped.pdf
+}
+
+// ExampleFpdf_SetFontFallbacks demonstrates text in a font that lacks Greek
+// and Cyrillic letters, which are printed with a fallback font.
+func ExampleFpdf_SetFontFallbacks() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddUTF8Font("calligra", "", example.FontFile("calligra.ttf"))
+	pdf.AddUTF8Font("dejavu", "", example.FontFile("DejaVuSansCondensed.ttf"))
+	pdf.SetFontFallbacks("calligra", []string{"dejavu"})
+	pdf.SetFont("calligra", "", 16)
+	pdf.AddPage()
+	pdf.Cell(0, 10, "Greek: Καλημέρα κόσμε")
+	pdf.Ln(10)
+	pdf.CellFormat(0, 10, "Cyrillic: Здравствуй, мир", "", 1, "R", false, 0, "")
+	pdf.MultiCell(100, 8, "Text is wrapped according to the widths of both fonts: "+
+		"Ἐν ἀρχῇ ἦν ὁ λόγος, καὶ ὁ λόγος ἦν πρὸς τὸν θεόν.", "1", "J", false)
+	fileStr := example.Filename("Fpdf_SetFontFallbacks")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_SetFontFallbacks.pdf
+}
+
+// ExampleFpdf_SetKerning demonstrates text printed with and without the
+// kerning pairs of a font.
+func ExampleFpdf_SetKerning() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddUTF8Font("dejavu", "", example.FontFile("DejaVuSansCondensed.ttf"))
+	pdf.SetFont("dejavu", "", 28)
+	pdf.AddPage()
+	for _, on := range []bool{false, true} {
+		pdf.SetKerning(on)
+		pdf.CellFormat(0, 14, "AVATAR Tower WAVY", "1", 1, "C", false, 0, "")
+	}
+	pdf.SetFontSize(12)
+	pdf.MultiCell(0, 6, "With kerning, lines are broken and justified according to the "+
+		"kerned widths: To Wally, VAT was a Yoke to avoid.", "1", "J", false)
+	fileStr := example.Filename("Fpdf_SetKerning")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_SetKerning.pdf
+}
+
+// TestKerning checks that kerned text is printed and measured with the
+// kerning pairs of the font
+func TestKerning(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false)
+	pdf.AddUTF8Font("dejavu", "", example.FontFile("DejaVuSansCondensed.ttf"))
+	pdf.SetFont("dejavu", "", 10)
+	pdf.AddPage()
+	plain := pdf.GetStringWidth("AV")
+	pdf.Cell(0, 10, "AV")
+	pdf.SetKerning(true)
+	kerned := pdf.GetStringWidth("AV")
+	_, size := pdf.GetFontSize()
+	if want := plain - 0.064*size; math.Abs(kerned-want) > 1e-9 {
+		t.Fatalf("kerned width is %.4f, expecting %.4f", kerned, want)
+	}
+	pdf.Ln(10)
+	x := pdf.GetX()
+	pdf.Write(10, "AV")
+	if wd := pdf.GetX() - x; math.Abs(wd-kerned) > 1e-9 {
+		t.Fatalf("Write advanced by %.4f, expecting %.4f", wd, kerned)
+	}
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "Td (\x00A\x00V)Tj ET") {
+		t.Fatal("text without kerning not found")
+	}
+	if !strings.Contains(buf.String(), "Td [(\x00A)64.000(\x00V)] TJ ET") {
+		t.Fatal("kerned text not found")
+	}
+}
+
+// ExampleFpdf_SetCharSpacing demonstrates character and word spacing, which
+// are taken into account when text is measured and broken into lines.
+func ExampleFpdf_SetCharSpacing() {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+	pdf.SetFont("Helvetica", "B", 20)
+	for _, space := range []float64{0, 1, 3} {
+		pdf.SetCharSpacing(space)
+		pdf.CellFormat(0, 12, "TRACKED HEADING", "1", 1, "C", false, 0, "")
+	}
+	pdf.SetCharSpacing(0)
+	pdf.SetFont("Helvetica", "", 12)
+	text := "Word spacing widens the gaps between words, and lines are broken " +
+		"according to the widened text."
+	for _, space := range []float64{0, 2} {
+		pdf.SetWordSpacing(space)
+		pdf.MultiCell(100, 6, text, "1", "L", false)
+		pdf.Ln(4)
+	}
+	pdf.AddUTF8Font("dejavu", "", example.FontFile("DejaVuSansCondensed.ttf"))
+	pdf.SetFont("dejavu", "", 12)
+	pdf.MultiCell(100, 6, text, "1", "L", false)
+	pdf.SetWordSpacing(0)
+	fileStr := example.Filename("Fpdf_SetCharSpacing")
+	err := pdf.OutputFileAndClose(fileStr)
+	example.Summary(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Fpdf_SetCharSpacing.pdf
+}
+
+// TestCharSpacing checks that character and word spacing are applied to text
+// and taken into account in its width
+func TestCharSpacing(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false)
+	pdf.SetFont("Helvetica", "", 10)
+	plain := pdf.GetStringWidth("a b")
+	pdf.SetCharSpacing(1)
+	pdf.SetWordSpacing(2)
+	if wd := pdf.GetStringWidth("a b"); math.Abs(wd-plain-5) > 1e-9 {
+		t.Fatalf("width is %.4f, expecting %.4f", wd, plain+5)
+	}
+	pdf.AddPage()
+	x := pdf.GetX()
+	pdf.Write(10, "a b")
+	if wd := pdf.GetX() - x; math.Abs(wd-plain-5) > 1e-9 {
+		t.Fatalf("Write advanced by %.4f, expecting %.4f", wd, plain+5)
+	}
+	if lines := pdf.SplitLines([]byte("a b"), plain+5+2*pdf.GetCellMargin()-0.1); len(lines) != 2 {
+		t.Fatalf("text split into %d lines, expecting 2", len(lines))
+	}
+	pdf.AddUTF8Font("dejavu", "", example.FontFile("DejaVuSansCondensed.ttf"))
+	pdf.SetFont("dejavu", "", 10)
+	pdf.Cell(0, 10, "a b")
+	pdf.AddPage()
+	if cs, ws := pdf.GetCharSpacing(), pdf.GetWordSpacing(); cs != 1 || ws != 2 {
+		t.Fatalf("spacing is %v and %v on the new page, expecting 1 and 2", cs, ws)
+	}
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(buf.String(), "2.83465 Tc\n5.66929 Tw"); n != 2 {
+		t.Fatalf("spacing set on %d pages, expecting 2", n)
+	}
+	if !strings.Contains(buf.String(), "Td [(\x00a)-566.929(\x00 \x00b)] TJ ET") {
+		t.Fatal("word spacing of UTF-8 text not found")
+	}
+}
+
+// TestFontFallbacks checks that characters missing from a font are printed,
+// and measured, with the fallback font
+func TestFontFallbacks(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false)
+	pdf.AddUTF8Font("calligra", "", example.FontFile("calligra.ttf"))
+	pdf.AddUTF8Font("dejavu", "", example.FontFile("DejaVuSansCondensed.ttf"))
+	pdf.AddPage()
+	pdf.SetFont("dejavu", "", 12)
+	greekWd := pdf.GetStringWidth("αβγ")
+	pdf.SetFont("calligra", "", 12)
+	latinWd := pdf.GetStringWidth("ab ")
+	pdf.SetFontFallbacks("calligra", []string{"dejavu"})
+	if wd := pdf.GetStringWidth("ab αβγ"); math.Abs(wd-latinWd-greekWd) > 1e-9 {
+		t.Fatalf("width is %.4f, expecting %.4f", wd, latinWd+greekWd)
+	}
+	pdf.Cell(0, 10, "ab αβγ")
+	pdf.SetFontFallbacks("calligra", nil)
+	pdf.Cell(0, 10, "αβγ")
+	pdf.SetFontFallbacks("calligra", []string{"unknown"})
+	if !pdf.Err() {
+		t.Fatal("unknown fallback font accepted")
+	}
+	pdf.ClearError()
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	m := regexp.MustCompile(`BT [\d.]+ [\d.]+ Td \[\(\x00a\x00b\x00 \)\] TJ /F(\w+) 12.00 Tf \[\([^)]{6}\)\] TJ /F(\w+) 12.00 Tf ET`).FindStringSubmatch(buf.String())
+	if m == nil || m[1] == m[2] {
+		t.Fatal("text with fallback font not found")
+	}
+	if !strings.Contains(buf.String(), "Td (\x03\xb1\x03\xb2\x03\xb3)Tj ET") {
+		t.Fatal("text without fallback font not found")
+	}
+}
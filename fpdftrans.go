@@ -22,6 +22,7 @@ type TransformMatrix struct {
 // contexts must be properly ended prior to outputting the document.
 func (f *Fpdf) TransformBegin() {
 	f.transformNest++
+	f.nests = append(f.nests, nestType{kind: nestTransform, page: f.page})
 	f.out("q")
 }
 
@@ -200,11 +201,51 @@ func (f *Fpdf) Transform(tm TransformMatrix) {
 	}
 }
 
+// ImageTransformed puts the image imageNameStr on the current page, placed
+// with the matrix m, which combines any scaling, rotation, skewing and
+// translation computed by the caller. The image is a square of size 1 whose
+// upper left corner is at (0, 0), and m maps a point (x, y) of this square to
+// the point (A*x + C*y + E, B*x + D*y + F) of the page. Unlike the matrix of
+// Transform(), m is in the unit of measure of the document, and y grows
+// downwards, as it does for the other methods of Fpdf: the matrix {w, 0, 0,
+// h, x, y} puts the image with the width w and the height h at (x, y), like
+// Image() does.
+//
+// The image is loaded as by Image(), with its type inferred from the
+// extension of imageNameStr, unless it has been registered before. The square
+// is the upright image, as a JPEG image is displayed with its EXIF
+// orientation.
+//
+// The ImageTransformed example demonstrates this method.
+func (f *Fpdf) ImageTransformed(imageNameStr string, m TransformMatrix) {
+	if f.err != nil {
+		return
+	}
+	info := f.RegisterImageOptions(imageNameStr, ImageOptions{})
+	if f.err != nil {
+		return
+	}
+	f.imagesUsed[info.i] = true
+	// The subtractions from 0 keep zeros from being printed as -0
+	s := sprintf("q %.5f %.5f %.5f %.5f %.5f %.5f cm", m.A*f.k, 0-m.B*f.k,
+		0-m.C*f.k, m.D*f.k, (m.C+m.E)*f.k, (f.h-m.D-m.F)*f.k)
+	if _, _, o := imagePlacement(info, 0, false, false); o != (TransformMatrix{1, 0, 0, 1, 0, 0}) {
+		s += sprintf(" %.5f %.5f %.5f %.5f %.5f %.5f cm", o.A, o.B, o.C, o.D, o.E, o.F)
+	}
+	f.out(f.structMark(s + sprintf(" /I%s Do Q", info.i)))
+}
+
 // TransformEnd applies a transformation that was begun with a call to TransformBegin().
 //
 // The TransformBegin() example demonstrates this method.
 func (f *Fpdf) TransformEnd() {
 	if f.transformNest > 0 {
+		if err := f.nestEnd(nestTransform); err != nil {
+			if f.err == nil {
+				f.err = err
+			}
+			return
+		}
 		f.transformNest--
 		f.out("Q")
 	} else {
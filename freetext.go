@@ -0,0 +1,171 @@
+package gofpdf
+
+import (
+	"fmt"
+	"math"
+)
+
+// FreeTextStyle holds the properties of free text annotations.
+//
+// The properties of AnnotationOptions apply to free text annotations as
+// well, except Icon and Scale: Color is the color of the border and of the
+// callout line, which is black if Color is nil, InteriorColor the color of
+// the background, and LineEndings[0] the style of the start of the callout
+// line. NoBorder, if true, leaves the annotation without a border.
+//
+// FontFamily, FontStyle and FontSize select the font of the text, as
+// SetFont() does; the font must have been added to the document. If
+// FontFamily is empty, the current font is used, and if FontSize is zero, the
+// current font size. TextColor is the color of the text, and Align its
+// horizontal alignment, "L", "C", "R" or "J", as for MultiCell().
+//
+// Callout, if not empty, is the callout line that labels a spot of a figure
+// with the annotation, with two or three points in the unit of measure of the
+// document: the spot, an optional knee and the point at which the line meets
+// the box of the annotation.
+type FreeTextStyle struct {
+	AnnotationOptions
+	NoBorder   bool
+	FontFamily string
+	FontStyle  string
+	FontSize   float64
+	TextColor  RGBType
+	Align      string
+	Callout    []PointType
+}
+
+// AddFreeTextAnnotation adds a free text annotation, which shows text in a
+// box with the upper left corner (x, y), the width w and the height h on the
+// current page, with the properties specified by style. The text is laid out
+// as MultiCell() lays it out, with a font of the document, and the
+// appearance of the annotation is drawn by gofpdf, so that it looks the same
+// in every PDF reader. Text that does not fit into the box is clipped.
+//
+// An error occurs if no page has been added, if w or h is not positive, if
+// the callout line has a single or more than three points, if its line
+// ending is unknown, if style.Scale is not nil, or if the font cannot be
+// selected.
+//
+// The AddFreeTextAnnotation example demonstrates this method.
+func (f *Fpdf) AddFreeTextAnnotation(x, y, w, h float64, text string, style FreeTextStyle) {
+	if f.err != nil {
+		return
+	}
+	switch {
+	case f.page == 0 || f.state != 2:
+		f.err = fmt.Errorf("a page must be added before an annotation")
+	case w <= 0 || h <= 0:
+		f.err = fmt.Errorf("invalid free text annotation size %.2f x %.2f", w, h)
+	case len(style.Callout) == 1 || len(style.Callout) > 3:
+		f.err = fmt.Errorf("callout line needs 2 or 3 points, not %d", len(style.Callout))
+	case style.Scale != nil:
+		f.err = fmt.Errorf("FreeText annotation cannot measure a drawing")
+	}
+	if f.err != nil {
+		return
+	}
+	options := f.shapeAnnotOptions(style.AnnotationOptions)
+	if f.err != nil {
+		return
+	}
+	options.Contents = text
+	if options.Color == nil {
+		options.Color = &RGBAType{Alpha: 1}
+	}
+	if style.NoBorder {
+		options.BorderWidth, options.BorderDashes = 0, nil
+	}
+	family, fontStyle, size := style.FontFamily, style.FontStyle, style.FontSize
+	if family == "" {
+		family, fontStyle = f.fontFamily, f.fontStyle
+	}
+	if size == 0 {
+		size = f.fontSizePt
+	}
+	bw := options.BorderWidth
+	clr := options.Color
+	// The box is drawn with the methods of the document, which select
+	// and subset its fonts
+	c := f.beginCanvas(w, h, "a free text annotation")
+	if ic := options.InteriorColor; ic != nil {
+		f.SetFillColor(ic.R, ic.G, ic.B)
+		f.Rect(0, 0, w, h, "F")
+	}
+	if bw > 0 {
+		f.SetDrawColor(clr.R, clr.G, clr.B)
+		f.SetLineWidth(bw / f.k)
+		dashes := make([]float64, len(options.BorderDashes))
+		for j, v := range options.BorderDashes {
+			dashes[j] = v / f.k
+		}
+		f.SetDashPattern(dashes, 0)
+		f.Rect(bw/2/f.k, bw/2/f.k, w-bw/f.k, h-bw/f.k, "D")
+	}
+	f.SetFont(family, fontStyle, size)
+	f.SetTextColor(style.TextColor.R, style.TextColor.G, style.TextColor.B)
+	pad := (bw + 2) / f.k
+	f.SetXY(pad, pad)
+	f.MultiCell(w-2*pad, f.fontSize*1.2, text, "", style.Align, false)
+	da := sprintf("/F%s %.2f Tf %s", f.currentFont.i, size, f.color.text.str)
+	content := f.endCanvas(c)
+	if f.err != nil {
+		return
+	}
+	bx, by := x*f.k, f.hPt-(y+h)*f.k
+	var ap fmtBuffer
+	ap.printf("q 1 0 0 1 %.2f %.2f cm\n", bx, by)
+	ap.Write(content)
+	ap.printf("Q\n")
+	an := markupAnnotType{subtype: "FreeText", da: da}
+	x1, y1, x2, y2 := bx, by, bx+w*f.k, by+h*f.k
+	if len(style.Callout) > 0 {
+		for _, pt := range style.Callout {
+			px, py := pt.X*f.k, f.hPt-pt.Y*f.k
+			an.callout = append(an.callout, px, py)
+			margin := math.Max(bw/2, annotEndingSize(bw))
+			x1, y1 = math.Min(x1, px-margin), math.Min(y1, py-margin)
+			x2, y2 = math.Max(x2, px+margin), math.Max(y2, py+margin)
+		}
+		fill := options.InteriorColor != nil
+		ap.printf("%.3f %.3f %.3f RG %.2f w", float64(clr.R)/255, float64(clr.G)/255, float64(clr.B)/255, math.Max(bw, 1))
+		if fill {
+			ic := options.InteriorColor
+			ap.printf(" %.3f %.3f %.3f rg", float64(ic.R)/255, float64(ic.G)/255, float64(ic.B)/255)
+		}
+		ap.printf("\n")
+		p := an.callout
+		annotPath(&ap, p)
+		ap.printf(" S\n")
+		annotLineEnding(&ap, options.LineEndings[0], p[0], p[1], p[0]-p[2], p[1]-p[3], math.Max(bw, 1), fill)
+	}
+	an.x, an.y, an.w, an.h = x1, y1, x2-x1, y2-y1
+	// The box lies within the rectangle of the annotation
+	an.rd = [4]float64{bx - x1, by - y1, x2 - bx - w*f.k, y2 - by - h*f.k}
+	an.align = style.Align
+	an.ap = ap.Bytes()
+	f.addMarkupAnnot(an, options)
+	if f.err == nil {
+		version := "1.5"
+		if len(an.callout) > 0 {
+			version = "1.6"
+		}
+		if f.pdfVersion < version {
+			f.pdfVersion = version
+		}
+	}
+}
+
+// putFreeTextEntries writes the entries of the free text annotation an that
+// describe its text, border and callout line
+func (f *Fpdf) putFreeTextEntries(an markupAnnotType) {
+	quadding := map[string]int{"C": 1, "R": 2}[an.align]
+	dashes := ""
+	if len(an.options.BorderDashes) > 0 {
+		dashes = " /S /D /D [" + annotNumbers(an.options.BorderDashes) + "]"
+	}
+	f.outf("/F 4 /DA %s /Q %d /BS <</W %.2f%s>> /RD [%.2f %.2f %.2f %.2f]", f.textstring(an.da), quadding,
+		an.options.BorderWidth, dashes, an.rd[0], an.rd[1], an.rd[2], an.rd[3])
+	if len(an.callout) > 0 {
+		f.outf("/IT /FreeTextCallout /CL [%s] /LE /%s", annotNumbers(an.callout), an.options.LineEndings[0])
+	}
+}
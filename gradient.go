@@ -0,0 +1,150 @@
+package gofpdf
+
+import (
+	"errors"
+	"sort"
+)
+
+// GradientColor is a color of a gradient, in the RGB, CMYK or gray color
+// space. It is returned by GradientRGB(), GradientCMYK() and GradientGray().
+type GradientColor struct {
+	space string // color space of the shading
+	str   string // color components
+}
+
+// GradientRGB returns the gradient color with the red, green and blue
+// components r, g and b, which range from 0 to 255.
+func GradientRGB(r, g, b int) GradientColor {
+	return GradientColor{"DeviceRGB", rgbColorValue(r, g, b, "", "").str}
+}
+
+// GradientCMYK returns the gradient color with the ink percentages c, m, y
+// and k, which range from 0 to 100. Values above this are quietly capped to
+// 100.
+func GradientCMYK(c, m, y, k byte) GradientColor {
+	return GradientColor{"DeviceCMYK", sprintf("%.3f %.3f %.3f %.3f",
+		float64(byteBound(c))/100, float64(byteBound(m))/100,
+		float64(byteBound(y))/100, float64(byteBound(k))/100)}
+}
+
+// GradientGray returns the gradient color with the gray level g, which
+// ranges from 0 (black) to 255 (white).
+func GradientGray(g int) GradientColor {
+	_, level := colorComp(g)
+	return GradientColor{"DeviceGray", sprintf("%.3f", level)}
+}
+
+// GradientStop is a color of a gradient and its position along the
+// gradient, from 0 at its start to 1 at its end
+type GradientStop struct {
+	Offset float64
+	Color  GradientColor
+}
+
+// Gradient describes a blending of colors along a line, or between two
+// circles for radial gradients. Positions and radii are on the current page,
+// in the unit of measure of the document.
+type Gradient struct {
+	Radial bool
+	// Start and end of the line of linear gradients, or centers of the start
+	// and end circles of radial gradients
+	X1, Y1, X2, Y2 float64
+	R1, R2         float64 // radii of the start and end circles of radial gradients
+	// Colors of the gradient, all in the same color space. Offsets that are
+	// out of order are sorted.
+	Stops []GradientStop
+	// ExtendStart is true to paint with the first color before the start of
+	// the gradient, and ExtendEnd with the last color beyond its end.
+	ExtendStart, ExtendEnd bool
+}
+
+// SetFillGradient sets the current fill color to the gradient gr, so that
+// the areas filled by following drawing operations, such as Rect(), Circle()
+// and DrawPath(), are painted with it. Unlike LinearGradient() and
+// RadialGradient(), gradients can have any number of colors, in the RGB, CMYK
+// or gray color space. The gradient is placed on the page, rather than on each
+// area it fills: its positions are not changed by transformations or by
+// page breaks. Setting another fill color ends the gradient.
+//
+// An error occurs if the gradient has fewer than two colors or if its colors
+// are in different color spaces.
+//
+// The SetFillGradient example demonstrates this method.
+func (f *Fpdf) SetFillGradient(gr Gradient) {
+	pos := f.gradientPattern(gr)
+	if pos > 0 {
+		f.color.fill.mode = colorModePattern
+		f.color.fill.str = sprintf("/Pattern cs /P%d scn", pos)
+		f.colorFlag = f.color.fill.str != f.color.text.str
+		if f.page > 0 {
+			f.out(f.color.fill.str)
+		}
+	}
+}
+
+// SetDrawGradient sets the current draw color to the gradient gr, so that
+// the lines and outlines drawn by following drawing operations are painted
+// with it, in the same way as SetFillGradient() does for fills. Setting
+// another draw color ends the gradient.
+//
+// The SetFillGradient example demonstrates this method.
+func (f *Fpdf) SetDrawGradient(gr Gradient) {
+	pos := f.gradientPattern(gr)
+	if pos > 0 {
+		f.color.draw.mode = colorModePattern
+		f.color.draw.str = sprintf("/Pattern CS /P%d SCN", pos)
+		if f.page > 0 {
+			f.out(f.color.draw.str)
+		}
+	}
+}
+
+// gradientPattern adds the gradient gr as a shading pattern and returns its
+// number, or 0 if an error occurs
+func (f *Fpdf) gradientPattern(gr Gradient) int {
+	if f.err != nil {
+		return 0
+	}
+	if len(gr.Stops) < 2 {
+		f.err = errors.New("a gradient needs at least two colors")
+		return 0
+	}
+	stops := make([]GradientStop, len(gr.Stops))
+	copy(stops, gr.Stops)
+	sort.SliceStable(stops, func(i, j int) bool {
+		return stops[i].Offset < stops[j].Offset
+	})
+	space := stops[0].Color.space
+	var list []gradientStopType
+	for _, stop := range stops {
+		if stop.Color.space != space {
+			f.err = errors.New("the colors of a gradient must be in the same color space")
+			return 0
+		}
+		offset := stop.Offset
+		if offset < 0 {
+			offset = 0
+		} else if offset > 1 {
+			offset = 1
+		}
+		list = append(list, gradientStopType{offset, stop.Color.str})
+	}
+	// The blending function covers the whole gradient, from 0 to 1
+	if list[0].offset > 0 {
+		list = append([]gradientStopType{{0, list[0].clrStr}}, list...)
+	}
+	if last := list[len(list)-1]; last.offset < 1 {
+		list = append(list, gradientStopType{1, last.clrStr})
+	}
+	g := gradientType{tp: 2, clr1Str: list[0].clrStr, clr2Str: list[len(list)-1].clrStr,
+		x1: gr.X1 * f.k, y1: (f.h - gr.Y1) * f.k, x2: gr.X2 * f.k, y2: (f.h - gr.Y2) * f.k,
+		space: space, extend: sprintf("%t %t", gr.ExtendStart, gr.ExtendEnd), pattern: true}
+	if gr.Radial {
+		g.tp, g.r0, g.r = 3, gr.R1*f.k, gr.R2*f.k
+	}
+	if len(list) > 2 {
+		g.stops = list
+	}
+	f.gradientList = append(f.gradientList, g)
+	return len(f.gradientList) - 1
+}
@@ -0,0 +1,106 @@
+package gofpdf
+
+import (
+	"errors"
+)
+
+// groupType is a transparency group
+type groupType struct {
+	wPt, hPt           float64 // size of the page the group was drawn on
+	isolated, knockout bool
+	content            []byte
+	objNum             int
+}
+
+// BeginTransparencyGroup starts a transparency group: the drawing operations
+// that follow, up to EndTransparencyGroup(), are composited with each other
+// first, and the result is then put on the current page as a whole, with the
+// alpha value and blend mode given to EndTransparencyGroup(). This is how
+// design tools apply the opacity and blend mode of a layer or a group of
+// shapes: overlapping shapes of a group drawn at half opacity do not show
+// through each other, unlike shapes drawn one by one after SetAlpha().
+//
+// If isolated is true, the group is composited on a transparent backdrop, so
+// that the blend modes used within it do not blend with the content of the
+// page behind the group. If knockout is true, each shape of the group
+// replaces the shapes of the group it overlaps instead of being composited
+// with them, so that overlapping translucent shapes do not darken each
+// other.
+//
+// Groups can be nested. The group starts with the colors, line width, font
+// and alpha value of the document, which are restored by
+// EndTransparencyGroup(), as are the current position and page. Page breaks
+// do not occur in the group, and AddPage(), links and annotations are not
+// supported. An error occurs if no page has been added.
+//
+// The BeginTransparencyGroup example demonstrates this method.
+func (f *Fpdf) BeginTransparencyGroup(isolated, knockout bool) {
+	if f.err != nil {
+		return
+	}
+	if f.page == 0 || f.state != 2 {
+		f.err = errors.New("a page must be added before a transparency group is begun")
+		return
+	}
+	x, y := f.x, f.y
+	c := f.beginCanvas(f.w, f.h, "a transparency group")
+	f.x, f.y = x, y
+	f.groupCanvases = append(f.groupCanvases, c)
+	f.groups = append(f.groups, groupType{isolated: isolated, knockout: knockout})
+	c.group = len(f.groups)
+}
+
+// EndTransparencyGroup ends the transparency group begun last with
+// BeginTransparencyGroup() and puts it on the page with the alpha value alpha
+// and the blend mode blendModeStr, which are the same as those of SetAlpha().
+// The alpha value and blend mode of the document are not changed. An error
+// occurs if no group has been begun, if a soft mask begun within the group has
+// not been ended, or if alpha or blendModeStr is invalid.
+//
+// The BeginTransparencyGroup example demonstrates this method.
+func (f *Fpdf) EndTransparencyGroup(alpha float64, blendModeStr string) {
+	if f.err != nil {
+		return
+	}
+	count := len(f.groupCanvases)
+	if count == 0 {
+		f.err = errors.New("no transparency group has been begun")
+		return
+	}
+	c := f.groupCanvases[count-1]
+	if f.canvasDepth != c.depth+1 {
+		f.err = errors.New("soft mask must be ended before the transparency group")
+		return
+	}
+	f.groupCanvases = f.groupCanvases[:count-1]
+	content := f.endCanvas(c)
+	if f.err != nil {
+		return
+	}
+	gr := &f.groups[c.group-1]
+	gr.wPt, gr.hPt, gr.content = c.wPt, c.hPt, content
+	if pos := f.blendState(alpha, blendModeStr); pos > 0 {
+		f.outf("q /GS%d gs /TG%d Do Q", pos, c.group)
+	}
+}
+
+// putGroups writes the transparency groups of the document as form XObjects
+func (f *Fpdf) putGroups() {
+	filter := ""
+	if f.compress {
+		filter = "/Filter /" + f.filterName() + " "
+	}
+	for j, gr := range f.groups {
+		content := gr.content
+		if f.compress {
+			content = f.compressStream(content)
+		}
+		f.newobj()
+		f.groups[j].objNum = f.n
+		f.outf("<<%s/Type /XObject /Subtype /Form /BBox [0 0 %.5f %.5f]", filter, gr.wPt, gr.hPt)
+		f.outf("/Group <</S /Transparency /I %t /K %t>> /Resources 2 0 R", gr.isolated, gr.knockout)
+		f.outf("/Length %d >>", f.protect.streamLength(len(content)))
+		f.putstream(content)
+		f.out("endobj")
+	}
+}
@@ -0,0 +1,108 @@
+package gofpdf
+
+import (
+	"fmt"
+)
+
+// graphicsStateType holds the drawing and text settings of the document that
+// are saved and restored together
+type graphicsStateType struct {
+	lineWidth  float64
+	capStyle   int
+	joinStyle  int
+	miterLimit float64
+	dashArray  []float64
+	dashPhase  float64
+	color      struct {
+		draw, fill, text colorType
+	}
+	colorFlag                           bool
+	fontFamily, fontStyle               string
+	fontSizePt, fontSize                float64
+	currentFont                         fontDefType
+	underline, strikeout, isCurrentUTF8 bool
+	charSpacing, wordSpacing, textRise  float64
+	alpha                               float64
+	blendMode                           string
+	overprint                           overprintType
+}
+
+// graphicsState returns the current drawing and text settings of the document
+func (f *Fpdf) graphicsState() (gs graphicsStateType) {
+	gs.lineWidth, gs.capStyle, gs.joinStyle, gs.miterLimit = f.lineWidth, f.capStyle, f.joinStyle, f.miterLimit
+	gs.dashArray, gs.dashPhase = f.dashArray, f.dashPhase
+	gs.color, gs.colorFlag = f.color, f.colorFlag
+	gs.fontFamily, gs.fontStyle, gs.fontSizePt, gs.fontSize = f.fontFamily, f.fontStyle, f.fontSizePt, f.fontSize
+	gs.currentFont, gs.underline, gs.strikeout, gs.isCurrentUTF8 = f.currentFont, f.underline, f.strikeout, f.isCurrentUTF8
+	gs.charSpacing, gs.wordSpacing, gs.textRise = f.charSpacing, f.wordSpacing, f.textRise
+	gs.alpha, gs.blendMode, gs.overprint = f.alpha, f.blendMode, f.overprint
+	return
+}
+
+// setGraphicsState sets the drawing and text settings of the document to gs,
+// without output
+func (f *Fpdf) setGraphicsState(gs *graphicsStateType) {
+	f.lineWidth, f.capStyle, f.joinStyle, f.miterLimit = gs.lineWidth, gs.capStyle, gs.joinStyle, gs.miterLimit
+	f.dashArray, f.dashPhase = gs.dashArray, gs.dashPhase
+	f.color, f.colorFlag = gs.color, gs.colorFlag
+	f.fontFamily, f.fontStyle, f.fontSizePt, f.fontSize = gs.fontFamily, gs.fontStyle, gs.fontSizePt, gs.fontSize
+	f.currentFont, f.underline, f.strikeout, f.isCurrentUTF8 = gs.currentFont, gs.underline, gs.strikeout, gs.isCurrentUTF8
+	f.charSpacing, f.wordSpacing, f.textRise = gs.charSpacing, gs.wordSpacing, gs.textRise
+	f.alpha, f.blendMode, f.overprint = gs.alpha, gs.blendMode, gs.overprint
+}
+
+// SaveGState saves the graphics state: the colors, line width, line cap and
+// join styles, miter limit, dash pattern, font, text spacing, alpha value and
+// overprint settings of the document, which RestoreGState() restores. Helper
+// functions that change these settings can call SaveGState() first and
+// RestoreGState() last so that the settings of their caller are left as they
+// were.
+//
+// Saved graphics states can be nested, with each other and with clipping
+// operations and transformations, and end on the page where they are saved.
+// Clipping operations and transformations begun after SaveGState() are
+// ended by RestoreGState() in PDF, but must still be ended explicitly. The
+// document cannot be successfully output while a graphics state is saved.
+//
+// The SaveGState example demonstrates this method.
+func (f *Fpdf) SaveGState() {
+	if f.err != nil {
+		return
+	}
+	gs := f.graphicsState()
+	f.nests = append(f.nests, nestType{kind: nestGState, page: f.page, saved: &gs})
+	f.out("q")
+}
+
+// RestoreGState restores the graphics state saved last with SaveGState(). An
+// error occurs if no graphics state has been saved, if a clipping operation
+// or transformation that began after it has not ended, or if it has been
+// saved on another page.
+//
+// The SaveGState example demonstrates this method.
+func (f *Fpdf) RestoreGState() {
+	if f.err != nil {
+		return
+	}
+	count := len(f.nests)
+	if count == 0 || (f.nests[count-1].kind != nestGState && f.gStateCount() == 0) {
+		f.err = fmt.Errorf("no graphics state has been saved")
+		return
+	}
+	saved := f.nests[count-1].saved
+	if f.err = f.nestEnd(nestGState); f.err != nil {
+		return
+	}
+	f.out("Q")
+	f.setGraphicsState(saved)
+}
+
+// gStateCount returns the number of saved graphics states
+func (f *Fpdf) gStateCount() (count int) {
+	for _, nest := range f.nests {
+		if nest.kind == nestGState {
+			count++
+		}
+	}
+	return
+}
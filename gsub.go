@@ -0,0 +1,288 @@
+package gofpdf
+
+import (
+	"math"
+	"sort"
+)
+
+// Glyph substitution using the OpenType GSUB table of a UTF-8 font. Only
+// single substitutions (lookup type 1) and ligature substitutions (lookup
+// type 4), possibly wrapped in extension lookups (type 7), are supported;
+// other lookups are skipped. Lookup flags are ignored.
+
+// gsubFeatures lists the features applied by the built-in shaper, which are
+// those that a text layout engine applies by default to any script
+var gsubFeatures = []string{"ccmp", "rlig", "liga"}
+
+// gsubGlyphType is a glyph being shaped by the built-in shaper
+type gsubGlyphType struct {
+	gid   int
+	level int // resolved bidi level of the glyph's first character
+}
+
+// int16At returns the signed 16-bit integer at position pos of the font file
+func (utf *utf8FontFile) int16At(pos int) int {
+	return int(int16(utf.getUint16(pos)))
+}
+
+// uint32At returns the unsigned 32-bit integer at position pos of the font
+// file
+func (utf *utf8FontFile) uint32At(pos int) int {
+	return utf.getUint16(pos)<<16 | utf.getUint16(pos+2)
+}
+
+// tagAt returns the four-character tag at position pos of the font file
+func (utf *utf8FontFile) tagAt(pos int) string {
+	return string(utf.getRange(pos, 4))
+}
+
+// runeGlyph returns the glyph index that the font's cmap assigns to r, or 0
+// if it has none
+func (utf *utf8FontFile) runeGlyph(r rune) int {
+	if utf.runeGlyphs == nil {
+		utf.runeGlyphs = make(map[int]int)
+		symbolCharDictionary := make(map[int][]int)
+		if pos := utf.parseCMAPTable(0); pos > 0 {
+			utf.generateSCCSDictionaries(pos, symbolCharDictionary, utf.runeGlyphs)
+		}
+	}
+	return utf.runeGlyphs[int(r)]
+}
+
+// glyphAdvance returns the advance width of glyph gid in thousandths of the
+// font size
+func (utf *utf8FontFile) glyphAdvance(gid int) float64 {
+	utf.SeekTable("hhea")
+	utf.skip(34)
+	metrics := utf.getMetrics(utf.readUint16(), gid)
+	return float64(int(metrics[0])<<8|int(metrics[1])) * 1000 / float64(utf.fontElementSize)
+}
+
+// gsubLookups returns, in the order in which they are applied, the indices of
+// the GSUB lookups that implement the specified features for the script with
+// OpenType tag scriptStr, or for the default script if the font has no
+// features specific to it
+func (utf *utf8FontFile) gsubLookups(scriptStr string, features []string) []int {
+	desc, ok := utf.tableDescriptions["GSUB"]
+	if !ok {
+		return nil
+	}
+	gsub := desc.position
+	scriptList := gsub + utf.getUint16(gsub+4)
+	featureList := gsub + utf.getUint16(gsub+6)
+	langSys := 0
+	for _, tagStr := range []string{scriptStr, "DFLT", "latn"} {
+		count := utf.getUint16(scriptList)
+		for j := 0; j < count && langSys == 0; j++ {
+			rec := scriptList + 2 + 6*j
+			if utf.tagAt(rec) == tagStr {
+				script := scriptList + utf.getUint16(rec+4)
+				if off := utf.getUint16(script); off != 0 {
+					langSys = script + off
+				}
+			}
+		}
+		if langSys != 0 {
+			break
+		}
+	}
+	if langSys == 0 {
+		return nil
+	}
+	wanted := make(map[string]bool)
+	for _, feature := range features {
+		wanted[feature] = true
+	}
+	seen := make(map[int]bool)
+	var lookups []int
+	count := utf.getUint16(langSys + 4)
+	for j := 0; j < count; j++ {
+		rec := featureList + 2 + 6*utf.getUint16(langSys+6+2*j)
+		if !wanted[utf.tagAt(rec)] {
+			continue
+		}
+		feature := featureList + utf.getUint16(rec+4)
+		lookupCount := utf.getUint16(feature + 2)
+		for k := 0; k < lookupCount; k++ {
+			lookup := utf.getUint16(feature + 4 + 2*k)
+			if !seen[lookup] {
+				seen[lookup] = true
+				lookups = append(lookups, lookup)
+			}
+		}
+	}
+	sort.Ints(lookups)
+	return lookups
+}
+
+// coverageIndex returns the index of gid in the coverage table at position
+// pos, or -1 if the table does not cover it
+func (utf *utf8FontFile) coverageIndex(pos, gid int) int {
+	count := utf.getUint16(pos + 2)
+	switch utf.getUint16(pos) {
+	case 1:
+		j := sort.Search(count, func(j int) bool { return utf.getUint16(pos+4+2*j) >= gid })
+		if j < count && utf.getUint16(pos+4+2*j) == gid {
+			return j
+		}
+	case 2:
+		j := sort.Search(count, func(j int) bool { return utf.getUint16(pos+4+6*j+2) >= gid })
+		if j < count {
+			rec := pos + 4 + 6*j
+			if start := utf.getUint16(rec); gid >= start {
+				return utf.getUint16(rec+4) + gid - start
+			}
+		}
+	}
+	return -1
+}
+
+// gsubApply applies the GSUB lookup with index lookupIndex to glyphs and
+// returns the result. Ligatures are only formed from glyphs of the same bidi
+// level.
+func (utf *utf8FontFile) gsubApply(lookupIndex int, glyphs []gsubGlyphType) []gsubGlyphType {
+	gsub := utf.tableDescriptions["GSUB"].position
+	lookupList := gsub + utf.getUint16(gsub+8)
+	lookup := lookupList + utf.getUint16(lookupList+2+2*lookupIndex)
+	lookupType := utf.getUint16(lookup)
+	count := utf.getUint16(lookup + 4)
+	subtables := make([]int, 0, count)
+	types := make([]int, 0, count)
+	for j := 0; j < count; j++ {
+		sub := lookup + utf.getUint16(lookup+6+2*j)
+		tp := lookupType
+		if tp == 7 {
+			tp = utf.getUint16(sub + 2)
+			sub += utf.uint32At(sub + 4)
+		}
+		subtables = append(subtables, sub)
+		types = append(types, tp)
+	}
+	out := make([]gsubGlyphType, 0, len(glyphs))
+	for j := 0; j < len(glyphs); {
+		n := 0
+		for k, sub := range subtables {
+			var g gsubGlyphType
+			switch types[k] {
+			case 1:
+				g, n = utf.gsubSingle(sub, glyphs[j])
+			case 4:
+				g, n = utf.gsubLigature(sub, glyphs[j:])
+			}
+			if n > 0 {
+				out = append(out, g)
+				break
+			}
+		}
+		if n == 0 {
+			out = append(out, glyphs[j])
+			n = 1
+		}
+		j += n
+	}
+	return out
+}
+
+// gsubSingle applies the single substitution subtable at position sub to g.
+// It returns the substituted glyph and 1, or 0 if the subtable does not apply.
+func (utf *utf8FontFile) gsubSingle(sub int, g gsubGlyphType) (gsubGlyphType, int) {
+	ix := utf.coverageIndex(sub+utf.getUint16(sub+2), g.gid)
+	if ix < 0 {
+		return g, 0
+	}
+	switch utf.getUint16(sub) {
+	case 1:
+		g.gid = (g.gid + utf.int16At(sub+4)) & 0xffff
+	case 2:
+		if ix >= utf.getUint16(sub+4) {
+			return g, 0
+		}
+		g.gid = utf.getUint16(sub + 6 + 2*ix)
+	default:
+		return g, 0
+	}
+	return g, 1
+}
+
+// gsubLigature applies the ligature substitution subtable at position sub to
+// the start of glyphs. It returns the ligature glyph and the number of glyphs
+// it replaces, or 0 if the subtable does not apply.
+func (utf *utf8FontFile) gsubLigature(sub int, glyphs []gsubGlyphType) (gsubGlyphType, int) {
+	g := glyphs[0]
+	ix := utf.coverageIndex(sub+utf.getUint16(sub+2), g.gid)
+	if utf.getUint16(sub) != 1 || ix < 0 || ix >= utf.getUint16(sub+4) {
+		return g, 0
+	}
+	ligSet := sub + utf.getUint16(sub+6+2*ix)
+	count := utf.getUint16(ligSet)
+	for j := 0; j < count; j++ {
+		lig := ligSet + utf.getUint16(ligSet+2+2*j)
+		compCount := utf.getUint16(lig + 2)
+		if compCount < 1 || compCount > len(glyphs) {
+			continue
+		}
+		match := true
+		for k := 1; k < compCount && match; k++ {
+			match = glyphs[k].level == g.level && glyphs[k].gid == utf.getUint16(lig+4+2*(k-1))
+		}
+		if match {
+			g.gid = utf.getUint16(lig)
+			return g, compCount
+		}
+	}
+	return g, 0
+}
+
+// scriptTag returns the OpenType tag of the script of the first letter of rs
+// that belongs to a script with a tag known to gofpdf
+func scriptTag(rs []rune) string {
+	for _, r := range rs {
+		switch {
+		case r >= 0x0590 && r <= 0x05ff, r >= 0xfb1d && r <= 0xfb4f:
+			return "hebr"
+		case r >= 0x0600 && r <= 0x06ff, r >= 0x0750 && r <= 0x077f,
+			r >= 0xfb50 && r <= 0xfdff, r >= 0xfe70 && r <= 0xfeff:
+			return "arab"
+		case r >= 0x0370 && r <= 0x03ff:
+			return "grek"
+		case r >= 0x0400 && r <= 0x04ff:
+			return "cyrl"
+		case r >= 0x0e00 && r <= 0x0e7f:
+			return "thai"
+		case r >= 'A' && r <= 'Z', r >= 'a' && r <= 'z', r >= 0xc0 && r <= 0x24f:
+			return "latn"
+		}
+	}
+	return "DFLT"
+}
+
+// shape is the built-in shaper. It converts txtStr to glyphs of the font,
+// applies the bidirectional algorithm and Arabic shaping in the same way as
+// Write() and the standard ligatures of the font's GSUB table, and returns
+// the glyphs in visual order.
+func (utf *utf8FontFile) shape(txtStr string, base int) []ShapedGlyph {
+	rs := arabicShape([]rune(txtStr))
+	levels := bidiLevels(rs, base)
+	glyphs := make([]gsubGlyphType, len(rs))
+	for j, r := range rs {
+		if levels[j]%2 == 1 {
+			if m, ok := bidiMirror[r]; ok {
+				r = m
+			}
+		}
+		glyphs[j] = gsubGlyphType{gid: utf.runeGlyph(r), level: levels[j]}
+	}
+	for _, lookup := range utf.gsubLookups(scriptTag(rs), gsubFeatures) {
+		glyphs = utf.gsubApply(lookup, glyphs)
+	}
+	levels = levels[:len(glyphs)]
+	for j, g := range glyphs {
+		levels[j] = g.level
+	}
+	list := make([]ShapedGlyph, len(glyphs))
+	for j, k := range bidiOrder(levels) {
+		gid := glyphs[k].gid
+		list[j] = ShapedGlyph{GlyphID: gid, XAdvance: math.Round(utf.glyphAdvance(gid))}
+	}
+	return list
+}
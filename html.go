@@ -0,0 +1,893 @@
+package gofpdf
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// HTMLOptions specifies how WriteHTML() renders HTML. Distances are in the
+// unit of measure specified in New().
+type HTMLOptions struct {
+	// LinkColor is the text color of hyperlinks, which are also underlined.
+	// If it is nil, links are dark blue.
+	LinkColor *RGBType
+	// ParagraphSpacing is the vertical space above and below paragraphs,
+	// headings, lists, tables and horizontal rules. Zero means half the
+	// current font size.
+	ParagraphSpacing float64
+	// ListIndent is the indentation of each level of lists and of block
+	// quotes. List markers are printed in it. Zero means 2.5 times the
+	// current font size.
+	ListIndent float64
+}
+
+// htmlNode is an element or, if tag is empty, a text node of an HTML document
+type htmlNode struct {
+	tag      string
+	attr     map[string]string
+	text     string
+	children []*htmlNode
+}
+
+// htmlVoidTags are the elements that have no content or end tag
+var htmlVoidTags = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "wbr": true,
+}
+
+// htmlBlockTags are the elements that implicitly end an open paragraph
+var htmlBlockTags = map[string]bool{
+	"blockquote": true, "center": true, "div": true, "h1": true, "h2": true,
+	"h3": true, "h4": true, "h5": true, "h6": true, "hr": true, "ol": true,
+	"p": true, "pre": true, "table": true, "ul": true,
+}
+
+// htmlHeadingSizes are the font sizes of the headings h1 through h6 relative
+// to the size of the surrounding text
+var htmlHeadingSizes = []float64{2, 1.5, 1.17, 1, .83, .67}
+
+// htmlFontSizes are the font sizes, in points, of the sizes 1 through 7 of
+// the <font> tag
+var htmlFontSizes = []float64{7.5, 10, 12, 13.5, 18, 24, 36}
+
+// htmlColors are the named colors that are recognized in HTML attributes and
+// style sheets
+var htmlColors = map[string]RGBType{
+	"aqua": {0, 255, 255}, "black": {0, 0, 0}, "blue": {0, 0, 255},
+	"fuchsia": {255, 0, 255}, "gray": {128, 128, 128}, "green": {0, 128, 0},
+	"grey": {128, 128, 128}, "lime": {0, 255, 0}, "maroon": {128, 0, 0},
+	"navy": {0, 0, 128}, "olive": {128, 128, 0}, "orange": {255, 165, 0},
+	"purple": {128, 0, 128}, "red": {255, 0, 0}, "silver": {192, 192, 192},
+	"teal": {0, 128, 128}, "white": {255, 255, 255}, "yellow": {255, 255, 0},
+}
+
+// parseHTML parses htmlStr into a tree of nodes. Like browsers, it accepts
+// unclosed paragraphs, list items and table cells, and ignores end tags
+// without a matching start tag.
+func parseHTML(htmlStr string) *htmlNode {
+	root := &htmlNode{}
+	stack := []*htmlNode{root}
+	addText := func(s string) {
+		if s != "" {
+			top := stack[len(stack)-1]
+			top.children = append(top.children, &htmlNode{text: html.UnescapeString(s)})
+		}
+	}
+	// closeTo closes the innermost open element named tag unless one of the
+	// elements stops is open inside it
+	closeTo := func(tag string, stops ...string) {
+		for k := len(stack) - 1; k > 0; k-- {
+			if stack[k].tag == tag {
+				stack = stack[:k]
+				return
+			}
+			for _, stop := range stops {
+				if stack[k].tag == stop {
+					return
+				}
+			}
+		}
+	}
+	for len(htmlStr) > 0 {
+		if htmlStr[0] != '<' {
+			pos := strings.IndexByte(htmlStr, '<')
+			if pos < 0 {
+				pos = len(htmlStr)
+			}
+			addText(htmlStr[:pos])
+			htmlStr = htmlStr[pos:]
+			continue
+		}
+		switch {
+		case strings.HasPrefix(htmlStr, "<!--"):
+			pos := strings.Index(htmlStr, "-->")
+			if pos < 0 {
+				pos = len(htmlStr) - 3
+			}
+			htmlStr = htmlStr[pos+3:]
+		case strings.HasPrefix(htmlStr, "<!") || strings.HasPrefix(htmlStr, "<?"):
+			pos := strings.IndexByte(htmlStr, '>')
+			if pos < 0 {
+				pos = len(htmlStr) - 1
+			}
+			htmlStr = htmlStr[pos+1:]
+		case strings.HasPrefix(htmlStr, "</"):
+			tag, rest := htmlTagName(htmlStr[2:])
+			if tag == "" {
+				addText("<")
+				htmlStr = htmlStr[1:]
+				continue
+			}
+			pos := strings.IndexByte(rest, '>')
+			if pos < 0 {
+				pos = len(rest) - 1
+			}
+			htmlStr = rest[pos+1:]
+			closeTo(tag)
+		default:
+			tag, rest := htmlTagName(htmlStr[1:])
+			if tag == "" {
+				addText("<")
+				htmlStr = htmlStr[1:]
+				continue
+			}
+			attr, rest := htmlAttributes(rest)
+			htmlStr = rest
+			switch tag {
+			case "li":
+				closeTo("li", "ul", "ol")
+			case "tr":
+				closeTo("tr", "table")
+			case "td", "th":
+				closeTo("td", "tr", "table")
+				closeTo("th", "tr", "table")
+			}
+			if htmlBlockTags[tag] {
+				closeTo("p", "li", "td", "th", "blockquote", "div")
+			}
+			node := &htmlNode{tag: tag, attr: attr}
+			top := stack[len(stack)-1]
+			top.children = append(top.children, node)
+			switch {
+			case tag == "script" || tag == "style":
+				// Their content is not text
+				pos := strings.Index(strings.ToLower(htmlStr), "</"+tag)
+				if pos < 0 {
+					pos = len(htmlStr)
+				}
+				htmlStr = htmlStr[pos:]
+			case !htmlVoidTags[tag]:
+				stack = append(stack, node)
+			}
+		}
+	}
+	return root
+}
+
+// htmlTagName returns the lower case tag name at the beginning of s, or an
+// empty string if s does not start with one, and the rest of s
+func htmlTagName(s string) (tag, rest string) {
+	n := 0
+	for n < len(s) && (s[n] >= 'a' && s[n] <= 'z' || s[n] >= 'A' && s[n] <= 'Z' ||
+		n > 0 && s[n] >= '0' && s[n] <= '9') {
+		n++
+	}
+	return strings.ToLower(s[:n]), s[n:]
+}
+
+// htmlAttributes returns the attributes at the beginning of s, up to the end
+// of the tag, and the rest of s after the tag. Attribute names are lower case.
+func htmlAttributes(s string) (attr map[string]string, rest string) {
+	attr = make(map[string]string)
+	isSpace := func(c byte) bool {
+		return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+	}
+	for {
+		s = strings.TrimLeft(s, " \t\n\r\f/")
+		if s == "" {
+			return attr, s
+		}
+		if s[0] == '>' {
+			return attr, s[1:]
+		}
+		n := 0
+		for n < len(s) && !isSpace(s[n]) && s[n] != '=' && s[n] != '>' && s[n] != '/' {
+			n++
+		}
+		if n == 0 {
+			// A stray "="
+			s = s[1:]
+			continue
+		}
+		name := strings.ToLower(s[:n])
+		s = strings.TrimLeft(s[n:], " \t\n\r\f")
+		value := ""
+		if strings.HasPrefix(s, "=") {
+			s = strings.TrimLeft(s[1:], " \t\n\r\f")
+			if s != "" && (s[0] == '"' || s[0] == '\'') {
+				pos := strings.IndexByte(s[1:], s[0])
+				if pos < 0 {
+					pos = len(s) - 1
+				}
+				value, s = s[1:pos+1], s[min(pos+2, len(s)):]
+			} else {
+				n = 0
+				for n < len(s) && !isSpace(s[n]) && s[n] != '>' {
+					n++
+				}
+				value, s = s[:n], s[n:]
+			}
+		}
+		attr[name] = html.UnescapeString(value)
+	}
+}
+
+// htmlStyle is the text style that is inherited by the content of an element
+type htmlStyle struct {
+	family                          string
+	bold, italic, underline, strike bool
+	size                            float64 // in points
+	color                           colorType
+	linkStr                         string
+	align                           string
+	pre                             bool
+}
+
+// run returns a text run of txtStr with style st
+func (st htmlStyle) run(txtStr string) textRun {
+	style := ""
+	for j, flag := range []bool{st.bold, st.italic, st.underline, st.strike} {
+		if flag {
+			style += string("BIUS"[j])
+		}
+	}
+	return textRun{text: txtStr, family: st.family, style: style, size: st.size,
+		color: st.color, linkStr: st.linkStr}
+}
+
+// htmlRenderer lays out an HTML document
+type htmlRenderer struct {
+	f      *Fpdf
+	opts   HTMLOptions
+	runs   []textRun // text of the current paragraph
+	align  string    // alignment of the current paragraph
+	marker *textRun  // list marker of the current paragraph
+	space  float64   // vertical space before the next block
+	lists  []int     // number of the last item of each open list, or -1
+	tr     func(string) string
+}
+
+// WriteHTML renders htmlStr starting at the current ordinate and between the
+// left and right margins, using the current font, font size and text color
+// for text without other formatting. It is a more complete replacement of
+// HTMLBasicNew(). The current position after the call is at the left margin
+// below the rendered text, and the font and text color are unchanged.
+//
+// Paragraphs (<p>, <div>, <center>, <h1> through <h6>, <blockquote>, <pre>
+// and line breaks with <br>) are laid out as with WriteParagraph(). Text can
+// be formatted with <b>, <strong>, <i>, <em>, <u>, <s>, <del>, <code>, <font>
+// with its color, size and face attributes and <span>, and <a href> makes a
+// hyperlink. Ordered and unordered lists (<ol>, <ul>, <li>) can be nested.
+// Tables are drawn with NewTable(), with the cells of a first row of <th>
+// cells as header; cells contain plain text. Horizontal rules are drawn
+// with <hr>. Images (<img>) are placed on their own line; the src attribute
+// is the name of an image registered with RegisterImageOptions() or
+// RegisterImageOptionsReader(), or of an image file. Their width and height
+// attributes are in pixels of 1/96 inch, and images without them are
+// rendered at 96 dpi and scaled down if they are wider than the margins.
+//
+// The style attribute of elements supports a subset of CSS: the properties
+// color, font-family, font-size, font-style, font-weight, text-align,
+// text-decoration, width and height of images, and margin, margin-top,
+// margin-right, margin-bottom and margin-left of blocks. Vertical margins of
+// adjacent blocks collapse. Colors are names, such as "navy", or "#rgb",
+// "#rrggbb" or "rgb(r, g, b)", and lengths are in pt, px, mm, cm, in, em or
+// percent of the font size. Other elements, attributes and properties, and
+// the content of <script> and <style>, are ignored.
+//
+// Text printed with a font that is not a UTF-8 font is converted from UTF-8
+// to code page 1252.
+//
+// The WriteHTML example demonstrates this method.
+func (f *Fpdf) WriteHTML(htmlStr string, opts HTMLOptions) {
+	if f.err != nil {
+		return
+	}
+	if f.fontFamily == "" {
+		f.err = fmt.Errorf("font has not been set; unable to render text")
+		return
+	}
+	if opts.LinkColor == nil {
+		opts.LinkColor = &RGBType{0, 0, 128}
+	}
+	if opts.ParagraphSpacing <= 0 {
+		opts.ParagraphSpacing = f.fontSize / 2
+	}
+	if opts.ListIndent <= 0 {
+		opts.ListIndent = 2.5 * f.fontSize
+	}
+	family, fontStyle, size := f.fontFamily, f.currentStyle(), f.fontSizePt
+	color, colorFlag := f.color.text, f.colorFlag
+	st := htmlStyle{
+		family:    family,
+		bold:      strings.Contains(fontStyle, "B"),
+		italic:    strings.Contains(fontStyle, "I"),
+		underline: f.underline,
+		strike:    f.strikeout,
+		size:      size,
+		color:     color,
+		align:     "L",
+	}
+	r := &htmlRenderer{f: f, opts: opts}
+	r.render(parseHTML(htmlStr), st)
+	r.flush()
+	f.y += r.space
+	f.x = f.lMargin
+	if f.err != nil {
+		return
+	}
+	if f.fontFamily != family || f.currentStyle() != fontStyle || f.fontSizePt != size {
+		f.SetFont(family, fontStyle, size)
+	}
+	f.color.text, f.colorFlag = color, colorFlag
+}
+
+// render renders the content of node with style st
+func (r *htmlRenderer) render(node *htmlNode, st htmlStyle) {
+	for _, child := range node.children {
+		if r.f.err != nil {
+			return
+		}
+		if child.tag == "" {
+			r.text(child.text, st)
+		} else {
+			r.element(child, st)
+		}
+	}
+}
+
+// text adds txtStr to the current paragraph
+func (r *htmlRenderer) text(txtStr string, st htmlStyle) {
+	if st.pre {
+		txtStr = strings.Replace(txtStr, "\r", "", -1)
+		txtStr = strings.Replace(txtStr, "\t", " ", -1)
+	} else {
+		txtStr = strings.Map(func(c rune) rune {
+			if c == '\n' || c == '\r' || c == '\t' || c == '\f' {
+				return ' '
+			}
+			return c
+		}, txtStr)
+	}
+	r.add(st.run(txtStr), st.align)
+}
+
+// add adds run to the current paragraph, which is aligned with alignStr if
+// run is its first run
+func (r *htmlRenderer) add(run textRun, alignStr string) {
+	if len(r.runs) == 0 {
+		r.align = alignStr
+	}
+	r.runs = append(r.runs, run)
+}
+
+// element renders the element node, whose parent has style st
+func (r *htmlRenderer) element(node *htmlNode, st htmlStyle) {
+	f := r.f
+	st = r.style(node, st)
+	spacing := r.opts.ParagraphSpacing
+	switch node.tag {
+	case "br":
+		r.add(st.run("\n"), st.align)
+	case "p", "h1", "h2", "h3", "h4", "h5", "h6":
+		r.block(node, st, spacing, 0, func() { r.render(node, st) })
+	case "div", "center":
+		r.block(node, st, 0, 0, func() { r.render(node, st) })
+	case "pre":
+		st.pre = true
+		r.block(node, st, spacing, 0, func() { r.render(node, st) })
+	case "blockquote":
+		r.block(node, st, spacing, r.opts.ListIndent, func() { r.render(node, st) })
+	case "ul", "ol":
+		if len(r.lists) > 0 {
+			spacing = 0
+		}
+		r.block(node, st, spacing, r.opts.ListIndent, func() {
+			n := -1
+			if node.tag == "ol" {
+				n = 0
+				if start, err := strconv.Atoi(node.attr["start"]); err == nil {
+					n = start - 1
+				}
+			}
+			r.lists = append(r.lists, n)
+			r.render(node, st)
+			r.lists = r.lists[:len(r.lists)-1]
+		})
+	case "li":
+		r.block(node, st, 0, 0, func() {
+			marker := st.run("• ")
+			marker.linkStr = ""
+			if k := len(r.lists) - 1; k >= 0 && r.lists[k] >= 0 {
+				r.lists[k]++
+				if value, err := strconv.Atoi(node.attr["value"]); err == nil {
+					r.lists[k] = value
+				}
+				marker.text = fmt.Sprintf("%d. ", r.lists[k])
+			}
+			r.marker = &marker
+			r.render(node, st)
+		})
+	case "hr":
+		r.flush()
+		r.space = math.Max(r.space, spacing)
+		r.startBlock()
+		if f.y > f.pageBreakTrigger && !f.inHeader && !f.inFooter && f.acceptBreak() {
+			f.AddPageFormat(f.curOrientation, f.curPageSize)
+		}
+		f.Line(f.lMargin, f.y, f.w-f.rMargin, f.y)
+		r.space = spacing
+	case "img":
+		r.image(node, st)
+	case "table":
+		r.table(node, st, spacing)
+	case "head", "script", "style", "title":
+	default:
+		r.render(node, st)
+	}
+}
+
+// style returns the style of the content of the element node, whose parent
+// has style st
+func (r *htmlRenderer) style(node *htmlNode, st htmlStyle) htmlStyle {
+	switch node.tag {
+	case "b", "strong", "th":
+		st.bold = true
+	case "i", "em", "cite", "var":
+		st.italic = true
+	case "u", "ins":
+		st.underline = true
+	case "s", "strike", "del":
+		st.strike = true
+	case "code", "kbd", "pre", "samp", "tt":
+		st.family = "courier"
+	case "center":
+		st.align = "C"
+	case "a":
+		if href, ok := node.attr["href"]; ok {
+			st.linkStr = href
+			clr := r.opts.LinkColor
+			st.color = rgbColorValue(clr.R, clr.G, clr.B, "g", "rg")
+			st.underline = true
+		}
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		st.bold = true
+		st.size *= htmlHeadingSizes[node.tag[1]-'1']
+	case "font":
+		if value := node.attr["size"]; value != "" {
+			n, err := strconv.Atoi(strings.TrimPrefix(value, "+"))
+			if err == nil {
+				if value[0] == '+' || value[0] == '-' {
+					n += 3
+				}
+				st.size = htmlFontSizes[max(min(n, 7), 1)-1]
+			}
+		}
+		if clr, ok := htmlColor(node.attr["color"]); ok {
+			st.color = rgbColorValue(clr.R, clr.G, clr.B, "g", "rg")
+		}
+		if family, ok := r.f.cssFontFamily(node.attr["face"]); ok {
+			st.family = family
+		}
+	}
+	if alignStr, ok := htmlAlign(node.attr["align"]); ok && node.tag != "img" && node.tag != "table" {
+		st.align = alignStr
+	}
+	for name, value := range htmlCSS(node.attr["style"]) {
+		switch name {
+		case "color":
+			if clr, ok := htmlColor(value); ok {
+				st.color = rgbColorValue(clr.R, clr.G, clr.B, "g", "rg")
+			}
+		case "font-family":
+			if family, ok := r.f.cssFontFamily(value); ok {
+				st.family = family
+			}
+		case "font-size":
+			if size, ok := htmlLength(value, st.size); ok && size > 0 {
+				st.size = size
+			}
+		case "font-style":
+			st.italic = value == "italic" || value == "oblique"
+		case "font-weight":
+			if n, err := strconv.Atoi(value); err == nil {
+				st.bold = n >= 600
+			} else {
+				st.bold = value == "bold" || value == "bolder"
+			}
+		case "text-align":
+			if alignStr, ok := htmlAlign(value); ok {
+				st.align = alignStr
+			}
+		case "text-decoration":
+			st.underline = strings.Contains(value, "underline")
+			st.strike = strings.Contains(value, "line-through")
+		}
+	}
+	return st
+}
+
+// cssFontFamily returns the first font family of the comma separated list
+// value of a CSS font-family property which is a core font or has been added
+// to the document
+func (f *Fpdf) cssFontFamily(value string) (string, bool) {
+	generic := map[string]string{
+		"arial": "helvetica", "courier new": "courier", "monospace": "courier",
+		"sans-serif": "helvetica", "serif": "times", "times new roman": "times",
+	}
+	for _, name := range strings.Split(value, ",") {
+		name = strings.ToLower(strings.Trim(strings.TrimSpace(name), `"'`))
+		if family, ok := generic[name]; ok {
+			name = family
+		}
+		if _, ok := f.coreFonts[name]; ok {
+			return name, true
+		}
+		for _, style := range []string{"", "B", "I", "BI"} {
+			if _, ok := f.fonts[name+style]; ok {
+				return name, true
+			}
+		}
+	}
+	return "", false
+}
+
+// block renders a block element with the body function. The vertical
+// margins of the block are spacing and its left and right margins indent,
+// unless they are set by its style attribute.
+func (r *htmlRenderer) block(node *htmlNode, st htmlStyle, spacing, indent float64, body func()) {
+	f := r.f
+	margins := [4]float64{spacing, 0, spacing, indent}
+	if node.tag == "blockquote" {
+		margins[1] = indent
+	}
+	css := htmlCSS(node.attr["style"])
+	if value, ok := css["margin"]; ok {
+		fields := strings.Fields(value)
+		// The top, right, bottom and left margins are taken from these
+		// fields of 1 to 4 fields
+		index := [][4]int{{0, 0, 0, 0}, {0, 1, 0, 1}, {0, 1, 2, 1}, {0, 1, 2, 3}}
+		if n := len(fields); n > 0 && n <= 4 {
+			for j, k := range index[n-1] {
+				if length, ok := htmlLength(fields[k], st.size); ok {
+					margins[j] = length / f.k
+				}
+			}
+		}
+	}
+	for j, side := range []string{"top", "right", "bottom", "left"} {
+		if length, ok := htmlLength(css["margin-"+side], st.size); ok {
+			margins[j] = length / f.k
+		}
+	}
+	r.flush()
+	r.space = math.Max(r.space, margins[0])
+	lMargin, rMargin := f.lMargin, f.rMargin
+	f.lMargin += margins[3]
+	f.rMargin += margins[1]
+	body()
+	r.flush()
+	f.lMargin, f.rMargin = lMargin, rMargin
+	r.space = math.Max(r.space, margins[2])
+}
+
+// flush writes the current paragraph, if it has any text or a list marker
+func (r *htmlRenderer) flush() {
+	f := r.f
+	runs := r.runs
+	r.runs = nil
+	blank := true
+	for _, run := range runs {
+		blank = blank && strings.TrimSpace(run.text) == ""
+	}
+	if blank && r.marker == nil || f.err != nil {
+		return
+	}
+	style := ParagraphStyle{SpaceBefore: r.space, Alignment: r.align}
+	for j := range runs {
+		runs[j] = r.translate(runs[j])
+	}
+	if r.marker != nil {
+		marker := r.translate(*r.marker)
+		f.useRun(&marker)
+		style.FirstLineIndent = -f.GetStringWidth(marker.text)
+		runs = append([]textRun{marker}, runs...)
+		r.marker = nil
+	}
+	f.writeRuns(style, runs)
+	r.space = 0
+}
+
+// startBlock moves to the left margin below the space that precedes a block
+// other than a paragraph
+func (r *htmlRenderer) startBlock() {
+	r.f.y += r.space
+	r.f.x = r.f.lMargin
+	r.space = 0
+}
+
+// translate returns run with its text converted to code page 1252 if its
+// font is not a UTF-8 font
+func (r *htmlRenderer) translate(run textRun) textRun {
+	styleStr := strings.Replace(strings.Replace(run.style, "U", "", -1), "S", "", -1)
+	if font, ok := r.f.fonts[getFontKey(run.family, styleStr)]; ok && font.Tp == "UTF8" {
+		return run
+	}
+	if r.tr == nil {
+		r.tr = r.f.UnicodeTranslatorFromDescriptor("")
+	}
+	run.text = r.tr(run.text)
+	return run
+}
+
+// image places the image of node on its own line
+func (r *htmlRenderer) image(node *htmlNode, st htmlStyle) {
+	f := r.f
+	src := node.attr["src"]
+	if src == "" {
+		return
+	}
+	r.flush()
+	r.startBlock()
+	info := f.RegisterImageOptions(src, ImageOptions{})
+	if f.err != nil {
+		return
+	}
+	// Width and height in points
+	var w, h float64
+	if px, err := strconv.ParseFloat(node.attr["width"], 64); err == nil {
+		w = px * 72 / 96
+	}
+	if px, err := strconv.ParseFloat(node.attr["height"], 64); err == nil {
+		h = px * 72 / 96
+	}
+	css := htmlCSS(node.attr["style"])
+	if length, ok := htmlLength(css["width"], st.size); ok {
+		w = length
+	}
+	if length, ok := htmlLength(css["height"], st.size); ok {
+		h = length
+	}
+	switch {
+	case w <= 0 && h <= 0:
+		w, h = info.w*72/96, info.h*72/96
+	case w <= 0:
+		w = h * info.w / info.h
+	case h <= 0:
+		h = w * info.h / info.w
+	}
+	w, h = w/f.k, h/f.k
+	if avail := f.w - f.lMargin - f.rMargin; w > avail {
+		w, h = avail, h*avail/w
+	}
+	x := f.lMargin
+	alignStr, _ := htmlAlign(node.attr["align"])
+	if alignStr == "" {
+		alignStr = st.align
+	}
+	switch alignStr {
+	case "C":
+		x += (f.w - f.lMargin - f.rMargin - w) / 2
+	case "R":
+		x = f.w - f.rMargin - w
+	}
+	f.ImageOptions(src, x, 0, w, h, true, ImageOptions{}, 0, st.linkStr)
+	f.x = f.lMargin
+}
+
+// table draws the rows of the table node with NewTable()
+func (r *htmlRenderer) table(node *htmlNode, st htmlStyle, spacing float64) {
+	f := r.f
+	var rows [][]*htmlNode
+	var collect func(node *htmlNode)
+	collect = func(node *htmlNode) {
+		for _, child := range node.children {
+			switch child.tag {
+			case "thead", "tbody", "tfoot":
+				collect(child)
+			case "tr":
+				var cells []*htmlNode
+				for _, cell := range child.children {
+					if cell.tag == "td" || cell.tag == "th" {
+						cells = append(cells, cell)
+					}
+				}
+				if len(cells) > 0 {
+					rows = append(rows, cells)
+				}
+			}
+		}
+	}
+	collect(node)
+	r.flush()
+	r.space = math.Max(r.space, spacing)
+	if len(rows) == 0 {
+		return
+	}
+	r.startBlock()
+	n := 0
+	for _, cells := range rows {
+		n = max(n, len(cells))
+	}
+	columns := make([]TableColumnType, n)
+	header := len(rows[0]) == n
+	for _, cell := range rows[0] {
+		header = header && cell.tag == "th"
+	}
+	for _, cells := range rows {
+		for j, cell := range cells {
+			if alignStr, ok := htmlAlign(cell.attr["align"]); ok && alignStr != "J" && columns[j].Align == "" {
+				columns[j].Align = alignStr
+			}
+		}
+	}
+	if header {
+		for j, cell := range rows[0] {
+			columns[j].Header = r.translate(st.run(htmlPlainText(cell))).text
+		}
+		rows = rows[1:]
+	}
+	t := f.NewTable(columns)
+	if border := node.attr["border"]; border == "" || border == "0" {
+		t.SetBorder("")
+	}
+	if header {
+		t.SetHeaderStyle("B", nil)
+	}
+	if value := node.attr["width"]; strings.HasSuffix(value, "%") {
+		if pct, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64); err == nil {
+			t.SetWidth((f.w - f.lMargin - f.rMargin) * pct / 100)
+		}
+	} else if px, err := strconv.ParseFloat(value, 64); err == nil {
+		t.SetWidth(px * 72 / 96 / f.k)
+	}
+	for _, cells := range rows {
+		texts := make([]string, n)
+		for j, cell := range cells {
+			texts[j] = r.translate(st.run(htmlPlainText(cell))).text
+		}
+		t.AddRow(texts...)
+	}
+	run := st.run("")
+	run.style = strings.Replace(strings.Replace(run.style, "U", "", -1), "S", "", -1)
+	f.useRun(&run)
+	t.Draw()
+	f.x = f.lMargin
+	r.space = spacing
+}
+
+// htmlPlainText returns the text of node with its whitespace collapsed and
+// line breaks for its <br> elements
+func htmlPlainText(node *htmlNode) string {
+	var s strings.Builder
+	var walk func(node *htmlNode)
+	walk = func(node *htmlNode) {
+		for _, child := range node.children {
+			switch child.tag {
+			case "":
+				s.WriteString(child.text)
+			case "br":
+				s.WriteByte('\n')
+			case "script", "style":
+			default:
+				walk(child)
+			}
+		}
+	}
+	walk(node)
+	lines := strings.Split(s.String(), "\n")
+	for j, line := range lines {
+		lines[j] = strings.Join(strings.Fields(line), " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// htmlCSS returns the properties of the CSS declarations of a style
+// attribute, with lower case names and values
+func htmlCSS(style string) map[string]string {
+	css := make(map[string]string)
+	for _, decl := range strings.Split(style, ";") {
+		pos := strings.IndexByte(decl, ':')
+		if pos < 0 {
+			continue
+		}
+		name := strings.ToLower(strings.TrimSpace(decl[:pos]))
+		value := strings.TrimSpace(decl[pos+1:])
+		if name != "font-family" {
+			value = strings.ToLower(value)
+		}
+		css[name] = value
+	}
+	return css
+}
+
+// htmlAlign returns the alignment of a paragraph for the value of an align
+// attribute or a text-align property
+func htmlAlign(value string) (string, bool) {
+	switch strings.ToLower(value) {
+	case "left":
+		return "L", true
+	case "center":
+		return "C", true
+	case "right":
+		return "R", true
+	case "justify":
+		return "J", true
+	}
+	return "", false
+}
+
+// htmlLength returns a CSS length in points. Lengths in em and percentages
+// are relative to fontSize, in points, and lengths without unit are in
+// pixels.
+func htmlLength(value string, fontSize float64) (float64, bool) {
+	value = strings.TrimSpace(strings.ToLower(value))
+	units := []struct {
+		suffix string
+		scale  float64
+	}{
+		{"pt", 1}, {"px", .75}, {"mm", 72 / 25.4}, {"cm", 72 / 2.54},
+		{"in", 72}, {"em", fontSize}, {"%", fontSize / 100},
+	}
+	scale := .75
+	for _, unit := range units {
+		if strings.HasSuffix(value, unit.suffix) {
+			value, scale = strings.TrimSuffix(value, unit.suffix), unit.scale
+			break
+		}
+	}
+	length, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, false
+	}
+	return length * scale, true
+}
+
+// htmlColor returns the color of a named color or a color written as
+// "#rgb", "#rrggbb" or "rgb(r, g, b)"
+func htmlColor(value string) (clr RGBType, ok bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if clr, ok = htmlColors[value]; ok {
+		return
+	}
+	switch {
+	case strings.HasPrefix(value, "#") && (len(value) == 4 || len(value) == 7):
+		digits := value[1:]
+		if len(digits) == 3 {
+			digits = string([]byte{digits[0], digits[0], digits[1], digits[1], digits[2], digits[2]})
+		}
+		n, err := strconv.ParseUint(digits, 16, 32)
+		if err != nil {
+			return clr, false
+		}
+		return RGBType{int(n >> 16), int(n >> 8 & 255), int(n & 255)}, true
+	case strings.HasPrefix(value, "rgb(") && strings.HasSuffix(value, ")"):
+		fields := strings.Split(value[4:len(value)-1], ",")
+		if len(fields) != 3 {
+			return clr, false
+		}
+		var rgb [3]int
+		for j, field := range fields {
+			n, err := strconv.Atoi(strings.TrimSpace(field))
+			if err != nil {
+				return clr, false
+			}
+			rgb[j] = max(min(n, 255), 0)
+		}
+		return RGBType{rgb[0], rgb[1], rgb[2]}, true
+	}
+	return clr, false
+}
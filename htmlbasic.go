@@ -105,7 +105,8 @@ type HTMLBasicType struct {
 }
 
 // HTMLBasicNew returns an instance that facilitates writing basic HTML in the
-// specified PDF file.
+// specified PDF file. WriteHTML() renders a larger subset of HTML, including
+// lists, tables, images and inline styles.
 func (f *Fpdf) HTMLBasicNew() (html HTMLBasicType) {
 	html.pdf = f
 	html.Link.ClrR, html.Link.ClrG, html.Link.ClrB = 0, 0, 128
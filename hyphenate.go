@@ -0,0 +1,243 @@
+package gofpdf
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// Hyphenator finds the points at which words can be hyphenated.
+type Hyphenator interface {
+	// Hyphenate returns, in increasing order, the positions in word, counted
+	// in characters, after which the word can be broken with a hyphen.
+	Hyphenate(word string) []int
+}
+
+// SetHyphenator sets the hyphenator that MultiCell(), Write() and the
+// functions that split text into lines, such as SplitText(), use to break
+// words that do not fit at the end of a line. The part of the word that fits
+// is printed on the line followed by a hyphen, and the rest of the word
+// starts the next line. A nil hyphenator, the default, disables hyphenation.
+//
+// PatternHyphenator hyphenates words with TeX hyphenation patterns. The
+// SetHyphenator example demonstrates this method.
+func (f *Fpdf) SetHyphenator(h Hyphenator) {
+	f.hyphenator = h
+}
+
+// GetHyphenator returns the hyphenator set with SetHyphenator().
+func (f *Fpdf) GetHyphenator() Hyphenator {
+	return f.hyphenator
+}
+
+// PatternHyphenator implements Hyphenator with Liang's algorithm, which is
+// used by TeX, and its hyphenation patterns. Patterns for many languages are
+// available from the hyph-utf8 project and can be loaded with
+// LoadPatternHyphenator().
+type PatternHyphenator struct {
+	// LeftMin and RightMin are the minimum number of characters that are
+	// kept before and after a hyphen. NewPatternHyphenator() sets them to 2
+	// and 3, the values TeX uses for English.
+	LeftMin, RightMin int
+	patterns          map[string][]int
+	exceptions        map[string][]int
+	maxLen            int
+}
+
+// NewPatternHyphenator returns a hyphenator for the TeX hyphenation patterns
+// and exceptions. A pattern such as "hen5at" consists of letters, with "." at
+// the beginning or end of words, and digits between them; odd digits allow a
+// hyphen and even digits prevent it, and the highest digit at a point of a
+// word matched by several patterns wins. Exceptions are words with the
+// hyphens at which they are broken, such as "ta-ble". Patterns and exceptions
+// are matched with the lower case letters of words.
+func NewPatternHyphenator(patterns, exceptions []string) *PatternHyphenator {
+	h := &PatternHyphenator{
+		LeftMin:    2,
+		RightMin:   3,
+		patterns:   make(map[string][]int, len(patterns)),
+		exceptions: make(map[string][]int, len(exceptions)),
+	}
+	for _, pattern := range patterns {
+		var letters []rune
+		values := []int{0}
+		for _, c := range pattern {
+			if c >= '0' && c <= '9' {
+				values[len(values)-1] = int(c - '0')
+			} else {
+				letters = append(letters, unicode.ToLower(c))
+				values = append(values, 0)
+			}
+		}
+		if len(letters) == 0 {
+			continue
+		}
+		h.patterns[string(letters)] = values
+		h.maxLen = max(h.maxLen, len(letters))
+	}
+	for _, exception := range exceptions {
+		var letters []rune
+		var positions []int
+		for _, c := range exception {
+			if c == '-' {
+				positions = append(positions, len(letters))
+			} else {
+				letters = append(letters, unicode.ToLower(c))
+			}
+		}
+		h.exceptions[string(letters)] = positions
+	}
+	return h
+}
+
+// LoadPatternHyphenator returns a hyphenator for the patterns and exceptions
+// of a TeX hyphenation file in UTF-8, such as the hyph-*.tex files of the
+// hyph-utf8 project. The patterns are read from the \patterns{...} group of
+// the file and the exceptions from its \hyphenation{...} group; other
+// commands and comments are ignored.
+func LoadPatternHyphenator(r io.Reader) (*PatternHyphenator, error) {
+	var patterns, exceptions []string
+	var group *[]string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if pos := strings.IndexByte(line, '%'); pos >= 0 {
+			line = line[:pos]
+		}
+		for _, field := range strings.Fields(line) {
+			switch {
+			case strings.HasPrefix(field, `\patterns{`):
+				group, field = &patterns, field[len(`\patterns{`):]
+			case strings.HasPrefix(field, `\hyphenation{`):
+				group, field = &exceptions, field[len(`\hyphenation{`):]
+			}
+			if group == nil {
+				continue
+			}
+			if pos := strings.IndexByte(field, '}'); pos >= 0 {
+				if pos > 0 {
+					*group = append(*group, field[:pos])
+				}
+				group = nil
+				continue
+			}
+			if field != "" && field[0] != '\\' {
+				*group = append(*group, field)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(patterns) == 0 {
+		return nil, fmt.Errorf("no hyphenation patterns found")
+	}
+	return NewPatternHyphenator(patterns, exceptions), nil
+}
+
+// Hyphenate implements Hyphenator.
+func (h *PatternHyphenator) Hyphenate(word string) (positions []int) {
+	letters := []rune(strings.ToLower(word))
+	n := len(letters)
+	if n < h.LeftMin+h.RightMin {
+		return nil
+	}
+	if exception, ok := h.exceptions[string(letters)]; ok {
+		return exception
+	}
+	// points[k] is the value of the point before the kth character of the
+	// word enclosed in dots
+	w := append(append([]rune{'.'}, letters...), '.')
+	points := make([]int, len(w)+1)
+	for start := range w {
+		for end := start + 1; end <= len(w) && end-start <= h.maxLen; end++ {
+			if values, ok := h.patterns[string(w[start:end])]; ok {
+				for k, v := range values {
+					if v > points[start+k] {
+						points[start+k] = v
+					}
+				}
+			}
+		}
+	}
+	for pos := max(h.LeftMin, 1); pos <= n-max(h.RightMin, 1); pos++ {
+		if points[pos+1]%2 == 1 {
+			positions = append(positions, pos)
+		}
+	}
+	return
+}
+
+// lineText returns the characters txt of a line of text as a string, with
+// the characters of non-UTF-8 fonts converted back to bytes
+func (f *Fpdf) lineText(txt []rune) string {
+	if f.isCurrentUTF8 {
+		return string(txt)
+	}
+	b := make([]byte, len(txt))
+	for j, c := range txt {
+		b[j] = byte(c)
+	}
+	return string(b)
+}
+
+// hyphenPoints returns, in increasing order, the positions in txt at which
+// the word that starts at txt[start] can be hyphenated, or nil if no
+// hyphenator is set
+func (f *Fpdf) hyphenPoints(txt []rune, start int) (points []int) {
+	if f.hyphenator == nil {
+		return nil
+	}
+	isLetter := func(c rune) bool {
+		return unicode.IsLetter(c) && (c < 128 || f.isCurrentUTF8)
+	}
+	// Leading and trailing punctuation is not passed to the hyphenator
+	first := start
+	for first < len(txt) && !unicode.IsSpace(txt[first]) && !isLetter(txt[first]) {
+		first++
+	}
+	end := first
+	for end < len(txt) && isLetter(txt[end]) {
+		end++
+	}
+	if end == first {
+		return nil
+	}
+	for _, pos := range f.hyphenator.Hyphenate(f.lineText(txt[first:end])) {
+		if pos > 0 && first+pos < end {
+			points = append(points, first+pos)
+		}
+	}
+	return
+}
+
+// hyphenation returns the number of characters of the word that starts at
+// txt[start] which can be printed, followed by a hyphen, at the end of the
+// line that starts at txt[j], so that the line is at most wmax thousandths
+// of the font size wide. It returns 0 if no hyphenator is set or the word
+// cannot be broken so that its first part fits.
+func (f *Fpdf) hyphenation(txt []rune, j, start int, wmax float64) int {
+	points := f.hyphenPoints(txt, start)
+	for k := len(points) - 1; k >= 0; k-- {
+		line := f.lineText(txt[j:points[k]]) + "-"
+		if f.GetStringWidth(line)*1000/f.fontSize <= wmax {
+			return points[k] - start
+		}
+	}
+	return 0
+}
+
+// textRunes returns the characters of s, which are bytes in non-UTF-8 fonts
+func (f *Fpdf) textRunes(s string) []rune {
+	if f.isCurrentUTF8 {
+		return []rune(s)
+	}
+	txt := make([]rune, len(s))
+	for j := 0; j < len(s); j++ {
+		txt[j] = rune(s[j])
+	}
+	return txt
+}
@@ -0,0 +1,310 @@
+package gofpdf
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"strings"
+)
+
+// iccProfileType is an ICC color profile registered with the document
+type iccProfileType struct {
+	name       string
+	data       []byte
+	components int
+	objNum     int
+}
+
+// iccAlternates are the device color spaces that viewers use for ICC-based
+// colors with 1, 3 and 4 components when they do not apply the profile
+var iccAlternates = map[int]string{1: "DeviceGray", 3: "DeviceRGB", 4: "DeviceCMYK"}
+
+// iccComponents are the numbers of color components of the device color
+// spaces
+var iccComponents = map[string]int{"DeviceGray": 1, "DeviceRGB": 3, "DeviceCMYK": 4}
+
+// iccHeaderSpaces are the color spaces of the ICC profile header that have 1,
+// 3 and 4 components
+var iccHeaderSpaces = map[string]int{"GRAY": 1, "RGB ": 3, "CMYK": 4}
+
+// RegisterICCProfile adds the ICC color profile data, such as the profile of
+// a printing condition supplied by a print service, to the document under
+// the name name. components is the number of color components of the
+// profile: 1 for gray, 3 for RGB or 4 for CMYK profiles. Colors are set in the
+// color space of the profile with SetDrawColorICC(), SetFillColorICC() and
+// SetTextColorICC(), and the profile can be made the output intent of the
+// document with SetOutputIntentProfile().
+//
+// An error occurs if name has already been registered, if components is not
+// 1, 3 or 4, or if data is not an ICC profile with that number of
+// components.
+//
+// The RegisterICCProfile example demonstrates this method.
+func (f *Fpdf) RegisterICCProfile(name string, data []byte, components int) {
+	if f.err != nil {
+		return
+	}
+	if f.iccProfile(name) > 0 {
+		f.err = fmt.Errorf("ICC profile %s is already registered", name)
+		return
+	}
+	if iccAlternates[components] == "" {
+		f.err = fmt.Errorf("ICC profile must have 1, 3 or 4 color components: %d", components)
+		return
+	}
+	if len(data) < 128 || string(data[36:40]) != "acsp" {
+		f.err = fmt.Errorf("ICC profile %s is not valid", name)
+		return
+	}
+	if n, ok := iccHeaderSpaces[string(data[16:20])]; ok && n != components {
+		f.err = fmt.Errorf("ICC profile %s has %d color components, not %d", name, n, components)
+		return
+	}
+	f.iccProfiles = append(f.iccProfiles, iccProfileType{name: name, data: data, components: components})
+}
+
+// iccProfile returns the number of the ICC profile registered as name, or 0
+// if there is none
+func (f *Fpdf) iccProfile(name string) int {
+	for j, pr := range f.iccProfiles {
+		if pr.name == name {
+			return j + 1
+		}
+	}
+	return 0
+}
+
+// iccColorValue returns the color with the components vals in the color
+// space of the ICC profile name, for stroking operations if stroke is true.
+// Components are quietly limited to the range 0 to 1. Its RGB components are
+// an approximation of the color on screen.
+func (f *Fpdf) iccColorValue(name string, vals []float64, stroke bool) (clr colorType, ok bool) {
+	pos := f.iccProfile(name)
+	if pos == 0 {
+		f.err = fmt.Errorf("ICC profile %s is not registered", name)
+		return
+	}
+	pr := f.iccProfiles[pos-1]
+	if len(vals) != pr.components {
+		f.err = fmt.Errorf("ICC profile %s needs %d color components, not %d", name, pr.components, len(vals))
+		return
+	}
+	vals = append([]float64(nil), vals...)
+	var sl []string
+	for j, v := range vals {
+		vals[j] = floatBound(v)
+		sl = append(sl, sprintf("%.3f", vals[j]))
+	}
+	rgb := vals
+	switch len(vals) {
+	case 1:
+		rgb = []float64{vals[0], vals[0], vals[0]}
+	case 4:
+		rgb = make([]float64, 3)
+		for j := range rgb {
+			rgb[j] = (1 - vals[j]) * (1 - vals[3])
+		}
+	}
+	clr.ir, clr.r = colorComp(int(255*rgb[0] + 0.5))
+	clr.ig, clr.g = colorComp(int(255*rgb[1] + 0.5))
+	clr.ib, clr.b = colorComp(int(255*rgb[2] + 0.5))
+	clr.mode = colorModeICC
+	clr.str = sprintf("/ICC%d cs %s scn", pos, strings.Join(sl, " "))
+	if stroke {
+		clr.str = sprintf("/ICC%d CS %s SCN", pos, strings.Join(sl, " "))
+	}
+	return clr, true
+}
+
+// floatBound returns v limited to the range 0 to 1
+func floatBound(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
+
+// SetDrawColorICC sets the color used for all drawing operations to the
+// color with the components vals in the color space of the ICC profile name,
+// registered with RegisterICCProfile(). There is one component per color
+// component of the profile, ranging from 0 to 1; values outside this range
+// are quietly limited to it. Viewers and printers that manage color render
+// the color as the profile defines it. The method can be called before the
+// first page is created. The value is retained from page to page. An error
+// occurs if name is not registered or if the number of components is wrong.
+//
+// The RegisterICCProfile example demonstrates this method.
+func (f *Fpdf) SetDrawColorICC(name string, vals ...float64) {
+	if f.err != nil {
+		return
+	}
+	if clr, ok := f.iccColorValue(name, vals, true); ok {
+		f.color.draw = clr
+		if f.page > 0 {
+			f.out(f.color.draw.str)
+		}
+	}
+}
+
+// SetFillColorICC sets the color used for filling operations to the color
+// with the components vals in the color space of the ICC profile name, in the
+// same way as SetDrawColorICC().
+//
+// The RegisterICCProfile example demonstrates this method.
+func (f *Fpdf) SetFillColorICC(name string, vals ...float64) {
+	if f.err != nil {
+		return
+	}
+	if clr, ok := f.iccColorValue(name, vals, false); ok {
+		f.color.fill = clr
+		f.colorFlag = f.color.fill.str != f.color.text.str
+		if f.page > 0 {
+			f.out(f.color.fill.str)
+		}
+	}
+}
+
+// SetTextColorICC sets the color used for text to the color with the
+// components vals in the color space of the ICC profile name, in the same way
+// as SetDrawColorICC().
+//
+// The RegisterICCProfile example demonstrates this method.
+func (f *Fpdf) SetTextColorICC(name string, vals ...float64) {
+	if f.err != nil {
+		return
+	}
+	if clr, ok := f.iccColorValue(name, vals, false); ok {
+		f.color.text = clr
+		f.colorFlag = f.color.fill.str != f.color.text.str
+	}
+}
+
+// SetOutputIntentProfile makes the ICC profile name, registered with
+// RegisterICCProfile(), the output intent of the document in place of the
+// built-in sRGB profile: the printing condition, such as that of a press and
+// paper, that the document is prepared for. conditionStr identifies the
+// condition, for example "FOGRA39" or "CGATS TR 001"; if it is empty, name is
+// used. The output intent is written for PDF/A and for PDF/X, which requires
+// it, and it is included even if SetOutputIntent() has not been called. An
+// error occurs if name is not registered.
+//
+// The RegisterICCProfile example demonstrates this method.
+func (f *Fpdf) SetOutputIntentProfile(name, conditionStr string) {
+	if f.err != nil {
+		return
+	}
+	pos := f.iccProfile(name)
+	if pos == 0 {
+		f.err = fmt.Errorf("ICC profile %s is not registered", name)
+		return
+	}
+	if conditionStr == "" {
+		conditionStr = name
+	}
+	f.outputProfile, f.outputCondition = pos, conditionStr
+	f.addOutputIntent = true
+}
+
+// putICCProfiles writes the registered ICC profiles as streams
+func (f *Fpdf) putICCProfiles() {
+	for j, pr := range f.iccProfiles {
+		data := pr.data
+		filter := ""
+		if f.compress {
+			data = f.compressStream(data)
+			filter = "/Filter /" + f.filterName() + " "
+		}
+		f.newobj()
+		f.iccProfiles[j].objNum = f.n
+		f.outf("<<%s/N %d /Alternate /%s /Length %d>>", filter, pr.components,
+			iccAlternates[pr.components], f.protect.streamLength(len(data)))
+		f.putstream(data)
+		f.out("endobj")
+	}
+}
+
+// iccPutResourceDict writes the color spaces of the registered ICC profiles
+// in the color space resource dictionary
+func (f *Fpdf) iccPutResourceDict() {
+	for j, pr := range f.iccProfiles {
+		f.outf("/ICC%d [/ICCBased %d 0 R]", j+1, pr.objNum)
+	}
+}
+
+// imageICCProfile returns the ICC profile data embedded in an image with
+// components color components, or nil if data is not such a profile
+func imageICCProfile(data []byte, components int) []byte {
+	if len(data) < 128 || string(data[36:40]) != "acsp" || iccHeaderSpaces[string(data[16:20])] != components {
+		return nil
+	}
+	return data
+}
+
+// jpegICCProfile returns the ICC profile embedded in the APP2 segments of the
+// JPEG data, or nil if there is none
+func jpegICCProfile(data []byte) []byte {
+	const marker = "ICC_PROFILE\x00"
+	var chunks [][]byte
+	pos := 2
+	for pos+4 <= len(data) && data[pos] == 0xff {
+		tp := data[pos+1]
+		if tp == 0xda || tp == 0xd9 {
+			// The image data begins
+			break
+		}
+		n := int(data[pos+2])<<8 | int(data[pos+3])
+		if n < 2 || pos+2+n > len(data) {
+			break
+		}
+		seg := data[pos+4 : pos+2+n]
+		if tp == 0xe2 && len(seg) > len(marker)+2 && string(seg[:len(marker)]) == marker {
+			seq, count := int(seg[len(marker)]), int(seg[len(marker)+1])
+			if chunks == nil {
+				chunks = make([][]byte, count)
+			}
+			if seq < 1 || seq > len(chunks) || count != len(chunks) {
+				return nil
+			}
+			chunks[seq-1] = seg[len(marker)+2:]
+		}
+		pos += 2 + n
+	}
+	var profile []byte
+	for _, chunk := range chunks {
+		if chunk == nil {
+			return nil
+		}
+		profile = append(profile, chunk...)
+	}
+	return profile
+}
+
+// imageICCObject returns the object number of the ICC profile of the image
+// info, which is the object number next if the profile has not been written
+// by another image yet, and whether the profile needs to be written
+func (f *Fpdf) imageICCObject(info *ImageInfoType, next int) (n int, put bool) {
+	key := sprintf("ICC%x", sha1.Sum(info.icc))
+	if n, ok := f.resourceObjs[key]; ok {
+		return n, false
+	}
+	f.resourceObjs[key] = next
+	return next, true
+}
+
+// putImageICC writes the ICC profile of the image info, which has the
+// number of color components of its color space
+func (f *Fpdf) putImageICC(info *ImageInfoType, components int) {
+	data := info.icc
+	filter := ""
+	if f.compress {
+		data = f.compressStream(data)
+		filter = "/Filter /" + f.filterName() + " "
+	}
+	f.newobj()
+	f.outf("<<%s/N %d /Alternate /%s /Length %d>>", filter, components,
+		iccAlternates[components], f.protect.streamLength(len(data)))
+	f.putstream(data)
+	f.out("endobj")
+}
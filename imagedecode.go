@@ -0,0 +1,198 @@
+package gofpdf
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"io"
+	"strings"
+)
+
+// imageJPEGQuality is the quality with which decoded images are encoded as
+// JPEG images by default
+const imageJPEGQuality = 90
+
+// RegisterImageFromImage registers the image img, adding it to the PDF file
+// but not adding it to the page, so that images drawn or decoded in memory,
+// such as charts and WebP and AVIF images decoded with
+// golang.org/x/image/webp or another decoder, can be placed with Image() and
+// the name imgName without being encoded in another format first.
+//
+// The pixels of img are embedded losslessly as a Flate-compressed image, with
+// its transparency, unless options.ImageType is "jpg" or "jpeg", or is empty
+// and img is an image in the YCbCr color model of lossy formats such as JPEG
+// and lossy WebP; the image is then embedded as a JPEG (DCT) image, encoded
+// with the quality options.JPEGQuality, without its transparency. Paletted
+// and gray images keep their color spaces.
+//
+// An error occurs if options.ImageType is not empty, "jpg", "jpeg" or "png",
+// if options.JPEGQuality is not between 0 and 100, or if the image is empty.
+//
+// The RegisterImageFromImage example demonstrates this method.
+func (f *Fpdf) RegisterImageFromImage(imgName string, img image.Image, options ImageOptions) (info *ImageInfoType) {
+	if f.err != nil {
+		return
+	}
+	info, ok := f.images[imgName]
+	if ok {
+		return
+	}
+	if options.JPEGQuality < 0 || options.JPEGQuality > 100 {
+		f.err = fmt.Errorf("JPEG quality (0 - 100) is out of range: %d", options.JPEGQuality)
+		return
+	}
+	info = f.parseimage(img, strings.ToLower(options.ImageType), options.JPEGQuality)
+	if f.err == nil {
+		f.registerImage(imgName, info)
+	}
+	return
+}
+
+// parsedecoded extracts info from the data of an image of type tp that is read
+// by a decoder registered with the image package
+func (f *Fpdf) parsedecoded(r io.Reader, tp string) (info *ImageInfoType) {
+	img, format, err := image.Decode(r)
+	switch {
+	case err == image.ErrFormat:
+		f.err = fmt.Errorf("unsupported image type: %s", tp)
+	case err != nil:
+		f.err = err
+	case format != tp:
+		f.err = fmt.Errorf("image of type %s is a %s image", tp, format)
+	}
+	if f.err != nil {
+		return
+	}
+	return f.parseimage(img, "", 0)
+}
+
+// parseimage extracts info from the image img, which is encoded as an image
+// of type tp, or of the type that suits it if tp is empty. JPEG images are
+// encoded with quality, or the default quality if it is 0.
+func (f *Fpdf) parseimage(img image.Image, tp string, quality int) (info *ImageInfoType) {
+	if tp == "" {
+		tp = "png"
+		if _, ok := img.(*image.YCbCr); ok {
+			tp = "jpg"
+		}
+	}
+	if img.Bounds().Empty() {
+		f.err = fmt.Errorf("image is empty")
+		return
+	}
+	switch tp {
+	case "jpg", "jpeg":
+		if quality == 0 {
+			quality = imageJPEGQuality
+		}
+		var buf bytes.Buffer
+		if f.err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); f.err == nil {
+			info = f.parsejpg(&buf)
+		}
+	case "png":
+		info = f.parsepixels(img)
+	default:
+		f.err = fmt.Errorf("image cannot be embedded as type %s", tp)
+	}
+	return
+}
+
+// parsepixels extracts info from the pixels of img, which are compressed
+// with Flate; the alpha channel becomes a soft mask
+func (f *Fpdf) parsepixels(img image.Image) (info *ImageInfoType) {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	info = f.newImageInfo()
+	info.w, info.h = float64(w), float64(h)
+	info.bpc, info.f = 8, "FlateDecode"
+	if img.ColorModel() == color.Gray16Model {
+		gray := image.NewGray(b)
+		draw.Draw(gray, b, img, b.Min, draw.Src)
+		img = gray
+	}
+	var data []byte
+	// The rows of the soft mask begin with the PNG predictor of the row
+	alpha := make([]byte, 0, (w+1)*h)
+	opaque := true
+	switch m := img.(type) {
+	case *image.Gray:
+		info.cs = "DeviceGray"
+		data = make([]byte, 0, w*h)
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			pos := m.PixOffset(b.Min.X, y)
+			data = append(data, m.Pix[pos:pos+w]...)
+		}
+	case *image.Paletted:
+		pal := m.Palette
+		if len(pal) == 0 || len(pal) > 256 {
+			f.err = fmt.Errorf("image palette has %d colors", len(pal))
+			return
+		}
+		info.cs = "Indexed"
+		info.pal = make([]byte, 0, len(pal)*3)
+		alphas := make([]byte, len(pal))
+		for j, c := range pal {
+			clr := color.NRGBAModel.Convert(c).(color.NRGBA)
+			info.pal = append(info.pal, clr.R, clr.G, clr.B)
+			alphas[j] = clr.A
+		}
+		data = make([]byte, 0, w*h)
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			pos := m.PixOffset(b.Min.X, y)
+			row := m.Pix[pos : pos+w]
+			data = append(data, row...)
+			alpha = append(alpha, 0)
+			for _, j := range row {
+				if int(j) >= len(alphas) {
+					f.err = fmt.Errorf("image pixel has color %d of a palette of %d colors", j, len(alphas))
+					return
+				}
+				alpha = append(alpha, alphas[j])
+				opaque = opaque && alphas[j] == 255
+			}
+		}
+	default:
+		// The pixels of RGBA images are premultiplied with their alpha values
+		var pix []byte
+		var stride int
+		premultiplied := false
+		switch m := img.(type) {
+		case *image.NRGBA:
+			pix, stride = m.Pix[m.PixOffset(b.Min.X, b.Min.Y):], m.Stride
+		case *image.RGBA:
+			pix, stride, premultiplied = m.Pix[m.PixOffset(b.Min.X, b.Min.Y):], m.Stride, true
+		default:
+			nrgba := image.NewNRGBA(b)
+			draw.Draw(nrgba, b, img, b.Min, draw.Src)
+			pix, stride = nrgba.Pix, nrgba.Stride
+		}
+		info.cs = "DeviceRGB"
+		data = make([]byte, 0, w*h*3)
+		for y := 0; y < h; y++ {
+			row := pix[y*stride : y*stride+w*4]
+			alpha = append(alpha, 0)
+			for x := 0; x < w*4; x += 4 {
+				r, g, b, a := row[x], row[x+1], row[x+2], row[x+3]
+				if premultiplied && a != 255 && a != 0 {
+					r = uint8(uint32(r) * 255 / uint32(a))
+					g = uint8(uint32(g) * 255 / uint32(a))
+					b = uint8(uint32(b) * 255 / uint32(a))
+				}
+				data = append(data, r, g, b)
+				alpha = append(alpha, a)
+				opaque = opaque && a == 255
+			}
+		}
+	}
+	info.data = f.compressFlate(data)
+	if !opaque {
+		info.smask = f.compressFlate(alpha)
+		if f.pdfVersion < "1.4" {
+			f.pdfVersion = "1.4"
+		}
+	}
+	return
+}
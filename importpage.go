@@ -0,0 +1,503 @@
+package gofpdf
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// importedPageType is a page imported with ImportPage()
+type importedPageType struct {
+	name string  // name of the form XObject
+	w, h float64 // size of the page, in points
+}
+
+// pdfImporter copies the objects of a parsed PDF document to the imported
+// objects of a document
+type pdfImporter struct {
+	f      *Fpdf
+	p      *pdfParser
+	digest string         // hash of the PDF document
+	pages  []pdfPage      // pages of the PDF document
+	refs   map[int]string // hashes of objects that are copied with changes
+}
+
+// pdfPage is a page of a parsed PDF document
+type pdfPage struct {
+	dict pdfObjDict // page dictionary with the attributes it inherits
+	num  int        // object number of the page dictionary
+}
+
+// ImportPage imports the page with the one-based number pageNo of the PDF
+// document read from r, so that it can be drawn like a template on the pages
+// of the document with UseImportedPage(), for example as a letterhead or as a
+// form to fill in. box is the page boundary that is imported: "MediaBox",
+// "CropBox", "BleedBox", "TrimBox" or "ArtBox". If empty, the crop box is
+// imported, which is the area that readers display. The rotation of the page
+// is applied.
+//
+// The content of the page is imported together with the fonts, images and
+// other resources it uses; annotations such as links and form fields are not.
+// Resources that several imported pages of the same document share are
+// included only once. Encrypted documents cannot be imported, and documents
+// with imported pages cannot be encrypted with SetProtection().
+//
+// The returned id identifies the page in calls to UseImportedPage() and
+// GetImportedPageSize(). Importing the same page again returns the same id.
+// If an error occurs, 0 is returned and the error state of the document is
+// set.
+//
+// The ImportPage example demonstrates this method.
+func (f *Fpdf) ImportPage(r io.ReadSeeker, pageNo int, box string) (id int) {
+	if f.err != nil {
+		return
+	}
+	box = strings.TrimPrefix(box, "/")
+	if box == "" {
+		box = "CropBox"
+	}
+	switch box {
+	case "MediaBox", "CropBox", "BleedBox", "TrimBox", "ArtBox":
+	default:
+		f.err = fmt.Errorf("invalid page boundary %q", box)
+		return
+	}
+	var data []byte
+	if data, f.err = readAll(r); f.err != nil {
+		return
+	}
+	im := &pdfImporter{f: f, digest: fmt.Sprintf("%x", sha1.Sum(data))}
+	if id, ok := f.importedPageIDs[im.formHash(pageNo, box)]; ok {
+		return id
+	}
+	if f.err = im.parse(data); f.err != nil {
+		return
+	}
+	return f.importPage(im, pageNo, box)
+}
+
+// readAll returns the data of r from its start
+func readAll(r io.ReadSeeker) ([]byte, error) {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(r)
+}
+
+// parse parses the PDF document data and locates its pages
+func (im *pdfImporter) parse(data []byte) (err error) {
+	if im.p, err = newPdfParser(data); err != nil {
+		return
+	}
+	if im.pages, err = im.p.pageList(); err != nil {
+		return
+	}
+	if version := pdfVersion(data); version > im.f.pdfVersion {
+		im.f.pdfVersion = version
+	}
+	return
+}
+
+// formHash returns the hash of the form XObject of page pageNo and
+// boundary box
+func (im *pdfImporter) formHash(pageNo int, box string) string {
+	return im.hash(sprintf("page %d %s", pageNo, box))
+}
+
+// importPage imports the page pageNo of the PDF document parsed by im and
+// returns its id
+func (f *Fpdf) importPage(im *pdfImporter, pageNo int, box string) (id int) {
+	formHash := im.formHash(pageNo, box)
+	if id, ok := f.importedPageIDs[formHash]; ok {
+		return id
+	}
+	if pageNo < 1 || pageNo > len(im.pages) {
+		f.err = fmt.Errorf("page %d not found in PDF document with %d pages", pageNo, len(im.pages))
+		return
+	}
+	var form []byte
+	var pos map[int]string
+	var w, h float64
+	if form, pos, w, h, f.err = im.form(im.pages[pageNo-1].dict, box); f.err != nil {
+		return
+	}
+	f.importedObjs[formHash] = form
+	f.importedObjPos[formHash] = pos
+	id = len(f.importedPages) + 1
+	name := sprintf("/IPG%d", id)
+	f.importedTplObjs[name] = formHash
+	f.importedPages = append(f.importedPages, importedPageType{name: name, w: w, h: h})
+	if f.importedPageIDs == nil {
+		f.importedPageIDs = make(map[string]int)
+	}
+	f.importedPageIDs[formHash] = id
+	return
+}
+
+// UseImportedPage draws the page id, imported with ImportPage(), with its
+// upper left corner at (x, y) and a width of w and a height of h, in the unit
+// of measure specified in New(). If w and h are both 0, the page is drawn at
+// its original size; if one of them is 0, it is calculated so that the page
+// keeps its aspect ratio.
+//
+// The ImportPage example demonstrates this method.
+func (f *Fpdf) UseImportedPage(id int, x, y, w, h float64) {
+	if f.err != nil {
+		return
+	}
+	if id < 1 || id > len(f.importedPages) {
+		f.err = fmt.Errorf("invalid imported page %d", id)
+		return
+	}
+	if f.page == 0 {
+		f.err = fmt.Errorf("a page must be added before an imported page is used")
+		return
+	}
+	pg := f.importedPages[id-1]
+	switch {
+	case w == 0 && h == 0:
+		w, h = pg.w/f.k, pg.h/f.k
+	case w == 0:
+		w = h * pg.w / pg.h
+	case h == 0:
+		h = w * pg.h / pg.w
+	}
+	f.out(f.structMark(sprintf("q %.5F 0 0 %.5F %.2F %.2F cm %s Do Q",
+		w*f.k/pg.w, h*f.k/pg.h, x*f.k, (f.h-(y+h))*f.k, pg.name)))
+}
+
+// GetImportedPageSize returns the width and height of the page id, imported
+// with ImportPage(), in the unit of measure specified in New(). The size
+// reflects the page boundary that was imported and the rotation of the page.
+func (f *Fpdf) GetImportedPageSize(id int) (w, h float64) {
+	if id < 1 || id > len(f.importedPages) {
+		return
+	}
+	pg := f.importedPages[id-1]
+	return pg.w / f.k, pg.h / f.k
+}
+
+// pdfVersion returns the version of the PDF document data as stated in its
+// header
+func pdfVersion(data []byte) string {
+	j := bytes.Index(data, []byte("%PDF-"))
+	if j < 0 || j+8 > len(data) {
+		return ""
+	}
+	return string(data[j+5 : j+8])
+}
+
+// pageList returns the pages of the document, with the attributes they
+// inherit from the page tree
+func (p *pdfParser) pageList() ([]pdfPage, error) {
+	catalog, _ := p.resolve(p.trailer["Root"]).(pdfObjDict)
+	root, _ := p.resolve(catalog["Pages"]).(pdfObjDict)
+	if root == nil {
+		return nil, fmt.Errorf("page tree not found")
+	}
+	var pages []pdfPage
+	visited := make(map[int]bool)
+	var walk func(node pdfObjDict, num int, inherited pdfObjDict)
+	walk = func(node pdfObjDict, num int, inherited pdfObjDict) {
+		attrs := make(pdfObjDict)
+		for key, value := range inherited {
+			attrs[key] = value
+		}
+		for _, key := range []string{"Resources", "MediaBox", "CropBox", "Rotate"} {
+			if value, ok := node[key]; ok {
+				attrs[key] = value
+			}
+		}
+		kids, isTree := p.resolve(node["Kids"]).(pdfObjArray)
+		if !isTree || node["Type"] == pdfObjName("Page") {
+			page := make(pdfObjDict)
+			for key, value := range node {
+				page[key] = value
+			}
+			for key, value := range attrs {
+				page[key] = value
+			}
+			pages = append(pages, pdfPage{dict: page, num: num})
+			return
+		}
+		for _, kid := range kids {
+			ref, ok := kid.(pdfObjRef)
+			if !ok || visited[ref.num] {
+				// Cycles in damaged page trees are broken
+				continue
+			}
+			visited[ref.num] = true
+			if dict, ok := p.object(ref.num).(pdfObjDict); ok {
+				walk(dict, ref.num, attrs)
+			}
+		}
+	}
+	walk(root, 0, nil)
+	return pages, nil
+}
+
+// rect returns the rectangle obj with the lower left corner first
+func (p *pdfParser) rect(obj interface{}) (r [4]float64, ok bool) {
+	arr, _ := p.resolve(obj).(pdfObjArray)
+	if len(arr) != 4 {
+		return
+	}
+	for j := range r {
+		if r[j], ok = p.number(arr[j]); !ok {
+			return
+		}
+	}
+	if r[0] > r[2] {
+		r[0], r[2] = r[2], r[0]
+	}
+	if r[1] > r[3] {
+		r[1], r[3] = r[3], r[1]
+	}
+	return r, r[2] > r[0] && r[3] > r[1]
+}
+
+// number returns obj as a number
+func (p *pdfParser) number(obj interface{}) (float64, bool) {
+	switch v := p.resolve(obj).(type) {
+	case int:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// pageBox returns the page boundary box of page, falling back to the
+// boundaries it defaults to
+func (p *pdfParser) pageBox(page pdfObjDict, box string) (r [4]float64) {
+	for _, key := range map[string][]string{
+		"MediaBox": {"MediaBox"},
+		"CropBox":  {"CropBox", "MediaBox"},
+		"BleedBox": {"BleedBox", "CropBox", "MediaBox"},
+		"TrimBox":  {"TrimBox", "CropBox", "MediaBox"},
+		"ArtBox":   {"ArtBox", "CropBox", "MediaBox"},
+	}[box] {
+		var ok bool
+		if r, ok = p.rect(page[key]); ok {
+			return
+		}
+	}
+	// Readers display pages without a media box in the US Letter format
+	return [4]float64{0, 0, 612, 792}
+}
+
+// content returns the decoded content of page
+func (p *pdfParser) content(page pdfObjDict) ([]byte, error) {
+	var streams pdfObjArray
+	switch v := p.resolve(page["Contents"]).(type) {
+	case *pdfObjStream:
+		streams = pdfObjArray{v}
+	case pdfObjArray:
+		streams = v
+	}
+	var buf bytes.Buffer
+	for _, obj := range streams {
+		stm, ok := p.resolve(obj).(*pdfObjStream)
+		if !ok {
+			continue
+		}
+		data, err := p.decodeStream(stm)
+		if err != nil {
+			return nil, err
+		}
+		// Streams may split the content between any two tokens
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// pageMatrix returns the boundary box of page, the matrix that transforms
+// the box to the origin with the rotation of the page applied, and the
+// size of the box after the transformation
+func (p *pdfParser) pageMatrix(page pdfObjDict, box string) (r [4]float64, matrix [6]float64, w, h float64) {
+	r = p.pageBox(page, box)
+	rotate, _ := p.resolve(page["Rotate"]).(int)
+	rotate = (rotate%360 + 360) % 360
+	llx, lly, urx, ury := r[0], r[1], r[2], r[3]
+	w, h = urx-llx, ury-lly
+	switch rotate {
+	case 90:
+		matrix = [6]float64{0, -1, 1, 0, -lly, urx}
+		w, h = h, w
+	case 180:
+		matrix = [6]float64{-1, 0, 0, -1, urx, ury}
+	case 270:
+		matrix = [6]float64{0, 1, -1, 0, ury, -llx}
+		w, h = h, w
+	default:
+		matrix = [6]float64{1, 0, 0, 1, -llx, -lly}
+	}
+	return
+}
+
+// form returns the form XObject of page, which holds the area of its
+// boundary box with the rotation of the page applied, the positions of the
+// hashes of the objects it refers to, and the size of the area in points
+func (im *pdfImporter) form(page pdfObjDict, box string) (form []byte, pos map[int]string, w, h float64, err error) {
+	content, err := im.p.content(page)
+	if err != nil {
+		return
+	}
+	r, matrix, w, h := im.p.pageMatrix(page, box)
+	var buf bytes.Buffer
+	pos = make(map[int]string)
+	buf.WriteString("<</Type /XObject /Subtype /Form /FormType 1 /BBox [")
+	for j, v := range r {
+		if j > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(pdfNumber(v))
+	}
+	buf.WriteString("] /Matrix [")
+	for j, v := range matrix {
+		if j > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(pdfNumber(v))
+	}
+	buf.WriteString("] /Resources ")
+	if page["Resources"] == nil {
+		buf.WriteString("<<>>")
+	} else {
+		im.write(&buf, page["Resources"], pos)
+	}
+	if group := page["Group"]; group != nil {
+		// The transparency group of the page
+		buf.WriteString(" /Group ")
+		im.write(&buf, group, pos)
+	}
+	if im.f.compress {
+		content = im.f.compressStream(content)
+		buf.WriteString(" /Filter /" + im.f.filterName())
+	}
+	buf.WriteString(sprintf(" /Length %d>>\nstream\n", len(content)))
+	buf.Write(content)
+	buf.WriteString("\nendstream\nendobj")
+	form = buf.Bytes()
+	return
+}
+
+// hash returns the hash that identifies the object of the PDF document with
+// the given key, such as its object number, among the imported objects
+func (im *pdfImporter) hash(key string) string {
+	return fmt.Sprintf("%x", sha1.Sum([]byte(im.digest+" "+key)))
+}
+
+// ref copies the indirect object num and the objects it refers to, unless
+// they have been copied already, and returns its hash
+func (im *pdfImporter) ref(num int) string {
+	if h, ok := im.refs[num]; ok {
+		return h
+	}
+	h := im.hash(strconv.Itoa(num))
+	if _, ok := im.f.importedObjs[h]; ok {
+		return h
+	}
+	// The placeholder ends cycles of references
+	im.f.importedObjs[h] = nil
+	var buf bytes.Buffer
+	pos := make(map[int]string)
+	switch obj := im.p.object(num).(type) {
+	case *pdfObjStream:
+		dict := make(pdfObjDict)
+		for key, value := range obj.dict {
+			dict[key] = value
+		}
+		dict["Length"] = len(obj.data)
+		im.write(&buf, dict, pos)
+		buf.WriteString("\nstream\n")
+		buf.Write(obj.data)
+		buf.WriteString("\nendstream")
+	default:
+		im.write(&buf, obj, pos)
+	}
+	buf.WriteString("\nendobj")
+	im.f.importedObjs[h] = buf.Bytes()
+	im.f.importedObjPos[h] = pos
+	return h
+}
+
+// write writes the direct object obj to buf. The objects it refers to are
+// copied, and the positions of their hashes in buf are recorded in pos.
+func (im *pdfImporter) write(buf *bytes.Buffer, obj interface{}, pos map[int]string) {
+	writePdfObject(buf, obj, func(v pdfObjRef) {
+		target := im.p.object(v.num)
+		if dict, ok := target.(pdfObjDict); target == nil || ok &&
+			(dict["Type"] == pdfObjName("Page") || dict["Type"] == pdfObjName("Pages")) {
+			// Pages of the document are not imported
+			buf.WriteString("null")
+			return
+		}
+		h := im.ref(v.num)
+		pos[buf.Len()] = h
+		buf.WriteString(h + " 0 R")
+	})
+}
+
+// writePdfObject writes the direct object obj of a parsed PDF document to
+// buf, and the references it holds with ref
+func writePdfObject(buf *bytes.Buffer, obj interface{}, ref func(v pdfObjRef)) {
+	switch v := obj.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(v))
+	case int:
+		buf.WriteString(strconv.Itoa(v))
+	case float64:
+		buf.WriteString(pdfNumber(v))
+	case pdfObjName:
+		buf.WriteString(pdfName(string(v)))
+	case pdfObjString:
+		buf.WriteString(sprintf("<%x>", string(v)))
+	case pdfObjArray:
+		buf.WriteByte('[')
+		for j, elem := range v {
+			if j > 0 {
+				buf.WriteByte(' ')
+			}
+			writePdfObject(buf, elem, ref)
+		}
+		buf.WriteByte(']')
+	case pdfObjDict:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		buf.WriteString("<<")
+		for j, key := range keys {
+			if j > 0 {
+				buf.WriteByte(' ')
+			}
+			buf.WriteString(pdfName(key))
+			buf.WriteByte(' ')
+			writePdfObject(buf, v[key], ref)
+		}
+		buf.WriteString(">>")
+	case pdfObjRef:
+		ref(v)
+	default:
+		buf.WriteString("null")
+	}
+}
+
+// pdfNumber returns v in the shortest form of a PDF number
+func pdfNumber(v float64) string {
+	if v == 0 {
+		// Avoid negative zero
+		return "0"
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
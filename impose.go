@@ -0,0 +1,172 @@
+package gofpdf
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"math"
+)
+
+// ImpositionOptions sets how OutputImposed() places the pages of a document
+// on printed sheets. Sizes are in the unit of measure of the document.
+type ImpositionOptions struct {
+	// NUp is the number of pages on each side of a sheet, 2 if it is 0. The
+	// pages are arranged in a grid that is as square as possible, with more
+	// columns than rows, for example 2 by 1 for 2 pages and 4 by 2 for 8.
+	NUp int
+	// Booklet arranges the pages 2-up in the order of a saddle-stitched
+	// booklet: the sheets, printed on both sides, folded in the middle and
+	// stacked inside each other, give the pages in their order. Blank pages are
+	// added at the end so that the number of pages is a multiple of 4.
+	Booklet bool
+	// Creep is the distance by which the pages of the innermost sheet of a
+	// booklet are moved towards the spine, to make up for the sheets folded
+	// around it pushing it out; the pages of the other sheets are moved in
+	// proportion to their depth in the booklet.
+	Creep float64
+	// SheetSize is the size of the sheets. If it is zero, the sheets are just
+	// large enough to hold the pages at their size. Pages are scaled down to
+	// fit their place on the sheet, if needed, and centered in it; the pages
+	// of booklets are placed against the spine.
+	SheetSize SizeType
+	// Box is the page boundary of the pages that is placed on the sheets, as
+	// for ImportPage(); by default the crop box.
+	Box string
+}
+
+// OutputImposed closes the document and writes it to w imposed on printed
+// sheets as set by opts, several pages to a side, so that a document can be
+// sent to a printer or a print shop without a separate imposition tool. The
+// pages are placed on the sheets as form XObjects, so they keep their content
+// and resources but not their links, annotations and form fields. The
+// document information of the imposed document is that of the document.
+//
+// An error occurs if the document cannot be output with Output(), if it is
+// encrypted, if NUp is negative, or if Booklet is true and NUp is not 0 or 2.
+//
+// The OutputImposed example demonstrates this method.
+func (f *Fpdf) OutputImposed(w io.Writer, opts ImpositionOptions) error {
+	if f.err != nil {
+		return f.err
+	}
+	if opts.NUp == 0 {
+		opts.NUp = 2
+	}
+	switch {
+	case opts.NUp < 0:
+		f.err = fmt.Errorf("number of pages per sheet must be positive: %d", opts.NUp)
+	case opts.Booklet && opts.NUp != 2:
+		f.err = fmt.Errorf("booklets are imposed 2-up, not %d-up", opts.NUp)
+	case f.protect.encrypted:
+		f.err = fmt.Errorf("encrypted document cannot be imposed")
+	}
+	if f.err != nil {
+		return f.err
+	}
+	var buf bytes.Buffer
+	if err := f.Output(&buf); err != nil {
+		return err
+	}
+	if f.err = f.impose(w, buf.Bytes(), opts); f.err != nil {
+		return f.err
+	}
+	return nil
+}
+
+// impose writes the PDF document data, which is the output of f, to w
+// imposed as set by opts
+func (f *Fpdf) impose(w io.Writer, data []byte, opts ImpositionOptions) error {
+	sheet := NewCustom(&InitType{UnitStr: f.unitStr, Size: f.defPageSize, FontDirStr: f.fontDirStr})
+	sheet.SetCompression(f.compress)
+	sheet.producer, sheet.title, sheet.subject = f.producer, f.title, f.subject
+	sheet.author, sheet.keywords, sheet.creator = f.author, f.keywords, f.creator
+	sheet.creationDate, sheet.modDate = f.creationDate, f.modDate
+	im := &pdfImporter{f: sheet, digest: fmt.Sprintf("%x", sha1.Sum(data))}
+	if err := im.parse(data); err != nil {
+		return err
+	}
+	box := opts.Box
+	if box == "" {
+		box = "CropBox"
+	}
+	count := len(im.pages)
+	ids := make([]int, count)
+	for j := range ids {
+		ids[j] = sheet.importPage(im, j+1, box)
+	}
+	if sheet.err != nil {
+		return sheet.err
+	}
+	// The grid of places on a side of a sheet
+	rows := int(math.Sqrt(float64(opts.NUp)))
+	for opts.NUp%rows != 0 {
+		rows--
+	}
+	cols := opts.NUp / rows
+	cellW, cellH := sheet.GetImportedPageSize(ids[0])
+	sheetSize := SizeType{Wd: cellW * float64(cols), Ht: cellH * float64(rows)}
+	if opts.SheetSize.Wd > 0 && opts.SheetSize.Ht > 0 {
+		sheetSize = opts.SheetSize
+		cellW, cellH = sheetSize.Wd/float64(cols), sheetSize.Ht/float64(rows)
+	}
+	// sides holds the pages of each side of a sheet, -1 for a blank place
+	var sides [][]int
+	if opts.Booklet {
+		n := (count + 3) / 4 * 4
+		for j := 0; j < n/2; j++ {
+			left, right := n-1-j, j
+			if j%2 == 1 {
+				left, right = j, n-1-j
+			}
+			sides = append(sides, []int{left, right})
+		}
+		for _, side := range sides {
+			for k, pg := range side {
+				if pg >= count {
+					side[k] = -1
+				}
+			}
+		}
+	} else {
+		for j := 0; j < count; j += opts.NUp {
+			side := make([]int, opts.NUp)
+			for k := range side {
+				side[k] = j + k
+				if j+k >= count {
+					side[k] = -1
+				}
+			}
+			sides = append(sides, side)
+		}
+	}
+	sheets := len(sides) / 2
+	for j, side := range sides {
+		sheet.AddPageFormat("P", sheetSize)
+		creep := 0.0
+		if opts.Booklet && sheets > 1 {
+			creep = opts.Creep * float64(j/2) / float64(sheets-1)
+		}
+		for k, pg := range side {
+			if pg < 0 {
+				continue
+			}
+			pw, ph := sheet.GetImportedPageSize(ids[pg])
+			if scale := math.Min(cellW/pw, cellH/ph); scale < 1 {
+				pw, ph = pw*scale, ph*scale
+			}
+			x := float64(k%cols)*cellW + (cellW-pw)/2
+			y := float64(k/cols)*cellH + (cellH-ph)/2
+			if opts.Booklet {
+				// The pages lie against the spine and are moved towards it
+				if k == 0 {
+					x = cellW - pw + creep
+				} else {
+					x = cellW - creep
+				}
+			}
+			sheet.UseImportedPage(ids[pg], x, y, pw, ph)
+		}
+	}
+	return sheet.Output(w)
+}
@@ -0,0 +1,55 @@
+package gofpdf
+
+import (
+	"encoding/ascii85"
+	"encoding/hex"
+)
+
+// inlineColorSpaces are the abbreviations of the device color spaces of
+// inline images
+var inlineColorSpaces = map[string]string{
+	"DeviceGray": "/G",
+	"DeviceRGB":  "/RGB",
+	"DeviceCMYK": "/CMYK",
+}
+
+// inlineFilters are the abbreviations of the filters of inline images
+var inlineFilters = map[string]string{
+	"FlateDecode":    "/Fl",
+	"DCTDecode":      "/DCT",
+	"CCITTFaxDecode": "/CCF",
+}
+
+// inlineImage returns the operators that paint the image info inline in the
+// content stream, or an empty string if the image has transparency, a mask,
+// an ICC profile or a JPEG 2000 filter, which inline images cannot have. The
+// data is encoded with ASCII85, so that the content stream remains text.
+func (f *Fpdf) inlineImage(info *ImageInfoType) string {
+	filter, ok := inlineFilters[info.f]
+	if !ok || info.hasSMask() || info.mask != nil || len(info.trns) > 0 || len(info.cmask) > 0 ||
+		len(info.icc) > 0 {
+		return ""
+	}
+	var cs string
+	if info.cs == "Indexed" {
+		cs = sprintf("[/I /RGB %d <%s>]", len(info.pal)/3-1, hex.EncodeToString(info.pal))
+	} else if cs, ok = inlineColorSpaces[info.cs]; !ok {
+		return ""
+	}
+	if f.err = info.loadImage(); f.err != nil {
+		return ""
+	}
+	defer info.unloadImage()
+	var s fmtBuffer
+	s.printf("BI /W %d /H %d /CS %s /BPC %d /F [/A85 %s]", int(info.w), int(info.h), cs, info.bpc, filter)
+	if info.dp != "" {
+		s.printf(" /DP [null <<%s>>]", info.dp)
+	}
+	if info.cs == "DeviceCMYK" {
+		s.printf(" /D [1 0 1 0 1 0 1 0]")
+	}
+	data := make([]byte, ascii85.MaxEncodedLen(len(info.data)))
+	data = data[:ascii85.Encode(data, info.data)]
+	s.printf(" ID %s~> EI", data)
+	return s.String()
+}
@@ -0,0 +1,184 @@
+package gofpdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// jp2Signature is the signature box that begins JP2 and JPX files
+var jp2Signature = []byte{0, 0, 0, 12, 'j', 'P', ' ', ' ', 0x0d, 0x0a, 0x87, 0x0a}
+
+// jp2Box is a box of a JP2 file
+type jp2Box struct {
+	tp   string
+	data []byte
+}
+
+// jp2Boxes returns the boxes that data consists of
+func jp2Boxes(data []byte) (boxes []jp2Box, err error) {
+	for len(data) > 0 {
+		if len(data) < 8 {
+			return nil, fmt.Errorf("JPEG 2000 box is truncated")
+		}
+		size, hdr := uint64(binary.BigEndian.Uint32(data)), uint64(8)
+		switch size {
+		case 0: // The box extends to the end of the data
+			size = uint64(len(data))
+		case 1: // The size follows the type
+			if len(data) < 16 {
+				return nil, fmt.Errorf("JPEG 2000 box is truncated")
+			}
+			size, hdr = binary.BigEndian.Uint64(data[8:]), 16
+		}
+		if size < hdr || size > uint64(len(data)) {
+			return nil, fmt.Errorf("JPEG 2000 box is truncated")
+		}
+		boxes = append(boxes, jp2Box{tp: string(data[4:8]), data: data[hdr:size]})
+		data = data[size:]
+	}
+	return
+}
+
+// parsejpx extracts info from a JP2 or JPX file, or from a JPEG 2000
+// codestream, which is embedded without being decoded. The size and the
+// number of components are read from the codestream header. The color space
+// is the device color space of the components unless the file specifies
+// another color space, such as an ICC profile, a palette or sYCC, in which
+// case it is left to the data.
+func (f *Fpdf) parsejpx(r io.Reader, readdpi bool) (info *ImageInfoType) {
+	buf, err := bufferFromReader(r)
+	if err != nil {
+		f.err = err
+		return
+	}
+	data := buf.Bytes()
+	codestream := data
+	info = f.newImageInfo()
+	// The color space that the file specifies, if known
+	cs, fileCS := "", false
+	if bytes.HasPrefix(data, jp2Signature) {
+		boxes, err := jp2Boxes(data)
+		if err != nil {
+			f.err = err
+			return
+		}
+		codestream = nil
+		for _, box := range boxes {
+			switch box.tp {
+			case "jp2h":
+				if cs, fileCS, err = parsejp2Header(box.data, info, readdpi); err != nil {
+					f.err = err
+					return
+				}
+			case "jp2c":
+				if codestream == nil {
+					codestream = box.data
+				}
+			}
+		}
+		if codestream == nil {
+			f.err = fmt.Errorf("JPEG 2000 image has no codestream")
+			return
+		}
+	}
+	// Image and tile size (SIZ) marker segment
+	if len(codestream) < 42 || codestream[0] != 0xff || codestream[1] != 0x4f ||
+		codestream[2] != 0xff || codestream[3] != 0x51 {
+		f.err = fmt.Errorf("image is not a JPEG 2000 image")
+		return
+	}
+	be := binary.BigEndian
+	w := be.Uint32(codestream[8:]) - be.Uint32(codestream[16:])
+	h := be.Uint32(codestream[12:]) - be.Uint32(codestream[20:])
+	nc := int(be.Uint16(codestream[40:]))
+	if w == 0 || h == 0 || w > 1<<30 || h > 1<<30 || nc == 0 || len(codestream) < 42+nc*3 {
+		f.err = fmt.Errorf("JPEG 2000 image header is invalid")
+		return
+	}
+	info.w, info.h = float64(w), float64(h)
+	info.bpc = int(codestream[42]&0x7f) + 1
+	if !fileCS {
+		switch nc {
+		case 1:
+			cs = "DeviceGray"
+		case 3:
+			cs = "DeviceRGB"
+		case 4:
+			cs = "DeviceCMYK"
+		default:
+			f.err = fmt.Errorf("JPEG 2000 codestream with %d components is not supported", nc)
+			return
+		}
+	} else if cs != "" && nc != iccComponents[cs] {
+		// The components that exceed the color space, such as alpha
+		// channels, are described by the file
+		cs = ""
+	}
+	info.cs, info.f = cs, "JPXDecode"
+	info.data = data
+	if f.pdfVersion < "1.5" {
+		f.pdfVersion = "1.5"
+	}
+	return
+}
+
+// parsejp2Header reads the header box of a JP2 file into info and returns the
+// device color space that the file specifies, or an empty string if it
+// specifies another color space, and whether it specifies a color space
+func parsejp2Header(data []byte, info *ImageInfoType, readdpi bool) (cs string, ok bool, err error) {
+	boxes, err := jp2Boxes(data)
+	if err != nil {
+		return
+	}
+	palette := false
+	for _, box := range boxes {
+		switch box.tp {
+		case "pclr":
+			palette = true
+		case "colr":
+			if ok || len(box.data) < 3 {
+				continue
+			}
+			ok = true
+			if box.data[0] == 1 && len(box.data) >= 7 {
+				// Enumerated color space
+				switch binary.BigEndian.Uint32(box.data[3:]) {
+				case 16: // sRGB
+					cs = "DeviceRGB"
+				case 17: // greyscale
+					cs = "DeviceGray"
+				case 12: // CMYK
+					cs = "DeviceCMYK"
+				}
+			}
+		case "res ":
+			if !readdpi {
+				continue
+			}
+			res, err := jp2Boxes(box.data)
+			if err != nil {
+				return "", false, err
+			}
+			// Capture resolution takes precedence over display resolution
+			for _, tp := range []string{"resd", "resc"} {
+				for _, r := range res {
+					if r.tp == tp && len(r.data) >= 10 {
+						num, den := binary.BigEndian.Uint16(r.data[4:]), binary.BigEndian.Uint16(r.data[6:])
+						if num > 0 && den > 0 {
+							// Grid points per meter
+							info.dpi = float64(num) / float64(den) *
+								math.Pow10(int(int8(r.data[9]))) * 0.0254
+						}
+					}
+				}
+			}
+		}
+	}
+	if palette {
+		cs, ok = "", true
+	}
+	return
+}
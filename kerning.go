@@ -0,0 +1,224 @@
+package gofpdf
+
+import (
+	"sort"
+)
+
+// Kerning of UTF-8 fonts. The pair adjustments of the "kern" feature of the
+// OpenType GPOS table (lookup type 2, possibly wrapped in extension lookups
+// of type 9) are used; fonts without them are kerned with the pairs of the
+// format 0 subtables of the older kern table. Lookup flags are ignored.
+
+// SetKerning enables or disables kerning of text printed in UTF-8 fonts,
+// which is disabled by default. Kerning adjusts the space between pairs of
+// characters, such as "AV" and "To", as specified by the font. When it is
+// enabled, Cell(), MultiCell(), Write() and Text() print text with the
+// adjustments, and GetStringWidth() and the line breaking of text take them
+// into account. Fonts without kerning data, and core and Type1 fonts, are
+// not affected.
+//
+// The SetKerning example demonstrates this method.
+func (f *Fpdf) SetKerning(on bool) {
+	f.kerning = on
+}
+
+// GetKerning returns true if kerning of text printed in UTF-8 fonts is
+// enabled.
+func (f *Fpdf) GetKerning() bool {
+	return f.kerning
+}
+
+// parseKerning reads the positions of the GPOS pair adjustment subtables of
+// the "kern" feature, or the kerning pairs of the kern table if there are
+// none
+func (utf *utf8FontFile) parseKerning() {
+	utf.kernLookups = nil
+	utf.kernPairs = make(map[int]int)
+	utf.kernCache = make(map[int]int)
+	if desc, ok := utf.tableDescriptions["GPOS"]; ok {
+		gpos := desc.position
+		featureList := gpos + utf.getUint16(gpos+6)
+		lookupList := gpos + utf.getUint16(gpos+8)
+		seen := make(map[int]bool)
+		var lookups []int
+		count := utf.getUint16(featureList)
+		for j := 0; j < count; j++ {
+			rec := featureList + 2 + 6*j
+			if utf.tagAt(rec) != "kern" {
+				continue
+			}
+			feature := featureList + utf.getUint16(rec+4)
+			lookupCount := utf.getUint16(feature + 2)
+			for k := 0; k < lookupCount; k++ {
+				lookup := utf.getUint16(feature + 4 + 2*k)
+				if !seen[lookup] {
+					seen[lookup] = true
+					lookups = append(lookups, lookup)
+				}
+			}
+		}
+		sort.Ints(lookups)
+		for _, lookupIndex := range lookups {
+			lookup := lookupList + utf.getUint16(lookupList+2+2*lookupIndex)
+			lookupType := utf.getUint16(lookup)
+			var subtables []int
+			subCount := utf.getUint16(lookup + 4)
+			for j := 0; j < subCount; j++ {
+				sub := lookup + utf.getUint16(lookup+6+2*j)
+				tp := lookupType
+				if tp == 9 {
+					tp = utf.getUint16(sub + 2)
+					sub += utf.uint32At(sub + 4)
+				}
+				if tp == 2 {
+					subtables = append(subtables, sub)
+				}
+			}
+			if len(subtables) > 0 {
+				utf.kernLookups = append(utf.kernLookups, subtables)
+			}
+		}
+	}
+	desc, ok := utf.tableDescriptions["kern"]
+	if len(utf.kernLookups) > 0 || !ok || utf.getUint16(desc.position) != 0 {
+		return
+	}
+	pos := desc.position + 4
+	for n := utf.getUint16(desc.position + 2); n > 0; n-- {
+		length, coverage := utf.getUint16(pos+2), utf.getUint16(pos+4)
+		// Horizontal kerning values of format 0
+		if coverage&0xff07 == 1 {
+			pairCount := utf.getUint16(pos + 6)
+			for j := 0; j < pairCount; j++ {
+				rec := pos + 14 + 6*j
+				utf.kernPairs[utf.getUint16(rec)<<16|utf.getUint16(rec+2)] += utf.int16At(rec + 4)
+			}
+		}
+		if length == 0 {
+			break
+		}
+		pos += length
+	}
+}
+
+// kerning returns the kerning, in thousandths of the font size, between the
+// glyphs left and right
+func (utf *utf8FontFile) kerning(left, right int) int {
+	key := left<<16 | right
+	if v, ok := utf.kernCache[key]; ok {
+		return v
+	}
+	units := utf.kernPairs[key]
+	for _, subtables := range utf.kernLookups {
+		// The first subtable of a lookup that applies to the pair is used
+		for _, sub := range subtables {
+			if v, ok := utf.pairAdjustment(sub, left, right); ok {
+				units += v
+				break
+			}
+		}
+	}
+	v := round(float64(units) * 1000 / float64(utf.fontElementSize))
+	utf.kernCache[key] = v
+	return v
+}
+
+// valueRecordSize returns the size of a GPOS value record of format
+// valueFormat
+func valueRecordSize(valueFormat int) (size int) {
+	for ; valueFormat != 0; valueFormat >>= 1 {
+		size += 2 * (valueFormat & 1)
+	}
+	return
+}
+
+// pairAdjustment returns the advance adjustment, in font units, of the first
+// glyph of the pair left and right by the pair adjustment subtable at
+// position sub, and true if the subtable applies to the pair
+func (utf *utf8FontFile) pairAdjustment(sub, left, right int) (int, bool) {
+	ix := utf.coverageIndex(sub+utf.getUint16(sub+2), left)
+	if ix < 0 {
+		return 0, false
+	}
+	format1, format2 := utf.getUint16(sub+4), utf.getUint16(sub+6)
+	size1, size2 := valueRecordSize(format1), valueRecordSize(format2)
+	xAdvance := func(rec int) int {
+		// XAdvance follows XPlacement and YPlacement if they are present
+		if format1&4 == 0 {
+			return 0
+		}
+		return utf.int16At(rec + valueRecordSize(format1&3))
+	}
+	switch utf.getUint16(sub) {
+	case 1:
+		if ix >= utf.getUint16(sub+8) {
+			return 0, false
+		}
+		set := sub + utf.getUint16(sub+10+2*ix)
+		count := utf.getUint16(set)
+		size := 2 + size1 + size2
+		j := sort.Search(count, func(j int) bool { return utf.getUint16(set+2+size*j) >= right })
+		if j < count && utf.getUint16(set+2+size*j) == right {
+			return xAdvance(set + 4 + size*j), true
+		}
+	case 2:
+		class1 := utf.glyphClass(sub+utf.getUint16(sub+8), left)
+		class2 := utf.glyphClass(sub+utf.getUint16(sub+10), right)
+		count1, count2 := utf.getUint16(sub+12), utf.getUint16(sub+14)
+		if class1 < count1 && class2 < count2 {
+			return xAdvance(sub + 16 + (class1*count2+class2)*(size1+size2)), true
+		}
+	}
+	return 0, false
+}
+
+// glyphClass returns the class of gid in the class definition table at
+// position pos
+func (utf *utf8FontFile) glyphClass(pos, gid int) int {
+	switch utf.getUint16(pos) {
+	case 1:
+		start, count := utf.getUint16(pos+2), utf.getUint16(pos+4)
+		if gid >= start && gid < start+count {
+			return utf.getUint16(pos + 6 + 2*(gid-start))
+		}
+	case 2:
+		count := utf.getUint16(pos + 2)
+		j := sort.Search(count, func(j int) bool { return utf.getUint16(pos+4+6*j+2) >= gid })
+		if j < count {
+			rec := pos + 4 + 6*j
+			if gid >= utf.getUint16(rec) {
+				return utf.getUint16(rec + 4)
+			}
+		}
+	}
+	return 0
+}
+
+// fontKerning returns the kerning, in thousandths of the font size, between
+// the characters left and right printed in font, or 0 if kerning is disabled
+func (f *Fpdf) fontKerning(font *fontDefType, left, right rune) int {
+	if !f.kerning || font.utf8File == nil {
+		return 0
+	}
+	utf := font.utf8File
+	utf.mu.Lock()
+	defer utf.mu.Unlock()
+	return utf.kerning(utf.runeGlyph(left), utf.runeGlyph(right))
+}
+
+// kern returns the kerning, in thousandths of the font size, between the
+// characters left and right printed in the current font or, if neither is
+// in it, in the same fallback font
+func (f *Fpdf) kern(left, right rune) int {
+	if !f.kerning || !f.isCurrentUTF8 {
+		return 0
+	}
+	font, other := f.fallbackFont(left), f.fallbackFont(right)
+	switch {
+	case font == nil && other == nil:
+		font = &f.currentFont
+	case font == nil || other == nil || font.i != other.i:
+		return 0
+	}
+	return f.fontKerning(font, left, right)
+}
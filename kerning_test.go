@@ -0,0 +1,37 @@
+package gofpdf
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+// TestKernTable checks that fonts without GPOS kerning are kerned with the
+// pairs of their kern table
+func TestKernTable(t *testing.T) {
+	ttf, err := ioutil.ReadFile("font/DejaVuSansCondensed.ttf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	utf := newUTF8Font(&fileReader{array: ttf})
+	if err = utf.parseFile(); err != nil {
+		t.Fatal(err)
+	}
+	pairs := []string{"AV", "To", "ab"}
+	gpos := make([]int, len(pairs))
+	for j, pair := range pairs {
+		gpos[j] = utf.kerning(utf.runeGlyph(rune(pair[0])), utf.runeGlyph(rune(pair[1])))
+	}
+	if len(utf.kernLookups) == 0 || gpos[0] >= 0 || gpos[1] >= 0 || gpos[2] != 0 {
+		t.Fatalf("unexpected GPOS kerning %v", gpos)
+	}
+	delete(utf.tableDescriptions, "GPOS")
+	utf.parseKerning()
+	if len(utf.kernPairs) == 0 {
+		t.Fatal("kern table not read")
+	}
+	for j, pair := range pairs {
+		if k := utf.kerning(utf.runeGlyph(rune(pair[0])), utf.runeGlyph(rune(pair[1]))); k != gpos[j] {
+			t.Fatalf("kerning of %s is %d in kern table and %d in GPOS", pair, k, gpos[j])
+		}
+	}
+}
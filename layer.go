@@ -16,24 +16,31 @@
 
 package gofpdf
 
+import (
+	"fmt"
+	"strings"
+)
+
 // Routines in this file are translated from
 // http://www.fpdf.org/en/script/script97.php
 
 type layerType struct {
 	name    string
 	visible bool
+	parent  int // ID of the parent layer, or -1
 	objNum  int // object number
 }
 
 type layerRecType struct {
 	list          []layerType
-	currentLayer  int
+	active        []int   // IDs of the layers that content is added to, innermost last
+	radioGroups   [][]int // IDs of the layers of radio button groups
 	openLayerPane bool
 }
 
 func (f *Fpdf) layerInit() {
 	f.layer.list = make([]layerType, 0)
-	f.layer.currentLayer = -1
+	f.layer.active = nil
 	f.layer.openLayerPane = false
 }
 
@@ -44,28 +51,83 @@ func (f *Fpdf) layerInit() {
 // to BeginLayer().
 func (f *Fpdf) AddLayer(name string, visible bool) (layerID int) {
 	layerID = len(f.layer.list)
-	f.layer.list = append(f.layer.list, layerType{name: name, visible: visible})
+	f.layer.list = append(f.layer.list, layerType{name: name, visible: visible, parent: -1})
+	return
+}
+
+// AddChildLayer defines a layer like AddLayer() does, which the document
+// reader displays below the layer parentID in the layer list. Content that
+// is added to the child layer while the parent layer is active, as described
+// for BeginLayer(), is only shown if both layers are visible.
+//
+// The AddChildLayer example demonstrates this method.
+func (f *Fpdf) AddChildLayer(parentID int, name string, visible bool) (layerID int) {
+	if f.err != nil {
+		return -1
+	}
+	if parentID < 0 || parentID >= len(f.layer.list) {
+		f.err = fmt.Errorf("invalid parent layer %d", parentID)
+		return -1
+	}
+	layerID = len(f.layer.list)
+	f.layer.list = append(f.layer.list, layerType{name: name, visible: visible, parent: parentID})
 	return
 }
 
+// AddLayerRadioGroup groups the layers specified by layerIDs so that the
+// document reader shows at most one of them at a time, like radio buttons.
+// When one of the layers is turned on, the others are turned off. This can be
+// used, for example, for the languages of a multilingual document. Usually
+// only one of the layers is initially visible.
+//
+// The AddChildLayer example demonstrates this method.
+func (f *Fpdf) AddLayerRadioGroup(layerIDs ...int) {
+	if f.err != nil {
+		return
+	}
+	for _, id := range layerIDs {
+		if id < 0 || id >= len(f.layer.list) {
+			f.err = fmt.Errorf("invalid layer %d in radio button group", id)
+			return
+		}
+	}
+	f.layer.radioGroups = append(f.layer.radioGroups, append([]int(nil), layerIDs...))
+}
+
 // BeginLayer is called to begin adding content to the specified layer. All
 // content added to the page between a call to BeginLayer and a call to
 // EndLayer is added to the layer specified by id. See AddLayer for more
 // details.
+//
+// If the layer is a child layer, defined with AddChildLayer(), of the active
+// layer, its content is nested within the content of the active layer, and
+// EndLayer() returns to the active layer. Otherwise, the active layers are
+// ended first.
 func (f *Fpdf) BeginLayer(id int) {
-	f.EndLayer()
-	if id >= 0 && id < len(f.layer.list) {
-		f.outf("/OC /OC%d BDC", id)
-		f.layer.currentLayer = id
+	if id < 0 || id >= len(f.layer.list) {
+		f.layerEndAll()
+		return
 	}
+	if n := len(f.layer.active); n == 0 || f.layer.list[id].parent != f.layer.active[n-1] {
+		f.layerEndAll()
+	}
+	f.outf("/OC /OC%d BDC", id)
+	f.layer.active = append(f.layer.active, id)
 }
 
 // EndLayer is called to stop adding content to the currently active layer. See
 // BeginLayer for more details.
 func (f *Fpdf) EndLayer() {
-	if f.layer.currentLayer >= 0 {
+	if n := len(f.layer.active); n > 0 {
 		f.out("EMC")
-		f.layer.currentLayer = -1
+		f.layer.active = f.layer.active[:n-1]
+	}
+}
+
+// layerEndAll ends all active layers
+func (f *Fpdf) layerEndAll() {
+	for len(f.layer.active) > 0 {
+		f.EndLayer()
 	}
 }
 
@@ -113,9 +175,36 @@ func (f *Fpdf) layerPutCatalog() {
 				offStr += sprintf("%d 0 R ", layer.objNum)
 			}
 		}
-		f.outf("/OCProperties <</OCGs [%s] /D <</OFF [%s] /Order [%s]>>>>", onStr, offStr, onStr)
+		rbStr := ""
+		if len(f.layer.radioGroups) > 0 {
+			groups := make([]string, len(f.layer.radioGroups))
+			for j, group := range f.layer.radioGroups {
+				refs := make([]string, len(group))
+				for k, id := range group {
+					refs[k] = sprintf("%d 0 R", f.layer.list[id].objNum)
+				}
+				groups[j] = "[" + strings.Join(refs, " ") + "]"
+			}
+			rbStr = " /RBGroups [" + strings.Join(groups, " ") + "]"
+		}
+		f.outf("/OCProperties <</OCGs [%s] /D <</OFF [%s] /Order [%s]%s>>>>", onStr, offStr, f.layerOrder(-1), rbStr)
 		if f.layer.openLayerPane {
 			f.out("/PageMode /UseOC")
 		}
 	}
 }
+
+// layerOrder returns the references of the child layers of parent, each
+// followed by an array of its own child layers if it has any
+func (f *Fpdf) layerOrder(parent int) string {
+	s := ""
+	for j, layer := range f.layer.list {
+		if layer.parent == parent {
+			s += sprintf("%d 0 R ", layer.objNum)
+			if children := f.layerOrder(j); children != "" {
+				s += "[" + children + "] "
+			}
+		}
+	}
+	return s
+}
@@ -0,0 +1,473 @@
+package gofpdf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// OutputLinearized sends the PDF document to w, like Output(), as a
+// linearized document, also known as a document optimized for fast web view:
+// the first page and the objects it uses come first, followed by hint tables
+// that locate the objects of the other pages, so that web browsers and other
+// viewers display the first page of a large document served over HTTP before
+// the rest of it has been received.
+//
+// Linearized documents end with a cross-reference table, even if
+// cross-reference streams have been selected with SetPDFVersion() or
+// SetCompressionLevel(). Encrypted and signed documents, and documents written
+// with SetOutputWriter(), cannot be linearized.
+//
+// The OutputLinearized example demonstrates this method.
+func (f *Fpdf) OutputLinearized(w io.Writer) error {
+	if f.err != nil {
+		return f.err
+	}
+	switch {
+	case f.outStream != nil:
+		f.err = fmt.Errorf("document written to an output writer cannot be linearized")
+	case f.protect.encrypted:
+		f.err = fmt.Errorf("encrypted document cannot be linearized")
+	case f.signature.provider != nil:
+		f.err = fmt.Errorf("signed document cannot be linearized")
+	}
+	if f.err != nil {
+		return f.err
+	}
+	var buf bytes.Buffer
+	if err := f.Output(&buf); err != nil {
+		return err
+	}
+	var data []byte
+	if data, f.err = linearize(buf.Bytes()); f.err != nil {
+		return f.err
+	}
+	if _, err := w.Write(data); err != nil {
+		f.err = err
+	}
+	return f.err
+}
+
+// linearize returns the PDF document data rewritten as a linearized document.
+// Its objects are renumbered and arranged in the parts of Annex F of the PDF
+// reference: the linearization dictionary and the first-page cross-reference
+// table, the catalog, the hint stream, the objects of the first page, the
+// objects of each of the other pages, the objects shared by these pages,
+// everything else, and the main cross-reference table.
+func linearize(data []byte) ([]byte, error) {
+	p, err := newPdfParser(data)
+	if err != nil {
+		return nil, err
+	}
+	pages, err := p.pageList()
+	if err != nil {
+		return nil, err
+	}
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("PDF document has no pages")
+	}
+	root, _ := p.trailer["Root"].(pdfObjRef)
+	info, _ := p.trailer["Info"].(pdfObjRef)
+	if _, ok := p.object(root.num).(pdfObjDict); !ok {
+		return nil, fmt.Errorf("PDF document has no catalog")
+	}
+	// The objects of the document, except object and cross-reference streams,
+	// whose objects and entries are written anew
+	var all []int
+	exists := make(map[int]bool)
+	for num := range p.xref {
+		obj := p.object(num)
+		if obj == nil {
+			continue
+		}
+		if stm, ok := obj.(*pdfObjStream); ok {
+			if tp := stm.dict["Type"]; tp == pdfObjName("ObjStm") || tp == pdfObjName("XRef") {
+				continue
+			}
+		}
+		all = append(all, num)
+		exists[num] = true
+	}
+	sort.Ints(all)
+	// The objects used by each page are those it refers to, directly or not,
+	// without passing through other pages, the page tree or the catalog
+	isPage := make(map[int]bool, len(pages))
+	for _, page := range pages {
+		isPage[page.num] = true
+	}
+	used := make([][]int, len(pages))
+	users := make(map[int]int)
+	for j, page := range pages {
+		seen := map[int]bool{page.num: true}
+		queue := []int{page.num}
+		for len(queue) > 0 {
+			num := queue[0]
+			queue = queue[1:]
+			used[j] = append(used[j], num)
+			if num != page.num {
+				users[num]++
+			}
+			pdfObjRefs(p.object(num), func(v pdfObjRef) {
+				if seen[v.num] || !exists[v.num] {
+					return
+				}
+				seen[v.num] = true
+				if dict, ok := p.object(v.num).(pdfObjDict); isPage[v.num] || v.num == root.num ||
+					v.num == info.num || ok && dict["Type"] == pdfObjName("Pages") {
+					return
+				}
+				queue = append(queue, v.num)
+			})
+		}
+	}
+	// groups[0] holds the objects of the first page, and groups[j] the page
+	// j+1 and the objects only it uses
+	placed := map[int]bool{root.num: true}
+	groups := make([][]int, len(pages))
+	for j := range pages {
+		for _, num := range used[j] {
+			if !placed[num] && (j == 0 || num == pages[j].num || users[num] == 1) {
+				groups[j] = append(groups[j], num)
+				placed[num] = true
+			}
+		}
+	}
+	var shared, rest []int
+	for j := 1; j < len(pages); j++ {
+		for _, num := range used[j] {
+			if !placed[num] {
+				shared = append(shared, num)
+				placed[num] = true
+			}
+		}
+	}
+	for _, num := range all {
+		if !placed[num] {
+			rest = append(rest, num)
+		}
+	}
+	// The objects after the first page are numbered first, so that the main
+	// cross-reference table starts at object 0
+	nums := make(map[int]int, len(all))
+	var order []int
+	number := func(list []int) {
+		for _, num := range list {
+			order = append(order, num)
+			nums[num] = len(nums) + 1
+		}
+	}
+	for _, group := range groups[1:] {
+		number(group)
+	}
+	number(shared)
+	number(rest)
+	mainSize := len(nums)
+	linNum, catalogNum, hintNum := mainSize+1, mainSize+2, mainSize+3
+	nums[root.num] = catalogNum
+	for k, num := range groups[0] {
+		nums[num] = hintNum + 1 + k
+	}
+	size := hintNum + len(groups[0]) + 1
+	// The objects renumbered
+	objs := make(map[int][]byte, len(all))
+	for _, num := range all {
+		if n, ok := nums[num]; ok {
+			objs[num] = linearObject(p, num, n, nums)
+		}
+	}
+	header := sprintf("%%PDF-%s\n%%\xe2\xe3\xcf\xd3\n", pdfVersion(data))
+	var id bytes.Buffer
+	if p.trailer["ID"] != nil {
+		id.WriteString(" /ID ")
+		writePdfObject(&id, p.trailer["ID"], func(v pdfObjRef) { id.WriteString("null") })
+	}
+	infoRef := ""
+	if n, ok := nums[info.num]; ok && info.num > 0 {
+		infoRef = sprintf(" /Info %d 0 R", n)
+	}
+	// The parts of the document before the objects of the first page have the
+	// same length whatever the values they hold, so that the offsets can be
+	// computed before they are known
+	type layoutType struct {
+		length, hintOff, hintLen, end, mainXref, firstXref int
+	}
+	var lay layoutType
+	linDict := func() string {
+		s := sprintf("%d 0 obj\n", linNum)
+		dict := sprintf("<</Linearized 1 /L %d /H [%d %d] /O %d /E %d /N %d /T %d>>",
+			lay.length, lay.hintOff, lay.hintLen, nums[pages[0].num], lay.end, len(pages),
+			lay.mainXref+len(sprintf("xref\n0 %d", mainSize+1)))
+		return s + padRight(dict, 200) + "\nendobj\n"
+	}
+	xrefEntry := func(offset int) string {
+		return sprintf("%010d 00000 n \n", offset)
+	}
+	offsets := make(map[int]int, size)
+	firstXref := func() string {
+		var b bytes.Buffer
+		b.WriteString(sprintf("xref\n%d %d\n", linNum, size-linNum))
+		for n := linNum; n < size; n++ {
+			b.WriteString(xrefEntry(offsets[n]))
+		}
+		trailer := sprintf("<</Size %d /Prev %d /Root %d 0 R%s%s>>", size, lay.mainXref, catalogNum, infoRef, id.String())
+		b.WriteString("trailer\n" + padRight(trailer, 300) + "\nstartxref\n0\n%EOF\n")
+		return b.String()
+	}
+	hints := func() []byte {
+		var sharedNum, sharedOff int
+		if len(shared) > 0 {
+			sharedNum = nums[shared[0]]
+			sharedOff = offsets[sharedNum]
+		}
+		hint, s := linearHints(groups, shared, used, objs, offsets[nums[pages[0].num]], sharedNum, sharedOff)
+		var b bytes.Buffer
+		b.WriteString(sprintf("%d 0 obj\n<</Length %d /S %d>>\nstream\n", hintNum, len(hint), s))
+		b.Write(hint)
+		b.WriteString("\nendstream\nendobj\n")
+		return b.Bytes()
+	}
+	// Objects are located in two passes: the second one with the offsets and
+	// lengths found by the first one
+	var out bytes.Buffer
+	for pass := 0; pass < 2; pass++ {
+		out.Reset()
+		out.WriteString(header)
+		offsets[linNum] = out.Len()
+		out.WriteString(linDict())
+		lay.firstXref = out.Len()
+		out.WriteString(firstXref())
+		offsets[catalogNum] = out.Len()
+		out.Write(objs[root.num])
+		lay.hintOff = out.Len()
+		offsets[hintNum] = out.Len()
+		hint := hints()
+		lay.hintLen = len(hint)
+		out.Write(hint)
+		for _, num := range groups[0] {
+			offsets[nums[num]] = out.Len()
+			out.Write(objs[num])
+		}
+		lay.end = out.Len()
+		for _, num := range order {
+			offsets[nums[num]] = out.Len()
+			out.Write(objs[num])
+		}
+		lay.mainXref = out.Len()
+		out.WriteString(sprintf("xref\n0 %d\n0000000000 65535 f \n", mainSize+1))
+		for n := 1; n <= mainSize; n++ {
+			out.WriteString(xrefEntry(offsets[n]))
+		}
+		out.WriteString(sprintf("trailer\n<</Size %d>>\nstartxref\n%d\n%%%%EOF\n", mainSize+1, lay.firstXref))
+		lay.length = out.Len()
+	}
+	return out.Bytes(), nil
+}
+
+// linearObject returns the object num of the parsed document p as the
+// indirect object n, with its references renumbered with nums
+func linearObject(p *pdfParser, num, n int, nums map[int]int) []byte {
+	var buf bytes.Buffer
+	ref := func(v pdfObjRef) {
+		if m, ok := nums[v.num]; ok {
+			buf.WriteString(sprintf("%d 0 R", m))
+		} else {
+			buf.WriteString("null")
+		}
+	}
+	buf.WriteString(sprintf("%d 0 obj\n", n))
+	switch v := p.object(num).(type) {
+	case *pdfObjStream:
+		dict := make(pdfObjDict, len(v.dict))
+		for key, value := range v.dict {
+			dict[key] = value
+		}
+		dict["Length"] = len(v.data)
+		writePdfObject(&buf, dict, ref)
+		buf.WriteString("\nstream\n")
+		buf.Write(v.data)
+		buf.WriteString("\nendstream")
+	default:
+		writePdfObject(&buf, v, ref)
+	}
+	buf.WriteString("\nendobj\n")
+	return buf.Bytes()
+}
+
+// linearHints returns the hint stream data of a linearized document, with
+// the page offset hint table followed by the shared object hint table, and
+// the offset of the latter. groups holds the objects of each page, shared
+// the objects shared by pages after the first, and used the objects used by
+// each page. firstPage is the offset of the page object of the first page,
+// and sharedNum and sharedOff the object number and offset of the first
+// shared object, if any.
+func linearHints(groups [][]int, shared []int, used [][]int, objs map[int][]byte, firstPage, sharedNum, sharedOff int) (data []byte, s int) {
+	var w bitWriter
+	length := func(list []int) (n int) {
+		for _, num := range list {
+			n += len(objs[num])
+		}
+		return
+	}
+	// The shared object identifiers are the indexes of the objects of the
+	// first page followed by those of the shared objects
+	ids := make(map[int]int, len(groups[0])+len(shared))
+	for _, num := range append(append([]int(nil), groups[0]...), shared...) {
+		ids[num] = len(ids)
+	}
+	pageIDs := make([][]int, len(groups))
+	counts := make([]int, len(groups))
+	lengths := make([]int, len(groups))
+	var maxID int
+	for j, group := range groups {
+		counts[j] = len(group)
+		lengths[j] = length(group)
+		if j == 0 {
+			continue
+		}
+		for _, num := range used[j] {
+			if id, ok := ids[num]; ok {
+				pageIDs[j] = append(pageIDs[j], id)
+				maxID = max(maxID, id)
+			}
+		}
+	}
+	minCount, maxCount := counts[0], counts[0]
+	minLength, maxLength := lengths[0], lengths[0]
+	var maxShared int
+	for j := range groups {
+		minCount, maxCount = min(minCount, counts[j]), max(maxCount, counts[j])
+		minLength, maxLength = min(minLength, lengths[j]), max(maxLength, lengths[j])
+		maxShared = max(maxShared, len(pageIDs[j]))
+	}
+	countBits, lengthBits := bitLength(maxCount-minCount), bitLength(maxLength-minLength)
+	sharedBits, idBits := bitLength(maxShared), bitLength(maxID)
+	// Page offset hint table: the content streams are described as spanning
+	// the whole page, as most writers do
+	w.write(minCount, 32)
+	w.write(firstPage, 32)
+	w.write(countBits, 16)
+	w.write(minLength, 32)
+	w.write(lengthBits, 16)
+	w.write(0, 32)
+	w.write(0, 16)
+	w.write(minLength, 32)
+	w.write(lengthBits, 16)
+	w.write(sharedBits, 16)
+	w.write(idBits, 16)
+	w.write(0, 16)
+	w.write(0, 16)
+	for j := range groups {
+		w.write(counts[j]-minCount, countBits)
+	}
+	w.align()
+	for j := range groups {
+		w.write(lengths[j]-minLength, lengthBits)
+	}
+	w.align()
+	for j := range groups {
+		w.write(len(pageIDs[j]), sharedBits)
+	}
+	w.align()
+	for j := range groups {
+		for _, id := range pageIDs[j] {
+			w.write(id, idBits)
+		}
+	}
+	w.align()
+	for j := range groups {
+		w.write(lengths[j]-minLength, lengthBits)
+	}
+	w.align()
+	// Shared object hint table, with a group for each object
+	s = len(w.buf)
+	var minGroup, maxGroup int
+	for k, num := range append(append([]int(nil), groups[0]...), shared...) {
+		n := len(objs[num])
+		if k == 0 || n < minGroup {
+			minGroup = n
+		}
+		maxGroup = max(maxGroup, n)
+	}
+	groupBits := bitLength(maxGroup - minGroup)
+	w.write(sharedNum, 32)
+	w.write(sharedOff, 32)
+	w.write(len(groups[0]), 32)
+	w.write(len(groups[0])+len(shared), 32)
+	w.write(0, 16)
+	w.write(minGroup, 32)
+	w.write(groupBits, 16)
+	for _, num := range append(append([]int(nil), groups[0]...), shared...) {
+		w.write(len(objs[num])-minGroup, groupBits)
+	}
+	w.align()
+	for range ids {
+		w.write(0, 1)
+	}
+	w.align()
+	return w.buf, s
+}
+
+// bitWriter writes the bit fields of hint tables
+type bitWriter struct {
+	buf  []byte
+	bits int // number of bits written to the last byte of buf
+}
+
+// write writes the n low-order bits of v, most significant first
+func (w *bitWriter) write(v, n int) {
+	for j := n - 1; j >= 0; j-- {
+		if w.bits == 0 {
+			w.buf = append(w.buf, 0)
+		}
+		if v>>uint(j)&1 == 1 {
+			w.buf[len(w.buf)-1] |= 0x80 >> uint(w.bits)
+		}
+		w.bits = (w.bits + 1) % 8
+	}
+}
+
+// align pads the last byte written with zero bits
+func (w *bitWriter) align() {
+	w.bits = 0
+}
+
+// bitLength returns the number of bits needed to represent v
+func bitLength(v int) (n int) {
+	for ; v > 0; v >>= 1 {
+		n++
+	}
+	return
+}
+
+// pdfObjRefs calls fn with each reference held by obj
+func pdfObjRefs(obj interface{}, fn func(v pdfObjRef)) {
+	switch v := obj.(type) {
+	case pdfObjRef:
+		fn(v)
+	case pdfObjArray:
+		for _, elem := range v {
+			pdfObjRefs(elem, fn)
+		}
+	case pdfObjDict:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			pdfObjRefs(v[key], fn)
+		}
+	case *pdfObjStream:
+		pdfObjRefs(v.dict, fn)
+	}
+}
+
+// padRight returns s followed by spaces up to width characters
+func padRight(s string, width int) string {
+	if len(s) >= width {
+		return s
+	}
+	return s + strings.Repeat(" ", width-len(s))
+}
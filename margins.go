@@ -0,0 +1,52 @@
+package gofpdf
+
+// mirrorType holds the mirrored margins set with SetMarginsMirrored()
+type mirrorType struct {
+	on           bool    // whether the left and right margins alternate
+	inner, outer float64 // margins at the binding and at the outer edge
+}
+
+// SetMarginsMirrored sets margins that alternate between odd and even pages,
+// as in a book printed on both sides: inner is the margin at the binding,
+// which is the left margin of odd pages and the right margin of even pages,
+// outer is the margin at the outer edge and top is the top margin. The
+// margins apply to the current page, or to the first page if none has been
+// added, and change with each page added later, before its header is
+// printed. SetMargins() ends the mirrored margins.
+//
+// Pages are odd or even by their number when they are added; the pages of
+// page builders and of a table of contents, which are numbered later, have
+// the margins they are given.
+//
+// The SetMarginsMirrored example demonstrates this method.
+func (f *Fpdf) SetMarginsMirrored(inner, outer, top float64) {
+	f.mirror = mirrorType{on: true, inner: inner, outer: outer}
+	f.tMargin = top
+	page := f.page
+	if page == 0 {
+		page = 1
+	}
+	x := f.x - f.lMargin
+	f.mirrorMargins(page)
+	if f.columns.n > 1 {
+		// The current column is placed within the new margins
+		f.setColumn(f.columns.current)
+		f.x = f.lMargin + x
+	}
+}
+
+// mirrorMargins sets the left and right page margins for page if mirrored
+// margins are set
+func (f *Fpdf) mirrorMargins(page int) {
+	if !f.mirror.on {
+		return
+	}
+	left, right := f.mirror.inner, f.mirror.outer
+	if page%2 == 0 {
+		left, right = right, left
+	}
+	if f.columns.n > 1 {
+		f.columns.lMargin, f.columns.rMargin = left, right
+	}
+	f.lMargin, f.rMargin = left, right
+}
@@ -0,0 +1,365 @@
+package gofpdf
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// MarkupAnnotationOptions holds the properties of text annotations and text
+// markup annotations, with which reviewers comment on a document.
+//
+// Author is the name of the author of the annotation, which PDF readers show
+// as the title of its popup window, and Contents is the text of the
+// annotation, shown in that window. Subject, if not empty, is a short
+// description of the subject of the annotation.
+//
+// Color, if not nil, specifies the color of the annotation and its opacity,
+// from 0 (transparent) to 1 (opaque). As for attachment annotations, an Alpha
+// value of 0 is taken to mean opaque. If Color is nil, text annotations and
+// highlights are yellow, and the other markups are red.
+//
+// Icon is the name of the icon of a text annotation: "Comment", "Help",
+// "Insert", "Key", "NewParagraph", "Note" or "Paragraph". If it is empty,
+// "Note" is used. Text markup annotations have no icon.
+//
+// Modified is the date and time of the last modification of the annotation.
+// If it is zero, the modification date of the document is used, as set with
+// SetModificationDate(), or the current time.
+//
+// Open, if true, causes the popup window of the annotation to be open when
+// the document is opened.
+type MarkupAnnotationOptions struct {
+	Author   string
+	Contents string
+	Subject  string
+	Color    *RGBAType
+	Icon     string
+	Modified time.Time
+	Open     bool
+}
+
+// markupAnnotType is a text annotation, a text markup annotation or a shape
+// annotation
+type markupAnnotType struct {
+	subtype    string
+	x, y, w, h float64     // rectangle, in points from the lower left corner of the page
+	quads      []float64   // quadrilaterals covered by text markups, in points
+	paths      [][]float64 // vertices of lines, polygons and ink strokes, in points
+	da         string      // default appearance of the text of free text annotations
+	align      string      // alignment of the text of free text annotations
+	callout    []float64   // callout line of free text annotations, in points
+	rd         [4]float64  // margins of the box of free text annotations within the rectangle
+	ap         []byte      // appearance of free text and stamp annotations, drawn when they are added
+	options    AnnotationOptions
+	wPt, hPt   float64 // size of the page
+}
+
+// markupIcons are the names of the icons of text annotations
+var markupIcons = map[string]bool{
+	"Comment": true, "Help": true, "Insert": true, "Key": true,
+	"NewParagraph": true, "Note": true, "Paragraph": true,
+}
+
+// markupSubtypes are the subtypes of text markup annotations
+var markupSubtypes = map[string]bool{
+	"Highlight": true, "Squiggly": true, "StrikeOut": true, "Underline": true,
+}
+
+// AddTextAnnotation adds a text annotation, which PDF readers show as an icon
+// that opens a popup window like a sticky note, with its upper left corner at
+// (x, y) on the current page. author is the name of the author of the note
+// and contents its text. The icon has the color clr.
+//
+// The AddTextAnnotation example demonstrates this method.
+func (f *Fpdf) AddTextAnnotation(x, y float64, clr RGBType, author, contents string) {
+	f.AddTextAnnotationOptions(x, y, MarkupAnnotationOptions{
+		Author: author, Contents: contents, Color: &RGBAType{R: clr.R, G: clr.G, B: clr.B, Alpha: 1}})
+}
+
+// AddTextAnnotationOptions is the same as AddTextAnnotation() but the
+// annotation has the properties specified by options. An error occurs if
+// options.Icon is not a known icon.
+func (f *Fpdf) AddTextAnnotationOptions(x, y float64, options MarkupAnnotationOptions) {
+	if f.err != nil {
+		return
+	}
+	if options.Icon == "" {
+		options.Icon = "Note"
+	}
+	if !markupIcons[options.Icon] {
+		f.err = fmt.Errorf("unrecognized text annotation icon \"%s\"", options.Icon)
+		return
+	}
+	// The icon has the size of the icons of most PDF readers
+	const size = 20
+	f.addMarkupAnnot(markupAnnotType{subtype: "Text", x: x * f.k, y: f.hPt - y*f.k - size, w: size, h: size},
+		AnnotationOptions{MarkupAnnotationOptions: options})
+}
+
+// AddHighlight highlights the text covered by quadPoints on the current page
+// with the color clr. author is the name of the author of the highlight and
+// contents the text of its popup window. See AddTextMarkupAnnotation() for
+// the quadrilaterals of quadPoints.
+//
+// The AddTextAnnotation example demonstrates this method.
+func (f *Fpdf) AddHighlight(quadPoints []PointType, clr RGBType, author, contents string) {
+	f.addTextMarkup("Highlight", quadPoints, clr, author, contents)
+}
+
+// AddUnderline underlines the text covered by quadPoints on the current page,
+// like AddHighlight().
+func (f *Fpdf) AddUnderline(quadPoints []PointType, clr RGBType, author, contents string) {
+	f.addTextMarkup("Underline", quadPoints, clr, author, contents)
+}
+
+// AddSquiggly underlines the text covered by quadPoints on the current page
+// with a squiggly line, like AddHighlight().
+func (f *Fpdf) AddSquiggly(quadPoints []PointType, clr RGBType, author, contents string) {
+	f.addTextMarkup("Squiggly", quadPoints, clr, author, contents)
+}
+
+// AddStrikeOut strikes out the text covered by quadPoints on the current page,
+// like AddHighlight().
+func (f *Fpdf) AddStrikeOut(quadPoints []PointType, clr RGBType, author, contents string) {
+	f.addTextMarkup("StrikeOut", quadPoints, clr, author, contents)
+}
+
+// addTextMarkup adds a text markup annotation of the specified subtype with
+// the color clr
+func (f *Fpdf) addTextMarkup(subtype string, quadPoints []PointType, clr RGBType, author, contents string) {
+	f.AddTextMarkupAnnotation(subtype, quadPoints, MarkupAnnotationOptions{
+		Author: author, Contents: contents, Color: &RGBAType{R: clr.R, G: clr.G, B: clr.B, Alpha: 1}})
+}
+
+// AddTextMarkupAnnotation adds a text markup annotation of the specified
+// subtype, "Highlight", "Underline", "Squiggly" or "StrikeOut", with the
+// properties specified by options, to the text covered by quadPoints on the
+// current page. Each group of four points of quadPoints is a quadrilateral
+// that covers a line of the text, or part of it: the upper left, upper right,
+// lower left and lower right corner of the text, in this order, in the unit
+// of measure of the document. The width of the text is found with
+// GetStringWidth().
+//
+// The annotation is drawn by gofpdf, so that it looks the same in every PDF
+// reader. An error occurs if subtype is unknown or if quadPoints is empty or
+// does not consist of groups of four points.
+//
+// The AddTextAnnotation example demonstrates this method.
+func (f *Fpdf) AddTextMarkupAnnotation(subtype string, quadPoints []PointType, options MarkupAnnotationOptions) {
+	if f.err != nil {
+		return
+	}
+	if !markupSubtypes[subtype] {
+		f.err = fmt.Errorf("unrecognized text markup annotation \"%s\"", subtype)
+		return
+	}
+	if len(quadPoints) == 0 || len(quadPoints)%4 != 0 {
+		f.err = fmt.Errorf("text markup annotation needs groups of four points, not %d points", len(quadPoints))
+		return
+	}
+	an := markupAnnotType{subtype: subtype, quads: make([]float64, 0, 2*len(quadPoints))}
+	x1, y1, x2, y2 := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, pt := range quadPoints {
+		x, y := pt.X*f.k, f.hPt-pt.Y*f.k
+		an.quads = append(an.quads, x, y)
+		x1, y1, x2, y2 = math.Min(x1, x), math.Min(y1, y), math.Max(x2, x), math.Max(y2, y)
+	}
+	an.x, an.y, an.w, an.h = x1, y1, x2-x1, y2-y1
+	options.Icon = ""
+	f.addMarkupAnnot(an, AnnotationOptions{MarkupAnnotationOptions: options})
+}
+
+// addMarkupAnnot adds the annotation an with the properties of options to the
+// current page
+func (f *Fpdf) addMarkupAnnot(an markupAnnotType, options AnnotationOptions) {
+	if f.page == 0 {
+		f.err = fmt.Errorf("a page must be added before an annotation")
+		return
+	}
+	if options.Color == nil {
+		options.Color = &RGBAType{R: 255, G: 0, B: 0, Alpha: 1}
+		if an.subtype == "Text" || an.subtype == "Highlight" {
+			options.Color = &RGBAType{R: 255, G: 255, B: 0, Alpha: 1}
+		}
+	} else {
+		clr := *options.Color
+		if clr.Alpha == 0 {
+			clr.Alpha = 1
+		}
+		options.Color = &clr
+	}
+	an.options, an.wPt, an.hPt = options, f.wPt, f.hPt
+	if f.pdfVersion < "1.4" {
+		f.pdfVersion = "1.4"
+	}
+	if f.pageMarkups == nil {
+		f.pageMarkups = make(map[int][]markupAnnotType)
+	}
+	f.pageMarkups[f.page] = append(f.pageMarkups[f.page], an)
+}
+
+// markupAnnotCount returns the number of text annotations, text markup
+// annotations and shape annotations in the document
+func (f *Fpdf) markupAnnotCount() (count int) {
+	for _, list := range f.pageMarkups {
+		count += len(list)
+	}
+	return
+}
+
+// putMarkupAnnots writes the text annotations, text markup annotations and
+// shape annotations of the document, with their appearance streams and popup
+// windows, and keeps their object numbers for putMarkupAnnotRefs()
+func (f *Fpdf) putMarkupAnnots() {
+	pages := make([]int, 0, len(f.pageMarkups))
+	for n := range f.pageMarkups {
+		pages = append(pages, n)
+	}
+	sort.Ints(pages)
+	if f.markupObjs == nil {
+		f.markupObjs = make(map[int][]int)
+	}
+	for _, n := range pages {
+		for _, an := range f.pageMarkups[n] {
+			apObj := f.putMarkupAppearance(an)
+			clr := an.options.Color
+			f.newobj()
+			f.markupObjs[n] = append(f.markupObjs[n], f.n, f.n+1)
+			f.outf("<</Type /Annot /Subtype /%s /Rect [%.2f %.2f %.2f %.2f]", an.subtype, an.x, an.y, an.x+an.w, an.y+an.h)
+			switch {
+			case an.subtype == "Text":
+				// Notes keep their size when the page is zoomed or rotated
+				f.outf("/F 28 /Name /%s /Open %t", an.options.Icon, an.options.Open)
+			case an.subtype == "FreeText":
+				f.putFreeTextEntries(an)
+			case an.subtype == "Stamp":
+				f.outf("/F 4 /Name /%s", an.options.Icon)
+			case an.quads == nil:
+				f.putShapeAnnotEntries(an)
+			default:
+				var quads fmtBuffer
+				for _, v := range an.quads {
+					quads.printf("%.2f ", v)
+				}
+				f.outf("/F 4 /QuadPoints [%s]", quads.String()[:quads.Len()-1])
+			}
+			f.outf("/C [%.3f %.3f %.3f] /CA %.3f", float64(clr.R)/255, float64(clr.G)/255, float64(clr.B)/255, clr.Alpha)
+			f.outf("/T %s /Contents %s", f.textstring(utf8toutf16(an.options.Author)),
+				f.textstring(utf8toutf16(an.options.Contents)))
+			if an.options.Subject != "" {
+				f.outf("/Subj %s", f.textstring(utf8toutf16(an.options.Subject)))
+			}
+			mod := an.options.Modified
+			if mod.IsZero() {
+				mod = timeOrNow(f.modDate)
+			}
+			f.outf("/M %s", f.textstring("D:"+mod.Format("20060102150405")))
+			if apObj > 0 {
+				f.outf("/AP <</N %d 0 R>>", apObj)
+			}
+			f.outf("/Popup %d 0 R>>", f.n+1)
+			f.out("endobj")
+			// The popup window is put beside the annotation, within the page
+			const popupW, popupH = 180, 120
+			px := math.Max(0, math.Min(an.x+an.w+10, an.wPt-popupW))
+			py := math.Max(0, math.Min(an.y+an.h-popupH, an.hPt-popupH))
+			f.newobj()
+			f.outf("<</Type /Annot /Subtype /Popup /Rect [%.2f %.2f %.2f %.2f] /F 28 /Parent %d 0 R /Open %t>>",
+				px, py, px+popupW, py+popupH, f.n-1, an.options.Open)
+			f.out("endobj")
+		}
+	}
+}
+
+// putMarkupAnnotRefs writes the references to the markup annotations of the
+// page, and to their popup windows, to the annotations of the page
+func (f *Fpdf) putMarkupAnnotRefs(out *fmtBuffer, page int) {
+	for _, obj := range f.markupObjs[page] {
+		out.printf("%d 0 R ", obj)
+	}
+}
+
+// putMarkupAppearance writes the appearance stream of the annotation an and
+// returns its object number, or 0 if it is a text annotation, whose icon is
+// drawn by the PDF reader unless the document is a PDF/A document
+func (f *Fpdf) putMarkupAppearance(an markupAnnotType) int {
+	clr := an.options.Color
+	rgb := sprintf("%.3f %.3f %.3f", float64(clr.R)/255, float64(clr.G)/255, float64(clr.B)/255)
+	var b fmtBuffer
+	resources := ""
+	q := an.quads
+	switch an.subtype {
+	case "Text":
+		if f.pdfa.part == 0 {
+			return 0
+		}
+		b.printf("%s rg 0 G 1 w 2 4 m 18 4 l 18 19 l 2 19 l h B 5 8 m 15 8 l 5 11.5 m 15 11.5 l 5 15 m 15 15 l S\n", rgb)
+	case "Highlight":
+		// The highlight darkens the text instead of covering it
+		resources = "/Resources <</ExtGState <</GS1 <</BM /Multiply>>>>>> "
+		b.printf("/GS1 gs %s rg\n", rgb)
+		for j := 0; j < len(q); j += 8 {
+			b.printf("%.2f %.2f m %.2f %.2f l %.2f %.2f l %.2f %.2f l h f\n",
+				q[j], q[j+1], q[j+2], q[j+3], q[j+6], q[j+7], q[j+4], q[j+5])
+		}
+	case "Square", "Circle", "Line", "Polygon", "PolyLine", "Ink":
+		f.drawShapeAppearance(&b, an)
+	case "FreeText", "Stamp":
+		// The text and templates are drawn with the resources of the document
+		resources = "/Resources 2 0 R "
+		b.Write(an.ap)
+	default:
+		b.printf("%s RG\n", rgb)
+		for j := 0; j < len(q); j += 8 {
+			// at returns the point of the quadrilateral at the fraction s of
+			// its width and t of its height, from its lower left corner
+			at := func(s, t float64) (x, y float64) {
+				bx, by := q[j+4]+(q[j+6]-q[j+4])*s, q[j+5]+(q[j+7]-q[j+5])*s
+				tx, ty := q[j]+(q[j+2]-q[j])*s, q[j+1]+(q[j+3]-q[j+1])*s
+				return bx + (tx-bx)*t, by + (ty-by)*t
+			}
+			ht := math.Hypot(q[j]-q[j+4], q[j+1]-q[j+5])
+			switch an.subtype {
+			case "Underline", "StrikeOut":
+				t := 0.08
+				if an.subtype == "StrikeOut" {
+					t = 0.5
+				}
+				x1, y1 := at(0, t)
+				x2, y2 := at(1, t)
+				b.printf("%.2f w %.2f %.2f m %.2f %.2f l S\n", ht/16, x1, y1, x2, y2)
+			case "Squiggly":
+				// The waves are as long as a quarter of the height of the text
+				wd := math.Hypot(q[j+6]-q[j+4], q[j+7]-q[j+5])
+				waves := int(math.Max(1, math.Round(wd/(ht/4))))
+				b.printf("%.2f w", ht/20)
+				for k := 0; k <= 2*waves; k++ {
+					t := 0.02
+					if k%2 == 1 {
+						t = 0.12
+					}
+					x, y := at(float64(k)/float64(2*waves), t)
+					op := "l"
+					if k == 0 {
+						op = "m"
+					}
+					b.printf(" %.2f %.2f %s", x, y, op)
+				}
+				b.printf(" S\n")
+			}
+		}
+	}
+	f.newobj()
+	if an.subtype == "Text" {
+		f.outf("<</Type /XObject /Subtype /Form /BBox [0 0 %.2f %.2f] /Length %d>>",
+			an.w, an.h, f.protect.streamLength(b.Len()))
+	} else {
+		f.outf("<</Type /XObject /Subtype /Form /BBox [%.2f %.2f %.2f %.2f] %s/Length %d>>",
+			an.x, an.y, an.x+an.w, an.y+an.h, resources, f.protect.streamLength(b.Len()))
+	}
+	f.putstream(b.Bytes())
+	f.out("endobj")
+	return f.n
+}
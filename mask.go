@@ -0,0 +1,109 @@
+package gofpdf
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maskedImage returns the image with which the image info is placed with the
+// mask image and the color key mask of options, which is a copy of info
+// unless options specifies neither
+func (f *Fpdf) maskedImage(info *ImageInfoType, options ImageOptions) *ImageInfoType {
+	if options.SMaskImage == "" && len(options.MaskColors) == 0 {
+		return info
+	}
+	masked := *info
+	if info.lazy != nil {
+		// The copy reads its data on its own
+		lazy := *info.lazy
+		masked.lazy = &lazy
+	}
+	if options.SMaskImage != "" {
+		mask := f.RegisterImageOptions(options.SMaskImage, ImageOptions{})
+		if f.err != nil {
+			return info
+		}
+		if mask.cs != "DeviceGray" || mask.hasSMask() || mask.mask != nil {
+			f.err = fmt.Errorf("mask image %s is not a gray image without transparency", options.SMaskImage)
+			return info
+		}
+		// The mask replaces the transparency of the image, and is embedded
+		// as an image only if it is placed itself
+		masked.mask, masked.smask, masked.trns = mask, nil, nil
+		if mask.bpc != 1 && f.pdfVersion < "1.4" {
+			f.pdfVersion = "1.4"
+		}
+	}
+	if len(options.MaskColors) > 0 {
+		components := iccComponents[info.cs]
+		if info.cs == "Indexed" {
+			components = 1
+		}
+		if components == 0 || len(options.MaskColors) != 2*components {
+			f.err = fmt.Errorf("color key mask of image needs %d values, not %d", 2*components, len(options.MaskColors))
+			return info
+		}
+		maxVal := 1<<uint(info.bpc) - 1
+		for j := 0; j < len(options.MaskColors); j += 2 {
+			low, high := options.MaskColors[j], options.MaskColors[j+1]
+			if low < 0 || low > high || high > maxVal {
+				f.err = fmt.Errorf("invalid color key mask range %d to %d of image with values 0 to %d", low, high, maxVal)
+				return info
+			}
+		}
+		masked.cmask, masked.trns = append([]int(nil), options.MaskColors...), nil
+	}
+	key := sprintf("\x00masked %s %v", info.i, options.MaskColors)
+	if masked.mask != nil {
+		key += " " + masked.mask.i
+	}
+	if existing, ok := f.images[key]; ok {
+		return existing
+	}
+	if masked.i, f.err = generateImageID(&masked); f.err != nil {
+		return info
+	}
+	f.images[key] = &masked
+	return &masked
+}
+
+// imageMaskObject returns the object number of the mask image mask, which is
+// the object number next if the mask has not been written for another image
+// yet, and whether the mask needs to be written
+func (f *Fpdf) imageMaskObject(mask *ImageInfoType, next int) (n int, put bool) {
+	key := "M" + mask.i
+	if n, ok := f.resourceObjs[key]; ok {
+		return n, false
+	}
+	f.resourceObjs[key] = next
+	return next, true
+}
+
+// putImageMask writes the mask image mask, as a stencil mask if it has 1 bit
+// per component, whose white samples show the image, or as a soft mask
+func (f *Fpdf) putImageMask(mask *ImageInfoType) {
+	if f.err = mask.loadImage(); f.err != nil {
+		return
+	}
+	defer mask.unloadImage()
+	f.newobj()
+	var dict fmtBuffer
+	dict.printf("<</Type /XObject /Subtype /Image /Width %d /Height %d", int(mask.w), int(mask.h))
+	if mask.bpc == 1 {
+		dict.printf(" /ImageMask true /Decode [1 0]")
+	} else {
+		dict.printf(" /ColorSpace /DeviceGray")
+		if mask.f != "JPXDecode" {
+			dict.printf(" /BitsPerComponent %d", mask.bpc)
+		}
+	}
+	if mask.f != "" {
+		dict.printf(" /Filter /%s", mask.f)
+	}
+	if mask.dp != "" {
+		dict.printf(" /DecodeParms <<%s>>", mask.dp)
+	}
+	f.outf("%s /Length %d>>", strings.TrimSpace(dict.String()), f.protect.streamLength(len(mask.data)))
+	f.putstream(mask.data)
+	f.out("endobj")
+}
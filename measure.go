@@ -0,0 +1,133 @@
+package gofpdf
+
+// Measurer lays out cells, text and images like the document it has been
+// created for, without adding anything to the document, so that the space
+// they take can be known before they are drawn, for example to center a
+// block of text vertically or to align it with the bottom of the page. A
+// measurer has the methods of Fpdf; the position, height and page breaks of
+// what has been laid out with them are returned by Result().
+type Measurer struct {
+	*Fpdf
+	header     func()
+	headerHome bool
+	footer     func()
+	tops, ends []float64 // positions at which each page starts and ends
+}
+
+// MeasureResult is the outcome of the layout of a measurer
+type MeasureResult struct {
+	X, Y       float64 // current position after the layout
+	Height     float64 // height taken on all pages, without headers and footers
+	PageBreaks int     // number of pages added after the first one
+}
+
+// NewMeasurer returns a measurer for the document. It starts on a page of the
+// size of the current page of the document, at its current position, with
+// the margins, page break settings, fonts, images, colors and line and text
+// settings of the document, as a page builder does; if the document has no
+// current page, it starts at the top left margin of a page of the default
+// format.
+//
+// The header and footer functions of the document are not called on the
+// pages that a measurer adds when a page break occurs, since they draw on the
+// document; layout on these pages starts at their top margin. Headers and
+// footers that change the position or the margins can be reproduced with
+// SetHeaderFunc() and SetFooterFunc() of the measurer.
+//
+// The NewMeasurer example demonstrates this method.
+func (f *Fpdf) NewMeasurer() *Measurer {
+	m := &Measurer{Fpdf: f.NewPageBuilder().Fpdf}
+	if m.err != nil {
+		return m
+	}
+	m.Fpdf.headerFnc = m.pageHeader
+	m.Fpdf.footerFnc = m.pageFooter
+	if f.state == 2 && !f.pageClosed {
+		m.AddPageFormat(f.curOrientation, f.curPageSize)
+		m.x, m.y, m.lasth = f.x, f.y, f.lasth
+		m.tops[0] = m.y
+	} else {
+		m.AddPage()
+	}
+	return m
+}
+
+// SetHeaderFunc sets the function called when the measurer adds a page, like
+// SetHeaderFunc() of Fpdf. The height taken on the page is counted from the
+// position fnc leaves.
+func (m *Measurer) SetHeaderFunc(fnc func()) {
+	m.header, m.headerHome = fnc, false
+}
+
+// SetHeaderFuncMode sets the function called when the measurer adds a page,
+// like SetHeaderFuncMode() of Fpdf.
+func (m *Measurer) SetHeaderFuncMode(fnc func(), homeMode bool) {
+	m.header, m.headerHome = fnc, homeMode
+}
+
+// SetFooterFunc sets the function called when the measurer leaves a page,
+// like SetFooterFunc() of Fpdf.
+func (m *Measurer) SetFooterFunc(fnc func()) {
+	m.footer = fnc
+}
+
+// SetFooterFuncLpi sets the function called when the measurer leaves a page,
+// like SetFooterFuncLpi() of Fpdf. Pages left by a measurer are never the
+// last one.
+func (m *Measurer) SetFooterFuncLpi(fnc func(lastPage bool)) {
+	m.footer = func() {
+		fnc(false)
+	}
+}
+
+// SetHeaderFuncEx sets the function called when the measurer adds a page,
+// like SetHeaderFuncEx() of Fpdf.
+func (m *Measurer) SetHeaderFuncEx(fnc func(ctx PageContext)) {
+	m.header, m.headerHome = func() {
+		fnc(m.pageContext(false))
+	}, false
+}
+
+// SetFooterFuncEx sets the function called when the measurer leaves a page,
+// like SetFooterFuncEx() of Fpdf. Pages left by a measurer are never the last
+// one.
+func (m *Measurer) SetFooterFuncEx(fnc func(ctx PageContext)) {
+	m.footer = func() {
+		fnc(m.pageContext(false))
+	}
+}
+
+// Result returns the current position of the measurer, the height taken by
+// what has been laid out since it was created and the number of page breaks
+// that have occurred.
+func (m *Measurer) Result() (r MeasureResult) {
+	r.X, r.Y = m.x, m.y
+	r.PageBreaks = len(m.ends)
+	for j, end := range m.ends {
+		r.Height += end - m.tops[j]
+	}
+	if len(m.tops) > len(m.ends) {
+		r.Height += m.y - m.tops[len(m.ends)]
+	}
+	return
+}
+
+// pageHeader prints the header of a page of the measurer and records where
+// the page starts
+func (m *Measurer) pageHeader() {
+	if m.header != nil {
+		m.header()
+		if m.headerHome {
+			m.SetHomeXY()
+		}
+	}
+	m.tops = append(m.tops, m.y)
+}
+
+// pageFooter records where a page of the measurer ends and prints its footer
+func (m *Measurer) pageFooter() {
+	m.ends = append(m.ends, m.y)
+	if m.footer != nil {
+		m.footer()
+	}
+}
@@ -0,0 +1,251 @@
+package gofpdf
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"math"
+)
+
+// AppendPDF appends the pages of the PDF document read from r to the
+// document, after the pages that have been added so far, so that generated
+// pages and existing documents, such as a cover letter and the attachments
+// it refers to, can be put together. Each page is appended with its crop box,
+// the area that readers display, and with its rotation applied.
+//
+// Links of the appended pages keep working: links to pages of the appended
+// document lead to the pages they have become, and links to web pages and
+// other resources are kept as they are. Other annotations, such as notes,
+// highlights and the widgets of form fields, are copied as well; form fields
+// keep their appearance but are no longer interactive. Bookmarks of the
+// appended document are not copied; they can be added with SetOutline().
+//
+// The header and footer functions set with SetHeaderFunc() and
+// SetFooterFunc() are called for the appended pages as for other pages, for
+// example to number them. Otherwise, the restrictions of ImportPage() apply.
+//
+// The AppendPDF example demonstrates this method.
+func (f *Fpdf) AppendPDF(r io.ReadSeeker) {
+	if f.err != nil {
+		return
+	}
+	var data []byte
+	if data, f.err = readAll(r); f.err != nil {
+		return
+	}
+	im := &pdfImporter{f: f, digest: fmt.Sprintf("%x", sha1.Sum(data)), refs: make(map[int]string)}
+	if f.err = im.parse(data); f.err != nil {
+		return
+	}
+	if len(im.pages) == 0 {
+		f.err = fmt.Errorf("PDF document has no pages to append")
+		return
+	}
+	first := f.page + 1
+	index := make(map[int]int, len(im.pages))
+	for j, page := range im.pages {
+		index[page.num] = first + j
+	}
+	for j, page := range im.pages {
+		id := f.importPage(im, j+1, "CropBox")
+		if f.err != nil {
+			return
+		}
+		w, h := f.GetImportedPageSize(id)
+		f.AddPageFormat("P", SizeType{Wd: w, Ht: h})
+		f.UseImportedPage(id, 0, 0, w, h)
+		f.appendAnnots(im, page, index)
+	}
+}
+
+// Merge writes the PDF document that consists of the pages of the PDF
+// documents read from inputs, in order, to w, in the way AppendPDF() appends
+// them.
+//
+// The AppendPDF example demonstrates this function.
+func Merge(w io.Writer, inputs ...io.ReadSeeker) error {
+	if len(inputs) == 0 {
+		return fmt.Errorf("no PDF documents to merge")
+	}
+	f := New("P", "pt", "A4", "")
+	for _, r := range inputs {
+		f.AppendPDF(r)
+	}
+	return f.Output(w)
+}
+
+// appendAnnots adds the annotations of page, which has been appended as the
+// current page, to the current page. index maps the object numbers of the
+// pages of the appended document to the numbers of the pages they have
+// become.
+func (f *Fpdf) appendAnnots(im *pdfImporter, page pdfPage, index map[int]int) {
+	p := im.p
+	_, matrix, _, _ := p.pageMatrix(page.dict, "CropBox")
+	annots, _ := p.resolve(page.dict["Annots"]).(pdfObjArray)
+	type annotType struct {
+		hash string
+		dict pdfObjDict
+	}
+	var copies []annotType
+	for j, obj := range annots {
+		dict, ok := p.resolve(obj).(pdfObjDict)
+		if !ok {
+			continue
+		}
+		rect, ok := p.rect(dict["Rect"])
+		if !ok {
+			continue
+		}
+		rect = transformRect(rect, matrix)
+		if dict["Subtype"] == pdfObjName("Link") && f.appendLink(im, dict, rect, index) {
+			continue
+		}
+		annot := make(pdfObjDict, len(dict))
+		for key, value := range dict {
+			annot[key] = value
+		}
+		// The page and structure of the appended document are not copied
+		delete(annot, "P")
+		delete(annot, "StructParent")
+		annot["Rect"] = pdfObjArray{rect[0], rect[1], rect[2], rect[3]}
+		h := im.hash(sprintf("annotation %d %d", f.page, j))
+		if ref, ok := obj.(pdfObjRef); ok {
+			// Annotations that refer to each other, such as notes and their
+			// pop-up windows, refer to the copies on this page
+			im.refs[ref.num] = h
+		}
+		f.importedObjs[h] = nil
+		copies = append(copies, annotType{h, annot})
+	}
+	for _, c := range copies {
+		var buf bytes.Buffer
+		pos := make(map[int]string)
+		im.write(&buf, c.dict, pos)
+		buf.WriteString("\nendobj")
+		f.importedObjs[c.hash] = buf.Bytes()
+		f.importedObjPos[c.hash] = pos
+		if f.importedAnnots == nil {
+			f.importedAnnots = make(map[int][]string)
+		}
+		f.importedAnnots[f.page] = append(f.importedAnnots[f.page], c.hash)
+	}
+}
+
+// appendLink adds the link annotation dict with the rectangle rect, in
+// points, to the current page, if it leads to a page of the appended
+// document or to a URI, and returns true if it has been added or leads to a
+// page that does not exist
+func (f *Fpdf) appendLink(im *pdfImporter, dict pdfObjDict, rect [4]float64, index map[int]int) bool {
+	p := im.p
+	x, y := rect[0]/f.k, (f.hPt-rect[3])/f.k
+	w, h := (rect[2]-rect[0])/f.k, (rect[3]-rect[1])/f.k
+	dest := dict["Dest"]
+	if action, ok := p.resolve(dict["A"]).(pdfObjDict); ok {
+		switch p.resolve(action["S"]) {
+		case pdfObjName("URI"):
+			uri, ok := p.resolve(action["URI"]).(pdfObjString)
+			if ok {
+				f.LinkString(x, y, w, h, string(uri))
+			}
+			return ok
+		case pdfObjName("GoTo"):
+			dest = action["D"]
+		default:
+			return false
+		}
+	}
+	if dest == nil {
+		return false
+	}
+	num, top, ok := im.dest(dest)
+	if page, found := index[num]; ok && found {
+		link := f.AddLink()
+		f.SetLink(link, im.destY(num, top), page)
+		f.Link(x, y, w, h, link)
+	}
+	return true
+}
+
+// dest returns the object number of the page of the destination obj and the
+// top of the area it shows, or NaN if it does not specify one
+func (im *pdfImporter) dest(obj interface{}) (num int, top float64, ok bool) {
+	p := im.p
+	obj = p.resolve(obj)
+	catalog, _ := p.resolve(p.trailer["Root"]).(pdfObjDict)
+	switch v := obj.(type) {
+	case pdfObjName:
+		// Named destination of PDF 1.1
+		dests, _ := p.resolve(catalog["Dests"]).(pdfObjDict)
+		obj = p.resolve(dests[string(v)])
+	case pdfObjString:
+		names, _ := p.resolve(catalog["Names"]).(pdfObjDict)
+		obj = p.resolve(p.nameTree(names["Dests"], string(v), 0))
+	}
+	if dict, isDict := obj.(pdfObjDict); isDict {
+		obj = p.resolve(dict["D"])
+	}
+	arr, _ := obj.(pdfObjArray)
+	if len(arr) < 2 {
+		return
+	}
+	ref, isRef := arr[0].(pdfObjRef)
+	if !isRef {
+		return
+	}
+	top = math.NaN()
+	kind, _ := arr[1].(pdfObjName)
+	pos := map[pdfObjName]int{"XYZ": 3, "FitH": 2, "FitBH": 2, "FitR": 5}[kind]
+	if pos > 0 && pos < len(arr) {
+		if v, isNum := p.number(arr[pos]); isNum {
+			top = v
+		}
+	}
+	return ref.num, top, true
+}
+
+// destY returns the position on the appended page num, in the unit of
+// measure specified in New(), of the top of a destination
+func (im *pdfImporter) destY(num int, top float64) float64 {
+	if math.IsNaN(top) {
+		return 0
+	}
+	for _, page := range im.pages {
+		if page.num == num {
+			r, matrix, _, h := im.p.pageMatrix(page.dict, "CropBox")
+			r = transformRect([4]float64{r[0], top, r[0], top}, matrix)
+			return math.Max(0, (h-r[3])/im.f.k)
+		}
+	}
+	return 0
+}
+
+// nameTree returns the value of key in the name tree node
+func (p *pdfParser) nameTree(node interface{}, key string, depth int) interface{} {
+	dict, _ := p.resolve(node).(pdfObjDict)
+	if dict == nil || depth > 32 {
+		return nil
+	}
+	names, _ := p.resolve(dict["Names"]).(pdfObjArray)
+	for j := 0; j+1 < len(names); j += 2 {
+		if name, ok := p.resolve(names[j]).(pdfObjString); ok && string(name) == key {
+			return names[j+1]
+		}
+	}
+	kids, _ := p.resolve(dict["Kids"]).(pdfObjArray)
+	for _, kid := range kids {
+		if value := p.nameTree(kid, key, depth+1); value != nil {
+			return value
+		}
+	}
+	return nil
+}
+
+// transformRect returns the bounding box of the rectangle r transformed by
+// matrix
+func transformRect(r [4]float64, matrix [6]float64) [4]float64 {
+	a, b, c, d, e, f := matrix[0], matrix[1], matrix[2], matrix[3], matrix[4], matrix[5]
+	x0, y0 := a*r[0]+c*r[1]+e, b*r[0]+d*r[1]+f
+	x1, y1 := a*r[2]+c*r[3]+e, b*r[2]+d*r[3]+f
+	return [4]float64{math.Min(x0, x1), math.Min(y0, y1), math.Max(x0, x1), math.Max(y0, y1)}
+}
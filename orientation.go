@@ -0,0 +1,129 @@
+package gofpdf
+
+import (
+	"math"
+)
+
+// exifOrientation is the tag of the orientation of the image in EXIF data
+const exifOrientation = 0x0112
+
+// jpegOrientation returns the orientation of the JPEG data that its EXIF
+// data specifies, from 2 to 8 if the image is not stored upright, or 0 if
+// the data specifies no orientation
+func jpegOrientation(data []byte) int {
+	const marker = "Exif\x00\x00"
+	pos := 2
+	for pos+4 <= len(data) && data[pos] == 0xff {
+		tp := data[pos+1]
+		if tp == 0xda || tp == 0xd9 {
+			// The image data begins
+			break
+		}
+		n := int(data[pos+2])<<8 | int(data[pos+3])
+		if n < 2 || pos+2+n > len(data) {
+			break
+		}
+		seg := data[pos+4 : pos+2+n]
+		if tp == 0xe1 && len(seg) > len(marker) && string(seg[:len(marker)]) == marker {
+			// The EXIF data is a TIFF file whose first directory holds the
+			// orientation
+			ifd, err := parseTIFFIFD(seg[len(marker):])
+			if err != nil {
+				return 0
+			}
+			if orientation := int(ifd.value(exifOrientation, 0)); orientation <= 8 {
+				return orientation
+			}
+			return 0
+		}
+		pos += 2 + n
+	}
+	return 0
+}
+
+// orientationMatrices are the matrices that map the unit square of images
+// with the EXIF orientations 1 to 8 to the unit square of the upright image
+var orientationMatrices = [9]TransformMatrix{
+	{1, 0, 0, 1, 0, 0},
+	{1, 0, 0, 1, 0, 0},   // 1: upright
+	{-1, 0, 0, 1, 1, 0},  // 2: mirrored horizontally
+	{-1, 0, 0, -1, 1, 1}, // 3: rotated by 180 degrees
+	{1, 0, 0, -1, 0, 1},  // 4: mirrored vertically
+	{0, -1, -1, 0, 1, 1}, // 5: mirrored along the main diagonal
+	{0, -1, 1, 0, 0, 1},  // 6: to be rotated clockwise
+	{0, 1, 1, 0, 0, 0},   // 7: mirrored along the other diagonal
+	{0, 1, -1, 0, 1, 0},  // 8: to be rotated counter-clockwise
+}
+
+// uprightSize returns the width and height in pixels of the image as it is
+// displayed, which are swapped if its EXIF orientation rotates it by a
+// quarter turn
+func (info *ImageInfoType) uprightSize() (w, h float64) {
+	if info.orientation >= 5 {
+		return info.h, info.w
+	}
+	return info.w, info.h
+}
+
+// imagePlacement returns the size in pixels of the bounding box of the
+// upright image info when it is flipped horizontally if flipH is true and
+// vertically if flipV is true, and then rotated counter-clockwise by
+// rotation degrees about its center, and the matrix that maps the unit
+// square of the image to the unit square of that bounding box
+func imagePlacement(info *ImageInfoType, rotation float64, flipH, flipV bool) (bw, bh float64, m TransformMatrix) {
+	m = orientationMatrices[0]
+	if info.orientation > 0 && info.orientation < len(orientationMatrices) {
+		m = orientationMatrices[info.orientation]
+	}
+	if flipH {
+		m = matrixProduct(m, TransformMatrix{-1, 0, 0, 1, 1, 0})
+	}
+	if flipV {
+		m = matrixProduct(m, TransformMatrix{1, 0, 0, -1, 0, 1})
+	}
+	dw, dh := info.uprightSize()
+	bw, bh = dw, dh
+	rotation = math.Mod(rotation, 360)
+	if rotation < 0 {
+		rotation += 360
+	}
+	if rotation != 0 {
+		// Quarter turns are exact
+		var sin, cos float64
+		switch rotation {
+		case 90:
+			sin = 1
+		case 180:
+			cos = -1
+		case 270:
+			sin = -1
+		default:
+			sin, cos = math.Sincos(rotation * math.Pi / 180)
+		}
+		bw = math.Abs(dw*cos) + math.Abs(dh*sin)
+		bh = math.Abs(dw*sin) + math.Abs(dh*cos)
+		m = matrixProduct(m, TransformMatrix{dw, 0, 0, dh, -dw / 2, -dh / 2})
+		m = matrixProduct(m, TransformMatrix{cos, sin, -sin, cos, bw / 2, bh / 2})
+		m = matrixProduct(m, TransformMatrix{1 / bw, 0, 0, 1 / bh, 0, 0})
+	}
+	// Zeros are not printed as -0
+	for _, v := range []*float64{&m.A, &m.B, &m.C, &m.D, &m.E, &m.F} {
+		if *v == 0 {
+			*v = 0
+		}
+	}
+	return
+}
+
+// matrixProduct returns the matrix of the transformation a followed by the
+// transformation b
+func matrixProduct(a, b TransformMatrix) TransformMatrix {
+	return TransformMatrix{
+		A: a.A*b.A + a.B*b.C,
+		B: a.A*b.B + a.B*b.D,
+		C: a.C*b.A + a.D*b.C,
+		D: a.C*b.B + a.D*b.D,
+		E: a.E*b.A + a.F*b.C + b.E,
+		F: a.E*b.B + a.F*b.D + b.F,
+	}
+}
@@ -0,0 +1,101 @@
+package gofpdf
+
+import (
+	"fmt"
+)
+
+// OutlineNode is a bookmark of the document outline, which is exchanged as
+// a tree with SetOutline() and Outline().
+type OutlineNode struct {
+	// Title is the text of the bookmark, encoded in UTF-8.
+	Title string
+	// Page is the one-based number of the page the bookmark refers to.
+	Page int
+	// Y is the position on the page the bookmark refers to, in the unit of
+	// measure specified in New().
+	Y float64
+	// Options specifies the appearance and destination of the bookmark in
+	// the way they are specified with BookmarkEx(). If nil, the bookmark
+	// appears in the way bookmarks set with Bookmark() do.
+	Options *BookmarkOptions
+	// Children are the bookmarks below the bookmark.
+	Children []OutlineNode
+}
+
+// SetOutline replaces the bookmarks of the document with the tree of
+// bookmarks specified by nodes, so that outlines can be built independently
+// of the order in which the pages are generated, or merged from the outlines
+// returned by Outline(). Bookmarks that are set with Bookmark() afterwards
+// are added to the end of the outline.
+//
+// The SetOutline example demonstrates this method.
+func (f *Fpdf) SetOutline(nodes []OutlineNode) {
+	if f.err != nil {
+		return
+	}
+	var outlines []outlineType
+	var add func(nodes []OutlineNode, level int)
+	add = func(nodes []OutlineNode, level int) {
+		for _, node := range nodes {
+			if f.err != nil {
+				return
+			}
+			if node.Page < 1 {
+				f.err = fmt.Errorf("invalid page %d of bookmark %q", node.Page, node.Title)
+				return
+			}
+			o := outlineType{text: outlineText(node.Title), level: level, y: node.Y, p: node.Page,
+				prev: -1, last: -1, next: -1, first: -1}
+			if node.Options != nil {
+				options := *node.Options
+				if f.err = bookmarkZoom(&options); f.err != nil {
+					return
+				}
+				o.options = &options
+			}
+			outlines = append(outlines, o)
+			add(node.Children, level+1)
+		}
+	}
+	add(nodes, 0)
+	if f.err == nil {
+		f.outlines = outlines
+	}
+}
+
+// outlineText returns the title s of a bookmark as a text string, which is
+// left as it is if it consists of ASCII characters only
+func outlineText(s string) string {
+	for j := 0; j < len(s); j++ {
+		if s[j] >= 0x80 {
+			return utf8toutf16(s)
+		}
+	}
+	return s
+}
+
+// Outline returns the bookmarks of the document as a tree, such as the
+// bookmarks set with Bookmark() and BookmarkEx().
+//
+// The SetOutline example demonstrates this method.
+func (f *Fpdf) Outline() []OutlineNode {
+	nodes, _ := f.outlineNodes(0, 0)
+	return nodes
+}
+
+// outlineNodes returns the tree of the bookmarks from index j on that are at
+// level or below it, and the index of the bookmark that follows them
+func (f *Fpdf) outlineNodes(j, level int) ([]OutlineNode, int) {
+	var nodes []OutlineNode
+	for j < len(f.outlines) && f.outlines[j].level >= level {
+		o := f.outlines[j]
+		node := OutlineNode{Title: infoText(o.text), Page: o.p, Y: o.y}
+		if o.options != nil {
+			options := *o.options
+			node.Options = &options
+		}
+		node.Children, j = f.outlineNodes(j+1, o.level+1)
+		nodes = append(nodes, node)
+	}
+	return nodes, j
+}
@@ -0,0 +1,128 @@
+package gofpdf
+
+import (
+	"fmt"
+)
+
+// PageBoxes holds the page boxes of a page, in the unit of measure of the
+// document, with the origin at the upper left corner of the page like the
+// other coordinates of the document. A box whose width and height are zero is
+// not set.
+//
+// The crop box is the region of the page that viewers show and print. The
+// bleed box is the region that printed content extends to before the sheet is
+// cut, the trim box is the size of the finished page after cutting and the art
+// box is the extent of the meaningful content of the page.
+type PageBoxes struct {
+	Crop, Bleed, Trim, Art PageBox
+}
+
+// pageBoxNames are the names of the page boxes in the order in which they are
+// written
+var pageBoxNames = []string{"CropBox", "BleedBox", "TrimBox", "ArtBox"}
+
+// list returns the boxes of boxes in the order of pageBoxNames
+func (boxes *PageBoxes) list() []*PageBox {
+	return []*PageBox{&boxes.Crop, &boxes.Bleed, &boxes.Trim, &boxes.Art}
+}
+
+// SetPageBoxes sets the crop, bleed, trim and art boxes of the page page,
+// which is one-based, to boxes, replacing all the boxes the page had. Print
+// shops require the bleed and trim boxes to cut printed sheets to the size of
+// the finished page. The boxes of flushed pages can be set up to the time
+// the document is closed.
+//
+// An error occurs if page does not exist, if a box has a negative width or
+// height, if a box extends beyond the page, or if the trim or art box extends
+// beyond the bleed box.
+//
+// The SetPageBoxes example demonstrates this method.
+func (f *Fpdf) SetPageBoxes(page int, boxes PageBoxes) {
+	if f.err != nil {
+		return
+	}
+	if page < 1 || page >= len(f.pages) {
+		f.err = fmt.Errorf("page %d does not exist", page)
+		return
+	}
+	f.pageBoxes[page] = make(map[string]PageBox)
+	f.addPageBoxes(page, boxes)
+}
+
+// SetDefaultPageBoxes sets the page boxes of the pages that are added after
+// it is called, in the same way as SetPageBoxes(). The boxes replace the
+// defaults set with SetPageBox() and SetPageBoxRec(), which can still add to
+// them later. An error occurs when a page is added if a box does not fit on
+// it.
+//
+// The SetPageBoxes example demonstrates this method.
+func (f *Fpdf) SetDefaultPageBoxes(boxes PageBoxes) {
+	if f.err != nil {
+		return
+	}
+	f.defPageBoxes = make(map[string]PageBox)
+	f.defBoxes = boxes
+}
+
+// GetPageBoxes returns the page boxes of the page page, which is one-based, in
+// the unit of measure of the document, with the origin at the upper left
+// corner of the page. The boxes of a page that does not exist are all zero.
+func (f *Fpdf) GetPageBoxes(page int) (boxes PageBoxes) {
+	_, h := f.pageSizeUnits(page)
+	for j, box := range boxes.list() {
+		if pb, ok := f.pageBoxes[page][pageBoxNames[j]]; ok {
+			box.X, box.Wd = pb.X/f.k, (pb.Wd-pb.X)/f.k
+			box.Y, box.Ht = h-pb.Ht/f.k, (pb.Ht-pb.Y)/f.k
+		}
+	}
+	return
+}
+
+// pageSizeUnits returns the width and height of page in the unit of measure of
+// the document
+func (f *Fpdf) pageSizeUnits(page int) (w, h float64) {
+	if sz, ok := f.pageSizes[page]; ok {
+		return sz.Wd / f.k, sz.Ht / f.k
+	}
+	if f.defOrientation == "P" {
+		return f.defPageSize.Wd, f.defPageSize.Ht
+	}
+	return f.defPageSize.Ht, f.defPageSize.Wd
+}
+
+// addPageBoxes sets the boxes of page that are set in boxes, after checking
+// that they fit on the page
+func (f *Fpdf) addPageBoxes(page int, boxes PageBoxes) {
+	w, h := f.pageSizeUnits(page)
+	const tol = 1e-9
+	list := boxes.list()
+	// inside reports whether the box a lies within the box b
+	inside := func(a, b *PageBox) bool {
+		return a.X >= b.X-tol && a.Y >= b.Y-tol && a.X+a.Wd <= b.X+b.Wd+tol && a.Y+a.Ht <= b.Y+b.Ht+tol
+	}
+	media := PageBox{SizeType{Wd: w, Ht: h}, PointType{}}
+	for j, box := range list {
+		name := pageBoxNames[j]
+		switch {
+		case box.Wd == 0 && box.Ht == 0:
+			continue
+		case box.Wd <= 0 || box.Ht <= 0:
+			f.err = fmt.Errorf("%s of page %d has a width or height that is not positive", name, page)
+		case !inside(box, &media):
+			f.err = fmt.Errorf("%s of page %d extends beyond the page", name, page)
+		case (box == &boxes.Trim || box == &boxes.Art) && boxes.Bleed.Wd > 0 && !inside(box, &boxes.Bleed):
+			f.err = fmt.Errorf("%s of page %d extends beyond its BleedBox", name, page)
+		}
+		if f.err != nil {
+			return
+		}
+	}
+	for j, box := range list {
+		if box.Wd > 0 {
+			f.pageBoxes[page][pageBoxNames[j]] = PageBox{
+				SizeType{Wd: (box.X + box.Wd) * f.k, Ht: (h - box.Y) * f.k},
+				PointType{X: box.X * f.k, Y: (h - box.Y - box.Ht) * f.k},
+			}
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package gofpdf
+
+import (
+	"fmt"
+)
+
+// PageBuilder renders pages apart from the document it has been created for,
+// so that the pages of a large document, such as a catalog, can be rendered
+// by several goroutines at once. A page builder has the methods of Fpdf with
+// which pages are added and drawn; its pages are added to the document with
+// AddBuiltPages().
+type PageBuilder struct {
+	*Fpdf
+}
+
+// NewPageBuilder returns a page builder for the document. It starts with the
+// page format, margins, fonts, images, colors and line and text settings of
+// the document, and has no header and footer functions; they can be set with
+// SetHeaderFunc() and SetFooterFunc() of the page builder.
+//
+// NewPageBuilder and AddBuiltPages() are called from the goroutine that
+// generates the document, and each page builder is used by a single
+// goroutine at a time. Page builders and the document can be used by
+// different goroutines at once, provided that the font loader set with
+// SetFontLoader() and the shaper set with SetShaper(), if any, can be.
+//
+// The AddBuiltPages example demonstrates this method.
+func (f *Fpdf) NewPageBuilder() *PageBuilder {
+	b := &PageBuilder{fpdfNew(f.defOrientation, f.unitStr, "", f.fontDirStr, f.defPageSize)}
+	if f.err != nil {
+		b.err = f.err
+		return b
+	}
+	for box, pb := range f.defPageBoxes {
+		b.defPageBoxes[box] = pb
+	}
+	b.defBoxes = f.defBoxes
+	b.pageFormat = f.pageFormat
+	b.fontpath = f.fontpath
+	b.fontLoader = f.fontLoader
+	// The runes used by UTF-8 fonts are copied, since the pages of the builder
+	// add to them
+	for key, font := range f.fonts {
+		if font.usedRunes != nil {
+			usedRunes := make(map[int]int, len(font.usedRunes))
+			for r, v := range font.usedRunes {
+				usedRunes[r] = v
+			}
+			font.usedRunes = usedRunes
+		}
+		b.fonts[key] = font
+		if font.i == f.currentFont.i {
+			b.currentFont = font
+		}
+	}
+	for key, file := range f.fontFiles {
+		b.fontFiles[key] = file
+	}
+	b.diffs = append(b.diffs, f.diffs...)
+	if f.fontFallbacks != nil {
+		b.fontFallbacks = make(map[string][]string, len(f.fontFallbacks))
+		for family, list := range f.fontFallbacks {
+			b.fontFallbacks[family] = list
+		}
+	}
+	for key, info := range f.images {
+		b.images[key] = info
+	}
+	// Blend modes, overprint settings, spot colors, ICC profiles and tiling
+	// patterns that the document has already are used by the pages of the
+	// builder with the same numbers
+	b.blendList = append(b.blendList[:0], f.blendList...)
+	for key, j := range f.blendMap {
+		b.blendMap[key] = j
+	}
+	for name, clr := range f.spotColorMap {
+		b.spotColorMap[name] = clr
+	}
+	b.overprintStates = append(b.overprintStates, f.overprintStates...)
+	b.overprint = f.overprint
+	b.patterns = f.patterns[:len(f.patterns):len(f.patterns)]
+	b.iccProfiles = f.iccProfiles[:len(f.iccProfiles):len(f.iccProfiles)]
+	b.kerning, b.hyphenator, b.shaper = f.kerning, f.hyphenator, f.shaper
+	b.textDirection, b.isRTL = f.textDirection, f.isRTL
+	b.aliasNbPagesStr, b.section = f.aliasNbPagesStr, f.section
+	b.counters = append([]counterType(nil), f.counters...)
+	b.userUnderlineThickness = f.userUnderlineThickness
+	b.lMargin, b.tMargin, b.rMargin, b.cMargin = f.lMargin, f.tMargin, f.rMargin, f.cMargin
+	b.SetAutoPageBreak(f.autoPageBreak, f.bMargin)
+	b.acceptPageBreak = func() bool {
+		return b.autoPageBreak
+	}
+	b.lineWidth, b.capStyle, b.joinStyle, b.miterLimit = f.lineWidth, f.capStyle, f.joinStyle, f.miterLimit
+	b.dashArray, b.dashPhase = append([]float64(nil), f.dashArray...), f.dashPhase
+	b.color, b.colorFlag = f.color, f.colorFlag
+	b.fontFamily, b.fontStyle, b.fontSizePt, b.fontSize = f.fontFamily, f.fontStyle, f.fontSizePt, f.fontSize
+	b.underline, b.strikeout = f.underline, f.strikeout
+	b.isCurrentUTF8 = f.isCurrentUTF8
+	b.charSpacing, b.wordSpacing, b.textRise = f.charSpacing, f.wordSpacing, f.textRise
+	return b
+}
+
+// AddBuiltPages adds the pages rendered with the page builders to the
+// document, in the order of builders, after the page of the document that
+// has been added last, whose footer is printed first. The document has no
+// current page afterwards; drawing continues on a page added with
+// AddPage().
+//
+// The pages of a page builder can hold text, drawings, images, templates and
+// links. Internal links created with AddLink() of a page builder lead to its
+// own pages. Transparency, blend modes, spot colors, ICC profiles and tiling
+// patterns can be used if the document has used or created them before the
+// page builder was created. Features that need resources of the document, such as gradients,
+// soft masks, transparency groups, layers, bookmarks, form fields and
+// attachments, cannot be used on the pages of page builders.
+//
+// The AddBuiltPages example demonstrates this method.
+func (f *Fpdf) AddBuiltPages(builders ...*PageBuilder) {
+	if f.err != nil {
+		return
+	}
+	for _, b := range builders {
+		if b.state == 3 {
+			f.err = fmt.Errorf("pages of page builder have been added already")
+			return
+		}
+		b.closeBuiltPage()
+		if b.err != nil {
+			f.err = b.err
+			return
+		}
+		if feature := f.builtPageFeature(b); feature != "" {
+			f.err = fmt.Errorf("%s cannot be used on the pages of a page builder", feature)
+			return
+		}
+	}
+	if f.state == 0 {
+		f.open()
+	}
+	f.closeBuiltPage()
+	for _, b := range builders {
+		base := f.page
+		f.mergeAliasShifts(b)
+		links := make([]int, len(b.links))
+		for j, link := range b.links[1:] {
+			if link.page > 0 {
+				link.page += base
+			}
+			f.links = append(f.links, link)
+			links[j+1] = len(f.links) - 1
+		}
+		for n := 1; n <= b.page; n++ {
+			f.page++
+			f.pages = append(f.pages, b.pages[n])
+			pageLinks := make([]linkType, len(b.pageLinks[n]))
+			for j, link := range b.pageLinks[n] {
+				link.link = links[link.link]
+				pageLinks[j] = link
+			}
+			f.pageLinks = append(f.pageLinks, pageLinks)
+			f.pageAttachments = append(f.pageAttachments, []annotationAttach{})
+			f.pageBoxes[f.page] = b.pageBoxes[n]
+			if size, ok := b.pageSizes[n]; ok {
+				f.pageSizes[f.page] = size
+			}
+			if rotate, ok := b.pageRotations[n]; ok {
+				f.pageRotations[f.page] = rotate
+			}
+			if ht, ok := b.autoPageHeights[n]; ok {
+				f.autoPageHeights[f.page] = ht
+			}
+			f.pageSections[f.page] = f.sectionNo
+		}
+		f.mergeFonts(b.fonts, b.fontFiles)
+		// The alias of the total number of pages that the headers and footers of
+		// the builder may have set is replaced in the document
+		if f.aliasNbPagesStr == "" {
+			f.aliasNbPagesStr = b.aliasNbPagesStr
+		}
+		// Images are referred to by their hashes, whatever their names
+		existingImages := make(map[string]bool, len(f.images))
+		for _, info := range f.images {
+			existingImages[info.i] = true
+		}
+		for name, info := range b.images {
+			if existingImages[info.i] {
+				continue
+			}
+			if _, taken := f.images[name]; taken {
+				name = "b" + info.i
+			}
+			f.images[name] = info
+			existingImages[info.i] = true
+		}
+		for i := range b.imagesUsed {
+			f.imagesUsed[i] = true
+		}
+		for i := range b.imagesInline {
+			f.imagesInline[i] = true
+		}
+		for id, t := range b.templates {
+			f.templates[id] = t
+		}
+		b.state = 3
+	}
+	f.pageClosed = true
+}
+
+// closeBuiltPage prints the footnotes and the footer of the current page, if
+// any, and closes it
+func (f *Fpdf) closeBuiltPage() {
+	if f.state != 2 {
+		return
+	}
+	f.putFootnotes()
+	for len(f.footnotes.pending) > 0 && f.err == nil {
+		f.AddPage()
+		f.putFootnotes()
+	}
+	if !f.pageClosed {
+		f.inFooter = true
+		if f.footerFnc != nil {
+			f.footerFnc()
+		} else if f.footerFncLpi != nil {
+			f.footerFncLpi(false)
+		}
+		f.inFooter = false
+	}
+	f.endpage()
+	f.pageClosed = true
+}
+
+// builtPageFeature returns the name of a feature that the pages of the page
+// builder b use and that cannot be added to the document, if any
+func (f *Fpdf) builtPageFeature(b *PageBuilder) string {
+	for j, mode := range b.blendList {
+		if j >= len(f.blendList) || mode.strokeStr != f.blendList[j].strokeStr ||
+			mode.fillStr != f.blendList[j].fillStr || mode.modeStr != f.blendList[j].modeStr {
+			return "transparency and blend modes not used by the document"
+		}
+	}
+	for j, st := range b.overprintStates {
+		if j >= len(f.overprintStates) || st.stroke != f.overprintStates[j].stroke ||
+			st.fill != f.overprintStates[j].fill || st.mode != f.overprintStates[j].mode {
+			return "overprint settings not used by the document"
+		}
+	}
+	for name, clr := range b.spotColorMap {
+		if docClr, ok := f.spotColorMap[name]; !ok || docClr.id != clr.id {
+			return "spot colors not used by the document"
+		}
+	}
+	attachments := len(b.attachments) > 0
+	for _, list := range b.pageAttachments {
+		attachments = attachments || len(list) > 0
+	}
+	switch {
+	case len(b.gradientList) > 1:
+		return "gradients"
+	case len(b.patterns) > len(f.patterns):
+		return "tiling patterns"
+	case len(b.iccProfiles) > len(f.iccProfiles):
+		return "ICC profiles"
+	case len(b.softMasks) > 0:
+		return "soft masks"
+	case len(b.groups) > 0:
+		return "transparency groups"
+	case len(b.layer.list) > 0:
+		return "layers"
+	case len(b.outlines) > 0 || len(b.tocEntries) > 0:
+		return "bookmarks"
+	case len(b.namedDests) > 0:
+		return "named destinations"
+	case len(b.form.fields) > 0:
+		return "form fields"
+	case attachments:
+		return "attachments"
+	case len(b.pageMarkups) > 0:
+		return "annotations"
+	case len(b.importedPages) > 0 || len(b.importedObjs) > 0:
+		return "imported pages"
+	case len(b.pageActions) > 0:
+		return "page actions"
+	case len(b.pageLabels) > 0:
+		return "page labels"
+	case len(b.articles) > 0:
+		return "article threads"
+	case b.structTree.tagged:
+		return "tagged content"
+	}
+	return ""
+}
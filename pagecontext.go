@@ -0,0 +1,111 @@
+package gofpdf
+
+// PageContext describes the page whose header or footer is printed by the
+// functions set with SetHeaderFuncEx() and SetFooterFuncEx().
+type PageContext struct {
+	// PageNo is the one-based number of the page, as returned by PageNo().
+	PageNo int
+	// TotalPages is the alias of the total number of pages, which is replaced
+	// with the number when the document is output. It is the alias set with
+	// AliasNbPages(), or "{nb}", which is then set as the alias.
+	TotalPages string
+	// Section is the title of the current section, set with SetSection().
+	Section string
+	// SectionPageNo is the one-based number of the page in its section, as
+	// returned by SectionPageNo().
+	SectionPageNo int
+	// PageSize is the size of the page in the unit of measure of the document.
+	PageSize SizeType
+	// LastPage is true if the footer of the last page of the document is
+	// printed; it is always false for headers.
+	LastPage bool
+}
+
+// SetSection sets the title of the current section of the document, such as
+// the title of a chapter, which is passed to the header and footer functions
+// set with SetHeaderFuncEx() and SetFooterFuncEx() so that running headers
+// can show it. The section of a page header is the section set when the page
+// is added. An empty string ends the current section.
+//
+// The SetHeaderFuncEx example demonstrates this method.
+func (f *Fpdf) SetSection(titleStr string) {
+	if titleStr != f.section {
+		f.sectionNo++
+	}
+	f.section = titleStr
+}
+
+// GetSection returns the title of the current section set with SetSection().
+func (f *Fpdf) GetSection() string {
+	return f.section
+}
+
+// SetHeaderFuncEx sets the function that lets the application render the page
+// header, like SetHeaderFunc(), but passes it the number and size of the page,
+// the total number of pages and the current section, so that headers such as
+// "Chapter 3 - page 12 of 90" can be printed without variables outside of the
+// function.
+//
+// The SetHeaderFuncEx example demonstrates this method.
+func (f *Fpdf) SetHeaderFuncEx(fnc func(ctx PageContext)) {
+	f.SetHeaderFunc(func() {
+		fnc(f.pageContext(false))
+	})
+}
+
+// SetFooterFuncEx sets the function that lets the application render the page
+// footer, like SetFooterFuncLpi(), but passes it the same description of the
+// page as SetHeaderFuncEx(), which tells whether the page is the last one.
+//
+// The SetHeaderFuncEx example demonstrates this method.
+func (f *Fpdf) SetFooterFuncEx(fnc func(ctx PageContext)) {
+	f.SetFooterFuncLpi(func(lastPage bool) {
+		fnc(f.pageContext(lastPage))
+	})
+}
+
+// SetHeaderFuncOddEven sets the functions that render the headers of odd and
+// even pages, like SetHeaderFuncEx(), so that book-style documents can place
+// running headers at the outer edge of each page. A nil function prints no
+// header on its pages.
+//
+// The SetMarginsMirrored example demonstrates this method.
+func (f *Fpdf) SetHeaderFuncOddEven(odd, even func(ctx PageContext)) {
+	f.SetHeaderFuncEx(oddEven(odd, even))
+}
+
+// SetFooterFuncOddEven sets the functions that render the footers of odd and
+// even pages, like SetFooterFuncEx(), so that page numbers can alternate
+// between the outer edges of the pages. A nil function prints no footer on
+// its pages.
+//
+// The SetMarginsMirrored example demonstrates this method.
+func (f *Fpdf) SetFooterFuncOddEven(odd, even func(ctx PageContext)) {
+	f.SetFooterFuncEx(oddEven(odd, even))
+}
+
+// oddEven returns a function that calls odd or even by the page number of its
+// context
+func oddEven(odd, even func(ctx PageContext)) func(ctx PageContext) {
+	return func(ctx PageContext) {
+		fnc := odd
+		if ctx.PageNo%2 == 0 {
+			fnc = even
+		}
+		if fnc != nil {
+			fnc(ctx)
+		}
+	}
+}
+
+// pageContext returns the description of the current page
+func (f *Fpdf) pageContext(lastPage bool) (ctx PageContext) {
+	if f.aliasNbPagesStr == "" {
+		f.AliasNbPages("")
+	}
+	ctx.PageNo, ctx.TotalPages, ctx.Section = f.page, f.aliasNbPagesStr, f.section
+	ctx.SectionPageNo = f.SectionPageNo()
+	ctx.PageSize = SizeType{Wd: f.w, Ht: f.h}
+	ctx.LastPage = lastPage
+	return
+}
@@ -0,0 +1,92 @@
+package gofpdf
+
+import (
+	"fmt"
+	"strings"
+)
+
+// pageFormatType is the orientation and size of the pages added with
+// AddPage()
+type pageFormatType struct {
+	orientation string // "P" or "L"; empty for the default of the document
+	size        SizeType
+}
+
+// SetDefaultPageFormat sets the orientation and size of the pages that are
+// added with AddPage() from now on, for example to switch to landscape pages
+// for a section of wide tables and back. orientationStr and size are the same
+// as for AddPageFormat(). The pages added earlier keep their format, and
+// headers, footers and coordinates follow the format of each page. An error
+// occurs if orientationStr is not valid or if size is not positive.
+//
+// The SetPageRotation example demonstrates this method.
+func (f *Fpdf) SetDefaultPageFormat(orientationStr string, size SizeType) {
+	if f.err != nil {
+		return
+	}
+	switch strings.ToLower(orientationStr) {
+	case "p", "portrait":
+		orientationStr = "P"
+	case "l", "landscape":
+		orientationStr = "L"
+	default:
+		f.err = fmt.Errorf("incorrect orientation: %s", orientationStr)
+		return
+	}
+	if size.Wd <= 0 || size.Ht <= 0 {
+		f.err = fmt.Errorf("page size must be positive: %.2f x %.2f", size.Wd, size.Ht)
+		return
+	}
+	f.pageFormat = pageFormatType{orientation: orientationStr, size: size}
+}
+
+// SetPageRotation sets the page page, which is one-based, to be rotated
+// clockwise by degrees when it is displayed or printed, a multiple of 90.
+// Only the presentation of the page is rotated; its content is still drawn
+// in the coordinates of the page as it was added, so that a wide table can
+// be laid out on a landscape page that is shown in portrait orientation, or
+// the reverse. The rotation of flushed pages can be set up to the time the
+// document is closed. An error occurs if page does not exist or if degrees is
+// not a multiple of 90.
+//
+// The SetPageRotation example demonstrates this method.
+func (f *Fpdf) SetPageRotation(page int, degrees int) {
+	if f.err != nil {
+		return
+	}
+	if page < 1 || page >= len(f.pages) {
+		f.err = fmt.Errorf("page %d does not exist", page)
+		return
+	}
+	if degrees%90 != 0 {
+		f.err = fmt.Errorf("page rotation must be a multiple of 90 degrees: %d", degrees)
+		return
+	}
+	degrees = (degrees%360 + 360) % 360
+	if degrees == 0 {
+		delete(f.pageRotations, page)
+	} else {
+		f.pageRotations[page] = degrees
+	}
+}
+
+// GetPageRotation returns the rotation of the page page, which is one-based,
+// in degrees clockwise, as set with SetPageRotation().
+func (f *Fpdf) GetPageRotation(page int) int {
+	return f.pageRotations[page]
+}
+
+// selectPage makes page the current page, with the size of that page, so that
+// positions on it are converted with its height
+func (f *Fpdf) selectPage(page int) {
+	f.page = page
+	f.w, f.h = f.pageSizeUnits(page)
+	f.wPt, f.hPt = f.w*f.k, f.h*f.k
+	f.pageBreakTrigger = f.h - f.bMargin
+	// Page breaks continue with pages of the format of this page
+	if f.w > f.h {
+		f.curOrientation, f.curPageSize = "L", SizeType{Wd: f.h, Ht: f.w}
+	} else {
+		f.curOrientation, f.curPageSize = "P", SizeType{Wd: f.w, Ht: f.h}
+	}
+}
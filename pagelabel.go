@@ -0,0 +1,107 @@
+package gofpdf
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// PageLabelStyle is the numbering style of page labels set with
+// SetPageLabel().
+type PageLabelStyle string
+
+// Numbering styles of page labels
+const (
+	// PageLabelNone labels pages with the prefix only.
+	PageLabelNone PageLabelStyle = ""
+	// PageLabelDecimal numbers pages 1, 2, 3 and so on.
+	PageLabelDecimal PageLabelStyle = "D"
+	// PageLabelRomanUpper numbers pages I, II, III and so on.
+	PageLabelRomanUpper PageLabelStyle = "R"
+	// PageLabelRomanLower numbers pages i, ii, iii and so on.
+	PageLabelRomanLower PageLabelStyle = "r"
+	// PageLabelLettersUpper numbers pages A to Z, then AA to ZZ and so on.
+	PageLabelLettersUpper PageLabelStyle = "A"
+	// PageLabelLettersLower numbers pages a to z, then aa to zz and so on.
+	PageLabelLettersLower PageLabelStyle = "a"
+)
+
+type pageLabelType struct {
+	style       PageLabelStyle
+	prefix      string
+	firstNumber int
+}
+
+// SetPageLabel sets the labels that the document reader displays for the
+// pages, for example in its page indicator, from page startPage on, up to the
+// next page for which labels are set. Pages are numbered in the numbering
+// style specified by style, starting with firstNumber, and prefix precedes
+// each number. Pages before the first page with labels are numbered 1, 2, 3
+// and so on. startPage is the one-based number of the page in the document;
+// labels can be set for pages that have not been added yet.
+//
+// For example, front matter can be numbered i, ii, iii with PageLabelRomanLower
+// and the pages after it restart at 1 with PageLabelDecimal.
+//
+// The SetPageLabel example demonstrates this method.
+func (f *Fpdf) SetPageLabel(startPage int, style PageLabelStyle, prefix string, firstNumber int) {
+	if f.err != nil {
+		return
+	}
+	switch style {
+	case PageLabelNone, PageLabelDecimal, PageLabelRomanUpper, PageLabelRomanLower,
+		PageLabelLettersUpper, PageLabelLettersLower:
+	default:
+		f.err = fmt.Errorf("invalid page label style %q", style)
+		return
+	}
+	if startPage < 1 {
+		f.err = fmt.Errorf("invalid start page %d of page labels", startPage)
+		return
+	}
+	if firstNumber < 1 {
+		f.err = fmt.Errorf("invalid first number %d of page labels", firstNumber)
+		return
+	}
+	if f.pageLabels == nil {
+		f.pageLabels = make(map[int]pageLabelType)
+	}
+	f.pageLabels[startPage] = pageLabelType{style: style, prefix: prefix, firstNumber: firstNumber}
+}
+
+// putPageLabels writes the /PageLabels number tree of the catalog, whose keys
+// are zero-based page indices
+func (f *Fpdf) putPageLabels() {
+	var pages []int
+	for page := range f.pageLabels {
+		if page <= f.page {
+			pages = append(pages, page)
+		}
+	}
+	if len(pages) == 0 {
+		return
+	}
+	sort.Ints(pages)
+	var s fmtBuffer
+	s.printf("/PageLabels <</Nums [")
+	if pages[0] > 1 {
+		// The number tree starts with the first page
+		s.printf("0 <</S /D>> ")
+	}
+	for _, page := range pages {
+		label := f.pageLabels[page]
+		var entries []string
+		if label.style != PageLabelNone {
+			entries = append(entries, sprintf("/S /%s", label.style))
+		}
+		if label.prefix != "" {
+			entries = append(entries, "/P "+f.textstring(utf8toutf16(label.prefix)))
+		}
+		if label.firstNumber != 1 {
+			entries = append(entries, sprintf("/St %d", label.firstNumber))
+		}
+		s.printf("%d <<%s>> ", page-1, strings.Join(entries, " "))
+	}
+	s.printf("]>>")
+	f.out(s.String())
+}
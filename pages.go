@@ -0,0 +1,302 @@
+package gofpdf
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// MovePage moves the page from, which is one-based, to position to in the
+// document; the pages between them move up or down by one, as when a cover
+// page printed last is moved to the front. Links, bookmarks, named
+// destinations, form fields and the other references to pages follow the
+// pages. If the page being written is moved, its footer is printed first and
+// drawing continues on a page added with AddPage(); otherwise the current page
+// stays the same page. Page numbers that have already been printed on pages
+// remain as they were printed. An error occurs if from or to is not a page of
+// the document.
+//
+// The MovePage example demonstrates this method.
+func (f *Fpdf) MovePage(from, to int) {
+	if f.err != nil {
+		return
+	}
+	count := f.PageCount()
+	if from < 1 || from > count || to < 1 || to > count {
+		f.err = fmt.Errorf("cannot move page %d to position %d of %d pages", from, to, count)
+		return
+	}
+	if from == to {
+		return
+	}
+	if f.state == 2 && !f.pageClosed && f.openPage() == from {
+		f.closeBuiltPage()
+		if f.err != nil {
+			return
+		}
+	}
+	f.remapPages(func(page int) int {
+		switch {
+		case page == from:
+			return to
+		case from < to && page > from && page <= to:
+			return page - 1
+		case to < from && page >= to && page < from:
+			return page + 1
+		}
+		return page
+	})
+}
+
+// DeletePage removes the page n, which is one-based, from the document, for
+// example an empty page at the end of a document. The following pages move
+// up by one. Links, bookmarks and named destinations that lead to the page
+// lead to the page that follows it instead, or to the last page. If the page
+// being written is deleted, it is dropped without its footer, and drawing
+// continues on a page added with AddPage().
+//
+// An error occurs if n is not a page of the document, if it is the only page,
+// if it has form fields or the signature field, if it has been flushed to an
+// output writer, or if a clipping operation, transformation or saved graphics
+// state begun on it has not ended.
+//
+// The MovePage example demonstrates this method.
+func (f *Fpdf) DeletePage(n int) {
+	if f.err != nil {
+		return
+	}
+	count := f.PageCount()
+	switch {
+	case n < 1 || n > count:
+		f.err = fmt.Errorf("page %d does not exist", n)
+	case count == 1:
+		f.err = fmt.Errorf("the only page of the document cannot be deleted")
+	case f.formWidgetCount(n) > 0 || f.signature.page == n:
+		f.err = fmt.Errorf("page %d with form fields cannot be deleted", n)
+	case f.pageFlushed(n):
+		f.err = fmt.Errorf("page %d has been flushed and cannot be deleted", n)
+	}
+	for _, nest := range f.nests {
+		if nest.page == n && f.err == nil {
+			f.err = fmt.Errorf("page %d has clipping, transformations or graphics states that have not ended", n)
+		}
+	}
+	if f.err != nil {
+		return
+	}
+	if f.state == 2 && !f.pageClosed && f.openPage() == n {
+		// The page is dropped without its footer
+		f.selectPage(n)
+		f.pageClosed = true
+		f.endpage()
+	}
+	f.remapPages(func(page int) int {
+		switch {
+		case page == n:
+			return 0
+		case page > n:
+			return page - 1
+		}
+		return page
+	})
+}
+
+// InsertPageAfter adds a new page like AddPage(), printing the footer of the
+// page being written and the header of the new page, but places it after the
+// page n, which is one-based, or at the front of the document if n is 0,
+// instead of after the last page. The pages after n move down by one. It
+// returns the number of the new page, which is the current page; the pages
+// added with AddPage() afterwards follow the last page of the document.
+//
+// The MovePage example demonstrates this method.
+func (f *Fpdf) InsertPageAfter(n int) int {
+	if f.err != nil {
+		return 0
+	}
+	if n < 0 || n > f.PageCount() {
+		f.err = fmt.Errorf("page %d does not exist", n)
+		return 0
+	}
+	f.insertAt = n + 1
+	f.AddPage()
+	f.insertAt = 0
+	return f.page
+}
+
+// openPage returns the page that is being written, whose footer has not been
+// printed yet: the page added last, unless it has been placed elsewhere
+func (f *Fpdf) openPage() int {
+	if f.insertedPage > 0 {
+		return f.insertedPage
+	}
+	return len(f.pages) - 1
+}
+
+// placeNewPage moves the page that has just been added to the position set by
+// InsertPageAfter(), if any
+func (f *Fpdf) placeNewPage() {
+	to, last := f.insertAt, f.page
+	if to == 0 || to == last {
+		return
+	}
+	f.remapPages(func(page int) int {
+		switch {
+		case page == last:
+			return to
+		case page >= to:
+			return page + 1
+		}
+		return page
+	})
+}
+
+// remapPages renumbers the pages of the document with move, which returns the
+// new number of each page, or 0 for a page that is removed, and updates the
+// references to pages. References to a removed page refer to the page that
+// follows it, or to the last page.
+func (f *Fpdf) remapPages(move func(page int) int) {
+	last := len(f.pages) - 1
+	open := 0
+	if f.state == 2 && !f.pageClosed {
+		open = f.openPage()
+	}
+	count := 0
+	for p := 1; p <= last; p++ {
+		if move(p) > 0 {
+			count++
+		}
+	}
+	// ref returns the new number of the page that a reference to page leads to
+	ref := func(page int) int {
+		for p := page; p <= last; p++ {
+			if q := move(p); q > 0 {
+				return q
+			}
+		}
+		return count
+	}
+	pages := make([]*bytes.Buffer, count+1)
+	pageLinks := make([][]linkType, count+1)
+	pageAttachments := make([][]annotationAttach, count+1)
+	pages[0], pageLinks[0], pageAttachments[0] = f.pages[0], f.pageLinks[0], f.pageAttachments[0]
+	for p := 1; p <= last; p++ {
+		if q := move(p); q > 0 {
+			pages[q], pageLinks[q], pageAttachments[q] = f.pages[p], f.pageLinks[p], f.pageAttachments[p]
+		}
+	}
+	f.pages, f.pageLinks, f.pageAttachments = pages, pageLinks, pageAttachments
+	pageSizes := make(map[int]SizeType)
+	for p, size := range f.pageSizes {
+		if q := move(p); q > 0 {
+			pageSizes[q] = size
+		}
+	}
+	f.pageSizes = pageSizes
+	pageRotations := make(map[int]int)
+	for p, rotate := range f.pageRotations {
+		if q := move(p); q > 0 {
+			pageRotations[q] = rotate
+		}
+	}
+	f.pageRotations = pageRotations
+	autoPageHeights := make(map[int]float64)
+	for p, ht := range f.autoPageHeights {
+		if q := move(p); q > 0 {
+			autoPageHeights[q] = ht
+		}
+	}
+	f.autoPageHeights = autoPageHeights
+	pageSections := make(map[int]int)
+	for p, section := range f.pageSections {
+		if q := move(p); q > 0 {
+			pageSections[q] = section
+		}
+	}
+	f.pageSections = pageSections
+	pageBoxes := make(map[int]map[string]PageBox)
+	for p, boxes := range f.pageBoxes {
+		if q := move(p); q > 0 {
+			pageBoxes[q] = boxes
+		}
+	}
+	f.pageBoxes = pageBoxes
+	pageActions := make(map[int]map[string]Action)
+	for p, actions := range f.pageActions {
+		if q := move(p); q > 0 {
+			pageActions[q] = actions
+		}
+	}
+	f.pageActions = pageActions
+	importedAnnots := make(map[int][]string)
+	for p, annots := range f.importedAnnots {
+		if q := move(p); q > 0 {
+			importedAnnots[q] = annots
+		}
+	}
+	f.importedAnnots = importedAnnots
+	pageMarkups := make(map[int][]markupAnnotType)
+	for p, markups := range f.pageMarkups {
+		if q := move(p); q > 0 {
+			pageMarkups[q] = markups
+		}
+	}
+	f.pageMarkups = pageMarkups
+	if f.outStream != nil {
+		contents := make(map[int]int)
+		for p, obj := range f.outStream.contents {
+			contents[move(p)] = obj
+		}
+		f.outStream.contents = contents
+	}
+	// Page labels start at positions in the document rather than at pages
+	for p := range f.pageLabels {
+		if p > count {
+			delete(f.pageLabels, p)
+		}
+	}
+	for j := range f.links {
+		if f.links[j].page > 0 {
+			f.links[j].page = ref(f.links[j].page)
+		}
+	}
+	for name, dest := range f.namedDests {
+		dest.page = ref(dest.page)
+		f.namedDests[name] = dest
+	}
+	for j := range f.articles {
+		beads := f.articles[j].beads[:0]
+		for _, bead := range f.articles[j].beads {
+			if bead.page = move(bead.page); bead.page > 0 {
+				beads = append(beads, bead)
+			}
+		}
+		f.articles[j].beads = beads
+	}
+	for j := range f.outlines {
+		f.outlines[j].p = ref(f.outlines[j].p)
+	}
+	for j := range f.form.fields {
+		for k := range f.form.fields[j].widgets {
+			f.form.fields[j].widgets[k].page = move(f.form.fields[j].widgets[k].page)
+		}
+	}
+	if f.signature.page > 0 {
+		f.signature.page = move(f.signature.page)
+	}
+	for j := range f.nests {
+		f.nests[j].page = move(f.nests[j].page)
+	}
+	f.structMovePages(move)
+	// The current page stays the same page if it is kept, and the page being
+	// written stays open
+	f.insertedPage = 0
+	if open > 0 && move(open) != count {
+		f.insertedPage = move(open)
+	}
+	if f.page > 0 {
+		page := move(f.page)
+		if page == 0 {
+			page = f.openPage()
+		}
+		f.selectPage(page)
+	}
+}
@@ -0,0 +1,567 @@
+package gofpdf
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// ParagraphStyle specifies the layout of a paragraph written with
+// WriteParagraph(). Distances are in the unit of measure specified in New().
+type ParagraphStyle struct {
+	// Leading is the distance between the baselines of successive lines. If
+	// it is zero, each line is 1.25 times as high as the largest font used in
+	// it.
+	Leading float64
+	// SpaceBefore and SpaceAfter are the vertical spaces above and below the
+	// paragraph.
+	SpaceBefore, SpaceAfter float64
+	// FirstLineIndent is the indentation of the first line from the left
+	// margin. It can be negative.
+	FirstLineIndent float64
+	// Alignment is "L", the default, "C" or "R" to align lines left, center
+	// or right, or "J" to justify all lines but the last one of the paragraph
+	// and the ones that end with an explicit line break.
+	Alignment string
+	// Orphans is the minimum number of lines of the paragraph that are left
+	// at the bottom of a page, and Widows the minimum number of lines that
+	// are carried over to the top of the next page. Zero means 2, and 1
+	// allows any page break.
+	Orphans, Widows int
+}
+
+// Span is a part of the text written with WriteStyled() that is printed
+// with its own font, color and link.
+type Span struct {
+	Text string
+	// FontFamily is the family of the font of the span, or empty for the
+	// current font family.
+	FontFamily string
+	// FontStyle is the style of the font of the span, as passed to
+	// SetFont(): a combination of "B", "I", "U" and "S", or empty for
+	// regular text.
+	FontStyle string
+	// SizeDelta is added to the current font size, in points, for the span.
+	SizeDelta float64
+	// Color is the text color of the span, or nil for the current text
+	// color.
+	Color *RGBType
+	// Link is a link identifier returned by AddLink() and URL an external
+	// link; the span is a link to Link if it is not zero, or else to URL if
+	// it is not empty.
+	Link int
+	URL  string
+}
+
+// textRun is a part of a paragraph that is printed with the same font, color
+// and link
+type textRun struct {
+	text    string
+	family  string
+	style   string // style of SetFont(), including "U" and "S"
+	size    float64
+	color   colorType
+	link    int
+	linkStr string
+}
+
+// textPiece is the part of a word that is printed with the font of a run
+type textPiece struct {
+	run   int
+	text  string
+	width float64
+}
+
+// textWord is a word of a paragraph, which may be printed with several fonts
+type textWord struct {
+	pieces []textPiece
+	width  float64
+	space  float64 // width of the space that follows the word
+	// spaceRun is the run of the space that follows the word, or -1 if it is
+	// followed by a line break or ends the paragraph
+	spaceRun  int
+	lineBreak bool // the word is followed by an explicit line break
+}
+
+// textLine is a line of a paragraph
+type textLine struct {
+	words   []textWord
+	indent  float64
+	width   float64 // width of the words and the spaces between them
+	size    float64 // largest font size of the line, in user units
+	height  float64
+	justify bool
+}
+
+// WriteParagraph writes txtStr as a paragraph with the layout of style,
+// starting at the current ordinate and filling the width between the left
+// and right margins. Lines are broken between words or, if a hyphenator has
+// been set with SetHyphenator(), within them; explicit line breaks start a
+// new line. A word that is wider than a line is broken where necessary.
+// Automatic page breaks keep the number of lines of style.Orphans at the
+// bottom and of style.Widows at the top of a page. The current position
+// after the call is at the left margin below the paragraph.
+//
+// The text is printed with the current font and text color. The style of
+// parts of it can be changed with the tags <b>, <i>, <u> and <s>, for bold,
+// italic, underlined and struck out text, which can be nested. Other text
+// between angle brackets is printed as it is.
+//
+// The WriteParagraph example demonstrates this method.
+func (f *Fpdf) WriteParagraph(style ParagraphStyle, txtStr string) {
+	if f.err != nil {
+		return
+	}
+	f.writeRuns(style, f.markupRuns(txtStr))
+}
+
+// WriteStyled writes spans of text with different fonts, colors and links as
+// one paragraph with the layout of style. Lines are broken as with
+// WriteParagraph(), between words of the same span or of different ones, and
+// each line is as high as the largest font used in it unless style.Leading
+// is set. Spans are printed as they are, without the tags of
+// WriteParagraph(). The current font and text color are unchanged after the
+// call.
+//
+// The WriteStyled example demonstrates this method.
+func (f *Fpdf) WriteStyled(style ParagraphStyle, spans []Span) {
+	if f.err != nil {
+		return
+	}
+	runs := make([]textRun, 0, len(spans))
+	for _, span := range spans {
+		run := textRun{
+			text:    span.Text,
+			family:  f.fontFamily,
+			style:   strings.ToUpper(span.FontStyle),
+			size:    f.fontSizePt + span.SizeDelta,
+			color:   f.color.text,
+			link:    span.Link,
+			linkStr: span.URL,
+		}
+		if span.FontFamily != "" {
+			run.family = span.FontFamily
+		}
+		if span.Color != nil {
+			run.color = rgbColorValue(span.Color.R, span.Color.G, span.Color.B, "g", "rg")
+		}
+		if run.size <= 0 {
+			f.err = fmt.Errorf("invalid font size %.2f of span %q", run.size, span.Text)
+			return
+		}
+		runs = append(runs, run)
+	}
+	f.writeRuns(style, runs)
+}
+
+// currentStyle returns the style of the current font, including the
+// underline and strike-out flags
+func (f *Fpdf) currentStyle() string {
+	style := f.fontStyle
+	if f.underline {
+		style += "U"
+	}
+	if f.strikeout {
+		style += "S"
+	}
+	return style
+}
+
+// markupRuns returns the runs of txtStr, in which the tags <b>, <i>, <u> and
+// <s> change the style of the current font
+func (f *Fpdf) markupRuns(txtStr string) (runs []textRun) {
+	base := f.currentStyle()
+	depth := make(map[byte]int)
+	var text strings.Builder
+	flush := func() {
+		if text.Len() == 0 {
+			return
+		}
+		style := ""
+		for _, flag := range []byte("BIUS") {
+			if depth[flag] > 0 || strings.IndexByte(base, flag) >= 0 {
+				style += string(flag)
+			}
+		}
+		runs = append(runs, textRun{text: text.String(), family: f.fontFamily, style: style,
+			size: f.fontSizePt, color: f.color.text})
+		text.Reset()
+	}
+	for len(txtStr) > 0 {
+		if txtStr[0] == '<' {
+			closing := strings.HasPrefix(txtStr, "</")
+			tag := txtStr[1:]
+			if closing {
+				tag = txtStr[2:]
+			}
+			if len(tag) >= 2 && tag[1] == '>' && strings.IndexByte("bius", tag[0]) >= 0 {
+				flush()
+				flag := tag[0] - 'a' + 'A'
+				if closing {
+					if depth[flag] > 0 {
+						depth[flag]--
+					}
+				} else {
+					depth[flag]++
+				}
+				txtStr = tag[2:]
+				continue
+			}
+		}
+		text.WriteByte(txtStr[0])
+		txtStr = txtStr[1:]
+	}
+	flush()
+	return
+}
+
+// useRun selects the font of run, if it is not the current font, and its
+// text color
+func (f *Fpdf) useRun(run *textRun) {
+	if f.fontFamily != run.family || f.currentStyle() != run.style || f.fontSizePt != run.size {
+		f.SetFont(run.family, run.style, run.size)
+	}
+	f.color.text = run.color
+	f.colorFlag = f.color.fill.str != f.color.text.str
+}
+
+// runWords splits runs into words and measures them
+func (f *Fpdf) runWords(runs []textRun) (words []textWord) {
+	word := textWord{spaceRun: -1}
+	endWord := func(spaceRun int, lineBreak bool) {
+		if len(word.pieces) == 0 {
+			// Spaces are collapsed, and dropped at the beginning and end of
+			// lines
+			if n := len(words); n > 0 && !words[n-1].lineBreak {
+				if lineBreak || spaceRun < 0 {
+					words[n-1].spaceRun, words[n-1].lineBreak = -1, lineBreak
+				}
+				return
+			}
+			if !lineBreak {
+				return
+			}
+		}
+		word.spaceRun, word.lineBreak = spaceRun, lineBreak
+		if lineBreak {
+			word.spaceRun = -1
+		}
+		words = append(words, word)
+		word = textWord{spaceRun: -1}
+	}
+	for r, run := range runs {
+		text := strings.Replace(run.text, "\r", "", -1)
+		for len(text) > 0 {
+			pos := strings.IndexAny(text, " \n")
+			if pos < 0 {
+				word.pieces = append(word.pieces, textPiece{run: r, text: text})
+				break
+			}
+			if pos > 0 {
+				word.pieces = append(word.pieces, textPiece{run: r, text: text[:pos]})
+			}
+			endWord(r, text[pos] == '\n')
+			text = text[pos+1:]
+		}
+	}
+	endWord(-1, false)
+	// Measure the pieces of the runs with their fonts
+	spaces := make([]float64, len(runs))
+	for r := range runs {
+		f.useRun(&runs[r])
+		spaces[r] = f.GetStringWidth(" ")
+		for j := range words {
+			for k := range words[j].pieces {
+				if piece := &words[j].pieces[k]; piece.run == r {
+					piece.width = f.GetStringWidth(piece.text)
+				}
+			}
+		}
+	}
+	for j := range words {
+		for _, piece := range words[j].pieces {
+			words[j].width += piece.width
+		}
+		if r := words[j].spaceRun; r >= 0 {
+			words[j].space = spaces[r]
+		}
+	}
+	return
+}
+
+// pieceChars returns the characters of piece, selecting its font
+func (f *Fpdf) pieceChars(runs []textRun, piece textPiece) []rune {
+	f.useRun(&runs[piece.run])
+	return f.textRunes(piece.text)
+}
+
+// splitWord breaks word before its nth character, adding a hyphen to the
+// first part if hyphen is true
+func (f *Fpdf) splitWord(runs []textRun, word textWord, n int, hyphen bool) (head, tail textWord) {
+	head = textWord{spaceRun: -1}
+	tail = word
+	tail.pieces = nil
+	tail.width = 0
+	for _, piece := range word.pieces {
+		chars := f.pieceChars(runs, piece)
+		switch {
+		case n <= 0:
+			tail.pieces = append(tail.pieces, piece)
+			tail.width += piece.width
+		case n >= len(chars):
+			head.pieces = append(head.pieces, piece)
+			head.width += piece.width
+		default:
+			first := textPiece{run: piece.run, text: f.lineText(chars[:n])}
+			first.width = f.GetStringWidth(first.text)
+			rest := textPiece{run: piece.run, text: f.lineText(chars[n:])}
+			rest.width = f.GetStringWidth(rest.text)
+			head.pieces = append(head.pieces, first)
+			head.width += first.width
+			tail.pieces = append(tail.pieces, rest)
+			tail.width += rest.width
+		}
+		n -= len(chars)
+	}
+	if hyphen && len(head.pieces) > 0 {
+		last := &head.pieces[len(head.pieces)-1]
+		f.useRun(&runs[last.run])
+		width := f.GetStringWidth(last.text + "-")
+		head.width += width - last.width
+		last.text += "-"
+		last.width = width
+	}
+	return
+}
+
+// fitWord returns the parts of word before and after the last point at which
+// it can be broken so that the first part fits in width. The word is broken
+// at the points given by the hyphenator or, if force is true and there are
+// none that fit, between any characters. The first part is empty if the
+// word cannot be broken.
+func (f *Fpdf) fitWord(runs []textRun, word textWord, width float64, force bool) (head, tail textWord) {
+	var chars []rune
+	for _, piece := range word.pieces {
+		chars = append(chars, f.pieceChars(runs, piece)...)
+	}
+	if len(word.pieces) > 0 {
+		f.useRun(&runs[word.pieces[0].run])
+	}
+	points := f.hyphenPoints(chars, 0)
+	for k := len(points) - 1; k >= 0; k-- {
+		if head, tail = f.splitWord(runs, word, points[k], true); head.width <= width {
+			return
+		}
+	}
+	head, tail = textWord{spaceRun: -1}, word
+	if force {
+		for n := 1; n < len(chars); n++ {
+			h, t := f.splitWord(runs, word, n, false)
+			if n > 1 && h.width > width {
+				break
+			}
+			head, tail = h, t
+		}
+	}
+	return
+}
+
+// breakLines breaks words into lines of at most width, the first of which is
+// indented by indent
+func (f *Fpdf) breakLines(runs []textRun, words []textWord, width, indent float64) (lines []textLine) {
+	line := textLine{indent: indent}
+	endLine := func(justify bool) {
+		line.justify = justify
+		lines = append(lines, line)
+		line = textLine{}
+	}
+	add := func(word textWord) {
+		if n := len(line.words); n > 0 {
+			line.width += line.words[n-1].space
+		}
+		line.words = append(line.words, word)
+		line.width += word.width
+	}
+	for j := 0; j < len(words); {
+		word := words[j]
+		avail := width - line.indent
+		if len(line.words) == 0 {
+			if word.width > avail && len(word.pieces) > 0 {
+				head, tail := f.fitWord(runs, word, avail, true)
+				if len(head.pieces) > 0 {
+					add(head)
+					endLine(true)
+					words[j] = tail
+					continue
+				}
+			}
+			add(word)
+		} else {
+			space := line.words[len(line.words)-1].space
+			if line.width+space+word.width > avail {
+				head, tail := f.fitWord(runs, word, avail-line.width-space, false)
+				if len(head.pieces) > 0 {
+					add(head)
+					words[j] = tail
+				}
+				endLine(true)
+				continue
+			}
+			add(word)
+		}
+		j++
+		if word.lineBreak {
+			endLine(false)
+		}
+	}
+	if len(line.words) > 0 || len(lines) == 0 {
+		endLine(false)
+	}
+	return
+}
+
+// writeRuns writes runs as a paragraph with the layout of style
+func (f *Fpdf) writeRuns(style ParagraphStyle, runs []textRun) {
+	if f.fontFamily == "" {
+		f.err = fmt.Errorf("font has not been set; unable to render text")
+		return
+	}
+	family, fontStyle, size := f.fontFamily, f.currentStyle(), f.fontSizePt
+	color, colorFlag := f.color.text, f.colorFlag
+	defer func() {
+		f.color.text, f.colorFlag = color, colorFlag
+	}()
+	if f.keepTogether {
+		f.BeginBlock()
+		defer f.EndBlock()
+	}
+	words := f.runWords(runs)
+	lines := f.breakLines(runs, words, f.w-f.rMargin-f.lMargin, style.FirstLineIndent)
+	if f.err != nil {
+		return
+	}
+	for j := range lines {
+		line := &lines[j]
+		line.size = size / f.k
+		for _, word := range line.words {
+			for _, piece := range word.pieces {
+				line.size = math.Max(line.size, runs[piece.run].size/f.k)
+			}
+		}
+		line.height = style.Leading
+		if line.height <= 0 {
+			line.height = 1.25 * line.size
+		}
+	}
+	orphans, widows := style.Orphans, style.Widows
+	if orphans <= 0 {
+		orphans = 2
+	}
+	if widows <= 0 {
+		widows = 2
+	}
+	f.y += style.SpaceBefore
+	topOfPage := false
+	for k := 0; k < len(lines); {
+		// Lines that fit on the page
+		m := 0
+		for y := f.y; k+m < len(lines) && y+lines[k+m].height <= f.pageBreakTrigger; m++ {
+			y += lines[k+m].height
+		}
+		if k+m == len(lines) || f.inHeader || f.inFooter {
+			m = len(lines) - k
+		} else {
+			if len(lines)-(k+m) < widows {
+				m = len(lines) - widows - k
+			}
+			if k == 0 && m < orphans {
+				m = 0
+			}
+			if m <= 0 && topOfPage {
+				// The page cannot hold the lines that are kept together
+				m = 1
+			}
+			m = max(m, 0)
+		}
+		for _, line := range lines[k : k+m] {
+			f.writeLine(runs, line, style.Alignment)
+		}
+		k += m
+		if k < len(lines) {
+			page, column := f.page, f.columns.current
+			if f.acceptBreak() {
+				f.AddPageFormat(f.curOrientation, f.curPageSize)
+				if f.err != nil {
+					return
+				}
+			} else if f.page == page && f.columns.current == column {
+				// The page is not broken, so the remaining lines follow
+				for _, line := range lines[k:] {
+					f.writeLine(runs, line, style.Alignment)
+				}
+				break
+			}
+			topOfPage = true
+		}
+	}
+	f.y += style.SpaceAfter
+	f.x = f.lMargin
+	if f.fontFamily != family || f.currentStyle() != fontStyle || f.fontSizePt != size {
+		f.SetFont(family, fontStyle, size)
+	}
+}
+
+// writeLine prints a line of a paragraph at the current ordinate and moves
+// below it
+func (f *Fpdf) writeLine(runs []textRun, line textLine, alignStr string) {
+	x := f.lMargin + line.indent
+	extra := f.w - f.rMargin - x - line.width
+	gap := 0.0
+	switch alignStr {
+	case "C":
+		x += extra / 2
+	case "R":
+		x += extra
+	case "J":
+		if line.justify && len(line.words) > 1 {
+			gap = extra / float64(len(line.words)-1)
+		}
+	}
+	y := f.y + .5*line.height + .3*line.size
+	for j, word := range line.words {
+		for _, piece := range word.pieces {
+			run := &runs[piece.run]
+			f.useRun(run)
+			f.Text(x, y, piece.text)
+			if run.link > 0 || run.linkStr != "" {
+				f.newLink(x, y-.8*f.fontSize, piece.width, f.fontSize, run.link, run.linkStr)
+			}
+			x += piece.width
+		}
+		if j == len(line.words)-1 {
+			break
+		}
+		width := word.space + gap
+		if r := word.spaceRun; r >= 0 && strings.ContainsAny(runs[r].style, "US") {
+			// Underline or strike out the space between words
+			f.useRun(&runs[r])
+			var s fmtBuffer
+			if f.colorFlag {
+				s.printf("q %s ", f.color.text.str)
+			}
+			if f.underline {
+				s.printf("%s ", f.underlineRect(x, y, width))
+			}
+			if f.strikeout {
+				s.printf("%s ", f.strikeoutRect(x, y, width))
+			}
+			if f.colorFlag {
+				s.printf("Q")
+			}
+			f.out(strings.TrimSpace(s.String()))
+		}
+		x += width
+	}
+	f.y += line.height
+	f.lasth = line.height
+}
@@ -0,0 +1,263 @@
+package gofpdf
+
+import (
+	"fmt"
+	"math"
+)
+
+// pathSegType is a segment of a path: a move ('m'), line ('l'), cubic Bézier
+// curve ('c'), rectangle ('r') or the closing of a subpath ('h')
+type pathSegType struct {
+	op  byte
+	pts [6]float64
+}
+
+// Path is a path made of one or more subpaths, built with its methods, which
+// return the path so that calls can be chained, and painted with Stroke(),
+// Fill(), FillEvenOdd(), FillStroke() and FillStrokeEvenOdd(), or used to clip
+// with Clip() and ClipEvenOdd(). Each method maps to a path construction or
+// painting operator of PDF. Unlike the path drawn with MoveTo() and DrawPath(),
+// a path can be painted any number of times, and building it does not change
+// the current position of the document.
+//
+// Positions are in the unit of measure of the document. The line width, line
+// cap and join styles, miter limit and dash pattern of the document can be
+// overridden for a single path with its SetLineWidth(), SetLineCapStyle(),
+// SetLineJoinStyle(), SetMiterLimit() and SetDashPattern() methods; these
+// settings are not used when the path clips.
+//
+// The NewPath example demonstrates this type.
+type Path struct {
+	f        *Fpdf
+	segs     []pathSegType
+	x, y     float64 // current point
+	sx, sy   float64 // start of the current subpath
+	hasPoint bool    // true if the path has a current point
+	style    string  // operators that override the line settings of the document
+}
+
+// NewPath returns an empty path of the document.
+func (f *Fpdf) NewPath() *Path {
+	return &Path{f: f}
+}
+
+// MoveTo starts a new subpath at (x, y).
+func (p *Path) MoveTo(x, y float64) *Path {
+	p.segs = append(p.segs, pathSegType{op: 'm', pts: [6]float64{x, y}})
+	p.x, p.y, p.sx, p.sy, p.hasPoint = x, y, x, y, true
+	return p
+}
+
+// LineTo adds a line from the current point to (x, y), which becomes the
+// current point. A subpath is started at (x, y) if the path has no current
+// point.
+func (p *Path) LineTo(x, y float64) *Path {
+	if !p.hasPoint {
+		return p.MoveTo(x, y)
+	}
+	p.segs = append(p.segs, pathSegType{op: 'l', pts: [6]float64{x, y}})
+	p.x, p.y = x, y
+	return p
+}
+
+// CurveTo adds a quadratic Bézier curve from the current point to (x, y),
+// with the control point (cx, cy).
+func (p *Path) CurveTo(cx, cy, x, y float64) *Path {
+	if !p.hasPoint {
+		p.MoveTo(cx, cy)
+	}
+	return p.CurveBezierCubicTo(p.x+(cx-p.x)*2/3, p.y+(cy-p.y)*2/3, x+(cx-x)*2/3, y+(cy-y)*2/3, x, y)
+}
+
+// CurveBezierCubicTo adds a cubic Bézier curve from the current point to
+// (x, y), with the control points (cx0, cy0) and (cx1, cy1).
+func (p *Path) CurveBezierCubicTo(cx0, cy0, cx1, cy1, x, y float64) *Path {
+	if !p.hasPoint {
+		p.MoveTo(cx0, cy0)
+	}
+	p.segs = append(p.segs, pathSegType{op: 'c', pts: [6]float64{cx0, cy0, cx1, cy1, x, y}})
+	p.x, p.y = x, y
+	return p
+}
+
+// ArcTo adds an elliptical arc centered at (x, y), with the horizontal and
+// vertical radii rx and ry, rotated by degRotate degrees, from the angle
+// degStart to the angle degEnd, in the same way as ArcTo() of Fpdf. A line
+// joins the current point to the start of the arc, or a subpath is started
+// there if the path has no current point.
+func (p *Path) ArcTo(x, y, rx, ry, degRotate, degStart, degEnd float64) *Path {
+	segments := int(math.Abs(degEnd-degStart)) / 60
+	if segments < 2 {
+		segments = 2
+	}
+	sin, cos := math.Sincos(degRotate * math.Pi / 180)
+	// point returns the point of the arc at angle t, and its derivative
+	point := func(t float64) (px, py, dx, dy float64) {
+		u, v := rx*math.Cos(t), ry*math.Sin(t)
+		du, dv := -rx*math.Sin(t), ry*math.Cos(t)
+		return x + u*cos - v*sin, y - (u*sin + v*cos), du*cos - dv*sin, -(du*sin + dv*cos)
+	}
+	start := degStart * math.Pi / 180
+	dt := (degEnd - degStart) * math.Pi / 180 / float64(segments)
+	x0, y0, dx0, dy0 := point(start)
+	if !p.hasPoint {
+		p.MoveTo(x0, y0)
+	} else if x0 != p.x || y0 != p.y {
+		p.LineTo(x0, y0)
+	}
+	for j := 1; j <= segments; j++ {
+		x1, y1, dx1, dy1 := point(start + float64(j)*dt)
+		p.CurveBezierCubicTo(x0+dx0*dt/3, y0+dy0*dt/3, x1-dx1*dt/3, y1-dy1*dt/3, x1, y1)
+		x0, y0, dx0, dy0 = x1, y1, dx1, dy1
+	}
+	return p
+}
+
+// Rect adds a closed subpath that is the rectangle of width w and height h
+// whose upper left corner is at (x, y). The current point is (x, y)
+// afterwards.
+func (p *Path) Rect(x, y, w, h float64) *Path {
+	p.segs = append(p.segs, pathSegType{op: 'r', pts: [6]float64{x, y, w, h}})
+	p.x, p.y, p.sx, p.sy, p.hasPoint = x, y, x, y, true
+	return p
+}
+
+// Close closes the current subpath with a line from the current point to the
+// start of the subpath, which becomes the current point. Lines that meet at
+// the start of a closed subpath are joined with the current line join style.
+func (p *Path) Close() *Path {
+	if p.hasPoint {
+		p.segs = append(p.segs, pathSegType{op: 'h'})
+		p.x, p.y = p.sx, p.sy
+	}
+	return p
+}
+
+// SetLineWidth sets the width of the lines of the path when it is drawn,
+// instead of the line width of the document.
+func (p *Path) SetLineWidth(width float64) *Path {
+	p.style += sprintf("%.2f w\n", width*p.f.k)
+	return p
+}
+
+// SetLineCapStyle sets the line cap style of the path, "butt", "round" or
+// "square", instead of the line cap style of the document.
+func (p *Path) SetLineCapStyle(styleStr string) *Path {
+	p.style += sprintf("%d J\n", lineCapStyle(styleStr))
+	return p
+}
+
+// SetLineJoinStyle sets the line join style of the path, "miter", "round" or
+// "bevel", instead of the line join style of the document.
+func (p *Path) SetLineJoinStyle(styleStr string) *Path {
+	p.style += sprintf("%d j\n", lineJoinStyle(styleStr))
+	return p
+}
+
+// SetMiterLimit sets the miter limit of the path instead of the miter limit
+// of the document, in the same way as SetMiterLimit() of Fpdf.
+func (p *Path) SetMiterLimit(limit float64) *Path {
+	if limit < 1 {
+		if p.f.err == nil {
+			p.f.err = fmt.Errorf("miter limit must be at least 1: %.2f", limit)
+		}
+		return p
+	}
+	p.style += sprintf("%.2f M\n", limit)
+	return p
+}
+
+// SetDashPattern sets the dash pattern of the path instead of the dash
+// pattern of the document, in the same way as SetDashPattern() of Fpdf. An
+// empty dashArray draws the path with solid lines.
+func (p *Path) SetDashPattern(dashArray []float64, dashPhase float64) *Path {
+	scaled := make([]float64, len(dashArray))
+	for i, value := range dashArray {
+		scaled[i] = value * p.f.k
+	}
+	p.style += dashPatternBuffer(scaled, dashPhase*p.f.k).String() + "\n"
+	return p
+}
+
+// Stroke draws the path with the current draw color, and with the line width
+// and line styles of the path, or else of the document. Subpaths that have
+// not been closed with Close() are left open.
+func (p *Path) Stroke() {
+	p.paint("S")
+}
+
+// Fill fills the path with the current fill color, using the nonzero winding
+// number rule: areas that subpaths surround in opposite directions are left
+// empty. Open subpaths are closed implicitly.
+func (p *Path) Fill() {
+	p.paint("f")
+}
+
+// FillEvenOdd fills the path with the current fill color, using the even-odd
+// rule: areas that an even number of subpaths surround are left empty,
+// whatever their direction. Open subpaths are closed implicitly.
+func (p *Path) FillEvenOdd() {
+	p.paint("f*")
+}
+
+// FillStroke fills the path like Fill() and then draws it like Stroke(), in a
+// single operation.
+func (p *Path) FillStroke() {
+	p.paint("B")
+}
+
+// FillStrokeEvenOdd fills the path like FillEvenOdd() and then draws it like
+// Stroke(), in a single operation.
+func (p *Path) FillStrokeEvenOdd() {
+	p.paint("B*")
+}
+
+// Clip begins a clipping operation in which rendering is confined to the
+// inside of the path, determined with the nonzero winding number rule. Call
+// ClipEnd() to restore unclipped operations.
+func (p *Path) Clip() {
+	p.f.clipBegin()
+	p.paint("q", "W n")
+}
+
+// ClipEvenOdd begins a clipping operation in which rendering is confined to
+// the inside of the path, determined with the even-odd rule. Call ClipEnd() to
+// restore unclipped operations.
+func (p *Path) ClipEvenOdd() {
+	p.f.clipBegin()
+	p.paint("q", "W* n")
+}
+
+// paint outputs the path followed by the painting operator op, or, if ops
+// has two elements, enclosed in them. The line settings of the path apply to
+// the path alone, and are left out when it clips, since the clipping operation
+// would keep them after the path.
+func (p *Path) paint(ops ...string) {
+	f := p.f
+	if f.err != nil {
+		return
+	}
+	var buf fmtBuffer
+	if len(ops) > 1 {
+		buf.printf("%s\n", ops[0])
+	} else if p.style != "" {
+		buf.printf("q\n%s", p.style)
+		defer f.out("Q")
+	}
+	for _, seg := range p.segs {
+		pts := seg.pts
+		switch seg.op {
+		case 'm', 'l':
+			buf.printf("%.2f %.2f %c\n", pts[0]*f.k, (f.h-pts[1])*f.k, seg.op)
+		case 'c':
+			buf.printf("%.5f %.5f %.5f %.5f %.5f %.5f c\n", pts[0]*f.k, (f.h-pts[1])*f.k,
+				pts[2]*f.k, (f.h-pts[3])*f.k, pts[4]*f.k, (f.h-pts[5])*f.k)
+		case 'r':
+			buf.printf("%.2f %.2f %.2f %.2f re\n", pts[0]*f.k, (f.h-pts[1])*f.k, pts[2]*f.k, -pts[3]*f.k)
+		case 'h':
+			buf.printf("h\n")
+		}
+	}
+	buf.printf("%s", ops[len(ops)-1])
+	f.out(buf.String())
+}
@@ -0,0 +1,184 @@
+package gofpdf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// PatternID identifies a tiling pattern created with CreatePattern()
+type PatternID int
+
+// PatternDrawer draws the cell of a tiling pattern with the methods of Fpdf.
+// Positions are relative to the upper left corner of the cell, in the unit of
+// measure of the document.
+type PatternDrawer struct {
+	*Fpdf
+}
+
+// patternType is a tiling pattern
+type patternType struct {
+	w, h    float64 // size of the cell in points
+	content []byte
+	objNum  int
+}
+
+// CreatePattern creates a tiling pattern whose cell, of width w and height h,
+// is drawn by draw with the methods of Fpdf, such as Line(), Circle(), Text()
+// and Image(). Shapes and cells filled with the pattern after SetFillPattern()
+// repeat the cell side by side, without the cost of an image of the whole
+// area, for example to hatch or dot an area or to tile it with a logo.
+//
+// The cell starts with the colors, line width and font of the document, which
+// are restored afterwards, as are the current position and page. Page breaks
+// do not occur in the cell, and AddPage(), links and annotations are not
+// supported. The pattern can be created before the first page is added.
+//
+// The pattern is returned, or 0 if an error occurs.
+//
+// The CreatePattern example demonstrates this method.
+func (f *Fpdf) CreatePattern(w, h float64, draw func(p *PatternDrawer)) PatternID {
+	if f.err != nil {
+		return 0
+	}
+	if w <= 0 || h <= 0 {
+		f.err = errors.New("pattern width and height must be positive")
+		return 0
+	}
+	c := f.beginCanvas(w, h, "the cell of a pattern")
+	f.x, f.y = 0, 0
+	draw(&PatternDrawer{f})
+	content := f.endCanvas(c)
+	if f.err != nil {
+		return 0
+	}
+	f.patterns = append(f.patterns, patternType{w: w * f.k, h: h * f.k, content: content})
+	return PatternID(len(f.patterns))
+}
+
+// SetFillPattern sets the current fill color to the tiling pattern id,
+// created with CreatePattern(), so that the areas filled by following drawing
+// operations, such as Rect(), Circle(), DrawPath() and CellFormat(), are
+// tiled with its cell. The cells are aligned with the lower left corner of
+// the page, whatever the position of the areas. Setting another fill color
+// ends the pattern. An error occurs if the pattern does not exist.
+//
+// The CreatePattern example demonstrates this method.
+func (f *Fpdf) SetFillPattern(id PatternID) {
+	if f.err != nil {
+		return
+	}
+	if id < 1 || int(id) > len(f.patterns) {
+		f.err = errors.New("pattern does not exist")
+		return
+	}
+	f.color.fill.mode = colorModePattern
+	f.color.fill.str = sprintf("/Pattern cs /TP%d scn", id)
+	f.colorFlag = f.color.fill.str != f.color.text.str
+	if f.page > 0 {
+		f.out(f.color.fill.str)
+	}
+}
+
+// canvasType holds the content of the current page and the settings of the
+// document while content is drawn into a separate stream, such as the cell of
+// a pattern or a soft mask
+type canvasType struct {
+	content            bytes.Buffer
+	what               string // what the content is, for errors
+	state, page, pages int
+	pageBuf            *bytes.Buffer
+	w, h, wPt, hPt     float64
+	x, y, lasth        float64
+	autoPageBreak      bool
+	gs                 graphicsStateType
+	nests              int
+	depth              int // number of streams begun before this one
+	group              int // transparency group drawn in the stream, if any
+}
+
+// beginCanvas puts the content of the current page aside and starts a
+// separate stream of width w and height h, in the unit of measure of the
+// document, in which drawing continues without page breaks. The stream starts
+// with the colors, line settings, font, alpha value and overprint settings of
+// the document.
+func (f *Fpdf) beginCanvas(w, h float64, what string) *canvasType {
+	c := &canvasType{what: what, state: f.state, page: f.page, pages: len(f.pages)}
+	c.pageBuf, c.w, c.h, c.wPt, c.hPt = f.pages[f.page], f.w, f.h, f.wPt, f.hPt
+	c.x, c.y, c.lasth, c.autoPageBreak = f.x, f.y, f.lasth, f.autoPageBreak
+	c.gs = f.graphicsState()
+	c.nests, c.depth = len(f.nests), f.canvasDepth
+	f.canvasDepth++
+	f.state, f.pages[f.page] = 2, &c.content
+	f.w, f.h, f.wPt, f.hPt = w, h, w*f.k, h*f.k
+	f.autoPageBreak = false
+	// The stream starts in the default graphics state of PDF
+	if f.currentFont.Name != "" {
+		f.outf("BT /F%s %.2f Tf ET", f.currentFont.i, f.fontSizePt)
+	}
+	f.outf("%d J %d j %.2f w %s %s", f.capStyle, f.joinStyle, f.lineWidth*f.k, f.color.draw.str, f.color.fill.str)
+	if f.miterLimit != 10 {
+		f.outf("%.2f M", f.miterLimit)
+	}
+	if len(f.dashArray) > 0 {
+		f.outputDashPattern()
+	}
+	if f.alpha != 1 || (f.blendMode != "" && f.blendMode != "Normal") {
+		f.SetAlpha(f.alpha, f.blendMode)
+	}
+	if f.overprint != (overprintType{}) {
+		f.outputOverprint()
+	}
+	return c
+}
+
+// endCanvas ends the clipping operations, transformations and saved graphics
+// states left active in the stream started by beginCanvas(), restores the
+// page and the settings of the document and returns the content of the stream
+func (f *Fpdf) endCanvas(c *canvasType) []byte {
+	for len(f.nests) > c.nests {
+		switch f.nests[len(f.nests)-1].kind {
+		case nestClip:
+			f.ClipEnd()
+		case nestTransform:
+			f.TransformEnd()
+		default:
+			f.RestoreGState()
+		}
+		if f.err != nil {
+			break
+		}
+	}
+	if len(f.pages) != c.pages && f.err == nil {
+		f.err = fmt.Errorf("AddPage() cannot be called in %s", c.what)
+	}
+	f.state, f.page, f.pages = c.state, c.page, f.pages[:c.pages]
+	f.pages[f.page] = c.pageBuf
+	f.w, f.h, f.wPt, f.hPt = c.w, c.h, c.wPt, c.hPt
+	f.x, f.y, f.lasth, f.autoPageBreak = c.x, c.y, c.lasth, c.autoPageBreak
+	f.setGraphicsState(&c.gs)
+	f.canvasDepth = c.depth
+	return c.content.Bytes()
+}
+
+// putPatterns writes the tiling patterns of the document
+func (f *Fpdf) putPatterns() {
+	filter := ""
+	if f.compress {
+		filter = "/Filter /" + f.filterName() + " "
+	}
+	for j, pt := range f.patterns {
+		content := pt.content
+		if f.compress {
+			content = f.compressStream(content)
+		}
+		f.newobj()
+		f.patterns[j].objNum = f.n
+		f.outf("<<%s/Type /Pattern /PatternType 1 /PaintType 1 /TilingType 1", filter)
+		f.outf("/BBox [0 0 %.5f %.5f] /XStep %.5f /YStep %.5f /Resources 2 0 R",
+			pt.w, pt.h, pt.w, pt.h)
+		f.outf("/Length %d >>", f.protect.streamLength(len(content)))
+		f.putstream(content)
+		f.out("endobj")
+	}
+}
@@ -0,0 +1,183 @@
+package gofpdf
+
+import (
+	"fmt"
+)
+
+// PDF/A conformance levels that can be passed to SetPDFAConformance. The
+// number is the part of ISO 19005 and the letter is the conformance level
+// within that part.
+const (
+	PDFA1B = "1B"
+	PDFA2B = "2B"
+	PDFA2U = "2U"
+	PDFA3B = "3B"
+	PDFA3U = "3U"
+)
+
+type pdfaType struct {
+	part        int    // 1, 2 or 3; 0 if conformance is not requested
+	conformance string // "B" or "U"
+}
+
+// SetPDFAConformance requests that the document be written in conformance with
+// the archival PDF/A standard at the specified level, one of PDFA1B, PDFA2B,
+// PDFA2U, PDFA3B or PDFA3U. PDFA3B is suitable for electronic invoices such as
+// ZUGFeRD and Factur-X that carry an XML attachment.
+//
+// In this mode the sRGB output intent is always included and, unless custom
+// metadata has been specified with SetXmpMetadata(), an XMP metadata stream
+// that mirrors the document information and identifies the PDF/A level is
+// generated. The PDF version of the document is raised as required by the
+// level.
+//
+// Some features are incompatible with PDF/A and cause an error to be
+// reported when the document is output: fonts that are not embedded (this
+// includes the standard core fonts such as Helvetica; use AddUTF8Font() or
+// AddFont() instead), encryption, JavaScript, and, for PDFA1B, transparency
+// and images with an alpha channel. With PDFA3B and PDFA3U attachments are
+// associated with the document by means of their Relationship field;
+// attachments are not permitted with the other levels.
+func (f *Fpdf) SetPDFAConformance(level string) {
+	if f.err != nil {
+		return
+	}
+	switch level {
+	case PDFA1B, PDFA2B, PDFA2U, PDFA3B, PDFA3U:
+		f.pdfa.part = int(level[0] - '0')
+		f.pdfa.conformance = level[1:]
+		f.addOutputIntent = true
+	default:
+		f.err = fmt.Errorf("unsupported PDF/A conformance level \"%s\"", level)
+	}
+}
+
+// pdfaPrepare verifies that the document can meet the requested PDF/A level
+// and arranges for the output intent and metadata. It is called when the
+// document is closed.
+func (f *Fpdf) pdfaPrepare() {
+	if f.pdfa.part == 0 {
+		return
+	}
+	if name := f.unembeddedFont(); name != "" {
+		f.err = fmt.Errorf("font %s is not embedded; PDF/A requires all fonts to be embedded", name)
+		return
+	}
+	switch {
+	case f.protect.encrypted:
+		f.err = fmt.Errorf("PDF/A does not permit encryption")
+	case f.javascript != nil || f.actionJavaScript():
+		f.err = fmt.Errorf("PDF/A does not permit JavaScript")
+	case f.pdfa.part < 3 && (len(f.attachments) > 0 || f.pageAttachmentCount() > 0):
+		f.err = fmt.Errorf("PDF/A-%d does not permit attachments; use PDF/A-3", f.pdfa.part)
+	}
+	if f.err != nil {
+		return
+	}
+	if f.pdfa.part == 1 {
+		for _, bl := range f.blendList[1:] {
+			if bl.fillStr != "1.000" || (bl.modeStr != "" && bl.modeStr != "Normal") {
+				f.err = fmt.Errorf("PDF/A-1 does not permit transparency")
+				return
+			}
+		}
+		if len(f.softMasks) > 0 || len(f.groups) > 0 {
+			f.err = fmt.Errorf("PDF/A-1 does not permit transparency")
+			return
+		}
+		for _, list := range f.pageMarkups {
+			for _, an := range list {
+				if an.subtype == "Highlight" || an.options.Color.Alpha != 1 {
+					f.err = fmt.Errorf("PDF/A-1 does not permit transparency, which highlights and translucent annotations need")
+					return
+				}
+			}
+		}
+		for _, img := range f.images {
+			if !f.imagesUsed[img.i] && !f.imagesInline[img.i] {
+				continue
+			}
+			if img.hasSMask() {
+				f.err = fmt.Errorf("PDF/A-1 does not permit images with an alpha channel")
+				return
+			}
+			if img.mask != nil && img.mask.bpc != 1 {
+				f.err = fmt.Errorf("PDF/A-1 does not permit soft masks")
+				return
+			}
+			if img.f == "JPXDecode" {
+				f.err = fmt.Errorf("PDF/A-1 does not permit JPEG 2000 images")
+				return
+			}
+			if img.bpc > 8 {
+				f.err = fmt.Errorf("PDF/A-1 does not permit images with 16 bits per component")
+				return
+			}
+		}
+		if f.pdfVersion < "1.4" {
+			f.pdfVersion = "1.4"
+		}
+	} else if f.pdfVersion < "1.7" {
+		f.pdfVersion = "1.7"
+	}
+	f.addOutputIntent = true
+}
+
+// unembeddedFont returns the name of the first font of the document, in the
+// order of their keys, that is not embedded, or an empty string if all fonts
+// are embedded
+func (f *Fpdf) unembeddedFont() string {
+	keyList := make([]string, 0, len(f.fonts))
+	for key := range f.fonts {
+		keyList = append(keyList, key)
+	}
+	gensort(len(keyList), func(a, b int) bool {
+		return keyList[a] < keyList[b]
+	}, func(a, b int) {
+		keyList[a], keyList[b] = keyList[b], keyList[a]
+	})
+	for _, key := range keyList {
+		font := f.fonts[key]
+		if font.Tp == "Core" || (font.Tp != "UTF8" && font.File == "") {
+			return font.Name
+		}
+	}
+	return ""
+}
+
+// pageAttachmentCount returns the number of attachment annotations in the
+// document
+func (f *Fpdf) pageAttachmentCount() (count int) {
+	for _, list := range f.pageAttachments {
+		count += len(list)
+	}
+	return
+}
+
+// pdfaPutCatalog writes the associated files entry of the catalog for
+// PDF/A-3. Both document attachments and the files of attachment annotations
+// are listed, each only once.
+func (f *Fpdf) pdfaPutCatalog() {
+	if f.pdfa.part != 3 || len(f.attachments)+f.pageAttachmentCount() == 0 {
+		return
+	}
+	var af fmtBuffer
+	listed := make(map[int]bool)
+	add := func(obj int) {
+		if !listed[obj] {
+			listed[obj] = true
+			af.printf("%d 0 R ", obj)
+		}
+	}
+	af.printf("/AF [")
+	for _, a := range f.attachments {
+		add(a.objectNumber)
+	}
+	for _, list := range f.pageAttachments {
+		for _, an := range list {
+			add(an.objectNumber)
+		}
+	}
+	af.printf("]")
+	f.out(af.String())
+}
@@ -0,0 +1,730 @@
+package gofpdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/ascii85"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+)
+
+// Objects of a parsed PDF document are represented by nil (null), bool, int,
+// float64, pdfObjName, pdfObjString, pdfObjArray, pdfObjDict, pdfObjRef and *pdfObjStream
+
+// pdfObjName is a name object, without the leading slash
+type pdfObjName string
+
+// pdfObjString is a string object
+type pdfObjString string
+
+// pdfObjArray is an array object
+type pdfObjArray []interface{}
+
+// pdfObjDict is a dictionary object
+type pdfObjDict map[string]interface{}
+
+// pdfObjRef is a reference to an indirect object
+type pdfObjRef struct {
+	num, gen int
+}
+
+// pdfObjStream is a stream object with its encoded data
+type pdfObjStream struct {
+	dict pdfObjDict
+	data []byte
+}
+
+// pdfXrefEntry is the location of an indirect object, either at an offset of
+// the file or in an object stream
+type pdfXrefEntry struct {
+	offset     int
+	compressed bool
+	stream     int // object number of the object stream
+	index      int // index of the object in the object stream
+}
+
+// pdfParser reads the objects of a PDF document
+type pdfParser struct {
+	data    []byte
+	pos     int
+	xref    map[int]pdfXrefEntry
+	trailer pdfObjDict
+	objs    map[int]interface{}   // cache of the objects read
+	objStms map[int][]interface{} // cache of the objects of object streams
+	depth   int                   // depth of nested object lookups
+}
+
+// newPdfParser parses the cross-reference information of the PDF document
+// data. If it is damaged, the objects are located by scanning the file.
+func newPdfParser(data []byte) (p *pdfParser, err error) {
+	p = &pdfParser{data: data, xref: make(map[int]pdfXrefEntry),
+		objs: make(map[int]interface{}), objStms: make(map[int][]interface{})}
+	if !bytes.HasPrefix(bytes.TrimLeft(data, "\x00\t\n\f\r "), []byte("%PDF-")) {
+		return nil, fmt.Errorf("not a PDF document")
+	}
+	if err = p.readXrefChain(); err != nil || p.trailer == nil || p.trailer["Root"] == nil {
+		p.xref = make(map[int]pdfXrefEntry)
+		p.trailer = nil
+		if err = p.rebuildXref(); err != nil {
+			return nil, err
+		}
+	}
+	if p.trailer["Encrypt"] != nil {
+		return nil, fmt.Errorf("encrypted PDF documents are not supported")
+	}
+	return p, nil
+}
+
+// readXrefChain reads the cross-reference sections, starting with the last
+// one
+func (p *pdfParser) readXrefChain() (err error) {
+	tail := p.data
+	if len(tail) > 2048 {
+		tail = tail[len(tail)-2048:]
+	}
+	j := bytes.LastIndex(tail, []byte("startxref"))
+	if j < 0 {
+		return fmt.Errorf("startxref not found")
+	}
+	p.pos = len(p.data) - len(tail) + j + len("startxref")
+	offset, ok := p.parseObject().(int)
+	if !ok {
+		return fmt.Errorf("invalid startxref")
+	}
+	visited := make(map[int]bool)
+	for offset > 0 && !visited[offset] {
+		visited[offset] = true
+		if offset >= len(p.data) {
+			return fmt.Errorf("invalid cross-reference offset %d", offset)
+		}
+		var trailer pdfObjDict
+		p.pos = offset
+		p.skipSpace()
+		if bytes.HasPrefix(p.data[p.pos:], []byte("xref")) {
+			p.pos += len("xref")
+			if trailer, err = p.readXrefTable(); err != nil {
+				return
+			}
+			if stm, ok := trailer["XRefStm"].(int); ok {
+				if _, err = p.readXrefStream(stm); err != nil {
+					return
+				}
+			}
+		} else if trailer, err = p.readXrefStream(offset); err != nil {
+			return
+		}
+		if p.trailer == nil {
+			p.trailer = trailer
+		}
+		offset, _ = trailer["Prev"].(int)
+	}
+	return
+}
+
+// readXrefTable reads a cross-reference table and returns the trailer that
+// follows it
+func (p *pdfParser) readXrefTable() (pdfObjDict, error) {
+	for {
+		p.skipSpace()
+		if bytes.HasPrefix(p.data[p.pos:], []byte("trailer")) {
+			p.pos += len("trailer")
+			trailer, ok := p.parseObject().(pdfObjDict)
+			if !ok {
+				return nil, fmt.Errorf("invalid trailer")
+			}
+			return trailer, nil
+		}
+		start, ok1 := p.parseObject().(int)
+		count, ok2 := p.parseObject().(int)
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("invalid cross-reference table")
+		}
+		for j := 0; j < count; j++ {
+			offset, ok1 := p.parseObject().(int)
+			_, ok2 := p.parseObject().(int)
+			kind := p.token()
+			if !ok1 || !ok2 || (kind != "n" && kind != "f") {
+				return nil, fmt.Errorf("invalid cross-reference entry")
+			}
+			if _, ok := p.xref[start+j]; !ok && kind == "n" && offset > 0 {
+				p.xref[start+j] = pdfXrefEntry{offset: offset}
+			} else if !ok && kind == "f" {
+				// Free entries hide older entries of the object
+				p.xref[start+j] = pdfXrefEntry{offset: -1}
+			}
+		}
+	}
+}
+
+// readXrefStream reads the cross-reference stream at offset and returns its
+// dictionary, which serves as the trailer
+func (p *pdfParser) readXrefStream(offset int) (pdfObjDict, error) {
+	_, obj, err := p.parseIndirect(offset)
+	if err != nil {
+		return nil, err
+	}
+	stm, ok := obj.(*pdfObjStream)
+	if !ok || stm.dict["Type"] != pdfObjName("XRef") {
+		return nil, fmt.Errorf("invalid cross-reference stream")
+	}
+	data, err := p.decodeStream(stm)
+	if err != nil {
+		return nil, err
+	}
+	w, _ := stm.dict["W"].(pdfObjArray)
+	if len(w) != 3 {
+		return nil, fmt.Errorf("invalid cross-reference stream")
+	}
+	var widths [3]int
+	for j := range widths {
+		widths[j], _ = w[j].(int)
+	}
+	index, _ := stm.dict["Index"].(pdfObjArray)
+	if index == nil {
+		size, _ := stm.dict["Size"].(int)
+		index = pdfObjArray{0, size}
+	}
+	field := func(b []byte, def int) int {
+		if len(b) == 0 {
+			return def
+		}
+		v := 0
+		for _, c := range b {
+			v = v<<8 | int(c)
+		}
+		return v
+	}
+	rowLen := widths[0] + widths[1] + widths[2]
+	pos := 0
+	for j := 0; j+1 < len(index); j += 2 {
+		start, _ := index[j].(int)
+		count, _ := index[j+1].(int)
+		for k := 0; k < count && pos+rowLen <= len(data); k++ {
+			row := data[pos : pos+rowLen]
+			pos += rowLen
+			kind := field(row[:widths[0]], 1)
+			f2 := field(row[widths[0]:widths[0]+widths[1]], 0)
+			f3 := field(row[widths[0]+widths[1]:], 0)
+			if _, ok := p.xref[start+k]; ok {
+				continue
+			}
+			switch kind {
+			case 0:
+				p.xref[start+k] = pdfXrefEntry{offset: -1}
+			case 1:
+				p.xref[start+k] = pdfXrefEntry{offset: f2}
+			case 2:
+				p.xref[start+k] = pdfXrefEntry{compressed: true, stream: f2, index: f3}
+			}
+		}
+	}
+	return stm.dict, nil
+}
+
+var pdfObjRe = regexp.MustCompile(`(?m)(?:^|[^\d])(\d+)[\x00\t\f ]+(\d+)[\x00\t\n\f\r ]+obj\b`)
+
+// rebuildXref locates the objects by scanning the file, and takes the
+// trailer from the last trailer dictionary or cross-reference stream
+func (p *pdfParser) rebuildXref() error {
+	for _, m := range pdfObjRe.FindAllSubmatchIndex(p.data, -1) {
+		num, _ := strconv.Atoi(string(p.data[m[2]:m[3]]))
+		p.xref[num] = pdfXrefEntry{offset: m[2]}
+	}
+	for j := bytes.LastIndex(p.data, []byte("trailer")); j >= 0 && p.trailer == nil; {
+		p.pos = j + len("trailer")
+		p.trailer, _ = p.parseObject().(pdfObjDict)
+		break
+	}
+	if p.trailer == nil {
+		// Objects in object streams are listed by cross-reference streams
+		for _, entry := range p.xref {
+			if _, obj, err := p.parseIndirect(entry.offset); err == nil {
+				if stm, ok := obj.(*pdfObjStream); ok && stm.dict["Type"] == pdfObjName("XRef") {
+					p.readXrefStream(entry.offset)
+					p.trailer = stm.dict
+				}
+			}
+		}
+	}
+	if p.trailer == nil || p.trailer["Root"] == nil {
+		return fmt.Errorf("document catalog not found")
+	}
+	return nil
+}
+
+// object returns the indirect object num, or nil if it does not exist
+func (p *pdfParser) object(num int) interface{} {
+	if obj, ok := p.objs[num]; ok {
+		return obj
+	}
+	entry, ok := p.xref[num]
+	if !ok || p.depth > 32 {
+		return nil
+	}
+	p.depth++
+	defer func() { p.depth-- }()
+	var obj interface{}
+	switch {
+	case entry.compressed:
+		objs := p.objectStream(entry.stream)
+		if entry.index < len(objs) {
+			obj = objs[entry.index]
+		}
+	case entry.offset >= 0:
+		pos := p.pos
+		_, obj, _ = p.parseIndirect(entry.offset)
+		p.pos = pos
+	}
+	p.objs[num] = obj
+	return obj
+}
+
+// objectStream returns the objects of the object stream num
+func (p *pdfParser) objectStream(num int) []interface{} {
+	if objs, ok := p.objStms[num]; ok {
+		return objs
+	}
+	p.objStms[num] = nil
+	stm, ok := p.object(num).(*pdfObjStream)
+	if !ok {
+		return nil
+	}
+	data, err := p.decodeStream(stm)
+	if err != nil {
+		return nil
+	}
+	n, _ := stm.dict["N"].(int)
+	first, _ := stm.dict["First"].(int)
+	sub := &pdfParser{data: data}
+	offsets := make([]int, 0, n)
+	for j := 0; j < n; j++ {
+		sub.parseObject()
+		offset, _ := sub.parseObject().(int)
+		offsets = append(offsets, offset)
+	}
+	objs := make([]interface{}, n)
+	for j, offset := range offsets {
+		if first+offset < len(data) {
+			sub.pos = first + offset
+			objs[j] = sub.parseObject()
+		}
+	}
+	p.objStms[num] = objs
+	return objs
+}
+
+// resolve returns obj, or the object it refers to if it is a reference
+func (p *pdfParser) resolve(obj interface{}) interface{} {
+	if ref, ok := obj.(pdfObjRef); ok {
+		return p.object(ref.num)
+	}
+	return obj
+}
+
+// parseIndirect parses the indirect object at offset
+func (p *pdfParser) parseIndirect(offset int) (num int, obj interface{}, err error) {
+	if offset < 0 || offset >= len(p.data) {
+		return 0, nil, fmt.Errorf("invalid object offset %d", offset)
+	}
+	p.pos = offset
+	num, ok1 := p.parseObject().(int)
+	_, ok2 := p.parseObject().(int)
+	if !ok1 || !ok2 || p.token() != "obj" {
+		return 0, nil, fmt.Errorf("invalid object at offset %d", offset)
+	}
+	obj = p.parseObject()
+	dict, ok := obj.(pdfObjDict)
+	if !ok {
+		return
+	}
+	pos := p.pos
+	if p.token() != "stream" {
+		p.pos = pos
+		return
+	}
+	// The data starts after the end of the line of the keyword
+	if p.pos < len(p.data) && p.data[p.pos] == '\r' {
+		p.pos++
+	}
+	if p.pos < len(p.data) && p.data[p.pos] == '\n' {
+		p.pos++
+	}
+	start := p.pos
+	length, ok := p.resolve(dict["Length"]).(int)
+	end := start + length
+	if !ok || length < 0 || end > len(p.data) ||
+		!bytes.HasPrefix(bytes.TrimLeft(p.data[end:], "\x00\t\n\f\r "), []byte("endstream")) {
+		// The length is wrong; the data extends to the keyword
+		j := bytes.Index(p.data[start:], []byte("endstream"))
+		if j < 0 {
+			return 0, nil, fmt.Errorf("unterminated stream at offset %d", offset)
+		}
+		end = start + j
+		if end > start && p.data[end-1] == '\n' {
+			end--
+		}
+		if end > start && p.data[end-1] == '\r' {
+			end--
+		}
+	}
+	obj = &pdfObjStream{dict: dict, data: p.data[start:end]}
+	p.pos = end
+	return
+}
+
+// isPdfSpace returns true if c is a white-space character
+func isPdfSpace(c byte) bool {
+	return c == 0 || c == '\t' || c == '\n' || c == '\f' || c == '\r' || c == ' '
+}
+
+// isPdfDelimiter returns true if c is a delimiter character
+func isPdfDelimiter(c byte) bool {
+	switch c {
+	case '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
+
+// skipSpace skips white space and comments
+func (p *pdfParser) skipSpace() {
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		if c == '%' {
+			for p.pos < len(p.data) && p.data[p.pos] != '\n' && p.data[p.pos] != '\r' {
+				p.pos++
+			}
+		} else if !isPdfSpace(c) {
+			return
+		}
+		p.pos++
+	}
+}
+
+// token returns the next regular token, such as a keyword or a number
+func (p *pdfParser) token() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.data) && !isPdfSpace(p.data[p.pos]) && !isPdfDelimiter(p.data[p.pos]) {
+		p.pos++
+	}
+	return string(p.data[start:p.pos])
+}
+
+// parseObject parses the direct object at the current position. Keywords
+// other than true, false, null and R are returned as nil.
+func (p *pdfParser) parseObject() interface{} {
+	p.skipSpace()
+	if p.pos >= len(p.data) {
+		return nil
+	}
+	switch c := p.data[p.pos]; c {
+	case '/':
+		p.pos++
+		return p.parseName()
+	case '(':
+		p.pos++
+		return p.parseString()
+	case '<':
+		if p.pos+1 < len(p.data) && p.data[p.pos+1] == '<' {
+			p.pos += 2
+			dict := make(pdfObjDict)
+			for {
+				p.skipSpace()
+				if p.pos >= len(p.data) {
+					return dict
+				}
+				if bytes.HasPrefix(p.data[p.pos:], []byte(">>")) {
+					p.pos += 2
+					return dict
+				}
+				key, ok := p.parseObject().(pdfObjName)
+				if !ok {
+					// Skip what cannot be a key
+					continue
+				}
+				dict[string(key)] = p.parseObject()
+			}
+		}
+		p.pos++
+		start := p.pos
+		for p.pos < len(p.data) && p.data[p.pos] != '>' {
+			p.pos++
+		}
+		s, _ := pdfHexDecode(p.data[start:p.pos])
+		p.pos++
+		return pdfObjString(s)
+	case '[':
+		p.pos++
+		arr := pdfObjArray{}
+		for {
+			p.skipSpace()
+			if p.pos >= len(p.data) {
+				return arr
+			}
+			if p.data[p.pos] == ']' {
+				p.pos++
+				return arr
+			}
+			start := p.pos
+			arr = append(arr, p.parseObject())
+			if p.pos == start {
+				// Skip an unexpected delimiter
+				p.pos++
+			}
+		}
+	}
+	start := p.pos
+	tok := p.token()
+	switch tok {
+	case "true":
+		return true
+	case "false":
+		return false
+	case "null", "":
+		if tok == "" && p.pos == start && p.pos < len(p.data) {
+			// An unexpected delimiter
+			p.pos++
+		}
+		return nil
+	}
+	if n, err := strconv.Atoi(tok); err == nil {
+		// A number may be the start of a reference
+		pos := p.pos
+		if gen, err := strconv.Atoi(p.token()); err == nil && p.token() == "R" {
+			return pdfObjRef{num: n, gen: gen}
+		}
+		p.pos = pos
+		return n
+	}
+	if v, err := strconv.ParseFloat(tok, 64); err == nil {
+		return v
+	}
+	return nil
+}
+
+// parseName parses a name after its slash
+func (p *pdfParser) parseName() pdfObjName {
+	var b []byte
+	for p.pos < len(p.data) && !isPdfSpace(p.data[p.pos]) && !isPdfDelimiter(p.data[p.pos]) {
+		c := p.data[p.pos]
+		if c == '#' && p.pos+2 < len(p.data) {
+			if v, err := strconv.ParseUint(string(p.data[p.pos+1:p.pos+3]), 16, 8); err == nil {
+				b = append(b, byte(v))
+				p.pos += 3
+				continue
+			}
+		}
+		b = append(b, c)
+		p.pos++
+	}
+	return pdfObjName(b)
+}
+
+// parseString parses a literal string after its opening parenthesis
+func (p *pdfParser) parseString() pdfObjString {
+	var b []byte
+	depth := 1
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		p.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				return pdfObjString(b)
+			}
+		case '\\':
+			if p.pos >= len(p.data) {
+				continue
+			}
+			c = p.data[p.pos]
+			p.pos++
+			switch c {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				// A line continuation
+				if p.pos < len(p.data) && p.data[p.pos] == '\n' {
+					p.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if c >= '0' && c <= '7' {
+					v := int(c - '0')
+					for k := 0; k < 2 && p.pos < len(p.data) && p.data[p.pos] >= '0' && p.data[p.pos] <= '7'; k++ {
+						v = v*8 + int(p.data[p.pos]-'0')
+						p.pos++
+					}
+					c = byte(v)
+				}
+			}
+		}
+		b = append(b, c)
+	}
+	return pdfObjString(b)
+}
+
+// decodeStream returns the decoded data of stm
+func (p *pdfParser) decodeStream(stm *pdfObjStream) (data []byte, err error) {
+	data = stm.data
+	var filters, parms pdfObjArray
+	switch v := p.resolve(stm.dict["Filter"]).(type) {
+	case pdfObjName:
+		filters = pdfObjArray{v}
+	case pdfObjArray:
+		filters = v
+	}
+	switch v := p.resolve(stm.dict["DecodeParms"]).(type) {
+	case pdfObjDict:
+		parms = pdfObjArray{v}
+	case pdfObjArray:
+		parms = v
+	}
+	for j, filter := range filters {
+		var parm pdfObjDict
+		if j < len(parms) {
+			parm, _ = p.resolve(parms[j]).(pdfObjDict)
+		}
+		switch p.resolve(filter) {
+		case pdfObjName("FlateDecode"), pdfObjName("Fl"):
+			var r interface {
+				Read([]byte) (int, error)
+				Close() error
+			}
+			if r, err = zlib.NewReader(bytes.NewReader(data)); err != nil {
+				return
+			}
+			// Damaged data is used as far as it can be decoded
+			data, _ = ioutil.ReadAll(r)
+			r.Close()
+			if data, err = pdfPredict(data, parm); err != nil {
+				return
+			}
+		case pdfObjName("ASCIIHexDecode"), pdfObjName("AHx"):
+			end := bytes.IndexByte(data, '>')
+			if end < 0 {
+				end = len(data)
+			}
+			if data, err = pdfHexDecode(data[:end]); err != nil {
+				return
+			}
+		case pdfObjName("ASCII85Decode"), pdfObjName("A85"):
+			src := bytes.TrimPrefix(bytes.TrimSpace(data), []byte("<~"))
+			if end := bytes.Index(src, []byte("~>")); end >= 0 {
+				src = src[:end]
+			}
+			dst := make([]byte, 4*len(src))
+			var n int
+			if n, _, err = ascii85.Decode(dst, src, true); err != nil {
+				return
+			}
+			data = dst[:n]
+		default:
+			return nil, fmt.Errorf("unsupported stream filter %v", filter)
+		}
+	}
+	return
+}
+
+// pdfHexDecode decodes the hexadecimal digits of b, which may be separated by
+// white space; a missing last digit is 0
+func pdfHexDecode(b []byte) ([]byte, error) {
+	b = bytes.Map(func(r rune) rune {
+		if r < 0x80 && isPdfSpace(byte(r)) {
+			return -1
+		}
+		return r
+	}, b)
+	if len(b)%2 != 0 {
+		b = append(b, '0')
+	}
+	return hex.DecodeString(string(b))
+}
+
+// pdfPredict reverses the PNG predictors of data decoded with FlateDecode
+func pdfPredict(data []byte, parm pdfObjDict) ([]byte, error) {
+	predictor, _ := parm["Predictor"].(int)
+	if predictor < 10 {
+		if predictor > 1 {
+			return nil, fmt.Errorf("unsupported predictor %d", predictor)
+		}
+		return data, nil
+	}
+	colors, ok := parm["Colors"].(int)
+	if !ok {
+		colors = 1
+	}
+	bpc, ok := parm["BitsPerComponent"].(int)
+	if !ok {
+		bpc = 8
+	}
+	columns, ok := parm["Columns"].(int)
+	if !ok {
+		columns = 1
+	}
+	bpp := (colors*bpc + 7) / 8
+	rowLen := (colors*bpc*columns + 7) / 8
+	var out []byte
+	prev := make([]byte, rowLen)
+	for pos := 0; pos+rowLen+1 <= len(data); pos += rowLen + 1 {
+		kind := data[pos]
+		row := append([]byte(nil), data[pos+1:pos+1+rowLen]...)
+		for j := range row {
+			var left, upLeft byte
+			if j >= bpp {
+				left, upLeft = row[j-bpp], prev[j-bpp]
+			}
+			up := prev[j]
+			switch kind {
+			case 1:
+				row[j] += left
+			case 2:
+				row[j] += up
+			case 3:
+				row[j] += byte((int(left) + int(up)) / 2)
+			case 4:
+				row[j] += paethPredictor(left, up, upLeft)
+			}
+		}
+		out = append(out, row...)
+		prev = row
+	}
+	return out, nil
+}
+
+// paethPredictor returns the Paeth predictor of the PNG specification
+func paethPredictor(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := p-int(a), p-int(b), p-int(c)
+	if pa < 0 {
+		pa = -pa
+	}
+	if pb < 0 {
+		pb = -pb
+	}
+	if pc < 0 {
+		pc = -pc
+	}
+	switch {
+	case pa <= pb && pa <= pc:
+		return a
+	case pb <= pc:
+		return b
+	}
+	return c
+}
@@ -0,0 +1,261 @@
+package gofpdf
+
+import (
+	"bytes"
+	"crypto/md5"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PDF/X conformance levels that can be passed to SetPDFXConformance. The
+// value is the one that identifies the level in the metadata of the document.
+const (
+	PDFX4 = "PDF/X-4"
+)
+
+type pdfxType struct {
+	version   string       // PDF/X level; empty if conformance is not requested
+	rgbPages  map[int]bool // pages flushed to the output writer that use DeviceRGB colors
+	cmykPages map[int]bool // pages flushed to the output writer that use DeviceCMYK colors
+}
+
+// SetPDFXConformance requests that the document be written in conformance
+// with the PDF/X prepress standard at the specified level, currently only
+// PDFX4, so that printers can accept it without preflight fixes. PDF/X-4
+// permits transparency and layers.
+//
+// The output intent of a PDF/X document is the printing condition it is
+// prepared for: an ICC profile, typically the CMYK profile supplied by the
+// print service, set with RegisterICCProfile() and SetOutputIntentProfile().
+// The document information gains the trapping state and the PDF/X level, and
+// an XMP metadata stream that identifies the level is generated unless custom
+// metadata has been specified with SetXmpMetadata().
+//
+// When the document is output it is verified, and an error that lists all
+// the violations found is reported if it does not conform: every page needs a
+// trim box or an art box, set with SetPageBox(); the document needs a title
+// and an output intent profile; colors and images in the device color spaces
+// must match the output intent, so that DeviceRGB is not used with a CMYK
+// profile or DeviceCMYK with an RGB profile, while gray, spot and ICC-based
+// colors can always be used; fonts must be embedded; and encryption,
+// JavaScript and attachments are not permitted. Documents of PDF version 1.7,
+// including those that conform to PDF/A-2 or PDF/A-3, are not permitted
+// either; PDF/A-1 can be combined with PDF/X-4.
+//
+// The SetPDFXConformance example demonstrates this method.
+func (f *Fpdf) SetPDFXConformance(level string) {
+	if f.err != nil {
+		return
+	}
+	if level != PDFX4 {
+		f.err = fmt.Errorf("unsupported PDF/X conformance level \"%s\"", level)
+		return
+	}
+	f.pdfx.version = level
+}
+
+// pdfxPrepare verifies that the document conforms to the requested PDF/X
+// level. It is called when the document is closed, after pdfaPrepare().
+func (f *Fpdf) pdfxPrepare() {
+	if f.pdfx.version == "" {
+		return
+	}
+	var list []string
+	var noBox []string
+	for n := 1; n < len(f.pages); n++ {
+		boxes := f.pageBoxes[n]
+		if _, ok := boxes["TrimBox"]; !ok {
+			if _, ok = boxes["ArtBox"]; !ok {
+				noBox = append(noBox, strconv.Itoa(n))
+			}
+		}
+	}
+	if len(noBox) > 0 {
+		list = append(list, "pages without a trim box: "+strings.Join(noBox, ", "))
+	}
+	if len(f.title) == 0 {
+		list = append(list, "no title")
+	}
+	components := 0
+	if f.outputProfile > 0 {
+		components = f.iccProfiles[f.outputProfile-1].components
+	} else {
+		list = append(list, "no output intent profile")
+	}
+	rgb, cmyk := f.pdfxDeviceSpaces()
+	if len(rgb) > 0 && components != 3 {
+		list = append(list, "DeviceRGB colors used by "+strings.Join(rgb, ", "))
+	}
+	if len(cmyk) > 0 && components != 4 {
+		list = append(list, "DeviceCMYK colors used by "+strings.Join(cmyk, ", "))
+	}
+	if name := f.unembeddedFont(); name != "" {
+		list = append(list, "font "+name+" is not embedded")
+	}
+	if f.protect.encrypted {
+		list = append(list, "encryption")
+	}
+	if f.javascript != nil || f.actionJavaScript() {
+		list = append(list, "JavaScript")
+	}
+	if len(f.attachments) > 0 || f.pageAttachmentCount() > 0 {
+		list = append(list, "attachments")
+	}
+	if f.markupAnnotCount() > 0 {
+		list = append(list, "annotations")
+	}
+	if f.pdfVersion > "1.6" {
+		list = append(list, "PDF version "+f.pdfVersion)
+	}
+	if len(f.xmp) > 0 && !bytes.Contains(f.xmp, []byte("GTS_PDFXVersion")) {
+		list = append(list, "XMP metadata without GTS_PDFXVersion")
+	}
+	if len(list) > 0 {
+		f.err = fmt.Errorf("document does not conform to %s: %s", f.pdfx.version, strings.Join(list, "; "))
+		return
+	}
+	if f.pdfVersion < "1.4" {
+		f.pdfVersion = "1.4"
+	}
+}
+
+// pdfxDeviceSpaces returns the descriptions of the pages, images, gradients
+// and other content of the document that use DeviceRGB and DeviceCMYK colors
+func (f *Fpdf) pdfxDeviceSpaces() (rgb, cmyk []string) {
+	add := func(what string, isRGB, isCMYK bool) {
+		if isRGB {
+			rgb = append(rgb, what)
+		}
+		if isCMYK {
+			cmyk = append(cmyk, what)
+		}
+	}
+	for n := 1; n < len(f.pages); n++ {
+		if f.pageFlushed(n) {
+			add(sprintf("page %d", n), f.pdfx.rgbPages[n], f.pdfx.cmykPages[n])
+		} else {
+			isRGB, isCMYK := deviceColorOps(f.pages[n].Bytes())
+			add(sprintf("page %d", n), isRGB, isCMYK)
+		}
+	}
+	keyList := make([]string, 0, len(f.templates))
+	for key := range f.templates {
+		keyList = append(keyList, key)
+	}
+	gensort(len(keyList), func(a, b int) bool {
+		return keyList[a] < keyList[b]
+	}, func(a, b int) {
+		keyList[a], keyList[b] = keyList[b], keyList[a]
+	})
+	for _, key := range keyList {
+		isRGB, isCMYK := deviceColorOps(f.templates[key].Bytes())
+		add("template "+key, isRGB, isCMYK)
+	}
+	for j, pt := range f.patterns {
+		isRGB, isCMYK := deviceColorOps(pt.content)
+		add(sprintf("tiling pattern %d", j+1), isRGB, isCMYK)
+	}
+	for j, sm := range f.softMasks {
+		isRGB, isCMYK := deviceColorOps(sm.content)
+		add(sprintf("soft mask %d", j+1), isRGB, isCMYK)
+	}
+	for j, gr := range f.groups {
+		isRGB, isCMYK := deviceColorOps(gr.content)
+		add(sprintf("transparency group %d", j+1), isRGB, isCMYK)
+	}
+	for j, gr := range f.gradientList {
+		if j > 0 {
+			add(sprintf("gradient %d", j), gr.space == "" || gr.space == "DeviceRGB", gr.space == "DeviceCMYK")
+		}
+	}
+	keyList = keyList[:0]
+	for key := range f.images {
+		if i := f.images[key].i; f.imagesUsed[i] || f.imagesInline[i] {
+			keyList = append(keyList, key)
+		}
+	}
+	gensort(len(keyList), func(a, b int) bool {
+		return keyList[a] < keyList[b]
+	}, func(a, b int) {
+		keyList[a], keyList[b] = keyList[b], keyList[a]
+	})
+	for _, key := range keyList {
+		// Images with ICC profiles do not use device color spaces
+		cs := f.images[key].cs
+		if len(f.images[key].icc) > 0 {
+			cs = ""
+		}
+		add("image "+key, cs == "DeviceRGB" || cs == "Indexed", cs == "DeviceCMYK")
+	}
+	return
+}
+
+// groupColorSpace returns the color space in which the transparency groups of
+// pages and soft masks are blended: DeviceCMYK in a PDF/X document whose output
+// intent is a CMYK profile, so that blending matches the printing condition,
+// and DeviceRGB otherwise
+func (f *Fpdf) groupColorSpace() string {
+	if f.pdfx.version != "" && f.outputProfile > 0 && f.iccProfiles[f.outputProfile-1].components == 4 {
+		return "DeviceCMYK"
+	}
+	return "DeviceRGB"
+}
+
+// pageFlushed reports whether the content of page has been flushed to the
+// output writer
+func (f *Fpdf) pageFlushed(page int) bool {
+	if f.outStream == nil {
+		return false
+	}
+	_, ok := f.outStream.contents[page]
+	return ok
+}
+
+// pdfxScanPage records the device color spaces that page uses before its
+// content is flushed to the output writer
+func (f *Fpdf) pdfxScanPage(page int) {
+	if f.pdfx.version == "" {
+		return
+	}
+	if f.pdfx.rgbPages == nil {
+		f.pdfx.rgbPages, f.pdfx.cmykPages = make(map[int]bool), make(map[int]bool)
+	}
+	f.pdfx.rgbPages[page], f.pdfx.cmykPages[page] = deviceColorOps(f.pages[page].Bytes())
+}
+
+// deviceColorOps reports whether the content stream content sets colors in
+// the DeviceRGB and the DeviceCMYK color spaces
+func deviceColorOps(content []byte) (rgb, cmyk bool) {
+	fields := bytes.Fields(content)
+	// operands reports whether the count fields before the operator at j are
+	// numbers
+	operands := func(j, count int) bool {
+		if j < count {
+			return false
+		}
+		for _, field := range fields[j-count : j] {
+			if _, err := strconv.ParseFloat(string(field), 64); err != nil {
+				return false
+			}
+		}
+		return true
+	}
+	for j, field := range fields {
+		switch string(field) {
+		case "rg", "RG":
+			rgb = rgb || operands(j, 3)
+		case "k", "K":
+			cmyk = cmyk || operands(j, 4)
+		}
+	}
+	return
+}
+
+// pdfxDocumentID returns the document identifier of the XMP metadata of
+// PDF/X documents, which is derived from the document information
+func (f *Fpdf) pdfxDocumentID() string {
+	sum := md5.Sum([]byte(f.title + "\x00" + f.author + "\x00" + timeOrNow(f.creationDate).String()))
+	h := fmt.Sprintf("%x", sum)
+	return "uuid:" + h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
@@ -19,6 +19,7 @@ package gofpdf
 import (
 	"bytes"
 	"fmt"
+	"image/png"
 	"strings"
 )
 
@@ -39,6 +40,7 @@ func (f *Fpdf) pngColorSpace(ct byte) (colspace string, colorVal int) {
 }
 
 func (f *Fpdf) parsepngstream(buf *bytes.Buffer, readdpi bool) (info *ImageInfoType) {
+	pngData := buf.Bytes()
 	info = f.newImageInfo()
 	// 	Check signature
 	if string(buf.Next(8)) != "\x89PNG\x0d\x0a\x1a\x0a" {
@@ -54,9 +56,6 @@ func (f *Fpdf) parsepngstream(buf *bytes.Buffer, readdpi bool) (info *ImageInfoT
 	w := f.readBeInt32(buf)
 	h := f.readBeInt32(buf)
 	bpc := f.readByte(buf)
-	if bpc > 8 {
-		f.err = fmt.Errorf("16-bit depth not supported in PNG file")
-	}
 	ct := f.readByte(buf)
 	var colspace string
 	var colorVal int
@@ -81,6 +80,7 @@ func (f *Fpdf) parsepngstream(buf *bytes.Buffer, readdpi bool) (info *ImageInfoT
 	// Scan chunks looking for palette, transparency and image data
 	pal := make([]byte, 0, 32)
 	var trns []int
+	var icc []byte
 	data := make([]byte, 0, 32)
 	loop := true
 	for loop {
@@ -98,9 +98,9 @@ func (f *Fpdf) parsepngstream(buf *bytes.Buffer, readdpi bool) (info *ImageInfoT
 			t := buf.Next(n)
 			switch ct {
 			case 0:
-				trns = []int{int(t[1])} // ord(substr($t,1,1)));
+				trns = []int{pngSample(t, 0, bpc)} // ord(substr($t,1,1)));
 			case 2:
-				trns = []int{int(t[1]), int(t[3]), int(t[5])} // array(ord(substr($t,1,1)), ord(substr($t,3,1)), ord(substr($t,5,1)));
+				trns = []int{pngSample(t, 0, bpc), pngSample(t, 1, bpc), pngSample(t, 2, bpc)} // array(ord(substr($t,1,1)), ord(substr($t,3,1)), ord(substr($t,5,1)));
 			default:
 				pos := strings.Index(string(t), "\x00")
 				if pos >= 0 {
@@ -113,6 +113,14 @@ func (f *Fpdf) parsepngstream(buf *bytes.Buffer, readdpi bool) (info *ImageInfoT
 			// Read image data block
 			data = append(data, buf.Next(n)...)
 			_ = buf.Next(4)
+		case "iCCP":
+			// Embedded ICC profile: name, compression method and
+			// compressed profile
+			chunk := buf.Next(n)
+			if pos := bytes.IndexByte(chunk, 0); pos > 0 && pos+2 <= len(chunk) && chunk[pos+1] == 0 {
+				icc, _ = sliceUncompress(chunk[pos+2:])
+			}
+			_ = buf.Next(4)
 		case "IEND":
 			// dbg("IEND")
 			loop = false
@@ -149,6 +157,11 @@ func (f *Fpdf) parsepngstream(buf *bytes.Buffer, readdpi bool) (info *ImageInfoT
 	if colspace == "Indexed" && len(pal) == 0 {
 		f.err = fmt.Errorf("missing palette in PNG buffer")
 	}
+	if bpc == 16 && f.pdfa.part == 1 {
+		// PDF/A-1 does not permit 16 bits per component, so the image is
+		// reduced to 8 bits
+		return f.parsepng16(pngData, info.dpi, icc)
+	}
 	info.w = float64(w)
 	info.h = float64(h)
 	info.cs = colspace
@@ -157,6 +170,13 @@ func (f *Fpdf) parsepngstream(buf *bytes.Buffer, readdpi bool) (info *ImageInfoT
 	info.dp = dp
 	info.pal = pal
 	info.trns = trns
+	if colspace == "Indexed" {
+		colorVal = 3
+	}
+	info.icc = imageICCProfile(icc, colorVal)
+	if bpc == 16 && f.pdfVersion < "1.5" {
+		f.pdfVersion = "1.5"
+	}
 	// dbg("ct [%d]", ct)
 	if ct >= 4 {
 		// Separate alpha and color channels
@@ -167,43 +187,32 @@ func (f *Fpdf) parsepngstream(buf *bytes.Buffer, readdpi bool) (info *ImageInfoT
 			return
 		}
 		var color, alpha bytes.Buffer
-		if ct == 4 {
-			// Gray image
-			width := int(w)
-			height := int(h)
-			length := 2 * width
-			var pos, elPos int
-			for i := 0; i < height; i++ {
-				pos = (1 + length) * i
-				color.WriteByte(data[pos])
-				alpha.WriteByte(data[pos])
-				elPos = pos + 1
-				for k := 0; k < width; k++ {
-					color.WriteByte(data[elPos])
-					alpha.WriteByte(data[elPos+1])
-					elPos += 2
-				}
-			}
-		} else {
-			// RGB image
-			width := int(w)
-			height := int(h)
-			length := 4 * width
-			var pos, elPos int
-			for i := 0; i < height; i++ {
-				pos = (1 + length) * i
-				color.WriteByte(data[pos])
-				alpha.WriteByte(data[pos])
-				elPos = pos + 1
-				for k := 0; k < width; k++ {
-					color.Write(data[elPos : elPos+3])
-					alpha.WriteByte(data[elPos+3])
-					elPos += 4
-				}
+		// The filters of the rows of PNG images apply to each byte of a pixel
+		// separately, so the color and alpha bytes keep their filters.
+		// Samples have 1 or 2 bytes.
+		size := int(bpc) / 8
+		colorLen, alphaLen := colorVal*size, size
+		width := int(w)
+		height := int(h)
+		length := (colorLen + alphaLen) * width
+		if len(data) < (1+length)*height {
+			f.err = fmt.Errorf("PNG image data is truncated")
+			return
+		}
+		var pos, elPos int
+		for i := 0; i < height; i++ {
+			pos = (1 + length) * i
+			color.WriteByte(data[pos])
+			alpha.WriteByte(data[pos])
+			elPos = pos + 1
+			for k := 0; k < width; k++ {
+				color.Write(data[elPos : elPos+colorLen])
+				alpha.Write(data[elPos+colorLen : elPos+colorLen+alphaLen])
+				elPos += colorLen + alphaLen
 			}
 		}
-		data = sliceCompress(color.Bytes())
-		info.smask = sliceCompress(alpha.Bytes())
+		data = f.compressFlate(color.Bytes())
+		info.smask = f.compressFlate(alpha.Bytes())
 		if f.pdfVersion < "1.4" {
 			f.pdfVersion = "1.4"
 		}
@@ -211,3 +220,31 @@ func (f *Fpdf) parsepngstream(buf *bytes.Buffer, readdpi bool) (info *ImageInfoT
 	info.data = data
 	return
 }
+
+// pngSample returns the sample j of the transparency chunk data t of a PNG
+// image with bpc bits per sample
+func pngSample(t []byte, j int, bpc byte) int {
+	if len(t) < j*2+2 {
+		return 0
+	}
+	if bpc == 16 {
+		return int(t[j*2])<<8 | int(t[j*2+1])
+	}
+	return int(t[j*2+1])
+}
+
+// parsepng16 extracts info from the PNG data of an image with 16 bits per
+// sample, which is decoded and reduced to 8 bits per sample, with the
+// resolution dpi and the ICC profile icc
+func (f *Fpdf) parsepng16(data []byte, dpi float64, icc []byte) (info *ImageInfoType) {
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		f.err = err
+		return
+	}
+	if info = f.parsepixels(img); f.err == nil {
+		info.dpi = dpi
+		info.icc = imageICCProfile(icc, iccComponents[info.cs])
+	}
+	return
+}
@@ -0,0 +1,295 @@
+package gofpdf
+
+import (
+	"math"
+	"strings"
+)
+
+// ArrowType sets the ends of a polyline that PolylineEx() draws arrowheads at
+type ArrowType int
+
+const (
+	// ArrowNone draws no arrowhead.
+	ArrowNone ArrowType = iota
+	// ArrowStart draws an arrowhead at the first point.
+	ArrowStart
+	// ArrowEnd draws an arrowhead at the last point.
+	ArrowEnd
+	// ArrowBoth draws arrowheads at the first and the last point.
+	ArrowBoth
+)
+
+// ArrowShape is the shape of the arrowheads drawn by PolylineEx()
+type ArrowShape int
+
+const (
+	// ArrowTriangle is a filled triangle.
+	ArrowTriangle ArrowShape = iota
+	// ArrowOpen is made of two lines, like a V.
+	ArrowOpen
+	// ArrowStealth is a filled triangle with a notched back.
+	ArrowStealth
+	// ArrowDiamond is a filled diamond.
+	ArrowDiamond
+)
+
+// MarkerShape is the shape of the markers drawn at the points of a polyline
+// or polygon by PolylineEx() and PolygonEx()
+type MarkerShape int
+
+const (
+	// MarkerNone draws no marker.
+	MarkerNone MarkerShape = iota
+	// MarkerCircle draws a circle.
+	MarkerCircle
+	// MarkerSquare draws a square.
+	MarkerSquare
+	// MarkerDiamond draws a square standing on a corner.
+	MarkerDiamond
+)
+
+// LineStyle sets how PolylineEx() and PolygonEx() draw lines through points.
+// Sizes are in the unit of measure of the document; sizes of 0 are replaced
+// with sizes that suit the current line width.
+type LineStyle struct {
+	// Smooth is true to join the points with a smooth curve, a Catmull-Rom
+	// spline, instead of straight lines. The curve passes through every point.
+	Smooth bool
+	// Arrow sets the ends of a polyline that have arrowheads, of the shape
+	// ArrowShape, the length ArrowLength along the line and the width
+	// ArrowWidth across it. Arrowheads are filled with the draw color. The line
+	// is shortened so that it ends within a filled arrowhead.
+	Arrow                   ArrowType
+	ArrowShape              ArrowShape
+	ArrowLength, ArrowWidth float64
+	// Marker is the shape of the markers drawn at the points, except at the
+	// ends that have arrowheads, of width MarkerSize. Markers are filled with
+	// the fill color and outlined with the draw color.
+	Marker     MarkerShape
+	MarkerSize float64
+}
+
+// polySegType is a segment of a polyline, straight or a cubic Bézier curve
+type polySegType struct {
+	p0, c1, c2, p3 PointType
+	curve          bool
+}
+
+// PolylineEx draws an open line through points, with the current draw color,
+// line width and line styles, and with the arrowheads, markers and smoothing
+// of style. It draws flowchart connectors and diagram arrows without the
+// Bézier control points of curves being computed by hand. Nothing is drawn if
+// there are fewer than two points.
+//
+// The PolylineEx example demonstrates this method.
+func (f *Fpdf) PolylineEx(points []PointType, style LineStyle) {
+	if f.err != nil || len(points) < 2 {
+		return
+	}
+	style = f.lineStyleDefaults(style)
+	segs := polySegments(points, false, style.Smooth)
+	first, last := &segs[0], &segs[len(segs)-1]
+	startArrow := style.Arrow == ArrowStart || style.Arrow == ArrowBoth
+	endArrow := style.Arrow == ArrowEnd || style.Arrow == ArrowBoth
+	// The arrowheads point along the line at its ends
+	var startDir, endDir PointType
+	if startArrow {
+		startDir = polyDirection(first.c1, first.p0, first.p3)
+		shortenSeg(first, startDir, style.arrowInset(), true)
+	}
+	if endArrow {
+		endDir = polyDirection(last.c2, last.p3, last.p0)
+		shortenSeg(last, endDir, style.arrowInset(), false)
+	}
+	f.outPolySegments(segs)
+	f.DrawPath("D")
+	for j, pt := range points {
+		if (j == 0 && startArrow) || (j == len(points)-1 && endArrow) {
+			continue
+		}
+		f.polyMarker(pt, style)
+	}
+	if startArrow {
+		f.arrowhead(points[0], startDir, style)
+	}
+	if endArrow {
+		f.arrowhead(points[len(points)-1], endDir, style)
+	}
+}
+
+// PolygonEx draws a closed figure through points, like Polygon(), with the
+// markers and smoothing of style; the arrowheads of style are not used.
+// styleStr is the same as for Polygon(). Nothing is drawn if there are fewer
+// than three points.
+//
+// The PolylineEx example demonstrates this method.
+func (f *Fpdf) PolygonEx(points []PointType, styleStr string, style LineStyle) {
+	if f.err != nil || len(points) < 3 {
+		return
+	}
+	style = f.lineStyleDefaults(style)
+	f.outPolySegments(polySegments(points, true, style.Smooth))
+	f.out("h")
+	f.DrawPath(styleStr)
+	for _, pt := range points {
+		f.polyMarker(pt, style)
+	}
+}
+
+// lineStyleDefaults returns style with its sizes of 0 replaced with sizes that
+// suit the current line width
+func (f *Fpdf) lineStyleDefaults(style LineStyle) LineStyle {
+	if style.ArrowLength <= 0 {
+		style.ArrowLength = 4/f.k + 4*f.lineWidth
+	}
+	if style.ArrowWidth <= 0 {
+		style.ArrowWidth = style.ArrowLength * 0.7
+	}
+	if style.MarkerSize <= 0 {
+		style.MarkerSize = 3/f.k + 2*f.lineWidth
+	}
+	return style
+}
+
+// arrowInset returns the length by which a line is shortened at an arrowhead
+func (style LineStyle) arrowInset() float64 {
+	switch style.ArrowShape {
+	case ArrowOpen:
+		return 0
+	case ArrowStealth:
+		return style.ArrowLength * 0.7
+	}
+	return style.ArrowLength
+}
+
+// polySegments returns the segments of the line through points, closed if
+// closed is true, with Catmull-Rom curves if smooth is true
+func polySegments(points []PointType, closed, smooth bool) (segs []polySegType) {
+	n := len(points)
+	count := n - 1
+	if closed {
+		count = n
+	}
+	// at returns the point j, wrapping around closed lines and repeating the
+	// ends of open lines
+	at := func(j int) PointType {
+		switch {
+		case closed:
+			return points[(j+n)%n]
+		case j < 0:
+			return points[0]
+		case j >= n:
+			return points[n-1]
+		}
+		return points[j]
+	}
+	for j := 0; j < count; j++ {
+		p0, p3 := at(j), at(j+1)
+		seg := polySegType{p0: p0, c1: p0, c2: p3, p3: p3}
+		if smooth {
+			prev, next := at(j-1), at(j+2)
+			seg.c1 = PointType{X: p0.X + (p3.X-prev.X)/6, Y: p0.Y + (p3.Y-prev.Y)/6}
+			seg.c2 = PointType{X: p3.X - (next.X-p0.X)/6, Y: p3.Y - (next.Y-p0.Y)/6}
+			seg.curve = true
+		}
+		segs = append(segs, seg)
+	}
+	return
+}
+
+// polyDirection returns the unit vector from the point from to the end tip of
+// a segment, or from the other end of the segment if from is at the tip
+func polyDirection(from, tip, other PointType) PointType {
+	if from == tip {
+		from = other
+	}
+	dx, dy := tip.X-from.X, tip.Y-from.Y
+	d := math.Hypot(dx, dy)
+	if d == 0 {
+		return PointType{X: 1}
+	}
+	return PointType{X: dx / d, Y: dy / d}
+}
+
+// shortenSeg moves the start of seg, if start is true, or else its end, back
+// by length against the direction dir, in which the end points, without
+// going past the other end of a straight segment
+func shortenSeg(seg *polySegType, dir PointType, length float64, start bool) {
+	if !seg.curve {
+		length = math.Min(length, math.Hypot(seg.p3.X-seg.p0.X, seg.p3.Y-seg.p0.Y))
+	}
+	dx, dy := dir.X*length, dir.Y*length
+	if start {
+		seg.p0.X, seg.p0.Y = seg.p0.X-dx, seg.p0.Y-dy
+		seg.c1.X, seg.c1.Y = seg.c1.X-dx, seg.c1.Y-dy
+	} else {
+		seg.p3.X, seg.p3.Y = seg.p3.X-dx, seg.p3.Y-dy
+		seg.c2.X, seg.c2.Y = seg.c2.X-dx, seg.c2.Y-dy
+	}
+}
+
+// outPolySegments outputs the path of segs
+func (f *Fpdf) outPolySegments(segs []polySegType) {
+	f.point(segs[0].p0.XY())
+	for _, seg := range segs {
+		if seg.curve {
+			f.curve(seg.c1.X, seg.c1.Y, seg.c2.X, seg.c2.Y, seg.p3.X, seg.p3.Y)
+		} else {
+			f.outf("%.5f %.5f l", seg.p3.X*f.k, (f.h-seg.p3.Y)*f.k)
+		}
+	}
+}
+
+// arrowhead draws the arrowhead of style with its tip at tip, pointing in
+// the direction dir
+func (f *Fpdf) arrowhead(tip, dir PointType, style LineStyle) {
+	l, w := style.ArrowLength, style.ArrowWidth/2
+	// pt returns the point at a along and b across the arrowhead, from its tip
+	pt := func(a, b float64) PointType {
+		return PointType{X: tip.X - dir.X*a - dir.Y*b, Y: tip.Y - dir.Y*a + dir.X*b}
+	}
+	var pts []PointType
+	switch style.ArrowShape {
+	case ArrowOpen:
+		f.outPolySegments(polySegments([]PointType{pt(l, w), tip, pt(l, -w)}, false, false))
+		f.DrawPath("D")
+		return
+	case ArrowStealth:
+		pts = []PointType{tip, pt(l, w), pt(l*0.7, 0), pt(l, -w)}
+	case ArrowDiamond:
+		pts = []PointType{tip, pt(l/2, w), pt(l, 0), pt(l/2, -w)}
+	default:
+		pts = []PointType{tip, pt(l, w), pt(l, -w)}
+	}
+	// The arrowhead is filled with the draw color
+	f.outf("q %s", fillColorOps(f.color.draw.str))
+	f.outPolySegments(polySegments(pts, true, false))
+	f.out("h f Q")
+}
+
+// polyMarker draws the marker of style at pt
+func (f *Fpdf) polyMarker(pt PointType, style LineStyle) {
+	r := style.MarkerSize / 2
+	switch style.Marker {
+	case MarkerCircle:
+		f.Circle(pt.X, pt.Y, r, "FD")
+	case MarkerSquare:
+		f.Rect(pt.X-r, pt.Y-r, 2*r, 2*r, "FD")
+	case MarkerDiamond:
+		f.Polygon([]PointType{{X: pt.X, Y: pt.Y - r}, {X: pt.X + r, Y: pt.Y},
+			{X: pt.X, Y: pt.Y + r}, {X: pt.X - r, Y: pt.Y}}, "FD")
+	}
+}
+
+// fillColorOps returns the operators that set the fill color to the color
+// that the stroke color operators drawStr set
+func fillColorOps(drawStr string) string {
+	fields := strings.Fields(drawStr)
+	for j, field := range fields {
+		switch field {
+		case "G", "RG", "K", "CS", "SC", "SCN":
+			fields[j] = strings.ToLower(field)
+		}
+	}
+	return strings.Join(fields, " ")
+}
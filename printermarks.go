@@ -0,0 +1,179 @@
+package gofpdf
+
+import (
+	"fmt"
+)
+
+// MarksOptions sets the printer marks that AddPrinterMarks() draws. Sizes are
+// in the unit of measure of the document; sizes of 0 are replaced with the
+// usual sizes of printer marks.
+type MarksOptions struct {
+	// Crop draws crop marks at the corners of the trim box, where the printed
+	// sheet is cut.
+	Crop bool
+	// Bleed draws dashed marks at the corners of the bleed box, which show how
+	// far content extends beyond the trim box.
+	Bleed bool
+	// Registration draws registration targets at the middle of each side, by
+	// which the printing plates are aligned.
+	Registration bool
+	// ColorBars draws patches of the process colors, their overprints and
+	// tints of black above the page, by which the press is calibrated.
+	ColorBars bool
+	// PageInfo prints the title of the document, the page number and the
+	// creation date below the page.
+	PageInfo bool
+	// Offset is the distance of the marks from the trim box, by default the
+	// width of the bleed or 3 mm if the page has no bleed box.
+	Offset float64
+	// Length is the length of crop marks and the size of the other marks, 5 mm
+	// by default.
+	Length float64
+	// LineWidth is the width of the lines of the marks, 0.25 point by default.
+	LineWidth float64
+}
+
+// registrationColor is the name of the spot color that prints on all plates
+const registrationColor = "All"
+
+// AddPrinterMarks draws the prepress marks selected by opts around the trim
+// box of the current page, set with SetPageBoxes() or SetPageBox(), so that
+// a short run can be printed and cut without the marks being added later.
+// The marks are drawn outside the trim box, in the area between the bleed box
+// and the edge of the page, which must be large enough to hold them. Crop,
+// bleed and registration marks and the page information are drawn in the
+// registration color, the spot color "All" that prints on all plates, which
+// is added to the document if needed; color bars are drawn in CMYK process
+// colors. The marks are artifacts, and the settings of the document are not
+// changed.
+//
+// The page information uses the current font, or Helvetica if no font has
+// been set. An error occurs if no page has been added or if the current page
+// has no trim box.
+//
+// The AddPrinterMarks example demonstrates this method.
+func (f *Fpdf) AddPrinterMarks(opts MarksOptions) {
+	if f.err != nil {
+		return
+	}
+	if f.page == 0 || f.state != 2 {
+		f.err = fmt.Errorf("a page must be added before printer marks")
+		return
+	}
+	boxes := f.GetPageBoxes(f.page)
+	trim, bleed := boxes.Trim, boxes.Bleed
+	if trim.Wd == 0 {
+		f.err = fmt.Errorf("page %d has no trim box", f.page)
+		return
+	}
+	if bleed.Wd == 0 {
+		bleed = trim
+	}
+	if opts.Length <= 0 {
+		opts.Length = 5 * 72 / 25.4 / f.k
+	}
+	if opts.LineWidth <= 0 {
+		opts.LineWidth = 0.25 / f.k
+	}
+	if opts.Offset <= 0 {
+		opts.Offset = 3 * 72 / 25.4 / f.k
+		if bleed != trim {
+			opts.Offset = bleed.X + bleed.Wd - trim.X - trim.Wd
+			for _, d := range []float64{trim.X - bleed.X, trim.Y - bleed.Y, bleed.Y + bleed.Ht - trim.Y - trim.Ht} {
+				if d > opts.Offset {
+					opts.Offset = d
+				}
+			}
+		}
+	}
+	if _, ok := f.spotColorMap[registrationColor]; !ok {
+		f.AddSpotColor(registrationColor, 100, 100, 100, 100)
+	}
+	f.BeginArtifact()
+	f.SaveGState()
+	f.SetLineWidth(opts.LineWidth)
+	f.SetLineCapStyle("butt")
+	f.SetDashPattern([]float64{}, 0)
+	f.SetDrawSpotColor(registrationColor, 100)
+	f.SetFillSpotColor(registrationColor, 100)
+	off, l := opts.Offset, opts.Length
+	// corners are the corners of the trim box with the directions away from
+	// it
+	corners := []struct{ x, y, sx, sy float64 }{
+		{trim.X, trim.Y, -1, -1}, {trim.X + trim.Wd, trim.Y, 1, -1},
+		{trim.X, trim.Y + trim.Ht, -1, 1}, {trim.X + trim.Wd, trim.Y + trim.Ht, 1, 1},
+	}
+	if opts.Crop {
+		for _, c := range corners {
+			f.Line(c.x+c.sx*off, c.y, c.x+c.sx*(off+l), c.y)
+			f.Line(c.x, c.y+c.sy*off, c.x, c.y+c.sy*(off+l))
+		}
+	}
+	if opts.Bleed && bleed != trim {
+		f.SetDashPattern([]float64{l / 10, l / 10}, 0)
+		for _, c := range corners {
+			// The bleed edges beside the corner
+			bx, by := bleed.X, bleed.Y
+			if c.sx > 0 {
+				bx += bleed.Wd
+			}
+			if c.sy > 0 {
+				by += bleed.Ht
+			}
+			f.Line(c.x+c.sx*off, by, c.x+c.sx*(off+l/2), by)
+			f.Line(bx, c.y+c.sy*off, bx, c.y+c.sy*(off+l/2))
+		}
+		f.SetDashPattern([]float64{}, 0)
+	}
+	if opts.Registration {
+		d := off + l/2
+		cx, cy := trim.X+trim.Wd/2, trim.Y+trim.Ht/2
+		for _, pt := range []PointType{{X: cx, Y: trim.Y - d}, {X: cx, Y: trim.Y + trim.Ht + d},
+			{X: trim.X - d, Y: cy}, {X: trim.X + trim.Wd + d, Y: cy}} {
+			f.registrationTarget(pt.X, pt.Y, l)
+		}
+	}
+	if opts.ColorBars {
+		f.colorBars(trim, off, l)
+	}
+	if opts.PageInfo {
+		f.SetTextSpotColor(registrationColor, 100)
+		if f.fontFamily == "" {
+			f.SetFont("Helvetica", "", 6)
+		} else {
+			f.SetFontSize(6)
+		}
+		info := fmt.Sprintf("Page %d   %s", f.page, timeOrNow(f.creationDate).Format("2006-01-02 15:04"))
+		if len(f.title) > 0 {
+			info = infoText(f.title) + "   " + info
+		}
+		f.Text(trim.X, trim.Y+trim.Ht+off+l/2+f.fontSize/3, info)
+	}
+	f.RestoreGState()
+	f.EndArtifact()
+}
+
+// registrationTarget draws a registration target of size l centered on x, y
+func (f *Fpdf) registrationTarget(x, y, l float64) {
+	r := l * 0.3
+	f.Circle(x, y, r, "D")
+	f.Circle(x, y, r/2, "F")
+	f.Line(x-l/2, y, x+l/2, y)
+	f.Line(x, y-l/2, x, y+l/2)
+}
+
+// colorBars draws patches of process colors centered above the trim box, in
+// the band from off to off+l above it
+func (f *Fpdf) colorBars(trim PageBox, off, l float64) {
+	patches := [][4]byte{{100, 0, 0, 0}, {0, 100, 0, 0}, {0, 0, 100, 0}, {0, 0, 0, 100},
+		{100, 100, 0, 0}, {0, 100, 100, 0}, {100, 0, 100, 0},
+		{0, 0, 0, 75}, {0, 0, 0, 50}, {0, 0, 0, 25}}
+	s := l * 0.6
+	x := trim.X + (trim.Wd-s*float64(len(patches)))/2
+	y := trim.Y - off - l/2 - s/2
+	for _, p := range patches {
+		f.SetFillColorCMYK(p[0], p[1], p[2], p[3])
+		f.Rect(x, y, s, s, "F")
+		x += s
+	}
+}
@@ -20,9 +20,17 @@
 package gofpdf
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
 	"crypto/md5"
+	cryptorand "crypto/rand"
 	"crypto/rc4"
+	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/binary"
+	"fmt"
+	"hash"
+	"io"
 	"math/rand"
 )
 
@@ -34,6 +42,16 @@ const (
 	CnProtectAnnotForms = 32
 )
 
+// Encryption algorithm constants used with SetProtectionEx
+const (
+	// EncryptionRC4 selects the 40-bit RC4 scheme (PDF 1.1, revision 2) that
+	// is used by SetProtection.
+	EncryptionRC4 = iota
+	// EncryptionAES256 selects the 256-bit AES scheme (PDF 2.0, revision 6)
+	// with AESV3 crypt filters for strings and streams.
+	EncryptionAES256
+)
+
 type protectType struct {
 	encrypted     bool
 	uValue        []byte
@@ -44,6 +62,96 @@ type protectType struct {
 	objNum        int
 	rc4cipher     *rc4.Cipher
 	rc4n          uint32 // Object number associated with rc4 cipher
+	mode          int    // EncryptionRC4 or EncryptionAES256
+	oeValue       []byte // Encrypted file key for owner (AES-256 only)
+	ueValue       []byte // Encrypted file key for user (AES-256 only)
+	permsValue    []byte // Encrypted permissions (AES-256 only)
+	err           error  // First failure of the random number source
+}
+
+// encrypt encrypts the contents of buf in the context of object n. With
+// RC4 the buffer is modified in place; with AES-256 it is replaced by the
+// initialization vector followed by the padded cipher text.
+func (p *protectType) encrypt(n uint32, buf *[]byte) {
+	if p.mode == EncryptionAES256 {
+		*buf = p.aesEncrypt(*buf)
+	} else {
+		p.rc4(n, buf)
+	}
+}
+
+// encryptWriter returns a writer that encrypts the data written to it in the
+// context of object n before passing it on to w. This allows streams of
+// unknown length to be encrypted without holding them in memory. Close must
+// be called to flush the final block; it does not close w. If the document is
+// not encrypted the data is passed through unchanged.
+func (p *protectType) encryptWriter(n uint32, w io.Writer) io.WriteCloser {
+	if !p.encrypted {
+		return nopWriteCloser{w}
+	}
+	if p.mode == EncryptionAES256 {
+		block, _ := aes.NewCipher(p.encryptionKey)
+		iv := p.randomBytes(aes.BlockSize)
+		return &aesWriter{w: w, iv: iv, mode: cipher.NewCBCEncrypter(block, iv)}
+	}
+	c, _ := rc4.NewCipher(p.objectKey(n))
+	return nopWriteCloser{cipher.StreamWriter{S: c, W: w}}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// aesWriter encrypts data in CBC mode as it is written, holding back any
+// partial block until more data arrives or the writer is closed
+type aesWriter struct {
+	w    io.Writer
+	iv   []byte // written ahead of the first block
+	mode cipher.BlockMode
+	buf  []byte
+}
+
+func (a *aesWriter) Write(p []byte) (n int, err error) {
+	if a.iv != nil {
+		if _, err = a.w.Write(a.iv); err != nil {
+			return
+		}
+		a.iv = nil
+	}
+	a.buf = append(a.buf, p...)
+	full := len(a.buf) - len(a.buf)%aes.BlockSize
+	if full > 0 {
+		out := make([]byte, full)
+		a.mode.CryptBlocks(out, a.buf[:full])
+		if _, err = a.w.Write(out); err != nil {
+			return
+		}
+		a.buf = append(a.buf[:0], a.buf[full:]...)
+	}
+	return len(p), nil
+}
+
+// Close pads and writes the final block
+func (a *aesWriter) Close() error {
+	padLen := aes.BlockSize - len(a.buf)%aes.BlockSize
+	for j := 0; j < padLen; j++ {
+		a.buf = append(a.buf, byte(padLen))
+	}
+	_, err := a.Write(nil)
+	return err
+}
+
+// streamLength returns the length of a stream of size n once it has been
+// encrypted. This is the value that belongs in the stream's /Length entry.
+func (p *protectType) streamLength(n int) int {
+	if p.encrypted && p.mode == EncryptionAES256 {
+		return aes.BlockSize + (n/aes.BlockSize+1)*aes.BlockSize
+	}
+	return n
 }
 
 func (p *protectType) rc4(n uint32, buf *[]byte) {
@@ -101,6 +209,7 @@ func (p *protectType) setProtection(privFlag byte, userPassStr, ownerPassStr str
 	}
 	userPass = append(userPass, p.padding...)[0:32]
 	ownerPass = append(ownerPass, p.padding...)[0:32]
+	p.mode = EncryptionRC4
 	p.encrypted = true
 	p.oValue = oValueGen(userPass, ownerPass)
 	var buf []byte
@@ -112,3 +221,121 @@ func (p *protectType) setProtection(privFlag byte, userPassStr, ownerPassStr str
 	p.uValue = p.uValueGen()
 	p.pValue = -(int(privFlag^255) + 1)
 }
+
+// aesEncrypt encrypts buf with the file encryption key using AES-256 in CBC
+// mode. A random initialization vector is prepended and PKCS#5 padding is
+// appended, as required by the AESV3 crypt filter. AES-256 uses the file key
+// directly, so no per-object key is derived.
+func (p *protectType) aesEncrypt(buf []byte) []byte {
+	block, _ := aes.NewCipher(p.encryptionKey)
+	padLen := aes.BlockSize - len(buf)%aes.BlockSize
+	out := make([]byte, aes.BlockSize+len(buf)+padLen)
+	copy(out, p.randomBytes(aes.BlockSize))
+	copy(out[aes.BlockSize:], buf)
+	for j := len(out) - padLen; j < len(out); j++ {
+		out[j] = byte(padLen)
+	}
+	cipher.NewCBCEncrypter(block, out[:aes.BlockSize]).CryptBlocks(out[aes.BlockSize:], out[aes.BlockSize:])
+	return out
+}
+
+// aesCBCNoPad encrypts buf, which must be a multiple of the AES block size,
+// in CBC mode with the specified key and initialization vector and no
+// padding.
+func aesCBCNoPad(key, iv, buf []byte) []byte {
+	block, _ := aes.NewCipher(key)
+	out := make([]byte, len(buf))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(out, buf)
+	return out
+}
+
+// hashR6 implements the revision 6 password hash (algorithm 2.B of ISO
+// 32000-2). udata is the 48-byte U value when computing owner values and nil
+// otherwise.
+func hashR6(pass, salt, udata []byte) []byte {
+	var h hash.Hash = sha256.New()
+	h.Write(pass)
+	h.Write(salt)
+	h.Write(udata)
+	k := h.Sum(nil)
+	for round := 1; ; round++ {
+		var k1 []byte
+		for j := 0; j < 64; j++ {
+			k1 = append(k1, pass...)
+			k1 = append(k1, k...)
+			k1 = append(k1, udata...)
+		}
+		e := aesCBCNoPad(k[0:16], k[16:32], k1)
+		sum := 0
+		for _, b := range e[0:16] {
+			sum += int(b)
+		}
+		switch sum % 3 {
+		case 0:
+			h = sha256.New()
+		case 1:
+			h = sha512.New384()
+		default:
+			h = sha512.New()
+		}
+		h.Write(e)
+		k = h.Sum(nil)
+		// At least 64 rounds, then continue until the last byte of e is no
+		// greater than the round count less 32
+		if round >= 64 && int(e[len(e)-1]) <= round-32 {
+			break
+		}
+	}
+	return k[0:32]
+}
+
+// randomBytes returns a slice of n cryptographically random bytes. If the
+// random number source fails, the error is retained in p.err; encryption
+// must not proceed with the predictable bytes that are returned in that case.
+func (p *protectType) randomBytes(n int) []byte {
+	b := make([]byte, n)
+	if _, err := cryptorand.Read(b); err != nil && p.err == nil {
+		p.err = fmt.Errorf("unable to generate random bytes for encryption: %s", err)
+	}
+	return b
+}
+
+func (p *protectType) setProtectionAES256(privFlag byte, userPassStr, ownerPassStr string) {
+	privFlag = 192 | (privFlag & (CnProtectCopy | CnProtectModify | CnProtectPrint | CnProtectAnnotForms))
+	userPass := []byte(userPassStr)
+	var ownerPass []byte
+	if ownerPassStr == "" {
+		ownerPass = p.randomBytes(16)
+	} else {
+		ownerPass = []byte(ownerPassStr)
+	}
+	// Passwords are limited to 127 bytes of UTF-8
+	if len(userPass) > 127 {
+		userPass = userPass[0:127]
+	}
+	if len(ownerPass) > 127 {
+		ownerPass = ownerPass[0:127]
+	}
+	zeroIV := make([]byte, aes.BlockSize)
+	p.mode = EncryptionAES256
+	p.encrypted = true
+	p.encryptionKey = p.randomBytes(32)
+	// U and UE: hash || validation salt || key salt
+	salt := p.randomBytes(16)
+	p.uValue = append(hashR6(userPass, salt[0:8], nil), salt...)
+	p.ueValue = aesCBCNoPad(hashR6(userPass, salt[8:16], nil), zeroIV, p.encryptionKey)
+	// O and OE are computed in the same way but also cover the U value
+	salt = p.randomBytes(16)
+	p.oValue = append(hashR6(ownerPass, salt[0:8], p.uValue), salt...)
+	p.oeValue = aesCBCNoPad(hashR6(ownerPass, salt[8:16], p.uValue), zeroIV, p.encryptionKey)
+	p.pValue = -(int(privFlag^255) + 1)
+	// Perms: P (extended to 64 bits), metadata flag, "adb" and random filler,
+	// encrypted with the file key in ECB mode (a single block)
+	perms := make([]byte, 16)
+	binary.LittleEndian.PutUint32(perms, uint32(int32(p.pValue)))
+	perms[4], perms[5], perms[6], perms[7] = 0xff, 0xff, 0xff, 0xff
+	perms[8] = 'T'
+	perms[9], perms[10], perms[11] = 'a', 'd', 'b'
+	copy(perms[12:], p.randomBytes(4))
+	p.permsValue = aesCBCNoPad(p.encryptionKey, zeroIV, perms)
+}
@@ -0,0 +1,84 @@
+package gofpdf
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"encoding/hex"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+// TestAES256RoundTrip recovers the file key of an AES-256 encrypted document
+// from its user password and uses it to decrypt a page content stream
+func TestAES256RoundTrip(t *testing.T) {
+	const userPass = "123"
+	pdf := New("P", "mm", "A4", "")
+	pdf.SetCompression(false)
+	pdf.SetProtectionEx(EncryptionAES256, CnProtectPrint, userPass, "abc")
+	pdf.AddPage()
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.Write(10, "Round trip")
+	var buf bytes.Buffer
+	err := pdf.Output(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := buf.Bytes()
+	hexValue := func(keyStr string) []byte {
+		m := regexp.MustCompile(`/` + keyStr + ` <([0-9a-f]*)>`).FindSubmatch(doc)
+		if m == nil {
+			t.Fatalf("/%s not found", keyStr)
+		}
+		b, _ := hex.DecodeString(string(m[1]))
+		return b
+	}
+	u := hexValue("U")
+	ue := hexValue("UE")
+	perms := hexValue("Perms")
+	if len(u) != 48 || len(ue) != 32 || len(perms) != 16 {
+		t.Fatalf("unexpected lengths of U (%d), UE (%d) and Perms (%d)", len(u), len(ue), len(perms))
+	}
+	// Authenticate the user password with the validation salt
+	if !bytes.Equal(hashR6([]byte(userPass), u[32:40], nil), u[0:32]) {
+		t.Fatal("user password does not validate against /U")
+	}
+	// Recover the file key with the key salt
+	block, _ := aes.NewCipher(hashR6([]byte(userPass), u[40:48], nil))
+	key := make([]byte, 32)
+	cipher.NewCBCDecrypter(block, make([]byte, aes.BlockSize)).CryptBlocks(key, ue)
+	// Perms is a single block encrypted with the file key
+	block, _ = aes.NewCipher(key)
+	p := make([]byte, 16)
+	block.Decrypt(p, perms)
+	if string(p[9:12]) != "adb" {
+		t.Fatalf("decrypted /Perms is not valid: %x", p)
+	}
+	pm := regexp.MustCompile(`/P (-?\d+)`).FindSubmatch(doc)
+	pValue, _ := strconv.Atoi(string(pm[1]))
+	if int32(binary.LittleEndian.Uint32(p)) != int32(pValue) {
+		t.Fatalf("permissions in /Perms do not match /P %d", pValue)
+	}
+	// Decrypt the first page content stream
+	sm := regexp.MustCompile(`(?s)/Length (\d+)>>\nstream\n`).FindSubmatchIndex(doc)
+	if sm == nil {
+		t.Fatal("no stream found")
+	}
+	n, _ := strconv.Atoi(string(doc[sm[2]:sm[3]]))
+	data := doc[sm[1] : sm[1]+n]
+	if len(data) < 2*aes.BlockSize || len(data)%aes.BlockSize != 0 {
+		t.Fatalf("encrypted stream has invalid length %d", len(data))
+	}
+	plain := make([]byte, len(data)-aes.BlockSize)
+	cipher.NewCBCDecrypter(block, data[:aes.BlockSize]).CryptBlocks(plain, data[aes.BlockSize:])
+	padLen := int(plain[len(plain)-1])
+	if padLen < 1 || padLen > aes.BlockSize {
+		t.Fatalf("invalid padding %d", padLen)
+	}
+	plain = plain[:len(plain)-padLen]
+	if !bytes.Contains(plain, []byte("(Round trip)Tj")) {
+		t.Fatalf("decrypted stream does not contain the page text: %q", plain)
+	}
+}
@@ -0,0 +1,146 @@
+package gofpdf
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"text/template"
+)
+
+// RegionElement is a cell of a region template created with
+// NewRegionTemplate(). Its text is a Go text/template, such as
+// "Invoice {{.Number}}", whose placeholders are filled in from the data passed
+// to FillTemplate().
+type RegionElement struct {
+	// X and Y are the position of the cell relative to the top left corner
+	// of the region, in the unit of measure specified in New().
+	X, Y float64
+	// W is the width of the cell; 0 extends it to the right margin. H is the
+	// height of the cell, or of each of its lines if MultiLine is true.
+	W, H float64
+	// Text is the template of the text of the cell.
+	Text string
+	// Border, Align and Fill are used in the way CellFormat() uses them.
+	Border string
+	Align  string
+	Fill   bool
+	// MultiLine wraps the text into lines in the way MultiCell() does. The
+	// region grows with the lines of the cell.
+	MultiLine bool
+	// FontFamily, FontStyle and FontSize specify the font of the cell. The
+	// current font is used for those that are empty or 0.
+	FontFamily string
+	FontStyle  string
+	FontSize   float64
+}
+
+// RegionTemplate is a region of a page, such as a header block, a table row
+// or a total line of a report, that is defined once and printed repeatedly
+// with FillTemplate().
+type RegionTemplate struct {
+	height   float64
+	elements []RegionElement
+	texts    []*template.Template
+}
+
+// NewRegionTemplate creates a region template with the cells specified by
+// elements, whose placeholders are parsed as Go templates. height is the
+// minimum height of the region, in the unit of measure specified in New();
+// the region extends to the bottom of its lowest cell if that is lower.
+//
+// The FillTemplate example demonstrates this function.
+func NewRegionTemplate(height float64, elements ...RegionElement) (*RegionTemplate, error) {
+	tpl := &RegionTemplate{height: height, elements: elements}
+	for j, e := range elements {
+		t, err := template.New(fmt.Sprintf("element%d", j)).Option("missingkey=error").Parse(e.Text)
+		if err != nil {
+			return nil, err
+		}
+		tpl.texts = append(tpl.texts, t)
+	}
+	return tpl, nil
+}
+
+// FillTemplate prints the region template tpl at the current position, with
+// its placeholders filled in from data, which is usually a struct or a map.
+// If the region does not fit in the rest of the page, it is printed at the
+// top of the next page or column instead, if automatic page breaking is
+// enabled. The current position is moved to the left edge of the region below
+// it, so that regions that are filled in one after the other are stacked.
+//
+// The FillTemplate example demonstrates this method.
+func (f *Fpdf) FillTemplate(tpl *RegionTemplate, data interface{}) {
+	if f.err != nil {
+		return
+	}
+	if f.currentFont.Name == "" {
+		f.err = fmt.Errorf("font has not been set; unable to render text")
+		return
+	}
+	texts := make([]string, len(tpl.elements))
+	var buf bytes.Buffer
+	for j, t := range tpl.texts {
+		buf.Reset()
+		if f.err = t.Execute(&buf, data); f.err != nil {
+			return
+		}
+		texts[j] = buf.String()
+	}
+	family, style, size := f.fontFamily, f.currentStyle(), f.fontSizePt
+	x := f.x
+	height := tpl.height
+	for j, e := range tpl.elements {
+		h := e.H
+		if e.MultiLine {
+			f.regionFont(e, family, style, size)
+			h *= float64(len(f.SplitText(texts[j], f.regionWidth(e, x))))
+		}
+		height = math.Max(height, e.Y+h)
+	}
+	f.SetFont(family, style, size)
+	// The region keeps its distance from the left margin in the next column
+	// or on the next page
+	dx := x - f.lMargin
+	if f.y+height > f.pageBreakTrigger && !f.inHeader && !f.inFooter && f.acceptBreak() {
+		f.AddPageFormat(f.curOrientation, f.curPageSize)
+		if f.err != nil {
+			return
+		}
+	}
+	x, y := f.lMargin+dx, f.y
+	for j, e := range tpl.elements {
+		f.regionFont(e, family, style, size)
+		f.SetXY(x+e.X, y+e.Y)
+		if e.MultiLine {
+			f.MultiCell(f.regionWidth(e, x), e.H, texts[j], e.Border, e.Align, e.Fill)
+		} else {
+			f.CellFormat(e.W, e.H, texts[j], e.Border, 0, e.Align, e.Fill, 0, "")
+		}
+	}
+	f.SetFont(family, style, size)
+	f.x, f.y = x, y+height
+}
+
+// regionFont sets the font of the region element e, using the font family,
+// style and size of the text around the region for those it does not specify
+func (f *Fpdf) regionFont(e RegionElement, family, style string, size float64) {
+	if e.FontFamily != "" {
+		family = e.FontFamily
+	}
+	if e.FontFamily != "" || e.FontStyle != "" {
+		style = e.FontStyle
+	}
+	if e.FontSize > 0 {
+		size = e.FontSize
+	}
+	f.SetFont(family, style, size)
+}
+
+// regionWidth returns the width of the region element e in a region whose
+// left edge is at x
+func (f *Fpdf) regionWidth(e RegionElement, x float64) float64 {
+	if e.W == 0 {
+		return f.w - f.rMargin - x - e.X
+	}
+	return e.W
+}
@@ -0,0 +1,220 @@
+package gofpdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"strings"
+)
+
+// resampledImage returns the image with which the image info, registered as
+// imgName, is placed with the width w and height h, in the unit of measure of
+// the document, at a resolution of at most options.MaxDPI. This is info
+// itself unless it has more pixels than that resolution allows and can be
+// decoded, in which case it is a copy of info with fewer pixels.
+func (f *Fpdf) resampledImage(imgName string, info *ImageInfoType, w, h float64, options ImageOptions) *ImageInfoType {
+	if options.MaxDPI <= 0 {
+		return info
+	}
+	pw := int(math.Ceil(math.Abs(w) * f.k / 72 * options.MaxDPI))
+	ph := int(math.Ceil(math.Abs(h) * f.k / 72 * options.MaxDPI))
+	if pw >= int(info.w) && ph >= int(info.h) {
+		return info
+	}
+	if pw < 1 {
+		pw = 1
+	}
+	if ph < 1 {
+		ph = 1
+	}
+	if pw > int(info.w) {
+		pw = int(info.w)
+	}
+	if ph > int(info.h) {
+		ph = int(info.h)
+	}
+	key := sprintf("%s\x00resampled %dx%d %d", imgName, pw, ph, options.JPEGQuality)
+	if resampled, ok := f.images[key]; ok {
+		return resampled
+	}
+	if f.err = info.loadImage(); f.err != nil {
+		return info
+	}
+	img := info.decode()
+	info.unloadImage()
+	if img == nil {
+		return info
+	}
+	tp := "png"
+	if info.f == "DCTDecode" {
+		tp = "jpg"
+	}
+	resampled := f.parseimage(resampleImage(img, pw, ph), tp, options.JPEGQuality)
+	if f.err != nil {
+		return info
+	}
+	resampled.dpi = info.dpi * float64(pw) / info.w
+	resampled.icc = imageICCProfile(info.icc, iccComponents[resampled.cs])
+	resampled.orientation = info.orientation
+	if resampled.i, f.err = generateImageID(resampled); f.err != nil {
+		return info
+	}
+	f.images[key] = resampled
+	return resampled
+}
+
+// decode returns the pixels of the image, or nil if the image is not a JPEG
+// image or a Flate-compressed image in a gray, RGB or indexed color space
+// without a color key mask, or if its data cannot be decoded
+func (info *ImageInfoType) decode() image.Image {
+	switch {
+	case info.f == "DCTDecode" && info.cs != "DeviceCMYK":
+		img, err := jpeg.Decode(bytes.NewReader(info.data))
+		if err != nil {
+			return nil
+		}
+		return img
+	case info.f != "FlateDecode" || len(info.trns) > 0:
+		return nil
+	}
+	var ct byte
+	colors := 1
+	switch info.cs {
+	case "DeviceGray":
+	case "DeviceRGB":
+		ct, colors = 2, 3
+	case "Indexed":
+		ct = 3
+	default:
+		return nil
+	}
+	img := decodeSamples(info.data, int(info.w), int(info.h), info.bpc, ct, colors,
+		strings.Contains(info.dp, "/Predictor"), info.pal)
+	if img == nil || len(info.smask) == 0 {
+		return img
+	}
+	bpc := 8
+	if info.bpc == 16 {
+		bpc = 16
+	}
+	mask := decodeSamples(info.smask, int(info.w), int(info.h), bpc, 0, 1, true, nil)
+	if mask == nil {
+		return nil
+	}
+	// The soft mask becomes the alpha channel
+	b := img.Bounds()
+	nrgba := image.NewNRGBA(b)
+	draw.Draw(nrgba, b, img, b.Min, draw.Src)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			a, _, _, _ := mask.At(x, y).RGBA()
+			nrgba.Pix[nrgba.PixOffset(x, y)+3] = uint8(a >> 8)
+		}
+	}
+	return nrgba
+}
+
+// decodeSamples returns the image of the Flate-compressed samples data of an
+// image of size w x h with bpc bits per sample, PNG color type ct and colors
+// samples per pixel, whose rows begin with PNG filter types if predicted is
+// true, and with the palette pal if it is indexed, or nil if the data cannot
+// be decoded
+func decodeSamples(data []byte, w, h, bpc int, ct byte, colors int, predicted bool, pal []byte) image.Image {
+	samples, err := sliceUncompress(data)
+	if err != nil {
+		return nil
+	}
+	if !predicted {
+		// The rows begin with the filter type None
+		rowLen := (w*colors*bpc + 7) / 8
+		if len(samples) < rowLen*h {
+			return nil
+		}
+		rows := make([]byte, 0, (rowLen+1)*h)
+		for y := 0; y < h; y++ {
+			rows = append(rows, 0)
+			rows = append(rows, samples[y*rowLen:(y+1)*rowLen]...)
+		}
+		samples = rows
+	}
+	var buf bytes.Buffer
+	chunk := func(tp string, data []byte) {
+		binary.Write(&buf, binary.BigEndian, uint32(len(data)))
+		crc := crc32.NewIEEE()
+		crc.Write([]byte(tp))
+		crc.Write(data)
+		buf.WriteString(tp)
+		buf.Write(data)
+		binary.Write(&buf, binary.BigEndian, crc.Sum32())
+	}
+	buf.WriteString("\x89PNG\r\n\x1a\n")
+	hdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(hdr, uint32(w))
+	binary.BigEndian.PutUint32(hdr[4:], uint32(h))
+	hdr[8], hdr[9] = byte(bpc), ct
+	chunk("IHDR", hdr)
+	if ct == 3 {
+		chunk("PLTE", pal)
+	}
+	chunk("IDAT", sliceCompressLevel(samples, zlib.NoCompression))
+	chunk("IEND", nil)
+	img, err := png.Decode(&buf)
+	if err != nil {
+		return nil
+	}
+	return img
+}
+
+// resampleImage returns img reduced to the size w x h, with each pixel the
+// average of the pixels of img it covers. Gray images remain gray images.
+func resampleImage(img image.Image, w, h int) image.Image {
+	b := img.Bounds()
+	src := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(src, src.Rect, img, b.Min, draw.Src)
+	sw, sh := b.Dx(), b.Dy()
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0, y1 := y*sh/h, (y+1)*sh/h
+		if y1 == y0 {
+			y1++
+		}
+		for x := 0; x < w; x++ {
+			x0, x1 := x*sw/w, (x+1)*sw/w
+			if x1 == x0 {
+				x1++
+			}
+			// The colors are weighted by their alpha values
+			var r, g, bl, a uint64
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					pa := uint64(p[3])
+					r += uint64(p[0]) * pa
+					g += uint64(p[1]) * pa
+					bl += uint64(p[2]) * pa
+					a += pa
+				}
+			}
+			d := dst.Pix[y*dst.Stride+x*4:]
+			if a > 0 {
+				d[0], d[1], d[2] = uint8((r+a/2)/a), uint8((g+a/2)/a), uint8((bl+a/2)/a)
+			}
+			n := uint64((y1 - y0) * (x1 - x0))
+			d[3] = uint8((a + n/2) / n)
+		}
+	}
+	switch img.(type) {
+	case *image.Gray, *image.Gray16:
+		gray := image.NewGray(dst.Rect)
+		draw.Draw(gray, gray.Rect, dst, image.Point{}, draw.Src)
+		return gray
+	}
+	return dst
+}
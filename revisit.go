@@ -0,0 +1,130 @@
+package gofpdf
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// revisitType holds the settings of the page being written while
+// SetPageForWriting() has made an earlier page the current page
+type revisitType struct {
+	saved bool              // the page being written has been left
+	gs    graphicsStateType // settings of the page being written
+	x, y  float64           // position on the page being written
+}
+
+// SetPageForWriting makes the page n, which is one-based, the current page
+// so that content can be added to it after later pages have been added, for
+// example to print the total of a table or a "continued on page 5" note once
+// it is known, without aliases. Unlike SetPage(), it keeps the settings of
+// the document and the page in step: the content that the page has is
+// enclosed in a saved graphics state, and the drawing and text settings of
+// the document, such as the font, colors and line width, are applied anew to
+// the content that follows. The position is not changed.
+//
+// The page being written, the one added last, can be returned to with
+// SetPageForWriting() or by AddPage() and Close(), which print its footer;
+// its settings and position are then those it had when it was left. Headers
+// and footers are not printed again on earlier pages.
+//
+// An error occurs if n is not a page of the document, if it has been flushed
+// to an output writer, if a block, layer, artifact, clipping operation,
+// transformation or saved graphics state has not ended on the current page,
+// or if the document has been closed.
+//
+// The SetPageForWriting example demonstrates this method.
+func (f *Fpdf) SetPageForWriting(n int) {
+	if f.err != nil {
+		return
+	}
+	switch {
+	case f.state == 3:
+		f.err = fmt.Errorf("document has been closed")
+	case n < 1 || n > f.PageCount():
+		f.err = fmt.Errorf("page %d does not exist", n)
+	case f.pageFlushed(n):
+		f.err = fmt.Errorf("page %d has been flushed and cannot be written", n)
+	case len(f.nests) > 0:
+		f.err = fmt.Errorf("clipping, transformations and graphics states must end before another page is written")
+	case f.block != nil:
+		f.err = fmt.Errorf("block must end before another page is written")
+	case len(f.layer.active) > 0 || f.artifacts > 0:
+		f.err = fmt.Errorf("layers and artifacts must end before another page is written")
+	}
+	if f.err != nil || n == f.page {
+		return
+	}
+	open := 0
+	if f.state == 2 && !f.pageClosed {
+		open = f.openPage()
+	}
+	if f.page == open {
+		f.revisit = revisitType{saved: true, gs: f.graphicsState(), x: f.x, y: f.y}
+	}
+	if n == open {
+		f.resumeOpenPage()
+		return
+	}
+	f.selectPage(n)
+	f.state = 2
+	// The content of the page ends with the graphics state it started with
+	var buf bytes.Buffer
+	buf.WriteString("q\n")
+	buf.Write(f.pages[n].Bytes())
+	buf.WriteString("Q\n")
+	f.pages[n] = &buf
+	f.putPageSettings()
+}
+
+// resumeOpenPage makes the page being written the current page again, with
+// the settings and position it had when SetPageForWriting() left it
+func (f *Fpdf) resumeOpenPage() {
+	if open := f.openPage(); f.page != open {
+		f.selectPage(open)
+	}
+	if f.revisit.saved {
+		f.setGraphicsState(&f.revisit.gs)
+		f.x, f.y = f.revisit.x, f.revisit.y
+		f.revisit = revisitType{}
+	}
+}
+
+// putPageSettings applies the drawing and text settings of the document that
+// differ from the initial graphics state to the current page
+func (f *Fpdf) putPageSettings() {
+	f.outf("%d J", f.capStyle)
+	f.outf("%d j", f.joinStyle)
+	if f.miterLimit != 10 {
+		f.outf("%.2f M", f.miterLimit)
+	}
+	f.outf("%.2f w", f.lineWidth*f.k)
+	if len(f.dashArray) > 0 {
+		f.outputDashPattern()
+	}
+	if f.fontFamily != "" {
+		family, style, size := f.fontFamily, f.currentStyle(), f.fontSizePt
+		f.fontFamily = ""
+		f.SetFont(family, style, size)
+	}
+	if f.color.draw.str != "0 G" {
+		f.out(f.color.draw.str)
+	}
+	if f.color.fill.str != "0 g" {
+		f.out(f.color.fill.str)
+	}
+	if f.overprint != (overprintType{}) {
+		f.outputOverprint()
+	}
+	if f.charSpacing != 0 {
+		f.SetCharSpacing(f.charSpacing)
+	}
+	if f.wordSpacing != 0 {
+		f.SetWordSpacing(f.wordSpacing)
+	}
+	if f.textRise != 0 {
+		f.SetTextRise(f.textRise)
+	}
+	if f.alpha != 1 || (f.blendMode != "Normal" && f.blendMode != "") {
+		f.SetAlpha(f.alpha, f.blendMode)
+	}
+}
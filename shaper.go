@@ -0,0 +1,201 @@
+package gofpdf
+
+import (
+	"fmt"
+	"math"
+)
+
+// ShapedGlyph is a glyph produced by a Shaper. GlyphID is the index of the
+// glyph in the font. XAdvance is the distance by which the current position
+// moves after the glyph is drawn; XOffset and YOffset displace the glyph from
+// the current position without moving it, with YOffset pointing up. All three
+// are expressed in thousandths of the font size, which is the unit of the
+// widths in a PDF font.
+type ShapedGlyph struct {
+	GlyphID  int
+	XAdvance float64
+	XOffset  float64
+	YOffset  float64
+}
+
+// Shaper is the interface for text shaping engines used by WriteShaped().
+// Shape converts txtStr, a line of text in logical order, to glyphs of the
+// TrueType font whose file contents are passed in fontData. rtl is true if
+// the base direction of the line is right-to-left. The glyphs are returned in
+// visual order, from left to right, as produced for example by HarfBuzz.
+//
+// Shaping engines apply the substitutions (GSUB) and positioning (GPOS) that
+// scripts such as Devanagari and Thai require. An adapter for any such engine
+// can be registered with SetShaper().
+type Shaper interface {
+	Shape(fontData []byte, txtStr string, rtl bool) ([]ShapedGlyph, error)
+}
+
+// SetShaper registers the shaping engine used by WriteShaped(). If s is nil,
+// which is the default, WriteShaped() uses a built-in shaper. It applies the
+// bidirectional algorithm and Arabic shaping in the same way as Write(), and
+// the standard ligatures and glyph compositions ("liga", "rlig" and "ccmp"
+// features) found in the font, and kerning if it is enabled with
+// SetKerning(), but no other substitutions or positioning.
+func (f *Fpdf) SetShaper(s Shaper) {
+	f.shaper = s
+}
+
+// WriteShaped prints a line of text at the current position using shaped
+// glyphs, and moves the current position to the end of the text. h is the
+// line height in the unit of measure specified in New(); the text is aligned
+// with the line in the same way as with Write(). Unlike Write(), the text is
+// not broken into lines, so txtStr must fit on the current line.
+//
+// The text is converted to glyphs by the shaper registered with SetShaper(),
+// or by the built-in shaper. A UTF-8 font, added with AddUTF8Font(), must be
+// selected. So that the text can be extracted from the document, the original
+// characters are attached to the glyphs as their actual text.
+//
+// The WriteShaped example demonstrates this method.
+func (f *Fpdf) WriteShaped(h float64, txtStr string) {
+	if f.err != nil {
+		return
+	}
+	if !f.isCurrentUTF8 || f.currentFont.utf8File == nil {
+		f.err = fmt.Errorf("WriteShaped requires a UTF-8 font")
+		return
+	}
+	utf := f.currentFont.utf8File
+	base := f.bidiBaseLevel([]rune(txtStr))
+	var glyphs []ShapedGlyph
+	if f.shaper != nil {
+		var err error
+		glyphs, err = f.shaper.Shape(utf.fileReader.array, txtStr, base == 1)
+		if err != nil {
+			f.err = err
+			return
+		}
+	} else {
+		utf.mu.Lock()
+		glyphs = utf.shape(txtStr, base)
+		if f.kerning {
+			for j := 1; j < len(glyphs); j++ {
+				glyphs[j-1].XAdvance += float64(utf.kerning(glyphs[j-1].GlyphID, glyphs[j].GlyphID))
+			}
+		}
+		utf.mu.Unlock()
+	}
+	var s fmtBuffer
+	if f.colorFlag {
+		s.printf("q %s ", f.color.text.str)
+	}
+	y := f.y + .5*h + .3*f.fontSize
+	s.printf("/Span <</ActualText (%s)>> BDC ", f.escape(utf8toutf16(txtStr)))
+	s.printf("BT %.2f %.2f Td", f.x*f.k, (f.h-y)*f.k)
+	// Glyphs are shown with TJ arrays whose numbers correct the difference
+	// between their widths in the font and their shaped positions. A change
+	// of vertical offset ends the array and sets the text rise.
+	inArray := false
+	endArray := func() {
+		if inArray {
+			s.printf("] TJ")
+			inArray = false
+		}
+	}
+	rise := 0.0
+	w := 0.0
+	for _, g := range glyphs {
+		code := f.glyphCode(g.GlyphID)
+		if f.err != nil {
+			return
+		}
+		if g.YOffset != rise {
+			endArray()
+			rise = g.YOffset
+			s.printf(" %.2f Ts", rise*f.fontSizePt/1000)
+		}
+		if !inArray {
+			s.printf(" [")
+			inArray = true
+		}
+		if g.XOffset != 0 {
+			s.printf("%.2f", -g.XOffset)
+		}
+		s.printf("(%s)", f.escape(string([]byte{byte(code >> 8), byte(code)})))
+		if adj := f.codeWidth(code) - g.XAdvance + g.XOffset; math.Abs(adj) >= 0.005 {
+			s.printf("%.2f", adj)
+		}
+		// The character spacing follows each glyph
+		w += g.XAdvance*f.fontSize/1000 + f.charSpacing
+	}
+	endArray()
+	if rise != 0 {
+		s.printf(" 0 Ts")
+	}
+	s.printf(" ET EMC")
+	if f.underline {
+		s.printf(" %s", f.underlineRect(f.x, y, w))
+	}
+	if f.strikeout {
+		s.printf(" %s", f.strikeoutRect(f.x, y, w))
+	}
+	if f.colorFlag {
+		s.printf(" Q")
+	}
+	f.out(s.String())
+	f.x += w
+	f.lasth = h
+}
+
+// glyphCode returns the character code with which glyph gid of the current
+// UTF-8 font is shown. Glyphs that the font's cmap assigns to a character use
+// the code of that character; other glyphs, such as ligatures and
+// contextual forms, are assigned a code in the Unicode private use area.
+func (f *Fpdf) glyphCode(gid int) int {
+	utf := f.currentFont.utf8File
+	utf.mu.Lock()
+	defer utf.mu.Unlock()
+	if utf.glyphCodes == nil {
+		utf.glyphCodes = make(map[int]int)
+		utf.privateGlyphs = make(map[int]int)
+		utf.runeGlyph(0)
+		for r, g := range utf.runeGlyphs {
+			if c, ok := utf.glyphCodes[g]; g != 0 && r < 0x10000 && f.currentFont.Cw[r] != 0 && (!ok || r < c) {
+				utf.glyphCodes[g] = r
+			}
+		}
+	}
+	code, ok := utf.glyphCodes[gid]
+	if !ok {
+		// Skip codes that the font maps to a glyph of its own
+		code = 0xe000
+		for {
+			if _, used := utf.privateGlyphs[code]; !used && utf.runeGlyphs[code] == 0 {
+				break
+			}
+			code++
+		}
+		if code > 0xf8ff {
+			f.err = fmt.Errorf("too many glyphs without a character code in font %s", f.currentFont.Name)
+			return 0
+		}
+		width := int(math.Round(utf.glyphAdvance(gid)))
+		if width == 0 {
+			width = 65535
+		}
+		f.currentFont.Cw[code] = width
+		utf.glyphCodes[gid] = code
+		utf.privateGlyphs[code] = gid
+	}
+	f.currentFont.usedRunes[code] = code
+	return code
+}
+
+// codeWidth returns the width, in thousandths of the font size, with which a
+// PDF viewer displays code of the current UTF-8 font
+func (f *Fpdf) codeWidth(code int) float64 {
+	switch w := f.currentFont.Cw[code]; w {
+	case 0:
+		return float64(f.currentFont.Desc.MissingWidth)
+	case 65535:
+		return 0
+	default:
+		return float64(w)
+	}
+}
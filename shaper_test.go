@@ -0,0 +1,67 @@
+package gofpdf
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// testShaper returns fixed glyphs regardless of the text
+type testShaper []ShapedGlyph
+
+func (s testShaper) Shape(fontData []byte, txtStr string, rtl bool) ([]ShapedGlyph, error) {
+	return s, nil
+}
+
+// TestWriteShaped checks that glyphs without a character in the font's cmap
+// are given private use codes and that shaped positions are honored
+func TestWriteShaped(t *testing.T) {
+	pdf := New("P", "mm", "A4", "")
+	pdf.SetCompression(false)
+	pdf.AddUTF8Font("dejavu", "", "font/DejaVuSansCondensed.ttf")
+	pdf.SetFont("dejavu", "", 10)
+	pdf.AddPage()
+	utf := pdf.currentFont.utf8File
+	mapped := make(map[int]bool)
+	gidA := utf.runeGlyph('A')
+	for _, g := range utf.runeGlyphs {
+		mapped[g] = true
+	}
+	unmapped := 1
+	for mapped[unmapped] {
+		unmapped++
+	}
+	adv := utf.glyphAdvance(unmapped)
+	pdf.SetShaper(testShaper{
+		{GlyphID: gidA, XAdvance: 700},
+		{GlyphID: unmapped, XAdvance: adv, XOffset: 50, YOffset: 100},
+	})
+	x := pdf.GetX()
+	pdf.WriteShaped(10, "test")
+	if pdf.Err() {
+		t.Fatal(pdf.Error())
+	}
+	wantX := x + (700+adv)*pdf.fontSize/1000
+	if got := pdf.GetX(); got < wantX-1e-9 || got > wantX+1e-9 {
+		t.Fatalf("current position is %.4f, expecting %.4f", got, wantX)
+	}
+	a := float64(pdf.currentFont.Cw['A'])
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	doc := buf.String()
+	want := sprintf("[(\x00A)%.2f] TJ 1.00 Ts [-50.00(\xe0\x00)50.00] TJ 0 Ts ET EMC", a-700)
+	if !strings.Contains(doc, want) {
+		t.Fatalf("shaped text %q not found", want)
+	}
+	if !strings.Contains(doc, "/ActualText (\xfe\xff\x00t\x00e\x00s\x00t)") {
+		t.Fatal("actual text not found")
+	}
+	if utf.privateGlyphs[0xe000] != unmapped {
+		t.Fatalf("private use code is not assigned to glyph %d", unmapped)
+	}
+	if !strings.Contains(doc, "57344 [") && !strings.Contains(doc, "57344 57344") {
+		t.Fatal("width of private use code not found")
+	}
+}
@@ -0,0 +1,330 @@
+package gofpdf
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+)
+
+// SignatureProvider is the interface used to produce the digital signature of
+// a document. The content passed to Signature is the entire output document
+// with the exception of the signature placeholder itself. Implementations can
+// delegate signing to a hardware security module or a remote signing service.
+// Sign uses an implementation based on crypto.Signer; SignWith accepts any
+// implementation.
+type SignatureProvider interface {
+	// SignatureSize returns the maximum size in bytes of the DER-encoded
+	// signature. This amount of space is reserved in the document before the
+	// signature is computed.
+	SignatureSize() int
+	// Signature returns a DER-encoded detached PKCS#7 (CMS) SignedData
+	// structure for content.
+	Signature(content []byte) ([]byte, error)
+}
+
+type signatureType struct {
+	provider      SignatureProvider
+	name          string
+	page          int
+	x, y, w, h    float64 // fpdf coordinates (y diff and scaling done)
+	fieldObj      int     // object number of field widget
+	rangeOffset   int     // offset of /ByteRange values in output buffer
+	contentOffset int     // offset of /Contents hex string in output buffer
+	contentLen    int     // length of /Contents hex string including delimiters
+}
+
+// AddSignatureField places a signature field named nameStr on the current
+// page in the rectangle specified by x, y, w and h. If w or h is zero the
+// field is invisible. Only one signature field is supported per document. If
+// Sign or SignWith is called, the field will contain the document's digital
+// signature; otherwise an empty field is written that can be signed later with
+// a PDF editor. Note that no drawing is done by this method; call methods like
+// Rect() or Cell() to show the signature area.
+//
+// The Sign example demonstrates this method.
+func (f *Fpdf) AddSignatureField(nameStr string, x, y, w, h float64) {
+	if f.err != nil {
+		return
+	}
+	if f.page <= 0 {
+		f.err = fmt.Errorf("a page must be added before a signature field")
+		return
+	}
+	if f.signature.page > 0 {
+		f.err = fmt.Errorf("only one signature field is supported")
+		return
+	}
+	if f.formFieldExists(nameStr) {
+		f.err = fmt.Errorf("form field \"%s\" already exists", nameStr)
+		return
+	}
+	f.signature.name = nameStr
+	f.signature.page = f.page
+	f.signature.x = x * f.k
+	f.signature.y = f.hPt - y*f.k
+	f.signature.w = w * f.k
+	f.signature.h = h * f.k
+}
+
+// Sign arranges for the document to be digitally signed when it is output.
+// signer is used to sign the document digest with SHA-256; it can be an
+// *rsa.PrivateKey, an *ecdsa.PrivateKey or any other implementation of
+// crypto.Signer that uses an RSA or ECDSA key, such as a key held in a
+// hardware token. cert is the certificate that corresponds to signer. Any
+// intermediate certificates specified by chain are included in the signature
+// to help readers validate it.
+//
+// The signature is placed in the field defined by AddSignatureField. If no
+// field has been defined, an invisible one is added to the first page.
+func (f *Fpdf) Sign(signer crypto.Signer, cert *x509.Certificate, chain ...*x509.Certificate) {
+	if f.err != nil {
+		return
+	}
+	if signer == nil || cert == nil {
+		f.err = fmt.Errorf("signer and certificate must be specified")
+		return
+	}
+	f.SignWith(&pkcs7Signer{signer: signer, cert: cert, chain: chain, tm: f.signingTime})
+}
+
+// SignWith arranges for the document to be digitally signed by provider when
+// it is output. This is useful when the signature is produced by an external
+// service. See Sign for more details.
+func (f *Fpdf) SignWith(provider SignatureProvider) {
+	if f.err != nil {
+		return
+	}
+	f.signature.provider = provider
+}
+
+// signingTime returns the time used in the signature and the /M entry of the
+// signature dictionary
+func (f *Fpdf) signingTime() time.Time {
+	return timeOrNow(f.modDate)
+}
+
+// putSignature writes the signature value and field objects. It is called
+// before the pages are written so that the field can be included in the
+// annotations of its page.
+func (f *Fpdf) putSignature() {
+	if f.signature.provider != nil && f.signature.page == 0 {
+		// Choose a name that does not collide with a form field
+		for j := 1; f.signature.name == "" || f.formFieldExists(f.signature.name); j++ {
+			f.signature.name = sprintf("Signature%d", j)
+		}
+		f.signature.page = 1
+	}
+	if f.signature.page == 0 {
+		return
+	}
+	valueObj := 0
+	if f.signature.provider != nil {
+		size := f.signature.provider.SignatureSize()
+		f.newobj()
+		valueObj = f.n
+		f.out("<</Type /Sig /Filter /Adobe.PPKLite /SubFilter /adbe.pkcs7.detached")
+		f.outf("/M %s", f.textstring("D:"+f.signingTime().Format("20060102150405")))
+		f.signature.rangeOffset = f.buffer.Len() + len("/ByteRange [")
+		f.outf("/ByteRange [%010d %010d %010d %010d]", 0, 0, 0, 0)
+		// The contents are never encrypted so they are written directly
+		f.signature.contentOffset = f.buffer.Len() + len("/Contents ")
+		f.signature.contentLen = 2*size + 2
+		f.outf("/Contents <%s>>>", bytes.Repeat([]byte("0"), 2*size))
+		f.out("endobj")
+	}
+	f.newobj()
+	f.signature.fieldObj = f.n
+	f.outf("<</Type /Annot /Subtype /Widget /FT /Sig /F 132 /T %s", f.textstring(utf8toutf16(f.signature.name)))
+	f.outf("/Rect [%.2f %.2f %.2f %.2f]", f.signature.x, f.signature.y-f.signature.h,
+		f.signature.x+f.signature.w, f.signature.y)
+	if valueObj > 0 {
+		f.outf("/V %d 0 R", valueObj)
+	}
+	f.out(">>")
+	f.out("endobj")
+}
+
+// signDocument fills in the byte range of the completed document, computes
+// its signature and places the signature in the reserved space
+func (f *Fpdf) signDocument() {
+	if f.err != nil || f.signature.provider == nil {
+		return
+	}
+	buf := f.buffer.Bytes()
+	start := f.signature.contentOffset
+	end := start + f.signature.contentLen
+	copy(buf[f.signature.rangeOffset:], sprintf("%010d %010d %010d %010d", 0, start, end, len(buf)-end))
+	var content []byte
+	content = append(content, buf[:start]...)
+	content = append(content, buf[end:]...)
+	sig, err := f.signature.provider.Signature(content)
+	if err != nil {
+		f.err = err
+		return
+	}
+	str := hex.EncodeToString(sig)
+	if len(str) > f.signature.contentLen-2 {
+		f.err = fmt.Errorf("signature of %d bytes exceeds reserved size of %d bytes",
+			len(sig), f.signature.provider.SignatureSize())
+		return
+	}
+	copy(buf[start+1:], str)
+}
+
+var (
+	oidData          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidSignedData    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidContentType   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidSigningTime   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+	oidSHA256        = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidRSA           = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidECDSASHA256   = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+)
+
+type pkcs7ContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"optional"`
+}
+
+type pkcs7SignedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	ContentInfo      pkcs7ContentInfo
+	Certificates     asn1.RawValue     `asn1:"optional"`
+	SignerInfos      []pkcs7SignerInfo `asn1:"set"`
+}
+
+type pkcs7IssuerAndSerial struct {
+	Issuer asn1.RawValue
+	Serial *big.Int
+}
+
+type pkcs7SignerInfo struct {
+	Version                   int
+	IssuerAndSerialNumber     pkcs7IssuerAndSerial
+	DigestAlgorithm           pkix.AlgorithmIdentifier
+	AuthenticatedAttributes   asn1.RawValue `asn1:"optional"`
+	DigestEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedDigest           []byte
+}
+
+type pkcs7Attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values []asn1.RawValue `asn1:"set"`
+}
+
+// pkcs7Signer is a SignatureProvider that creates a detached PKCS#7
+// signature with a crypto.Signer
+type pkcs7Signer struct {
+	signer crypto.Signer
+	cert   *x509.Certificate
+	chain  []*x509.Certificate
+	tm     func() time.Time
+}
+
+func (s *pkcs7Signer) SignatureSize() int {
+	size := 2048 + len(s.cert.Raw)
+	for _, c := range s.chain {
+		size += len(c.Raw)
+	}
+	return size
+}
+
+func (s *pkcs7Signer) Signature(content []byte) (der []byte, err error) {
+	var encAlg pkix.AlgorithmIdentifier
+	switch s.signer.Public().(type) {
+	case *rsa.PublicKey:
+		encAlg = pkix.AlgorithmIdentifier{Algorithm: oidRSA, Parameters: asn1.NullRawValue}
+	case *ecdsa.PublicKey:
+		encAlg = pkix.AlgorithmIdentifier{Algorithm: oidECDSASHA256}
+	default:
+		return nil, fmt.Errorf("unsupported signing key type %T", s.signer.Public())
+	}
+	digestAlg := pkix.AlgorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue}
+	sum := sha256.Sum256(content)
+	attrs, err := pkcs7Attributes(sum[:], s.tm())
+	if err != nil {
+		return
+	}
+	// The signature covers the DER encoding of the attributes as a SET
+	signed, err := asn1.Marshal(asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: attrs})
+	if err != nil {
+		return
+	}
+	attrSum := sha256.Sum256(signed)
+	encDigest, err := s.signer.Sign(rand.Reader, attrSum[:], crypto.SHA256)
+	if err != nil {
+		return
+	}
+	var certs []byte
+	certs = append(certs, s.cert.Raw...)
+	for _, c := range s.chain {
+		certs = append(certs, c.Raw...)
+	}
+	sd := pkcs7SignedData{
+		Version:          1,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{digestAlg},
+		ContentInfo:      pkcs7ContentInfo{ContentType: oidData},
+		Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: certs},
+		SignerInfos: []pkcs7SignerInfo{{
+			Version: 1,
+			IssuerAndSerialNumber: pkcs7IssuerAndSerial{
+				Issuer: asn1.RawValue{FullBytes: s.cert.RawIssuer},
+				Serial: s.cert.SerialNumber,
+			},
+			DigestAlgorithm:           digestAlg,
+			AuthenticatedAttributes:   asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: attrs},
+			DigestEncryptionAlgorithm: encAlg,
+			EncryptedDigest:           encDigest,
+		}},
+	}
+	sdBytes, err := asn1.Marshal(sd)
+	if err != nil {
+		return
+	}
+	return asn1.Marshal(pkcs7ContentInfo{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: sdBytes},
+	})
+}
+
+// pkcs7Attributes returns the concatenated DER encoding of the authenticated
+// attributes, sorted as required for a DER SET OF
+func pkcs7Attributes(digest []byte, tm time.Time) ([]byte, error) {
+	list := []struct {
+		oid asn1.ObjectIdentifier
+		val interface{}
+	}{
+		{oidContentType, oidData},
+		{oidSigningTime, tm.UTC()},
+		{oidMessageDigest, digest},
+	}
+	var encoded [][]byte
+	for _, a := range list {
+		val, err := asn1.Marshal(a.val)
+		if err != nil {
+			return nil, err
+		}
+		attr, err := asn1.Marshal(pkcs7Attribute{Type: a.oid, Values: []asn1.RawValue{{FullBytes: val}}})
+		if err != nil {
+			return nil, err
+		}
+		encoded = append(encoded, attr)
+	}
+	sort.Slice(encoded, func(a, b int) bool {
+		return bytes.Compare(encoded[a], encoded[b]) < 0
+	})
+	return bytes.Join(encoded, nil), nil
+}
@@ -0,0 +1,90 @@
+package gofpdf
+
+import (
+	"strings"
+	"unicode"
+)
+
+// smallCapsScale is the size of synthesized small capitals relative to the
+// font size
+const smallCapsScale = 0.75
+
+// SetSmallCaps turns small capitals on or off. While they are on, lower case
+// letters are printed as capital letters of three quarters of the font
+// size, for fonts that lack a small caps variant. Small capitals are taken
+// into account by GetStringWidth() and by the line breaking of MultiCell(),
+// Write() and SplitText(). Kerning and the justification of text in UTF-8
+// fonts are not applied to text in small capitals.
+//
+// The WriteSuperscript example demonstrates this method.
+func (f *Fpdf) SetSmallCaps(on bool) {
+	f.smallCaps = on
+}
+
+// GetSmallCaps returns true if small capitals have been turned on with
+// SetSmallCaps().
+func (f *Fpdf) GetSmallCaps() bool {
+	return f.smallCaps
+}
+
+// smallCap returns the capital letter that is printed as small capital for
+// c, or false if c is not printed as small capital
+func (f *Fpdf) smallCap(c rune) (rune, bool) {
+	if !f.smallCaps || !unicode.IsLower(c) {
+		return c, false
+	}
+	upper := unicode.ToUpper(c)
+	if upper == c || !f.isCurrentUTF8 && upper > 255 {
+		return c, false
+	}
+	return upper, true
+}
+
+// smallCapWidth returns the difference between the width of the small
+// capital for c and the width of c, in thousandths of the font size, or 0 if
+// c is not printed as small capital
+func (f *Fpdf) smallCapWidth(c rune) float64 {
+	upper, ok := f.smallCap(c)
+	if !ok {
+		return 0
+	}
+	return smallCapsScale*float64(f.GetStringSymbolWidth(f.lineText([]rune{upper}))) -
+		float64(f.GetStringSymbolWidth(f.lineText([]rune{c})))
+}
+
+// smallCapsText returns the text showing operators that print txtStr with
+// its lower case letters as small capitals. The font size is changed for the
+// small capitals and restored at the end.
+func (f *Fpdf) smallCapsText(txtStr string) string {
+	var s fmtBuffer
+	chars := f.textRunes(txtStr)
+	small := false
+	for j := 0; j < len(chars); {
+		k := j
+		var run []rune
+		for ; k < len(chars); k++ {
+			upper, ok := f.smallCap(chars[k])
+			if k > j && ok != small {
+				break
+			}
+			small = ok
+			run = append(run, upper)
+		}
+		if small {
+			s.printf("/F%s %.2f Tf ", f.currentFont.i, f.fontSizePt*smallCapsScale)
+		}
+		str := f.lineText(run)
+		if f.isCurrentUTF8 {
+			for _, c := range run {
+				f.currentFont.usedRunes[int(c)] = int(c)
+			}
+			str = utf8toutf16(str, false)
+		}
+		s.printf("(%s) Tj ", f.escape(str))
+		if small {
+			s.printf("/F%s %.2f Tf ", f.currentFont.i, f.fontSizePt)
+		}
+		j = k
+	}
+	return strings.TrimSpace(s.String())
+}
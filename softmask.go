@@ -0,0 +1,163 @@
+package gofpdf
+
+import (
+	"errors"
+	"strings"
+)
+
+// SoftMaskID identifies a soft mask drawn between BeginSoftMask() and
+// EndSoftMask()
+type SoftMaskID int
+
+// softMaskType is the content of a soft mask, drawn as a transparency group
+type softMaskType struct {
+	wPt, hPt float64 // size of the page the mask was drawn on
+	content  []byte
+	objNum   int
+}
+
+// softMaskStateType is a graphics state that applies a soft mask, or removes
+// the current one if mask is 0
+type softMaskStateType struct {
+	mask    int
+	typeStr string // Luminosity or Alpha
+	objNum  int
+}
+
+// BeginSoftMask starts drawing a soft mask: the drawing operations that
+// follow, up to EndSoftMask(), such as Rect(), Image(), LinearGradient() and
+// RadialGradient(), are not put on the current page but drawn into the mask,
+// at the same positions. Once applied with ApplySoftMask(), the mask sets how
+// much of the content drawn afterwards shows on the page, point by point,
+// which fades content out gradually or feathers the edges of images.
+//
+// The mask starts with the colors, line width and font of the document, which
+// are restored by EndSoftMask(), as are the current position and page. Page
+// breaks do not occur in the mask, and AddPage(), links and annotations are
+// not supported. An error occurs if no page has been added or if a mask has
+// already been begun.
+//
+// The BeginSoftMask example demonstrates this method.
+func (f *Fpdf) BeginSoftMask() {
+	if f.err != nil {
+		return
+	}
+	switch {
+	case f.page == 0 || f.state != 2:
+		f.err = errors.New("a page must be added before a soft mask is begun")
+	case f.softMaskCanvas != nil:
+		f.err = errors.New("a soft mask has already been begun")
+	default:
+		x, y := f.x, f.y
+		f.softMaskCanvas = f.beginCanvas(f.w, f.h, "a soft mask")
+		f.x, f.y = x, y
+	}
+}
+
+// EndSoftMask ends the soft mask begun by BeginSoftMask() and returns it, or
+// 0 if an error occurs. An error occurs if no mask has been begun.
+//
+// The BeginSoftMask example demonstrates this method.
+func (f *Fpdf) EndSoftMask() SoftMaskID {
+	if f.err != nil {
+		return 0
+	}
+	c := f.softMaskCanvas
+	if c == nil {
+		f.err = errors.New("no soft mask has been begun")
+		return 0
+	}
+	if f.canvasDepth != c.depth+1 {
+		f.err = errors.New("transparency group must be ended before the soft mask")
+		return 0
+	}
+	f.softMaskCanvas = nil
+	content := f.endCanvas(c)
+	if f.err != nil {
+		return 0
+	}
+	f.softMasks = append(f.softMasks, softMaskType{wPt: c.wPt, hPt: c.hPt, content: content})
+	return SoftMaskID(len(f.softMasks))
+}
+
+// ApplySoftMask applies the soft mask id, returned by EndSoftMask(), to the
+// content drawn afterwards on the current page, until another mask is
+// applied or the mask is removed by passing 0 as id. The mask is placed on
+// the page where it has been drawn; apply it outside of transformations and
+// clipping operations begun with TransformBegin() and ClipRect(), which would
+// move it or end it early.
+//
+// typeStr sets how the mask is read. With "Luminosity", or an empty string,
+// the content shows where the mask is white, is hidden where it is black or
+// has not been drawn, and shows partly where it is gray. With "Alpha", the
+// content shows where the mask has been drawn, according to the opacity set
+// with SetAlpha() when it was drawn, and is hidden elsewhere. An error occurs
+// if typeStr is not one of these or if the mask does not exist.
+//
+// The BeginSoftMask example demonstrates this method.
+func (f *Fpdf) ApplySoftMask(id SoftMaskID, typeStr string) {
+	if f.err != nil {
+		return
+	}
+	switch strings.ToLower(typeStr) {
+	case "", "luminosity":
+		typeStr = "Luminosity"
+	case "alpha":
+		typeStr = "Alpha"
+	default:
+		f.err = errors.New("soft mask type must be Luminosity or Alpha")
+		return
+	}
+	if id < 0 || int(id) > len(f.softMasks) {
+		f.err = errors.New("soft mask does not exist")
+		return
+	}
+	if id == 0 {
+		typeStr = ""
+	}
+	pos := 0
+	for j, st := range f.softMaskStates {
+		if st.mask == int(id) && st.typeStr == typeStr {
+			pos = j + 1
+			break
+		}
+	}
+	if pos == 0 {
+		f.softMaskStates = append(f.softMaskStates, softMaskStateType{mask: int(id), typeStr: typeStr})
+		pos = len(f.softMaskStates)
+	}
+	f.outf("/SM%d gs", pos)
+}
+
+// putSoftMasks writes the soft masks of the document as transparency group
+// XObjects and the graphics states that apply them
+func (f *Fpdf) putSoftMasks() {
+	filter := ""
+	if f.compress {
+		filter = "/Filter /" + f.filterName() + " "
+	}
+	for j, sm := range f.softMasks {
+		content := sm.content
+		if f.compress {
+			content = f.compressStream(content)
+		}
+		f.newobj()
+		f.softMasks[j].objNum = f.n
+		f.outf("<<%s/Type /XObject /Subtype /Form /BBox [0 0 %.5f %.5f]", filter, sm.wPt, sm.hPt)
+		f.outf("/Group <</S /Transparency /CS /%s>> /Resources 2 0 R", f.groupColorSpace())
+		f.outf("/Length %d >>", f.protect.streamLength(len(content)))
+		f.putstream(content)
+		f.out("endobj")
+	}
+	for j, st := range f.softMaskStates {
+		f.newobj()
+		f.softMaskStates[j].objNum = f.n
+		if st.mask == 0 {
+			f.out("<</Type /ExtGState /SMask /None>>")
+		} else {
+			f.outf("<</Type /ExtGState /SMask <</Type /Mask /S /%s /G %d 0 R>>>>",
+				st.typeStr, f.softMasks[st.mask-1].objNum)
+		}
+		f.out("endobj")
+	}
+}
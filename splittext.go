@@ -23,25 +23,47 @@ func (f *Fpdf) SplitText(txt string, w float64) (lines []string) {
 	i := 0
 	j := 0
 	l := 0
+	sp := 0.0 // character and word spacing
 	for i < nb {
 		c := s[i]
-		l += cw[c]
+		if i > j {
+			l += f.kern(s[i-1], c)
+		}
+		if fw, ok := f.fallbackWidth(c); ok {
+			l += fw
+		} else {
+			l += f.tableWidth(cw, c)
+		}
+		sp += f.extraWidth(c)
 		if unicode.IsSpace(c) || isChinese(c) {
 			sep = i
 		}
-		if c == '\n' || l > wmax {
-			if sep == -1 {
+		if c == '\n' || l+int(sp) > wmax {
+			start := j
+			if sep != -1 {
+				start = sep + 1
+			}
+			var n int
+			if c != '\n' {
+				n = f.hyphenation(s, j, start, float64(wmax))
+			}
+			if n > 0 {
+				// Break the last word of the line with a hyphen
+				lines = append(lines, string(s[j:start+n])+"-")
+				i = start + n
+			} else if sep == -1 {
 				if i == j {
 					i++
 				}
-				sep = i
+				lines = append(lines, string(s[j:i]))
 			} else {
 				i = sep + 1
+				lines = append(lines, string(s[j:sep]))
 			}
-			lines = append(lines, string(s[j:sep]))
 			sep = -1
 			j = i
 			l = 0
+			sp = 0
 		} else {
 			i++
 		}
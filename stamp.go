@@ -0,0 +1,158 @@
+package gofpdf
+
+import (
+	"fmt"
+	"math"
+	"unicode"
+)
+
+// Names of the standard stamps of stamp annotations
+const (
+	StampApproved            = "Approved"
+	StampAsIs                = "AsIs"
+	StampConfidential        = "Confidential"
+	StampDepartmental        = "Departmental"
+	StampDraft               = "Draft"
+	StampExperimental        = "Experimental"
+	StampExpired             = "Expired"
+	StampFinal               = "Final"
+	StampForComment          = "ForComment"
+	StampForPublicRelease    = "ForPublicRelease"
+	StampNotApproved         = "NotApproved"
+	StampNotForPublicRelease = "NotForPublicRelease"
+	StampSold                = "Sold"
+	StampTopSecret           = "TopSecret"
+)
+
+// stampColors are the colors of the standard stamps: green for stamps that
+// release a document, red for those that restrict it and blue for the others
+var stampColors = map[string]RGBAType{
+	StampApproved:            {R: 30, G: 130, B: 50, Alpha: 1},
+	StampAsIs:                {R: 30, G: 70, B: 160, Alpha: 1},
+	StampConfidential:        {R: 190, G: 30, B: 30, Alpha: 1},
+	StampDepartmental:        {R: 30, G: 70, B: 160, Alpha: 1},
+	StampDraft:               {R: 30, G: 70, B: 160, Alpha: 1},
+	StampExperimental:        {R: 30, G: 70, B: 160, Alpha: 1},
+	StampExpired:             {R: 190, G: 30, B: 30, Alpha: 1},
+	StampFinal:               {R: 30, G: 130, B: 50, Alpha: 1},
+	StampForComment:          {R: 30, G: 70, B: 160, Alpha: 1},
+	StampForPublicRelease:    {R: 30, G: 130, B: 50, Alpha: 1},
+	StampNotApproved:         {R: 190, G: 30, B: 30, Alpha: 1},
+	StampNotForPublicRelease: {R: 190, G: 30, B: 30, Alpha: 1},
+	StampSold:                {R: 30, G: 130, B: 50, Alpha: 1},
+	StampTopSecret:           {R: 190, G: 30, B: 30, Alpha: 1},
+}
+
+// AddStampAnnotation adds a stamp annotation with the standard stamp name,
+// one of the Stamp constants such as StampApproved, in the rectangle of width
+// w and height h with its upper left corner at (x, y) on the current page.
+//
+// The AddStampAnnotation example demonstrates this method.
+func (f *Fpdf) AddStampAnnotation(x, y, w, h float64, name string) {
+	f.AddStampAnnotationOptions(x, y, w, h, name, MarkupAnnotationOptions{})
+}
+
+// AddStampAnnotationOptions is the same as AddStampAnnotation() but the
+// annotation has the properties specified by options, except Icon. If
+// options.Color is nil, the stamp is green, red or blue, depending on
+// whether it releases or restricts the document.
+//
+// The stamp is drawn by gofpdf, so that it looks the same in every PDF
+// reader, as its name in capital letters within a border. The name is written
+// with the current font, or with Helvetica Bold if no font has been selected,
+// so that a PDF/A document needs an embedded font to be selected. An error
+// occurs if name is not the name of a standard stamp, or if w or h is not
+// positive.
+func (f *Fpdf) AddStampAnnotationOptions(x, y, w, h float64, name string, options MarkupAnnotationOptions) {
+	if f.err != nil {
+		return
+	}
+	clr, ok := stampColors[name]
+	switch {
+	case !ok:
+		f.err = fmt.Errorf("unrecognized stamp \"%s\"", name)
+	case f.page == 0 || f.state != 2:
+		f.err = fmt.Errorf("a page must be added before an annotation")
+	case w <= 0 || h <= 0:
+		f.err = fmt.Errorf("invalid stamp annotation size %.2f x %.2f", w, h)
+	}
+	if f.err != nil {
+		return
+	}
+	if options.Color == nil {
+		options.Color = &clr
+	}
+	// The words of the name are separated
+	var label []rune
+	for j, r := range name {
+		if j > 0 && unicode.IsUpper(r) {
+			label = append(label, ' ')
+		}
+		label = append(label, unicode.ToUpper(r))
+	}
+	family, style := f.fontFamily, f.fontStyle
+	if family == "" {
+		family, style = "Helvetica", "B"
+	}
+	c := f.beginCanvas(w, h, "a stamp annotation")
+	lw := math.Max(math.Min(w, h)*f.k/15, 1) / f.k
+	f.SetDrawColor(options.Color.R, options.Color.G, options.Color.B)
+	f.SetTextColor(options.Color.R, options.Color.G, options.Color.B)
+	f.SetLineWidth(lw)
+	f.RoundedRect(lw/2, lw/2, w-lw, h-lw, math.Min(w, h)/6, "1234", "D")
+	// The name fills the stamp, within its border
+	f.SetFont(family, style, 1)
+	if tw := f.GetStringWidth(string(label)); f.err == nil {
+		size := math.Min(h*0.55*f.k, (w-4*lw)/tw)
+		f.SetFontSize(size)
+		f.SetXY(0, 0)
+		f.CellFormat(w, h, string(label), "", 0, "C", false, 0, "")
+	}
+	content := f.endCanvas(c)
+	options.Icon = name
+	f.addStampAnnot(x, y, w, h, content, options)
+}
+
+// AddCustomStampAnnotation adds a stamp annotation whose appearance is the
+// template tpl, scaled to the rectangle of width w and height h with its
+// upper left corner at (x, y) on the current page, with the properties
+// specified by options, except Icon. This lets approval workflows stamp
+// documents with their own artwork, such as a company seal or a signature,
+// that reviewers can move or remove as an annotation instead of finding it
+// flattened into the page.
+//
+// The template may be one of CreateTemplate() or CapturePageRegion(). An error
+// occurs if w or h is not positive, or if the template cannot be used.
+//
+// The AddStampAnnotation example demonstrates this method.
+func (f *Fpdf) AddCustomStampAnnotation(x, y, w, h float64, tpl Template, options MarkupAnnotationOptions) {
+	if f.err != nil {
+		return
+	}
+	if w <= 0 || h <= 0 {
+		f.err = fmt.Errorf("invalid stamp annotation size %.2f x %.2f", w, h)
+		return
+	}
+	if !f.templateUse(tpl) {
+		return
+	}
+	_, size := tpl.Size()
+	content := []byte(sprintf("q %.5f 0 0 %.5f 0 0 cm /TPL%s Do Q\n", w/size.Wd, h/size.Ht, tpl.ID()))
+	options.Icon = "Custom"
+	f.addStampAnnot(x, y, w, h, content, options)
+}
+
+// addStampAnnot adds a stamp annotation with the appearance content, drawn in
+// a box of width w and height h, with its upper left corner at (x, y)
+func (f *Fpdf) addStampAnnot(x, y, w, h float64, content []byte, options MarkupAnnotationOptions) {
+	if f.err != nil {
+		return
+	}
+	an := markupAnnotType{subtype: "Stamp", x: x * f.k, y: f.hPt - (y+h)*f.k, w: w * f.k, h: h * f.k}
+	var ap fmtBuffer
+	ap.printf("q 1 0 0 1 %.2f %.2f cm\n", an.x, an.y)
+	ap.Write(content)
+	ap.printf("Q\n")
+	an.ap = ap.Bytes()
+	f.addMarkupAnnot(an, AnnotationOptions{MarkupAnnotationOptions: options})
+}
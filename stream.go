@@ -0,0 +1,147 @@
+package gofpdf
+
+import (
+	"bytes"
+	"crypto/md5"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+)
+
+// outputStreamType holds the state of a document whose pages are written to
+// an output writer as they are finished
+type outputStreamType struct {
+	w        io.Writer
+	written  int         // number of bytes written to w
+	digest   hash.Hash   // MD5 hash of the bytes written, for the document ID
+	version  string      // PDF version of the header, once it has been written
+	protect  bool        // whether the pages written are encrypted
+	contents map[int]int // object numbers of the content streams written, by page
+}
+
+// SetOutputWriter specifies that the document is written to w while it is
+// generated, so that documents with many pages, such as statements with
+// thousands of pages, do not need to be held in memory. Pages that are
+// finished are written to w with FlushPage(), and Close() writes the rest of
+// the document. Only the small page dictionaries and the cross-reference
+// bookkeeping remain in memory until the document is closed. The document is
+// not written with Output() or the methods that call it.
+//
+// SetOutputWriter must be called before the first page is added. Aliases,
+// including the one for the total number of pages, cannot be used on pages
+// that are flushed, since those pages are written before the document is
+// complete. The protection set with SetProtection() must be set before the
+// first page is flushed, and a document that is written to w cannot be
+// signed.
+//
+// The SetOutputWriter example demonstrates this method.
+func (f *Fpdf) SetOutputWriter(w io.Writer) {
+	if f.err != nil {
+		return
+	}
+	if f.page > 0 {
+		f.err = fmt.Errorf("output writer must be set before the first page is added")
+		return
+	}
+	f.outStream = &outputStreamType{w: w, digest: md5.New(), contents: make(map[int]int)}
+}
+
+// FlushPage writes the content of the pages that are finished, that is the
+// pages before the current page, to the writer set with SetOutputWriter() and
+// releases them. It is typically called after each call of AddPage().
+//
+// The SetOutputWriter example demonstrates this method.
+func (f *Fpdf) FlushPage() {
+	if f.err != nil {
+		return
+	}
+	if f.outStream == nil {
+		f.err = fmt.Errorf("output writer has not been set")
+		return
+	}
+	if f.state == 3 {
+		f.err = fmt.Errorf("document has been closed")
+		return
+	}
+	state := f.state
+	f.state = 1
+	f.flushContents(f.page - 1)
+	f.state = state
+	f.flushOutput()
+}
+
+// flushContents adds the content streams of the pages up to page last that
+// have not been written yet to the output, after the header if it has not been
+// written yet
+func (f *Fpdf) flushContents(last int) {
+	s := f.outStream
+	for n := 1; n <= last && f.err == nil; n++ {
+		if _, ok := s.contents[n]; ok {
+			continue
+		}
+		f.replacePageAliases(n)
+		if f.aliasNbPagesStr != "" && (strings.Contains(f.pages[n].String(), f.aliasNbPagesStr) ||
+			strings.Contains(f.pages[n].String(), utf8toutf16(f.aliasNbPagesStr, false))) {
+			f.err = fmt.Errorf("total number of pages is not known when page %d is flushed", n)
+			return
+		}
+		if f.err = f.pageCounterFlushed(n); f.err != nil {
+			return
+		}
+		f.resolvePageShifts(n)
+		if s.version == "" {
+			f.putheader()
+			s.protect = f.protect.encrypted
+		}
+		f.putPageBackground(n)
+		f.pdfxScanPage(n)
+		f.newobj()
+		s.contents[n] = f.n
+		f.putPageContent(n)
+		f.pages[n] = new(bytes.Buffer)
+	}
+}
+
+// flushOutput writes the output so far to the output writer
+func (f *Fpdf) flushOutput() {
+	s := f.outStream
+	b := f.buffer.Bytes()
+	s.digest.Write(b)
+	n, err := s.w.Write(b)
+	s.written += n
+	f.buffer.Reset()
+	if err != nil && f.err == nil {
+		f.err = err
+	}
+}
+
+// offset returns the position in the document of the end of the output so far
+func (f *Fpdf) offset() int {
+	if f.outStream != nil {
+		return f.outStream.written + f.buffer.Len()
+	}
+	return f.buffer.Len()
+}
+
+// pageObj returns the object number of the page dictionary of page. The page
+// dictionaries follow the objects written before them, such as annotations and
+// form fields, and each one is followed by its content stream unless the
+// content streams have been written to an output writer already.
+func (f *Fpdf) pageObj(page int) int {
+	if f.outStream != nil {
+		return f.pageBase + page
+	}
+	return f.pageBase + 2*page - 1
+}
+
+// documentDigest returns the MD5 hash of the document so far
+func (f *Fpdf) documentDigest() []byte {
+	if f.outStream != nil {
+		d := f.outStream.digest
+		d.Write(f.buffer.Bytes())
+		return d.Sum(nil)
+	}
+	sum := md5.Sum(f.buffer.Bytes())
+	return sum[:]
+}
@@ -0,0 +1,446 @@
+package gofpdf
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// StructRole is the standard structure type of a structure element started
+// with StartStructElem().
+type StructRole string
+
+// Standard structure types of tagged documents
+const (
+	RoleDocument   StructRole = "Document"
+	RolePart       StructRole = "Part"
+	RoleArticle    StructRole = "Art"
+	RoleSection    StructRole = "Sect"
+	RoleDivision   StructRole = "Div"
+	RoleBlockQuote StructRole = "BlockQuote"
+	RoleCaption    StructRole = "Caption"
+	RoleTOC        StructRole = "TOC"
+	RoleTOCItem    StructRole = "TOCI"
+	RoleIndex      StructRole = "Index"
+	RoleParagraph  StructRole = "P"
+	RoleHeading    StructRole = "H"
+	RoleHeading1   StructRole = "H1"
+	RoleHeading2   StructRole = "H2"
+	RoleHeading3   StructRole = "H3"
+	RoleHeading4   StructRole = "H4"
+	RoleHeading5   StructRole = "H5"
+	RoleHeading6   StructRole = "H6"
+	RoleList       StructRole = "L"
+	RoleListItem   StructRole = "LI"
+	RoleLabel      StructRole = "Lbl"
+	RoleListBody   StructRole = "LBody"
+	RoleTable      StructRole = "Table"
+	RoleTableRow   StructRole = "TR"
+	RoleTableHead  StructRole = "TH"
+	RoleTableData  StructRole = "TD"
+	RoleSpan       StructRole = "Span"
+	RoleQuote      StructRole = "Quote"
+	RoleNote       StructRole = "Note"
+	RoleReference  StructRole = "Reference"
+	RoleCode       StructRole = "Code"
+	RoleLink       StructRole = "Link"
+	RoleFigure     StructRole = "Figure"
+	RoleFormula    StructRole = "Formula"
+	RoleForm       StructRole = "Form"
+)
+
+// StructElemOptions provides optional attributes of a structure element
+// started with StartStructElemEx().
+type StructElemOptions struct {
+	// AltText is the alternate description of the element, such as the
+	// description of a figure, that screen readers speak in place of its
+	// content.
+	AltText string
+	// ActualText replaces the text of the content of the element, for
+	// example the spelled out form of an abbreviation.
+	ActualText string
+	// Lang is the natural language of the content of the element, such as
+	// "en-US", if it differs from the language of the document.
+	Lang string
+	// Title is the title of the element.
+	Title string
+}
+
+// structElemType is an element of the structure tree
+type structElemType struct {
+	role    StructRole
+	parent  int // index of the parent element
+	kids    []structKidType
+	options StructElemOptions
+}
+
+// structKidType is a child element or a marked-content sequence of an
+// element
+type structKidType struct {
+	elem int // index of the child element, or -1 for marked content
+	page int
+	mcid int
+}
+
+// structTreeType is the structure tree of a tagged document
+type structTreeType struct {
+	tagged    bool
+	elems     []structElemType // elems[0] is the Document element
+	open      []int            // stack of the open elements
+	pageMCIDs map[int]int      // number of marked-content sequences of each page
+	obj       int              // object number of the structure tree root
+}
+
+// SetTagged specifies whether the document is a tagged document, whose
+// content is organized in a tree of structure elements for screen readers
+// and accessibility checkers, as required by PDF/UA and Section 508. It must
+// be called before content is added to the document.
+//
+// In a tagged document the content printed with Cell(), MultiCell(),
+// Write(), Text() and the image methods is marked automatically as the
+// content of the current structure element, started with StartStructElem().
+// The backgrounds and borders of cells and the content of page headers and
+// footers are marked as artifacts, which are not part of the structure.
+// Content printed outside all structure elements belongs to the Document
+// element at the root of the tree. Figures should be given an alternate
+// description with StartStructElemEx(), and the language of the document be
+// set with SetLang().
+//
+// The StartStructElem example demonstrates this method.
+func (f *Fpdf) SetTagged(tagged bool) {
+	if f.err != nil {
+		return
+	}
+	if f.page > 0 {
+		f.err = fmt.Errorf("SetTagged() must be called before content is added")
+		return
+	}
+	f.structTree.tagged = tagged
+	if tagged && len(f.structTree.elems) == 0 {
+		f.structTree.elems = []structElemType{{role: RoleDocument, parent: -1}}
+		f.structTree.pageMCIDs = make(map[int]int)
+	}
+}
+
+// SetLang sets the natural language of the document, such as "en-US", which
+// screen readers use to pronounce its text.
+func (f *Fpdf) SetLang(langStr string) {
+	f.lang = langStr
+}
+
+// StartStructElem starts a structure element with the structure type role
+// within the current element, in a document that is tagged as set with
+// SetTagged(). The content printed up to the matching call of
+// EndStructElem() belongs to the element. Elements can be nested, for example
+// list items in a list, and can extend over several pages.
+//
+// The StartStructElem example demonstrates this method.
+func (f *Fpdf) StartStructElem(role StructRole) {
+	f.StartStructElemEx(role, StructElemOptions{})
+}
+
+// StartStructElemEx starts a structure element in the way StartStructElem()
+// does, with the attributes specified by options, such as the alternate
+// description of a figure.
+//
+// The StartStructElem example demonstrates this method.
+func (f *Fpdf) StartStructElemEx(role StructRole, options StructElemOptions) {
+	if f.err != nil {
+		return
+	}
+	st := &f.structTree
+	if !st.tagged {
+		f.err = fmt.Errorf("StartStructElem() called in a document that is not tagged")
+		return
+	}
+	if role == "" {
+		f.err = fmt.Errorf("a structure element needs a structure type")
+		return
+	}
+	parent := f.structElem()
+	st.elems = append(st.elems, structElemType{role: role, parent: parent, options: options})
+	j := len(st.elems) - 1
+	st.elems[parent].kids = append(st.elems[parent].kids, structKidType{elem: j})
+	st.open = append(st.open, j)
+}
+
+// EndStructElem ends the structure element started last with
+// StartStructElem().
+//
+// The StartStructElem example demonstrates this method.
+func (f *Fpdf) EndStructElem() {
+	if f.err != nil {
+		return
+	}
+	st := &f.structTree
+	if len(st.open) == 0 {
+		f.err = fmt.Errorf("EndStructElem() called without StartStructElem()")
+		return
+	}
+	st.open = st.open[:len(st.open)-1]
+}
+
+// structElem returns the index of the current structure element
+func (f *Fpdf) structElem() int {
+	st := &f.structTree
+	if len(st.open) == 0 {
+		return 0
+	}
+	return st.open[len(st.open)-1]
+}
+
+// structMark returns the content s marked as content of the current
+// structure element, or as an artifact in page headers and footers. s is
+// returned unchanged if the document is not tagged.
+func (f *Fpdf) structMark(s string) string {
+	st := &f.structTree
+	if !st.tagged || s == "" || f.page == 0 || f.artifacts > 0 {
+		return s
+	}
+	if f.inHeader || f.inFooter {
+		return f.structArtifact(s)
+	}
+	j := f.structElem()
+	mcid := st.pageMCIDs[f.page]
+	st.pageMCIDs[f.page] = mcid + 1
+	st.elems[j].kids = append(st.elems[j].kids, structKidType{elem: -1, page: f.page, mcid: mcid})
+	return sprintf("/%s <</MCID %d>> BDC %s EMC", st.elems[j].role, mcid, s)
+}
+
+// structArtifact returns the content s marked as an artifact if the document
+// is tagged
+func (f *Fpdf) structArtifact(s string) string {
+	if !f.structTree.tagged || s == "" || f.artifacts > 0 {
+		return s
+	}
+	return "/Artifact BMC " + s + " EMC"
+}
+
+// BeginArtifact begins content that is not part of the content proper of the
+// document, such as decorative lines and images, backgrounds and watermarks,
+// up to the matching call of EndArtifact(). Screen readers and accessibility
+// checkers skip artifacts. Artifacts may be nested; the artifacts that are
+// open at the end of a page are ended there.
+//
+// In a tagged document, as set with SetTagged(), the content of artifacts is
+// not part of the current structure element.
+//
+// The BeginArtifact example demonstrates this method.
+func (f *Fpdf) BeginArtifact() {
+	if f.err != nil {
+		return
+	}
+	if f.page == 0 {
+		f.err = fmt.Errorf("a page must be added before an artifact")
+		return
+	}
+	f.out("/Artifact BMC")
+	f.artifacts++
+}
+
+// EndArtifact ends the artifact begun last with BeginArtifact().
+//
+// The BeginArtifact example demonstrates this method.
+func (f *Fpdf) EndArtifact() {
+	if f.artifacts > 0 {
+		f.out("EMC")
+		f.artifacts--
+	}
+}
+
+// artifactEndAll ends all open artifacts
+func (f *Fpdf) artifactEndAll() {
+	for f.artifacts > 0 {
+		f.EndArtifact()
+	}
+}
+
+var structMCIDRe = regexp.MustCompile(`<</MCID (\d+)>> BDC`)
+
+// structMoveContent renumbers the marked-content sequences of content that
+// is moved from page from to the current page, and returns the content
+func (f *Fpdf) structMoveContent(content []byte, from int) []byte {
+	st := &f.structTree
+	if !st.tagged || from == f.page {
+		return content
+	}
+	mcids := make(map[int]int)
+	content = structMCIDRe.ReplaceAllFunc(content, func(b []byte) []byte {
+		old, _ := strconv.Atoi(string(structMCIDRe.FindSubmatch(b)[1]))
+		mcid := st.pageMCIDs[f.page]
+		st.pageMCIDs[f.page] = mcid + 1
+		mcids[old] = mcid
+		return []byte(sprintf("<</MCID %d>> BDC", mcid))
+	})
+	for j := range st.elems {
+		for k, kid := range st.elems[j].kids {
+			if mcid, ok := mcids[kid.mcid]; ok && kid.elem < 0 && kid.page == from {
+				st.elems[j].kids[k].page, st.elems[j].kids[k].mcid = f.page, mcid
+			}
+		}
+	}
+	return content
+}
+
+// structTruncate removes the marked-content sequences after the first mcids
+// sequences of page and those of the pages after it
+func (f *Fpdf) structTruncate(page, mcids int) {
+	st := &f.structTree
+	if !st.tagged {
+		return
+	}
+	for j := range st.elems {
+		kids := st.elems[j].kids[:0]
+		for _, kid := range st.elems[j].kids {
+			if kid.elem >= 0 || kid.page < page || (kid.page == page && kid.mcid < mcids) {
+				kids = append(kids, kid)
+			}
+		}
+		st.elems[j].kids = kids
+	}
+	for p := range st.pageMCIDs {
+		if p > page {
+			delete(st.pageMCIDs, p)
+		}
+	}
+	st.pageMCIDs[page] = mcids
+}
+
+// structMovePages updates the pages of the marked-content sequences when
+// pages are moved with move, and removes the sequences of pages that move
+// removes
+func (f *Fpdf) structMovePages(move func(page int) int) {
+	st := &f.structTree
+	if !st.tagged {
+		return
+	}
+	for j := range st.elems {
+		kids := st.elems[j].kids[:0]
+		for _, kid := range st.elems[j].kids {
+			if kid.elem < 0 {
+				if kid.page = move(kid.page); kid.page == 0 {
+					continue
+				}
+			}
+			kids = append(kids, kid)
+		}
+		st.elems[j].kids = kids
+	}
+	pageMCIDs := make(map[int]int)
+	for p, n := range st.pageMCIDs {
+		if q := move(p); q > 0 {
+			pageMCIDs[q] = n
+		}
+	}
+	st.pageMCIDs = pageMCIDs
+}
+
+// structPutPage writes the entries of the page dictionary of page n that
+// relate it to the structure tree
+func (f *Fpdf) structPutPage(n int) {
+	if !f.structTree.tagged {
+		return
+	}
+	if f.structTree.pageMCIDs[n] > 0 {
+		f.outf("/StructParents %d", n-1)
+	}
+	// Annotations are visited in the order of the structure
+	f.out("/Tabs /S")
+}
+
+// putStructTree writes the structure tree root, which is followed by the
+// structure elements
+func (f *Fpdf) putStructTree() {
+	st := &f.structTree
+	if !st.tagged {
+		return
+	}
+	root := f.n + 1
+	elemObj := func(j int) int {
+		return root + 1 + j
+	}
+	// The parent tree maps the marked-content sequences of each page to their
+	// elements
+	parents := make(map[int][]int)
+	for page, n := range st.pageMCIDs {
+		if n > 0 && page <= f.page {
+			parents[page] = make([]int, n)
+		}
+	}
+	for j, elem := range st.elems {
+		for _, kid := range elem.kids {
+			if kid.elem < 0 && parents[kid.page] != nil {
+				parents[kid.page][kid.mcid] = elemObj(j)
+			}
+		}
+	}
+	pages := make([]int, 0, len(parents))
+	for page := range parents {
+		pages = append(pages, page)
+	}
+	sort.Ints(pages)
+	f.newobj()
+	var s fmtBuffer
+	s.printf("<</Type /StructTreeRoot /K [%d 0 R] /ParentTree <</Nums [", elemObj(0))
+	for _, page := range pages {
+		s.printf("%d [", page-1)
+		for _, obj := range parents[page] {
+			if obj > 0 {
+				s.printf("%d 0 R ", obj)
+			} else {
+				s.printf("null ")
+			}
+		}
+		s.printf("] ")
+	}
+	s.printf("]>> /ParentTreeNextKey %d>>", f.page)
+	f.out(s.String())
+	f.out("endobj")
+	for _, elem := range st.elems {
+		f.newobj()
+		s.Reset()
+		parent := root
+		if elem.parent >= 0 {
+			parent = elemObj(elem.parent)
+		}
+		s.printf("<</Type /StructElem /S /%s /P %d 0 R /K [", elem.role, parent)
+		for _, kid := range elem.kids {
+			if kid.elem >= 0 {
+				s.printf("%d 0 R ", elemObj(kid.elem))
+			} else if kid.page <= f.page {
+				s.printf("<</Type /MCR /Pg %d 0 R /MCID %d>> ", f.pageObj(kid.page), kid.mcid)
+			}
+		}
+		s.printf("]")
+		o := elem.options
+		if o.AltText != "" {
+			s.printf(" /Alt %s", f.textstring(utf8toutf16(o.AltText)))
+		}
+		if o.ActualText != "" {
+			s.printf(" /ActualText %s", f.textstring(utf8toutf16(o.ActualText)))
+		}
+		if o.Lang != "" {
+			s.printf(" /Lang %s", f.textstring(o.Lang))
+		}
+		if o.Title != "" {
+			s.printf(" /T %s", f.textstring(utf8toutf16(o.Title)))
+		}
+		s.printf(">>")
+		f.out(s.String())
+		f.out("endobj")
+	}
+	st.obj = root
+}
+
+// structPutCatalog writes the entries of the catalog of a tagged document
+func (f *Fpdf) structPutCatalog() {
+	if f.lang != "" {
+		f.outf("/Lang %s", f.textstring(f.lang))
+	}
+	if !f.structTree.tagged {
+		return
+	}
+	f.out("/MarkInfo <</Marked true>>")
+	f.outf("/StructTreeRoot %d 0 R", f.structTree.obj)
+}
@@ -0,0 +1,313 @@
+package gofpdf
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// TrueType subsetting. The subset font contains the glyphs that are used in
+// the document, the glyphs that their composite glyphs refer to and the
+// missing glyph, renumbered in their original order. The glyf, loca, hmtx and
+// cmap tables are rebuilt for these glyphs; the tables of the hinting
+// programs are copied, and tables that a PDF viewer does not use, such as
+// name and the OpenType layout tables, are dropped.
+
+// Flags of the components of composite glyphs
+const (
+	glyphArgWords  = 1 << 0
+	glyphArgsAreXY = 1 << 1
+	glyphScale     = 1 << 3
+	glyphMore      = 1 << 5
+	glyphXYScale   = 1 << 6
+	glyphTwoByTwo  = 1 << 7
+)
+
+// subsetTablesCopied lists the tables that are copied to subset fonts as they
+// are
+var subsetTablesCopied = []string{"cvt ", "fpgm", "prep", "gasp", "OS/2"}
+
+// glyphComponents returns the positions within glyph data of the glyph
+// indices of its components, or nil if the glyph is not composite
+func glyphComponents(data []byte) (positions []int, err error) {
+	if len(data) < 10 || int16(binary.BigEndian.Uint16(data)) >= 0 {
+		return nil, nil
+	}
+	flags := glyphMore
+	for pos := 10; flags&glyphMore != 0; {
+		if pos+4 > len(data) {
+			return nil, fmt.Errorf("truncated composite glyph")
+		}
+		flags = int(binary.BigEndian.Uint16(data[pos:]))
+		positions = append(positions, pos+2)
+		pos += 4
+		if flags&glyphArgWords != 0 {
+			pos += 4
+		} else {
+			pos += 2
+		}
+		switch {
+		case flags&glyphScale != 0:
+			pos += 2
+		case flags&glyphXYScale != 0:
+			pos += 4
+		case flags&glyphTwoByTwo != 0:
+			pos += 8
+		}
+	}
+	return positions, nil
+}
+
+// subset returns a TrueType font program with the glyphs needed to show the
+// character codes of codeGlyphs, which maps each code to the index of its
+// glyph in the font. It also returns the index of each code's glyph in the
+// subset font. If keepNames is true, the name table is copied too.
+func (utf *utf8FontFile) subset(codeGlyphs map[int]int, keepNames bool) ([]byte, map[int]int, error) {
+	tables := make(map[string][]byte)
+	for name, desc := range utf.tableDescriptions {
+		if desc.position+desc.size > len(utf.fileReader.array) {
+			return nil, nil, fmt.Errorf("font table %s out of range", name)
+		}
+		tables[name] = utf.fileReader.array[desc.position : desc.position+desc.size]
+	}
+	for _, name := range []string{"head", "hhea", "maxp", "hmtx", "loca", "glyf"} {
+		if _, ok := tables[name]; !ok {
+			return nil, nil, fmt.Errorf("font has no %s table", name)
+		}
+	}
+	u16 := func(b []byte, pos int) int {
+		return int(binary.BigEndian.Uint16(b[pos:]))
+	}
+	head, hhea, maxp := tables["head"], tables["hhea"], tables["maxp"]
+	hmtx, loca, glyf := tables["hmtx"], tables["loca"], tables["glyf"]
+	if len(head) < 54 || len(hhea) < 36 || len(maxp) < 6 {
+		return nil, nil, fmt.Errorf("invalid font header tables")
+	}
+	numGlyphs := u16(maxp, 4)
+	numMetrics := u16(hhea, 34)
+	longLoca := u16(head, 50) == 1
+	if numMetrics < 1 || len(hmtx) < 4*numMetrics+2*(numGlyphs-numMetrics) ||
+		longLoca && len(loca) < 4*(numGlyphs+1) || !longLoca && len(loca) < 2*(numGlyphs+1) {
+		return nil, nil, fmt.Errorf("invalid font metrics or glyph locations")
+	}
+	glyph := func(gid int) ([]byte, error) {
+		var start, end int
+		if longLoca {
+			start, end = int(binary.BigEndian.Uint32(loca[4*gid:])), int(binary.BigEndian.Uint32(loca[4*gid+4:]))
+		} else {
+			start, end = 2*u16(loca, 2*gid), 2*u16(loca, 2*gid+2)
+		}
+		if start > end || end > len(glyf) {
+			return nil, fmt.Errorf("invalid location of glyph %d", gid)
+		}
+		return glyf[start:end], nil
+	}
+
+	// Collect the glyphs with the components of composite glyphs
+	used := map[int]bool{0: true}
+	queue := []int{0}
+	for _, gid := range codeGlyphs {
+		if gid > 0 && gid < numGlyphs && !used[gid] {
+			used[gid] = true
+			queue = append(queue, gid)
+		}
+	}
+	for len(queue) > 0 {
+		gid := queue[0]
+		queue = queue[1:]
+		data, err := glyph(gid)
+		if err != nil {
+			return nil, nil, err
+		}
+		positions, err := glyphComponents(data)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, pos := range positions {
+			comp := u16(data, pos)
+			if comp >= numGlyphs {
+				return nil, nil, fmt.Errorf("invalid component of glyph %d", gid)
+			}
+			if !used[comp] {
+				used[comp] = true
+				queue = append(queue, comp)
+			}
+		}
+	}
+	oldGlyphs := make([]int, 0, len(used))
+	for gid := range used {
+		oldGlyphs = append(oldGlyphs, gid)
+	}
+	sort.Ints(oldGlyphs)
+	newGlyph := make(map[int]int, len(oldGlyphs))
+	for j, gid := range oldGlyphs {
+		newGlyph[gid] = j
+	}
+
+	// Glyph outlines, locations and metrics
+	var newGlyf, newHmtx []byte
+	offsets := make([]int, 0, len(oldGlyphs)+1)
+	for _, gid := range oldGlyphs {
+		data, _ := glyph(gid)
+		data = append([]byte(nil), data...)
+		positions, _ := glyphComponents(data)
+		for _, pos := range positions {
+			binary.BigEndian.PutUint16(data[pos:], uint16(newGlyph[u16(data, pos)]))
+		}
+		offsets = append(offsets, len(newGlyf))
+		newGlyf = append(newGlyf, data...)
+		newGlyf = append(newGlyf, make([]byte, (4-len(data)%4)%4)...)
+		if gid < numMetrics {
+			newHmtx = append(newHmtx, hmtx[4*gid:4*gid+4]...)
+		} else {
+			newHmtx = append(newHmtx, hmtx[4*(numMetrics-1):4*numMetrics-2]...)
+			pos := 4*numMetrics + 2*(gid-numMetrics)
+			newHmtx = append(newHmtx, hmtx[pos:pos+2]...)
+		}
+	}
+	offsets = append(offsets, len(newGlyf))
+	var newLoca []byte
+	locaFormat := 0
+	if len(newGlyf)/2 > 0xffff {
+		locaFormat = 1
+		for _, off := range offsets {
+			newLoca = append(newLoca, byte(off>>24), byte(off>>16), byte(off>>8), byte(off))
+		}
+	} else {
+		for _, off := range offsets {
+			newLoca = append(newLoca, byte(off>>9), byte(off>>1))
+		}
+	}
+
+	codeNewGlyphs := make(map[int]int, len(codeGlyphs))
+	for code, gid := range codeGlyphs {
+		codeNewGlyphs[code] = newGlyph[gid]
+	}
+	out := map[string][]byte{
+		"glyf": newGlyf,
+		"loca": newLoca,
+		"hmtx": newHmtx,
+		"cmap": subsetCmap(codeNewGlyphs),
+	}
+	out["head"] = append([]byte(nil), head...)
+	binary.BigEndian.PutUint32(out["head"][8:], 0)
+	binary.BigEndian.PutUint16(out["head"][50:], uint16(locaFormat))
+	out["hhea"] = append([]byte(nil), hhea...)
+	binary.BigEndian.PutUint16(out["hhea"][34:], uint16(len(oldGlyphs)))
+	out["maxp"] = append([]byte(nil), maxp...)
+	binary.BigEndian.PutUint16(out["maxp"][4:], uint16(len(oldGlyphs)))
+	if post, ok := tables["post"]; ok && len(post) >= 32 {
+		// Version 3 has no glyph names
+		out["post"] = append(append([]byte{0, 3, 0, 0}, post[4:16]...), make([]byte, 16)...)
+	}
+	copied := subsetTablesCopied
+	if keepNames {
+		copied = append(copied[:len(copied):len(copied)], "name")
+	}
+	for _, name := range copied {
+		if data, ok := tables[name]; ok {
+			out[name] = data
+		}
+	}
+	return assembleFont(out), codeNewGlyphs, nil
+}
+
+// subsetCmap returns a cmap table with a format 4 subtable for the Windows
+// Unicode encoding that maps the codes of codeGlyphs to their glyphs
+func subsetCmap(codeGlyphs map[int]int) []byte {
+	codes := make([]int, 0, len(codeGlyphs))
+	for code, gid := range codeGlyphs {
+		if code > 0 && code < 0xffff && gid > 0 {
+			codes = append(codes, code)
+		}
+	}
+	sort.Ints(codes)
+	// Segments of consecutive codes with consecutive glyphs
+	var starts, ends, deltas []int
+	for j, code := range codes {
+		if j > 0 && code == ends[len(ends)-1]+1 && codeGlyphs[code]-code == deltas[len(deltas)-1] {
+			ends[len(ends)-1] = code
+			continue
+		}
+		starts = append(starts, code)
+		ends = append(ends, code)
+		deltas = append(deltas, codeGlyphs[code]-code)
+	}
+	starts = append(starts, 0xffff)
+	ends = append(ends, 0xffff)
+	deltas = append(deltas, 1)
+	segCount := len(starts)
+	searchRange, entrySelector := 2, 0
+	for searchRange*2 <= 2*segCount {
+		searchRange *= 2
+		entrySelector++
+	}
+	var sub []byte
+	put := func(vals ...int) {
+		for _, v := range vals {
+			sub = append(sub, byte(v>>8), byte(v))
+		}
+	}
+	put(4, 16+8*segCount, 0, 2*segCount, searchRange, entrySelector, 2*segCount-searchRange)
+	put(ends...)
+	put(0)
+	put(starts...)
+	for _, delta := range deltas {
+		put(delta & 0xffff)
+	}
+	put(make([]int, segCount)...)
+	cmap := []byte{0, 0, 0, 1, 0, 3, 0, 1, 0, 0, 0, 12}
+	return append(cmap, sub...)
+}
+
+// fontChecksum returns the checksum of a font table
+func fontChecksum(data []byte) (sum uint32) {
+	for j := 0; j < len(data); j += 4 {
+		var word [4]byte
+		copy(word[:], data[j:])
+		sum += binary.BigEndian.Uint32(word[:])
+	}
+	return
+}
+
+// assembleFont returns a TrueType font program with the specified tables
+func assembleFont(tables map[string][]byte) []byte {
+	names := make([]string, 0, len(tables))
+	for name := range tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	searchRange, entrySelector := 1, 0
+	for searchRange*2 <= len(names) {
+		searchRange *= 2
+		entrySelector++
+	}
+	font := make([]byte, 12, 12+16*len(names))
+	binary.BigEndian.PutUint32(font, 0x00010000)
+	binary.BigEndian.PutUint16(font[4:], uint16(len(names)))
+	binary.BigEndian.PutUint16(font[6:], uint16(16*searchRange))
+	binary.BigEndian.PutUint16(font[8:], uint16(entrySelector))
+	binary.BigEndian.PutUint16(font[10:], uint16(16*(len(names)-searchRange)))
+	offset := 12 + 16*len(names)
+	headPos := 0
+	for _, name := range names {
+		var rec [16]byte
+		copy(rec[:], name)
+		binary.BigEndian.PutUint32(rec[4:], fontChecksum(tables[name]))
+		binary.BigEndian.PutUint32(rec[8:], uint32(offset))
+		binary.BigEndian.PutUint32(rec[12:], uint32(len(tables[name])))
+		font = append(font, rec[:]...)
+		if name == "head" {
+			headPos = offset
+		}
+		offset += (len(tables[name]) + 3) &^ 3
+	}
+	for _, name := range names {
+		font = append(font, tables[name]...)
+		font = append(font, make([]byte, (4-len(tables[name])%4)%4)...)
+	}
+	if headPos > 0 {
+		binary.BigEndian.PutUint32(font[headPos+8:], 0xb1b0afba-fontChecksum(font))
+	}
+	return font
+}
@@ -0,0 +1,56 @@
+package gofpdf
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"testing"
+)
+
+// TestSubset checks that a subset font contains the components of its
+// composite glyphs and maps its characters to the renumbered glyphs
+func TestSubset(t *testing.T) {
+	ttf, err := ioutil.ReadFile("font/DejaVuSansCondensed.ttf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	utf := newUTF8Font(&fileReader{array: ttf})
+	if err = utf.parseFile(); err != nil {
+		t.Fatal(err)
+	}
+	codeGlyphs := make(map[int]int)
+	for _, r := range "Aé" {
+		codeGlyphs[int(r)] = utf.runeGlyph(r)
+	}
+	data, newGlyphs, err := utf.subset(codeGlyphs, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) > len(ttf)/10 {
+		t.Fatalf("subset has %d bytes, font has %d", len(data), len(ttf))
+	}
+	sub := newUTF8Font(&fileReader{array: data})
+	if err = sub.parseFile(); err != nil {
+		t.Fatal(err)
+	}
+	for code, gid := range newGlyphs {
+		if got := sub.runeGlyph(rune(code)); got != gid || gid == 0 {
+			t.Fatalf("character %U maps to glyph %d, want %d", code, got, gid)
+		}
+	}
+	numGlyphs := int(binary.BigEndian.Uint16(sub.getTableData("maxp")[4:]))
+	loca, glyf := sub.getTableData("loca"), sub.getTableData("glyf")
+	gid := newGlyphs['é']
+	start, end := 2*int(binary.BigEndian.Uint16(loca[2*gid:])), 2*int(binary.BigEndian.Uint16(loca[2*gid+2:]))
+	positions, err := glyphComponents(glyf[start:end])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(positions) == 0 {
+		t.Fatalf("glyph of é is not composite")
+	}
+	for _, pos := range positions {
+		if comp := int(binary.BigEndian.Uint16(glyf[start+pos:])); comp == 0 || comp >= numGlyphs {
+			t.Fatalf("component %d of é is not in the subset of %d glyphs", comp, numGlyphs)
+		}
+	}
+}
@@ -33,3 +33,45 @@ func (f *Fpdf) SubWrite(ht float64, str string, subFontSize, subOffset float64,
 	// restore font size
 	f.SetFontSize(subFontSizeOld)
 }
+
+// Relative sizes and offsets of superscripts and subscripts
+const (
+	scriptScale     = 0.65
+	superscriptRise = 0.35
+	subscriptRise   = -0.15
+)
+
+// WriteSuperscript prints txtStr from the current position in the same way
+// as Write(), as a superscript. The text is printed with 65 percent of the
+// current font size and raised by 35 percent of it with SetTextRise(). h is
+// the line height in the unit of measure specified in New().
+//
+// The WriteSuperscript example demonstrates this method.
+func (f *Fpdf) WriteSuperscript(h float64, txtStr string) {
+	f.writeScript(h, txtStr, superscriptRise)
+}
+
+// WriteSubscript prints txtStr from the current position in the same way as
+// Write(), as a subscript. The text is printed with 65 percent of the
+// current font size and lowered by 15 percent of it with SetTextRise(). h is
+// the line height in the unit of measure specified in New().
+//
+// The WriteSuperscript example demonstrates this method.
+func (f *Fpdf) WriteSubscript(h float64, txtStr string) {
+	f.writeScript(h, txtStr, subscriptRise)
+}
+
+// writeScript writes txtStr with a smaller font size, raised by rise times
+// the current font size
+func (f *Fpdf) writeScript(h float64, txtStr string, rise float64) {
+	if f.err != nil {
+		return
+	}
+	size, textRise := f.fontSizePt, f.textRise
+	f.SetFontSize(size * scriptScale)
+	// Write() places the baseline of smaller text higher
+	f.SetTextRise(textRise + rise*size - 0.3*(size-f.fontSizePt))
+	f.write(h, txtStr, 0, "")
+	f.SetTextRise(textRise)
+	f.SetFontSize(size)
+}
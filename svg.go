@@ -0,0 +1,1359 @@
+package gofpdf
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// svgNode is an element or, if tag is empty, a text node of an SVG image
+type svgNode struct {
+	tag      string
+	attr     map[string]string
+	text     string
+	children []*svgNode
+}
+
+// svgPaint is the value of a fill or stroke property
+type svgPaint struct {
+	none    bool
+	current bool    // currentColor
+	clr     RGBType // color, or fallback color of a paint server
+	url     string  // id of a paint server, such as a gradient
+}
+
+// svgStyle holds the properties of an SVG element, most of which its
+// children inherit
+type svgStyle struct {
+	fill, stroke               svgPaint
+	color                      RGBType
+	fillOpacity, strokeOpacity float64
+	opacity                    float64 // product of the opacity of the element and its ancestors
+	strokeWidth                float64
+	evenOdd                    bool
+	capStyle, joinStyle        int
+	dashArray                  []float64
+	dashPhase                  float64
+	fontFamily                 string
+	fontSize                   float64
+	bold, italic               bool
+	anchor                     string
+	hidden                     bool
+	none                       bool // display: none, which is not inherited
+}
+
+// svgSeg is a segment of a path: a move ('M'), a line ('L') or a cubic
+// Bézier curve ('C') to the last point of pts, or a closing line ('Z')
+type svgSeg struct {
+	cmd byte
+	pts [6]float64
+}
+
+// svgStop is a color of an SVG gradient
+type svgStop struct {
+	offset  float64
+	clr     RGBType
+	opacity float64
+}
+
+// svgRenderer draws the elements of an SVG image
+type svgRenderer struct {
+	f        *Fpdf
+	buf      fmtBuffer
+	ids      map[string]*svgNode
+	vw, vh   float64 // size of the current viewport
+	family   string  // font family of text without an available font family
+	tr       func(string) string
+	useDepth int
+}
+
+// DrawSVG draws the SVG image svg with its upper left corner at (x, y) and a
+// width of w and a height of h, in the unit of measure specified in New(). If
+// w and h are both 0, the image is drawn at its own size, taking a pixel as
+// 1/96 inch; if one of them is 0, it is calculated so that the image keeps its
+// aspect ratio. The viewBox and preserveAspectRatio attributes of the image
+// scale its content to this area, outside of which nothing is drawn.
+//
+// Paths, rectangles, circles, ellipses, lines, polylines and polygons are
+// drawn with their fill and stroke colors, opacities, line widths, caps,
+// joins and dash patterns. Groups, nested svg elements and use elements can
+// transform their content. Fills can be linear and radial gradients, whose
+// stops have the mean opacity of the stops; strokes painted with a gradient
+// use its first color. Text is drawn with the font
+// families of the document and the core fonts that its font-family property
+// names, falling back to the current font family of the document, or
+// Helvetica, and is filled but not stroked. Style sheets, clipping paths,
+// masks, patterns, markers, filters and embedded images are not supported.
+// The opacity of groups is applied to each of the elements they hold.
+//
+// An error is set if svg is not a well-formed SVG image.
+//
+// The DrawSVG example demonstrates this method.
+func (f *Fpdf) DrawSVG(svg []byte, x, y, w, h float64) {
+	if f.err != nil {
+		return
+	}
+	if f.page == 0 {
+		f.err = fmt.Errorf("a page must be added before an SVG image is drawn")
+		return
+	}
+	root, err := parseSVG(svg)
+	if err != nil {
+		f.err = err
+		return
+	}
+	r := &svgRenderer{f: f, ids: make(map[string]*svgNode), family: f.fontFamily}
+	if r.family == "" {
+		r.family = "helvetica"
+	}
+	r.index(root)
+	// The size of the image, in pixels
+	vb, hasViewBox := svgViewBox(root.attr["viewBox"])
+	iw, okW := svgLength(root.attr["width"], 0, 0)
+	ih, okH := svgLength(root.attr["height"], 0, 0)
+	switch {
+	case okW && okH && iw > 0 && ih > 0:
+	case hasViewBox && okW && iw > 0:
+		ih = iw * vb[3] / vb[2]
+	case hasViewBox && okH && ih > 0:
+		iw = ih * vb[2] / vb[3]
+	case hasViewBox:
+		iw, ih = vb[2], vb[3]
+	default:
+		f.err = fmt.Errorf("SVG image has no width, height or viewBox")
+		return
+	}
+	px := 72 / 96.0 / f.k
+	switch {
+	case w == 0 && h == 0:
+		w, h = iw*px, ih*px
+	case w == 0:
+		w = h * iw / ih
+	case h == 0:
+		h = w * ih / iw
+	}
+	// The font of the document is restored at the end, and its graphics state
+	// by the Q operator
+	family, style, sizePt, size := f.fontFamily, f.fontStyle, f.fontSizePt, f.fontSize
+	font, utf8, underline, strikeout := f.currentFont, f.isCurrentUTF8, f.underline, f.strikeout
+	r.buf.printf("q %.5f 0 0 %.5f %.5f %.5f cm\n", w*f.k/iw, -h*f.k/ih, x*f.k, (f.h-y)*f.k)
+	r.vw, r.vh = iw, ih
+	r.viewport(root, r.style(root, r.defaultStyle()), iw, ih)
+	r.buf.printf("Q")
+	f.fontFamily, f.fontStyle, f.fontSizePt, f.fontSize = family, style, sizePt, size
+	f.currentFont, f.isCurrentUTF8, f.underline, f.strikeout = font, utf8, underline, strikeout
+	if f.err == nil {
+		f.out(f.structMark(r.buf.String()))
+	}
+}
+
+// parseSVG parses the SVG image data into a tree of nodes
+func parseSVG(data []byte) (*svgNode, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	d.Entity = xml.HTMLEntity
+	var root *svgNode
+	var stack []*svgNode
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse SVG image: %s", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			node := &svgNode{tag: t.Name.Local, attr: make(map[string]string, len(t.Attr))}
+			for _, a := range t.Attr {
+				node.attr[a.Name.Local] = a.Value
+			}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, node)
+			} else if root == nil {
+				root = node
+			}
+			stack = append(stack, node)
+		case xml.EndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, &svgNode{text: string(t)})
+			}
+		}
+	}
+	if root == nil || root.tag != "svg" {
+		return nil, fmt.Errorf("not an SVG image")
+	}
+	return root, nil
+}
+
+// index records the elements of the tree of node that have an id
+func (r *svgRenderer) index(node *svgNode) {
+	if id := node.attr["id"]; id != "" {
+		if _, ok := r.ids[id]; !ok {
+			r.ids[id] = node
+		}
+	}
+	for _, child := range node.children {
+		r.index(child)
+	}
+}
+
+// defaultStyle returns the initial values of the properties
+func (r *svgRenderer) defaultStyle() svgStyle {
+	return svgStyle{
+		fill:          svgPaint{},
+		stroke:        svgPaint{none: true},
+		fillOpacity:   1,
+		strokeOpacity: 1,
+		opacity:       1,
+		strokeWidth:   1,
+		fontSize:      16,
+		anchor:        "start",
+	}
+}
+
+// svgProperties are the properties that are read from the presentation
+// attributes and the style attribute of elements
+var svgProperties = []string{
+	"color", "fill", "stroke", "fill-opacity", "stroke-opacity", "opacity",
+	"stroke-width", "fill-rule", "stroke-linecap", "stroke-linejoin",
+	"stroke-dasharray", "stroke-dashoffset", "font-family", "font-size",
+	"font-weight", "font-style", "text-anchor", "display", "visibility",
+}
+
+// style returns the properties of node, which inherits st
+func (r *svgRenderer) style(node *svgNode, st svgStyle) svgStyle {
+	props := make(map[string]string)
+	for _, name := range svgProperties {
+		if value, ok := node.attr[name]; ok {
+			props[name] = strings.TrimSpace(value)
+		}
+	}
+	for _, decl := range strings.Split(node.attr["style"], ";") {
+		pos := strings.IndexByte(decl, ':')
+		if pos < 0 {
+			continue
+		}
+		name := strings.ToLower(strings.TrimSpace(decl[:pos]))
+		props[name] = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(decl[pos+1:]), "!important"))
+	}
+	st.none = false
+	if value, ok := props["color"]; ok {
+		if clr, ok := htmlColor(value); ok {
+			st.color = clr
+		}
+	}
+	opacity := func(value string) (float64, bool) {
+		v, ok := svgLength(value, 1, 0)
+		return math.Max(math.Min(v, 1), 0), ok
+	}
+	for _, name := range svgProperties {
+		value, ok := props[name]
+		if !ok || value == "inherit" {
+			continue
+		}
+		switch name {
+		case "fill":
+			if paint, ok := svgPaintValue(value); ok {
+				st.fill = paint
+			}
+		case "stroke":
+			if paint, ok := svgPaintValue(value); ok {
+				st.stroke = paint
+			}
+		case "fill-opacity":
+			if v, ok := opacity(value); ok {
+				st.fillOpacity = v
+			}
+		case "stroke-opacity":
+			if v, ok := opacity(value); ok {
+				st.strokeOpacity = v
+			}
+		case "opacity":
+			if v, ok := opacity(value); ok {
+				st.opacity *= v
+			}
+		case "stroke-width":
+			if v, ok := svgLength(value, math.Hypot(r.vw, r.vh)/math.Sqrt2, st.fontSize); ok && v >= 0 {
+				st.strokeWidth = v
+			}
+		case "fill-rule":
+			st.evenOdd = value == "evenodd"
+		case "stroke-linecap":
+			if v, ok := map[string]int{"butt": 0, "round": 1, "square": 2}[value]; ok {
+				st.capStyle = v
+			}
+		case "stroke-linejoin":
+			if v, ok := map[string]int{"miter": 0, "round": 1, "bevel": 2}[value]; ok {
+				st.joinStyle = v
+			}
+		case "stroke-dasharray":
+			st.dashArray = nil
+			if value != "none" {
+				var sum float64
+				for _, v := range svgNumbers(value) {
+					st.dashArray = append(st.dashArray, math.Abs(v))
+					sum += math.Abs(v)
+				}
+				if len(st.dashArray)%2 == 1 {
+					st.dashArray = append(st.dashArray, st.dashArray...)
+				}
+				if sum == 0 {
+					st.dashArray = nil
+				}
+			}
+		case "stroke-dashoffset":
+			if v, ok := svgLength(value, 0, st.fontSize); ok {
+				st.dashPhase = v
+			}
+		case "font-family":
+			st.fontFamily = value
+		case "font-size":
+			if v, ok := svgLength(value, st.fontSize, st.fontSize); ok && v > 0 {
+				st.fontSize = v
+			}
+		case "font-weight":
+			if n, err := strconv.Atoi(value); err == nil {
+				st.bold = n >= 600
+			} else {
+				st.bold = value == "bold" || value == "bolder"
+			}
+		case "font-style":
+			st.italic = value == "italic" || value == "oblique"
+		case "text-anchor":
+			st.anchor = value
+		case "display":
+			st.none = value == "none"
+		case "visibility":
+			st.hidden = value == "hidden" || value == "collapse"
+		}
+	}
+	return st
+}
+
+// svgPaintValue returns the paint of the value of a fill or stroke property
+func svgPaintValue(value string) (paint svgPaint, ok bool) {
+	switch {
+	case value == "none" || value == "transparent":
+		paint.none = true
+	case value == "currentColor" || value == "currentcolor":
+		paint.current = true
+	case strings.HasPrefix(value, "url("):
+		end := strings.IndexByte(value, ')')
+		if end < 0 {
+			return paint, false
+		}
+		paint.url = strings.Trim(strings.TrimSpace(value[4:end]), `"'`)
+		paint.url = strings.TrimPrefix(paint.url, "#")
+		fallback := strings.TrimSpace(value[end+1:])
+		if fallback == "" || fallback == "none" {
+			paint.none = true
+		} else if paint.clr, ok = htmlColor(fallback); !ok {
+			paint.none = true
+		}
+	default:
+		if paint.clr, ok = htmlColor(value); !ok {
+			return paint, false
+		}
+	}
+	return paint, true
+}
+
+// svgLength returns the value of an SVG length in pixels. Percentages are
+// relative to ref, and lengths in em and ex to fontSize.
+func svgLength(value string, ref, fontSize float64) (float64, bool) {
+	value = strings.TrimSpace(value)
+	scale := 1.0
+	for _, unit := range []struct {
+		suffix string
+		scale  float64
+	}{
+		{"px", 1}, {"pt", 96.0 / 72}, {"pc", 16}, {"mm", 96 / 25.4}, {"cm", 96 / 2.54},
+		{"in", 96}, {"em", fontSize}, {"ex", fontSize / 2}, {"%", ref / 100},
+	} {
+		if strings.HasSuffix(value, unit.suffix) {
+			value, scale = strings.TrimSuffix(value, unit.suffix), unit.scale
+			break
+		}
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return 0, false
+	}
+	return v * scale, true
+}
+
+// length returns the length of the attribute name of node, relative to the
+// width of the viewport, its height or its diagonal, according to ref ('x',
+// 'y' or 'r'). If the attribute is missing, def is returned.
+func (r *svgRenderer) length(node *svgNode, name string, ref byte, def float64) float64 {
+	if v, ok := r.lengthValue(node.attr[name], ref); ok {
+		return v
+	}
+	return def
+}
+
+// lengthValue returns the length value, relative to the viewport as with
+// length()
+func (r *svgRenderer) lengthValue(value string, ref byte) (float64, bool) {
+	size := math.Hypot(r.vw, r.vh) / math.Sqrt2
+	switch ref {
+	case 'x':
+		size = r.vw
+	case 'y':
+		size = r.vh
+	}
+	return svgLength(value, size, 16)
+}
+
+// svgNumbers returns the numbers of a list separated by spaces or commas
+func svgNumbers(s string) (list []float64) {
+	sc := svgScanner{s: s}
+	for {
+		v, ok := sc.number()
+		if !ok {
+			return
+		}
+		list = append(list, v)
+	}
+}
+
+// svgViewBox returns the minimum x, minimum y, width and height of the value
+// of a viewBox attribute
+func svgViewBox(value string) (vb [4]float64, ok bool) {
+	list := svgNumbers(value)
+	if len(list) != 4 || list[2] <= 0 || list[3] <= 0 {
+		return
+	}
+	copy(vb[:], list)
+	return vb, true
+}
+
+// viewport draws the content of the svg or symbol element node in a viewport
+// of width w and height h, which its viewBox attribute is scaled to
+func (r *svgRenderer) viewport(node *svgNode, st svgStyle, w, h float64) {
+	if w <= 0 || h <= 0 {
+		return
+	}
+	vw, vh := r.vw, r.vh
+	r.vw, r.vh = w, h
+	r.buf.printf("0 0 %.5f %.5f re W n\n", w, h)
+	if vb, ok := svgViewBox(node.attr["viewBox"]); ok {
+		sx, sy := w/vb[2], h/vb[3]
+		fields := strings.Fields(node.attr["preserveAspectRatio"])
+		if len(fields) > 0 && fields[0] == "defer" {
+			fields = fields[1:]
+		}
+		align, slice := "xMidYMid", false
+		if len(fields) > 0 {
+			align = fields[0]
+		}
+		if len(fields) > 1 {
+			slice = fields[1] == "slice"
+		}
+		var tx, ty float64
+		if align != "none" {
+			if slice {
+				sx = math.Max(sx, sy)
+			} else {
+				sx = math.Min(sx, sy)
+			}
+			sy = sx
+			switch {
+			case strings.Contains(align, "xMid"):
+				tx = (w - vb[2]*sx) / 2
+			case strings.Contains(align, "xMax"):
+				tx = w - vb[2]*sx
+			}
+			switch {
+			case strings.Contains(align, "YMid"):
+				ty = (h - vb[3]*sy) / 2
+			case strings.Contains(align, "YMax"):
+				ty = h - vb[3]*sy
+			}
+		}
+		r.buf.printf("%.5f 0 0 %.5f %.5f %.5f cm\n", sx, sy, tx-vb[0]*sx, ty-vb[1]*sy)
+		r.vw, r.vh = vb[2], vb[3]
+	}
+	for _, child := range node.children {
+		r.render(child, st)
+	}
+	r.vw, r.vh = vw, vh
+}
+
+// render draws the element node, which inherits the properties st
+func (r *svgRenderer) render(node *svgNode, st svgStyle) {
+	switch node.tag {
+	case "svg", "g", "a", "switch", "use", "rect", "circle", "ellipse", "line",
+		"polyline", "polygon", "path", "text":
+	default:
+		// Text nodes outside of text elements, definitions and unsupported
+		// elements
+		return
+	}
+	if r.f.err != nil {
+		return
+	}
+	st = r.style(node, st)
+	if st.none {
+		return
+	}
+	r.buf.printf("q\n")
+	r.transform(node.attr["transform"])
+	switch node.tag {
+	case "svg":
+		r.buf.printf("1 0 0 1 %.5f %.5f cm\n", r.length(node, "x", 'x', 0), r.length(node, "y", 'y', 0))
+		r.viewport(node, st, r.length(node, "width", 'x', r.vw), r.length(node, "height", 'y', r.vh))
+	case "g", "a", "switch":
+		for _, child := range node.children {
+			r.render(child, st)
+		}
+	case "use":
+		r.use(node, st)
+	case "text":
+		r.text(node, st)
+	default:
+		r.paint(r.shape(node), st)
+	}
+	r.buf.printf("Q\n")
+}
+
+// use draws the element that the use element node refers to
+func (r *svgRenderer) use(node *svgNode, st svgStyle) {
+	href := node.attr["href"]
+	target, ok := r.ids[strings.TrimPrefix(href, "#")]
+	if !ok || !strings.HasPrefix(href, "#") || r.useDepth >= 16 {
+		// References to other documents are not followed, nor cycles
+		return
+	}
+	r.useDepth++
+	r.buf.printf("1 0 0 1 %.5f %.5f cm\n", r.length(node, "x", 'x', 0), r.length(node, "y", 'y', 0))
+	if target.tag == "symbol" || target.tag == "svg" {
+		st = r.style(target, st)
+		r.buf.printf("q\n")
+		r.transform(target.attr["transform"])
+		w := r.length(node, "width", 'x', r.length(target, "width", 'x', r.vw))
+		h := r.length(node, "height", 'y', r.length(target, "height", 'y', r.vh))
+		r.viewport(target, st, w, h)
+		r.buf.printf("Q\n")
+	} else {
+		r.render(target, st)
+	}
+	r.useDepth--
+}
+
+// transform applies the transformations of the value of a transform
+// attribute
+func (r *svgRenderer) transform(value string) {
+	for _, m := range svgTransform(value) {
+		r.buf.printf("%.5f %.5f %.5f %.5f %.5f %.5f cm\n", m.A, m.B, m.C, m.D, m.E, m.F)
+	}
+}
+
+// svgTransform returns the matrices of the transformations of the value of a
+// transform attribute, in the order in which they are applied with the cm
+// operator
+func svgTransform(value string) (list []TransformMatrix) {
+	for {
+		open := strings.IndexByte(value, '(')
+		end := strings.IndexByte(value, ')')
+		if open < 0 || end < open {
+			return
+		}
+		name := strings.Trim(strings.TrimSpace(value[:open]), ",")
+		name = strings.TrimSpace(name)
+		args := svgNumbers(value[open+1 : end])
+		value = value[end+1:]
+		arg := func(j int, def float64) float64 {
+			if j < len(args) {
+				return args[j]
+			}
+			return def
+		}
+		switch name {
+		case "matrix":
+			if len(args) == 6 {
+				list = append(list, TransformMatrix{args[0], args[1], args[2], args[3], args[4], args[5]})
+			}
+		case "translate":
+			list = append(list, TransformMatrix{1, 0, 0, 1, arg(0, 0), arg(1, 0)})
+		case "scale":
+			list = append(list, TransformMatrix{arg(0, 1), 0, 0, arg(1, arg(0, 1)), 0, 0})
+		case "rotate":
+			angle := arg(0, 0) * math.Pi / 180
+			cx, cy := arg(1, 0), arg(2, 0)
+			sin, cos := math.Sincos(angle)
+			rotate := TransformMatrix{cos, sin, -sin, cos, 0, 0}
+			if cx == 0 && cy == 0 {
+				list = append(list, rotate)
+			} else {
+				list = append(list, TransformMatrix{1, 0, 0, 1, cx, cy}, rotate, TransformMatrix{1, 0, 0, 1, -cx, -cy})
+			}
+		case "skewX":
+			list = append(list, TransformMatrix{1, 0, math.Tan(arg(0, 0) * math.Pi / 180), 1, 0, 0})
+		case "skewY":
+			list = append(list, TransformMatrix{1, math.Tan(arg(0, 0) * math.Pi / 180), 0, 1, 0, 0})
+		}
+	}
+}
+
+// shape returns the path of a basic shape or path element
+func (r *svgRenderer) shape(node *svgNode) (segs []svgSeg) {
+	num := func(name string, ref byte) float64 {
+		return r.length(node, name, ref, 0)
+	}
+	switch node.tag {
+	case "path":
+		return svgPath(node.attr["d"])
+	case "rect":
+		x, y, w, h := num("x", 'x'), num("y", 'y'), num("width", 'x'), num("height", 'y')
+		if w <= 0 || h <= 0 {
+			return nil
+		}
+		_, okX := node.attr["rx"]
+		_, okY := node.attr["ry"]
+		var radiusX, radiusY float64
+		switch {
+		case okX && okY:
+			radiusX, radiusY = num("rx", 'x'), num("ry", 'y')
+		case okX:
+			radiusX = num("rx", 'x')
+			radiusY = radiusX
+		case okY:
+			radiusY = num("ry", 'y')
+			radiusX = radiusY
+		}
+		radiusX, radiusY = math.Min(math.Max(radiusX, 0), w/2), math.Min(math.Max(radiusY, 0), h/2)
+		if radiusX == 0 || radiusY == 0 {
+			return []svgSeg{{cmd: 'M', pts: [6]float64{x, y}}, {cmd: 'L', pts: [6]float64{x + w, y}},
+				{cmd: 'L', pts: [6]float64{x + w, y + h}}, {cmd: 'L', pts: [6]float64{x, y + h}}, {cmd: 'Z'}}
+		}
+		segs = append(segs, svgSeg{cmd: 'M', pts: [6]float64{x + radiusX, y}})
+		corner := func(x1, y1, x2, y2 float64) {
+			segs = append(segs, svgSeg{cmd: 'L', pts: [6]float64{x1, y1}})
+			segs = append(segs, svgArc(x1, y1, radiusX, radiusY, 0, false, true, x2, y2)...)
+		}
+		corner(x+w-radiusX, y, x+w, y+radiusY)
+		corner(x+w, y+h-radiusY, x+w-radiusX, y+h)
+		corner(x+radiusX, y+h, x, y+h-radiusY)
+		corner(x, y+radiusY, x+radiusX, y)
+		return append(segs, svgSeg{cmd: 'Z'})
+	case "circle", "ellipse":
+		cx, cy := num("cx", 'x'), num("cy", 'y')
+		rx, ry := num("rx", 'x'), num("ry", 'y')
+		if node.tag == "circle" {
+			rx = num("r", 'r')
+			ry = rx
+		}
+		if rx <= 0 || ry <= 0 {
+			return nil
+		}
+		segs = append(segs, svgSeg{cmd: 'M', pts: [6]float64{cx + rx, cy}})
+		segs = append(segs, svgArc(cx+rx, cy, rx, ry, 0, false, true, cx-rx, cy)...)
+		segs = append(segs, svgArc(cx-rx, cy, rx, ry, 0, false, true, cx+rx, cy)...)
+		return append(segs, svgSeg{cmd: 'Z'})
+	case "line":
+		return []svgSeg{{cmd: 'M', pts: [6]float64{num("x1", 'x'), num("y1", 'y')}},
+			{cmd: 'L', pts: [6]float64{num("x2", 'x'), num("y2", 'y')}}}
+	case "polyline", "polygon":
+		list := svgNumbers(node.attr["points"])
+		for j := 0; j+1 < len(list); j += 2 {
+			cmd := byte('L')
+			if j == 0 {
+				cmd = 'M'
+			}
+			segs = append(segs, svgSeg{cmd: cmd, pts: [6]float64{list[j], list[j+1]}})
+		}
+		if node.tag == "polygon" && len(segs) > 0 {
+			segs = append(segs, svgSeg{cmd: 'Z'})
+		}
+	}
+	return
+}
+
+// svgScanner reads the numbers, flags and commands of path data and lists
+// of numbers
+type svgScanner struct {
+	s   string
+	pos int
+}
+
+// skip skips spaces and commas
+func (sc *svgScanner) skip() {
+	for sc.pos < len(sc.s) && strings.IndexByte(" \t\r\n,", sc.s[sc.pos]) >= 0 {
+		sc.pos++
+	}
+}
+
+// number reads a number
+func (sc *svgScanner) number() (float64, bool) {
+	sc.skip()
+	start := sc.pos
+	digits := func() (n int) {
+		for sc.pos < len(sc.s) && sc.s[sc.pos] >= '0' && sc.s[sc.pos] <= '9' {
+			sc.pos++
+			n++
+		}
+		return
+	}
+	if sc.pos < len(sc.s) && (sc.s[sc.pos] == '+' || sc.s[sc.pos] == '-') {
+		sc.pos++
+	}
+	n := digits()
+	if sc.pos < len(sc.s) && sc.s[sc.pos] == '.' {
+		sc.pos++
+		n += digits()
+	}
+	if n == 0 {
+		sc.pos = start
+		return 0, false
+	}
+	if sc.pos < len(sc.s) && (sc.s[sc.pos] == 'e' || sc.s[sc.pos] == 'E') {
+		mark := sc.pos
+		sc.pos++
+		if sc.pos < len(sc.s) && (sc.s[sc.pos] == '+' || sc.s[sc.pos] == '-') {
+			sc.pos++
+		}
+		if digits() == 0 {
+			sc.pos = mark
+		}
+	}
+	v, err := strconv.ParseFloat(sc.s[start:sc.pos], 64)
+	return v, err == nil
+}
+
+// flag reads a flag of an elliptical arc, which need not be followed by a
+// separator
+func (sc *svgScanner) flag() (bool, bool) {
+	sc.skip()
+	if sc.pos < len(sc.s) && (sc.s[sc.pos] == '0' || sc.s[sc.pos] == '1') {
+		sc.pos++
+		return sc.s[sc.pos-1] == '1', true
+	}
+	return false, false
+}
+
+// svgPath returns the segments of the path data d, with the relative
+// commands made absolute, and quadratic curves and elliptical arcs converted
+// to cubic curves. As SVG viewers do, the path is drawn up to the first error
+// in d.
+func svgPath(d string) (segs []svgSeg) {
+	sc := svgScanner{s: d}
+	var x, y, startX, startY float64
+	// The second control point of the last cubic or quadratic curve, for
+	// the smooth curves that reflect it
+	var ctrlX, ctrlY float64
+	var cmd, last byte
+	read := func(vals []float64) bool {
+		for j := range vals {
+			v, ok := sc.number()
+			if !ok {
+				return false
+			}
+			vals[j] = v
+		}
+		return true
+	}
+	for {
+		sc.skip()
+		if sc.pos >= len(sc.s) {
+			return
+		}
+		if c := sc.s[sc.pos]; c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' {
+			cmd = c
+			sc.pos++
+		} else if cmd == 0 || cmd == 'Z' || cmd == 'z' {
+			return
+		}
+		if len(segs) == 0 && cmd != 'M' && cmd != 'm' {
+			return
+		}
+		var dx, dy float64
+		if cmd >= 'a' {
+			dx, dy = x, y
+		}
+		var vals [7]float64
+		switch cmd {
+		case 'M', 'm':
+			if !read(vals[:2]) {
+				return
+			}
+			x, y = vals[0]+dx, vals[1]+dy
+			startX, startY = x, y
+			segs = append(segs, svgSeg{cmd: 'M', pts: [6]float64{x, y}})
+			// Further coordinate pairs are lines
+			cmd = map[byte]byte{'M': 'L', 'm': 'l'}[cmd]
+		case 'L', 'l':
+			if !read(vals[:2]) {
+				return
+			}
+			x, y = vals[0]+dx, vals[1]+dy
+			segs = append(segs, svgSeg{cmd: 'L', pts: [6]float64{x, y}})
+		case 'H', 'h':
+			if !read(vals[:1]) {
+				return
+			}
+			x = vals[0] + dx
+			segs = append(segs, svgSeg{cmd: 'L', pts: [6]float64{x, y}})
+		case 'V', 'v':
+			if !read(vals[:1]) {
+				return
+			}
+			y = vals[0] + dy
+			segs = append(segs, svgSeg{cmd: 'L', pts: [6]float64{x, y}})
+		case 'C', 'c', 'S', 's':
+			n := 6
+			if cmd == 'S' || cmd == 's' {
+				n = 4
+			}
+			if !read(vals[:n]) {
+				return
+			}
+			cx1, cy1 := x, y
+			if cmd == 'S' || cmd == 's' {
+				if last == 'C' || last == 'c' || last == 'S' || last == 's' {
+					cx1, cy1 = 2*x-ctrlX, 2*y-ctrlY
+				}
+				copy(vals[2:], vals[:4])
+			} else {
+				cx1, cy1 = vals[0]+dx, vals[1]+dy
+			}
+			ctrlX, ctrlY = vals[2]+dx, vals[3]+dy
+			x, y = vals[4]+dx, vals[5]+dy
+			segs = append(segs, svgSeg{cmd: 'C', pts: [6]float64{cx1, cy1, ctrlX, ctrlY, x, y}})
+		case 'Q', 'q', 'T', 't':
+			qx, qy := x, y
+			if cmd == 'T' || cmd == 't' {
+				if !read(vals[:2]) {
+					return
+				}
+				if last == 'Q' || last == 'q' || last == 'T' || last == 't' {
+					qx, qy = 2*x-ctrlX, 2*y-ctrlY
+				}
+				copy(vals[2:4], vals[:2])
+			} else {
+				if !read(vals[:4]) {
+					return
+				}
+				qx, qy = vals[0]+dx, vals[1]+dy
+			}
+			x0, y0 := x, y
+			x, y = vals[2]+dx, vals[3]+dy
+			ctrlX, ctrlY = qx, qy
+			segs = append(segs, svgSeg{cmd: 'C', pts: [6]float64{x0 + 2*(qx-x0)/3, y0 + 2*(qy-y0)/3,
+				x + 2*(qx-x)/3, y + 2*(qy-y)/3, x, y}})
+		case 'A', 'a':
+			if !read(vals[:3]) {
+				return
+			}
+			large, ok1 := sc.flag()
+			sweep, ok2 := sc.flag()
+			if !ok1 || !ok2 || !read(vals[3:5]) {
+				return
+			}
+			x0, y0 := x, y
+			x, y = vals[3]+dx, vals[4]+dy
+			segs = append(segs, svgArc(x0, y0, vals[0], vals[1], vals[2], large, sweep, x, y)...)
+		case 'Z', 'z':
+			segs = append(segs, svgSeg{cmd: 'Z'})
+			x, y = startX, startY
+		default:
+			return
+		}
+		last = cmd
+	}
+}
+
+// svgArc returns the cubic curves that approximate the elliptical arc from
+// (x1, y1) to (x2, y2) with radii rx and ry, rotated by phi degrees, as
+// described by the flags large and sweep of the SVG arc command
+func svgArc(x1, y1, rx, ry, phi float64, large, sweep bool, x2, y2 float64) (segs []svgSeg) {
+	if x1 == x2 && y1 == y2 {
+		return nil
+	}
+	rx, ry = math.Abs(rx), math.Abs(ry)
+	if rx == 0 || ry == 0 {
+		return []svgSeg{{cmd: 'L', pts: [6]float64{x2, y2}}}
+	}
+	sin, cos := math.Sincos(phi * math.Pi / 180)
+	// The center of the ellipse, as in the implementation notes of the SVG
+	// specification
+	hx, hy := (x1-x2)/2, (y1-y2)/2
+	x1p, y1p := cos*hx+sin*hy, -sin*hx+cos*hy
+	if lambda := x1p*x1p/(rx*rx) + y1p*y1p/(ry*ry); lambda > 1 {
+		rx, ry = rx*math.Sqrt(lambda), ry*math.Sqrt(lambda)
+	}
+	num := rx*rx*ry*ry - rx*rx*y1p*y1p - ry*ry*x1p*x1p
+	den := rx*rx*y1p*y1p + ry*ry*x1p*x1p
+	coef := math.Sqrt(math.Max(num/den, 0))
+	if large == sweep {
+		coef = -coef
+	}
+	cxp, cyp := coef*rx*y1p/ry, -coef*ry*x1p/rx
+	cx, cy := cos*cxp-sin*cyp+(x1+x2)/2, sin*cxp+cos*cyp+(y1+y2)/2
+	ux, uy := (x1p-cxp)/rx, (y1p-cyp)/ry
+	vx, vy := (-x1p-cxp)/rx, (-y1p-cyp)/ry
+	theta := math.Atan2(uy, ux)
+	delta := math.Atan2(ux*vy-uy*vx, ux*vx+uy*vy)
+	if !sweep && delta > 0 {
+		delta -= 2 * math.Pi
+	} else if sweep && delta < 0 {
+		delta += 2 * math.Pi
+	}
+	// Each curve spans a quarter of the ellipse at most
+	n := int(math.Ceil(math.Abs(delta)/(math.Pi/2) - 1e-9))
+	if n < 1 {
+		n = 1
+	}
+	step := delta / float64(n)
+	t := 4.0 / 3 * math.Tan(step/4)
+	point := func(a float64) (float64, float64, float64, float64) {
+		sa, ca := math.Sincos(a)
+		return cx + rx*ca*cos - ry*sa*sin, cy + rx*ca*sin + ry*sa*cos,
+			-rx*sa*cos - ry*ca*sin, -rx*sa*sin + ry*ca*cos
+	}
+	for j := 0; j < n; j++ {
+		a1 := theta + float64(j)*step
+		px1, py1, dx1, dy1 := point(a1)
+		px2, py2, dx2, dy2 := point(a1 + step)
+		if j == n-1 {
+			px2, py2 = x2, y2
+		}
+		segs = append(segs, svgSeg{cmd: 'C', pts: [6]float64{px1 + t*dx1, py1 + t*dy1,
+			px2 - t*dx2, py2 - t*dy2, px2, py2}})
+	}
+	return
+}
+
+// path writes the operators that construct the path segs
+func (r *svgRenderer) path(segs []svgSeg) {
+	for _, seg := range segs {
+		p := seg.pts
+		switch seg.cmd {
+		case 'M':
+			r.buf.printf("%.5f %.5f m\n", p[0], p[1])
+		case 'L':
+			r.buf.printf("%.5f %.5f l\n", p[0], p[1])
+		case 'C':
+			r.buf.printf("%.5f %.5f %.5f %.5f %.5f %.5f c\n", p[0], p[1], p[2], p[3], p[4], p[5])
+		case 'Z':
+			r.buf.printf("h\n")
+		}
+	}
+}
+
+// svgBounds returns the rectangle that encloses the points of segs
+func svgBounds(segs []svgSeg) (x, y, w, h float64) {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, seg := range segs {
+		n := 2
+		switch seg.cmd {
+		case 'C':
+			n = 6
+		case 'Z':
+			n = 0
+		}
+		for j := 0; j < n; j += 2 {
+			minX, maxX = math.Min(minX, seg.pts[j]), math.Max(maxX, seg.pts[j])
+			minY, maxY = math.Min(minY, seg.pts[j+1]), math.Max(maxY, seg.pts[j+1])
+		}
+	}
+	if minX > maxX {
+		return
+	}
+	return minX, minY, maxX - minX, maxY - minY
+}
+
+// paintColor returns the color of paint and, if it refers to a gradient,
+// the gradient element and its colors. alpha is the opacity of the colors of
+// the gradient, which is the mean opacity of its stops, since the opacity
+// cannot vary within a gradient.
+func (r *svgRenderer) paintColor(paint svgPaint, st svgStyle) (clr RGBType, grad *svgNode, stops []svgStop, alpha float64, ok bool) {
+	if paint.url != "" {
+		if node, found := r.ids[paint.url]; found && (node.tag == "linearGradient" || node.tag == "radialGradient") {
+			stops = r.gradientStops(node)
+			if len(stops) == 0 {
+				return clr, nil, nil, 0, false
+			}
+			for _, stop := range stops {
+				alpha += stop.opacity / float64(len(stops))
+			}
+			if len(stops) == 1 {
+				return stops[0].clr, nil, nil, alpha, true
+			}
+			return stops[0].clr, node, stops, alpha, true
+		}
+	}
+	switch {
+	case paint.none:
+		return clr, nil, nil, 0, false
+	case paint.current:
+		return st.color, nil, nil, 1, true
+	}
+	return paint.clr, nil, nil, 1, true
+}
+
+// gradientAttr returns the attribute name of the gradient element node or,
+// if it has none, of the gradient it refers to
+func (r *svgRenderer) gradientAttr(node *svgNode, name string) (string, bool) {
+	for depth := 0; node != nil && depth < 16; depth++ {
+		if value, ok := node.attr[name]; ok {
+			return value, true
+		}
+		node = r.ids[strings.TrimPrefix(node.attr["href"], "#")]
+	}
+	return "", false
+}
+
+// gradientStops returns the colors of the gradient element node or, if it
+// has none, of the gradient it refers to, in increasing order of offsets
+func (r *svgRenderer) gradientStops(node *svgNode) (stops []svgStop) {
+	for depth := 0; node != nil && depth < 16; depth++ {
+		for _, child := range node.children {
+			if child.tag != "stop" {
+				continue
+			}
+			var stop svgStop
+			offset := child.attr["offset"]
+			if strings.HasSuffix(offset, "%") {
+				stop.offset, _ = svgLength(offset, 1, 0)
+			} else {
+				stop.offset, _ = strconv.ParseFloat(strings.TrimSpace(offset), 64)
+			}
+			stop.offset = math.Max(math.Min(stop.offset, 1), 0)
+			if len(stops) > 0 {
+				stop.offset = math.Max(stop.offset, stops[len(stops)-1].offset)
+			}
+			props := map[string]string{"stop-color": child.attr["stop-color"], "stop-opacity": child.attr["stop-opacity"]}
+			for _, decl := range strings.Split(child.attr["style"], ";") {
+				if pos := strings.IndexByte(decl, ':'); pos >= 0 {
+					props[strings.TrimSpace(decl[:pos])] = decl[pos+1:]
+				}
+			}
+			stop.clr, _ = htmlColor(props["stop-color"])
+			stop.opacity = 1
+			if v, ok := svgLength(props["stop-opacity"], 1, 0); ok {
+				stop.opacity = math.Max(math.Min(v, 1), 0)
+			}
+			stops = append(stops, stop)
+		}
+		if len(stops) > 0 {
+			return
+		}
+		node = r.ids[strings.TrimPrefix(node.attr["href"], "#")]
+	}
+	return
+}
+
+// gradient writes the operators that paint the gradient grad, with the
+// colors stops, over the clipping path. bounds is the rectangle that encloses
+// the path.
+func (r *svgRenderer) gradient(grad *svgNode, stops []svgStop, bounds [4]float64) {
+	f := r.f
+	attr := func(name, def string) string {
+		if value, ok := r.gradientAttr(grad, name); ok {
+			return value
+		}
+		return def
+	}
+	userSpace := attr("gradientUnits", "") == "userSpaceOnUse"
+	if !userSpace {
+		if bounds[2] == 0 || bounds[3] == 0 {
+			return
+		}
+		r.buf.printf("%.5f 0 0 %.5f %.5f %.5f cm\n", bounds[2], bounds[3], bounds[0], bounds[1])
+	}
+	r.transform(attr("gradientTransform", ""))
+	coord := func(name, def string, ref byte) float64 {
+		value := attr(name, def)
+		if !userSpace {
+			if v, ok := svgLength(value, 1, 0); ok {
+				return v
+			}
+			return 0
+		}
+		v, _ := r.lengthValue(value, ref)
+		return v
+	}
+	// The colors before the first stop and after the last one are those of
+	// these stops
+	if stops[0].offset > 0 {
+		stops = append([]svgStop{{0, stops[0].clr, 1}}, stops...)
+	}
+	if stops[len(stops)-1].offset < 1 {
+		stops = append(stops, svgStop{1, stops[len(stops)-1].clr, 1})
+	}
+	list := make([]gradientStopType, len(stops))
+	for j, stop := range stops {
+		list[j] = gradientStopType{stop.offset, rgbColorValue(stop.clr.R, stop.clr.G, stop.clr.B, "", "").str}
+	}
+	var pos int
+	if grad.tag == "linearGradient" {
+		pos = f.gradientStops(2, list, coord("x1", "0%", 'x'), coord("y1", "0%", 'y'),
+			coord("x2", "100%", 'x'), coord("y2", "0%", 'y'), 0)
+	} else {
+		cx, cy := coord("cx", "50%", 'x'), coord("cy", "50%", 'y')
+		fx := coord("fx", attr("cx", "50%"), 'x')
+		fy := coord("fy", attr("cy", "50%"), 'y')
+		pos = f.gradientStops(3, list, fx, fy, cx, cy, coord("r", "50%", 'r'))
+	}
+	r.buf.printf("/Sh%d sh\n", pos)
+}
+
+// gradientStops adds a gradient of type tp (2 for linear, 3 for radial) with
+// the colors stops, the first of which has offset 0 and the last offset 1,
+// and returns its number
+func (f *Fpdf) gradientStops(tp int, stops []gradientStopType, x1, y1, x2, y2, r float64) int {
+	gr := gradientType{tp: tp, clr1Str: stops[0].clrStr, clr2Str: stops[len(stops)-1].clrStr,
+		x1: x1, y1: y1, x2: x2, y2: y2, r: r}
+	if len(stops) > 2 {
+		gr.stops = stops
+	}
+	f.gradientList = append(f.gradientList, gr)
+	return len(f.gradientList) - 1
+}
+
+// alphaState returns the number of the graphics state that sets the stroke
+// alpha and the fill alpha, which SetAlpha() shares when they are equal
+func (f *Fpdf) alphaState(stroke, fill float64) int {
+	strokeStr, fillStr := sprintf("%.3f", stroke), sprintf("%.3f", fill)
+	keyStr := sprintf("%s %s Normal", strokeStr, fillStr)
+	if strokeStr == fillStr {
+		keyStr = sprintf("%s Normal", fillStr)
+	}
+	pos, ok := f.blendMap[keyStr]
+	if !ok {
+		pos = len(f.blendList)
+		f.blendList = append(f.blendList, blendModeType{strokeStr, fillStr, "Normal", 0})
+		f.blendMap[keyStr] = pos
+	}
+	return pos
+}
+
+// paint fills and strokes the path segs with the properties st
+func (r *svgRenderer) paint(segs []svgSeg, st svgStyle) {
+	if len(segs) == 0 || st.hidden {
+		return
+	}
+	fillClr, grad, stops, fillAlpha, fill := r.paintColor(st.fill, st)
+	strokeClr, _, _, strokeAlpha, stroke := r.paintColor(st.stroke, st)
+	stroke = stroke && st.strokeWidth > 0
+	if !fill && !stroke {
+		return
+	}
+	fillAlpha *= st.fillOpacity * st.opacity
+	strokeAlpha *= st.strokeOpacity * st.opacity
+	if fill && fillAlpha < 1 || stroke && strokeAlpha < 1 {
+		r.buf.printf("/GS%d gs\n", r.f.alphaState(strokeAlpha, fillAlpha))
+	}
+	if stroke {
+		r.buf.printf("%s\n%.5f w %d J %d j\n", rgbColorValue(strokeClr.R, strokeClr.G, strokeClr.B, "G", "RG").str,
+			st.strokeWidth, st.capStyle, st.joinStyle)
+		if len(st.dashArray) > 0 {
+			var dash []string
+			for _, v := range st.dashArray {
+				dash = append(dash, sprintf("%.5f", v))
+			}
+			r.buf.printf("[%s] %.5f d\n", strings.Join(dash, " "), st.dashPhase)
+		}
+	}
+	star := strIf(st.evenOdd, "*", "")
+	if fill && grad != nil {
+		x, y, w, h := svgBounds(segs)
+		r.buf.printf("q\n")
+		r.path(segs)
+		r.buf.printf("W%s n\n", star)
+		r.gradient(grad, stops, [4]float64{x, y, w, h})
+		r.buf.printf("Q\n")
+		fill = false
+		if !stroke {
+			return
+		}
+	}
+	if fill {
+		r.buf.printf("%s\n", rgbColorValue(fillClr.R, fillClr.G, fillClr.B, "g", "rg").str)
+	}
+	r.path(segs)
+	switch {
+	case fill && stroke:
+		r.buf.printf("B%s\n", star)
+	case fill:
+		r.buf.printf("f%s\n", star)
+	default:
+		r.buf.printf("S\n")
+	}
+}
+
+// svgRun is text of a text element with its properties and the position
+// attributes of the element that starts with it
+type svgRun struct {
+	text           string
+	st             svgStyle
+	hasX, hasY     bool
+	x, y, dx, dy   float64
+	width          float64
+	font           fontDefType
+	utf8           bool
+	fontSize, xPos float64
+}
+
+// text draws the text element node
+func (r *svgRenderer) text(node *svgNode, st svgStyle) {
+	var runs []svgRun
+	var pending svgRun
+	var walk func(node *svgNode, st svgStyle)
+	walk = func(node *svgNode, st svgStyle) {
+		if list := svgNumbers(node.attr["x"]); len(list) > 0 {
+			pending.hasX, pending.x = true, list[0]
+		}
+		if list := svgNumbers(node.attr["y"]); len(list) > 0 {
+			pending.hasY, pending.y = true, list[0]
+		}
+		if list := svgNumbers(node.attr["dx"]); len(list) > 0 {
+			pending.dx += list[0]
+		}
+		if list := svgNumbers(node.attr["dy"]); len(list) > 0 {
+			pending.dy += list[0]
+		}
+		for _, child := range node.children {
+			switch child.tag {
+			case "":
+				run := pending
+				run.text, run.st = child.text, st
+				runs = append(runs, run)
+				pending = svgRun{}
+			case "tspan", "a":
+				if childSt := r.style(child, st); !childSt.none {
+					walk(child, childSt)
+				}
+			}
+		}
+	}
+	walk(node, st)
+	// White space is collapsed as with the default value of xml:space
+	space := true
+	for j := range runs {
+		text := strings.NewReplacer("\n", "", "\r", "", "\t", " ").Replace(runs[j].text)
+		var b strings.Builder
+		for _, c := range text {
+			if c == ' ' && space {
+				continue
+			}
+			space = c == ' '
+			b.WriteRune(c)
+		}
+		runs[j].text = b.String()
+	}
+	for j := len(runs) - 1; j >= 0; j-- {
+		if runs[j].text != "" {
+			runs[j].text = strings.TrimRight(runs[j].text, " ")
+			break
+		}
+	}
+	// Runs are measured, and positioned in chunks that start with an
+	// absolute position and are aligned with their text-anchor property
+	f := r.f
+	var x, y float64
+	chunk := 0
+	align := func(end int) {
+		if chunk >= end {
+			return
+		}
+		var shift float64
+		switch runs[chunk].st.anchor {
+		case "middle":
+			shift = (runs[end-1].xPos + runs[end-1].width - runs[chunk].xPos) / 2
+		case "end":
+			shift = runs[end-1].xPos + runs[end-1].width - runs[chunk].xPos
+		}
+		for j := chunk; j < end; j++ {
+			runs[j].xPos -= shift
+		}
+	}
+	for j := range runs {
+		run := &runs[j]
+		if run.hasX && j > 0 {
+			align(j)
+			chunk = j
+		}
+		if run.hasX {
+			x = run.x
+		}
+		if run.hasY {
+			y = run.y
+		}
+		x, y = x+run.dx, y+run.dy
+		r.font(run.st)
+		if f.err != nil {
+			return
+		}
+		run.font, run.utf8, run.fontSize = f.currentFont, f.isCurrentUTF8, run.st.fontSize
+		if !run.utf8 {
+			if r.tr == nil {
+				r.tr = f.UnicodeTranslatorFromDescriptor("")
+			}
+			run.text = r.tr(run.text)
+		}
+		run.width = float64(f.GetStringSymbolWidth(run.text)) * run.fontSize / 1000
+		run.xPos, run.y = x, y
+		x += run.width
+	}
+	align(len(runs))
+	for _, run := range runs {
+		clr, _, _, alpha, ok := r.paintColor(run.st.fill, run.st)
+		if run.text == "" || run.st.hidden || !ok {
+			continue
+		}
+		var txt string
+		if run.utf8 {
+			txt = f.escape(utf8toutf16(run.text, false))
+			for _, c := range run.text {
+				run.font.usedRunes[int(c)] = int(c)
+			}
+		} else {
+			txt = f.escape(run.text)
+		}
+		r.buf.printf("q\n")
+		if alpha *= run.st.fillOpacity * run.st.opacity; alpha < 1 {
+			r.buf.printf("/GS%d gs\n", f.alphaState(alpha, alpha))
+		}
+		r.buf.printf("%s\nBT ", rgbColorValue(clr.R, clr.G, clr.B, "g", "rg").str)
+		if f.charSpacing != 0 || f.wordSpacing != 0 || f.textRise != 0 {
+			r.buf.printf("0 Tc 0 Tw 0 Ts ")
+		}
+		r.buf.printf("/F%s %.5f Tf 1 0 0 -1 %.5f %.5f Tm (%s) Tj ET\nQ\n",
+			run.font.i, run.fontSize, run.xPos, run.y, txt)
+	}
+}
+
+// font selects the font of the properties st
+func (r *svgRenderer) font(st svgStyle) {
+	f := r.f
+	family, ok := f.cssFontFamily(st.fontFamily)
+	if !ok {
+		family = r.family
+	}
+	var style string
+	if st.bold {
+		style += "B"
+	}
+	if st.italic {
+		style += "I"
+	}
+	if _, core := f.coreFonts[family]; !core {
+		if _, ok := f.fonts[family+style]; !ok {
+			style = ""
+		}
+	}
+	f.SetFont(family, style, st.fontSize)
+}
@@ -0,0 +1,48 @@
+package gofpdf
+
+import (
+	"math"
+	"testing"
+)
+
+// TestSVGPath checks that the commands of SVG path data are made absolute
+// and converted to lines and cubic curves
+func TestSVGPath(t *testing.T) {
+	for _, tc := range []struct {
+		d    string
+		n    int
+		x, y float64
+	}{
+		{"M10 20 l10-10h5v5z", 5, 10, 20},
+		{"M0 0L10.5.5", 2, 10.5, 0.5},
+		{"M0,0 1e1,0 10 10", 3, 10, 10},
+		{"M0 0 C0 10 10 10 10 0 S20 -10 20 0", 3, 20, 0},
+		{"M0 0 Q5 10 10 0 T20 0", 3, 20, 0},
+		{"M10 10 a10 10 0 0 1 20 0", 3, 30, 10},
+		{"M10 10 a10 10 0 1 0 0 .5", 5, 10, 10.5},
+		{"M0 0 L10 10 L20", 2, 10, 10},
+	} {
+		segs := svgPath(tc.d)
+		if len(segs) != tc.n {
+			t.Fatalf("%s: %d segments, expected %d", tc.d, len(segs), tc.n)
+		}
+		last := segs[len(segs)-1]
+		if last.cmd == 'Z' {
+			last = segs[0]
+		}
+		j := map[byte]int{'M': 0, 'L': 0, 'C': 4}[last.cmd]
+		if math.Abs(last.pts[j]-tc.x) > 1e-9 || math.Abs(last.pts[j+1]-tc.y) > 1e-9 {
+			t.Fatalf("%s: path ends at (%g, %g)", tc.d, last.pts[j], last.pts[j+1])
+		}
+	}
+	// The smooth curve reflects the second control point of the previous one
+	segs := svgPath("M0 0 C0 10 10 10 10 0 S20 -10 20 0")
+	if c := segs[2].pts; c[0] != 10 || c[1] != -10 {
+		t.Fatalf("smooth curve starts with control point (%g, %g)", c[0], c[1])
+	}
+	// Half a circle of radius 10 passes 10 below its center
+	segs = svgPath("M0 0 A10 10 0 0 0 20 0")
+	if len(segs) != 3 || math.Abs(segs[1].pts[5]-10) > 1e-9 {
+		t.Fatalf("unexpected arc %v", segs)
+	}
+}
@@ -202,7 +202,8 @@ type SVGBasicType struct {
 // includes only the commands 'M' (absolute moveto: x, y), 'L' (absolute
 // lineto: x, y), 'C' (absolute cubic Bézier curve: cx0, cy0, cx1, cy1,
 // x1,y1), 'Q' (absolute quadratic Bézier curve: x0, y0, x1, y1) and 'Z'
-// (closepath).
+// (closepath). Images that use more of the standard can be drawn with
+// DrawSVG().
 func SVGBasicParse(buf []byte) (sig SVGBasicType, err error) {
 	type pathType struct {
 		D string `xml:"d,attr"`
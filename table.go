@@ -0,0 +1,397 @@
+package gofpdf
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Column width strategies used in the Strategy field of TableColumnType
+const (
+	// ColumnAuto sizes the column to fit its widest content. If the table is
+	// too narrow for the natural widths of its automatic columns, they are
+	// reduced in proportion and their content is wrapped.
+	ColumnAuto = iota
+	// ColumnFixed sets the column width to Width user units.
+	ColumnFixed
+	// ColumnPercent sets the column width to Width percent of the table
+	// width.
+	ColumnPercent
+)
+
+// TableColumnType describes one column of a table created with NewTable().
+// Header is the text of the column's header cell; if no column has a header,
+// no header row is drawn. Strategy is one of ColumnAuto, ColumnFixed or
+// ColumnPercent, and Width is interpreted accordingly. Align is the horizontal
+// alignment of the column's cells: "L" (the default), "C" or "R".
+type TableColumnType struct {
+	Header   string
+	Strategy int
+	Width    float64
+	Align    string
+}
+
+// TableType is a table of text that is laid out and drawn by gofpdf. It is
+// created with NewTable(); rows are added with AddRow() and the table is drawn
+// at the current position with Draw().
+type TableType struct {
+	pdf         *Fpdf
+	columns     []TableColumnType
+	rows        [][]string
+	width       float64 // 0 for the width between the margins
+	padding     float64
+	lineHt      float64 // 0 for a value based on the font size
+	borderStr   string
+	valignStr   string
+	headerStyle string
+	headerFill  *RGBType
+	bodyStyle   string // font style in effect when Draw() is called
+}
+
+// NewTable returns a table with the specified columns. By default the table
+// spans the width between the left and right margins, cells have a padding of
+// one millimeter and a full border, and text is aligned with the top of its
+// cell.
+//
+// When drawn, rows that do not fit on the current page are moved to the next
+// page and the header row is repeated. A row that is taller than a page is
+// split across pages.
+//
+// The NewTable example demonstrates this method.
+func (f *Fpdf) NewTable(columns []TableColumnType) *TableType {
+	t := &TableType{pdf: f, padding: 72 / 25.4 / f.k, borderStr: "1", valignStr: "T"}
+	t.columns = append(t.columns, columns...)
+	if len(columns) == 0 && f.err == nil {
+		f.err = fmt.Errorf("a table must have at least one column")
+	}
+	return t
+}
+
+// SetWidth sets the overall width of the table in user units. A value of zero
+// restores the default, the width between the page margins. Percentage
+// columns are based on this width.
+func (t *TableType) SetWidth(w float64) {
+	t.width = w
+}
+
+// SetPadding sets the space between the border of each cell and its text, in
+// user units.
+func (t *TableType) SetPadding(padding float64) {
+	t.padding = padding
+}
+
+// SetLineHeight sets the height of each line of text in user units. A value
+// of zero restores the default, 1.25 times the font size in effect when the
+// table is drawn.
+func (t *TableType) SetLineHeight(h float64) {
+	t.lineHt = h
+}
+
+// SetBorder specifies the border of the cells: "1" draws every cell border
+// (the default) and "" draws none.
+func (t *TableType) SetBorder(borderStr string) {
+	t.borderStr = borderStr
+}
+
+// SetVerticalAlign specifies the vertical alignment of text within its cell:
+// "T" for top (the default), "M" for middle or "B" for bottom. Rows that are
+// split across pages are always aligned to the top.
+func (t *TableType) SetVerticalAlign(alignStr string) {
+	t.valignStr = strings.ToUpper(alignStr)
+}
+
+// SetHeaderStyle sets the font style, for example "B", of the header row, and
+// its background color. If fill is nil the header background is not filled.
+func (t *TableType) SetHeaderStyle(styleStr string, fill *RGBType) {
+	t.headerStyle = styleStr
+	t.headerFill = fill
+}
+
+// AddRow appends a row to the table. The number of cells must match the
+// number of columns. A cell may contain newline characters to force line
+// breaks.
+func (t *TableType) AddRow(cells ...string) {
+	if t.pdf.err != nil {
+		return
+	}
+	if len(cells) != len(t.columns) {
+		t.pdf.err = fmt.Errorf("table row has %d cells, expecting %d", len(cells), len(t.columns))
+		return
+	}
+	t.rows = append(t.rows, append([]string(nil), cells...))
+}
+
+// hasHeader returns true if any column has a header
+func (t *TableType) hasHeader() bool {
+	for _, col := range t.columns {
+		if col.Header != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// naturalWidth returns the width that txtStr needs without wrapping
+func (t *TableType) naturalWidth(txtStr string) (w float64) {
+	for _, line := range strings.Split(txtStr, "\n") {
+		if lw := t.pdf.GetStringWidth(line); lw > w {
+			w = lw
+		}
+	}
+	return w + 2*t.padding
+}
+
+// columnWidths calculates the width of each column
+func (t *TableType) columnWidths() []float64 {
+	f := t.pdf
+	tableWd := t.width
+	if tableWd <= 0 {
+		tableWd = f.w - f.lMargin - f.rMargin
+	}
+	widths := make([]float64, len(t.columns))
+	natural := make([]float64, len(t.columns))
+	remaining := tableWd
+	autoWd := 0.0
+	for j, col := range t.columns {
+		switch col.Strategy {
+		case ColumnFixed:
+			widths[j] = col.Width
+			remaining -= col.Width
+		case ColumnPercent:
+			widths[j] = tableWd * col.Width / 100
+			remaining -= widths[j]
+		default:
+			if t.headerStyle != "" {
+				f.SetFontStyle(t.headerStyle)
+			}
+			natural[j] = t.naturalWidth(col.Header)
+			if t.headerStyle != "" {
+				f.SetFontStyle(t.bodyStyle)
+			}
+			for _, row := range t.rows {
+				if w := t.naturalWidth(row[j]); w > natural[j] {
+					natural[j] = w
+				}
+			}
+			autoWd += natural[j]
+		}
+	}
+	scale := 1.0
+	if autoWd > remaining && autoWd > 0 {
+		scale = remaining / autoWd
+	}
+	for j, col := range t.columns {
+		if col.Strategy != ColumnFixed && col.Strategy != ColumnPercent {
+			widths[j] = natural[j] * scale
+		}
+	}
+	return widths
+}
+
+// tableRowType holds the wrapped lines of each cell of a row
+type tableRowType struct {
+	lines  [][]string
+	header bool
+}
+
+// maxLines returns the largest number of lines of any cell in the row
+func (r tableRowType) maxLines() (n int) {
+	for _, lines := range r.lines {
+		if len(lines) > n {
+			n = len(lines)
+		}
+	}
+	if n == 0 {
+		n = 1
+	}
+	return
+}
+
+// wrap returns the lines of each cell of a row
+func (t *TableType) wrap(cells []string, widths []float64, header bool) (r tableRowType) {
+	r.header = header
+	r.lines = make([][]string, len(cells))
+	for j, txtStr := range cells {
+		for _, para := range strings.Split(txtStr, "\n") {
+			lines := t.pdf.SplitText(para, widths[j])
+			if len(lines) == 0 {
+				lines = []string{""}
+			}
+			r.lines[j] = append(r.lines[j], lines...)
+		}
+	}
+	return
+}
+
+// drawSegment draws the lines first through first+count-1 of each cell of a
+// row at the current vertical position, in a band of height count lines
+// regardless of how many lines each cell has
+func (t *TableType) drawSegment(r tableRowType, widths []float64, first, count int, valign bool) {
+	f := t.pdf
+	h := float64(count)*t.lineHt + 2*t.padding
+	x, y := f.lMargin, f.y
+	fill := f.color.fill
+	if r.header && t.headerStyle != "" {
+		f.SetFontStyle(t.headerStyle)
+	}
+	for j, w := range widths {
+		styleStr := ""
+		if t.borderStr != "" {
+			styleStr = "D"
+		}
+		if r.header && t.headerFill != nil {
+			f.SetFillColor(t.headerFill.R, t.headerFill.G, t.headerFill.B)
+			styleStr = "F" + styleStr
+		}
+		if styleStr != "" {
+			f.Rect(x, y, w, h, styleStr)
+		}
+		lines := r.lines[j]
+		if first < len(lines) {
+			lines = lines[first:]
+		} else {
+			lines = nil
+		}
+		if len(lines) > count {
+			lines = lines[:count]
+		}
+		dy := 0.0
+		if valign {
+			spare := float64(count-len(lines)) * t.lineHt
+			switch t.valignStr {
+			case "M":
+				dy = spare / 2
+			case "B":
+				dy = spare
+			}
+		}
+		alignStr := t.columns[j].Align
+		if alignStr == "" {
+			alignStr = "L"
+		}
+		for k, line := range lines {
+			f.SetXY(x, y+t.padding+dy+float64(k)*t.lineHt)
+			f.CellFormat(w, t.lineHt, line, "", 0, alignStr, false, 0, "")
+		}
+		x += w
+	}
+	if r.header && t.headerStyle != "" {
+		f.SetFontStyle(t.bodyStyle)
+	}
+	if r.header && t.headerFill != nil {
+		// Restore the fill color, which may be a spot color
+		f.color.fill = fill
+		f.colorFlag = f.color.fill.str != f.color.text.str
+		f.out(fill.str)
+	}
+	f.SetXY(f.lMargin, y+h)
+}
+
+// Draw draws the table at the current vertical position, starting at the left
+// margin. When it returns, the current position is below the table.
+func (t *TableType) Draw() {
+	f := t.pdf
+	if f.err != nil {
+		return
+	}
+	if f.currentFont.Name == "" {
+		f.err = fmt.Errorf("font has not been set; unable to draw table")
+		return
+	}
+	t.bodyStyle = f.fontStyle
+	if f.underline {
+		t.bodyStyle += "U"
+	}
+	if f.strikeout {
+		t.bodyStyle += "S"
+	}
+	lineHt := t.lineHt
+	if lineHt <= 0 {
+		t.lineHt = f.fontSize * 1.25
+		defer func() { t.lineHt = lineHt }()
+	}
+	// Cell margins are replaced by the table padding while drawing
+	cMargin := f.cMargin
+	f.cMargin = t.padding
+	defer func() { f.cMargin = cMargin }()
+	widths := t.columnWidths()
+	var header tableRowType
+	hasHeader := t.hasHeader()
+	if hasHeader {
+		if t.headerStyle != "" {
+			f.SetFontStyle(t.headerStyle)
+		}
+		headers := make([]string, len(t.columns))
+		for j, col := range t.columns {
+			headers[j] = col.Header
+		}
+		header = t.wrap(headers, widths, true)
+		if t.headerStyle != "" {
+			f.SetFontStyle(t.bodyStyle)
+		}
+	}
+	height := func(lines int) float64 {
+		return float64(lines)*t.lineHt + 2*t.padding
+	}
+	const epsilon = 1e-6
+	fits := func(h float64) bool {
+		return f.y+h <= f.pageBreakTrigger+epsilon
+	}
+	newPage := func() {
+		f.AddPage()
+		if hasHeader {
+			t.drawSegment(header, widths, 0, header.maxLines(), true)
+		}
+	}
+	if hasHeader {
+		// Keep the header together with the first row
+		firstHt := 0.0
+		if len(t.rows) > 0 {
+			firstHt = height(1)
+		}
+		if !fits(height(header.maxLines())+firstHt) && f.autoPageBreak {
+			f.AddPage()
+		}
+		t.drawSegment(header, widths, 0, header.maxLines(), true)
+	}
+	pageTop := f.tMargin
+	if hasHeader {
+		pageTop += height(header.maxLines())
+	}
+	for _, cells := range t.rows {
+		if f.err != nil {
+			return
+		}
+		r := t.wrap(cells, widths, false)
+		n := r.maxLines()
+		if fits(height(n)) || !f.autoPageBreak {
+			t.drawSegment(r, widths, 0, n, true)
+			continue
+		}
+		// Move the row to the next page if it fits there
+		if f.pageBreakTrigger-pageTop+epsilon >= height(n) {
+			newPage()
+			t.drawSegment(r, widths, 0, n, true)
+			continue
+		}
+		// Otherwise split it across pages
+		for first := 0; first < n; {
+			count := int((f.pageBreakTrigger - f.y - 2*t.padding + epsilon) / t.lineHt)
+			if count > n-first {
+				count = n - first
+			}
+			if count < 1 {
+				if f.y <= pageTop+epsilon {
+					f.err = fmt.Errorf("table line does not fit on page")
+					return
+				}
+				newPage()
+				continue
+			}
+			t.drawSegment(r, widths, first, count, false)
+			first += count
+			if first < n {
+				newPage()
+			}
+		}
+	}
+}
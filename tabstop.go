@@ -0,0 +1,117 @@
+package gofpdf
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// TabStop is a position to which a tab character in text written with
+// Write() advances. Pos is the distance of the tab stop from the left margin
+// in the unit of measure specified in New(). Alignment specifies how the text
+// that follows the tab, up to the next tab or line break, is aligned with the
+// tab stop: "L" (the default) starts the text at the tab stop, "R" ends it
+// there, "C" centers it on the tab stop and "D" places its first decimal
+// point there, or ends it there if it has none. Leader, such as ".", is
+// repeated to fill the space before the text.
+type TabStop struct {
+	Pos       float64
+	Alignment string
+	Leader    string
+}
+
+// SetTabStops sets the tab stops used by Write() and its variants. A tab
+// character in the text advances to the first tab stop to the right of the
+// current position; if there is none, it advances to the next multiple of
+// half an inch from the left margin. A nil slice removes all tab stops.
+//
+// The SetTabStops example demonstrates this method.
+func (f *Fpdf) SetTabStops(stops []TabStop) {
+	if f.err != nil {
+		return
+	}
+	tabStops := make([]TabStop, 0, len(stops))
+	for _, stop := range stops {
+		stop.Alignment = strings.ToUpper(stop.Alignment)
+		if stop.Alignment == "" {
+			stop.Alignment = "L"
+		}
+		if !strings.Contains("LRCD", stop.Alignment) || len(stop.Alignment) != 1 {
+			f.err = fmt.Errorf("invalid tab stop alignment %q", stop.Alignment)
+			return
+		}
+		tabStops = append(tabStops, stop)
+	}
+	sort.SliceStable(tabStops, func(i, j int) bool {
+		return tabStops[i].Pos < tabStops[j].Pos
+	})
+	f.tabStops = tabStops
+}
+
+// GetTabStops returns the tab stops set with SetTabStops().
+func (f *Fpdf) GetTabStops() []TabStop {
+	return append([]TabStop(nil), f.tabStops...)
+}
+
+// nextTabStop returns the first tab stop to the right of pos, measured from
+// the left margin
+func (f *Fpdf) nextTabStop(pos float64) TabStop {
+	for _, stop := range f.tabStops {
+		if stop.Pos > pos {
+			return stop
+		}
+	}
+	interval := 36 / f.k
+	return TabStop{Pos: (math.Floor(pos/interval) + 1) * interval, Alignment: "L"}
+}
+
+// writeTabs writes txtStr, which contains tab characters, with write(),
+// advancing to the tab stops
+func (f *Fpdf) writeTabs(h float64, txtStr string, link int, linkStr string) {
+	pieces := strings.Split(txtStr, "\t")
+	if pieces[0] != "" {
+		f.write(h, pieces[0], link, linkStr)
+	}
+	for _, piece := range pieces[1:] {
+		if f.err != nil {
+			return
+		}
+		// Positions are those of the printed text, which Write() places
+		// one cell margin to the right of the current position
+		end := f.x + f.cMargin
+		stop := f.nextTabStop(end - f.lMargin)
+		text := piece
+		if pos := strings.IndexByte(text, '\n'); pos >= 0 {
+			text = text[:pos]
+		}
+		start := f.lMargin + stop.Pos
+		switch stop.Alignment {
+		case "R":
+			start -= f.GetStringWidth(text)
+		case "C":
+			start -= f.GetStringWidth(text) / 2
+		case "D":
+			if pos := strings.IndexByte(text, '.'); pos >= 0 {
+				start -= f.GetStringWidth(text[:pos])
+			} else {
+				start -= f.GetStringWidth(text)
+			}
+		}
+		start = math.Max(start, end)
+		if stop.Leader != "" {
+			// Leaders are aligned on a grid so that they line up from line
+			// to line
+			wd := f.GetStringWidth(stop.Leader)
+			first := f.lMargin + math.Ceil((end-f.lMargin)/wd)*wd
+			if n := int((start - first) / wd); n > 0 {
+				f.x = first - f.cMargin
+				f.CellFormat(float64(n)*wd, h, strings.Repeat(stop.Leader, n), "", 0, "", false, 0, "")
+			}
+		}
+		f.x = start - f.cMargin
+		if piece != "" {
+			f.write(h, piece, link, linkStr)
+		}
+	}
+}
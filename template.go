@@ -67,15 +67,60 @@ func (f *Fpdf) UseTemplate(t Template) {
 // UseTemplateScaled adds a template to the current page or another template,
 // using the given page coordinates.
 func (f *Fpdf) UseTemplateScaled(t Template, corner PointType, size SizeType) {
+	if !f.templateUse(t) {
+		return
+	}
+
+	// template data
+	_, templateSize := t.Size()
+	scaleX := size.Wd / templateSize.Wd
+	scaleY := size.Ht / templateSize.Ht
+	tx := corner.X * f.k
+	ty := (f.curPageSize.Ht - corner.Y - size.Ht) * f.k
+
+	f.outf("q %.4f 0 0 %.4f %.4f %.4f cm", scaleX, scaleY, tx, ty) // Translate
+	f.outf("/TPL%s Do Q", t.ID())
+}
+
+// UseTemplateTransformed adds a template to the current page or another
+// template, placed with the matrix m, which combines any scaling, rotation,
+// skewing and translation computed by the caller. m maps a point (x, y) of
+// the template, relative to its upper left corner, to the point (A*x + C*y +
+// E, B*x + D*y + F) of the page. Unlike the matrix of Transform(), m is in
+// the unit of measure of the document, and y grows downwards, as it does for
+// the other methods of Fpdf: the matrix {1, 0, 0, 1, x, y} places the template
+// at its original size with its upper left corner at (x, y).
+//
+// The ImageTransformed example demonstrates this method.
+func (f *Fpdf) UseTemplateTransformed(t Template, m TransformMatrix) {
+	if !f.templateUse(t) {
+		return
+	}
+	_, size := t.Size()
+	// The subtractions from 0 keep zeros from being printed as -0
+	f.outf("q %.5f %.5f %.5f %.5f %.5f %.5f cm /TPL%s Do Q", m.A, 0-m.B, 0-m.C, m.D,
+		(m.C*size.Ht+m.E)*f.k, (f.h-m.D*size.Ht-m.F)*f.k, t.ID())
+}
+
+// templateUse notes that the template t, and the templates, images and fonts
+// it uses, are used by the document, and returns false if t cannot be used
+func (f *Fpdf) templateUse(t Template) bool {
+	if f.err != nil {
+		return false
+	}
 	if t == nil {
 		f.SetErrorf("template is nil")
-		return
+		return false
 	}
 
 	// You have to add at least a page first
 	if f.page <= 0 {
 		f.SetErrorf("cannot use a template without first adding a page")
-		return
+		return false
+	}
+	if tpl, ok := t.(*FpdfTpl); ok && tpl.capture != nil && tpl.capture.doc != f {
+		f.SetErrorf("template of a page region cannot be used in another document")
+		return false
 	}
 
 	// make a note of the fact that we actually use this template, as well as any other templates,
@@ -93,6 +138,7 @@ func (f *Fpdf) UseTemplateScaled(t Template, corner PointType, size SizeType) {
 
 	// Add each template image to $f, unless already present.
 	for name, ti := range t.Images() {
+		f.imagesUsed[ti.i] = true
 		if _, found := existingImages[ti.i]; found {
 			continue
 		}
@@ -100,15 +146,13 @@ func (f *Fpdf) UseTemplateScaled(t Template, corner PointType, size SizeType) {
 		f.images[name] = ti
 	}
 
-	// template data
-	_, templateSize := t.Size()
-	scaleX := size.Wd / templateSize.Wd
-	scaleY := size.Ht / templateSize.Ht
-	tx := corner.X * f.k
-	ty := (f.curPageSize.Ht - corner.Y - size.Ht) * f.k
-
-	f.outf("q %.4f 0 0 %.4f %.4f %.4f cm", scaleX, scaleY, tx, ty) // Translate
-	f.outf("/TPL%s Do Q", t.ID())
+	// Add the fonts of the template and the templates it uses to $f, unless
+	// already present.
+	f.templateFonts(t)
+	for _, tt := range t.Templates() {
+		f.templateFonts(tt)
+	}
+	return true
 }
 
 // Template is an object that can be written to, then used and re-used any number of times within a document.
@@ -126,6 +170,82 @@ type Template interface {
 	gob.GobEncoder
 }
 
+// templateFonts adds the fonts used by the template t, which has been
+// created with CreateTpl() or read from a file, to the document, unless the
+// document has the same fonts already. The runes of UTF-8 fonts that t uses
+// are added to those of the font of the document.
+func (f *Fpdf) templateFonts(t Template) {
+	tpl, ok := t.(*FpdfTpl)
+	if !ok || len(tpl.fonts) == 0 {
+		return
+	}
+	f.mergeFonts(tpl.fonts, tpl.fontFiles)
+}
+
+// mergeFonts adds fonts, with their files in fontFiles, to the document, in
+// the way templateFonts() does
+func (f *Fpdf) mergeFonts(fonts map[string]fontDefType, fontFiles map[string]fontFileType) {
+	existingFonts := make(map[string]string, len(f.fonts))
+	for key, font := range f.fonts {
+		existingFonts[font.i] = key
+	}
+	var keyList []string
+	for key := range fonts {
+		keyList = append(keyList, key)
+	}
+	sort.Strings(keyList)
+	for _, key := range keyList {
+		font := fonts[key]
+		if docKey, found := existingFonts[font.i]; found {
+			docFont := f.fonts[docKey]
+			if docFont.Tp == "UTF8" && len(font.usedRunes) > 0 {
+				if docFont.usedRunes == nil {
+					docFont.usedRunes = make(map[int]int, len(font.usedRunes))
+					f.fonts[docKey] = docFont
+				}
+				for r, v := range font.usedRunes {
+					docFont.usedRunes[r] = v
+				}
+			}
+			continue
+		}
+		fileKey := font.File
+		if font.Tp == "UTF8" {
+			fileKey = key
+		}
+		if _, taken := f.fonts[key]; taken {
+			key = "tpl" + font.i
+		}
+		if font.usedRunes != nil {
+			usedRunes := make(map[int]int, len(font.usedRunes))
+			for r, v := range font.usedRunes {
+				usedRunes[r] = v
+			}
+			font.usedRunes = usedRunes
+		}
+		if len(font.Diff) > 0 {
+			font.DiffN = -1
+			for j, str := range f.diffs {
+				if str == font.Diff {
+					font.DiffN = j + 1
+					break
+				}
+			}
+			if font.DiffN < 0 {
+				f.diffs = append(f.diffs, font.Diff)
+				font.DiffN = len(f.diffs)
+			}
+		}
+		f.fonts[key] = font
+		existingFonts[font.i] = key
+		if file, ok := fontFiles[fileKey]; ok {
+			if _, found := f.fontFiles[fileKey]; !found {
+				f.fontFiles[fileKey] = file
+			}
+		}
+	}
+}
+
 func (f *Fpdf) templateFontCatalog() {
 	var keyList []string
 	var font fontDefType
@@ -137,9 +257,14 @@ func (f *Fpdf) templateFontCatalog() {
 	if f.catalogSort {
 		sort.Strings(keyList)
 	}
+	written := make(map[string]bool, len(keyList))
 	for _, key = range keyList {
 		font = f.fonts[key]
-		f.outf("/F%s %d 0 R", font.i, font.N)
+		// Fonts that have been added under several keys are listed once
+		if !written[font.i] {
+			written[font.i] = true
+			f.outf("/F%s %d 0 R", font.i, font.N)
+		}
 	}
 	f.out(">>")
 }
@@ -148,12 +273,16 @@ func (f *Fpdf) templateFontCatalog() {
 func (f *Fpdf) putTemplates() {
 	filter := ""
 	if f.compress {
-		filter = "/Filter /FlateDecode "
+		filter = "/Filter /" + f.filterName() + " "
 	}
 
 	templates := sortTemplates(f.templates, f.catalogSort)
 	var t Template
 	for _, t = range templates {
+		if tpl, ok := t.(*FpdfTpl); ok && tpl.capture != nil {
+			f.putPageCapture(tpl)
+			continue
+		}
 		corner, size := t.Size()
 
 		f.newobj()
@@ -186,15 +315,22 @@ func (f *Fpdf) putTemplates() {
 				if gl.catalogSort {
 					sort.Strings(keyList)
 				}
+				written := make(map[string]bool, len(keyList))
 				for _, key = range keyList {
 					// for _, ti := range tImages {
 					ti = tImages[key]
-					f.outf("/I%s %d 0 R", ti.i, ti.n)
+					// Images are written once per content hash
+					if !written[ti.i] {
+						written[ti.i] = true
+						f.outf("/I%s %d 0 R", ti.i, f.resourceObjs["I"+ti.i])
+					}
 				}
 			}
+			written := make(map[string]bool, len(tTemplates))
 			for _, tt := range tTemplates {
 				id := tt.ID()
-				if objID, ok := f.templateObjects[id]; ok {
+				if objID, ok := f.templateObjects[id]; ok && !written[id] {
+					written[id] = true
 					f.outf("/TPL%s %d 0 R", id, objID)
 				}
 			}
@@ -207,9 +343,9 @@ func (f *Fpdf) putTemplates() {
 		buffer := t.Bytes()
 		// fmt.Println("Put template bytes", string(buffer[:]))
 		if f.compress {
-			buffer = sliceCompress(buffer)
+			buffer = f.compressStream(buffer)
 		}
-		f.outf("/Length %d >>", len(buffer))
+		f.outf("/Length %d >>", f.protect.streamLength(len(buffer)))
 		f.putstream(buffer)
 		f.out("endobj")
 	}
@@ -253,14 +389,13 @@ func sortTemplates(templates map[string]Template, catalogSort bool) []Template {
 
 	// reduce that to make a simple list
 	sorted := make([]Template, 0, len(templates))
-chain:
+	// templates with the same ID have the same content and are written once
+	written := make(map[string]bool, len(chain))
 	for _, t := range chain {
-		for _, already := range sorted {
-			if t == already {
-				continue chain
-			}
+		if id := t.ID(); !written[id] {
+			written[id] = true
+			sorted = append(sorted, t)
 		}
-		sorted = append(sorted, t)
 	}
 
 	return sorted
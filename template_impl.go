@@ -47,9 +47,25 @@ func newTpl(corner PointType, size SizeType, orientationStr, unitStr, fontDirStr
 	for _, key := range templateKeyList(tpl.Fpdf.templates, true) {
 		templates = append(templates, tpl.Fpdf.templates[key])
 	}
-	images := tpl.Fpdf.images
+	images := make(map[string]*ImageInfoType, len(tpl.Fpdf.images))
+	for key, info := range tpl.Fpdf.images {
+		if tpl.Fpdf.imagesUsed[info.i] {
+			images[key] = info
+		}
+	}
 
-	template := FpdfTpl{corner, size, bytes, images, templates, tpl.Fpdf.page}
+	// The fonts are copied, since fonts that are added to the document later
+	// are not used by the template
+	fonts := make(map[string]fontDefType, len(tpl.Fpdf.fonts))
+	for key, font := range tpl.Fpdf.fonts {
+		fonts[key] = font
+	}
+	fontFiles := make(map[string]fontFileType, len(tpl.Fpdf.fontFiles))
+	for key, file := range tpl.Fpdf.fontFiles {
+		fontFiles[key] = file
+	}
+
+	template := FpdfTpl{corner, size, bytes, images, templates, tpl.Fpdf.page, fonts, fontFiles, nil}
 	return &template
 }
 
@@ -61,10 +77,20 @@ type FpdfTpl struct {
 	images    map[string]*ImageInfoType
 	templates []Template
 	page      int
+	fonts     map[string]fontDefType
+	fontFiles map[string]fontFileType
+	capture   *pageCaptureType // part of a page captured with CapturePageRegion()
 }
 
 // ID returns the global template identifier
 func (t *FpdfTpl) ID() string {
+	if t.capture != nil {
+		// Regions of the same content differ in their rectangles
+		h := sha1.New()
+		h.Write(t.Bytes())
+		fmt.Fprintf(h, "\x00region %v %v %v %v", t.capture.x, t.capture.y, t.capture.wd, t.capture.ht)
+		return fmt.Sprintf("%x", h.Sum(nil))
+	}
 	return fmt.Sprintf("%x", sha1.Sum(t.Bytes()))
 }
 
@@ -180,6 +206,9 @@ func (t *FpdfTpl) childrensTemplates() []Template {
 // GobEncode encodes the receiving template into a byte buffer. Use GobDecode
 // to decode the byte buffer back to a template.
 func (t *FpdfTpl) GobEncode() ([]byte, error) {
+	if t.capture != nil {
+		return nil, errors.New("template of a page region cannot be serialized")
+	}
 	w := new(bytes.Buffer)
 	encoder := gob.NewEncoder(w)
 
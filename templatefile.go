@@ -0,0 +1,467 @@
+package gofpdf
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// Templates written with WriteTo() start with templateMagic followed by the
+// version of the format. Later releases read the templates written
+// in the versions of the format that precede theirs. Version 2 adds the ICC
+// profiles of images, version 3 their masks.
+const (
+	templateMagic   = "GOFPDFTPL"
+	templateVersion = 3
+)
+
+// lazyImageData locates the data of an image that is read when the image is
+// written to a document, either at an offset of r or with load
+type lazyImageData struct {
+	r          io.ReaderAt
+	offset     int64
+	dataLen    int64
+	smaskLen   int64
+	dataLoaded bool
+	load       func() (data, smask []byte, err error)
+}
+
+// loadImage reads the data of info, if it is read lazily
+func (info *ImageInfoType) loadImage() (err error) {
+	lazy := info.lazy
+	if lazy == nil || lazy.dataLoaded {
+		return
+	}
+	if lazy.load != nil {
+		if info.data, info.smask, err = lazy.load(); err == nil {
+			lazy.dataLoaded = true
+		}
+		return
+	}
+	data := make([]byte, lazy.dataLen+lazy.smaskLen)
+	if _, err = lazy.r.ReadAt(data, lazy.offset); err != nil {
+		return fmt.Errorf("unable to read template image data: %s", err)
+	}
+	info.data = data[:lazy.dataLen]
+	if lazy.smaskLen > 0 {
+		info.smask = data[lazy.dataLen:]
+	}
+	lazy.dataLoaded = true
+	return
+}
+
+// unloadImage releases the data of info, if it is read lazily
+func (info *ImageInfoType) unloadImage() {
+	if lazy := info.lazy; lazy != nil {
+		info.data, info.smask = nil, nil
+		lazy.dataLoaded = false
+	}
+}
+
+// hasSMask reports whether info has a soft mask, whose data may not have
+// been read yet
+func (info *ImageInfoType) hasSMask() bool {
+	return len(info.smask) > 0 || info.lazy != nil && info.lazy.smaskLen > 0
+}
+
+// WriteTo writes the template, with its pages, images and nested templates,
+// to w in a binary format that, unlike the format of Serialize(), remains
+// readable by later releases of this package. Images that the template and
+// its nested templates share are written once. The template is read with
+// ReadTemplate() or OpenTemplate(), for example to keep a library of
+// pre-rendered templates in files or in a cache. Fonts are not written; the
+// document that uses a template that has been read needs to add the fonts of
+// the template itself.
+//
+// The ReadTemplate example demonstrates this method.
+func (t *FpdfTpl) WriteTo(w io.Writer) (n int64, err error) {
+	tw := &templateWriter{w: bufio.NewWriter(w), imageIndex: make(map[string]int)}
+	tw.collect(t)
+	tw.write([]byte(templateMagic))
+	tw.uint(templateVersion)
+	// The images precede the templates, and their data follows them, so that
+	// it can be read lazily
+	tw.uint(uint64(len(tw.images)))
+	for _, info := range tw.images {
+		if err = info.loadImage(); err != nil {
+			return tw.n, err
+		}
+		tw.string(info.i)
+		tw.float(info.w)
+		tw.float(info.h)
+		tw.string(info.cs)
+		tw.bytes(info.pal)
+		tw.uint(uint64(info.bpc))
+		tw.string(info.f)
+		tw.string(info.dp)
+		tw.uint(uint64(len(info.trns)))
+		for _, v := range info.trns {
+			tw.uint(uint64(v))
+		}
+		tw.float(info.scale)
+		tw.float(info.dpi)
+		tw.bytes(info.icc)
+		tw.uint(uint64(len(info.cmask)))
+		for _, v := range info.cmask {
+			tw.uint(uint64(v))
+		}
+		// The mask image precedes the image; 0 is no mask
+		var mask uint64
+		if info.mask != nil {
+			mask = uint64(tw.imageIndex[info.mask.i]) + 1
+		}
+		tw.uint(mask)
+		tw.uint(uint64(len(info.data)))
+		tw.uint(uint64(len(info.smask)))
+	}
+	tw.template(t)
+	for _, info := range tw.images {
+		tw.write(info.data)
+		tw.write(info.smask)
+	}
+	if tw.err == nil {
+		tw.err = tw.w.Flush()
+	}
+	return tw.n, tw.err
+}
+
+// templateWriter writes templates in the format of WriteTo()
+type templateWriter struct {
+	w          *bufio.Writer
+	n          int64
+	err        error
+	images     []*ImageInfoType
+	imageIndex map[string]int // index of images by checksum
+	buf        [binary.MaxVarintLen64]byte
+}
+
+// collect collects the distinct images of t and its nested templates, and
+// their mask images
+func (tw *templateWriter) collect(t *FpdfTpl) {
+	for _, key := range sortedImageKeys(t.images) {
+		tw.collectImage(t.images[key])
+	}
+	for _, child := range t.templates {
+		if c, ok := child.(*FpdfTpl); ok {
+			tw.collect(c)
+		}
+	}
+}
+
+// collectImage collects info, after its mask image
+func (tw *templateWriter) collectImage(info *ImageInfoType) {
+	if _, ok := tw.imageIndex[info.i]; ok {
+		return
+	}
+	if info.mask != nil {
+		tw.collectImage(info.mask)
+	}
+	tw.imageIndex[info.i] = len(tw.images)
+	tw.images = append(tw.images, info)
+}
+
+// template writes t. The images and templates of its nested templates are
+// left to them, in the way GobEncode() leaves them.
+func (tw *templateWriter) template(t *FpdfTpl) {
+	tw.float(t.corner.X)
+	tw.float(t.corner.Y)
+	tw.float(t.size.Wd)
+	tw.float(t.size.Ht)
+	tw.uint(uint64(t.page))
+	tw.uint(uint64(len(t.bytes)))
+	for _, b := range t.bytes {
+		tw.bytes(b)
+	}
+	childImages := t.childrenImages()
+	var keys []string
+	for _, key := range sortedImageKeys(t.images) {
+		if _, ok := childImages[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	tw.uint(uint64(len(keys)))
+	for _, key := range keys {
+		tw.string(key)
+		tw.uint(uint64(tw.imageIndex[t.images[key].i]))
+	}
+	childTemplates := make(map[string]bool)
+	for _, child := range t.childrensTemplates() {
+		childTemplates[child.ID()] = true
+	}
+	var templates []*FpdfTpl
+	for _, child := range t.templates {
+		c, ok := child.(*FpdfTpl)
+		if !ok {
+			tw.err = fmt.Errorf("nested template of type %T cannot be written", child)
+			return
+		}
+		if !childTemplates[c.ID()] {
+			templates = append(templates, c)
+		}
+	}
+	tw.uint(uint64(len(templates)))
+	for _, c := range templates {
+		tw.template(c)
+	}
+}
+
+// write writes b as it is
+func (tw *templateWriter) write(b []byte) {
+	if tw.err == nil {
+		var n int
+		n, tw.err = tw.w.Write(b)
+		tw.n += int64(n)
+	}
+}
+
+// uint writes v as a variable-length integer
+func (tw *templateWriter) uint(v uint64) {
+	tw.write(tw.buf[:binary.PutUvarint(tw.buf[:], v)])
+}
+
+// float writes v as its eight-byte IEEE 754 representation
+func (tw *templateWriter) float(v float64) {
+	binary.BigEndian.PutUint64(tw.buf[:8], math.Float64bits(v))
+	tw.write(tw.buf[:8])
+}
+
+// bytes writes b, preceded by its length
+func (tw *templateWriter) bytes(b []byte) {
+	tw.uint(uint64(len(b)))
+	tw.write(b)
+}
+
+// string writes s, preceded by its length
+func (tw *templateWriter) string(s string) {
+	tw.bytes([]byte(s))
+}
+
+// sortedImageKeys returns the keys of images in order
+func sortedImageKeys(images map[string]*ImageInfoType) []string {
+	keys := make([]string, 0, len(images))
+	for key := range images {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ReadTemplate reads a template written with the WriteTo() method of FpdfTpl
+// from r, with the data of its images.
+//
+// The ReadTemplate example demonstrates this function.
+func ReadTemplate(r io.Reader) (Template, error) {
+	tr := &templateReader{r: bufio.NewReader(r)}
+	t := tr.file()
+	if tr.err == nil {
+		for _, info := range tr.images {
+			lazy := info.lazy
+			data := tr.chunks(int(lazy.dataLen + lazy.smaskLen))
+			if tr.err != nil {
+				break
+			}
+			info.data = data[:lazy.dataLen]
+			if lazy.smaskLen > 0 {
+				info.smask = data[lazy.dataLen:]
+			}
+			info.lazy = nil
+		}
+	}
+	if tr.err != nil {
+		return nil, fmt.Errorf("unable to read template: %s", tr.err)
+	}
+	return t, nil
+}
+
+// OpenTemplate reads a template written with the WriteTo() method of FpdfTpl
+// from r, but leaves the data of its images to be read when the images are
+// written to a document, and releases the data afterwards. This bounds the
+// memory that libraries of templates with large images use. r needs to
+// remain readable as long as the template is used.
+//
+// The ReadTemplate example demonstrates this function.
+func OpenTemplate(r io.ReaderAt) (Template, error) {
+	tr := &templateReader{r: bufio.NewReader(io.NewSectionReader(r, 0, math.MaxInt64))}
+	t := tr.file()
+	if tr.err != nil {
+		return nil, fmt.Errorf("unable to read template: %s", tr.err)
+	}
+	offset := tr.n
+	for _, info := range tr.images {
+		info.lazy.r = r
+		info.lazy.offset = offset
+		offset += info.lazy.dataLen + info.lazy.smaskLen
+	}
+	return t, nil
+}
+
+// templateReader reads templates in the format of WriteTo()
+type templateReader struct {
+	r      *bufio.Reader
+	n      int64 // number of bytes read
+	err    error
+	images []*ImageInfoType
+}
+
+// file reads the header, the images without their data and the templates
+func (tr *templateReader) file() *FpdfTpl {
+	magic := make([]byte, len(templateMagic))
+	if tr.read(magic); tr.err == nil && string(magic) != templateMagic {
+		tr.err = fmt.Errorf("not a template")
+	}
+	version := tr.uint()
+	if tr.err == nil && version > templateVersion {
+		tr.err = fmt.Errorf("unsupported template format version %d", version)
+	}
+	count := tr.count()
+	for j := 0; j < count && tr.err == nil; j++ {
+		info := &ImageInfoType{lazy: &lazyImageData{}}
+		info.i = tr.string()
+		info.w = tr.float()
+		info.h = tr.float()
+		info.cs = tr.string()
+		info.pal = tr.bytes()
+		info.bpc = int(tr.uint())
+		info.f = tr.string()
+		info.dp = tr.string()
+		trns := tr.count()
+		for k := 0; k < trns && tr.err == nil; k++ {
+			info.trns = append(info.trns, int(tr.uint()))
+		}
+		info.scale = tr.float()
+		info.dpi = tr.float()
+		if version >= 2 {
+			info.icc = tr.bytes()
+		}
+		if version >= 3 {
+			cmask := tr.count()
+			for k := 0; k < cmask && tr.err == nil; k++ {
+				info.cmask = append(info.cmask, int(tr.uint()))
+			}
+			if mask := tr.uint(); mask > 0 {
+				if tr.err == nil && mask > uint64(len(tr.images)) {
+					tr.err = fmt.Errorf("invalid template mask image %d", mask-1)
+					return nil
+				}
+				if tr.err == nil {
+					info.mask = tr.images[mask-1]
+				}
+			}
+		}
+		info.lazy.dataLen = int64(tr.count())
+		info.lazy.smaskLen = int64(tr.count())
+		tr.images = append(tr.images, info)
+	}
+	return tr.template()
+}
+
+// template reads a template and its nested templates
+func (tr *templateReader) template() *FpdfTpl {
+	t := new(FpdfTpl)
+	t.corner.X = tr.float()
+	t.corner.Y = tr.float()
+	t.size.Wd = tr.float()
+	t.size.Ht = tr.float()
+	t.page = int(tr.uint())
+	pages := tr.count()
+	for j := 0; j < pages && tr.err == nil; j++ {
+		t.bytes = append(t.bytes, tr.bytes())
+	}
+	if tr.err == nil && (t.page < 1 || t.page >= len(t.bytes)) {
+		tr.err = fmt.Errorf("invalid template page %d", t.page)
+	}
+	images := make(map[string]*ImageInfoType)
+	count := tr.count()
+	for j := 0; j < count && tr.err == nil; j++ {
+		key := tr.string()
+		index := tr.uint()
+		if tr.err == nil && index >= uint64(len(tr.images)) {
+			tr.err = fmt.Errorf("invalid template image %d", index)
+			return nil
+		}
+		images[key] = tr.images[index]
+	}
+	count = tr.count()
+	for j := 0; j < count && tr.err == nil; j++ {
+		t.templates = append(t.templates, tr.template())
+	}
+	if tr.err != nil {
+		return nil
+	}
+	// The images and templates of nested templates are those of the
+	// template as well, in the way GobDecode() restores them
+	t.templates = append(t.childrensTemplates(), t.templates...)
+	t.images = t.childrenImages()
+	for key, info := range images {
+		t.images[key] = info
+	}
+	return t
+}
+
+// ReadByte reads a byte for binary.ReadUvarint()
+func (tr *templateReader) ReadByte() (c byte, err error) {
+	if c, err = tr.r.ReadByte(); err == nil {
+		tr.n++
+	}
+	return
+}
+
+// uint reads a variable-length integer
+func (tr *templateReader) uint() (v uint64) {
+	if tr.err == nil {
+		if v, tr.err = binary.ReadUvarint(tr); tr.err == io.EOF {
+			tr.err = io.ErrUnexpectedEOF
+		}
+	}
+	return
+}
+
+// count reads a number of elements that follow
+func (tr *templateReader) count() int {
+	v := tr.uint()
+	if tr.err == nil && v > math.MaxInt32 {
+		tr.err = fmt.Errorf("invalid number of elements %d", v)
+		return 0
+	}
+	return int(v)
+}
+
+// read reads len(b) bytes
+func (tr *templateReader) read(b []byte) {
+	if tr.err == nil {
+		var n int
+		n, tr.err = io.ReadFull(tr.r, b)
+		tr.n += int64(n)
+	}
+}
+
+// float reads a number written by templateWriter.float()
+func (tr *templateReader) float() float64 {
+	var b [8]byte
+	tr.read(b[:])
+	return math.Float64frombits(binary.BigEndian.Uint64(b[:]))
+}
+
+// bytes reads a byte slice preceded by its length
+func (tr *templateReader) bytes() []byte {
+	return tr.chunks(tr.count())
+}
+
+// chunks reads n bytes in chunks, so that a damaged length does not allocate
+// more memory than the data that is there
+func (tr *templateReader) chunks(n int) (b []byte) {
+	for len(b) < n && tr.err == nil {
+		chunk := make([]byte, min(n-len(b), 1<<16))
+		tr.read(chunk)
+		b = append(b, chunk...)
+	}
+	return b
+}
+
+// string reads a string preceded by its length
+func (tr *templateReader) string() string {
+	return string(tr.bytes())
+}
@@ -0,0 +1,108 @@
+package gofpdf
+
+import (
+	"fmt"
+	"math"
+)
+
+// TextOnPath prints txtStr along the path through the points of path, such
+// as a curve approximated by short segments. The baseline of the text
+// follows the path from its first point, and each character is rotated to
+// the direction of the path at its middle. Characters that extend beyond the
+// end of the path continue in the direction of its last segment. The text is
+// printed with the current font, text color and character spacing; it is
+// not broken into lines.
+//
+// The TextOnPath example demonstrates this method.
+func (f *Fpdf) TextOnPath(path []PointType, txtStr string) {
+	if f.err != nil {
+		return
+	}
+	// Successive points that coincide have no direction
+	var points []PointType
+	for j, pt := range path {
+		if j == 0 || pt != path[j-1] {
+			points = append(points, pt)
+		}
+	}
+	if len(points) < 2 {
+		f.err = fmt.Errorf("a text path needs at least two distinct points")
+		return
+	}
+	// dist[j] is the length of the path up to points[j]
+	dist := make([]float64, len(points))
+	for j := 1; j < len(points); j++ {
+		dist[j] = dist[j-1] + math.Hypot(points[j].X-points[j-1].X, points[j].Y-points[j-1].Y)
+	}
+	f.textAlong(txtStr, func(s float64) (x, y, angle float64) {
+		j := 1
+		for j < len(points)-1 && dist[j] < s {
+			j++
+		}
+		p0, p1 := points[j-1], points[j]
+		t := (s - dist[j-1]) / (dist[j] - dist[j-1])
+		x, y = p0.X+t*(p1.X-p0.X), p0.Y+t*(p1.Y-p0.Y)
+		angle = math.Atan2(p0.Y-p1.Y, p1.X-p0.X) * 180 / math.Pi
+		return
+	})
+}
+
+// TextOnArc prints txtStr along the circle with center (x, y) and radius r,
+// starting at the angle degStart, which is specified in degrees and measured
+// counter-clockwise from the 3 o'clock position. If clockwise is true, the
+// text runs clockwise with the tops of the characters outside the circle, as
+// on the upper half of a seal; otherwise it runs counter-clockwise with the
+// tops of the characters inside the circle, as on the lower half. The text
+// covers an angle of GetStringWidth(txtStr)/r radians, which can be used to
+// center it.
+//
+// The TextOnPath example demonstrates this method.
+func (f *Fpdf) TextOnArc(x, y, r, degStart float64, clockwise bool, txtStr string) {
+	if f.err != nil {
+		return
+	}
+	if r <= 0 {
+		f.err = fmt.Errorf("invalid radius %.2f of text arc", r)
+		return
+	}
+	dir := 1.0
+	if clockwise {
+		dir = -1
+	}
+	start := degStart * math.Pi / 180
+	f.textAlong(txtStr, func(s float64) (px, py, angle float64) {
+		a := start + dir*s/r
+		return x + r*math.Cos(a), y - r*math.Sin(a), (a + dir*math.Pi/2) * 180 / math.Pi
+	})
+}
+
+// textAlong prints the characters of txtStr one by one along a line. For a
+// distance s along the line, point returns the position on it and its
+// direction, in degrees measured counter-clockwise from the 3 o'clock
+// position.
+func (f *Fpdf) textAlong(txtStr string, point func(s float64) (x, y, angle float64)) {
+	if f.currentFont.Name == "" {
+		f.err = fmt.Errorf("font has not been set; unable to render text")
+		return
+	}
+	if f.isCurrentUTF8 {
+		// The text is reordered and shaped as a whole before it is split
+		// into characters
+		txtStr = f.visualText(txtStr)
+		isRTL := f.isRTL
+		f.isRTL = false
+		defer func() { f.isRTL = isRTL }()
+	}
+	s := 0.0
+	for _, c := range f.textRunes(txtStr) {
+		str := f.lineText([]rune{c})
+		w := f.GetStringWidth(str)
+		x, y, _ := point(s)
+		_, _, angle := point(s + w/2)
+		f.TransformBegin()
+		f.TransformRotate(angle, x, y)
+		f.Text(x, y, str)
+		f.TransformEnd()
+		s += w
+	}
+}
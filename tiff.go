@@ -0,0 +1,186 @@
+package gofpdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+	"math/bits"
+)
+
+// TIFF tags read by parsetiff()
+const (
+	tiffImageWidth      = 256
+	tiffImageLength     = 257
+	tiffBitsPerSample   = 258
+	tiffCompression     = 259
+	tiffPhotometric     = 262
+	tiffFillOrder       = 266
+	tiffStripOffsets    = 273
+	tiffSamplesPerPixel = 277
+	tiffStripByteCounts = 279
+	tiffXResolution     = 282
+	tiffT4Options       = 292
+	tiffResolutionUnit  = 296
+)
+
+// tiffIFD holds the values of the tags of the first image file directory of a
+// TIFF image; rational values are held as pairs of numerator and denominator
+type tiffIFD map[uint16][]uint32
+
+// value returns the first value of tag, or def if the tag is not set
+func (ifd tiffIFD) value(tag uint16, def uint32) uint32 {
+	if v := ifd[tag]; len(v) > 0 {
+		return v[0]
+	}
+	return def
+}
+
+// parseTIFFIFD returns the tags of the first image of the TIFF data
+func parseTIFFIFD(data []byte) (ifd tiffIFD, err error) {
+	var order binary.ByteOrder
+	switch {
+	case len(data) < 8:
+	case data[0] == 'I' && data[1] == 'I':
+		order = binary.LittleEndian
+	case data[0] == 'M' && data[1] == 'M':
+		order = binary.BigEndian
+	}
+	if order == nil || order.Uint16(data[2:]) != 42 {
+		return nil, fmt.Errorf("image is not a TIFF image")
+	}
+	pos := int(order.Uint32(data[4:]))
+	if pos < 8 || pos+2 > len(data) {
+		return nil, fmt.Errorf("TIFF image has no image file directory")
+	}
+	count := int(order.Uint16(data[pos:]))
+	pos += 2
+	if pos+count*12 > len(data) {
+		return nil, fmt.Errorf("TIFF image file directory is truncated")
+	}
+	ifd = make(tiffIFD)
+	for j := 0; j < count; j++ {
+		entry := data[pos+j*12:]
+		tag, tp, n := order.Uint16(entry), order.Uint16(entry[2:]), int(order.Uint32(entry[4:]))
+		var size int
+		switch tp {
+		case 3: // SHORT
+			size = 2
+		case 4: // LONG
+			size = 4
+		case 5: // RATIONAL
+			size, n = 4, n*2
+		default:
+			continue
+		}
+		values := entry[8:12]
+		if size*n > 4 {
+			offset := int(order.Uint32(values))
+			if n > len(data) || offset < 0 || offset+size*n > len(data) {
+				return nil, fmt.Errorf("TIFF image tag %d is truncated", tag)
+			}
+			values = data[offset:]
+		}
+		list := make([]uint32, n)
+		for k := range list {
+			if size == 2 {
+				list[k] = uint32(order.Uint16(values[k*2:]))
+			} else {
+				list[k] = order.Uint32(values[k*4:])
+			}
+		}
+		ifd[tag] = list
+	}
+	return
+}
+
+// parsetiff extracts info from TIFF data. Bilevel images compressed with
+// CCITT Group 3 or Group 4 compression in a single strip are embedded as
+// they are; other images are decoded with the TIFF decoder registered with
+// the image package.
+func (f *Fpdf) parsetiff(r io.Reader, readdpi bool) (info *ImageInfoType) {
+	buf, err := bufferFromReader(r)
+	if err != nil {
+		f.err = err
+		return
+	}
+	data := buf.Bytes()
+	ifd, err := parseTIFFIFD(data)
+	if err != nil {
+		f.err = err
+		return
+	}
+	if info = f.parseccitt(data, ifd); info == nil {
+		img, _, err := image.Decode(bytes.NewReader(data))
+		switch {
+		case err == image.ErrFormat:
+			f.err = fmt.Errorf("TIFF image that is not a CCITT compressed bilevel image in a single strip " +
+				"needs a TIFF decoder, such as golang.org/x/image/tiff")
+		case err != nil:
+			f.err = err
+		default:
+			info = f.parseimage(img, "", 0)
+		}
+		if f.err != nil {
+			return
+		}
+	}
+	if readdpi {
+		if res := ifd[tiffXResolution]; len(res) == 2 && res[1] > 0 && res[0] > 0 {
+			switch ifd.value(tiffResolutionUnit, 2) {
+			case 2: // inch
+				info.dpi = float64(res[0]) / float64(res[1])
+			case 3: // centimeter
+				info.dpi = float64(res[0]) / float64(res[1]) * 2.54
+			}
+		}
+	}
+	return
+}
+
+// parseccitt returns the info of the TIFF data with the tags ifd if it is a
+// CCITT compressed bilevel image in a single strip, or nil otherwise
+func (f *Fpdf) parseccitt(data []byte, ifd tiffIFD) (info *ImageInfoType) {
+	compression := ifd.value(tiffCompression, 1)
+	photometric := ifd.value(tiffPhotometric, 0)
+	offsets, counts := ifd[tiffStripOffsets], ifd[tiffStripByteCounts]
+	if compression < 2 || compression > 4 || photometric > 1 ||
+		ifd.value(tiffBitsPerSample, 1) != 1 || ifd.value(tiffSamplesPerPixel, 1) != 1 ||
+		len(offsets) != 1 || len(counts) != 1 || uint64(offsets[0])+uint64(counts[0]) > uint64(len(data)) {
+		return nil
+	}
+	w, h := ifd.value(tiffImageWidth, 0), ifd.value(tiffImageLength, 0)
+	if w == 0 || h == 0 {
+		return nil
+	}
+	info = f.newImageInfo()
+	info.w, info.h = float64(w), float64(h)
+	info.cs, info.bpc, info.f = "DeviceGray", 1, "CCITTFaxDecode"
+	var k int
+	switch {
+	case compression == 4: // Group 4
+		k = -1
+	case compression == 3 && ifd.value(tiffT4Options, 0)&1 != 0: // Group 3, 2-D
+		k = 4
+	}
+	var dp fmtBuffer
+	dp.printf("/K %d /Columns %d /Rows %d", k, w, h)
+	if compression == 2 || (compression == 3 && ifd.value(tiffT4Options, 0)&4 != 0) {
+		// Rows begin on byte boundaries
+		dp.printf(" /EncodedByteAlign true")
+	}
+	if photometric == 1 {
+		// The runs coded as white are black
+		dp.printf(" /BlackIs1 true")
+	}
+	info.dp = dp.String()
+	info.data = append([]byte(nil), data[offsets[0]:offsets[0]+counts[0]]...)
+	if ifd.value(tiffFillOrder, 1) == 2 {
+		// The bits of each byte are stored from the least significant bit
+		for j, b := range info.data {
+			info.data[j] = bits.Reverse8(b)
+		}
+	}
+	return
+}
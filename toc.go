@@ -0,0 +1,201 @@
+package gofpdf
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// tocEntryType is an entry of the table of contents
+type tocEntryType struct {
+	level int
+	title string
+	link  int // internal link to the position of the entry
+}
+
+// TOCEntry adds an entry with the title titleStr to the table of contents
+// printed by RenderTOC(). The entry refers to the current position on the
+// current page. level specifies the level of the entry; 0 is the top level,
+// and entries of each level below it are indented further.
+//
+// The RenderTOC example demonstrates this method.
+func (f *Fpdf) TOCEntry(level int, titleStr string) {
+	if f.err != nil {
+		return
+	}
+	if level < 0 {
+		f.err = fmt.Errorf("invalid table of contents level %d", level)
+		return
+	}
+	if f.page == 0 {
+		f.err = fmt.Errorf("a page must be added before a table of contents entry")
+		return
+	}
+	link := f.AddLink()
+	f.SetLink(link, -1, -1)
+	f.tocEntries = append(f.tocEntries, tocEntryType{level: level, title: titleStr, link: link})
+}
+
+// RenderTOC prints the entries added with TOCEntry() from the current
+// position in the current font, and then moves the pages it has printed them
+// on, starting with the current page, to position pageNumber in the document.
+// The page that was at that position and the pages after it follow the table
+// of contents. RenderTOC() is usually called once the rest of the document is
+// complete, after AddPage() and possibly a heading. The current page must be
+// the last page of the document, and it is again the last page afterwards.
+//
+// Each entry is printed with its title, a dotted leader and the number of its
+// page, which takes the moved pages into account, and links to its position.
+// Page numbers that have already been printed on pages, for example in page
+// footers, remain as they were printed.
+//
+// The RenderTOC example demonstrates this method.
+func (f *Fpdf) RenderTOC(pageNumber int) {
+	if f.err != nil {
+		return
+	}
+	if f.page == 0 || f.page != f.PageCount() {
+		f.err = fmt.Errorf("RenderTOC() must be called on the last page")
+		return
+	}
+	if pageNumber < 1 || pageNumber > f.page {
+		f.err = fmt.Errorf("invalid page number %d for the table of contents", pageNumber)
+		return
+	}
+	if f.currentFont.Name == "" {
+		f.err = fmt.Errorf("font has not been set; unable to render text")
+		return
+	}
+	// The page numbers depend on the number of pages of the table of
+	// contents, which is known once it has been printed. It is printed again
+	// if the first guess was wrong.
+	first, offset, links := f.page, f.pages[f.page].Len(), len(f.pageLinks[f.page])
+	mcids := f.structTree.pageMCIDs[f.page]
+	x, y := f.x, f.y
+	family, style, size := f.fontFamily, f.currentStyle(), f.fontSizePt
+	n := 1
+	for pass := 0; pass < 3; pass++ {
+		f.putTOC(func(page int) int {
+			switch {
+			case page >= first:
+				return page - first + pageNumber
+			case page >= pageNumber:
+				return page + n
+			}
+			return page
+		})
+		if f.err != nil || f.page-first+1 == n || pass == 2 {
+			break
+		}
+		n = f.page - first + 1
+		f.truncatePages(first, offset, links, mcids)
+		f.x, f.y = x, y
+		f.fontFamily = ""
+		f.SetFont(family, style, size)
+	}
+	if f.err != nil || pageNumber == first {
+		return
+	}
+	// The last page of the table of contents is completed before the pages
+	// are moved, and the last page of the document becomes the current page,
+	// which already has its footer
+	f.putFootnotes()
+	f.inFooter = true
+	if f.footerFnc != nil {
+		f.footerFnc()
+	} else if f.footerFncLpi != nil {
+		f.footerFncLpi(false)
+	}
+	f.inFooter = false
+	f.endpage()
+	f.movePages(first, pageNumber)
+	f.page = f.PageCount()
+	f.pageClosed = true
+}
+
+// putTOC prints the entries of the table of contents with the page numbers
+// returned by pageNo
+func (f *Fpdf) putTOC(pageNo func(page int) int) {
+	lh := 1.5 * f.fontSize
+	indent := 2 * f.fontSize
+	right := f.w - f.rMargin
+	dotW := f.GetStringWidth(".")
+	// The width of the widest page number and a few dots is reserved next
+	// to the titles
+	numStrs := make([]string, len(f.tocEntries))
+	numW := 0.0
+	for j, entry := range f.tocEntries {
+		numStrs[j] = strconv.Itoa(pageNo(f.links[entry.link].page))
+		numW = math.Max(numW, f.GetStringWidth(numStrs[j]))
+	}
+	numW += 2*f.cMargin + 3*dotW
+	for j, entry := range f.tocEntries {
+		if f.err != nil {
+			return
+		}
+		left := f.lMargin + float64(entry.level)*indent
+		numStr := numStrs[j]
+		lines := f.SplitText(entry.title, right-left-numW)
+		if len(lines) == 0 {
+			lines = []string{""}
+		}
+		f.x = left
+		for _, line := range lines[:len(lines)-1] {
+			f.CellFormat(right-left-numW, lh, line, "", 2, "L", false, entry.link, "")
+		}
+		last := lines[len(lines)-1]
+		f.CellFormat(f.GetStringWidth(last)+2*f.cMargin, lh, last, "", 0, "L", false, entry.link, "")
+		// Dots are aligned on a grid so that they line up from entry to entry
+		start := f.lMargin + math.Ceil((f.x-f.lMargin)/dotW)*dotW
+		end := right - f.GetStringWidth(numStr) - 2*f.cMargin
+		if dots := int((end - start) / dotW); dots > 0 {
+			f.x = start
+			f.CellFormat(float64(dots)*dotW, lh, strings.Repeat(".", dots), "", 0, "", false, entry.link, "")
+		}
+		f.x = end
+		f.CellFormat(right-end, lh, numStr, "", 1, "R", false, entry.link, "")
+	}
+}
+
+// truncatePages removes the pages after page, and the content, links and
+// marked-content sequences added to page after offset, links and mcids
+func (f *Fpdf) truncatePages(page, offset, links, mcids int) {
+	for p := page + 1; p < len(f.pages); p++ {
+		delete(f.pageSizes, p)
+		delete(f.pageRotations, p)
+		delete(f.autoPageHeights, p)
+		delete(f.pageSections, p)
+		delete(f.pageBoxes, p)
+		delete(f.importedAnnots, p)
+		delete(f.pageMarkups, p)
+		if f.outStream != nil {
+			delete(f.outStream.contents, p)
+		}
+	}
+	f.pages = f.pages[:page+1]
+	f.pageLinks = f.pageLinks[:page+1]
+	f.pageAttachments = f.pageAttachments[:page+1]
+	f.pages[page].Truncate(offset)
+	f.pageLinks[page] = f.pageLinks[page][:links]
+	f.structTruncate(page, mcids)
+	f.page = page
+}
+
+// movePages moves the pages from page first to the last page to position
+// to, and updates the references to pages
+func (f *Fpdf) movePages(first, to int) {
+	last := len(f.pages) - 1
+	if to >= first {
+		return
+	}
+	f.remapPages(func(page int) int {
+		switch {
+		case page >= first:
+			return page - first + to
+		case page >= to:
+			return page + last - first + 1
+		}
+		return page
+	})
+}
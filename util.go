@@ -81,10 +81,11 @@ func slicesEqual(a, b []float64) bool {
 	return true
 }
 
-// sliceCompress returns a zlib-compressed copy of the specified byte array
-func sliceCompress(data []byte) []byte {
+// sliceCompressLevel returns a copy of the specified byte array compressed
+// with zlib at level
+func sliceCompressLevel(data []byte, level int) []byte {
 	var buf bytes.Buffer
-	cmp, _ := zlib.NewWriterLevel(&buf, zlib.BestSpeed)
+	cmp, _ := zlib.NewWriterLevel(&buf, level)
 	cmp.Write(data)
 	cmp.Close()
 	return buf.Bytes()
@@ -105,6 +106,22 @@ func sliceUncompress(data []byte) (outData []byte, err error) {
 	return
 }
 
+// pdfName returns s as a PDF name object, escaping characters that are not
+// permitted in names, for example "text/xml" becomes "/text#2Fxml"
+func pdfName(s string) string {
+	var buf bytes.Buffer
+	buf.WriteByte('/')
+	for j := 0; j < len(s); j++ {
+		c := s[j]
+		if c < '!' || c > '~' || strings.IndexByte("#()<>[]{}/%", c) >= 0 {
+			fmt.Fprintf(&buf, "#%02X", c)
+		} else {
+			buf.WriteByte(c)
+		}
+	}
+	return buf.String()
+}
+
 // utf8toutf16 converts UTF-8 to UTF-16BE; from http://www.fpdf.org/
 func utf8toutf16(s string, withBOM ...bool) string {
 	bom := true
@@ -0,0 +1,996 @@
+package gofpdf
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// A variable TrueType font contains a default design and the variations of
+// its outlines along design axes such as weight and width. PDF viewers do not
+// apply variations, so the outlines and advance widths of an instance are
+// computed from the deltas of the gvar table and the instance is embedded as
+// a static font.
+
+// variationTables lists the tables of variable fonts that do not apply to a
+// static instance
+var variationTables = []string{"fvar", "gvar", "avar", "cvar", "HVAR", "VVAR", "MVAR", "STAT"}
+
+// Flags of the glyph variation data of the gvar table
+const (
+	tupleEmbeddedPeak  = 0x8000
+	tupleIntermediate  = 0x4000
+	tuplePrivatePoints = 0x2000
+	tupleIndexMask     = 0x0fff
+	tupleSharedPoints  = 0x8000
+	tupleCountMask     = 0x0fff
+	pointsAreWords     = 0x80
+	pointRunCountMask  = 0x7f
+	deltasAreZero      = 0x80
+	deltasAreWords     = 0x40
+	deltaRunCountMask  = 0x3f
+)
+
+// Flags of the points of simple glyphs
+const (
+	glyphOnCurve       = 1 << 0
+	glyphXShort        = 1 << 1
+	glyphYShort        = 1 << 2
+	glyphRepeat        = 1 << 3
+	glyphXSameOrPos    = 1 << 4
+	glyphYSameOrPos    = 1 << 5
+	glyphOverlapSimple = 1 << 6
+)
+
+const (
+	glyphPhantomPoints = 4       // points that follow the outline of a glyph in gvar
+	f2dot14            = 1 << 14 // one in 2.14 fixed-point numbers
+	fixedOne           = 1 << 16 // one in 16.16 fixed-point numbers
+)
+
+// widthClasses lists the widths, as a percentage of the normal width, of the
+// width classes of the OS/2 table
+var widthClasses = []float64{50, 62.5, 75, 87.5, 100, 112.5, 125, 150, 200}
+
+// AddUTF8FontVariation imports an instance of a variable TrueType font and
+// makes it available in the same way as AddUTF8Font(). A variable font
+// contains a range of designs along one or more axes, so a single file can
+// provide, for example, the regular and bold styles of a family.
+//
+// axes specifies the position of the instance on the axes of the font, keyed
+// by axis tag: "wght" for the weight (usually 100 to 900), "wdth" for the
+// width as a percentage of the normal width, "slnt" for the slant in degrees,
+// "ital" for italic and "opsz" for the optical size, or any other tag that
+// the font defines. Values are clamped to the range of their axis, and axes
+// that are not specified keep their default values. The variations of the
+// glyph outlines and advance widths are applied; those of the hinting
+// programs and of font-wide metrics are not.
+//
+// fileStr specifies the name of the font file, which is loaded from the font
+// directory specified in the call to New() or SetFontLocation(). An error is
+// set if the file is not a variable TrueType font, or if axes contains a tag
+// that the font does not define.
+func (f *Fpdf) AddUTF8FontVariation(familyStr, styleStr, fileStr string, axes map[string]float64) {
+	if f.err != nil {
+		return
+	}
+	familyStr = fontFamilyEscape(familyStr)
+	if _, ok := f.fonts[getFontKey(familyStr, styleStr)]; ok {
+		return
+	}
+	data, err := f.loadFontFile(fileStr)
+	if err != nil {
+		f.err = err
+		return
+	}
+	data, err = instanceFont(data, axes)
+	if err != nil {
+		f.err = fmt.Errorf("unable to instance font %s: %s", fileStr, err)
+		return
+	}
+	f.addFontFromBytes(familyStr, styleStr, nil, nil, data)
+}
+
+// fontTables returns the tables of a TrueType font program
+func fontTables(data []byte) (map[string][]byte, error) {
+	if len(data) < 12 {
+		return nil, fmt.Errorf("invalid font file")
+	}
+	switch binary.BigEndian.Uint32(data) {
+	case 0x00010000, 0x74727565:
+	case 0x4f54544f:
+		return nil, fmt.Errorf("variable fonts with PostScript outlines are not supported")
+	default:
+		return nil, fmt.Errorf("not a TrueType font")
+	}
+	numTables := int(binary.BigEndian.Uint16(data[4:]))
+	if len(data) < 12+16*numTables {
+		return nil, fmt.Errorf("invalid font table directory")
+	}
+	tables := make(map[string][]byte, numTables)
+	for j := 0; j < numTables; j++ {
+		rec := data[12+16*j:]
+		pos, size := int(binary.BigEndian.Uint32(rec[8:])), int(binary.BigEndian.Uint32(rec[12:]))
+		if pos < 0 || size < 0 || pos+size > len(data) {
+			return nil, fmt.Errorf("font table %s out of range", rec[:4])
+		}
+		tables[string(rec[:4])] = data[pos : pos+size]
+	}
+	return tables, nil
+}
+
+// fontAxisType is an axis of a variable font
+type fontAxisType struct {
+	tag           string
+	min, def, max float64
+}
+
+// fontAxes returns the axes defined in the fvar table of a variable font
+func fontAxes(fvar []byte) ([]fontAxisType, error) {
+	if len(fvar) < 16 {
+		return nil, fmt.Errorf("invalid fvar table")
+	}
+	pos := int(binary.BigEndian.Uint16(fvar[4:]))
+	count, size := int(binary.BigEndian.Uint16(fvar[8:])), int(binary.BigEndian.Uint16(fvar[10:]))
+	if size < 20 || pos+count*size > len(fvar) {
+		return nil, fmt.Errorf("invalid fvar table")
+	}
+	fixed := func(p int) float64 {
+		return float64(int32(binary.BigEndian.Uint32(fvar[p:]))) / fixedOne
+	}
+	axes := make([]fontAxisType, count)
+	for j := range axes {
+		p := pos + j*size
+		axes[j] = fontAxisType{tag: string(fvar[p : p+4]), min: fixed(p + 4), def: fixed(p + 8), max: fixed(p + 12)}
+	}
+	return axes, nil
+}
+
+// normalizedCoords returns the normalized coordinates, from -1 to 1, of the
+// instance of a variable font at the user values of axes
+func normalizedCoords(tables map[string][]byte, axes map[string]float64) ([]float64, error) {
+	fontAxes, err := fontAxes(tables["fvar"])
+	if err != nil {
+		return nil, err
+	}
+	for tag := range axes {
+		found := false
+		for _, axis := range fontAxes {
+			found = found || axis.tag == tag
+		}
+		if !found {
+			return nil, fmt.Errorf("font has no %q axis", tag)
+		}
+	}
+	coords := make([]float64, len(fontAxes))
+	for j, axis := range fontAxes {
+		v, ok := axes[axis.tag]
+		if !ok {
+			continue
+		}
+		v = math.Max(axis.min, math.Min(axis.max, v))
+		switch {
+		case v < axis.def:
+			coords[j] = (v - axis.def) / (axis.def - axis.min)
+		case v > axis.def:
+			coords[j] = (v - axis.def) / (axis.max - axis.def)
+		}
+	}
+	// The avar table maps the normalized coordinates of each axis piecewise
+	// linearly
+	if avar := tables["avar"]; len(avar) >= 8 && int(binary.BigEndian.Uint16(avar[6:])) == len(coords) {
+		pos := 8
+		for j := range coords {
+			if pos+2 > len(avar) {
+				return nil, fmt.Errorf("invalid avar table")
+			}
+			count := int(binary.BigEndian.Uint16(avar[pos:]))
+			pos += 2
+			if pos+4*count > len(avar) {
+				return nil, fmt.Errorf("invalid avar table")
+			}
+			f2 := func(p int) float64 {
+				return float64(int16(binary.BigEndian.Uint16(avar[p:]))) / f2dot14
+			}
+			for k := 1; k < count; k++ {
+				from0, to0 := f2(pos+4*k-4), f2(pos+4*k-2)
+				from1, to1 := f2(pos+4*k), f2(pos+4*k+2)
+				if coords[j] <= from1 {
+					if from1 > from0 {
+						coords[j] = to0 + (coords[j]-from0)*(to1-to0)/(from1-from0)
+					} else {
+						coords[j] = to1
+					}
+					break
+				}
+			}
+			pos += 4 * count
+		}
+	}
+	// Coordinates have the precision of F2DOT14 values
+	for j, c := range coords {
+		coords[j] = math.Round(c*f2dot14) / f2dot14
+	}
+	return coords, nil
+}
+
+// instanceFont returns a static TrueType font program with the outlines and
+// metrics of the instance of a variable font at the user values of axes
+func instanceFont(data []byte, axes map[string]float64) ([]byte, error) {
+	tables, err := fontTables(data)
+	if err != nil {
+		return nil, err
+	}
+	if tables["fvar"] == nil {
+		return nil, fmt.Errorf("not a variable font")
+	}
+	coords, err := normalizedCoords(tables, axes)
+	if err != nil {
+		return nil, err
+	}
+	if tables["gvar"] != nil {
+		if err = instanceGlyphs(tables, coords); err != nil {
+			return nil, err
+		}
+	}
+	fontAxes, _ := fontAxes(tables["fvar"])
+	for _, axis := range fontAxes {
+		v, ok := axes[axis.tag]
+		if !ok {
+			continue
+		}
+		v = math.Max(axis.min, math.Min(axis.max, v))
+		switch os2, post := tables["OS/2"], tables["post"]; {
+		case axis.tag == "wght" && len(os2) >= 8:
+			os2 = append([]byte(nil), os2...)
+			binary.BigEndian.PutUint16(os2[4:], uint16(math.Max(1, math.Min(1000, math.Round(v)))))
+			tables["OS/2"] = os2
+		case axis.tag == "wdth" && len(os2) >= 8:
+			class := 0
+			for j, w := range widthClasses {
+				if math.Abs(w-v) < math.Abs(widthClasses[class]-v) {
+					class = j
+				}
+			}
+			os2 = append([]byte(nil), os2...)
+			binary.BigEndian.PutUint16(os2[6:], uint16(class+1))
+			tables["OS/2"] = os2
+		case axis.tag == "slnt" && len(post) >= 8:
+			post = append([]byte(nil), post...)
+			binary.BigEndian.PutUint32(post[4:], uint32(int32(math.Round(v*fixedOne))))
+			tables["post"] = post
+		}
+	}
+	for _, name := range variationTables {
+		delete(tables, name)
+	}
+	if head := tables["head"]; len(head) >= 12 {
+		head = append([]byte(nil), head...)
+		binary.BigEndian.PutUint32(head[8:], 0)
+		tables["head"] = head
+	}
+	return assembleFont(tables), nil
+}
+
+// varGlyphType is a glyph whose outline is being varied. The points of a
+// simple glyph are its outline points; those of a composite glyph are the
+// offsets of its components. Both are followed by the four phantom points,
+// the first two of which give the horizontal metrics. Glyphs that are not
+// varied, and whose components are not, keep their original data.
+type varGlyphType struct {
+	data      []byte
+	endPts    []int
+	flags     []byte
+	xs, ys    []float64
+	instrs    []byte
+	compPos   []int // position of each component within data
+	composite bool
+	varied    bool
+}
+
+// decodeGlyph returns the glyph with outline data, with the phantom points
+// of its advance width and left side bearing
+func decodeGlyph(data []byte, advance, lsb int) (g varGlyphType, err error) {
+	g.data = data
+	xMin := 0
+	if len(data) >= 10 {
+		xMin = int(int16(binary.BigEndian.Uint16(data[2:])))
+	}
+	errTrunc := fmt.Errorf("truncated glyph")
+	switch {
+	case len(data) < 10:
+	case int16(binary.BigEndian.Uint16(data)) < 0:
+		g.composite = true
+		flags := glyphMore
+		for pos := 10; flags&glyphMore != 0; {
+			if pos+6 > len(data) {
+				return g, errTrunc
+			}
+			flags = int(binary.BigEndian.Uint16(data[pos:]))
+			g.compPos = append(g.compPos, pos)
+			var dx, dy int
+			if flags&glyphArgWords != 0 {
+				if pos+8 > len(data) {
+					return g, errTrunc
+				}
+				dx, dy = int(int16(binary.BigEndian.Uint16(data[pos+4:]))), int(int16(binary.BigEndian.Uint16(data[pos+6:])))
+				pos += 8
+			} else {
+				dx, dy = int(int8(data[pos+4])), int(int8(data[pos+5]))
+				pos += 6
+			}
+			if flags&glyphArgsAreXY == 0 {
+				dx, dy = 0, 0
+			}
+			g.xs = append(g.xs, float64(dx))
+			g.ys = append(g.ys, float64(dy))
+			switch {
+			case flags&glyphScale != 0:
+				pos += 2
+			case flags&glyphXYScale != 0:
+				pos += 4
+			case flags&glyphTwoByTwo != 0:
+				pos += 8
+			}
+			if pos > len(data) {
+				return g, errTrunc
+			}
+		}
+	default:
+		contours := int(binary.BigEndian.Uint16(data))
+		pos := 10 + 2*contours
+		if pos+2 > len(data) {
+			return g, errTrunc
+		}
+		for j := 0; j < contours; j++ {
+			g.endPts = append(g.endPts, int(binary.BigEndian.Uint16(data[10+2*j:])))
+		}
+		numPoints := g.endPts[contours-1] + 1
+		instrLen := int(binary.BigEndian.Uint16(data[pos:]))
+		pos += 2
+		if pos+instrLen > len(data) {
+			return g, errTrunc
+		}
+		g.instrs = data[pos : pos+instrLen]
+		pos += instrLen
+		for len(g.flags) < numPoints {
+			if pos >= len(data) {
+				return g, errTrunc
+			}
+			flag := data[pos]
+			pos++
+			g.flags = append(g.flags, flag)
+			if flag&glyphRepeat != 0 {
+				if pos >= len(data) {
+					return g, errTrunc
+				}
+				for n := int(data[pos]); n > 0 && len(g.flags) < numPoints; n-- {
+					g.flags = append(g.flags, flag)
+				}
+				pos++
+			}
+		}
+		readCoords := func(short, sameOrPos byte) ([]float64, error) {
+			coords := make([]float64, numPoints)
+			v := 0
+			for j, flag := range g.flags {
+				switch {
+				case flag&short != 0:
+					if pos >= len(data) {
+						return nil, errTrunc
+					}
+					if flag&sameOrPos != 0 {
+						v += int(data[pos])
+					} else {
+						v -= int(data[pos])
+					}
+					pos++
+				case flag&sameOrPos == 0:
+					if pos+2 > len(data) {
+						return nil, errTrunc
+					}
+					v += int(int16(binary.BigEndian.Uint16(data[pos:])))
+					pos += 2
+				}
+				coords[j] = float64(v)
+			}
+			return coords, nil
+		}
+		if g.xs, err = readCoords(glyphXShort, glyphXSameOrPos); err != nil {
+			return
+		}
+		if g.ys, err = readCoords(glyphYShort, glyphYSameOrPos); err != nil {
+			return
+		}
+	}
+	left := float64(xMin - lsb)
+	g.xs = append(g.xs, left, left+float64(advance), 0, 0)
+	g.ys = append(g.ys, 0, 0, 0, 0)
+	return g, nil
+}
+
+// glyphVariationPoints returns the point numbers packed at position pos of
+// data, or nil if they refer to all points, and the position that follows
+func glyphVariationPoints(data []byte, pos int) (points []int, next int, err error) {
+	errTrunc := fmt.Errorf("truncated glyph variation data")
+	if pos >= len(data) {
+		return nil, 0, errTrunc
+	}
+	count := int(data[pos])
+	pos++
+	if count&pointsAreWords != 0 {
+		if pos >= len(data) {
+			return nil, 0, errTrunc
+		}
+		count = (count&pointRunCountMask)<<8 | int(data[pos])
+		pos++
+	}
+	last := 0
+	for len(points) < count {
+		if pos >= len(data) {
+			return nil, 0, errTrunc
+		}
+		control := data[pos]
+		pos++
+		for n := int(control&pointRunCountMask) + 1; n > 0; n-- {
+			if control&pointsAreWords != 0 {
+				if pos+2 > len(data) {
+					return nil, 0, errTrunc
+				}
+				last += int(binary.BigEndian.Uint16(data[pos:]))
+				pos += 2
+			} else {
+				if pos >= len(data) {
+					return nil, 0, errTrunc
+				}
+				last += int(data[pos])
+				pos++
+			}
+			points = append(points, last)
+		}
+	}
+	return points, pos, nil
+}
+
+// glyphVariationDeltas returns count deltas packed at position pos of data,
+// and the position that follows them
+func glyphVariationDeltas(data []byte, pos, count int) (deltas []float64, next int, err error) {
+	errTrunc := fmt.Errorf("truncated glyph variation data")
+	for len(deltas) < count {
+		if pos >= len(data) {
+			return nil, 0, errTrunc
+		}
+		control := data[pos]
+		pos++
+		for n := int(control&deltaRunCountMask) + 1; n > 0; n-- {
+			switch {
+			case control&deltasAreZero != 0:
+				deltas = append(deltas, 0)
+			case control&deltasAreWords != 0:
+				if pos+2 > len(data) {
+					return nil, 0, errTrunc
+				}
+				deltas = append(deltas, float64(int16(binary.BigEndian.Uint16(data[pos:]))))
+				pos += 2
+			default:
+				if pos >= len(data) {
+					return nil, 0, errTrunc
+				}
+				deltas = append(deltas, float64(int8(data[pos])))
+				pos++
+			}
+		}
+	}
+	return deltas[:count], pos, nil
+}
+
+// tupleScalar returns the factor by which the deltas of a tuple variation
+// with the specified peak and intermediate region are scaled at coords
+func tupleScalar(coords, peak, start, end []float64) float64 {
+	scalar := 1.0
+	for j, c := range coords {
+		p := peak[j]
+		if p == 0 || c == p {
+			continue
+		}
+		if start != nil {
+			if c <= start[j] || c >= end[j] {
+				return 0
+			}
+			if c < p {
+				scalar *= (c - start[j]) / (p - start[j])
+			} else {
+				scalar *= (end[j] - c) / (end[j] - p)
+			}
+			continue
+		}
+		if c == 0 || c < math.Min(0, p) || c > math.Max(0, p) {
+			return 0
+		}
+		scalar *= c / p
+	}
+	return scalar
+}
+
+// interpolateDelta returns the delta of an untouched point at coordinate c
+// between two touched points at c1 and c2 with deltas d1 and d2
+func interpolateDelta(c, c1, c2, d1, d2 float64) float64 {
+	if c1 == c2 {
+		if d1 == d2 {
+			return d1
+		}
+		return 0
+	}
+	if c1 > c2 {
+		c1, c2, d1, d2 = c2, c1, d2, d1
+	}
+	switch {
+	case c <= c1:
+		return d1
+	case c >= c2:
+		return d2
+	}
+	return d1 + (c-c1)*(d2-d1)/(c2-c1)
+}
+
+// inferDeltas sets the deltas of the outline points of a simple glyph that
+// are not touched by a tuple variation by interpolating those of the touched
+// points of the same contour
+func inferDeltas(g *varGlyphType, touched []bool, dx, dy []float64) {
+	start := 0
+	for _, end := range g.endPts {
+		var refs []int
+		for j := start; j <= end && j < len(touched); j++ {
+			if touched[j] {
+				refs = append(refs, j)
+			}
+		}
+		if len(refs) > 0 && len(refs) < end-start+1 {
+			for k, ref := range refs {
+				next := refs[(k+1)%len(refs)]
+				for j := ref + 1; ; j++ {
+					if j > end {
+						j = start
+					}
+					if j == next {
+						break
+					}
+					dx[j] = interpolateDelta(g.xs[j], g.xs[ref], g.xs[next], dx[ref], dx[next])
+					dy[j] = interpolateDelta(g.ys[j], g.ys[ref], g.ys[next], dy[ref], dy[next])
+				}
+			}
+		}
+		start = end + 1
+	}
+}
+
+// varyGlyph adds to the points of g the deltas of its glyph variation data
+// at coords, using the shared tuples of the gvar table
+func varyGlyph(g *varGlyphType, data []byte, coords []float64, shared [][]float64) error {
+	if len(data) < 4 {
+		return nil
+	}
+	numPoints := len(g.xs)
+	count := int(binary.BigEndian.Uint16(data))
+	dataPos := int(binary.BigEndian.Uint16(data[2:]))
+	var sharedPoints []int
+	var err error
+	if count&tupleSharedPoints != 0 {
+		if sharedPoints, dataPos, err = glyphVariationPoints(data, dataPos); err != nil {
+			return err
+		}
+	}
+	sumX, sumY := make([]float64, numPoints), make([]float64, numPoints)
+	pos := 4
+	f2 := func(p int) float64 {
+		return float64(int16(binary.BigEndian.Uint16(data[p:]))) / f2dot14
+	}
+	tuple := func(p int) []float64 {
+		t := make([]float64, len(coords))
+		for j := range t {
+			t[j] = f2(p + 2*j)
+		}
+		return t
+	}
+	for n := count & tupleCountMask; n > 0; n-- {
+		if pos+4 > len(data) {
+			return fmt.Errorf("truncated glyph variation data")
+		}
+		size := int(binary.BigEndian.Uint16(data[pos:]))
+		index := int(binary.BigEndian.Uint16(data[pos+2:]))
+		pos += 4
+		need := 0
+		if index&tupleEmbeddedPeak != 0 {
+			need += 2 * len(coords)
+		}
+		if index&tupleIntermediate != 0 {
+			need += 4 * len(coords)
+		}
+		if pos+need > len(data) {
+			return fmt.Errorf("truncated glyph variation data")
+		}
+		var peak, start, end []float64
+		if index&tupleEmbeddedPeak != 0 {
+			peak = tuple(pos)
+			pos += 2 * len(coords)
+		} else if index&tupleIndexMask < len(shared) {
+			peak = shared[index&tupleIndexMask]
+		} else {
+			return fmt.Errorf("invalid shared tuple index")
+		}
+		if index&tupleIntermediate != 0 {
+			start, end = tuple(pos), tuple(pos+2*len(coords))
+			pos += 4 * len(coords)
+		}
+		tupleData := dataPos
+		dataPos += size
+		scalar := tupleScalar(coords, peak, start, end)
+		if scalar == 0 {
+			continue
+		}
+		if dataPos > len(data) {
+			return fmt.Errorf("truncated glyph variation data")
+		}
+		points := sharedPoints
+		p := tupleData
+		if index&tuplePrivatePoints != 0 {
+			if points, p, err = glyphVariationPoints(data[:dataPos], p); err != nil {
+				return err
+			}
+		}
+		if points == nil {
+			points = make([]int, numPoints)
+			for j := range points {
+				points[j] = j
+			}
+		}
+		var dxs, dys []float64
+		if dxs, p, err = glyphVariationDeltas(data[:dataPos], p, len(points)); err != nil {
+			return err
+		}
+		if dys, _, err = glyphVariationDeltas(data[:dataPos], p, len(points)); err != nil {
+			return err
+		}
+		dx, dy := make([]float64, numPoints), make([]float64, numPoints)
+		touched := make([]bool, numPoints)
+		for j, pt := range points {
+			if pt < numPoints {
+				dx[pt], dy[pt] = dxs[j], dys[j]
+				touched[pt] = true
+			}
+		}
+		if !g.composite {
+			inferDeltas(g, touched, dx, dy)
+		}
+		for j := range sumX {
+			sumX[j] += scalar * dx[j]
+			sumY[j] += scalar * dy[j]
+		}
+	}
+	for j := range g.xs {
+		x, y := float64(round(g.xs[j]+sumX[j])), float64(round(g.ys[j]+sumY[j]))
+		g.varied = g.varied || x != g.xs[j] || y != g.ys[j]
+		g.xs[j], g.ys[j] = x, y
+	}
+	return nil
+}
+
+// box returns the bounding box of the outline points of g, or true if it
+// has none
+func (g *varGlyphType) box() (box fontBoxType, empty bool) {
+	n := len(g.xs) - glyphPhantomPoints
+	switch {
+	case len(g.data) < 10:
+		return box, true
+	case !g.varied:
+		return fontBoxType{int(int16(binary.BigEndian.Uint16(g.data[2:]))), int(int16(binary.BigEndian.Uint16(g.data[4:]))),
+			int(int16(binary.BigEndian.Uint16(g.data[6:]))), int(int16(binary.BigEndian.Uint16(g.data[8:])))}, false
+	case g.composite || n <= 0:
+		return box, true
+	}
+	box = fontBoxType{int(g.xs[0]), int(g.ys[0]), int(g.xs[0]), int(g.ys[0])}
+	for j := 1; j < n; j++ {
+		box.Xmin = min(box.Xmin, int(g.xs[j]))
+		box.Ymin = min(box.Ymin, int(g.ys[j]))
+		box.Xmax = max(box.Xmax, int(g.xs[j]))
+		box.Ymax = max(box.Ymax, int(g.ys[j]))
+	}
+	return box, false
+}
+
+// encode returns the outline data of the varied glyph g with bounding box
+// box
+func (g *varGlyphType) encode(box fontBoxType) []byte {
+	if len(g.data) < 10 || !g.varied {
+		return g.data
+	}
+	out := append([]byte(nil), g.data[:10]...)
+	for j, v := range []int{box.Xmin, box.Ymin, box.Xmax, box.Ymax} {
+		binary.BigEndian.PutUint16(out[2+2*j:], uint16(int16(v)))
+	}
+	if g.composite {
+		for j, pos := range g.compPos {
+			flags := int(binary.BigEndian.Uint16(g.data[pos:]))
+			end := len(g.data)
+			if j+1 < len(g.compPos) {
+				end = g.compPos[j+1]
+			}
+			argSize := 2
+			if flags&glyphArgWords != 0 {
+				argSize = 4
+			}
+			// The transformation, and the instructions of the composite
+			// glyph after its last component, are copied
+			transform := g.data[pos+4+argSize : end]
+			var args []byte
+			if flags&glyphArgsAreXY == 0 {
+				args = g.data[pos+4 : pos+4+argSize]
+			} else {
+				dx, dy := int(g.xs[j]), int(g.ys[j])
+				if dx < -128 || dx > 127 || dy < -128 || dy > 127 {
+					flags |= glyphArgWords
+				}
+				if flags&glyphArgWords != 0 {
+					args = []byte{byte(dx >> 8), byte(dx), byte(dy >> 8), byte(dy)}
+				} else {
+					args = []byte{byte(dx), byte(dy)}
+				}
+			}
+			out = append(out, byte(flags>>8), byte(flags))
+			out = append(out, g.data[pos+2:pos+4]...)
+			out = append(out, args...)
+			out = append(out, transform...)
+		}
+		return out
+	}
+	for _, end := range g.endPts {
+		out = append(out, byte(end>>8), byte(end))
+	}
+	out = append(out, byte(len(g.instrs)>>8), byte(len(g.instrs)))
+	out = append(out, g.instrs...)
+	n := len(g.xs) - glyphPhantomPoints
+	flags := make([]byte, n)
+	var xData, yData []byte
+	coord := func(d int, short, sameOrPos byte, flag *byte, buf []byte) []byte {
+		switch {
+		case d == 0:
+			*flag |= sameOrPos
+		case d >= -255 && d <= 255:
+			*flag |= short
+			if d > 0 {
+				*flag |= sameOrPos
+			} else {
+				d = -d
+			}
+			buf = append(buf, byte(d))
+		default:
+			buf = append(buf, byte(d>>8), byte(d))
+		}
+		return buf
+	}
+	x, y := 0, 0
+	for j := 0; j < n; j++ {
+		flags[j] = g.flags[j] & (glyphOnCurve | glyphOverlapSimple)
+		xData = coord(int(g.xs[j])-x, glyphXShort, glyphXSameOrPos, &flags[j], xData)
+		yData = coord(int(g.ys[j])-y, glyphYShort, glyphYSameOrPos, &flags[j], yData)
+		x, y = int(g.xs[j]), int(g.ys[j])
+	}
+	for j := 0; j < n; {
+		repeat := 0
+		for j+repeat+1 < n && flags[j+repeat+1] == flags[j] && repeat < 255 {
+			repeat++
+		}
+		if repeat > 0 {
+			out = append(out, flags[j]|glyphRepeat, byte(repeat))
+		} else {
+			out = append(out, flags[j])
+		}
+		j += repeat + 1
+	}
+	out = append(out, xData...)
+	return append(out, yData...)
+}
+
+// instanceGlyphs replaces the glyf, loca and hmtx tables with those of the
+// instance of a variable font at the normalized coordinates coords
+func instanceGlyphs(tables map[string][]byte, coords []float64) error {
+	head, hhea, maxp := tables["head"], tables["hhea"], tables["maxp"]
+	hmtx, loca, glyf, gvar := tables["hmtx"], tables["loca"], tables["glyf"], tables["gvar"]
+	if len(head) < 54 || len(hhea) < 36 || len(maxp) < 6 || len(gvar) < 20 {
+		return fmt.Errorf("invalid font header tables")
+	}
+	numGlyphs := int(binary.BigEndian.Uint16(maxp[4:]))
+	numMetrics := int(binary.BigEndian.Uint16(hhea[34:]))
+	longLoca := binary.BigEndian.Uint16(head[50:]) == 1
+	if numMetrics < 1 || numMetrics > numGlyphs || len(hmtx) < 4*numMetrics+2*(numGlyphs-numMetrics) ||
+		longLoca && len(loca) < 4*(numGlyphs+1) || !longLoca && len(loca) < 2*(numGlyphs+1) {
+		return fmt.Errorf("invalid font metrics or glyph locations")
+	}
+	if int(binary.BigEndian.Uint16(gvar[4:])) != len(coords) || int(binary.BigEndian.Uint16(gvar[12:])) != numGlyphs {
+		return fmt.Errorf("gvar table does not match the font")
+	}
+	sharedCount, sharedPos := int(binary.BigEndian.Uint16(gvar[6:])), int(binary.BigEndian.Uint32(gvar[8:]))
+	longOffsets := binary.BigEndian.Uint16(gvar[14:])&1 != 0
+	dataPos := int(binary.BigEndian.Uint32(gvar[16:]))
+	offsetSize := 2
+	if longOffsets {
+		offsetSize = 4
+	}
+	if sharedPos+2*len(coords)*sharedCount > len(gvar) || 20+offsetSize*(numGlyphs+1) > len(gvar) {
+		return fmt.Errorf("invalid gvar table")
+	}
+	shared := make([][]float64, sharedCount)
+	for j := range shared {
+		shared[j] = make([]float64, len(coords))
+		for k := range coords {
+			shared[j][k] = float64(int16(binary.BigEndian.Uint16(gvar[sharedPos+2*(j*len(coords)+k):]))) / f2dot14
+		}
+	}
+	varOffset := func(gid int) int {
+		if longOffsets {
+			return int(binary.BigEndian.Uint32(gvar[20+4*gid:]))
+		}
+		return 2 * int(binary.BigEndian.Uint16(gvar[20+2*gid:]))
+	}
+
+	glyphs := make([]varGlyphType, numGlyphs)
+	for gid := range glyphs {
+		var start, end int
+		if longLoca {
+			start, end = int(binary.BigEndian.Uint32(loca[4*gid:])), int(binary.BigEndian.Uint32(loca[4*gid+4:]))
+		} else {
+			start, end = 2*int(binary.BigEndian.Uint16(loca[2*gid:])), 2*int(binary.BigEndian.Uint16(loca[2*gid+2:]))
+		}
+		if start > end || end > len(glyf) {
+			return fmt.Errorf("invalid location of glyph %d", gid)
+		}
+		m := gid
+		if m >= numMetrics {
+			m = numMetrics - 1
+		}
+		advance := int(binary.BigEndian.Uint16(hmtx[4*m:]))
+		lsb := int(int16(binary.BigEndian.Uint16(hmtx[4*m+2:])))
+		if gid >= numMetrics {
+			lsb = int(int16(binary.BigEndian.Uint16(hmtx[4*numMetrics+2*(gid-numMetrics):])))
+		}
+		g, err := decodeGlyph(glyf[start:end], advance, lsb)
+		if err != nil {
+			return fmt.Errorf("glyph %d: %s", gid, err)
+		}
+		varStart, varEnd := dataPos+varOffset(gid), dataPos+varOffset(gid+1)
+		if varStart > varEnd || varEnd > len(gvar) {
+			return fmt.Errorf("invalid variation data of glyph %d", gid)
+		}
+		if err = varyGlyph(&g, gvar[varStart:varEnd], coords, shared); err != nil {
+			return fmt.Errorf("glyph %d: %s", gid, err)
+		}
+		glyphs[gid] = g
+	}
+
+	// The bounding box of a composite glyph is that of its transformed
+	// components
+	boxes := make([]*fontBoxType, numGlyphs)
+	var glyphBox func(gid, depth int) (fontBoxType, bool)
+	glyphBox = func(gid, depth int) (fontBoxType, bool) {
+		if boxes[gid] != nil {
+			return *boxes[gid], true
+		}
+		g := &glyphs[gid]
+		if g.composite && depth < 16 {
+			// A composite glyph is varied if any of its components is
+			var compBoxes []fontBoxType
+			for j, pos := range g.compPos {
+				comp := int(binary.BigEndian.Uint16(g.data[pos+2:]))
+				if comp >= numGlyphs {
+					continue
+				}
+				if cbox, ok := glyphBox(comp, depth+1); ok {
+					compBoxes = append(compBoxes, transformBox(cbox, g.data[pos:], g.xs[j], g.ys[j]))
+				}
+				g.varied = g.varied || glyphs[comp].varied
+			}
+			if g.varied {
+				if len(compBoxes) == 0 {
+					return fontBoxType{}, false
+				}
+				box := compBoxes[0]
+				for _, cbox := range compBoxes[1:] {
+					box = fontBoxType{min(box.Xmin, cbox.Xmin), min(box.Ymin, cbox.Ymin),
+						max(box.Xmax, cbox.Xmax), max(box.Ymax, cbox.Ymax)}
+				}
+				boxes[gid] = &box
+				return box, true
+			}
+		}
+		box, empty := g.box()
+		if !empty {
+			boxes[gid] = &box
+		}
+		return box, !empty
+	}
+
+	var newGlyf, newLoca, newHmtx []byte
+	var fontBox fontBoxType
+	fontEmpty := true
+	advanceMax, lsbMin, rsbMin, extentMax := 0, math.MaxInt32, math.MaxInt32, math.MinInt32
+	for gid := range glyphs {
+		g := &glyphs[gid]
+		box, ok := glyphBox(gid, 0)
+		n := len(g.xs)
+		left := g.xs[n-4]
+		advance := max(0, round(g.xs[n-3]-left))
+		lsb := 0
+		if ok {
+			lsb = box.Xmin - int(left)
+			lsbMin = min(lsbMin, lsb)
+			rsbMin = min(rsbMin, advance-lsb-(box.Xmax-box.Xmin))
+			extentMax = max(extentMax, lsb+box.Xmax-box.Xmin)
+			if fontEmpty {
+				fontBox, fontEmpty = box, false
+			} else {
+				fontBox = fontBoxType{min(fontBox.Xmin, box.Xmin), min(fontBox.Ymin, box.Ymin),
+					max(fontBox.Xmax, box.Xmax), max(fontBox.Ymax, box.Ymax)}
+			}
+		}
+		advanceMax = max(advanceMax, advance)
+		newLoca = append(newLoca, byte(len(newGlyf)>>24), byte(len(newGlyf)>>16), byte(len(newGlyf)>>8), byte(len(newGlyf)))
+		data := g.encode(box)
+		newGlyf = append(newGlyf, data...)
+		newGlyf = append(newGlyf, make([]byte, (4-len(data)%4)%4)...)
+		newHmtx = append(newHmtx, byte(advance>>8), byte(advance), byte(lsb>>8), byte(lsb))
+	}
+	newLoca = append(newLoca, byte(len(newGlyf)>>24), byte(len(newGlyf)>>16), byte(len(newGlyf)>>8), byte(len(newGlyf)))
+	tables["glyf"], tables["loca"], tables["hmtx"] = newGlyf, newLoca, newHmtx
+
+	head = append([]byte(nil), head...)
+	if !fontEmpty {
+		for j, v := range []int{fontBox.Xmin, fontBox.Ymin, fontBox.Xmax, fontBox.Ymax} {
+			binary.BigEndian.PutUint16(head[36+2*j:], uint16(int16(v)))
+		}
+	}
+	binary.BigEndian.PutUint16(head[50:], 1)
+	tables["head"] = head
+	hhea = append([]byte(nil), hhea...)
+	binary.BigEndian.PutUint16(hhea[10:], uint16(advanceMax))
+	if !fontEmpty {
+		binary.BigEndian.PutUint16(hhea[12:], uint16(int16(lsbMin)))
+		binary.BigEndian.PutUint16(hhea[14:], uint16(int16(rsbMin)))
+		binary.BigEndian.PutUint16(hhea[16:], uint16(int16(extentMax)))
+	}
+	binary.BigEndian.PutUint16(hhea[34:], uint16(numGlyphs))
+	tables["hhea"] = hhea
+	return nil
+}
+
+// transformBox returns the bounding box of a component of a composite glyph
+// with bounding box box, transformed as specified by the component record at
+// the start of comp and moved by dx and dy
+func transformBox(box fontBoxType, comp []byte, dx, dy float64) fontBoxType {
+	flags := int(binary.BigEndian.Uint16(comp))
+	pos := 6
+	if flags&glyphArgWords != 0 {
+		pos = 8
+	}
+	f2 := func(p int) float64 {
+		return float64(int16(binary.BigEndian.Uint16(comp[p:]))) / f2dot14
+	}
+	xx, xy, yx, yy := 1.0, 0.0, 0.0, 1.0
+	switch {
+	case flags&glyphScale != 0:
+		xx = f2(pos)
+		yy = xx
+	case flags&glyphXYScale != 0:
+		xx, yy = f2(pos), f2(pos+2)
+	case flags&glyphTwoByTwo != 0:
+		xx, xy, yx, yy = f2(pos), f2(pos+2), f2(pos+4), f2(pos+6)
+	}
+	out := fontBoxType{math.MaxInt32, math.MaxInt32, math.MinInt32, math.MinInt32}
+	for _, x := range []int{box.Xmin, box.Xmax} {
+		for _, y := range []int{box.Ymin, box.Ymax} {
+			tx := round(xx*float64(x) + yx*float64(y) + dx)
+			ty := round(xy*float64(x) + yy*float64(y) + dy)
+			out = fontBoxType{min(out.Xmin, tx), min(out.Ymin, ty), max(out.Xmax, tx), max(out.Ymax, ty)}
+		}
+	}
+	return out
+}
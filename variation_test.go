@@ -0,0 +1,156 @@
+package gofpdf
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testVariableFont returns DejaVuSansCondensed with a weight axis from 100 to
+// 900 and glyph variations that, at the heaviest weight, move the outline of
+// "I" right by 10 units and widen it by 20, and move the second component of
+// "é" right by 150 units. The outline of "I" is varied through its first
+// point, from which the other points of its contour are inferred.
+func testVariableFont(t *testing.T) (data []byte, glyphI, glyphE int) {
+	ttf, err := ioutil.ReadFile("font/DejaVuSansCondensed.ttf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tables, err := fontTables(ttf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	utf := newUTF8Font(&fileReader{array: ttf})
+	if err = utf.parseFile(); err != nil {
+		t.Fatal(err)
+	}
+	glyphI, glyphE = utf.runeGlyph('I'), utf.runeGlyph('é')
+	numGlyphs := int(binary.BigEndian.Uint16(tables["maxp"][4:]))
+	u16 := func(vals ...int) (b []byte) {
+		for _, v := range vals {
+			b = append(b, byte(v>>8), byte(v))
+		}
+		return
+	}
+	u32 := func(vals ...int) (b []byte) {
+		for _, v := range vals {
+			b = append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+		}
+		return
+	}
+	tables["fvar"] = append(append(u16(1, 0, 16, 2, 1, 20, 0, 8), []byte("wght")...),
+		append(u32(100<<16, 400<<16, 900<<16), u16(0, 256)...)...)
+
+	// Each glyph's data has one tuple variation, with a peak at the heaviest
+	// weight, and private point numbers
+	variation := func(points []int, dxs []int) []byte {
+		tuple := []byte{byte(len(points))}
+		for j, pt := range points {
+			if j == 0 {
+				tuple = append(tuple, byte(len(points)-1|0x80))
+				tuple = append(tuple, u16(pt)...)
+			} else {
+				tuple = append(tuple, u16(pt-points[j-1])...)
+			}
+		}
+		tuple = append(tuple, byte(len(dxs)-1|deltasAreWords))
+		tuple = append(tuple, u16(dxs...)...)
+		tuple = append(tuple, byte(len(dxs)-1|deltasAreZero))
+		return append(u16(1, 10, len(tuple), tupleEmbeddedPeak|tuplePrivatePoints, f2dot14), tuple...)
+	}
+	glyphData := map[int][]byte{
+		// Point 0 of the outline and phantom point 1 of the 8 points of "I"
+		glyphI: variation([]int{0, 5}, []int{10, 20}),
+		// Component 1 of "é"
+		glyphE: variation([]int{1}, []int{150}),
+	}
+	var offsets, varData []byte
+	for gid := 0; gid <= numGlyphs; gid++ {
+		offsets = append(offsets, u32(len(varData))...)
+		varData = append(varData, glyphData[gid]...)
+	}
+	header := u16(1, 0, 1, 0)
+	header = append(header, u32(20+len(offsets))...)
+	header = append(header, u16(numGlyphs, 1)...)
+	header = append(header, u32(20+len(offsets))...)
+	tables["gvar"] = append(append(header, offsets...), varData...)
+	return assembleFont(tables), glyphI, glyphE
+}
+
+// TestAddUTF8FontVariation checks that the outlines and advance widths of
+// instances of a variable font are varied
+func TestAddUTF8FontVariation(t *testing.T) {
+	data, glyphI, glyphE := testVariableFont(t)
+	for _, weight := range []float64{400, 650, 900} {
+		inst, err := instanceFont(data, map[string]float64{"wght": weight})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tables, err := fontTables(inst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tables["fvar"] != nil || tables["gvar"] != nil {
+			t.Fatalf("weight %v: variation tables found in instance", weight)
+		}
+		if got := int(binary.BigEndian.Uint16(tables["OS/2"][4:])); got != int(weight) {
+			t.Fatalf("weight %v: weight class is %d", weight, got)
+		}
+		glyph := func(gid int) []byte {
+			loca := tables["loca"]
+			return tables["glyf"][binary.BigEndian.Uint32(loca[4*gid:]):binary.BigEndian.Uint32(loca[4*gid+4:])]
+		}
+		scalar := (weight - 400) / 500
+		wantAdvance, wantXMax := 543+round(20*scalar), 363+round(10*scalar)
+		advance := int(binary.BigEndian.Uint16(tables["hmtx"][4*glyphI:]))
+		xMax := int(int16(binary.BigEndian.Uint16(glyph(glyphI)[6:])))
+		if advance != wantAdvance || xMax != wantXMax {
+			t.Fatalf("weight %v: advance and xMax of I are %d and %d, want %d and %d",
+				weight, advance, xMax, wantAdvance, wantXMax)
+		}
+		comp := glyph(glyphE)
+		positions, err := glyphComponents(comp)
+		if err != nil || len(positions) != 2 {
+			t.Fatalf("weight %v: components of é not found: %v", weight, err)
+		}
+		pos := positions[1] - 2
+		var dx int
+		if binary.BigEndian.Uint16(comp[pos:])&glyphArgWords != 0 {
+			dx = int(int16(binary.BigEndian.Uint16(comp[pos+4:])))
+		} else {
+			dx = int(int8(comp[pos+4]))
+		}
+		if want := 125 + round(150*scalar); dx != want {
+			t.Fatalf("weight %v: offset of accent of é is %d, want %d", weight, dx, want)
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "gofpdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err = ioutil.WriteFile(filepath.Join(dir, "var.ttf"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	pdf := New("P", "mm", "A4", dir)
+	pdf.AddUTF8FontVariation("var", "", "var.ttf", map[string]float64{"wght": 400})
+	pdf.AddUTF8FontVariation("var", "B", "var.ttf", map[string]float64{"wght": 900})
+	pdf.SetFont("var", "", 12)
+	regular := pdf.GetStringWidth("I")
+	pdf.SetFont("var", "B", 12)
+	if bold := pdf.GetStringWidth("I"); bold <= regular {
+		t.Fatalf("width of I is %v in bold and %v in regular", bold, regular)
+	}
+	pdf.AddPage()
+	pdf.Write(10, "Iéa")
+	if err = pdf.Output(ioutil.Discard); err != nil {
+		t.Fatal(err)
+	}
+	pdf.AddUTF8FontVariation("var", "I", "var.ttf", map[string]float64{"wdth": 75})
+	if pdf.Error() == nil {
+		t.Fatalf("no error for undefined axis")
+	}
+}
@@ -0,0 +1,140 @@
+package gofpdf
+
+import (
+	"fmt"
+)
+
+// ViewerPreferences specifies how the document reader presents the document
+// when it is opened and how it is printed by default. It is passed to
+// SetViewerPreferences().
+type ViewerPreferences struct {
+	// HideToolbar hides the tool bars of the reader.
+	HideToolbar bool
+	// HideMenubar hides the menu bar of the reader.
+	HideMenubar bool
+	// HideWindowUI hides the user interface elements of the document window,
+	// such as scroll bars, leaving only the content displayed.
+	HideWindowUI bool
+	// FitWindow resizes the document window to fit the size of the first
+	// page.
+	FitWindow bool
+	// CenterWindow positions the document window in the center of the screen.
+	CenterWindow bool
+	// DisplayDocTitle displays the title of the document, set with
+	// SetTitle(), in the title bar of the window rather than the file name.
+	DisplayDocTitle bool
+	// Duplex is the default paper handling of the print dialog: "Simplex",
+	// "DuplexFlipShortEdge" or "DuplexFlipLongEdge". If empty, the reader
+	// decides.
+	Duplex string
+	// PrintScaling is the default page scaling of the print dialog: "None"
+	// to print at the actual size, or "AppDefault". If empty, the reader
+	// decides.
+	PrintScaling string
+	// NumCopies is the default number of copies of the print dialog. If 0,
+	// the reader decides.
+	NumCopies int
+	// PrintPageRange holds pairs of first and last page numbers of the page
+	// ranges selected by default in the print dialog, for example
+	// []int{1, 1, 3, 5} for pages 1 and 3 to 5.
+	PrintPageRange []int
+}
+
+// SetViewerPreferences sets the preferences of the document reader, such as
+// the visibility of its tool bars and the defaults of its print dialog, for
+// example for kiosks or print shops. The PDF version of the document is
+// raised as required by the print dialog defaults.
+//
+// The SetViewerPreferences example demonstrates this method.
+func (f *Fpdf) SetViewerPreferences(prefs ViewerPreferences) {
+	if f.err != nil {
+		return
+	}
+	switch prefs.Duplex {
+	case "", "Simplex", "DuplexFlipShortEdge", "DuplexFlipLongEdge":
+	default:
+		f.err = fmt.Errorf("invalid duplex mode %q", prefs.Duplex)
+		return
+	}
+	switch prefs.PrintScaling {
+	case "", "None", "AppDefault":
+	default:
+		f.err = fmt.Errorf("invalid print scaling %q", prefs.PrintScaling)
+		return
+	}
+	if prefs.NumCopies < 0 {
+		f.err = fmt.Errorf("invalid number of copies %d", prefs.NumCopies)
+		return
+	}
+	if len(prefs.PrintPageRange)%2 != 0 {
+		f.err = fmt.Errorf("the print page range needs pairs of page numbers")
+		return
+	}
+	for j := 0; j < len(prefs.PrintPageRange); j += 2 {
+		first, last := prefs.PrintPageRange[j], prefs.PrintPageRange[j+1]
+		if first < 1 || last < first {
+			f.err = fmt.Errorf("invalid print page range %d to %d", first, last)
+			return
+		}
+	}
+	switch {
+	case prefs.Duplex != "" || prefs.NumCopies > 0 || len(prefs.PrintPageRange) > 0:
+		if f.pdfVersion < "1.7" {
+			f.pdfVersion = "1.7"
+		}
+	case prefs.PrintScaling != "":
+		if f.pdfVersion < "1.6" {
+			f.pdfVersion = "1.6"
+		}
+	}
+	prefs.PrintPageRange = append([]int(nil), prefs.PrintPageRange...)
+	f.viewerPrefs = &prefs
+}
+
+// viewerPutCatalog writes the viewer preferences entry of the catalog. The
+// title is always displayed in tagged documents, as PDF/UA requires.
+func (f *Fpdf) viewerPutCatalog() {
+	var prefs ViewerPreferences
+	if f.viewerPrefs != nil {
+		prefs = *f.viewerPrefs
+	} else if !f.structTree.tagged {
+		return
+	}
+	prefs.DisplayDocTitle = prefs.DisplayDocTitle || f.structTree.tagged
+	var s fmtBuffer
+	s.printf("/ViewerPreferences <<")
+	for _, flag := range []struct {
+		key string
+		on  bool
+	}{
+		{"HideToolbar", prefs.HideToolbar},
+		{"HideMenubar", prefs.HideMenubar},
+		{"HideWindowUI", prefs.HideWindowUI},
+		{"FitWindow", prefs.FitWindow},
+		{"CenterWindow", prefs.CenterWindow},
+		{"DisplayDocTitle", prefs.DisplayDocTitle},
+	} {
+		if flag.on {
+			s.printf("/%s true ", flag.key)
+		}
+	}
+	if prefs.Duplex != "" {
+		s.printf("/Duplex /%s ", prefs.Duplex)
+	}
+	if prefs.PrintScaling != "" {
+		s.printf("/PrintScaling /%s ", prefs.PrintScaling)
+	}
+	if prefs.NumCopies > 0 {
+		s.printf("/NumCopies %d ", prefs.NumCopies)
+	}
+	if len(prefs.PrintPageRange) > 0 {
+		// Page indices are zero-based
+		s.printf("/PrintPageRange [")
+		for _, page := range prefs.PrintPageRange {
+			s.printf("%d ", page-1)
+		}
+		s.printf("] ")
+	}
+	s.printf(">>")
+	f.out(s.String())
+}
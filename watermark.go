@@ -0,0 +1,191 @@
+package gofpdf
+
+import (
+	"fmt"
+)
+
+// WatermarkLayer is the layer of the page on which a watermark is drawn
+type WatermarkLayer int
+
+const (
+	// WatermarkUnder draws a watermark behind the content of the page, before
+	// the header
+	WatermarkUnder WatermarkLayer = iota
+	// WatermarkOver draws a watermark above the content of the page, after the
+	// footer, as is usual for stamps such as "Approved"
+	WatermarkOver
+)
+
+// WatermarkOptions sets a watermark or stamp that SetWatermark() and
+// AddWatermark() apply to the pages of the document. A watermark is either a
+// text or an image. Sizes are in the unit of measure of the document.
+type WatermarkOptions struct {
+	// Text is the text of the watermark.
+	Text string
+	// FontFamily and FontStyle select the font of the text, as for SetFont();
+	// by default bold Helvetica. The font must have been added to the document
+	// if it is not a core font.
+	FontFamily, FontStyle string
+	// FontSize is the size of the text in points. If it is 0, the text is
+	// sized to fill 70 percent of the width of each page.
+	FontSize float64
+	// Color is the color of the text, gray if it is nil.
+	Color *RGBType
+	// Image is the name of the image of the watermark, as for Image(). An image
+	// that has not been registered is read from the file of that name.
+	Image string
+	// ImageWidth is the width of the image; by default half the width of each
+	// page. The height follows from the aspect ratio of the image.
+	ImageWidth float64
+	// Opacity is the opacity of the watermark, from 0 to 1; 0.3 if it is 0.
+	Opacity float64
+	// Angle is the angle in degrees by which the watermark is rotated counter-
+	// clockwise about the center of the page.
+	Angle float64
+	// Layer selects whether the watermark is drawn behind or above the content
+	// of the page.
+	Layer WatermarkLayer
+	// Pages is the range of the pages that the watermark is applied to, by
+	// default all pages.
+	Pages PageRange
+}
+
+// watermarkType is a watermark with its list of pages
+type watermarkType struct {
+	opts  WatermarkOptions
+	pages pageRanges
+}
+
+// SetWatermark sets the watermark that is applied to the pages added from now
+// on, replacing the watermarks set earlier, so that a text such as "DRAFT" or
+// "CONFIDENTIAL" or a logo appears on every page without being drawn in the
+// header or footer function. The watermark is drawn centered on the page,
+// rotated by the angle of opts, with the opacity of opts, and is an artifact;
+// the settings of the document are not changed. Passing WatermarkOptions{}
+// removes the watermarks.
+//
+// Watermarks are not applied to the pages of page builders. Page numbers are
+// those of the pages when they are added, before pages are moved by a table
+// of contents.
+//
+// An error occurs if opts has both or neither of a text and an image, if the
+// image cannot be registered, if the opacity is not between 0 and 1, or if
+// the list of pages is not valid.
+//
+// The SetWatermark example demonstrates this method.
+func (f *Fpdf) SetWatermark(opts WatermarkOptions) {
+	if f.err != nil {
+		return
+	}
+	f.watermarks = nil
+	if opts != (WatermarkOptions{}) {
+		f.AddWatermark(opts)
+	}
+}
+
+// AddWatermark adds a watermark or stamp to those applied to the pages added
+// from now on, in the same way as SetWatermark(), for example a stamp above
+// the content of the first page and a watermark behind the content of all
+// pages. Watermarks on the same layer are drawn in the order they are added.
+//
+// The SetWatermark example demonstrates this method.
+func (f *Fpdf) AddWatermark(opts WatermarkOptions) {
+	if f.err != nil {
+		return
+	}
+	switch {
+	case (opts.Text == "") == (opts.Image == ""):
+		f.err = fmt.Errorf("watermark must have either a text or an image")
+	case opts.Opacity < 0 || opts.Opacity > 1:
+		f.err = fmt.Errorf("watermark opacity (0.0 - 1.0) is out of range: %.3f", opts.Opacity)
+	case opts.FontSize < 0 || opts.ImageWidth < 0:
+		f.err = fmt.Errorf("watermark size must not be negative")
+	}
+	if f.err != nil {
+		return
+	}
+	wm := watermarkType{opts: opts}
+	if wm.pages, f.err = opts.Pages.parse(); f.err != nil {
+		return
+	}
+	if opts.Image != "" {
+		f.RegisterImage(opts.Image, "")
+		if f.err != nil {
+			return
+		}
+	}
+	f.watermarks = append(f.watermarks, wm)
+}
+
+// putWatermarks draws the watermarks on layer that apply to the current page
+func (f *Fpdf) putWatermarks(layer WatermarkLayer) {
+	for j := range f.watermarks {
+		wm := &f.watermarks[j]
+		if wm.opts.Layer == layer && wm.pages.contains(f.page) && f.err == nil {
+			f.putWatermark(&wm.opts)
+		}
+	}
+}
+
+// putWatermark draws the watermark opts centered on the current page
+func (f *Fpdf) putWatermark(opts *WatermarkOptions) {
+	opacity := opts.Opacity
+	if opacity == 0 {
+		opacity = 0.3
+	}
+	cx, cy := f.w/2, f.h/2
+	if ht := f.autoPageHeights[f.page]; ht > 0 {
+		// Pages added with AddPageAuto() end with their content
+		cy = ht / f.k / 2
+	}
+	f.BeginArtifact()
+	f.SaveGState()
+	f.SetAlpha(opacity, "Normal")
+	f.TransformBegin()
+	f.TransformRotate(opts.Angle, cx, cy)
+	if opts.Text != "" {
+		family, style := opts.FontFamily, opts.FontStyle
+		if family == "" {
+			family, style = "Helvetica", "B"
+		}
+		size := opts.FontSize
+		if size == 0 {
+			size = 10
+		}
+		// The text is written without the spacing and rise of the document
+		if f.charSpacing != 0 {
+			f.SetCharSpacing(0)
+		}
+		if f.wordSpacing != 0 {
+			f.SetWordSpacing(0)
+		}
+		if f.textRise != 0 {
+			f.SetTextRise(0)
+		}
+		f.SetFont(family, style, size)
+		if opts.FontSize == 0 {
+			if wd := f.GetStringWidth(opts.Text); wd > 0 {
+				f.SetFontSize(size * f.w * 0.7 / wd)
+			}
+		}
+		// The fill color is set as well, since the fill color of the document
+		// may not have been output on a new page yet
+		clr := RGBType{R: 128, G: 128, B: 128}
+		if opts.Color != nil {
+			clr = *opts.Color
+		}
+		f.SetFillColor(clr.R, clr.G, clr.B)
+		f.SetTextColor(clr.R, clr.G, clr.B)
+		f.Text(cx-f.GetStringWidth(opts.Text)/2, cy+f.fontSize*0.35, opts.Text)
+	} else if info := f.GetImageInfo(opts.Image); info != nil {
+		wd := opts.ImageWidth
+		if wd == 0 {
+			wd = f.w / 2
+		}
+		ht := wd * info.Height() / info.Width()
+		f.ImageOptions(opts.Image, cx-wd/2, cy-ht/2, wd, ht, false, ImageOptions{}, 0, "")
+	}
+	f.TransformEnd()
+	f.RestoreGState()
+	f.EndArtifact()
+}
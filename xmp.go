@@ -0,0 +1,126 @@
+package gofpdf
+
+import (
+	"bytes"
+	"encoding/xml"
+	"time"
+	"unicode/utf16"
+)
+
+// SetAutoXmpMetadata specifies whether XMP metadata is generated and embedded
+// with the document when it is closed. The metadata repeats the title,
+// author, subject, keywords, creator, producer and dates of the document
+// information in the Dublin Core, XMP basic and PDF schemas, where document
+// asset management systems that index XMP find them. Metadata specified with
+// SetXmpMetadata() is embedded instead.
+//
+// Metadata is always generated for documents that conform to PDF/A or PDF/X,
+// as set with SetPDFAConformance() and SetPDFXConformance().
+func (f *Fpdf) SetAutoXmpMetadata(auto bool) {
+	f.xmpAuto = auto
+}
+
+// xmpPrepare generates the XMP metadata if it is requested and has not been
+// specified
+func (f *Fpdf) xmpPrepare() {
+	if (!f.xmpAuto && f.pdfa.part == 0 && f.pdfx.version == "") || len(f.xmp) > 0 {
+		return
+	}
+	// The dates in the information dictionary and the metadata must agree
+	if f.creationDate.IsZero() {
+		f.creationDate = time.Now()
+	}
+	if f.modDate.IsZero() {
+		f.modDate = f.creationDate
+	}
+	f.xmp = f.xmpMetadata()
+}
+
+// infoText returns the UTF-8 representation of a document information string,
+// which is stored either as UTF-16 with a byte order mark or as ISO-8859-1
+func infoText(s string) string {
+	if len(s) >= 2 && s[0] == 0xfe && s[1] == 0xff {
+		u := make([]uint16, 0, len(s)/2)
+		for j := 2; j+1 < len(s); j += 2 {
+			u = append(u, uint16(s[j])<<8|uint16(s[j+1]))
+		}
+		return string(utf16.Decode(u))
+	}
+	r := make([]rune, len(s))
+	for j := 0; j < len(s); j++ {
+		r[j] = rune(s[j])
+	}
+	return string(r)
+}
+
+// xmlText returns s, converted from a document information string, with XML
+// special characters escaped
+func xmlText(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(infoText(s)))
+	return buf.String()
+}
+
+// xmpMetadata returns an XMP metadata packet that repeats the document
+// information dictionary and identifies the PDF/A and PDF/X levels, if any
+func (f *Fpdf) xmpMetadata() []byte {
+	// Dates in the information dictionary carry no time zone, so none is
+	// specified here either
+	const xmpDate = "2006-01-02T15:04:05"
+	var b fmtBuffer
+	b.printf("<?xpacket begin=\"\xef\xbb\xbf\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	b.printf("<x:xmpmeta xmlns:x=\"adobe:ns:meta/\">\n")
+	b.printf("<rdf:RDF xmlns:rdf=\"http://www.w3.org/1999/02/22-rdf-syntax-ns#\">\n")
+	if f.pdfa.part > 0 {
+		b.printf("<rdf:Description rdf:about=\"\" xmlns:pdfaid=\"http://www.aiim.org/pdfa/ns/id/\">\n")
+		b.printf("<pdfaid:part>%d</pdfaid:part>\n", f.pdfa.part)
+		b.printf("<pdfaid:conformance>%s</pdfaid:conformance>\n", f.pdfa.conformance)
+		b.printf("</rdf:Description>\n")
+	}
+	if f.pdfx.version != "" {
+		b.printf("<rdf:Description rdf:about=\"\" xmlns:pdfxid=\"http://www.npes.org/pdfx/ns/id/\">\n")
+		b.printf("<pdfxid:GTS_PDFXVersion>%s</pdfxid:GTS_PDFXVersion>\n", f.pdfx.version)
+		b.printf("</rdf:Description>\n")
+		b.printf("<rdf:Description rdf:about=\"\" xmlns:xmpMM=\"http://ns.adobe.com/xap/1.0/mm/\">\n")
+		id := f.pdfxDocumentID()
+		b.printf("<xmpMM:DocumentID>%s</xmpMM:DocumentID>\n", id)
+		b.printf("<xmpMM:InstanceID>%s</xmpMM:InstanceID>\n", id)
+		b.printf("<xmpMM:VersionID>1</xmpMM:VersionID>\n")
+		b.printf("<xmpMM:RenditionClass>default</xmpMM:RenditionClass>\n")
+		b.printf("</rdf:Description>\n")
+	}
+	b.printf("<rdf:Description rdf:about=\"\" xmlns:dc=\"http://purl.org/dc/elements/1.1/\">\n")
+	b.printf("<dc:format>application/pdf</dc:format>\n")
+	if len(f.title) > 0 {
+		b.printf("<dc:title><rdf:Alt><rdf:li xml:lang=\"x-default\">%s</rdf:li></rdf:Alt></dc:title>\n", xmlText(f.title))
+	}
+	if len(f.author) > 0 {
+		b.printf("<dc:creator><rdf:Seq><rdf:li>%s</rdf:li></rdf:Seq></dc:creator>\n", xmlText(f.author))
+	}
+	if len(f.subject) > 0 {
+		b.printf("<dc:description><rdf:Alt><rdf:li xml:lang=\"x-default\">%s</rdf:li></rdf:Alt></dc:description>\n", xmlText(f.subject))
+	}
+	b.printf("</rdf:Description>\n")
+	b.printf("<rdf:Description rdf:about=\"\" xmlns:pdf=\"http://ns.adobe.com/pdf/1.3/\">\n")
+	if len(f.producer) > 0 {
+		b.printf("<pdf:Producer>%s</pdf:Producer>\n", xmlText(f.producer))
+	}
+	if len(f.keywords) > 0 {
+		b.printf("<pdf:Keywords>%s</pdf:Keywords>\n", xmlText(f.keywords))
+	}
+	if f.pdfx.version != "" {
+		b.printf("<pdf:Trapped>False</pdf:Trapped>\n")
+	}
+	b.printf("</rdf:Description>\n")
+	b.printf("<rdf:Description rdf:about=\"\" xmlns:xmp=\"http://ns.adobe.com/xap/1.0/\">\n")
+	if len(f.creator) > 0 {
+		b.printf("<xmp:CreatorTool>%s</xmp:CreatorTool>\n", xmlText(f.creator))
+	}
+	b.printf("<xmp:CreateDate>%s</xmp:CreateDate>\n", timeOrNow(f.creationDate).Format(xmpDate))
+	b.printf("<xmp:ModifyDate>%s</xmp:ModifyDate>\n", timeOrNow(f.modDate).Format(xmpDate))
+	b.printf("</rdf:Description>\n")
+	b.printf("</rdf:RDF>\n")
+	b.printf("</x:xmpmeta>\n")
+	b.printf("<?xpacket end=\"w\"?>")
+	return b.Bytes()
+}
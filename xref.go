@@ -0,0 +1,158 @@
+package gofpdf
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+)
+
+// objStmSize is the maximum number of objects packed into an object stream
+const objStmSize = 100
+
+// compactXref returns true if the document ends with a cross-reference stream
+// rather than a cross-reference table, which is the case for documents of
+// version 1.5 or later set with SetPDFVersion(), except for PDF/A-1
+// documents
+func (f *Fpdf) compactXref() bool {
+	return f.xrefStream && f.pdfVersion >= "1.5" && f.pdfa.part != 1
+}
+
+// putObjectStreams packs the objects that are not streams into object
+// streams, and returns the object stream and the index within it of each
+// object packed. Objects are not packed if the document is encrypted, since
+// their strings are encrypted with their own object numbers, or signed, since
+// the signature refers to positions in the output.
+func (f *Fpdf) putObjectStreams() map[int][2]int {
+	if f.protect.encrypted || f.signature.provider != nil {
+		return nil
+	}
+	base := f.offset() - f.buffer.Len()
+	objs := make([]int, 0, f.n)
+	for j := 1; j <= f.n; j++ {
+		if f.offsets[j] >= base && f.offsets[j] > 0 {
+			objs = append(objs, j)
+		}
+	}
+	if len(objs) == 0 {
+		return nil
+	}
+	sort.SliceStable(objs, func(a, b int) bool { return f.offsets[objs[a]] < f.offsets[objs[b]] })
+	buf := append([]byte(nil), f.buffer.Bytes()...)
+	// The objects in the output, with their bodies if they can be packed
+	type objType struct {
+		num        int
+		start, end int
+		body       []byte
+	}
+	list := make([]objType, len(objs))
+	var packed int
+	for k, j := range objs {
+		start := f.offsets[j] - base
+		end := len(buf)
+		if k+1 < len(objs) {
+			end = f.offsets[objs[k+1]] - base
+		}
+		list[k] = objType{num: j, start: start, end: end}
+		obj := buf[start:end]
+		prefix := []byte(strconv.Itoa(j) + " 0 obj\n")
+		if bytes.HasPrefix(obj, prefix) && bytes.HasSuffix(obj, []byte("\nendobj\n")) &&
+			!bytes.HasSuffix(obj, []byte("endstream\nendobj\n")) {
+			list[k].body = obj[len(prefix) : len(obj)-len("endobj\n")]
+			packed++
+		}
+	}
+	if packed == 0 {
+		return nil
+	}
+	// The objects that are not packed remain in place
+	f.buffer.Truncate(list[0].start)
+	for _, obj := range list {
+		if obj.body == nil {
+			f.offsets[obj.num] = f.offset()
+			f.buffer.Write(buf[obj.start:obj.end])
+		}
+	}
+	index := make(map[int][2]int, packed)
+	var nums, bodies fmtBuffer
+	var count int
+	flush := func() {
+		if count == 0 {
+			return
+		}
+		data := append(nums.Bytes(), bodies.Bytes()...)
+		first := nums.Len()
+		f.newobj()
+		f.outf("<</Type /ObjStm /N %d /First %d", count, first)
+		if f.compress {
+			data = f.compressStream(data)
+			f.outf("/Filter /%s", f.filterName())
+		}
+		f.outf("/Length %d>>", len(data))
+		f.putstream(data)
+		f.out("endobj")
+		nums.Truncate(0)
+		bodies.Truncate(0)
+		count = 0
+	}
+	for _, obj := range list {
+		if obj.body == nil {
+			continue
+		}
+		// The object stream is numbered once it is complete
+		index[obj.num] = [2]int{f.n + 1, count}
+		nums.printf("%d %d ", obj.num, bodies.Len())
+		bodies.Write(obj.body)
+		count++
+		if count == objStmSize {
+			flush()
+		}
+	}
+	flush()
+	return index
+}
+
+// putXrefStream ends the document with a cross-reference stream that holds
+// the entries of the trailer dictionary. root and info are the object
+// numbers of the catalog and the document information dictionary.
+func (f *Fpdf) putXrefStream(root, info int) {
+	index := f.putObjectStreams()
+	f.newobj()
+	o := f.offsets[f.n]
+	// The width of the field of the offsets and object stream numbers, of
+	// which the offset of the cross-reference stream is the largest
+	w := 1
+	for v := o; v > 0xff; v >>= 8 {
+		w++
+	}
+	var data []byte
+	entry := func(tp, field2, field3 int) {
+		data = append(data, byte(tp))
+		for j := w - 1; j >= 0; j-- {
+			data = append(data, byte(field2>>(8*uint(j))))
+		}
+		data = append(data, byte(field3>>8), byte(field3))
+	}
+	entry(0, 0, 65535)
+	for j := 1; j <= f.n; j++ {
+		if ix, ok := index[j]; ok {
+			entry(2, ix[0], ix[1])
+		} else {
+			entry(1, f.offsets[j], 0)
+		}
+	}
+	f.outf("<</Type /XRef /W [1 %d 2]", w)
+	f.putTrailerEntries(f.n+1, root, info)
+	if f.compress {
+		data = f.compressFlate(data)
+		f.out("/Filter /FlateDecode")
+	}
+	f.outf("/Length %d>>", len(data))
+	// Cross-reference streams are not encrypted
+	f.out("stream")
+	f.out(string(data))
+	f.out("endstream")
+	f.out("endobj")
+	f.out("startxref")
+	f.outf("%d", o)
+	f.out("%%EOF")
+}